@@ -0,0 +1,80 @@
+package account
+
+import (
+	"github.com/teamhanko/hanko/backend/persistence"
+)
+
+// exportPageSize is the number of users loaded at once by ExportUsers
+const exportPageSize = 100
+
+// ExportUsers writes all users in the portable format, so they can be imported into another instance. It returns the
+// number of exported users.
+func ExportUsers(persister persistence.Persister, writer PortableWriter) (int, error) {
+	count := 0
+	for page := 1; ; page++ {
+		users, err := persister.GetUserPersister().List(page, exportPageSize)
+		if err != nil {
+			return count, err
+		}
+
+		for _, user := range users {
+			id := user.ID
+			createdAt := user.CreatedAt
+			portable := PortableUser{
+				ID:        &id,
+				Email:     user.Email,
+				Verified:  user.Verified,
+				CreatedAt: &createdAt,
+			}
+
+			emails, err := persister.GetEmailPersister().FindByUserId(user.ID)
+			if err != nil {
+				return count, err
+			}
+			for _, email := range emails {
+				if email.Address == user.Email {
+					continue
+				}
+				portable.Emails = append(portable.Emails, PortableEmail{Address: email.Address, Verified: email.Verified})
+			}
+
+			password, err := persister.GetPasswordCredentialPersister().GetByUserID(user.ID)
+			if err != nil {
+				return count, err
+			}
+			if password != nil {
+				portable.PasswordHash = password.Password
+			}
+
+			credentials, err := persister.GetWebauthnCredentialPersister().GetFromUser(user.ID)
+			if err != nil {
+				return count, err
+			}
+			for _, credential := range credentials {
+				exported := PortableWebauthnCredential{
+					ID:              credential.ID,
+					PublicKey:       credential.PublicKey,
+					AttestationType: credential.AttestationType,
+					AAGUID:          credential.AAGUID,
+					SignCount:       credential.SignCount,
+				}
+				for _, transport := range credential.Transports {
+					exported.Transports = append(exported.Transports, transport.Name)
+				}
+				portable.WebauthnCredentials = append(portable.WebauthnCredentials, exported)
+			}
+
+			err = writer.Write(portable)
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if len(users) < exportPageSize {
+			break
+		}
+	}
+
+	return count, writer.Flush()
+}
@@ -0,0 +1,48 @@
+package account
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+func TestExportUsers_RoundTrip(t *testing.T) {
+	john := models.NewUser("john.doe@example.com")
+	john.Verified = true
+	jane := models.NewUser("jane.doe@example.com")
+	credential := models.WebauthnCredential{ID: "AAAA", UserId: john.ID, PublicKey: testPublicKey, SignCount: 5, CreatedAt: john.CreatedAt, UpdatedAt: john.CreatedAt}
+	source := test.NewPersister([]models.User{john, jane}, nil, nil, []models.WebauthnCredential{credential}, nil, nil, nil, nil, nil)
+
+	for _, format := range []string{FormatJSONL, FormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			var buffer bytes.Buffer
+			writer, err := NewPortableWriter(&buffer, format)
+			require.NoError(t, err)
+			count, err := ExportUsers(source, writer)
+			require.NoError(t, err)
+			assert.Equal(t, 2, count)
+			assert.NotContains(t, buffer.String(), "\"is_admin\"")
+
+			target := test.NewPersister(nil, nil, nil, nil, nil, nil, nil, nil, nil)
+			report := importUsers(t, target, format, buffer.String(), false)
+			assert.Equal(t, 2, report.Created)
+			assert.Empty(t, report.Failures)
+
+			imported, err := target.GetUserPersister().Get(john.ID)
+			require.NoError(t, err)
+			if assert.NotNil(t, imported) {
+				assert.True(t, imported.Verified)
+			}
+			importedCredential, err := target.GetWebauthnCredentialPersister().Get("AAAA")
+			require.NoError(t, err)
+			if assert.NotNil(t, importedCredential) {
+				assert.Equal(t, 5, importedCredential.SignCount)
+				assert.Equal(t, john.ID, importedCredential.UserId)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package account
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/webhook"
+)
+
+// Deleter removes accounts together with the data referencing them. Login audits, security events, and posts and
+// activities as guest of other accounts are kept, but the user ID is replaced by a random pseudonym.
+type Deleter struct {
+	persister persistence.Persister
+	now       func() time.Time
+}
+
+func NewDeleter(persister persistence.Persister) *Deleter {
+	return &Deleter{persister: persister, now: time.Now}
+}
+
+// Delete removes the user. The relations to other accounts, as guest and as account holder, are revoked and pending
+// access grants are invalidated before. The revoked relations and the deletion are announced to the webhooks.
+func (d *Deleter) Delete(user models.User) error {
+	pseudonym, err := uuid.NewV4()
+	if err != nil {
+		return fmt.Errorf("failed to create pseudonym: %w", err)
+	}
+
+	return d.persister.Transaction(func(tx *pop.Connection) error {
+		now := d.now().UTC()
+
+		relationPersister := d.persister.GetUserGuestRelationPersisterWithConnection(tx)
+		webhookPersister := d.persister.GetWebhookPersisterWithConnection(tx)
+		asGuest, err := relationPersister.GetByGuestUserId(&user.ID)
+		if err != nil {
+			return err
+		}
+		asParent, err := relationPersister.GetByParentUserId(&user.ID)
+		if err != nil {
+			return err
+		}
+		for _, relation := range append(asGuest, asParent...) {
+			wasActive := relation.IsActive
+			relation.IsActive = false
+			relation.UpdatedAt = now
+			err = relationPersister.Update(relation)
+			if err != nil {
+				return err
+			}
+			if wasActive {
+				err = webhook.Enqueue(webhookPersister, models.WebhookEventRelationRevoked, webhook.NewRelationData(relation, webhook.ReasonAccountDeleted))
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		grantPersister := d.persister.GetAccountAccessGrantPersisterWithConnection(tx)
+		grants, err := grantPersister.GetByUserId(user.ID)
+		if err != nil {
+			return err
+		}
+		for _, grant := range grants {
+			if !grant.IsActive {
+				continue
+			}
+			grant.IsActive = false
+			grant.UpdatedAt = now
+			err = grantPersister.Update(grant)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = d.persister.GetLoginAuditLogPersisterWithConnection(tx).Pseudonymise(user.ID, pseudonym)
+		if err != nil {
+			return err
+		}
+
+		err = d.persister.GetSecurityEventPersisterWithConnection(tx).Pseudonymise(user.ID, pseudonym)
+		if err != nil {
+			return err
+		}
+
+		postPersister := d.persister.GetPostPersisterWithConnection(tx)
+		err = postPersister.DeleteByUserId(user.ID)
+		if err != nil {
+			return err
+		}
+		err = postPersister.PseudonymiseSurrogate(user.ID, pseudonym)
+		if err != nil {
+			return err
+		}
+		// the previous versions of the posts of the account are deleted with the posts
+		err = d.persister.GetPostHistoryPersisterWithConnection(tx).PseudonymiseSurrogate(user.ID, pseudonym)
+		if err != nil {
+			return err
+		}
+
+		err = d.persister.GetGuestActivityPersisterWithConnection(tx).PseudonymiseSurrogate(user.ID, pseudonym)
+		if err != nil {
+			return err
+		}
+
+		err = d.persister.GetUserPersisterWithConnection(tx).Delete(user)
+		if err != nil {
+			return err
+		}
+
+		return webhook.Enqueue(webhookPersister, models.WebhookEventUserDeleted, webhook.UserData{UserId: user.ID})
+	})
+}
+
+// DeleteDue deletes all accounts whose grace period has passed and returns how many were deleted. An account that
+// can't be deleted doesn't stop the deletion of the others, it is tried again in the next run.
+func (d *Deleter) DeleteDue() (int, error) {
+	users, err := d.persister.GetUserPersister().FindDeletionDue(d.now().UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, user := range users {
+		err = d.Delete(user)
+		if err != nil {
+			log.Printf("failed to delete user %s: %v", user.ID, err)
+			continue
+		}
+		deleted++
+	}
+
+	if deleted < len(users) {
+		return deleted, fmt.Errorf("failed to delete %d of %d accounts", len(users)-deleted, len(users))
+	}
+
+	return deleted, nil
+}
+
+// Run deletes the due accounts in the given interval. It does not return.
+func (d *Deleter) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		count, err := d.DeleteDue()
+		if err != nil {
+			log.Printf("failed to delete accounts: %v", err)
+		}
+		if count > 0 {
+			log.Printf("deleted %d accounts after the grace period", count)
+		}
+	}
+}
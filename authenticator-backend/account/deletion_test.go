@@ -0,0 +1,178 @@
+package account
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+func TestDeleter_Delete(t *testing.T) {
+	user := models.NewUser("john.doe@example.com")
+	other := models.NewUser("jane.doe@example.com")
+	now := time.Now().UTC()
+
+	asGuest := models.UserGuestRelation{ID: uuid.Must(uuid.NewV4()), GuestUserID: user.ID, ParentUserID: other.ID, IsActive: true, CreatedAt: now, UpdatedAt: now}
+	asParent := models.UserGuestRelation{ID: uuid.Must(uuid.NewV4()), GuestUserID: other.ID, ParentUserID: user.ID, IsActive: true, CreatedAt: now, UpdatedAt: now}
+	pendingGrant := models.AccountAccessGrant{ID: uuid.Must(uuid.NewV4()), UserId: user.ID, Token: "abcdefgh", IsActive: true, CreatedAt: now, UpdatedAt: now}
+	ownLogin := models.LoginAuditLog{ID: uuid.Must(uuid.NewV4()), UserId: user.ID, ClientIpAddress: "127.0.0.1", ClientUserAgent: "test", LoginMethod: 1}
+	guestLogin := models.LoginAuditLog{ID: uuid.Must(uuid.NewV4()), UserId: other.ID, SurrogateUserId: &user.ID, UserGuestRelationId: &asGuest.ID, ClientIpAddress: "127.0.0.1", ClientUserAgent: "test", LoginMethod: 3}
+	otherLogin := models.LoginAuditLog{ID: uuid.Must(uuid.NewV4()), UserId: other.ID, ClientIpAddress: "127.0.0.1", ClientUserAgent: "test", LoginMethod: 1}
+
+	p := test.NewPersister(
+		[]models.User{user, other}, nil, nil, nil, nil, nil,
+		[]models.AccountAccessGrant{pendingGrant},
+		[]models.UserGuestRelation{asGuest, asParent},
+		[]models.LoginAuditLog{ownLogin, guestLogin, otherLogin},
+	)
+	ownPost := models.Post{ID: uuid.Must(uuid.NewV4()), CreatedByUserId: user.ID, CreatedBySurrogateId: user.ID, UpdatedByUserId: user.ID, UpdatedBySurrogateId: user.ID, Data: "own", CreatedAt: now, UpdatedAt: now}
+	guestPost := models.Post{ID: uuid.Must(uuid.NewV4()), CreatedByUserId: other.ID, CreatedBySurrogateId: user.ID, UpdatedByUserId: other.ID, UpdatedBySurrogateId: user.ID, Data: "as guest", CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, p.GetPostPersister().Create(ownPost))
+	require.NoError(t, p.GetPostPersister().Create(guestPost))
+	require.NoError(t, p.GetPostHistoryPersister().Create(models.NewPostHistory(guestPost, now)))
+	event := models.NewSecurityEvent(models.EventGrantCreated)
+	event.ActorUserId = &user.ID
+	event.ClientIpAddress = "127.0.0.1"
+	require.NoError(t, p.GetSecurityEventPersister().Create(event))
+	activity := models.GuestActivity{ID: uuid.Must(uuid.NewV4()), UserGuestRelationId: asGuest.ID, UserId: other.ID, SurrogateUserId: user.ID, Action: models.GuestActivityUpdated, ResourceType: models.GuestActivityResourcePost, ResourceId: guestPost.ID, CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, p.GetGuestActivityPersister().Create(activity))
+
+	require.NoError(t, NewDeleter(p).Delete(user))
+
+	deleted, err := p.GetUserPersister().Get(user.ID)
+	require.NoError(t, err)
+	assert.Nil(t, deleted)
+
+	for _, id := range []uuid.UUID{asGuest.ID, asParent.ID} {
+		relation, err := p.GetUserGuestRelationPersister().Get(id)
+		require.NoError(t, err)
+		assert.False(t, relation.IsActive)
+	}
+
+	grant, err := p.GetAccountAccessGrantPersister().Get(pendingGrant.ID)
+	require.NoError(t, err)
+	assert.False(t, grant.IsActive)
+
+	logs, err := p.GetLoginAuditLogPersister().GetByPrimaryUserId(user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, logs)
+	logs, err = p.GetLoginAuditLogPersister().GetByGuestUserId(user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, logs)
+	logs, err = p.GetLoginAuditLogPersister().GetByPrimaryUserId(other.ID)
+	require.NoError(t, err)
+	if assert.Len(t, logs, 2) {
+		assert.Equal(t, models.Redacted, logs[0].ClientIpAddress)
+		assert.NotEqual(t, user.ID, *logs[0].SurrogateUserId)
+		assert.Equal(t, "127.0.0.1", logs[1].ClientIpAddress)
+	}
+
+	events, _, err := p.GetSecurityEventPersister().Search(persistence.SecurityEventFilter{UserId: &user.ID}, 1, 10)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+	events, _, err = p.GetSecurityEventPersister().Search(persistence.SecurityEventFilter{}, 1, 10)
+	require.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, models.Redacted, events[0].ClientIpAddress)
+	}
+
+	posts, err := p.GetPostPersister().List(1, 10)
+	require.NoError(t, err)
+	if assert.Len(t, posts, 1) {
+		assert.Equal(t, guestPost.ID, posts[0].ID)
+		assert.NotEqual(t, user.ID, posts[0].CreatedBySurrogateId)
+		assert.NotEqual(t, user.ID, posts[0].UpdatedBySurrogateId)
+	}
+	histories, err := p.GetPostHistoryPersister().ListByPost(guestPost.ID)
+	require.NoError(t, err)
+	if assert.Len(t, histories, 1) {
+		assert.NotEqual(t, user.ID, histories[0].CreatedBySurrogateId)
+		assert.NotEqual(t, user.ID, histories[0].UpdatedBySurrogateId)
+	}
+	activities, _, err := p.GetGuestActivityPersister().ListByRelation(asGuest.ID, 1, 10)
+	require.NoError(t, err)
+	if assert.Len(t, activities, 1) {
+		assert.Equal(t, other.ID, activities[0].UserId)
+		assert.NotEqual(t, user.ID, activities[0].SurrogateUserId)
+	}
+
+	webhookEvents, err := p.GetWebhookPersister().ListUndispatchedEvents(10)
+	require.NoError(t, err)
+	var webhookEventTypes []string
+	for _, webhookEvent := range webhookEvents {
+		webhookEventTypes = append(webhookEventTypes, webhookEvent.Type)
+	}
+	assert.Equal(t, []string{models.WebhookEventRelationRevoked, models.WebhookEventRelationRevoked, models.WebhookEventUserDeleted}, webhookEventTypes)
+}
+
+func TestDeleter_DeleteDue(t *testing.T) {
+	due := models.NewUser("john.doe@example.com")
+	past := time.Now().Add(-time.Minute)
+	due.DeletionScheduledAt = &past
+	pending := models.NewUser("jane.doe@example.com")
+	future := time.Now().Add(time.Hour)
+	pending.DeletionScheduledAt = &future
+	kept := models.NewUser("max@example.com")
+
+	p := test.NewPersister([]models.User{due, pending, kept}, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	count, err := NewDeleter(p).DeleteDue()
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	for _, user := range []models.User{due, pending, kept} {
+		found, err := p.GetUserPersister().Get(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID != due.ID, found != nil)
+	}
+}
+
+// failingUserPersister fails to delete the given user
+type failingUserPersister struct {
+	persistence.UserPersister
+	failing uuid.UUID
+}
+
+func (p failingUserPersister) Delete(user models.User) error {
+	if user.ID == p.failing {
+		return errors.New("failed to delete user")
+	}
+	return p.UserPersister.Delete(user)
+}
+
+type failingPersister struct {
+	persistence.Persister
+	userPersister persistence.UserPersister
+}
+
+func (p failingPersister) GetUserPersisterWithConnection(_ *pop.Connection) persistence.UserPersister {
+	return p.userPersister
+}
+
+func TestDeleter_DeleteDue_ContinuesAfterFailure(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	failing := models.NewUser("john.doe@example.com")
+	failing.DeletionScheduledAt = &past
+	due := models.NewUser("jane.doe@example.com")
+	due.DeletionScheduledAt = &past
+
+	p := test.NewPersister([]models.User{failing, due}, nil, nil, nil, nil, nil, nil, nil, nil)
+	deleter := NewDeleter(failingPersister{Persister: p, userPersister: failingUserPersister{UserPersister: p.GetUserPersister(), failing: failing.ID}})
+
+	count, err := deleter.DeleteDue()
+	assert.Error(t, err)
+	assert.Equal(t, 1, count)
+
+	for _, user := range []models.User{failing, due} {
+		found, err := p.GetUserPersister().Get(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID == failing.ID, found != nil)
+	}
+}
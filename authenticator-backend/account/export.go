@@ -0,0 +1,293 @@
+package account
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+// ExportFileName is the name of the JSON file in a zipped export
+const ExportFileName = "hanko-export.json"
+
+// Exporter collects the data stored about a user for a copy handed out to the user
+type Exporter struct {
+	persister persistence.Persister
+	now       func() time.Time
+}
+
+func NewExporter(persister persistence.Persister) *Exporter {
+	return &Exporter{persister: persister, now: time.Now}
+}
+
+// Export returns the data of the user or nil if the user does not exist
+func (e *Exporter) Export(userId uuid.UUID) (*dto.UserExport, error) {
+	user, err := e.persister.GetUserPersister().Get(userId)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, nil
+	}
+
+	export := &dto.UserExport{
+		ExportedAt:          e.now().UTC(),
+		Emails:              []dto.UserExportEmail{},
+		WebauthnCredentials: []dto.UserExportWebauthnCredential{},
+		LoginAuditLogs: dto.UserExportLoginAuditLogs{
+			AsAccountHolder: []dto.UserExportLoginAuditLog{},
+			AsGuest:         []dto.UserExportLoginAuditLog{},
+		},
+		AccessGrants: dto.UserExportAccessGrants{
+			Created: []dto.UserExportAccessGrant{},
+			Claimed: []dto.UserExportAccessGrant{},
+		},
+		Relations: dto.UserExportUserGuestRelations{
+			AsAccountHolder: []dto.UserExportUserGuestRelation{},
+			AsGuest:         []dto.UserExportUserGuestRelation{},
+		},
+		Posts:         []dto.UserExportPost{},
+		PostHistories: []dto.UserExportPostHistory{},
+	}
+
+	export.User, err = e.exportUser(*user)
+	if err != nil {
+		return nil, err
+	}
+
+	emails, err := e.persister.GetEmailPersister().FindByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, email := range emails {
+		export.Emails = append(export.Emails, dto.UserExportEmail{
+			Address:   email.Address,
+			Verified:  email.Verified,
+			Primary:   email.Primary,
+			CreatedAt: email.CreatedAt,
+		})
+	}
+
+	credentials, err := e.persister.GetWebauthnCredentialPersister().GetFromUser(userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, credential := range credentials {
+		transports := []string{}
+		for _, transport := range credential.Transports {
+			transports = append(transports, transport.Name)
+		}
+		export.WebauthnCredentials = append(export.WebauthnCredentials, dto.UserExportWebauthnCredential{
+			ID:              credential.ID,
+			AAGUID:          credential.AAGUID,
+			AttestationType: credential.AttestationType,
+			Transports:      transports,
+			CreatedAt:       credential.CreatedAt,
+			UpdatedAt:       credential.UpdatedAt,
+		})
+	}
+
+	auditLogPersister := e.persister.GetLoginAuditLogPersister()
+	logs, err := auditLogPersister.GetByPrimaryUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, log := range logs {
+		export.LoginAuditLogs.AsAccountHolder = append(export.LoginAuditLogs.AsAccountHolder, exportLoginAuditLog(log))
+	}
+	logs, err = auditLogPersister.GetByGuestUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, log := range logs {
+		export.LoginAuditLogs.AsGuest = append(export.LoginAuditLogs.AsGuest, exportLoginAuditLog(log))
+	}
+
+	grantPersister := e.persister.GetAccountAccessGrantPersister()
+	grants, err := grantPersister.GetByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, grant := range grants {
+		export.AccessGrants.Created = append(export.AccessGrants.Created, exportAccessGrant(grant))
+	}
+	grants, err = grantPersister.GetByClaimedBy(userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, grant := range grants {
+		export.AccessGrants.Claimed = append(export.AccessGrants.Claimed, exportAccessGrant(grant))
+	}
+
+	relations, err := e.persister.GetUserGuestRelationPersister().FindByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, relation := range relations {
+		exported := dto.UserExportUserGuestRelation{
+			ID:             relation.ID,
+			GuestUserId:    relation.GuestUserID,
+			ParentUserId:   relation.ParentUserID,
+			IsActive:       relation.IsActive,
+			LoginsAllowed:  nullInt32(relation.LoginsAllowed),
+			MinutesAllowed: nullInt32(relation.MinutesAllowed),
+			CreatedAt:      relation.CreatedAt,
+			UpdatedAt:      relation.UpdatedAt,
+		}
+		if relation.ParentUserID == userId {
+			export.Relations.AsAccountHolder = append(export.Relations.AsAccountHolder, exported)
+		} else {
+			export.Relations.AsGuest = append(export.Relations.AsGuest, exported)
+		}
+	}
+
+	posts, err := e.persister.GetPostPersister().FindByAuthor(userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		export.Posts = append(export.Posts, dto.UserExportPost{
+			ID:                   post.ID,
+			CreatedByUserId:      post.CreatedByUserId,
+			CreatedBySurrogateId: post.CreatedBySurrogateId,
+			UpdatedByUserId:      post.UpdatedByUserId,
+			UpdatedBySurrogateId: post.UpdatedBySurrogateId,
+			Data:                 post.Data,
+			IsActive:             post.IsActive,
+			Visibility:           post.Visibility,
+			Version:              post.Version,
+			CreatedAt:            post.CreatedAt,
+			UpdatedAt:            post.UpdatedAt,
+		})
+	}
+
+	histories, err := e.persister.GetPostHistoryPersister().FindByAuthor(userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, history := range histories {
+		export.PostHistories = append(export.PostHistories, dto.UserExportPostHistory{
+			ID:                   history.ID,
+			PostId:               history.PostId,
+			Version:              history.Version,
+			UpdatedByUserId:      history.UpdatedByUserId,
+			UpdatedBySurrogateId: history.UpdatedBySurrogateId,
+			Data:                 history.Data,
+			Visibility:           history.Visibility,
+			CreatedAt:            history.CreatedAt,
+			UpdatedAt:            history.UpdatedAt,
+		})
+	}
+
+	return export, nil
+}
+
+func (e *Exporter) exportUser(user models.User) (dto.UserExportUser, error) {
+	password, err := e.persister.GetPasswordCredentialPersister().GetByUserID(user.ID)
+	if err != nil {
+		return dto.UserExportUser{}, err
+	}
+	totp, err := e.persister.GetTotpCredentialPersister().GetByUserId(user.ID)
+	if err != nil {
+		return dto.UserExportUser{}, err
+	}
+	recoveryCodes, err := e.persister.GetRecoveryCodePersister().GetByUserId(user.ID)
+	if err != nil {
+		return dto.UserExportUser{}, err
+	}
+	roles, err := e.persister.GetRolePersister().FindByUserId(user.ID)
+	if err != nil {
+		return dto.UserExportUser{}, err
+	}
+	roleNames := []string{}
+	for _, role := range roles {
+		roleNames = append(roleNames, role.Name)
+	}
+
+	return dto.UserExportUser{
+		ID:                  user.ID,
+		Email:               user.Email,
+		Verified:            user.Verified,
+		IsActive:            user.IsActive,
+		Roles:               roleNames,
+		HasPassword:         password != nil,
+		HasTotp:             totp != nil,
+		RecoveryCodes:       len(recoveryCodes),
+		DeletionScheduledAt: user.DeletionScheduledAt,
+		CreatedAt:           user.CreatedAt,
+		UpdatedAt:           user.UpdatedAt,
+	}, nil
+}
+
+func exportLoginAuditLog(log models.LoginAuditLog) dto.UserExportLoginAuditLog {
+	return dto.UserExportLoginAuditLog{
+		ID:                  log.ID,
+		UserId:              log.UserId,
+		SurrogateUserId:     log.SurrogateUserId,
+		UserGuestRelationId: log.UserGuestRelationId,
+		LoginMethod:         log.LoginMethod,
+		ClientIpAddress:     log.ClientIpAddress,
+		ClientUserAgent:     log.ClientUserAgent,
+		CreatedAt:           log.CreatedAt,
+	}
+}
+
+func exportAccessGrant(grant models.AccountAccessGrant) dto.UserExportAccessGrant {
+	return dto.UserExportAccessGrant{
+		ID:             grant.ID,
+		UserId:         grant.UserId,
+		Email:          grant.Email,
+		IsActive:       grant.IsActive,
+		ClaimedBy:      grant.ClaimedBy,
+		LoginsAllowed:  nullInt32(grant.LoginsAllowed),
+		MinutesAllowed: nullInt32(grant.MinutesAllowed),
+		CreatedAt:      grant.CreatedAt,
+	}
+}
+
+func nullInt32(value sql.NullInt32) *int32 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Int32
+}
+
+// WriteJSON writes the export as indented JSON
+func WriteJSON(w io.Writer, export *dto.UserExport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(export)
+	if err != nil {
+		return fmt.Errorf("failed to encode export: %w", err)
+	}
+	return nil
+}
+
+// WriteZip writes a zip archive which contains the export as JSON file
+func WriteZip(w io.Writer, export *dto.UserExport) error {
+	archive := zip.NewWriter(w)
+	file, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     ExportFileName,
+		Method:   zip.Deflate,
+		Modified: export.ExportedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create export archive: %w", err)
+	}
+	err = WriteJSON(file, export)
+	if err != nil {
+		return err
+	}
+	err = archive.Close()
+	if err != nil {
+		return fmt.Errorf("failed to create export archive: %w", err)
+	}
+	return nil
+}
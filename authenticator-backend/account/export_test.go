@@ -0,0 +1,121 @@
+package account
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+func TestExporter_Export(t *testing.T) {
+	user := models.NewUser("john.doe@example.com")
+	other := models.NewUser("jane.doe@example.com")
+	now := time.Now().UTC()
+
+	credential := models.WebauthnCredential{ID: "credential", UserId: user.ID, PublicKey: "secret public key", AttestationType: "none", CreatedAt: now, UpdatedAt: now}
+	password := models.PasswordCredential{ID: uuid.Must(uuid.NewV4()), UserId: user.ID, Password: "secret hash", CreatedAt: now, UpdatedAt: now}
+	asGuest := models.UserGuestRelation{ID: uuid.Must(uuid.NewV4()), GuestUserID: user.ID, ParentUserID: other.ID, IsActive: false, CreatedAt: now, UpdatedAt: now}
+	asParent := models.UserGuestRelation{ID: uuid.Must(uuid.NewV4()), GuestUserID: other.ID, ParentUserID: user.ID, IsActive: true, CreatedAt: now, UpdatedAt: now}
+	created := models.AccountAccessGrant{ID: uuid.Must(uuid.NewV4()), UserId: user.ID, Token: "secret token", IsActive: true, CreatedAt: now, UpdatedAt: now}
+	claimed := models.AccountAccessGrant{ID: uuid.Must(uuid.NewV4()), UserId: other.ID, Token: "secret token", ClaimedBy: &user.ID, CreatedAt: now, UpdatedAt: now}
+	ownLogin := models.LoginAuditLog{ID: uuid.Must(uuid.NewV4()), UserId: user.ID, ClientIpAddress: "127.0.0.1", ClientUserAgent: "test", LoginMethod: 2}
+	guestLogin := models.LoginAuditLog{ID: uuid.Must(uuid.NewV4()), UserId: other.ID, SurrogateUserId: &user.ID, UserGuestRelationId: &asGuest.ID, ClientIpAddress: "127.0.0.1", ClientUserAgent: "test", LoginMethod: 3}
+
+	p := test.NewPersister(
+		[]models.User{user, other}, nil, nil,
+		[]models.WebauthnCredential{credential}, nil,
+		[]models.PasswordCredential{password},
+		[]models.AccountAccessGrant{created, claimed},
+		[]models.UserGuestRelation{asGuest, asParent},
+		[]models.LoginAuditLog{ownLogin, guestLogin},
+	)
+	require.NoError(t, p.GetEmailPersister().Create(models.NewEmail(user.ID, "john@work.example.com")))
+	post := models.Post{ID: uuid.Must(uuid.NewV4()), CreatedByUserId: other.ID, CreatedBySurrogateId: user.ID, UpdatedByUserId: other.ID, UpdatedBySurrogateId: user.ID, Data: "as guest", CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, p.GetPostPersister().Create(post))
+	require.NoError(t, p.GetPostHistoryPersister().Create(models.NewPostHistory(post, now)))
+
+	export, err := NewExporter(p).Export(user.ID)
+	require.NoError(t, err)
+	require.NotNil(t, export)
+
+	assert.Equal(t, user.ID, export.User.ID)
+	assert.True(t, export.User.HasPassword)
+	assert.False(t, export.User.HasTotp)
+	if assert.Len(t, export.Emails, 1) {
+		assert.Equal(t, "john@work.example.com", export.Emails[0].Address)
+	}
+	if assert.Len(t, export.WebauthnCredentials, 1) {
+		assert.Equal(t, "credential", export.WebauthnCredentials[0].ID)
+	}
+	if assert.Len(t, export.LoginAuditLogs.AsAccountHolder, 1) {
+		assert.Equal(t, ownLogin.ID, export.LoginAuditLogs.AsAccountHolder[0].ID)
+	}
+	if assert.Len(t, export.LoginAuditLogs.AsGuest, 1) {
+		assert.Equal(t, guestLogin.ID, export.LoginAuditLogs.AsGuest[0].ID)
+	}
+	if assert.Len(t, export.AccessGrants.Created, 1) {
+		assert.Equal(t, created.ID, export.AccessGrants.Created[0].ID)
+	}
+	if assert.Len(t, export.AccessGrants.Claimed, 1) {
+		assert.Equal(t, claimed.ID, export.AccessGrants.Claimed[0].ID)
+	}
+	if assert.Len(t, export.Relations.AsAccountHolder, 1) {
+		assert.Equal(t, asParent.ID, export.Relations.AsAccountHolder[0].ID)
+	}
+	if assert.Len(t, export.Relations.AsGuest, 1) {
+		assert.Equal(t, asGuest.ID, export.Relations.AsGuest[0].ID)
+		assert.False(t, export.Relations.AsGuest[0].IsActive)
+	}
+	if assert.Len(t, export.Posts, 1) {
+		assert.Equal(t, post.ID, export.Posts[0].ID)
+	}
+	if assert.Len(t, export.PostHistories, 1) {
+		assert.Equal(t, post.ID, export.PostHistories[0].PostId)
+		assert.Equal(t, user.ID, export.PostHistories[0].UpdatedBySurrogateId)
+	}
+
+	var buffer bytes.Buffer
+	require.NoError(t, WriteJSON(&buffer, export))
+	assert.NotContains(t, buffer.String(), "secret")
+}
+
+func TestExporter_Export_UnknownUser(t *testing.T) {
+	p := test.NewPersister(nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	export, err := NewExporter(p).Export(uuid.Must(uuid.NewV4()))
+	require.NoError(t, err)
+	assert.Nil(t, export)
+}
+
+func TestWriteZip(t *testing.T) {
+	user := models.NewUser("john.doe@example.com")
+	p := test.NewPersister([]models.User{user}, nil, nil, nil, nil, nil, nil, nil, nil)
+	export, err := NewExporter(p).Export(user.ID)
+	require.NoError(t, err)
+
+	var buffer bytes.Buffer
+	require.NoError(t, WriteZip(&buffer, export))
+
+	archive, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	require.NoError(t, err)
+	require.Len(t, archive.File, 1)
+	assert.Equal(t, ExportFileName, archive.File[0].Name)
+
+	file, err := archive.File[0].Open()
+	require.NoError(t, err)
+	content, err := io.ReadAll(file)
+	require.NoError(t, err)
+
+	var unzipped dto.UserExport
+	require.NoError(t, json.Unmarshal(content, &unzipped))
+	assert.Equal(t, user.ID, unzipped.User.ID)
+}
@@ -0,0 +1,467 @@
+package account
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/crypto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/webhook"
+)
+
+// Importer creates users from the portable format or updates them, if they already exist. Users are matched by ID,
+// if given, and otherwise by email address. Importing the same users again doesn't change anything.
+type Importer struct {
+	persister persistence.Persister
+	dryRun    bool
+	now       func() time.Time
+}
+
+// NewImporter returns an importer. With dryRun the changes are only reported and nothing is stored.
+func NewImporter(persister persistence.Persister, dryRun bool) *Importer {
+	return &Importer{persister: persister, dryRun: dryRun, now: time.Now}
+}
+
+// ImportReport summarizes an import. On a dry run the users which would be created or updated are counted.
+type ImportReport struct {
+	DryRun    bool
+	Created   int
+	Updated   int
+	Unchanged int
+	Failures  []ImportFailure
+}
+
+// ImportFailure is a user which could not be imported. Record is the position of the user in the input, starting at 1.
+type ImportFailure struct {
+	Record int
+	Email  string
+	Err    error
+}
+
+func (r *ImportReport) String() string {
+	var b strings.Builder
+	if r.DryRun {
+		b.WriteString("dry run, nothing was stored\n")
+	}
+	_, _ = fmt.Fprintf(&b, "created: %d, updated: %d, unchanged: %d, failed: %d\n", r.Created, r.Updated, r.Unchanged, len(r.Failures))
+	for _, failure := range r.Failures {
+		_, _ = fmt.Fprintf(&b, "record %d (%s): %v\n", failure.Record, failure.Email, failure.Err)
+	}
+	return b.String()
+}
+
+type importResult int
+
+const (
+	importCreated importResult = iota
+	importUpdated
+	importUnchanged
+)
+
+// Import reads all users from the reader. Invalid users are reported and skipped, an error is only returned when
+// reading fails.
+func (i *Importer) Import(reader PortableReader) (*ImportReport, error) {
+	report := &ImportReport{DryRun: i.dryRun}
+
+	for record := 1; ; record++ {
+		user, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseError *csv.ParseError
+		if errors.Is(err, ErrInvalidRecord) || errors.As(err, &parseError) {
+			report.Failures = append(report.Failures, ImportFailure{Record: record, Err: err})
+			continue
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to read record %d: %w", record, err)
+		}
+
+		result, err := i.importUser(*user)
+		if err != nil {
+			report.Failures = append(report.Failures, ImportFailure{Record: record, Email: user.Email, Err: err})
+			continue
+		}
+
+		switch result {
+		case importCreated:
+			report.Created++
+		case importUpdated:
+			report.Updated++
+		case importUnchanged:
+			report.Unchanged++
+		}
+	}
+
+	return report, nil
+}
+
+func (i *Importer) importUser(user PortableUser) (importResult, error) {
+	user.Email = strings.ToLower(strings.TrimSpace(user.Email))
+	for j := range user.Emails {
+		user.Emails[j].Address = strings.ToLower(strings.TrimSpace(user.Emails[j].Address))
+	}
+
+	credentials, err := i.validate(user)
+	if err != nil {
+		return 0, err
+	}
+
+	var result importResult
+	err = i.persister.Transaction(func(tx *pop.Connection) error {
+		existing, err := i.findExisting(tx, user)
+		if err != nil {
+			return err
+		}
+
+		// a dry run computes the same changes, but doesn't store them
+		now := i.now().UTC()
+		created := existing == nil
+		changed := false
+		if created {
+			existing, err = i.createUser(tx, user, now)
+			if err != nil {
+				return err
+			}
+		} else if existing.Verified != user.Verified {
+			existing.Verified = user.Verified
+			existing.UpdatedAt = now
+			if !i.dryRun {
+				err = i.persister.GetUserPersisterWithConnection(tx).Update(*existing)
+				if err != nil {
+					return err
+				}
+			}
+			changed = true
+		}
+
+		emailsChanged, err := i.importEmails(tx, *existing, user, now)
+		if err != nil {
+			return err
+		}
+		passwordChanged, err := i.importPassword(tx, *existing, user.PasswordHash, now)
+		if err != nil {
+			return err
+		}
+		credentialsChanged, err := i.importCredentials(tx, *existing, credentials)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case created:
+			result = importCreated
+		case changed || emailsChanged || passwordChanged || credentialsChanged:
+			result = importUpdated
+		default:
+			result = importUnchanged
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+// validate checks the user without accessing the database and returns the credentials to store
+func (i *Importer) validate(user PortableUser) ([]models.WebauthnCredential, error) {
+	model := models.User{
+		ID:        uuid.Must(uuid.NewV4()),
+		Email:     user.Email,
+		Verified:  user.Verified,
+		CreatedAt: i.now(),
+		UpdatedAt: i.now(),
+	}
+	vErr, err := model.Validate(nil)
+	if err != nil {
+		return nil, err
+	}
+	if vErr != nil && vErr.HasAny() {
+		return nil, fmt.Errorf("user validation failed: %w", vErr)
+	}
+
+	addresses := map[string]bool{user.Email: true}
+	for _, email := range user.Emails {
+		if addresses[email.Address] {
+			return nil, fmt.Errorf("email %s is given more than once", email.Address)
+		}
+		addresses[email.Address] = true
+		emailModel := models.NewEmail(model.ID, email.Address)
+		vErr, err = emailModel.Validate(nil)
+		if err != nil {
+			return nil, err
+		}
+		if vErr != nil && vErr.HasAny() {
+			return nil, fmt.Errorf("email validation failed: %w", vErr)
+		}
+	}
+
+	if user.PasswordHash != "" && !crypto.IsSupportedHash(user.PasswordHash) {
+		return nil, errors.New("password_hash must be a bcrypt or argon2id hash")
+	}
+
+	var credentials []models.WebauthnCredential
+	ids := map[string]bool{}
+	for _, portable := range user.WebauthnCredentials {
+		credential, err := i.toCredential(portable)
+		if err != nil {
+			return nil, err
+		}
+		if ids[credential.ID] {
+			return nil, fmt.Errorf("webauthn credential %s is given more than once", credential.ID)
+		}
+		ids[credential.ID] = true
+		credentials = append(credentials, *credential)
+	}
+
+	return credentials, nil
+}
+
+func (i *Importer) toCredential(portable PortableWebauthnCredential) (*models.WebauthnCredential, error) {
+	id, err := base64.RawURLEncoding.DecodeString(portable.ID)
+	if err != nil || len(id) == 0 {
+		return nil, errors.New("webauthn credential id must be base64url encoded without padding")
+	}
+	publicKey, err := base64.RawURLEncoding.DecodeString(portable.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("public key of webauthn credential %s must be base64url encoded without padding", portable.ID)
+	}
+	_, err = webauthncose.ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("public key of webauthn credential %s is not a COSE key: %w", portable.ID, err)
+	}
+
+	now := i.now().UTC()
+	credential := models.WebauthnCredential{
+		ID:              portable.ID,
+		PublicKey:       portable.PublicKey,
+		AttestationType: portable.AttestationType,
+		AAGUID:          portable.AAGUID,
+		SignCount:       portable.SignCount,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	for _, name := range portable.Transports {
+		if name == "" {
+			continue
+		}
+		credential.Transports = append(credential.Transports, models.WebauthnCredentialTransport{
+			ID:                   uuid.Must(uuid.NewV4()),
+			Name:                 name,
+			WebauthnCredentialID: portable.ID,
+		})
+	}
+
+	// the user is not known yet, so UserId is only checked when the credential is stored
+	credential.UserId = uuid.Must(uuid.NewV4())
+	vErr, err := credential.Validate(nil)
+	if err != nil {
+		return nil, err
+	}
+	if vErr != nil && vErr.HasAny() {
+		return nil, fmt.Errorf("webauthn credential validation failed: %w", vErr)
+	}
+
+	return &credential, nil
+}
+
+// findExisting returns the user to update or nil if the user has to be created
+func (i *Importer) findExisting(tx *pop.Connection, user PortableUser) (*models.User, error) {
+	userPersister := i.persister.GetUserPersisterWithConnection(tx)
+
+	byEmail, err := userPersister.GetByEmail(user.Email)
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == nil {
+		return byEmail, nil
+	}
+
+	byId, err := userPersister.Get(*user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if byEmail != nil && (byId == nil || byEmail.ID != byId.ID) {
+		return nil, fmt.Errorf("email %s belongs to another user", user.Email)
+	}
+	if byId != nil && byId.Email != user.Email {
+		return nil, fmt.Errorf("email of user %s is %s, it can't be changed by an import", byId.ID, byId.Email)
+	}
+
+	return byId, nil
+}
+
+func (i *Importer) createUser(tx *pop.Connection, user PortableUser, now time.Time) (*models.User, error) {
+	model := models.NewUser(user.Email)
+	if user.ID != nil {
+		model.ID = *user.ID
+	}
+	model.Verified = user.Verified
+	model.CreatedAt = now
+	model.UpdatedAt = now
+	if user.CreatedAt != nil {
+		model.CreatedAt = user.CreatedAt.UTC()
+	}
+	if i.dryRun {
+		return &model, nil
+	}
+
+	err := i.persister.GetUserPersisterWithConnection(tx).Create(model)
+	if err != nil {
+		return nil, err
+	}
+
+	email := models.NewEmail(model.ID, model.Email)
+	email.Primary = true
+	email.Verified = model.Verified
+	err = i.persister.GetEmailPersisterWithConnection(tx).Create(email)
+	if err != nil {
+		return nil, err
+	}
+
+	err = webhook.Enqueue(i.persister.GetWebhookPersisterWithConnection(tx), models.WebhookEventUserCreated, webhook.NewUserData(model))
+	if err != nil {
+		return nil, err
+	}
+
+	return &model, nil
+}
+
+// importEmails adds the additional addresses of the user, addresses which are not in the import are kept
+func (i *Importer) importEmails(tx *pop.Connection, user models.User, portable PortableUser, now time.Time) (bool, error) {
+	emailPersister := i.persister.GetEmailPersisterWithConnection(tx)
+	changed := false
+
+	for _, address := range portable.Emails {
+		email, err := emailPersister.GetByAddress(address.Address)
+		if err != nil {
+			return false, err
+		}
+		if email == nil {
+			existingUser, err := i.persister.GetUserPersisterWithConnection(tx).GetByEmail(address.Address)
+			if err != nil {
+				return false, err
+			}
+			if existingUser != nil {
+				return false, fmt.Errorf("email %s belongs to another user", address.Address)
+			}
+
+			if !i.dryRun {
+				created := models.NewEmail(user.ID, address.Address)
+				created.Verified = address.Verified
+				err = emailPersister.Create(created)
+				if err != nil {
+					return false, err
+				}
+			}
+			changed = true
+			continue
+		}
+		if email.UserId != user.ID {
+			return false, fmt.Errorf("email %s belongs to another user", address.Address)
+		}
+		if email.Verified != address.Verified {
+			email.Verified = address.Verified
+			email.UpdatedAt = now
+			if !i.dryRun {
+				err = emailPersister.Update(*email)
+				if err != nil {
+					return false, err
+				}
+			}
+			changed = true
+		}
+	}
+
+	return changed, nil
+}
+
+// importPassword sets the password hash, an empty hash keeps the current password
+func (i *Importer) importPassword(tx *pop.Connection, user models.User, hash string, now time.Time) (bool, error) {
+	if hash == "" {
+		return false, nil
+	}
+
+	passwordPersister := i.persister.GetPasswordCredentialPersisterWithConnection(tx)
+	password, err := passwordPersister.GetByUserID(user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	if i.dryRun {
+		return password == nil || password.Password != hash, nil
+	}
+
+	if password == nil {
+		err = passwordPersister.Create(models.PasswordCredential{
+			ID:        uuid.Must(uuid.NewV4()),
+			UserId:    user.ID,
+			Password:  hash,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+		return err == nil, err
+	}
+
+	if password.Password == hash {
+		return false, nil
+	}
+	password.Password = hash
+	password.UpdatedAt = now
+	err = passwordPersister.Update(*password)
+	return err == nil, err
+}
+
+// importCredentials adds the webauthn credentials, credentials which are not in the import are kept. The sign count
+// of an existing credential is only raised, so a replayed import can't lower it.
+func (i *Importer) importCredentials(tx *pop.Connection, user models.User, credentials []models.WebauthnCredential) (bool, error) {
+	credentialPersister := i.persister.GetWebauthnCredentialPersisterWithConnection(tx)
+	changed := false
+
+	for _, credential := range credentials {
+		existing, err := credentialPersister.Get(credential.ID)
+		if err != nil {
+			return false, err
+		}
+
+		if existing == nil {
+			if !i.dryRun {
+				credential.UserId = user.ID
+				err = credentialPersister.Create(credential)
+				if err != nil {
+					return false, err
+				}
+			}
+			changed = true
+			continue
+		}
+
+		if existing.UserId != user.ID {
+			return false, fmt.Errorf("webauthn credential %s belongs to another user", credential.ID)
+		}
+		if existing.PublicKey != credential.PublicKey {
+			return false, fmt.Errorf("public key of webauthn credential %s differs from the stored one", credential.ID)
+		}
+		if credential.SignCount > existing.SignCount {
+			existing.SignCount = credential.SignCount
+			existing.UpdatedAt = credential.UpdatedAt
+			if !i.dryRun {
+				err = credentialPersister.Update(*existing)
+				if err != nil {
+					return false, err
+				}
+			}
+			changed = true
+		}
+	}
+
+	return changed, nil
+}
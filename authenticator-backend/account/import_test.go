@@ -0,0 +1,146 @@
+package account
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/crypto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+const testPublicKey = "pQECAyYgASFYIPG9WtGAri-mevonFPH4p-lI3JBS29zjuvKvJmaP4_mRIlggOjHw31sdAGvE35vmRep-aPcbAAlbuc0KHxQ9u6zcHog"
+
+func importUsers(t *testing.T, p persistence.Persister, format string, input string, dryRun bool) *ImportReport {
+	reader, err := NewPortableReader(strings.NewReader(input), format)
+	require.NoError(t, err)
+	report, err := NewImporter(p, dryRun).Import(reader)
+	require.NoError(t, err)
+	return report
+}
+
+func TestImporter_Import(t *testing.T) {
+	hash, err := crypto.NewBcryptHasher(4).Hash("verybadpassword")
+	require.NoError(t, err)
+
+	input := `{"id": "b29a9c2d-1b1a-4b8f-9c3a-0c4d3a1f2e10", "email": "John.Doe@example.com", "verified": true, "password_hash": "` + hash + `", "emails": [{"address": "john@work.example.com", "verified": true}], "webauthn_credentials": [{"id": "AAAA", "public_key": "` + testPublicKey + `", "sign_count": 3, "transports": ["usb"]}]}
+{"email": "jane.doe@example.com"}
+`
+	p := test.NewPersister(nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	report := importUsers(t, p, FormatJSONL, input, false)
+	assert.Equal(t, 2, report.Created)
+	assert.Empty(t, report.Failures)
+
+	user, err := p.GetUserPersister().GetByEmail("john.doe@example.com")
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, "b29a9c2d-1b1a-4b8f-9c3a-0c4d3a1f2e10", user.ID.String())
+	assert.True(t, user.Verified)
+
+	secondary, err := p.GetUserPersister().GetByEmail("john@work.example.com")
+	require.NoError(t, err)
+	if assert.NotNil(t, secondary) {
+		assert.Equal(t, user.ID, secondary.ID)
+	}
+
+	password, err := p.GetPasswordCredentialPersister().GetByUserID(user.ID)
+	require.NoError(t, err)
+	if assert.NotNil(t, password) {
+		assert.Equal(t, hash, password.Password)
+	}
+
+	credential, err := p.GetWebauthnCredentialPersister().Get("AAAA")
+	require.NoError(t, err)
+	if assert.NotNil(t, credential) {
+		assert.Equal(t, user.ID, credential.UserId)
+		assert.Equal(t, 3, credential.SignCount)
+	}
+
+	// the same import again doesn't change anything
+	report = importUsers(t, p, FormatJSONL, input, false)
+	assert.Equal(t, 0, report.Created)
+	assert.Equal(t, 2, report.Unchanged)
+	assert.Empty(t, report.Failures)
+
+	// a changed user is updated
+	report = importUsers(t, p, FormatJSONL, `{"email": "jane.doe@example.com", "verified": true}`, false)
+	assert.Equal(t, 1, report.Updated)
+	jane, err := p.GetUserPersister().GetByEmail("jane.doe@example.com")
+	require.NoError(t, err)
+	assert.True(t, jane.Verified)
+}
+
+func TestImporter_Import_DryRun(t *testing.T) {
+	existing := models.NewUser("jane.doe@example.com")
+	unchanged := models.NewUser("max@example.com")
+	p := test.NewPersister([]models.User{existing, unchanged}, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	input := `{"email": "john.doe@example.com"}
+{"email": "jane.doe@example.com", "verified": true}
+{"email": "not an email"}
+{"email": "max@example.com"}
+`
+	report := importUsers(t, p, FormatJSONL, input, true)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Updated)
+	assert.Equal(t, 1, report.Unchanged)
+	if assert.Len(t, report.Failures, 1) {
+		assert.Equal(t, 3, report.Failures[0].Record)
+	}
+
+	user, err := p.GetUserPersister().GetByEmail("john.doe@example.com")
+	require.NoError(t, err)
+	assert.Nil(t, user)
+	jane, err := p.GetUserPersister().GetByEmail("jane.doe@example.com")
+	require.NoError(t, err)
+	assert.False(t, jane.Verified)
+}
+
+func TestImporter_Import_Failures(t *testing.T) {
+	existing := models.NewUser("jane.doe@example.com")
+	p := test.NewPersister([]models.User{existing}, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "malformed json", input: `{"email": `},
+		{name: "invalid email", input: `{"email": "john"}`},
+		{name: "plaintext password", input: `{"email": "john.doe@example.com", "password_hash": "verybadpassword"}`},
+		{name: "invalid public key", input: `{"email": "john.doe@example.com", "webauthn_credentials": [{"id": "AAAA", "public_key": "AAAA"}]}`},
+		{name: "email of another user", input: `{"id": "b29a9c2d-1b1a-4b8f-9c3a-0c4d3a1f2e10", "email": "jane.doe@example.com"}`},
+		{name: "duplicate email", input: `{"email": "john.doe@example.com", "emails": [{"address": "John.Doe@example.com"}]}`},
+	}
+
+	for _, currentTest := range tests {
+		t.Run(currentTest.name, func(t *testing.T) {
+			report := importUsers(t, p, FormatJSONL, currentTest.input, false)
+			assert.Len(t, report.Failures, 1)
+			assert.Equal(t, 0, report.Created+report.Updated+report.Unchanged)
+		})
+	}
+}
+
+func TestImporter_Import_CSV(t *testing.T) {
+	input := `email,verified,webauthn_credentials
+john.doe@example.com,true,"[{""id"": ""AAAA"", ""public_key"": ""` + testPublicKey + `""}]"
+jane.doe@example.com,maybe,
+`
+	p := test.NewPersister(nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	report := importUsers(t, p, FormatCSV, input, false)
+	assert.Equal(t, 1, report.Created)
+	if assert.Len(t, report.Failures, 1) {
+		assert.Equal(t, 2, report.Failures[0].Record)
+		assert.ErrorIs(t, report.Failures[0].Err, ErrInvalidRecord)
+	}
+
+	credential, err := p.GetWebauthnCredentialPersister().Get("AAAA")
+	require.NoError(t, err)
+	assert.NotNil(t, credential)
+}
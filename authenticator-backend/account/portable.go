@@ -0,0 +1,256 @@
+package account
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+const (
+	// FormatJSONL is one JSON encoded PortableUser per line
+	FormatJSONL = "jsonl"
+	// FormatCSV is one PortableUser per row, the lists are JSON encoded
+	FormatCSV = "csv"
+)
+
+// PortableUser is the format users are imported and exported in
+type PortableUser struct {
+	// ID is optional on import, a new ID is generated if it is missing
+	ID       *uuid.UUID `json:"id,omitempty"`
+	Email    string     `json:"email"`
+	Verified bool       `json:"verified"`
+	// Emails are the addresses of the user in addition to Email
+	Emails []PortableEmail `json:"emails,omitempty"`
+	// PasswordHash is a bcrypt or argon2id hash in the PHC string format
+	PasswordHash        string                       `json:"password_hash,omitempty"`
+	WebauthnCredentials []PortableWebauthnCredential `json:"webauthn_credentials,omitempty"`
+	CreatedAt           *time.Time                   `json:"created_at,omitempty"`
+}
+
+type PortableEmail struct {
+	Address  string `json:"address"`
+	Verified bool   `json:"verified"`
+}
+
+// PortableWebauthnCredential is a passkey, the ID and the COSE encoded public key are base64url encoded without padding
+type PortableWebauthnCredential struct {
+	ID              string    `json:"id"`
+	PublicKey       string    `json:"public_key"`
+	AttestationType string    `json:"attestation_type,omitempty"`
+	AAGUID          uuid.UUID `json:"aaguid"`
+	SignCount       int       `json:"sign_count"`
+	Transports      []string  `json:"transports,omitempty"`
+}
+
+var csvHeader = []string{"id", "email", "verified", "emails", "password_hash", "webauthn_credentials", "created_at"}
+
+// ErrInvalidRecord is returned by a PortableReader for a record which can't be decoded. The following records can
+// still be read.
+var ErrInvalidRecord = errors.New("invalid record")
+
+// PortableReader reads users one by one, it returns io.EOF when all users were read
+type PortableReader interface {
+	Read() (*PortableUser, error)
+}
+
+// PortableWriter writes users one by one, Flush must be called after the last user
+type PortableWriter interface {
+	Write(user PortableUser) error
+	Flush() error
+}
+
+// NewPortableReader returns a reader for the given format
+func NewPortableReader(r io.Reader, format string) (PortableReader, error) {
+	switch format {
+	case FormatJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		return &jsonlReader{scanner: scanner}, nil
+	case FormatCSV:
+		return &csvReader{reader: csv.NewReader(r)}, nil
+	}
+	return nil, fmt.Errorf("format must be one of %s, %s", FormatJSONL, FormatCSV)
+}
+
+// NewPortableWriter returns a writer for the given format
+func NewPortableWriter(w io.Writer, format string) (PortableWriter, error) {
+	switch format {
+	case FormatJSONL:
+		buffered := bufio.NewWriter(w)
+		return &jsonlWriter{writer: buffered, encoder: json.NewEncoder(buffered)}, nil
+	case FormatCSV:
+		return &csvWriter{writer: csv.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("format must be one of %s, %s", FormatJSONL, FormatCSV)
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+}
+
+func (r *jsonlReader) Read() (*PortableUser, error) {
+	for r.scanner.Scan() {
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" {
+			continue
+		}
+		var user PortableUser
+		err := json.Unmarshal([]byte(line), &user)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to decode user: %v", ErrInvalidRecord, err)
+		}
+		return &user, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+type jsonlWriter struct {
+	writer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (w *jsonlWriter) Write(user PortableUser) error {
+	return w.encoder.Encode(user)
+}
+
+func (w *jsonlWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+type csvReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func (r *csvReader) Read() (*PortableUser, error) {
+	if r.columns == nil {
+		header, err := r.reader.Read()
+		if err == io.EOF {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv header: %v", err)
+		}
+		r.columns = make(map[string]int)
+		for i, name := range header {
+			r.columns[strings.TrimSpace(name)] = i
+		}
+		if _, ok := r.columns["email"]; !ok {
+			return nil, errors.New("csv header must contain the column email")
+		}
+		// every row must have as many fields as the header
+		r.reader.FieldsPerRecord = len(header)
+	}
+
+	record, err := r.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	field := func(name string) string {
+		if i, ok := r.columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	user := PortableUser{
+		Email:        field("email"),
+		PasswordHash: field("password_hash"),
+	}
+	if value := field("id"); value != "" {
+		id, err := uuid.FromString(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to parse id: %v", ErrInvalidRecord, err)
+		}
+		user.ID = &id
+	}
+	if value := field("verified"); value != "" {
+		user.Verified, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to parse verified: %v", ErrInvalidRecord, err)
+		}
+	}
+	if value := field("emails"); value != "" {
+		err = json.Unmarshal([]byte(value), &user.Emails)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to decode emails: %v", ErrInvalidRecord, err)
+		}
+	}
+	if value := field("webauthn_credentials"); value != "" {
+		err = json.Unmarshal([]byte(value), &user.WebauthnCredentials)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to decode webauthn_credentials: %v", ErrInvalidRecord, err)
+		}
+	}
+	if value := field("created_at"); value != "" {
+		createdAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to parse created_at: %v", ErrInvalidRecord, err)
+		}
+		user.CreatedAt = &createdAt
+	}
+
+	return &user, nil
+}
+
+type csvWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvWriter) Write(user PortableUser) error {
+	if !w.headerWritten {
+		err := w.writer.Write(csvHeader)
+		if err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+
+	var id, createdAt, emails, credentials string
+	if user.ID != nil {
+		id = user.ID.String()
+	}
+	if user.CreatedAt != nil {
+		createdAt = user.CreatedAt.UTC().Format(time.RFC3339)
+	}
+	if len(user.Emails) > 0 {
+		encoded, err := json.Marshal(user.Emails)
+		if err != nil {
+			return err
+		}
+		emails = string(encoded)
+	}
+	if len(user.WebauthnCredentials) > 0 {
+		encoded, err := json.Marshal(user.WebauthnCredentials)
+		if err != nil {
+			return err
+		}
+		credentials = string(encoded)
+	}
+
+	return w.writer.Write([]string{id, user.Email, strconv.FormatBool(user.Verified), emails, user.PasswordHash, credentials, createdAt})
+}
+
+func (w *csvWriter) Flush() error {
+	if !w.headerWritten {
+		err := w.writer.Write(csvHeader)
+		if err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
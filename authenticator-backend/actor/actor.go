@@ -0,0 +1,102 @@
+package actor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gofrs/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	hankoJwt "github.com/teamhanko/hanko/backend/crypto/jwt"
+)
+
+// Actor is who performs a request. The principal is the account the session belongs to, the surrogate is the user who
+// is actually signed in. They differ in the sessions of guests, which also carry the guest relation and its scopes.
+type Actor struct {
+	PrincipalId uuid.UUID
+	SurrogateId uuid.UUID
+	RelationId  *uuid.UUID
+	Scopes      []string
+}
+
+// FromToken returns the actor of the session
+func FromToken(token jwt.Token) (Actor, error) {
+	principalId, err := uuid.FromString(token.Subject())
+	if err != nil {
+		return Actor{}, fmt.Errorf("failed to parse subject as uuid: %w", err)
+	}
+
+	surrogate, err := hankoJwt.GetSurrogateKeyFromToken(token)
+	if err != nil {
+		return Actor{}, err
+	}
+	surrogateId, err := uuid.FromString(surrogate)
+	if err != nil {
+		return Actor{}, fmt.Errorf("failed to parse surrogate as uuid: %w", err)
+	}
+
+	a := Actor{PrincipalId: principalId, SurrogateId: surrogateId, Scopes: []string{}}
+	if principalId == surrogateId {
+		return a, nil
+	}
+
+	grant, err := hankoJwt.GetGrantKeyFromToken(token)
+	if err != nil {
+		return Actor{}, err
+	}
+	relationId, err := uuid.FromString(grant)
+	if err != nil {
+		return Actor{}, fmt.Errorf("failed to parse grant as uuid: %w", err)
+	}
+	a.RelationId = &relationId
+	a.Scopes = hankoJwt.GetScopesFromToken(token)
+
+	return a, nil
+}
+
+// IsGuest returns whether a guest acts on behalf of the account holder
+func (a Actor) IsGuest() bool {
+	return a.RelationId != nil
+}
+
+// HasScope returns whether the actor may act in the scope. The account holders have all scopes on their account.
+func (a Actor) HasScope(scope string) bool {
+	if !a.IsGuest() {
+		return true
+	}
+	for _, s := range a.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Attributable is implemented by the records which keep who created and who last changed them
+type Attributable interface {
+	SetCreatedBy(userId uuid.UUID, surrogateId uuid.UUID)
+	SetUpdatedBy(userId uuid.UUID, surrogateId uuid.UUID)
+}
+
+// AttributeCreation records the actor as the creator of a new record
+func (a Actor) AttributeCreation(record Attributable) {
+	record.SetCreatedBy(a.PrincipalId, a.SurrogateId)
+	record.SetUpdatedBy(a.PrincipalId, a.SurrogateId)
+}
+
+// AttributeUpdate records the actor as the last one who changed the record
+func (a Actor) AttributeUpdate(record Attributable) {
+	record.SetUpdatedBy(a.PrincipalId, a.SurrogateId)
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of the context carrying the actor
+func NewContext(ctx context.Context, a Actor) context.Context {
+	return context.WithValue(ctx, contextKey{}, a)
+}
+
+// FromContext returns the actor the context carries, if any
+func FromContext(ctx context.Context) (Actor, bool) {
+	a, ok := ctx.Value(contextKey{}).(Actor)
+	return a, ok
+}
@@ -0,0 +1,90 @@
+package actor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	hankoJwt "github.com/teamhanko/hanko/backend/crypto/jwt"
+)
+
+var (
+	holderId   = uuid.FromStringOrNil("b5dd5267-b462-48be-b70d-bcd6f1bbe7a5")
+	guestId    = uuid.FromStringOrNil("6e1c4c5b-0d7c-4e58-9c43-9f5b0b1d2e3f")
+	relationId = uuid.FromStringOrNil("0f6d6a4e-7c38-4f6e-9a55-3b1c1a0d2f11")
+)
+
+type record struct {
+	createdBy, createdBySurrogate, updatedBy, updatedBySurrogate uuid.UUID
+}
+
+func (r *record) SetCreatedBy(userId uuid.UUID, surrogateId uuid.UUID) {
+	r.createdBy, r.createdBySurrogate = userId, surrogateId
+}
+
+func (r *record) SetUpdatedBy(userId uuid.UUID, surrogateId uuid.UUID) {
+	r.updatedBy, r.updatedBySurrogate = userId, surrogateId
+}
+
+func newToken(t *testing.T, subject uuid.UUID, surrogate uuid.UUID, claims map[string]string) jwt.Token {
+	token := jwt.New()
+	require.NoError(t, token.Set(jwt.SubjectKey, subject.String()))
+	require.NoError(t, token.Set(hankoJwt.SurrogateKey, surrogate.String()))
+	for key, value := range claims {
+		require.NoError(t, token.Set(key, value))
+	}
+	return token
+}
+
+func TestFromToken(t *testing.T) {
+	holder, err := FromToken(newToken(t, holderId, holderId, nil))
+	require.NoError(t, err)
+	assert.Equal(t, holderId, holder.PrincipalId)
+	assert.Equal(t, holderId, holder.SurrogateId)
+	assert.False(t, holder.IsGuest())
+	assert.True(t, holder.HasScope("posts:write"))
+
+	guest, err := FromToken(newToken(t, holderId, guestId, map[string]string{hankoJwt.GrantKey: relationId.String(), hankoJwt.ScopeKey: "posts:read"}))
+	require.NoError(t, err)
+	assert.Equal(t, holderId, guest.PrincipalId)
+	assert.Equal(t, guestId, guest.SurrogateId)
+	assert.True(t, guest.IsGuest())
+	assert.Equal(t, relationId, *guest.RelationId)
+	assert.True(t, guest.HasScope("posts:read"))
+	assert.False(t, guest.HasScope("posts:write"))
+}
+
+func TestFromToken_Errors(t *testing.T) {
+	_, err := FromToken(newToken(t, holderId, guestId, nil))
+	assert.Error(t, err, "the session of a guest must carry the relation")
+
+	token := jwt.New()
+	require.NoError(t, token.Set(jwt.SubjectKey, holderId.String()))
+	_, err = FromToken(token)
+	assert.Error(t, err)
+}
+
+func TestActor_Attribute(t *testing.T) {
+	guest := Actor{PrincipalId: holderId, SurrogateId: guestId, RelationId: &relationId}
+	r := &record{}
+
+	guest.AttributeCreation(r)
+	assert.Equal(t, record{createdBy: holderId, createdBySurrogate: guestId, updatedBy: holderId, updatedBySurrogate: guestId}, *r)
+
+	holder := Actor{PrincipalId: holderId, SurrogateId: holderId}
+	holder.AttributeUpdate(r)
+	assert.Equal(t, record{createdBy: holderId, createdBySurrogate: guestId, updatedBy: holderId, updatedBySurrogate: holderId}, *r)
+}
+
+func TestContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	a := Actor{PrincipalId: holderId, SurrogateId: holderId}
+	fromContext, ok := FromContext(NewContext(context.Background(), a))
+	assert.True(t, ok)
+	assert.Equal(t, a, fromContext)
+}
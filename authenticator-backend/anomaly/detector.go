@@ -0,0 +1,268 @@
+package anomaly
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/teamhanko/hanko/backend/config"
+	hankoJwk "github.com/teamhanko/hanko/backend/crypto/jwk"
+	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/mail"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"gopkg.in/gomail.v2"
+)
+
+// PurposeRevoke is the purpose claim of the tokens in the links to revoke the sessions or the guest relation of an
+// unusual login. The ID of the token is the ID of the anomaly.
+const PurposeRevoke = "login_anomaly_revoke"
+
+// batchSize is the maximum number of logins compared and notifications sent per run
+const batchSize = 100
+
+// Detector compares the logins of the login audit log chain with the login history and notifies the account holders
+// about unusual logins. The logins are compared after they are stored, in the order of the chain, so that logins of
+// all methods are covered. The cursor in the chain is locked while logins are compared and the notifications are
+// claimed before they are sent, so several instances can run a detector at the same time. A notification which
+// can't be sent is not retried.
+type Detector struct {
+	persister    persistence.Persister
+	rules        *Rules
+	history      int
+	mailer       mail.Mailer
+	renderer     *mail.Renderer
+	jwtGenerator jwt2.Generator
+	cfg          *config.Config
+	now          func() time.Time
+}
+
+func NewDetector(cfg *config.Config, persister persistence.Persister, jwkManager hankoJwk.Manager, mailer mail.Mailer) (*Detector, error) {
+	var geoIP *GeoIP
+	if len(cfg.LoginAnomalies.GeoIPDatabases) > 0 {
+		var err error
+		geoIP, err = LoadGeoIP(cfg.LoginAnomalies.GeoIPDatabases...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	renderer, err := mail.NewRenderer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new renderer: %w", err)
+	}
+
+	signatureKey, err := jwkManager.GetSigningKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signing key: %w", err)
+	}
+	verificationKeys, err := jwkManager.GetPublicKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get verification keys: %w", err)
+	}
+	generator, err := jwt2.NewGenerator(signatureKey, verificationKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jwt generator: %w", err)
+	}
+
+	return &Detector{
+		persister:    persister,
+		rules:        NewRules(cfg.LoginAnomalies, geoIP),
+		history:      cfg.LoginAnomalies.History,
+		mailer:       mailer,
+		renderer:     renderer,
+		jwtGenerator: generator,
+		cfg:          cfg,
+		now:          time.Now,
+	}, nil
+}
+
+// Scan compares the logins after the cursor with their history, stores the anomalies and returns how many logins
+// were compared
+func (d *Detector) Scan() (int, error) {
+	scanned := 0
+	err := d.persister.Transaction(func(tx *pop.Connection) error {
+		anomalyPersister := d.persister.GetLoginAnomalyPersisterWithConnection(tx)
+		cursor, err := anomalyPersister.LockCursor()
+		if err != nil {
+			return err
+		}
+		if cursor == nil {
+			return fmt.Errorf("login anomaly cursor not found")
+		}
+
+		auditPersister := d.persister.GetLoginAuditLogPersisterWithConnection(tx)
+		logs, err := auditPersister.ListChained(cursor.Sequence, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+
+		now := d.now().UTC()
+		for _, login := range logs {
+			cursor.Sequence = login.Sequence
+			// the logs of deleted users are pseudonymised, there is no one to notify
+			if login.IsPseudonymised() {
+				continue
+			}
+
+			history, err := auditPersister.ListHistory(login.UserId, login.SurrogateUserId, login.Sequence, d.history)
+			if err != nil {
+				return err
+			}
+			reasons := d.rules.Detect(login, history)
+			if len(reasons) == 0 {
+				continue
+			}
+
+			err = anomalyPersister.Create(models.NewLoginAnomaly(login, reasons, now))
+			if err != nil {
+				return err
+			}
+		}
+
+		cursor.UpdatedAt = now
+		scanned = len(logs)
+		return anomalyPersister.UpdateCursor(*cursor)
+	})
+
+	return scanned, err
+}
+
+// Notify sends the notifications of the anomalies to the account holders and returns how many were sent
+func (d *Detector) Notify() (int, error) {
+	anomalyPersister := d.persister.GetLoginAnomalyPersister()
+	anomalies, err := anomalyPersister.ListUnnotified(batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, anomaly := range anomalies {
+		claimed, err := anomalyPersister.ClaimNotification(anomaly.ID, d.now().UTC())
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+
+		err = d.notify(anomaly)
+		if err != nil {
+			log.Printf("failed to notify about login anomaly %s: %v", anomaly.ID, err)
+			continue
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+func (d *Detector) notify(anomaly models.LoginAnomaly) error {
+	userPersister := d.persister.GetUserPersister()
+	user, err := userPersister.Get(anomaly.UserId)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil
+	}
+
+	guestEmail := ""
+	if anomaly.SurrogateUserId != nil {
+		guest, err := userPersister.Get(*anomaly.SurrogateUserId)
+		if err != nil {
+			return fmt.Errorf("failed to get guest user: %w", err)
+		}
+		if guest == nil {
+			return nil
+		}
+		guestEmail = guest.Email
+	}
+
+	token, err := d.generateToken(anomaly)
+	if err != nil {
+		return err
+	}
+	link, err := url.Parse(d.cfg.LoginAnomalies.RevokeUrl)
+	if err != nil {
+		return fmt.Errorf("failed to parse revoke url: %w", err)
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	// there is no request, the notification is sent in the default language
+	lang := ""
+	reasons := []string{}
+	for _, reason := range anomaly.ReasonList() {
+		reasons = append(reasons, d.renderer.Translate(lang, "login_anomaly_reason_"+reason, nil))
+	}
+	data := map[string]interface{}{
+		"ServiceName": d.cfg.Service.Name,
+		"GuestEmail":  guestEmail,
+		"Time":        anomaly.LoginAt.UTC().Format(time.RFC1123),
+		"IpAddress":   anomaly.ClientIpAddress,
+		"UserAgent":   anomaly.ClientUserAgent,
+		"Reasons":     reasons,
+		"Link":        link.String(),
+		"TTL":         fmt.Sprintf("%.0f", (time.Duration(d.cfg.LoginAnomalies.RevokeTTL) * time.Second).Hours()),
+	}
+
+	str, err := d.renderer.Render("loginAnomalyTextMail", lang, data)
+	if err != nil {
+		return fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	message := gomail.NewMessage()
+	message.SetAddressHeader("To", user.Email, "")
+	message.SetAddressHeader("From", d.cfg.Passcode.Email.FromAddress, d.cfg.Passcode.Email.FromName)
+	message.SetHeader("Subject", d.renderer.Translate(lang, "email_subject_login_anomaly", data))
+	message.SetBody("text/plain", str)
+
+	err = d.mailer.Send(message)
+	if err != nil {
+		return fmt.Errorf("failed to send login anomaly email: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Detector) generateToken(anomaly models.LoginAnomaly) (string, error) {
+	issuedAt := d.now().UTC()
+	token := jwt.New()
+	_ = token.Set(jwt.SubjectKey, anomaly.UserId.String())
+	_ = token.Set(jwt.JwtIDKey, anomaly.ID.String())
+	_ = token.Set(jwt.IssuedAtKey, issuedAt)
+	_ = token.Set(jwt.ExpirationKey, issuedAt.Add(time.Duration(d.cfg.LoginAnomalies.RevokeTTL)*time.Second))
+	_ = token.Set(jwt2.PurposeKey, PurposeRevoke)
+
+	signed, err := d.jwtGenerator.Sign(token)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign login anomaly token: %w", err)
+	}
+
+	return string(signed), nil
+}
+
+// Run compares the new logins and sends the notifications in the given interval
+func (d *Detector) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		_, err := d.Scan()
+		if err != nil {
+			log.Printf("failed to scan logins for anomalies: %v", err)
+		}
+		_, err = d.Notify()
+		if err != nil {
+			log.Printf("failed to notify about login anomalies: %v", err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package anomaly
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/crypto/jwk"
+	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+	"gopkg.in/gomail.v2"
+)
+
+var (
+	holderId = uuid.FromStringOrNil("b5dd5267-b462-48be-b70d-bcd6f1bbe7a5")
+	guestId  = uuid.FromStringOrNil("6e1c4c5b-0d7c-4e58-9c43-9f5b0b1d2e3f")
+)
+
+type recordingMailer struct {
+	messages []*gomail.Message
+}
+
+func (m *recordingMailer) Send(message *gomail.Message) error {
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+func newDetector(t *testing.T) (*Detector, persistence.Persister, *recordingMailer) {
+	now := time.Now().UTC()
+	users := []models.User{
+		{ID: holderId, Email: "holder@example.com", IsActive: true, CreatedAt: now, UpdatedAt: now},
+		{ID: guestId, Email: "guest@example.com", IsActive: true, CreatedAt: now, UpdatedAt: now},
+	}
+	persister := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	cfg := config.DefaultConfig()
+	cfg.Service.Name = "Test Service"
+	cfg.Secrets.Keys = []string{"needsToBeAtLeast16"}
+	cfg.LoginAnomalies = testConfig
+	jwkManager, err := jwk.NewDefaultManager(cfg.Secrets.Keys, persister.GetJwkPersister())
+	require.NoError(t, err)
+
+	mailer := &recordingMailer{}
+	detector, err := NewDetector(cfg, persister, jwkManager, mailer)
+	require.NoError(t, err)
+	return detector, persister, mailer
+}
+
+func createLog(t *testing.T, persister persistence.Persister, log models.LoginAuditLog) {
+	log.LoginMethod = 1
+	require.NoError(t, persister.GetLoginAuditLogPersister().Create(log))
+}
+
+func TestDetector_Scan(t *testing.T) {
+	detector, persister, _ := newDetector(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	relationId := uuid.Must(uuid.NewV4())
+
+	createLog(t, persister, models.LoginAuditLog{UserId: holderId, ClientIpAddress: "192.0.2.10:4711", ClientUserAgent: firefox, CreatedAt: now.Add(-time.Hour)})
+	createLog(t, persister, models.LoginAuditLog{UserId: holderId, ClientIpAddress: "192.0.2.11:4711", ClientUserAgent: firefox, CreatedAt: now.Add(-time.Minute)})
+	// the first login of the guest is compared with the logins of the guest only
+	createLog(t, persister, models.LoginAuditLog{UserId: holderId, SurrogateUserId: &guestId, UserGuestRelationId: &relationId, ClientIpAddress: "198.51.100.1:4711", ClientUserAgent: safari, CreatedAt: now})
+	createLog(t, persister, models.LoginAuditLog{UserId: holderId, ClientIpAddress: "198.51.100.1:4711", ClientUserAgent: safari, CreatedAt: now})
+
+	scanned, err := detector.Scan()
+	require.NoError(t, err)
+	assert.Equal(t, 4, scanned)
+
+	anomalyPersister := persister.GetLoginAnomalyPersister()
+	anomalies, err := anomalyPersister.ListUnnotified(10)
+	require.NoError(t, err)
+	if assert.Len(t, anomalies, 1) {
+		assert.Equal(t, holderId, anomalies[0].UserId)
+		assert.Nil(t, anomalies[0].SurrogateUserId)
+		assert.Equal(t, []string{models.LoginAnomalyNewDevice, models.LoginAnomalyNewIpRange}, anomalies[0].ReasonList())
+		assert.Equal(t, "198.51.100.1:4711", anomalies[0].ClientIpAddress)
+	}
+
+	cursor, err := anomalyPersister.LockCursor()
+	require.NoError(t, err)
+	assert.Equal(t, 4, cursor.Sequence)
+
+	scanned, err = detector.Scan()
+	require.NoError(t, err)
+	assert.Equal(t, 0, scanned)
+}
+
+func TestDetector_Notify(t *testing.T) {
+	detector, persister, mailer := newDetector(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	relationId := uuid.Must(uuid.NewV4())
+
+	holderLogin := models.NewLoginAnomaly(models.LoginAuditLog{ID: uuid.Must(uuid.NewV4()), UserId: holderId, ClientIpAddress: "198.51.100.1:4711", ClientUserAgent: safari, CreatedAt: now}, []string{models.LoginAnomalyNewDevice}, now)
+	guestLogin := models.NewLoginAnomaly(models.LoginAuditLog{ID: uuid.Must(uuid.NewV4()), UserId: holderId, SurrogateUserId: &guestId, UserGuestRelationId: &relationId, ClientIpAddress: "198.51.100.1:4711", ClientUserAgent: safari, CreatedAt: now}, []string{models.LoginAnomalyUnusualHour}, now)
+	anomalyPersister := persister.GetLoginAnomalyPersister()
+	require.NoError(t, anomalyPersister.Create(holderLogin))
+	require.NoError(t, anomalyPersister.Create(guestLogin))
+
+	sent, err := detector.Notify()
+	require.NoError(t, err)
+	assert.Equal(t, 2, sent)
+
+	require.Len(t, mailer.messages, 2)
+	for _, message := range mailer.messages {
+		assert.Equal(t, []string{"holder@example.com"}, message.GetHeader("To"))
+		assert.Equal(t, []string{"New sign-in to your Test Service account"}, message.GetHeader("Subject"))
+	}
+
+	body := &strings.Builder{}
+	_, err = mailer.messages[0].WriteTo(body)
+	require.NoError(t, err)
+	assert.Contains(t, body.String(), "The sign-in was from a new device or browser.")
+	assert.Contains(t, body.String(), "https://example.com/login/revoke?token=3D")
+
+	body.Reset()
+	_, err = mailer.messages[1].WriteTo(body)
+	require.NoError(t, err)
+	assert.Contains(t, body.String(), "guest@example.com signed in to your Test Service account as your guest")
+
+	sent, err = detector.Notify()
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+	assert.Len(t, mailer.messages, 2)
+}
+
+func TestDetector_Token(t *testing.T) {
+	detector, _, _ := newDetector(t)
+	now := time.Now().UTC()
+	loginAnomaly := models.NewLoginAnomaly(models.LoginAuditLog{ID: uuid.Must(uuid.NewV4()), UserId: holderId, CreatedAt: now}, []string{models.LoginAnomalyNewDevice}, now)
+
+	signed, err := detector.generateToken(loginAnomaly)
+	require.NoError(t, err)
+
+	token, err := detector.jwtGenerator.Verify([]byte(signed))
+	require.NoError(t, err)
+	assert.Equal(t, PurposeRevoke, jwt2.GetPurposeFromToken(token))
+	assert.Equal(t, loginAnomaly.ID.String(), token.JwtID())
+	assert.Equal(t, holderId.String(), token.Subject())
+}
@@ -0,0 +1,138 @@
+package anomaly
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// earthRadius in km
+const earthRadius = 6371.0
+
+// Location is the approximate position of an IP address
+type Location struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Distance returns the great-circle distance to the other location in km
+func (l Location) Distance(other Location) float64 {
+	lat1 := l.Latitude * math.Pi / 180
+	lat2 := other.Latitude * math.Pi / 180
+	deltaLat := lat2 - lat1
+	deltaLon := (other.Longitude - l.Longitude) * math.Pi / 180
+
+	a := math.Sin(deltaLat/2)*math.Sin(deltaLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(deltaLon/2)*math.Sin(deltaLon/2)
+	return 2 * earthRadius * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+type geoIPBlock struct {
+	first    net.IP
+	last     net.IP
+	location Location
+}
+
+// GeoIP maps IP addresses to locations. The blocks are sorted by their first address and must not overlap.
+type GeoIP struct {
+	blocks []geoIPBlock
+}
+
+// LoadGeoIP reads the CSV files with the columns "network", "latitude" and "longitude", further columns are ignored.
+// Networks without a location are skipped.
+func LoadGeoIP(paths ...string) (*GeoIP, error) {
+	geoIP := &GeoIP{}
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open geoip database: %w", err)
+		}
+		err = geoIP.read(file)
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read geoip database %s: %w", path, err)
+		}
+	}
+
+	sort.Slice(geoIP.blocks, func(i, j int) bool {
+		return bytes.Compare(geoIP.blocks[i].first, geoIP.blocks[j].first) < 0
+	})
+	return geoIP, nil
+}
+
+func (g *GeoIP) read(reader io.Reader) error {
+	r := csv.NewReader(reader)
+	r.ReuseRecord = true
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+
+	columns := map[string]int{"network": -1, "latitude": -1, "longitude": -1}
+	for i, name := range header {
+		if _, ok := columns[name]; ok {
+			columns[name] = i
+		}
+	}
+	for name, index := range columns {
+		if index < 0 {
+			return fmt.Errorf("missing column %s", name)
+		}
+	}
+
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		latitude, latErr := strconv.ParseFloat(record[columns["latitude"]], 64)
+		longitude, lonErr := strconv.ParseFloat(record[columns["longitude"]], 64)
+		if latErr != nil || lonErr != nil {
+			continue
+		}
+		_, network, err := net.ParseCIDR(record[columns["network"]])
+		if err != nil {
+			return fmt.Errorf("invalid network %s: %w", record[columns["network"]], err)
+		}
+
+		first := network.IP.To16()
+		last := make(net.IP, len(first))
+		mask := network.Mask
+		if len(mask) == net.IPv4len {
+			// the mask of an IPv4 network covers the last 4 bytes of the 16 byte representation
+			ones, _ := mask.Size()
+			mask = net.CIDRMask(96+ones, 8*net.IPv6len)
+		}
+		for i := range first {
+			last[i] = first[i] | ^mask[i]
+		}
+		g.blocks = append(g.blocks, geoIPBlock{first: first, last: last, location: Location{Latitude: latitude, Longitude: longitude}})
+	}
+}
+
+// Lookup returns the location of the address, or nil if the address is not in any of the networks
+func (g *GeoIP) Lookup(ip net.IP) *Location {
+	ip = ip.To16()
+	if ip == nil {
+		return nil
+	}
+	// the last block starting at or before the address
+	i := sort.Search(len(g.blocks), func(i int) bool {
+		return bytes.Compare(g.blocks[i].first, ip) > 0
+	}) - 1
+	if i < 0 || bytes.Compare(ip, g.blocks[i].last) > 0 {
+		return nil
+	}
+	location := g.blocks[i].location
+	return &location
+}
@@ -0,0 +1,159 @@
+package anomaly
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+// travelTolerance in km covers the inaccuracy of the GeoIP locations, shorter distances are never impossible travel
+const travelTolerance = 100.0
+
+// versionPattern matches the version numbers in user agents
+var versionPattern = regexp.MustCompile(`[0-9]+([._][0-9]+)*`)
+
+// Fingerprint returns the fingerprint of the device with the user agent. Version numbers are ignored, so that an
+// updated browser is still the same device.
+func Fingerprint(userAgent string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(versionPattern.ReplaceAllString(userAgent, ""))), " ")
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseIP returns the address of the client address stored in the login audit logs, which may include the port
+func ParseIP(address string) net.IP {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	return net.ParseIP(address)
+}
+
+// Rules decide whether a login is unusual compared to the history of previous logins
+type Rules struct {
+	ipv4Mask             net.IPMask
+	ipv6Mask             net.IPMask
+	geoIP                *GeoIP
+	maxTravelSpeed       float64
+	unusualHourMinLogins int
+	unusualHourTolerance int
+}
+
+// NewRules creates the rules, impossible travel is only detected with a GeoIP database
+func NewRules(cfg config.LoginAnomalies, geoIP *GeoIP) *Rules {
+	return &Rules{
+		ipv4Mask:             net.CIDRMask(cfg.IPv4PrefixLength, 8*net.IPv4len),
+		ipv6Mask:             net.CIDRMask(cfg.IPv6PrefixLength, 8*net.IPv6len),
+		geoIP:                geoIP,
+		maxTravelSpeed:       float64(cfg.MaxTravelSpeed),
+		unusualHourMinLogins: cfg.UnusualHourMinLogins,
+		unusualHourTolerance: cfg.UnusualHourTolerance,
+	}
+}
+
+// Detect returns the reasons why the login is unusual. The history is newest first. The first login of a user or
+// guest is never unusual, there is nothing to compare it with.
+func (r *Rules) Detect(log models.LoginAuditLog, history []models.LoginAuditLog) []string {
+	reasons := []string{}
+	if len(history) == 0 {
+		return reasons
+	}
+
+	if r.isNewDevice(log, history) {
+		reasons = append(reasons, models.LoginAnomalyNewDevice)
+	}
+	if r.isNewIpRange(log, history) {
+		reasons = append(reasons, models.LoginAnomalyNewIpRange)
+	}
+	if r.isImpossibleTravel(log, history) {
+		reasons = append(reasons, models.LoginAnomalyImpossibleTravel)
+	}
+	if log.UserGuestRelationId != nil && r.isUnusualHour(log, history) {
+		reasons = append(reasons, models.LoginAnomalyUnusualHour)
+	}
+	return reasons
+}
+
+func (r *Rules) isNewDevice(log models.LoginAuditLog, history []models.LoginAuditLog) bool {
+	fingerprint := Fingerprint(log.ClientUserAgent)
+	for _, previous := range history {
+		if Fingerprint(previous.ClientUserAgent) == fingerprint {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Rules) isNewIpRange(log models.LoginAuditLog, history []models.LoginAuditLog) bool {
+	network := r.network(ParseIP(log.ClientIpAddress))
+	if network == nil {
+		return false
+	}
+	for _, previous := range history {
+		if network.Equal(r.network(ParseIP(previous.ClientIpAddress))) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Rules) network(ip net.IP) net.IP {
+	if ip == nil {
+		return nil
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(r.ipv4Mask)
+	}
+	return ip.Mask(r.ipv6Mask)
+}
+
+// isImpossibleTravel compares the location of the login with the location of the latest previous login with a known
+// location
+func (r *Rules) isImpossibleTravel(log models.LoginAuditLog, history []models.LoginAuditLog) bool {
+	if r.geoIP == nil {
+		return false
+	}
+	location := r.geoIP.Lookup(ParseIP(log.ClientIpAddress))
+	if location == nil {
+		return false
+	}
+	for _, previous := range history {
+		previousLocation := r.geoIP.Lookup(ParseIP(previous.ClientIpAddress))
+		if previousLocation == nil {
+			continue
+		}
+		distance := location.Distance(*previousLocation)
+		if distance <= travelTolerance {
+			return false
+		}
+		hours := log.CreatedAt.Sub(previous.CreatedAt).Hours()
+		return hours <= 0 || distance/hours > r.maxTravelSpeed
+	}
+	return false
+}
+
+// isUnusualHour returns whether none of the previous logins was within the tolerance of the hour of the day (UTC) of
+// the login
+func (r *Rules) isUnusualHour(log models.LoginAuditLog, history []models.LoginAuditLog) bool {
+	if len(history) < r.unusualHourMinLogins {
+		return false
+	}
+	hour := log.CreatedAt.UTC().Hour()
+	for _, previous := range history {
+		difference := hour - previous.CreatedAt.UTC().Hour()
+		if difference < 0 {
+			difference = -difference
+		}
+		if difference > 12 {
+			difference = 24 - difference
+		}
+		if difference <= r.unusualHourTolerance {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,198 @@
+package anomaly
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+const (
+	firefox        = "Mozilla/5.0 (X11; Linux x86_64; rv:106.0) Gecko/20100101 Firefox/106.0"
+	updatedFirefox = "Mozilla/5.0 (X11; Linux x86_64; rv:107.0) Gecko/20100101 Firefox/107.0"
+	safari         = "Mozilla/5.0 (iPhone; CPU iPhone OS 16_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.1 Mobile/15E148 Safari/604.1"
+)
+
+var testConfig = config.LoginAnomalies{
+	Enabled:              true,
+	History:              50,
+	IPv4PrefixLength:     24,
+	IPv6PrefixLength:     48,
+	MaxTravelSpeed:       1000,
+	UnusualHourMinLogins: 3,
+	UnusualHourTolerance: 2,
+	RevokeUrl:            "https://example.com/login/revoke",
+	RevokeTTL:            3600,
+}
+
+func writeGeoIP(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "blocks.csv")
+	content := "network,geoname_id,latitude,longitude,accuracy_radius\n" +
+		"192.0.2.0/24,2950159,52.5244,13.4105,20\n" +
+		"198.51.100.0/24,5128581,40.7143,-74.0060,20\n" +
+		"203.0.113.0/24,2867714,48.1374,11.5755,20\n" +
+		"2001:db8::/32,2950159,52.5244,13.4105,20\n" +
+		"100.64.0.0/10,,,,\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func newLog(ip string, userAgent string, createdAt time.Time) models.LoginAuditLog {
+	return models.LoginAuditLog{ClientIpAddress: ip, ClientUserAgent: userAgent, CreatedAt: createdAt}
+}
+
+func TestGeoIP_Lookup(t *testing.T) {
+	geoIP, err := LoadGeoIP(writeGeoIP(t))
+	require.NoError(t, err)
+
+	berlin := geoIP.Lookup(net.ParseIP("192.0.2.17"))
+	if assert.NotNil(t, berlin) {
+		assert.Equal(t, 52.5244, berlin.Latitude)
+	}
+	newYork := geoIP.Lookup(net.ParseIP("198.51.100.255"))
+	if assert.NotNil(t, newYork) {
+		assert.InDelta(t, 6385, berlin.Distance(*newYork), 10)
+	}
+	assert.NotNil(t, geoIP.Lookup(net.ParseIP("2001:db8:1::1")))
+	assert.Nil(t, geoIP.Lookup(net.ParseIP("192.0.3.1")))
+	assert.Nil(t, geoIP.Lookup(net.ParseIP("100.64.0.1")))
+	assert.Nil(t, geoIP.Lookup(nil))
+}
+
+func TestLoadGeoIP_MissingColumn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.csv")
+	require.NoError(t, os.WriteFile(path, []byte("network,geoname_id\n192.0.2.0/24,2950159\n"), 0600))
+
+	_, err := LoadGeoIP(path)
+	assert.Error(t, err)
+}
+
+func TestParseIP(t *testing.T) {
+	assert.Equal(t, "192.0.2.1", ParseIP("192.0.2.1:52314").String())
+	assert.Equal(t, "192.0.2.1", ParseIP("192.0.2.1").String())
+	assert.Equal(t, "2001:db8::1", ParseIP("[2001:db8::1]:443").String())
+	assert.Nil(t, ParseIP("redacted"))
+}
+
+func TestRules_Detect(t *testing.T) {
+	geoIP, err := LoadGeoIP(writeGeoIP(t))
+	require.NoError(t, err)
+	rules := NewRules(testConfig, geoIP)
+
+	now := time.Date(2022, 11, 18, 10, 0, 0, 0, time.UTC)
+	history := []models.LoginAuditLog{
+		newLog("192.0.2.10:4711", firefox, now.Add(-2*time.Hour)),
+		newLog("192.0.2.11:4711", firefox, now.Add(-26*time.Hour)),
+	}
+
+	tests := []struct {
+		name     string
+		log      models.LoginAuditLog
+		history  []models.LoginAuditLog
+		expected []string
+	}{
+		{
+			name:     "first login",
+			log:      newLog("198.51.100.1:4711", safari, now),
+			history:  nil,
+			expected: []string{},
+		},
+		{
+			name:     "known device and network",
+			log:      newLog("192.0.2.99:4711", firefox, now),
+			history:  history,
+			expected: []string{},
+		},
+		{
+			name:     "updated browser",
+			log:      newLog("192.0.2.99:4711", updatedFirefox, now),
+			history:  history,
+			expected: []string{},
+		},
+		{
+			name:     "new device",
+			log:      newLog("192.0.2.99:4711", safari, now),
+			history:  history,
+			expected: []string{models.LoginAnomalyNewDevice},
+		},
+		{
+			name:     "new network nearby",
+			log:      newLog("203.0.113.1:4711", firefox, now),
+			history:  history,
+			expected: []string{models.LoginAnomalyNewIpRange},
+		},
+		{
+			name:     "impossible travel",
+			log:      newLog("198.51.100.1:4711", firefox, now),
+			history:  history,
+			expected: []string{models.LoginAnomalyNewIpRange, models.LoginAnomalyImpossibleTravel},
+		},
+		{
+			name:     "unknown location",
+			log:      newLog("100.64.0.1:4711", firefox, now),
+			history:  history,
+			expected: []string{models.LoginAnomalyNewIpRange},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, rules.Detect(tt.log, tt.history))
+		})
+	}
+}
+
+func TestRules_Detect_PossibleTravel(t *testing.T) {
+	geoIP, err := LoadGeoIP(writeGeoIP(t))
+	require.NoError(t, err)
+	rules := NewRules(testConfig, geoIP)
+
+	now := time.Now().UTC()
+	history := []models.LoginAuditLog{newLog("192.0.2.10", firefox, now.Add(-9*time.Hour))}
+
+	assert.Equal(t, []string{models.LoginAnomalyNewIpRange}, rules.Detect(newLog("198.51.100.1", firefox, now), history))
+}
+
+func TestRules_Detect_WithoutGeoIP(t *testing.T) {
+	rules := NewRules(testConfig, nil)
+
+	now := time.Now().UTC()
+	history := []models.LoginAuditLog{newLog("192.0.2.10", firefox, now.Add(-time.Minute))}
+
+	assert.Equal(t, []string{models.LoginAnomalyNewIpRange}, rules.Detect(newLog("198.51.100.1", firefox, now), history))
+}
+
+func TestRules_Detect_UnusualHour(t *testing.T) {
+	rules := NewRules(testConfig, nil)
+	relationId := uuid.Must(uuid.NewV4())
+
+	day := time.Date(2022, 11, 18, 0, 0, 0, 0, time.UTC)
+	history := []models.LoginAuditLog{
+		newLog("192.0.2.10", firefox, day.Add(-24*time.Hour+9*time.Hour)),
+		newLog("192.0.2.10", firefox, day.Add(-48*time.Hour+10*time.Hour)),
+		newLog("192.0.2.10", firefox, day.Add(-72*time.Hour+23*time.Hour)),
+	}
+
+	night := newLog("192.0.2.10", firefox, day.Add(4*time.Hour))
+	night.UserGuestRelationId = &relationId
+	assert.Equal(t, []string{models.LoginAnomalyUnusualHour}, rules.Detect(night, history))
+
+	// 23 o'clock is within the tolerance of midnight
+	midnight := newLog("192.0.2.10", firefox, day.Add(24*time.Hour))
+	midnight.UserGuestRelationId = &relationId
+	assert.Equal(t, []string{}, rules.Detect(midnight, history))
+
+	// too few logins to know the usual hours
+	assert.Equal(t, []string{}, rules.Detect(night, history[:2]))
+
+	// only guest logins are checked
+	accountHolder := newLog("192.0.2.10", firefox, day.Add(4*time.Hour))
+	assert.Equal(t, []string{}, rules.Detect(accountHolder, history))
+}
@@ -0,0 +1,184 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	bufferPendingFile   = "pending.jsonl"
+	bufferSegmentPrefix = "segment-"
+	bufferSegmentSuffix = ".jsonl"
+)
+
+// buffer keeps the records of a sink on disk until the sink accepted them. New records are appended to the pending
+// file, which is turned into a segment once it holds a batch or when it is flushed. Segments are delivered oldest first
+// and removed when the sink accepted them, so records survive restarts and failed deliveries.
+type buffer struct {
+	dir         string
+	batchSize   int
+	mu          sync.Mutex
+	pending     int
+	nextSegment int
+}
+
+func newBuffer(dir string, batchSize int) (*buffer, error) {
+	err := os.MkdirAll(dir, 0750)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audit buffer directory: %w", err)
+	}
+	b := &buffer{dir: dir, batchSize: batchSize}
+
+	segments, err := b.segments()
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) > 0 {
+		var last int
+		_, err = fmt.Sscanf(segments[len(segments)-1], bufferSegmentPrefix+"%d"+bufferSegmentSuffix, &last)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse audit buffer segment name: %w", err)
+		}
+		b.nextSegment = last + 1
+	}
+
+	records, err := b.read(bufferPendingFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	b.pending = len(records)
+	return b, nil
+}
+
+// Append adds the record to the pending file. It returns true, if a full batch is ready to be delivered.
+func (b *buffer) Append(record Record) (bool, error) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode audit record: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	file, err := os.OpenFile(filepath.Join(b.dir, bufferPendingFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return false, fmt.Errorf("failed to open audit buffer: %w", err)
+	}
+	_, err = file.Write(append(line, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err != nil {
+		return false, fmt.Errorf("failed to write audit buffer: %w", err)
+	}
+	if closeErr != nil {
+		return false, fmt.Errorf("failed to write audit buffer: %w", closeErr)
+	}
+
+	b.pending++
+	if b.pending < b.batchSize {
+		return false, nil
+	}
+	return true, b.rotate()
+}
+
+// Flush turns the pending records into a segment, so they are delivered with the next batch
+func (b *buffer) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rotate()
+}
+
+func (b *buffer) rotate() error {
+	if b.pending == 0 {
+		return nil
+	}
+	name := fmt.Sprintf("%s%020d%s", bufferSegmentPrefix, b.nextSegment, bufferSegmentSuffix)
+	err := os.Rename(filepath.Join(b.dir, bufferPendingFile), filepath.Join(b.dir, name))
+	if err != nil {
+		return fmt.Errorf("failed to rotate audit buffer: %w", err)
+	}
+	b.nextSegment++
+	b.pending = 0
+	return nil
+}
+
+// Next returns the oldest segment and its records or an empty name, if there is none
+func (b *buffer) Next() (string, []Record, error) {
+	segments, err := b.segments()
+	if err != nil {
+		return "", nil, err
+	}
+	if len(segments) == 0 {
+		return "", nil, nil
+	}
+	records, err := b.read(segments[0])
+	if err != nil {
+		return "", nil, err
+	}
+	return segments[0], records, nil
+}
+
+// Remove deletes a delivered segment
+func (b *buffer) Remove(segment string) error {
+	err := os.Remove(filepath.Join(b.dir, segment))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove audit buffer segment: %w", err)
+	}
+	return nil
+}
+
+// segments returns the names of the segments, oldest first
+func (b *buffer) segments() ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit buffer directory: %w", err)
+	}
+	var segments []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, bufferSegmentPrefix) && strings.HasSuffix(name, bufferSegmentSuffix) {
+			segments = append(segments, name)
+		}
+	}
+	sort.Strings(segments)
+	return segments, nil
+}
+
+// read returns the records of a file. Lines which can't be decoded, e.g. a line cut off by a crash, are skipped.
+func (b *buffer) read(name string) ([]Record, error) {
+	file, err := os.Open(filepath.Join(b.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var record Record
+		err = json.Unmarshal(line, &record)
+		if err != nil {
+			log.Printf("skipping corrupt record in audit buffer %s: %v", filepath.Join(b.dir, name), err)
+			continue
+		}
+		records = append(records, record)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit buffer: %w", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/teamhanko/hanko/backend/crypto/jwk"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+// checkpointPayload is the signed content of a checkpoint
+type checkpointPayload struct {
+	Sequence  int       `json:"sequence"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Checkpointer signs the head of the login audit log chain with the JWT signing key. A signed head can't be rewritten
+// without the key, so logs up to the head can't be changed or removed unnoticed, even by someone who recomputes the
+// hashes of the whole chain.
+type Checkpointer struct {
+	persister  persistence.Persister
+	jwkManager jwk.Manager
+	now        func() time.Time
+}
+
+func NewCheckpointer(persister persistence.Persister, jwkManager jwk.Manager) *Checkpointer {
+	return &Checkpointer{persister: persister, jwkManager: jwkManager, now: time.Now}
+}
+
+// Checkpoint signs the current head of the chain. It returns nil, if the chain is empty or the head is signed already.
+func (c *Checkpointer) Checkpoint() (*models.AuditCheckpoint, error) {
+	head, err := c.persister.GetLoginAuditLogPersister().GetChainHead()
+	if err != nil {
+		return nil, err
+	}
+	if head == nil || head.Sequence == 0 {
+		return nil, nil
+	}
+
+	checkpointPersister := c.persister.GetAuditCheckpointPersister()
+	latest, err := checkpointPersister.GetLatest()
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && latest.Sequence >= head.Sequence {
+		return nil, nil
+	}
+
+	key, err := c.jwkManager.GetSigningKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signing key: %w", err)
+	}
+
+	now := c.now().UTC().Truncate(time.Second)
+	payload, err := json.Marshal(checkpointPayload{Sequence: head.Sequence, Hash: head.Hash, CreatedAt: now})
+	if err != nil {
+		return nil, err
+	}
+	signature, err := jws.Sign(payload, jws.WithKey(jwa.RS256, key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign checkpoint: %w", err)
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := models.AuditCheckpoint{
+		ID:        id,
+		Sequence:  head.Sequence,
+		Hash:      head.Hash,
+		Signature: string(signature),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = checkpointPersister.Create(checkpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &checkpoint, nil
+}
+
+// Run signs the head of the chain in the given interval. It does not return.
+func (c *Checkpointer) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		_, err := c.Checkpoint()
+		if err != nil {
+			log.Printf("failed to sign the login audit chain: %v", err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/crypto/jwk"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+func newJwkManager(t *testing.T, persister persistence.Persister) jwk.Manager {
+	manager, err := jwk.NewDefaultManager([]string{"a2c4e6g8i10k12m14o16q18s20"}, persister.GetJwkPersister())
+	require.NoError(t, err)
+	return manager
+}
+
+func createLogs(t *testing.T, persister persistence.Persister, count int) {
+	for i := 0; i < count; i++ {
+		userId, err := uuid.NewV4()
+		require.NoError(t, err)
+		require.NoError(t, persister.GetLoginAuditLogPersister().Create(models.LoginAuditLog{
+			ID:              userId,
+			UserId:          userId,
+			ClientIpAddress: "127.0.0.1",
+			ClientUserAgent: "test",
+			LoginMethod:     1,
+		}))
+	}
+}
+
+func TestCheckpointer_Checkpoint(t *testing.T) {
+	persister := test.NewPersister(nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	checkpointer := NewCheckpointer(persister, newJwkManager(t, persister))
+
+	checkpoint, err := checkpointer.Checkpoint()
+	require.NoError(t, err)
+	assert.Nil(t, checkpoint, "an empty chain is not signed")
+
+	createLogs(t, persister, 2)
+	checkpoint, err = checkpointer.Checkpoint()
+	require.NoError(t, err)
+	require.NotNil(t, checkpoint)
+	head, err := persister.GetLoginAuditLogPersister().GetChainHead()
+	require.NoError(t, err)
+	assert.Equal(t, 2, checkpoint.Sequence)
+	assert.Equal(t, head.Hash, checkpoint.Hash)
+	assert.NotEmpty(t, checkpoint.Signature)
+
+	checkpoint, err = checkpointer.Checkpoint()
+	require.NoError(t, err)
+	assert.Nil(t, checkpoint, "the head is signed already")
+
+	createLogs(t, persister, 1)
+	checkpoint, err = checkpointer.Checkpoint()
+	require.NoError(t, err)
+	require.NotNil(t, checkpoint)
+	assert.Equal(t, 3, checkpoint.Sequence)
+}
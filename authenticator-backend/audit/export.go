@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+// Export bundles the login audit log chain with the signed checkpoints and the public keys to verify them, so that
+// the chain can be verified without access to the database.
+type Export struct {
+	ExportedAt  time.Time                `json:"exported_at"`
+	Records     []models.LoginAuditLog   `json:"records"`
+	Checkpoints []models.AuditCheckpoint `json:"checkpoints"`
+	// Unchained is the number of logs created before the introduction of the hash chain, they are not exported
+	Unchained int `json:"unchained"`
+	// Keys is the JWK set with the public keys of the checkpoint signatures
+	Keys json.RawMessage `json:"keys"`
+}
+
+// NewExport collects the chain, the checkpoints and the public keys
+func NewExport(persister persistence.Persister, keys jwk.Set) (*Export, error) {
+	encodedKeys, err := json.Marshal(keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode keys: %w", err)
+	}
+
+	logPersister := persister.GetLoginAuditLogPersister()
+	export := &Export{
+		ExportedAt: time.Now().UTC(),
+		Records:    []models.LoginAuditLog{},
+		Keys:       encodedKeys,
+	}
+
+	sequence := 0
+	for {
+		logs, err := logPersister.ListChained(sequence, verifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		export.Records = append(export.Records, logs...)
+		if len(logs) < verifyBatchSize {
+			break
+		}
+		sequence = logs[len(logs)-1].Sequence
+	}
+
+	export.Checkpoints, err = persister.GetAuditCheckpointPersister().List()
+	if err != nil {
+		return nil, err
+	}
+	export.Unchained, err = logPersister.CountUnchained()
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// WriteExport writes the export as indented JSON
+func WriteExport(w io.Writer, export *Export) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(export)
+	if err != nil {
+		return fmt.Errorf("failed to encode export: %w", err)
+	}
+	return nil
+}
+
+// ReadExport reads an export written by WriteExport
+func ReadExport(r io.Reader) (*Export, error) {
+	var export Export
+	err := json.NewDecoder(r).Decode(&export)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode export: %w", err)
+	}
+	return &export, nil
+}
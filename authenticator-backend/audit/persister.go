@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+// WithStreaming returns a persister which publishes every stored login audit log and security event to the streamer.
+// Records stored in a transaction are published once the transaction is committed, records of a transaction which is
+// rolled back are not published.
+func WithStreaming(persister persistence.Persister, streamer *Streamer) persistence.Persister {
+	if streamer == nil {
+		return persister
+	}
+	return &streamingPersister{Persister: persister, streamer: streamer, pending: map[*pop.Connection]*[]Record{}}
+}
+
+type streamingPersister struct {
+	persistence.Persister
+	streamer *Streamer
+	mu       sync.Mutex
+	// pending holds the records stored in the open transactions
+	pending map[*pop.Connection]*[]Record
+}
+
+func (p *streamingPersister) Transaction(fn func(tx *pop.Connection) error) error {
+	var records []Record
+	err := p.Persister.Transaction(func(tx *pop.Connection) error {
+		p.mu.Lock()
+		p.pending[tx] = &records
+		p.mu.Unlock()
+		defer func() {
+			p.mu.Lock()
+			delete(p.pending, tx)
+			p.mu.Unlock()
+		}()
+		return fn(tx)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		p.streamer.Publish(record)
+	}
+	return nil
+}
+
+// publisher returns how the records stored with the connection are published. Records stored in a transaction are held
+// back until it is committed.
+func (p *streamingPersister) publisher(tx *pop.Connection) func(Record) {
+	p.mu.Lock()
+	records, ok := p.pending[tx]
+	p.mu.Unlock()
+	if !ok {
+		return p.streamer.Publish
+	}
+	return func(record Record) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		*records = append(*records, record)
+	}
+}
+
+func (p *streamingPersister) GetLoginAuditLogPersister() persistence.LoginAuditLogPersister {
+	return &streamingLoginAuditLogPersister{LoginAuditLogPersister: p.Persister.GetLoginAuditLogPersister(), publish: p.streamer.Publish}
+}
+
+func (p *streamingPersister) GetLoginAuditLogPersisterWithConnection(tx *pop.Connection) persistence.LoginAuditLogPersister {
+	return &streamingLoginAuditLogPersister{LoginAuditLogPersister: p.Persister.GetLoginAuditLogPersisterWithConnection(tx), publish: p.publisher(tx)}
+}
+
+func (p *streamingPersister) GetSecurityEventPersister() persistence.SecurityEventPersister {
+	return &streamingSecurityEventPersister{SecurityEventPersister: p.Persister.GetSecurityEventPersister(), publish: p.streamer.Publish}
+}
+
+func (p *streamingPersister) GetSecurityEventPersisterWithConnection(tx *pop.Connection) persistence.SecurityEventPersister {
+	return &streamingSecurityEventPersister{SecurityEventPersister: p.Persister.GetSecurityEventPersisterWithConnection(tx), publish: p.publisher(tx)}
+}
+
+type streamingLoginAuditLogPersister struct {
+	persistence.LoginAuditLogPersister
+	publish func(Record)
+}
+
+func (p *streamingLoginAuditLogPersister) Create(log models.LoginAuditLog) error {
+	// the persister sets the ID and the time as well, but they are not passed back
+	if log.ID == uuid.Nil {
+		id, err := uuid.NewV4()
+		if err != nil {
+			return err
+		}
+		log.ID = id
+	}
+	log.CreatedAt = time.Now().UTC().Truncate(time.Second)
+
+	err := p.LoginAuditLogPersister.Create(log)
+	if err != nil {
+		return err
+	}
+	p.publish(NewLoginRecord(log))
+	return nil
+}
+
+type streamingSecurityEventPersister struct {
+	persistence.SecurityEventPersister
+	publish func(Record)
+}
+
+func (p *streamingSecurityEventPersister) Create(event models.SecurityEvent) error {
+	err := p.SecurityEventPersister.Create(event)
+	if err != nil {
+		return err
+	}
+	p.publish(NewSecurityEventRecord(event))
+	return nil
+}
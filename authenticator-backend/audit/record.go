@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+// The record types of logins are prefixed with RecordTypeLoginPrefix, all other records are security events
+const RecordTypeLoginPrefix = "login."
+
+var loginMethodNames = map[dto.LoginMethod]string{
+	dto.Password:      "password",
+	dto.Passcode:      "passcode",
+	dto.Webauthn:      "webauthn",
+	dto.LogoutAsGuest: "guest_logout",
+	dto.RecoveryCode:  "recovery_code",
+	dto.Totp:          "totp",
+	dto.PasswordReset: "password_reset",
+}
+
+// Record is a login or a security event as streamed to the sinks
+type Record struct {
+	ID              uuid.UUID              `json:"id"`
+	Type            string                 `json:"type"`
+	ActorUserId     *uuid.UUID             `json:"actor_user_id,omitempty"`
+	SurrogateUserId *uuid.UUID             `json:"surrogate_user_id,omitempty"`
+	TargetUserId    *uuid.UUID             `json:"target_user_id,omitempty"`
+	RequestId       string                 `json:"request_id,omitempty"`
+	ClientIpAddress string                 `json:"client_ip_address,omitempty"`
+	ClientUserAgent string                 `json:"client_user_agent,omitempty"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt       time.Time              `json:"created_at"`
+}
+
+// IsLogin returns whether the record is a login
+func (r Record) IsLogin() bool {
+	return strings.HasPrefix(r.Type, RecordTypeLoginPrefix)
+}
+
+// NewLoginRecord returns the record of a login. The actor is the account logged in to and the surrogate the guest,
+// if a guest logged in.
+func NewLoginRecord(log models.LoginAuditLog) Record {
+	method, ok := loginMethodNames[dto.LoginMethod(log.LoginMethod)]
+	if !ok {
+		method = "unknown"
+	}
+	userId := log.UserId
+	record := Record{
+		ID:              log.ID,
+		Type:            RecordTypeLoginPrefix + method,
+		ActorUserId:     &userId,
+		SurrogateUserId: log.SurrogateUserId,
+		ClientIpAddress: log.ClientIpAddress,
+		ClientUserAgent: log.ClientUserAgent,
+		CreatedAt:       log.CreatedAt.UTC(),
+	}
+	if log.UserGuestRelationId != nil {
+		record.Metadata = map[string]interface{}{"user_guest_relation_id": log.UserGuestRelationId.String()}
+	}
+	return record
+}
+
+// NewSecurityEventRecord returns the record of a security event
+func NewSecurityEventRecord(event models.SecurityEvent) Record {
+	record := Record{
+		ID:              event.ID,
+		Type:            event.Type,
+		ActorUserId:     event.ActorUserId,
+		SurrogateUserId: event.SurrogateUserId,
+		TargetUserId:    event.TargetUserId,
+		RequestId:       event.RequestId,
+		ClientIpAddress: event.ClientIpAddress,
+		ClientUserAgent: event.ClientUserAgent,
+		CreatedAt:       event.CreatedAt.UTC(),
+	}
+	if len(event.Metadata) > 0 {
+		record.Metadata = event.Metadata
+	}
+	return record
+}
@@ -0,0 +1,61 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Sink delivers records to an external system. Send must either accept the whole batch or return an error, in which
+// case the batch is sent again later. Records can therefore be delivered more than once.
+type Sink interface {
+	// Name identifies the sink, it is used as name of the buffer directory of the sink
+	Name() string
+	Send(records []Record) error
+}
+
+// FileSink appends the records as JSON lines to a file
+type FileSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+func (s *FileSink) Name() string {
+	return "file"
+}
+
+func (s *FileSink) Send(records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.MkdirAll(filepath.Dir(s.path), 0750)
+	if err != nil {
+		return fmt.Errorf("failed to create audit file directory: %w", err)
+	}
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open audit file: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, record := range records {
+		err = encoder.Encode(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit record: %w", err)
+		}
+	}
+	err = writer.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to write audit file: %w", err)
+	}
+	return file.Sync()
+}
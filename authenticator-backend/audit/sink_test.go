@@ -0,0 +1,229 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/config"
+)
+
+func newRecords(t *testing.T, types ...string) []Record {
+	var records []Record
+	for _, recordType := range types {
+		id, err := uuid.NewV4()
+		require.NoError(t, err)
+		records = append(records, Record{
+			ID:              id,
+			Type:            recordType,
+			ActorUserId:     &id,
+			ClientIpAddress: "127.0.0.1",
+			Metadata:        map[string]interface{}{"role": "support"},
+			CreatedAt:       time.Date(2022, 11, 17, 10, 0, 0, 0, time.UTC),
+		})
+	}
+	return records
+}
+
+func TestFileSink_Send(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "audit.jsonl")
+	sink := NewFileSink(path)
+	records := newRecords(t, "login.password", "role.assigned", "password.changed")
+
+	require.NoError(t, sink.Send(records[:2]))
+	require.NoError(t, sink.Send(records[2:]))
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var written []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		written = append(written, record)
+	}
+	assert.Equal(t, records, written)
+}
+
+// parseSyslogMessage checks the RFC 5424 header and returns the decoded record of the message
+func parseSyslogMessage(t *testing.T, message string, priority int) Record {
+	parts := strings.SplitN(message, " ", 8)
+	require.Len(t, parts, 8, message)
+	assert.Equal(t, "<"+strconv.Itoa(priority)+">1", parts[0])
+	assert.Equal(t, "2022-11-17T10:00:00Z", parts[1])
+	assert.Equal(t, "auth-host", parts[2])
+	assert.Equal(t, "hanko", parts[3])
+	assert.Equal(t, "-", parts[4])
+	assert.Equal(t, "-", parts[6])
+
+	var record Record
+	require.NoError(t, json.Unmarshal([]byte(parts[7]), &record))
+	assert.Equal(t, record.Type, parts[5])
+	return record
+}
+
+// readOctetCounted returns the messages of a stream framed by octet counting
+func readOctetCounted(t *testing.T, stream string) []string {
+	var messages []string
+	for len(stream) > 0 {
+		space := strings.IndexByte(stream, ' ')
+		require.Greater(t, space, 0)
+		length, err := strconv.Atoi(stream[:space])
+		require.NoError(t, err)
+		stream = stream[space+1:]
+		require.GreaterOrEqual(t, len(stream), length)
+		messages = append(messages, stream[:length])
+		stream = stream[length:]
+	}
+	return messages
+}
+
+// acceptStream accepts one connection and returns everything received until the connection is closed
+func acceptStream(listener net.Listener) chan string {
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- ""
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- string(data)
+	}()
+	return received
+}
+
+func newSyslogConfig(network string, address string) config.AuditSyslogSink {
+	return config.AuditSyslogSink{
+		Enabled:  true,
+		Network:  network,
+		Address:  address,
+		Facility: 10,
+		AppName:  "hanko",
+		Hostname: "auth-host",
+	}
+}
+
+func TestSyslogSink_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	sink := NewSyslogSink(newSyslogConfig(config.AuditSyslogNetworkUDP, conn.LocalAddr().String()))
+	records := newRecords(t, "login.passcode", "user.deactivated")
+	require.NoError(t, sink.Send(records))
+
+	// authpriv (10) * 8 + informational (6) for logins and notice (5) for security events
+	priorities := []int{86, 85}
+	buf := make([]byte, 64*1024)
+	for i, record := range records {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		assert.Equal(t, record, parseSyslogMessage(t, string(buf[:n]), priorities[i]))
+	}
+}
+
+func TestSyslogSink_TCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	received := acceptStream(listener)
+
+	sink := NewSyslogSink(newSyslogConfig(config.AuditSyslogNetworkTCP, listener.Addr().String()))
+	records := newRecords(t, "grant.created", "login.webauthn")
+	require.NoError(t, sink.Send(records))
+
+	messages := readOctetCounted(t, <-received)
+	require.Len(t, messages, 2)
+	assert.Equal(t, records[0], parseSyslogMessage(t, messages[0], 85))
+	assert.Equal(t, records[1], parseSyslogMessage(t, messages[1], 86))
+}
+
+func TestSyslogSink_TLS(t *testing.T) {
+	// the test server provides a certificate for 127.0.0.1 and a client which trusts it
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: server.TLS.Certificates})
+	require.NoError(t, err)
+	defer listener.Close()
+	received := acceptStream(listener)
+
+	sink := NewSyslogSink(newSyslogConfig(config.AuditSyslogNetworkTLS, listener.Addr().String()))
+	sink.tlsConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+	records := newRecords(t, "password.reset")
+	require.NoError(t, sink.Send(records))
+
+	messages := readOctetCounted(t, <-received)
+	require.Len(t, messages, 1)
+	assert.Equal(t, records[0], parseSyslogMessage(t, messages[0], 85))
+}
+
+func TestSyslogSink_Unreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	sink := NewSyslogSink(newSyslogConfig(config.AuditSyslogNetworkTCP, address))
+	assert.Error(t, sink.Send(newRecords(t, "login.password")))
+}
+
+func TestWebhookSink_Send(t *testing.T) {
+	var received []Record
+	var signature, authorization string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		signature = r.Header.Get(WebhookSignatureHeader)
+		assert.Equal(t, "sha256="+Sign(body, "secret"), signature)
+		authorization = r.Header.Get("Authorization")
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.Unmarshal(body, &received))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(config.AuditWebhookSink{
+		Enabled: true,
+		Url:     server.URL,
+		Secret:  "secret",
+		Headers: map[string]string{"Authorization": "Bearer token"},
+		Timeout: "5s",
+	})
+	sink.client = server.Client()
+	records := newRecords(t, "login.totp", "relation.revoked")
+
+	require.NoError(t, sink.Send(records))
+	assert.Equal(t, records, received)
+	assert.NotEmpty(t, signature)
+	assert.Equal(t, "Bearer token", authorization)
+}
+
+func TestWebhookSink_Failure(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(config.AuditWebhookSink{Enabled: true, Url: server.URL, Timeout: "5s"})
+	sink.client = server.Client()
+
+	err := sink.Send(newRecords(t, "login.password"))
+	assert.ErrorContains(t, err, "503")
+}
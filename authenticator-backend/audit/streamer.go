@@ -0,0 +1,179 @@
+package audit
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/teamhanko/hanko/backend/config"
+)
+
+// Streamer delivers the published records to the configured sinks. Every sink has its own buffer and is delivered to
+// independently, so a sink which is down only delays its own records.
+type Streamer struct {
+	workers       []*streamWorker
+	flushInterval time.Duration
+	retryDelay    time.Duration
+	maxRetryDelay time.Duration
+	done          chan struct{}
+	wg            sync.WaitGroup
+}
+
+type streamWorker struct {
+	sink   Sink
+	buffer *buffer
+	// ready is signalled when a full batch is buffered
+	ready chan struct{}
+	// mu serializes the deliveries of the worker loop and Flush
+	mu sync.Mutex
+}
+
+// NewStreamer returns a streamer for the sinks enabled in the config or nil, if no sink is enabled
+func NewStreamer(cfg config.Audit) (*Streamer, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	var sinks []Sink
+	if cfg.File.Enabled {
+		sinks = append(sinks, NewFileSink(cfg.File.Path))
+	}
+	if cfg.Syslog.Enabled {
+		sinks = append(sinks, NewSyslogSink(cfg.Syslog))
+	}
+	if cfg.Webhook.Enabled {
+		sinks = append(sinks, NewWebhookSink(cfg.Webhook))
+	}
+
+	flushInterval, _ := time.ParseDuration(cfg.FlushInterval)
+	retryDelay, _ := time.ParseDuration(cfg.RetryDelay)
+	maxRetryDelay, _ := time.ParseDuration(cfg.MaxRetryDelay)
+	return newStreamer(sinks, cfg.BufferDir, cfg.BatchSize, flushInterval, retryDelay, maxRetryDelay)
+}
+
+func newStreamer(sinks []Sink, bufferDir string, batchSize int, flushInterval, retryDelay, maxRetryDelay time.Duration) (*Streamer, error) {
+	s := &Streamer{
+		flushInterval: flushInterval,
+		retryDelay:    retryDelay,
+		maxRetryDelay: maxRetryDelay,
+		done:          make(chan struct{}),
+	}
+	for _, sink := range sinks {
+		b, err := newBuffer(filepath.Join(bufferDir, sink.Name()), batchSize)
+		if err != nil {
+			return nil, err
+		}
+		s.workers = append(s.workers, &streamWorker{sink: sink, buffer: b, ready: make(chan struct{}, 1)})
+	}
+	return s, nil
+}
+
+// Publish buffers the record for every sink. Failures are logged, they must not fail the action which is recorded.
+func (s *Streamer) Publish(record Record) {
+	for _, worker := range s.workers {
+		ready, err := worker.buffer.Append(record)
+		if err != nil {
+			log.Printf("failed to buffer audit record %s for sink %s: %v", record.ID, worker.sink.Name(), err)
+		}
+		if ready {
+			select {
+			case worker.ready <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// Start delivers the buffered records in the background until Close is called
+func (s *Streamer) Start() {
+	for _, worker := range s.workers {
+		s.wg.Add(1)
+		go s.run(worker)
+	}
+}
+
+// Close stops the delivery. Records which were not delivered yet stay buffered and are delivered after a restart.
+func (s *Streamer) Close() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// Flush delivers all buffered records once. It returns the errors of the sinks which failed.
+func (s *Streamer) Flush() error {
+	var failed []error
+	for _, worker := range s.workers {
+		err := worker.buffer.Flush()
+		if err == nil {
+			err = worker.deliver()
+		}
+		if err != nil {
+			failed = append(failed, fmt.Errorf("%s: %w", worker.sink.Name(), err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to deliver audit records: %v", failed)
+	}
+	return nil
+}
+
+func (s *Streamer) run(worker *streamWorker) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	delay := s.retryDelay
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			err := worker.buffer.Flush()
+			if err != nil {
+				log.Printf("failed to flush audit buffer of sink %s: %v", worker.sink.Name(), err)
+			}
+		case <-worker.ready:
+		}
+
+		err := worker.deliver()
+		for err != nil {
+			log.Printf("failed to deliver audit records to sink %s, retrying in %s: %v", worker.sink.Name(), delay, err)
+			select {
+			case <-s.done:
+				return
+			case <-time.After(delay):
+			}
+			delay *= 2
+			if delay > s.maxRetryDelay {
+				delay = s.maxRetryDelay
+			}
+			err = worker.deliver()
+		}
+		delay = s.retryDelay
+	}
+}
+
+// deliver sends the buffered segments to the sink, oldest first, until all are delivered or the sink fails
+func (w *streamWorker) deliver() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for {
+		segment, records, err := w.buffer.Next()
+		if err != nil {
+			return err
+		}
+		if segment == "" {
+			return nil
+		}
+		if len(records) > 0 {
+			err = w.sink.Send(records)
+			if err != nil {
+				return err
+			}
+		}
+		err = w.buffer.Remove(segment)
+		if err != nil {
+			return err
+		}
+	}
+}
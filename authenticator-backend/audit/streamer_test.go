@@ -0,0 +1,204 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+// recordingSink records the delivered batches and fails as long as failures is greater than 0
+type recordingSink struct {
+	mu       sync.Mutex
+	failures int
+	batches  [][]Record
+}
+
+func (s *recordingSink) Name() string {
+	return "recording"
+}
+
+func (s *recordingSink) Send(records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("sink is down")
+	}
+	s.batches = append(s.batches, records)
+	return nil
+}
+
+func (s *recordingSink) records() []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var records []Record
+	for _, batch := range s.batches {
+		records = append(records, batch...)
+	}
+	return records
+}
+
+func TestStreamer_Flush(t *testing.T) {
+	sink := &recordingSink{}
+	streamer, err := newStreamer([]Sink{sink}, t.TempDir(), 2, time.Hour, time.Millisecond, time.Millisecond)
+	require.NoError(t, err)
+
+	records := newRecords(t, "login.password", "grant.created", "grant.denied")
+	for _, record := range records {
+		streamer.Publish(record)
+	}
+	require.NoError(t, streamer.Flush())
+
+	assert.Equal(t, records, sink.records())
+	require.Len(t, sink.batches, 2, "the records are delivered in batches")
+	assert.Len(t, sink.batches[0], 2)
+}
+
+func TestStreamer_BufferedOnDisk(t *testing.T) {
+	dir := t.TempDir()
+	sink := &recordingSink{failures: 1000}
+	streamer, err := newStreamer([]Sink{sink}, dir, 2, time.Hour, time.Millisecond, time.Millisecond)
+	require.NoError(t, err)
+
+	records := newRecords(t, "login.password", "grant.created", "grant.denied")
+	for _, record := range records {
+		streamer.Publish(record)
+	}
+	assert.Error(t, streamer.Flush())
+	assert.Empty(t, sink.records())
+
+	// a restarted streamer delivers the records buffered before
+	sink = &recordingSink{}
+	streamer, err = newStreamer([]Sink{sink}, dir, 2, time.Hour, time.Millisecond, time.Millisecond)
+	require.NoError(t, err)
+	more := newRecords(t, "login.totp")
+	streamer.Publish(more[0])
+	require.NoError(t, streamer.Flush())
+
+	assert.Equal(t, append(records, more...), sink.records())
+	entries, err := os.ReadDir(filepath.Join(dir, sink.Name()))
+	require.NoError(t, err)
+	assert.Empty(t, entries, "delivered records are removed from the buffer")
+}
+
+func TestStreamer_SkipsCorruptRecords(t *testing.T) {
+	dir := t.TempDir()
+	sink := &recordingSink{}
+	streamer, err := newStreamer([]Sink{sink}, dir, 10, time.Hour, time.Millisecond, time.Millisecond)
+	require.NoError(t, err)
+
+	records := newRecords(t, "login.password", "grant.created")
+	streamer.Publish(records[0])
+	// a record cut off by a crash
+	file, err := os.OpenFile(filepath.Join(dir, sink.Name(), bufferPendingFile), os.O_APPEND|os.O_WRONLY, 0640)
+	require.NoError(t, err)
+	_, err = file.WriteString("{\"id\":\"\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	streamer.Publish(records[1])
+
+	require.NoError(t, streamer.Flush())
+	assert.Equal(t, records, sink.records())
+}
+
+func TestStreamer_Retry(t *testing.T) {
+	sink := &recordingSink{failures: 3}
+	streamer, err := newStreamer([]Sink{sink}, t.TempDir(), 1, time.Hour, time.Millisecond, 5*time.Millisecond)
+	require.NoError(t, err)
+	streamer.Start()
+	defer streamer.Close()
+
+	records := newRecords(t, "login.passcode", "user.activated")
+	for _, record := range records {
+		streamer.Publish(record)
+	}
+
+	assert.Eventually(t, func() bool {
+		return len(sink.records()) == len(records)
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, records, sink.records())
+}
+
+func TestWithStreaming(t *testing.T) {
+	sink := &recordingSink{}
+	streamer, err := newStreamer([]Sink{sink}, t.TempDir(), 10, time.Hour, time.Millisecond, time.Millisecond)
+	require.NoError(t, err)
+	persister := WithStreaming(test.NewPersister(nil, nil, nil, nil, nil, nil, nil, nil, nil), streamer)
+
+	userId, err := uuid.NewV4()
+	require.NoError(t, err)
+	guestId, err := uuid.NewV4()
+	require.NoError(t, err)
+	require.NoError(t, persister.GetLoginAuditLogPersister().Create(models.LoginAuditLog{
+		UserId:          userId,
+		SurrogateUserId: &guestId,
+		ClientIpAddress: "127.0.0.1",
+		ClientUserAgent: "test",
+		LoginMethod:     dto.LoginMethodToValue(dto.Webauthn),
+	}))
+	event := models.NewSecurityEvent(models.EventPasswordChanged)
+	event.ActorUserId = &userId
+	event.TargetUserId = &userId
+	require.NoError(t, persister.GetSecurityEventPersister().Create(event))
+
+	require.NoError(t, streamer.Flush())
+	records := sink.records()
+	require.Len(t, records, 2)
+
+	assert.Equal(t, "login.webauthn", records[0].Type)
+	assert.True(t, records[0].IsLogin())
+	assert.NotEqual(t, uuid.Nil, records[0].ID)
+	assert.Equal(t, userId, *records[0].ActorUserId)
+	assert.Equal(t, guestId, *records[0].SurrogateUserId)
+	assert.Equal(t, "127.0.0.1", records[0].ClientIpAddress)
+	assert.False(t, records[0].CreatedAt.IsZero())
+
+	assert.Equal(t, models.EventPasswordChanged, records[1].Type)
+	assert.False(t, records[1].IsLogin())
+	assert.Equal(t, event.ID, records[1].ID)
+	assert.Equal(t, userId, *records[1].TargetUserId)
+
+	logs, err := persister.GetLoginAuditLogPersister().GetByPrimaryUserId(userId)
+	require.NoError(t, err)
+	assert.Len(t, logs, 1, "the records are stored as well")
+}
+
+func TestWithStreaming_PublishesAfterCommit(t *testing.T) {
+	sink := &recordingSink{}
+	streamer, err := newStreamer([]Sink{sink}, t.TempDir(), 10, time.Hour, time.Millisecond, time.Millisecond)
+	require.NoError(t, err)
+	persister := WithStreaming(test.NewPersister(nil, nil, nil, nil, nil, nil, nil, nil, nil), streamer)
+
+	committed := models.NewSecurityEvent(models.EventPasswordChanged)
+	err = persister.Transaction(func(tx *pop.Connection) error {
+		require.NoError(t, persister.GetSecurityEventPersisterWithConnection(tx).Create(committed))
+		require.NoError(t, streamer.Flush())
+		assert.Empty(t, sink.records(), "records are not published before the commit")
+		return nil
+	})
+	require.NoError(t, err)
+
+	rolledBack := models.NewSecurityEvent(models.EventPasswordChanged)
+	err = persister.Transaction(func(tx *pop.Connection) error {
+		require.NoError(t, persister.GetSecurityEventPersisterWithConnection(tx).Create(rolledBack))
+		return errors.New("rollback")
+	})
+	require.Error(t, err)
+
+	require.NoError(t, streamer.Flush())
+	records := sink.records()
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, committed.ID, records[0].ID)
+	}
+}
@@ -0,0 +1,132 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/teamhanko/hanko/backend/config"
+)
+
+const (
+	syslogSeverityNotice        = 5
+	syslogSeverityInformational = 6
+	syslogDialTimeout           = 10 * time.Second
+	syslogWriteTimeout          = 30 * time.Second
+)
+
+// SyslogSink sends the records as RFC 5424 messages. The message is the JSON encoded record, the MSGID is the type
+// of the record. Over udp every record is sent as a datagram, over tcp and tls the messages are framed by octet
+// counting (RFC 6587).
+type SyslogSink struct {
+	config    config.AuditSyslogSink
+	hostname  string
+	tlsConfig *tls.Config
+}
+
+func NewSyslogSink(cfg config.AuditSyslogSink) *SyslogSink {
+	hostname := cfg.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+	return &SyslogSink{config: cfg, hostname: syslogHeaderField(hostname, 255)}
+}
+
+func (s *SyslogSink) Name() string {
+	return "syslog"
+}
+
+func (s *SyslogSink) Send(records []Record) error {
+	conn, err := s.dial()
+	if err != nil {
+		return fmt.Errorf("failed to connect to syslog server: %w", err)
+	}
+	defer conn.Close()
+
+	err = conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+	if err != nil {
+		return err
+	}
+
+	var frames bytes.Buffer
+	for _, record := range records {
+		message, err := s.format(record)
+		if err != nil {
+			return err
+		}
+		if s.config.Network == config.AuditSyslogNetworkUDP {
+			_, err = conn.Write(message)
+			if err != nil {
+				return fmt.Errorf("failed to send syslog message: %w", err)
+			}
+			continue
+		}
+		frames.WriteString(fmt.Sprintf("%d ", len(message)))
+		frames.Write(message)
+	}
+	if frames.Len() > 0 {
+		_, err = conn.Write(frames.Bytes())
+		if err != nil {
+			return fmt.Errorf("failed to send syslog messages: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *SyslogSink) dial() (net.Conn, error) {
+	switch s.config.Network {
+	case config.AuditSyslogNetworkTLS:
+		tlsConfig := s.tlsConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		return tls.DialWithDialer(&net.Dialer{Timeout: syslogDialTimeout}, "tcp", s.config.Address, tlsConfig)
+	case config.AuditSyslogNetworkTCP:
+		return net.DialTimeout("tcp", s.config.Address, syslogDialTimeout)
+	}
+	return net.DialTimeout("udp", s.config.Address, syslogDialTimeout)
+}
+
+// format returns the record as RFC 5424 message: <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+func (s *SyslogSink) format(record Record) ([]byte, error) {
+	severity := syslogSeverityNotice
+	if record.IsLogin() {
+		severity = syslogSeverityInformational
+	}
+	message, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit record: %w", err)
+	}
+
+	return []byte(fmt.Sprintf("<%d>1 %s %s %s - %s - %s",
+		s.config.Facility*8+severity,
+		record.CreatedAt.UTC().Format(time.RFC3339Nano),
+		s.hostname,
+		syslogHeaderField(s.config.AppName, 48),
+		syslogHeaderField(record.Type, 32),
+		message,
+	)), nil
+}
+
+// syslogHeaderField returns the value restricted to the printable US-ASCII characters allowed in a header field, or
+// the nil value "-" if nothing remains
+func syslogHeaderField(value string, maxLength int) string {
+	field := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+	if len(field) > maxLength {
+		field = field[:maxLength]
+	}
+	if field == "" {
+		return "-"
+	}
+	return field
+}
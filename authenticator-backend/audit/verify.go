@@ -0,0 +1,193 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gofrs/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+// verifyBatchSize is the number of logs loaded at once while walking the chain
+const verifyBatchSize = 1000
+
+// BrokenLink is the first place where the chain does not hold
+type BrokenLink struct {
+	Sequence int       `json:"sequence"`
+	ID       uuid.UUID `json:"id,omitempty"`
+	Reason   string    `json:"reason"`
+}
+
+func (l *BrokenLink) String() string {
+	if l.ID.IsNil() {
+		return fmt.Sprintf("sequence %d: %s", l.Sequence, l.Reason)
+	}
+	return fmt.Sprintf("sequence %d (log %s): %s", l.Sequence, l.ID, l.Reason)
+}
+
+// Report is the result of a verification. Broken is nil if the chain is intact.
+type Report struct {
+	// Records is the number of logs in the chain which were checked
+	Records int `json:"records"`
+	// Unchained is the number of logs created before the introduction of the hash chain, they can't be verified
+	Unchained int `json:"unchained"`
+	// Pseudonymised is the number of logs whose personal data was replaced after the deletion of the user
+	Pseudonymised int `json:"pseudonymised"`
+	// Checkpoints is the number of signed checkpoints which were checked
+	Checkpoints int         `json:"checkpoints"`
+	Broken      *BrokenLink `json:"broken,omitempty"`
+}
+
+// Verify walks the login audit log chain stored in the database and checks it against the signed checkpoints
+func Verify(persister persistence.Persister, keys jwk.Set) (*Report, error) {
+	logPersister := persister.GetLoginAuditLogPersister()
+	unchained, err := logPersister.CountUnchained()
+	if err != nil {
+		return nil, err
+	}
+	checkpoints, err := persister.GetAuditCheckpointPersister().List()
+	if err != nil {
+		return nil, err
+	}
+	head, err := logPersister.GetChainHead()
+	if err != nil {
+		return nil, err
+	}
+
+	v := newVerifier(checkpoints, keys)
+	v.report.Unchained = unchained
+	if v.report.Broken != nil {
+		return &v.report, nil
+	}
+
+	sequence := 0
+	for {
+		logs, err := logPersister.ListChained(sequence, verifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, log := range logs {
+			if !v.add(log) {
+				return &v.report, nil
+			}
+			sequence = log.Sequence
+		}
+		if len(logs) < verifyBatchSize {
+			break
+		}
+	}
+
+	v.finish(head)
+	return &v.report, nil
+}
+
+// VerifyExport checks the chain of an export with the public keys contained in the export
+func VerifyExport(export *Export) (*Report, error) {
+	keys, err := jwk.Parse(export.Keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the keys of the export: %w", err)
+	}
+
+	v := newVerifier(export.Checkpoints, keys)
+	v.report.Unchained = export.Unchained
+	if v.report.Broken != nil {
+		return &v.report, nil
+	}
+	for _, log := range export.Records {
+		if !v.add(log) {
+			return &v.report, nil
+		}
+	}
+	v.finish(nil)
+	return &v.report, nil
+}
+
+// verifier checks the logs one by one in the order of their sequence
+type verifier struct {
+	report   Report
+	previous *models.LoginAuditLog
+	// signed are the hashes of the signed checkpoints by their sequence
+	signed map[int]string
+}
+
+// newVerifier checks the signatures of the checkpoints, the report is broken if one of them is invalid
+func newVerifier(checkpoints []models.AuditCheckpoint, keys jwk.Set) *verifier {
+	v := &verifier{signed: map[int]string{}}
+	for _, checkpoint := range checkpoints {
+		payload, err := jws.Verify([]byte(checkpoint.Signature), jws.WithKeySet(keys))
+		if err != nil {
+			v.report.Broken = &BrokenLink{Sequence: checkpoint.Sequence, Reason: fmt.Sprintf("the signature of checkpoint %s is invalid: %v", checkpoint.ID, err)}
+			return v
+		}
+		var signed checkpointPayload
+		err = json.Unmarshal(payload, &signed)
+		if err != nil || signed.Sequence != checkpoint.Sequence || signed.Hash != checkpoint.Hash {
+			v.report.Broken = &BrokenLink{Sequence: checkpoint.Sequence, Reason: fmt.Sprintf("checkpoint %s does not match its signature", checkpoint.ID)}
+			return v
+		}
+		v.signed[signed.Sequence] = signed.Hash
+		v.report.Checkpoints++
+	}
+	return v
+}
+
+// add checks the log and returns false if the chain is broken
+func (v *verifier) add(log models.LoginAuditLog) bool {
+	expectedSequence, expectedPreviousHash := 1, ""
+	if v.previous != nil {
+		expectedSequence, expectedPreviousHash = v.previous.Sequence+1, v.previous.Hash
+	}
+
+	switch {
+	case log.Sequence > expectedSequence:
+		v.report.Broken = &BrokenLink{Sequence: expectedSequence, Reason: "the log is missing"}
+	case log.Sequence < expectedSequence:
+		v.report.Broken = &BrokenLink{Sequence: log.Sequence, ID: log.ID, Reason: "the sequence is used twice"}
+	case log.PreviousHash != expectedPreviousHash:
+		v.report.Broken = &BrokenLink{Sequence: log.Sequence, ID: log.ID, Reason: "the log is not linked to the previous log"}
+	case log.ComputeHash() != log.Hash:
+		v.report.Broken = &BrokenLink{Sequence: log.Sequence, ID: log.ID, Reason: "the log was changed"}
+	case log.ComputePersonalDigest() != log.PersonalDigest && !log.IsPseudonymised():
+		v.report.Broken = &BrokenLink{Sequence: log.Sequence, ID: log.ID, Reason: "the user or client of the log was changed"}
+	}
+	if hash, ok := v.signed[log.Sequence]; ok && v.report.Broken == nil && hash != log.Hash {
+		v.report.Broken = &BrokenLink{Sequence: log.Sequence, ID: log.ID, Reason: "the log does not match the signed checkpoint"}
+	}
+	if v.report.Broken != nil {
+		return false
+	}
+
+	if log.ComputePersonalDigest() != log.PersonalDigest {
+		v.report.Pseudonymised++
+	}
+	v.report.Records++
+	v.previous = &log
+	return true
+}
+
+// finish checks that no logs were removed from the end of the chain. The head is nil for exports, there only the
+// checkpoints are checked.
+func (v *verifier) finish(head *models.AuditChainHead) {
+	lastSequence, lastHash := 0, ""
+	if v.previous != nil {
+		lastSequence, lastHash = v.previous.Sequence, v.previous.Hash
+	}
+
+	signedSequence := 0
+	for sequence := range v.signed {
+		if sequence > signedSequence {
+			signedSequence = sequence
+		}
+	}
+	if signedSequence > lastSequence {
+		v.report.Broken = &BrokenLink{Sequence: lastSequence + 1, Reason: fmt.Sprintf("the log is missing, the chain was signed up to sequence %d", signedSequence)}
+		return
+	}
+
+	if head != nil && (head.Sequence != lastSequence || head.Hash != lastHash) {
+		v.report.Broken = &BrokenLink{Sequence: lastSequence + 1, Reason: fmt.Sprintf("the log is missing, the head of the chain is at sequence %d", head.Sequence)}
+	}
+}
@@ -0,0 +1,191 @@
+package audit
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+// newSignedChain returns a persister with an unchained log, a chain of five logs and a checkpoint at sequence four
+func newSignedChain(t *testing.T) (persistence.Persister, []models.LoginAuditLog, []models.AuditCheckpoint) {
+	userId, err := uuid.NewV4()
+	require.NoError(t, err)
+	unchained := models.LoginAuditLog{ID: userId, UserId: userId, ClientIpAddress: "127.0.0.1", ClientUserAgent: "test", LoginMethod: 1}
+	persister := test.NewPersister(nil, nil, nil, nil, nil, nil, nil, nil, []models.LoginAuditLog{unchained})
+	checkpointer := NewCheckpointer(persister, newJwkManager(t, persister))
+
+	createLogs(t, persister, 4)
+	_, err = checkpointer.Checkpoint()
+	require.NoError(t, err)
+	createLogs(t, persister, 1)
+
+	logs, err := persister.GetLoginAuditLogPersister().ListChained(0, 10)
+	require.NoError(t, err)
+	checkpoints, err := persister.GetAuditCheckpointPersister().List()
+	require.NoError(t, err)
+	return persister, logs, checkpoints
+}
+
+// tamper returns a persister with the given logs and checkpoints and the keys of the original persister, as if the
+// logs were changed in the database
+func tamper(t *testing.T, original persistence.Persister, logs []models.LoginAuditLog, checkpoints []models.AuditCheckpoint) persistence.Persister {
+	keys, err := original.GetJwkPersister().GetAll()
+	require.NoError(t, err)
+	persister := test.NewPersister(nil, nil, keys, nil, nil, nil, nil, nil, logs)
+	for _, checkpoint := range checkpoints {
+		require.NoError(t, persister.GetAuditCheckpointPersister().Create(checkpoint))
+	}
+	return persister
+}
+
+func TestVerify(t *testing.T) {
+	persister, _, _ := newSignedChain(t)
+	keys, err := newJwkManager(t, persister).GetPublicKeys()
+	require.NoError(t, err)
+
+	report, err := Verify(persister, keys)
+	require.NoError(t, err)
+	assert.Nil(t, report.Broken)
+	assert.Equal(t, 5, report.Records)
+	assert.Equal(t, 1, report.Unchained)
+	assert.Equal(t, 1, report.Checkpoints)
+}
+
+func TestVerify_Pseudonymised(t *testing.T) {
+	persister, logs, _ := newSignedChain(t)
+	keys, err := newJwkManager(t, persister).GetPublicKeys()
+	require.NoError(t, err)
+
+	require.NoError(t, persister.GetLoginAuditLogPersister().Pseudonymise(logs[1].UserId, logs[0].UserId))
+
+	report, err := Verify(persister, keys)
+	require.NoError(t, err)
+	assert.Nil(t, report.Broken)
+	assert.Equal(t, 1, report.Pseudonymised)
+}
+
+func TestVerify_Broken(t *testing.T) {
+	persister, logs, checkpoints := newSignedChain(t)
+	manager := newJwkManager(t, persister)
+	keys, err := manager.GetPublicKeys()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		tamper   func(logs []models.LoginAuditLog, checkpoints []models.AuditCheckpoint) ([]models.LoginAuditLog, []models.AuditCheckpoint)
+		sequence int
+		reason   string
+	}{
+		{
+			name: "changed log",
+			tamper: func(logs []models.LoginAuditLog, checkpoints []models.AuditCheckpoint) ([]models.LoginAuditLog, []models.AuditCheckpoint) {
+				logs[1].LoginMethod = 2
+				return logs, checkpoints
+			},
+			sequence: 2,
+			reason:   "the log was changed",
+		},
+		{
+			name: "changed client",
+			tamper: func(logs []models.LoginAuditLog, checkpoints []models.AuditCheckpoint) ([]models.LoginAuditLog, []models.AuditCheckpoint) {
+				logs[2].ClientIpAddress = "10.0.0.1"
+				return logs, checkpoints
+			},
+			sequence: 3,
+			reason:   "the user or client of the log was changed",
+		},
+		{
+			name: "removed log",
+			tamper: func(logs []models.LoginAuditLog, checkpoints []models.AuditCheckpoint) ([]models.LoginAuditLog, []models.AuditCheckpoint) {
+				return append(logs[:1], logs[2:]...), checkpoints
+			},
+			sequence: 2,
+			reason:   "the log is missing",
+		},
+		{
+			name: "rehashed chain",
+			tamper: func(logs []models.LoginAuditLog, checkpoints []models.AuditCheckpoint) ([]models.LoginAuditLog, []models.AuditCheckpoint) {
+				logs[0].LoginMethod = 2
+				for i := range logs {
+					previousSequence, previousHash := 0, ""
+					if i > 0 {
+						previousSequence, previousHash = logs[i-1].Sequence, logs[i-1].Hash
+					}
+					logs[i].Link(previousSequence, previousHash)
+				}
+				return logs, checkpoints
+			},
+			sequence: 4,
+			reason:   "the log does not match the signed checkpoint",
+		},
+		{
+			name: "removed signed logs",
+			tamper: func(logs []models.LoginAuditLog, checkpoints []models.AuditCheckpoint) ([]models.LoginAuditLog, []models.AuditCheckpoint) {
+				return logs[:2], checkpoints
+			},
+			sequence: 3,
+			reason:   "the log is missing, the chain was signed up to sequence 4",
+		},
+		{
+			name: "forged checkpoint",
+			tamper: func(logs []models.LoginAuditLog, checkpoints []models.AuditCheckpoint) ([]models.LoginAuditLog, []models.AuditCheckpoint) {
+				checkpoints[0].Hash = logs[4].Hash
+				checkpoints[0].Sequence = 5
+				return logs, checkpoints
+			},
+			sequence: 5,
+			reason:   "does not match its signature",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tamperedLogs, tamperedCheckpoints := tt.tamper(
+				append([]models.LoginAuditLog{}, logs...),
+				append([]models.AuditCheckpoint{}, checkpoints...),
+			)
+
+			report, err := Verify(tamper(t, persister, tamperedLogs, tamperedCheckpoints), keys)
+			require.NoError(t, err)
+			if assert.NotNil(t, report.Broken) {
+				assert.Equal(t, tt.sequence, report.Broken.Sequence)
+				assert.Contains(t, report.Broken.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestVerifyExport(t *testing.T) {
+	persister, _, _ := newSignedChain(t)
+	keys, err := newJwkManager(t, persister).GetPublicKeys()
+	require.NoError(t, err)
+
+	export, err := NewExport(persister, keys)
+	require.NoError(t, err)
+	assert.Len(t, export.Records, 5)
+	assert.Len(t, export.Checkpoints, 1)
+	assert.Equal(t, 1, export.Unchained)
+
+	var buffer bytes.Buffer
+	require.NoError(t, WriteExport(&buffer, export))
+	read, err := ReadExport(&buffer)
+	require.NoError(t, err)
+
+	report, err := VerifyExport(read)
+	require.NoError(t, err)
+	assert.Nil(t, report.Broken)
+	assert.Equal(t, 5, report.Records)
+
+	read.Records[3].UserGuestRelationId = &read.Records[0].ID
+	report, err = VerifyExport(read)
+	require.NoError(t, err)
+	if assert.NotNil(t, report.Broken) {
+		assert.Equal(t, 4, report.Broken.Sequence)
+	}
+}
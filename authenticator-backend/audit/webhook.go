@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/teamhanko/hanko/backend/config"
+)
+
+// WebhookSignatureHeader carries the HMAC-SHA256 of the body, if a secret is configured
+const WebhookSignatureHeader = "X-Hanko-Signature"
+
+// WebhookSink posts the records as JSON array to an HTTPS endpoint. Every 2xx response counts as delivered.
+type WebhookSink struct {
+	config config.AuditWebhookSink
+	client *http.Client
+}
+
+func NewWebhookSink(cfg config.AuditWebhookSink) *WebhookSink {
+	timeout, _ := time.ParseDuration(cfg.Timeout)
+	return &WebhookSink{config: cfg, client: &http.Client{Timeout: timeout}}
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) Send(records []Record) error {
+	body, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit records: %w", err)
+	}
+
+	request, err := http.NewRequest(http.MethodPost, s.config.Url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	for name, value := range s.config.Headers {
+		request.Header.Set(name, value)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if s.config.Secret != "" {
+		request.Header.Set(WebhookSignatureHeader, "sha256="+Sign(body, s.config.Secret))
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook request: %w", err)
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", response.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the body, receivers compare it with the X-Hanko-Signature header
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
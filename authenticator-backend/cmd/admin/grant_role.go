@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"log"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	"github.com/spf13/cobra"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+func NewGrantRoleCommand(config *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "grant-role <user_id|email> <role>",
+		Short: "assign a role to a user",
+		Long: `Assigns a role to a user, e.g. to create the first super-admin, who can then assign roles to other users.
+The roles are support, user-admin and super-admin.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			persister, err := persistence.New(config.Database)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			var user *models.User
+			if userId, err := uuid.FromString(args[0]); err == nil {
+				user, err = persister.GetUserPersister().Get(userId)
+				if err != nil {
+					log.Fatal(err)
+				}
+			} else {
+				user, err = persister.GetUserPersister().GetByEmail(strings.ToLower(args[0]))
+				if err != nil {
+					log.Fatal(err)
+				}
+			}
+			if user == nil {
+				log.Fatalf("user %s not found", args[0])
+			}
+
+			rolePersister := persister.GetRolePersister()
+			role, err := rolePersister.GetByName(args[1])
+			if err != nil {
+				log.Fatal(err)
+			}
+			if role == nil {
+				log.Fatalf("role %s not found", args[1])
+			}
+
+			err = rolePersister.AddToUser(user.ID, role.ID)
+			if err != nil {
+				log.Fatalf("failed to assign role: %s", err)
+			}
+			log.Printf("assigned role %s to user %s (%s)", role.Name, user.ID, user.Email)
+		},
+	}
+}
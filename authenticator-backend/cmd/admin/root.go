@@ -0,0 +1,20 @@
+package admin
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/teamhanko/hanko/backend/config"
+)
+
+func NewAdminCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "admin",
+		Short: "Tools for managing the administrators",
+		Long:  ``,
+	}
+}
+
+func RegisterCommands(parent *cobra.Command, cfg *config.Config) {
+	cmd := NewAdminCmd()
+	parent.AddCommand(cmd)
+	cmd.AddCommand(NewGrantRoleCommand(cfg))
+}
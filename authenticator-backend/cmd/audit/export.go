@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/teamhanko/hanko/backend/audit"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/crypto/jwk"
+	"github.com/teamhanko/hanko/backend/persistence"
+)
+
+func NewExportCommand(config *config.Config) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "export the login audit with the signed checkpoints",
+		Long: `Exports the hash chain of the login audit together with the signed checkpoints and the public keys, so that
+it can be verified with "audit verify --file" without access to the database.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			persister, err := persistence.New(config.Database)
+			if err != nil {
+				log.Fatal(err)
+			}
+			jwkManager, err := jwk.NewDefaultManager(config.Secrets.Keys, persister.GetJwkPersister())
+			if err != nil {
+				log.Fatalf("failed to create jwk manager: %s", err)
+			}
+			keys, err := jwkManager.GetPublicKeys()
+			if err != nil {
+				log.Fatalf("failed to get public keys: %s", err)
+			}
+
+			export, err := audit.NewExport(persister, keys)
+			if err != nil {
+				log.Fatalf("failed to export the login audit: %s", err)
+			}
+
+			var w io.Writer = os.Stdout
+			if output != "" {
+				file, err := os.Create(output)
+				if err != nil {
+					log.Fatalf("failed to create output file: %s", err)
+				}
+				defer file.Close()
+				w = file
+			}
+			err = audit.WriteExport(w, export)
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "file to write the export to, defaults to stdout")
+
+	return cmd
+}
@@ -0,0 +1,21 @@
+package audit
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/teamhanko/hanko/backend/config"
+)
+
+func NewAuditCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "audit",
+		Short: "Tools for verifying and exporting the login audit",
+		Long:  ``,
+	}
+}
+
+func RegisterCommands(parent *cobra.Command, cfg *config.Config) {
+	cmd := NewAuditCmd()
+	parent.AddCommand(cmd)
+	cmd.AddCommand(NewVerifyCommand(cfg))
+	cmd.AddCommand(NewExportCommand(cfg))
+}
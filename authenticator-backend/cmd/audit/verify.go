@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/teamhanko/hanko/backend/audit"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/crypto/jwk"
+	"github.com/teamhanko/hanko/backend/persistence"
+)
+
+func NewVerifyCommand(config *config.Config) *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "verify the hash chain of the login audit",
+		Long: `Walks the hash chain of the login audit and checks it against the signed checkpoints. The first broken
+link is reported and the command exits with status 1. With --file an export created by "audit export" is verified
+instead of the database.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			var report *audit.Report
+			if file != "" {
+				f, err := os.Open(file)
+				if err != nil {
+					log.Fatalf("failed to open export: %s", err)
+				}
+				defer f.Close()
+				export, err := audit.ReadExport(f)
+				if err != nil {
+					log.Fatal(err)
+				}
+				report, err = audit.VerifyExport(export)
+				if err != nil {
+					log.Fatal(err)
+				}
+			} else {
+				persister, err := persistence.New(config.Database)
+				if err != nil {
+					log.Fatal(err)
+				}
+				jwkManager, err := jwk.NewDefaultManager(config.Secrets.Keys, persister.GetJwkPersister())
+				if err != nil {
+					log.Fatalf("failed to create jwk manager: %s", err)
+				}
+				keys, err := jwkManager.GetPublicKeys()
+				if err != nil {
+					log.Fatalf("failed to get public keys: %s", err)
+				}
+				report, err = audit.Verify(persister, keys)
+				if err != nil {
+					log.Fatalf("failed to verify the login audit: %s", err)
+				}
+			}
+
+			log.Printf("checked %d logs and %d checkpoints, %d logs are pseudonymised, %d logs predate the hash chain",
+				report.Records, report.Checkpoints, report.Pseudonymised, report.Unchained)
+			if report.Broken != nil {
+				log.Fatalf("the chain is broken at %s", report.Broken)
+			}
+			log.Print("the chain is intact")
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "export to verify instead of the database")
+
+	return cmd
+}
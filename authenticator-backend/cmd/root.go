@@ -5,10 +5,13 @@ package cmd
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/teamhanko/hanko/backend/cmd/admin"
+	"github.com/teamhanko/hanko/backend/cmd/audit"
 	"github.com/teamhanko/hanko/backend/cmd/jwk"
 	"github.com/teamhanko/hanko/backend/cmd/jwt"
 	"github.com/teamhanko/hanko/backend/cmd/migrate"
 	"github.com/teamhanko/hanko/backend/cmd/serve"
+	"github.com/teamhanko/hanko/backend/cmd/user"
 	"github.com/teamhanko/hanko/backend/config"
 	"log"
 )
@@ -28,6 +31,9 @@ func NewRootCmd() *cobra.Command {
 	serve.RegisterCommands(cmd, &cfg)
 	jwk.RegisterCommands(cmd)
 	jwt.RegisterCommands(cmd, &cfg)
+	user.RegisterCommands(cmd, &cfg)
+	admin.RegisterCommands(cmd, &cfg)
+	audit.RegisterCommands(cmd, &cfg)
 
 	return cmd
 }
@@ -6,9 +6,7 @@ package serve
 import (
 	"github.com/spf13/cobra"
 	"github.com/teamhanko/hanko/backend/config"
-	"github.com/teamhanko/hanko/backend/persistence"
 	"github.com/teamhanko/hanko/backend/server"
-	"log"
 	"sync"
 )
 
@@ -18,10 +16,8 @@ func NewServePrivateCommand(config *config.Config) *cobra.Command {
 		Short: "Start the private portion of the hanko server",
 		Long:  ``,
 		Run: func(cmd *cobra.Command, args []string) {
-			persister, err := persistence.New(config.Database)
-			if err != nil {
-				log.Fatal(err)
-			}
+			persister := newPersister(config)
+			server.StartJobs(config, persister)
 			var wg sync.WaitGroup
 			wg.Add(1)
 
@@ -5,7 +5,10 @@ package serve
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/teamhanko/hanko/backend/audit"
 	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"log"
 )
 
 func NewServeCommand() *cobra.Command {
@@ -23,3 +26,20 @@ func RegisterCommands(parent *cobra.Command, config *config.Config) {
 	cmd.AddCommand(NewServePrivateCommand(config))
 	cmd.AddCommand(NewServeAllCommand(config))
 }
+
+// newPersister connects to the database and streams the audit to the configured sinks
+func newPersister(config *config.Config) persistence.Persister {
+	persister, err := persistence.New(config.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	streamer, err := audit.NewStreamer(config.Audit)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if streamer == nil {
+		return persister
+	}
+	streamer.Start()
+	return audit.WithStreaming(persister, streamer)
+}
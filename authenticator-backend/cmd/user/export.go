@@ -0,0 +1,96 @@
+package user
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+
+	"github.com/gofrs/uuid"
+	"github.com/spf13/cobra"
+	"github.com/teamhanko/hanko/backend/account"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/persistence"
+)
+
+func NewExportCommand(config *config.Config) *cobra.Command {
+	var (
+		output string
+		zipped bool
+		format string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export [user_id]",
+		Short: "export all users for an import or, with a user_id, the data stored about a user",
+		Long: `Without user_id all users are exported as CSV or JSONL in the format read by "user import".
+With user_id the data stored about the user is exported as JSON.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				if zipped {
+					return errors.New("--zip requires a user_id")
+				}
+				if format != account.FormatJSONL && format != account.FormatCSV {
+					return errors.New("format must be one of jsonl, csv")
+				}
+				return nil
+			}
+			if _, err := uuid.FromString(args[0]); err != nil {
+				return errors.New("user_id is not a uuid")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			persister, err := persistence.New(config.Database)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			var w io.Writer = os.Stdout
+			if output != "" {
+				file, err := os.Create(output)
+				if err != nil {
+					log.Fatalf("failed to create output file: %s", err)
+				}
+				defer file.Close()
+				w = file
+			}
+
+			if len(args) == 0 {
+				writer, err := account.NewPortableWriter(w, format)
+				if err != nil {
+					log.Fatal(err)
+				}
+				count, err := account.ExportUsers(persister, writer)
+				if err != nil {
+					log.Fatalf("failed to export users: %s", err)
+				}
+				log.Printf("exported %d users", count)
+				return
+			}
+
+			export, err := account.NewExporter(persister).Export(uuid.FromStringOrNil(args[0]))
+			if err != nil {
+				log.Fatalf("failed to export user: %s", err)
+			}
+			if export == nil {
+				log.Fatalf("user %s not found", args[0])
+			}
+
+			if zipped {
+				err = account.WriteZip(w, export)
+			} else {
+				err = account.WriteJSON(w, export)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "file to write the export to, defaults to stdout")
+	cmd.Flags().BoolVar(&zipped, "zip", false, "write the export of a user as zip archive")
+	cmd.Flags().StringVar(&format, "format", account.FormatJSONL, "format of the export of all users, one of jsonl, csv")
+
+	return cmd
+}
@@ -0,0 +1,82 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/teamhanko/hanko/backend/account"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/persistence"
+)
+
+func NewImportCommand(config *config.Config) *cobra.Command {
+	var (
+		format string
+		dryRun bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import [file]",
+		Short: "import users from a CSV or JSONL file",
+		Long: `Creates the users of the file or updates them if they already exist, so an import can be repeated.
+Users are matched by id, if given, and otherwise by email. Without file the users are read from stdin.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 1 {
+				return errors.New("only one file can be imported")
+			}
+			if format != "" && format != account.FormatJSONL && format != account.FormatCSV {
+				return errors.New("format must be one of jsonl, csv")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			var r io.Reader = os.Stdin
+			if len(args) == 1 && args[0] != "-" {
+				file, err := os.Open(args[0])
+				if err != nil {
+					log.Fatalf("failed to open file: %s", err)
+				}
+				defer file.Close()
+				r = file
+
+				if format == "" && filepath.Ext(args[0]) == ".csv" {
+					format = account.FormatCSV
+				}
+			}
+			if format == "" {
+				format = account.FormatJSONL
+			}
+
+			reader, err := account.NewPortableReader(r, format)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			persister, err := persistence.New(config.Database)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			report, err := account.NewImporter(persister, dryRun).Import(reader)
+			if report != nil {
+				fmt.Print(report)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			if len(report.Failures) > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "", "format of the file, one of jsonl, csv. Defaults to csv for .csv files and jsonl otherwise")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "only report the changes, nothing is stored")
+
+	return cmd
+}
@@ -0,0 +1,21 @@
+package user
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/teamhanko/hanko/backend/config"
+)
+
+func NewUserCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "user",
+		Short: "Tools for managing users",
+		Long:  ``,
+	}
+}
+
+func RegisterCommands(parent *cobra.Command, cfg *config.Config) {
+	cmd := NewUserCmd()
+	parent.AddCommand(cmd)
+	cmd.AddCommand(NewExportCommand(cfg))
+	cmd.AddCommand(NewImportCommand(cfg))
+}
@@ -8,20 +8,32 @@ import (
 	"github.com/knadh/koanf/providers/env"
 	"github.com/knadh/koanf/providers/file"
 	"log"
+	"net"
+	"os"
 	"strings"
 	"time"
 )
 
 // Config is the central configuration type
 type Config struct {
-	Server   Server           `yaml:"server" json:"server" koanf:"server"`
-	Webauthn WebauthnSettings `yaml:"webauthn" json:"webauthn" koanf:"webauthn"`
-	Passcode Passcode         `yaml:"passcode" json:"passcode" koanf:"passcode"`
-	Password Password         `yaml:"password" json:"password" koanf:"password"`
-	Database Database         `yaml:"database" json:"database" koanf:"database"`
-	Secrets  Secrets          `yaml:"secrets" json:"secrets" koanf:"secrets"`
-	Service  Service          `yaml:"service" json:"service" koanf:"service"`
-	Session  Session          `yaml:"session" json:"session" koanf:"session"`
+	Server       Server           `yaml:"server" json:"server" koanf:"server"`
+	Webauthn     WebauthnSettings `yaml:"webauthn" json:"webauthn" koanf:"webauthn"`
+	Passcode     Passcode         `yaml:"passcode" json:"passcode" koanf:"passcode"`
+	Password     Password         `yaml:"password" json:"password" koanf:"password"`
+	Database     Database         `yaml:"database" json:"database" koanf:"database"`
+	Secrets      Secrets          `yaml:"secrets" json:"secrets" koanf:"secrets"`
+	Service      Service          `yaml:"service" json:"service" koanf:"service"`
+	Session      Session          `yaml:"session" json:"session" koanf:"session"`
+	SecondFactor SecondFactor     `yaml:"second_factor" json:"second_factor" koanf:"second_factor"`
+	Hashing      Hashing          `yaml:"hashing" json:"hashing" koanf:"hashing"`
+	RateLimit    RateLimit        `yaml:"rate_limit" json:"rate_limit" koanf:"rate_limit"`
+	Account      Account          `yaml:"account" json:"account" koanf:"account"`
+	Audit        Audit            `yaml:"audit" json:"audit" koanf:"audit"`
+	Webhooks     Webhooks         `yaml:"webhooks" json:"webhooks" koanf:"webhooks"`
+	// LoginAnomalies configures the comparison of logins with the login history of the users
+	LoginAnomalies LoginAnomalies `yaml:"login_anomalies" json:"login_anomalies" koanf:"login_anomalies"`
+	// GuestLoginNotifications configures the emails to the account holders about the logins of their guests
+	GuestLoginNotifications GuestLoginNotifications `yaml:"guest_login_notifications" json:"guest_login_notifications" koanf:"guest_login_notifications"`
 }
 
 func Load(cfgFile *string) (*Config, error) {
@@ -74,10 +86,19 @@ func DefaultConfig() *Config {
 			Smtp: SMTP{
 				Port: "465",
 			},
-			TTL: 300,
+			TTL:  300,
+			Mode: PasscodeModeCode,
+			Link: PasscodeLink{
+				Url:            "http://localhost:4200/#/login/link",
+				EmailChangeUrl: "http://localhost:4200/#/email/confirm",
+			},
 		},
 		Password: Password{
 			MinPasswordLength: 8,
+			Reset: PasswordReset{
+				TTL: 900,
+				Url: "http://localhost:4200/#/password/reset",
+			},
 		},
 		Database: Database{
 			Database: "hanko",
@@ -89,6 +110,114 @@ func DefaultConfig() *Config {
 				SameSite: "strict",
 				Secure:   true,
 			},
+			StepUp: StepUp{
+				MaxAge: "5m",
+			},
+		},
+		Account: Account{
+			Deletion: AccountDeletion{
+				GracePeriod: "720h",
+			},
+		},
+		Audit: Audit{
+			BufferDir:     "./audit_buffer",
+			BatchSize:     100,
+			FlushInterval: "5s",
+			RetryDelay:    "1s",
+			MaxRetryDelay: "5m",
+			Syslog: AuditSyslogSink{
+				Network:  "udp",
+				Facility: 10,
+				AppName:  "hanko",
+			},
+			Webhook: AuditWebhookSink{
+				Timeout: "10s",
+			},
+		},
+		Webhooks: Webhooks{
+			Timeout:       "10s",
+			MaxAttempts:   10,
+			RetryDelay:    "30s",
+			MaxRetryDelay: "6h",
+			Retention:     "720h",
+		},
+		LoginAnomalies: LoginAnomalies{
+			Enabled:              true,
+			History:              50,
+			IPv4PrefixLength:     24,
+			IPv6PrefixLength:     48,
+			MaxTravelSpeed:       1000,
+			UnusualHourMinLogins: 5,
+			UnusualHourTolerance: 2,
+			RevokeUrl:            "http://localhost:4200/#/login/revoke",
+			RevokeTTL:            604800,
+		},
+		GuestLoginNotifications: GuestLoginNotifications{
+			Mode:      GuestLoginNotificationsEach,
+			RevokeUrl: "http://localhost:4200/#/shares/revoke",
+			RevokeTTL: 604800,
+		},
+		SecondFactor: SecondFactor{
+			Mode:        SecondFactorOptional,
+			Lifespan:    "5m",
+			MaxAttempts: 5,
+		},
+		Hashing: Hashing{
+			Algorithm: HashingArgon2id,
+			Bcrypt: BcryptSettings{
+				Cost: 12,
+			},
+			Argon2id: Argon2idSettings{
+				Memory:      64 * 1024,
+				Iterations:  3,
+				Parallelism: 4,
+				SaltLength:  16,
+				KeyLength:   32,
+			},
+		},
+		RateLimit: RateLimit{
+			Enabled: true,
+			Store:   RateLimitStoreMemory,
+			PasswordLogin: RateLimitPolicy{
+				Attempts: 5,
+				Delay:    "1s",
+				MaxDelay: "15m",
+			},
+			PasscodeInit: RateLimitPolicy{
+				Attempts: 3,
+				Delay:    "30s",
+				MaxDelay: "15m",
+			},
+			PasscodeFinish: RateLimitPolicy{
+				Attempts: 5,
+				Delay:    "1s",
+				MaxDelay: "15m",
+			},
+			UserLookup: RateLimitPolicy{
+				Attempts: 10,
+				Delay:    "1s",
+				MaxDelay: "5m",
+			},
+			ShareInvitation: RateLimitPolicy{
+				Attempts: 5,
+				Delay:    "1m",
+				MaxDelay: "1h",
+			},
+			PasswordReset: RateLimitPolicy{
+				Attempts: 3,
+				Delay:    "30s",
+				MaxDelay: "15m",
+			},
+			RecoveryLogin: RateLimitPolicy{
+				Attempts: 5,
+				Delay:    "1s",
+				MaxDelay: "15m",
+			},
+			TotpLogin: RateLimitPolicy{
+				Attempts: 5,
+				Delay:    "30s",
+				MaxDelay: "15m",
+			},
 		},
 	}
 }
@@ -106,6 +235,10 @@ func (c *Config) Validate() error {
 	if err != nil {
 		return fmt.Errorf("failed to validate passcode settings: %w", err)
 	}
+	err = c.Password.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate password settings: %w", err)
+	}
 	err = c.Database.Validate()
 	if err != nil {
 		return fmt.Errorf("failed to validate database settings: %w", err)
@@ -122,6 +255,38 @@ func (c *Config) Validate() error {
 	if err != nil {
 		return fmt.Errorf("failed to validate session settings: %w", err)
 	}
+	err = c.SecondFactor.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate second factor settings: %w", err)
+	}
+	err = c.Hashing.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate hashing settings: %w", err)
+	}
+	err = c.RateLimit.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate rate limit settings: %w", err)
+	}
+	err = c.Account.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate account settings: %w", err)
+	}
+	err = c.Audit.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate audit settings: %w", err)
+	}
+	err = c.Webhooks.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate webhooks settings: %w", err)
+	}
+	err = c.LoginAnomalies.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate login anomalies settings: %w", err)
+	}
+	err = c.GuestLoginNotifications.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate guest login notifications settings: %w", err)
+	}
 	return nil
 }
 
@@ -155,8 +320,84 @@ func (s *Service) Validate() error {
 }
 
 type Password struct {
-	Enabled           bool `yaml:"enabled" json:"enabled" koanf:"enabled"`
-	MinPasswordLength int  `yaml:"min_password_length" json:"min_password_length" koanf:"min_password_length"`
+	Enabled           bool           `yaml:"enabled" json:"enabled" koanf:"enabled"`
+	MinPasswordLength int            `yaml:"min_password_length" json:"min_password_length" koanf:"min_password_length"`
+	Policy            PasswordPolicy `yaml:"policy" json:"policy" koanf:"policy"`
+	Reset             PasswordReset  `yaml:"reset" json:"-" koanf:"reset"`
+}
+
+func (p *Password) Validate() error {
+	err := p.Policy.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate policy settings: %w", err)
+	}
+	if p.Enabled {
+		err = p.Reset.Validate()
+		if err != nil {
+			return fmt.Errorf("failed to validate reset settings: %w", err)
+		}
+	}
+	return nil
+}
+
+// PasswordReset configures the password reset via a link sent by email
+type PasswordReset struct {
+	// TTL is how long the link is valid in seconds
+	TTL int `yaml:"ttl" json:"ttl" koanf:"ttl"`
+	// Url of the page in the frontend to choose a new password, the token is appended as query parameter "token"
+	Url string `yaml:"url" json:"url" koanf:"url"`
+}
+
+func (r *PasswordReset) Validate() error {
+	if r.TTL <= 0 {
+		return errors.New("ttl must be greater than 0")
+	}
+	if len(strings.TrimSpace(r.Url)) == 0 {
+		return errors.New("url must not be empty")
+	}
+	return nil
+}
+
+// PasswordPolicy defines the rules a new password must satisfy in addition to the minimum length
+type PasswordPolicy struct {
+	RequireLowercase bool `yaml:"require_lowercase" json:"require_lowercase" koanf:"require_lowercase"`
+	RequireUppercase bool `yaml:"require_uppercase" json:"require_uppercase" koanf:"require_uppercase"`
+	RequireDigit     bool `yaml:"require_digit" json:"require_digit" koanf:"require_digit"`
+	RequireSymbol    bool `yaml:"require_symbol" json:"require_symbol" koanf:"require_symbol"`
+	// MinStrength is the minimum estimated strength from 0 (too guessable) to 4 (very unguessable)
+	MinStrength       int               `yaml:"min_strength" json:"min_strength" koanf:"min_strength"`
+	BreachedPasswords BreachedPasswords `yaml:"breached_passwords" json:"breached_passwords" koanf:"breached_passwords"`
+}
+
+func (p *PasswordPolicy) Validate() error {
+	if p.MinStrength < 0 || p.MinStrength > 4 {
+		return errors.New("min_strength must be between 0 and 4")
+	}
+	err := p.BreachedPasswords.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate breached passwords settings: %w", err)
+	}
+	return nil
+}
+
+// BreachedPasswords configures the check against a local corpus of SHA-1 hashes of breached passwords
+type BreachedPasswords struct {
+	Enabled bool `yaml:"enabled" json:"enabled" koanf:"enabled"`
+	// File is the path to the directory of range files or the single hash file. It is not shared with the frontend.
+	File string `yaml:"file" json:"-" koanf:"file"`
+}
+
+func (b *BreachedPasswords) Validate() error {
+	if !b.Enabled {
+		return nil
+	}
+	if len(strings.TrimSpace(b.File)) == 0 {
+		return errors.New("file must not be empty")
+	}
+	if _, err := os.Stat(b.File); err != nil {
+		return fmt.Errorf("failed to access file: %w", err)
+	}
+	return nil
 }
 
 type Cookie struct {
@@ -171,6 +412,9 @@ type ServerSettings struct {
 	// See net.Dial for details of the address format.
 	Address string `yaml:"address" json:"address" koanf:"address"`
 	Cors    Cors   `yaml:"cors" json:"cors" koanf:"cors"`
+	// TrustedProxies are the IP ranges in CIDR notation of the reverse proxies in front of the server. The client IP
+	// is taken from the X-Forwarded-For header only if the request comes from one of them.
+	TrustedProxies []string `yaml:"trusted_proxies" json:"trusted_proxies" koanf:"trusted_proxies"`
 }
 
 type Cors struct {
@@ -187,6 +431,11 @@ func (s *ServerSettings) Validate() error {
 	if len(strings.TrimSpace(s.Address)) == 0 {
 		return errors.New("field Address must not be empty")
 	}
+	for _, proxy := range s.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil {
+			return fmt.Errorf("trusted proxy %s is not in CIDR notation: %w", proxy, err)
+		}
+	}
 	return nil
 }
 
@@ -240,9 +489,11 @@ func (e *Email) Validate() error {
 }
 
 type Passcode struct {
-	Email Email `yaml:"email" json:"email" koanf:"email"`
-	Smtp  SMTP  `yaml:"smtp" json:"smtp" koanf:"smtp"`
-	TTL   int   `yaml:"ttl" json:"ttl" koanf:"ttl"`
+	Email Email        `yaml:"email" json:"email" koanf:"email"`
+	Smtp  SMTP         `yaml:"smtp" json:"smtp" koanf:"smtp"`
+	TTL   int          `yaml:"ttl" json:"ttl" koanf:"ttl"`
+	Mode  string       `yaml:"mode" json:"mode" koanf:"mode"`
+	Link  PasscodeLink `yaml:"link" json:"link" koanf:"link"`
 }
 
 func (p *Passcode) Validate() error {
@@ -254,6 +505,42 @@ func (p *Passcode) Validate() error {
 	if err != nil {
 		return fmt.Errorf("failed to validate smtp settings: %w", err)
 	}
+	switch p.Mode {
+	case PasscodeModeCode:
+	case PasscodeModeLink:
+		err = p.Link.Validate()
+		if err != nil {
+			return fmt.Errorf("failed to validate link settings: %w", err)
+		}
+	default:
+		return fmt.Errorf("mode must be one of %s, %s", PasscodeModeCode, PasscodeModeLink)
+	}
+	return nil
+}
+
+const (
+	// PasscodeModeCode sends a six-digit passcode which is entered on the login screen
+	PasscodeModeCode = "code"
+	// PasscodeModeLink sends a magic link which only works in the browser that requested it
+	PasscodeModeLink = "link"
+)
+
+// PasscodeLink configures the magic link sent in passcode mode "link"
+type PasscodeLink struct {
+	// Url of the page in the frontend which finishes the login, the query parameters "id" and "token" are appended
+	Url string `yaml:"url" json:"url" koanf:"url"`
+	// EmailChangeUrl of the page in the frontend which confirms a new email address, the query parameters "id" and
+	// "token" are appended
+	EmailChangeUrl string `yaml:"email_change_url" json:"email_change_url" koanf:"email_change_url"`
+}
+
+func (l *PasscodeLink) Validate() error {
+	if len(strings.TrimSpace(l.Url)) == 0 {
+		return errors.New("url must not be empty")
+	}
+	if len(strings.TrimSpace(l.EmailChangeUrl)) == 0 {
+		return errors.New("email_change_url must not be empty")
+	}
 	return nil
 }
 
@@ -311,6 +598,7 @@ type Session struct {
 	EnableAuthTokenHeader bool   `yaml:"enable_auth_token_header" json:"enable_auth_token_header" koanf:"enable_auth_token_header"`
 	Lifespan              string `yaml:"lifespan" json:"lifespan" koanf:"lifespan"`
 	Cookie                Cookie `yaml:"cookie" json:"cookie" koanf:"cookie"`
+	StepUp                StepUp `yaml:"step_up" json:"step_up" koanf:"step_up"`
 }
 
 func (s *Session) Validate() error {
@@ -318,5 +606,501 @@ func (s *Session) Validate() error {
 	if err != nil {
 		return errors.New("failed to parse lifespan")
 	}
+	err = s.StepUp.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate step up settings: %w", err)
+	}
+	return nil
+}
+
+// StepUp configures the re-authentication required before sensitive operations
+type StepUp struct {
+	// MaxAge is how long a step-up authentication is accepted by sensitive routes
+	MaxAge string `yaml:"max_age" json:"max_age" koanf:"max_age"`
+}
+
+func (s *StepUp) Validate() error {
+	_, err := time.ParseDuration(s.MaxAge)
+	if err != nil {
+		return errors.New("failed to parse max_age")
+	}
+	return nil
+}
+
+// Account configures the self-service management of the account
+type Account struct {
+	Deletion AccountDeletion `yaml:"deletion" json:"deletion" koanf:"deletion"`
+}
+
+func (a *Account) Validate() error {
+	err := a.Deletion.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate deletion settings: %w", err)
+	}
+	return nil
+}
+
+// AccountDeletion configures the deletion of an account requested by the user
+type AccountDeletion struct {
+	// GracePeriod is how long after the request the account is deleted, until then the user can cancel the deletion
+	GracePeriod string `yaml:"grace_period" json:"grace_period" koanf:"grace_period"`
+}
+
+func (d *AccountDeletion) Validate() error {
+	_, err := time.ParseDuration(d.GracePeriod)
+	if err != nil {
+		return errors.New("failed to parse grace_period")
+	}
+	return nil
+}
+
+// Audit configures the streaming of the login audit and the security events to external sinks. The records are
+// buffered on disk until the sinks accepted them.
+type Audit struct {
+	// BufferDir is the directory the records are buffered in, every sink has its own subdirectory
+	BufferDir string `yaml:"buffer_dir" json:"buffer_dir" koanf:"buffer_dir"`
+	// BatchSize is the maximum number of records delivered at once
+	BatchSize int `yaml:"batch_size" json:"batch_size" koanf:"batch_size"`
+	// FlushInterval is how often the buffered records are delivered
+	FlushInterval string `yaml:"flush_interval" json:"flush_interval" koanf:"flush_interval"`
+	// RetryDelay is the delay after a failed delivery, it doubles with every further failure up to MaxRetryDelay
+	RetryDelay    string           `yaml:"retry_delay" json:"retry_delay" koanf:"retry_delay"`
+	MaxRetryDelay string           `yaml:"max_retry_delay" json:"max_retry_delay" koanf:"max_retry_delay"`
+	File          AuditFileSink    `yaml:"file" json:"file" koanf:"file"`
+	Syslog        AuditSyslogSink  `yaml:"syslog" json:"syslog" koanf:"syslog"`
+	Webhook       AuditWebhookSink `yaml:"webhook" json:"webhook" koanf:"webhook"`
+}
+
+// Enabled returns whether at least one sink is enabled
+func (a *Audit) Enabled() bool {
+	return a.File.Enabled || a.Syslog.Enabled || a.Webhook.Enabled
+}
+
+func (a *Audit) Validate() error {
+	if !a.Enabled() {
+		return nil
+	}
+	if len(strings.TrimSpace(a.BufferDir)) == 0 {
+		return errors.New("buffer_dir must not be empty")
+	}
+	if a.BatchSize <= 0 {
+		return errors.New("batch_size must be greater than 0")
+	}
+	durations := map[string]string{
+		"flush_interval":  a.FlushInterval,
+		"retry_delay":     a.RetryDelay,
+		"max_retry_delay": a.MaxRetryDelay,
+	}
+	for name, value := range durations {
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("failed to parse %s", name)
+		}
+	}
+	err := a.File.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate file sink: %w", err)
+	}
+	err = a.Syslog.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate syslog sink: %w", err)
+	}
+	err = a.Webhook.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate webhook sink: %w", err)
+	}
+	return nil
+}
+
+// AuditFileSink appends the records as JSON lines to a file
+type AuditFileSink struct {
+	Enabled bool   `yaml:"enabled" json:"enabled" koanf:"enabled"`
+	Path    string `yaml:"path" json:"path" koanf:"path"`
+}
+
+func (s *AuditFileSink) Validate() error {
+	if s.Enabled && len(strings.TrimSpace(s.Path)) == 0 {
+		return errors.New("path must not be empty")
+	}
+	return nil
+}
+
+const (
+	AuditSyslogNetworkUDP = "udp"
+	AuditSyslogNetworkTCP = "tcp"
+	AuditSyslogNetworkTLS = "tls"
+)
+
+// AuditSyslogSink sends the records as RFC 5424 syslog messages
+type AuditSyslogSink struct {
+	Enabled bool `yaml:"enabled" json:"enabled" koanf:"enabled"`
+	// Network is one of udp, tcp and tls. Messages sent over tcp and tls are framed by octet counting (RFC 6587).
+	Network string `yaml:"network" json:"network" koanf:"network"`
+	Address string `yaml:"address" json:"address" koanf:"address"`
+	// Facility is the syslog facility code, e.g. 10 for authpriv
+	Facility int    `yaml:"facility" json:"facility" koanf:"facility"`
+	AppName  string `yaml:"app_name" json:"app_name" koanf:"app_name"`
+	// Hostname defaults to the hostname of the machine
+	Hostname string `yaml:"hostname" json:"hostname" koanf:"hostname"`
+}
+
+func (s *AuditSyslogSink) Validate() error {
+	if !s.Enabled {
+		return nil
+	}
+	switch s.Network {
+	case AuditSyslogNetworkUDP, AuditSyslogNetworkTCP, AuditSyslogNetworkTLS:
+	default:
+		return fmt.Errorf("network must be one of %s, %s, %s", AuditSyslogNetworkUDP, AuditSyslogNetworkTCP, AuditSyslogNetworkTLS)
+	}
+	if len(strings.TrimSpace(s.Address)) == 0 {
+		return errors.New("address must not be empty")
+	}
+	if s.Facility < 0 || s.Facility > 23 {
+		return errors.New("facility must be between 0 and 23")
+	}
+	return nil
+}
+
+// AuditWebhookSink posts the records as JSON array to an HTTPS endpoint
+type AuditWebhookSink struct {
+	Enabled bool   `yaml:"enabled" json:"enabled" koanf:"enabled"`
+	Url     string `yaml:"url" json:"url" koanf:"url"`
+	// Secret is used to sign the body with HMAC-SHA256, the signature is sent in the X-Hanko-Signature header
+	Secret  string            `yaml:"secret" json:"-" koanf:"secret"`
+	Headers map[string]string `yaml:"headers" json:"headers" koanf:"headers"`
+	Timeout string            `yaml:"timeout" json:"timeout" koanf:"timeout"`
+}
+
+func (s *AuditWebhookSink) Validate() error {
+	if !s.Enabled {
+		return nil
+	}
+	if !strings.HasPrefix(s.Url, "https://") {
+		return errors.New("url must be an https url")
+	}
+	_, err := time.ParseDuration(s.Timeout)
+	if err != nil {
+		return errors.New("failed to parse timeout")
+	}
+	return nil
+}
+
+// Webhooks configures the delivery of the account and sharing lifecycle events to the registered webhook endpoints
+type Webhooks struct {
+	// Timeout is how long a delivery may take until it counts as failed
+	Timeout string `yaml:"timeout" json:"timeout" koanf:"timeout"`
+	// MaxAttempts is how often a delivery is attempted before it is given up, it can still be replayed afterwards
+	MaxAttempts int `yaml:"max_attempts" json:"max_attempts" koanf:"max_attempts"`
+	// RetryDelay is the delay after a failed attempt, it doubles with every further attempt up to MaxRetryDelay
+	RetryDelay    string `yaml:"retry_delay" json:"retry_delay" koanf:"retry_delay"`
+	MaxRetryDelay string `yaml:"max_retry_delay" json:"max_retry_delay" koanf:"max_retry_delay"`
+	// Retention is how long events and their deliveries are kept
+	Retention string `yaml:"retention" json:"retention" koanf:"retention"`
+}
+
+func (w *Webhooks) Validate() error {
+	if w.MaxAttempts <= 0 {
+		return errors.New("max_attempts must be greater than 0")
+	}
+	durations := map[string]string{
+		"timeout":         w.Timeout,
+		"retry_delay":     w.RetryDelay,
+		"max_retry_delay": w.MaxRetryDelay,
+		"retention":       w.Retention,
+	}
+	for name, value := range durations {
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("failed to parse %s", name)
+		}
+	}
+	return nil
+}
+
+// LoginAnomalies configures the detection of unusual logins. Every login is compared with the previous logins of the
+// account holder, every guest login with the previous logins of the guest to the account. The account holder is sent
+// an email with a link to revoke the sessions or the guest relation, if a login is unusual.
+type LoginAnomalies struct {
+	Enabled bool `yaml:"enabled" json:"enabled" koanf:"enabled"`
+	// History is how many of the previous logins a login is compared with
+	History int `yaml:"history" json:"history" koanf:"history"`
+	// IPv4PrefixLength and IPv6PrefixLength are the sizes of the networks, a login from an address outside the
+	// networks of the previous logins is unusual
+	IPv4PrefixLength int `yaml:"ipv4_prefix_length" json:"ipv4_prefix_length" koanf:"ipv4_prefix_length"`
+	IPv6PrefixLength int `yaml:"ipv6_prefix_length" json:"ipv6_prefix_length" koanf:"ipv6_prefix_length"`
+	// GeoIPDatabases are CSV files with the columns "network", "latitude" and "longitude", e.g. the GeoLite2 City
+	// blocks. Without a database impossible travel is not detected.
+	GeoIPDatabases []string `yaml:"geoip_databases" json:"geoip_databases" koanf:"geoip_databases"`
+	// MaxTravelSpeed in km/h, a login from a location which can't be reached from the location of the previous login
+	// at this speed is unusual
+	MaxTravelSpeed int `yaml:"max_travel_speed" json:"max_travel_speed" koanf:"max_travel_speed"`
+	// UnusualHourMinLogins is how many previous logins a guest needs, before a login at an unusual hour is detected.
+	// A guest login is unusual, if no previous login of the guest was within UnusualHourTolerance hours of the day.
+	UnusualHourMinLogins int `yaml:"unusual_hour_min_logins" json:"unusual_hour_min_logins" koanf:"unusual_hour_min_logins"`
+	UnusualHourTolerance int `yaml:"unusual_hour_tolerance" json:"unusual_hour_tolerance" koanf:"unusual_hour_tolerance"`
+	// RevokeUrl of the page in the frontend which revokes the sessions or the guest relation of an unusual login, the
+	// token is appended as query parameter "token"
+	RevokeUrl string `yaml:"revoke_url" json:"revoke_url" koanf:"revoke_url"`
+	// RevokeTTL is how long the link is valid in seconds
+	RevokeTTL int `yaml:"revoke_ttl" json:"revoke_ttl" koanf:"revoke_ttl"`
+}
+
+func (l *LoginAnomalies) Validate() error {
+	if !l.Enabled {
+		return nil
+	}
+	if l.History <= 0 {
+		return errors.New("history must be greater than 0")
+	}
+	if l.IPv4PrefixLength < 1 || l.IPv4PrefixLength > 32 {
+		return errors.New("ipv4_prefix_length must be between 1 and 32")
+	}
+	if l.IPv6PrefixLength < 1 || l.IPv6PrefixLength > 128 {
+		return errors.New("ipv6_prefix_length must be between 1 and 128")
+	}
+	if l.MaxTravelSpeed <= 0 {
+		return errors.New("max_travel_speed must be greater than 0")
+	}
+	if l.UnusualHourMinLogins <= 0 {
+		return errors.New("unusual_hour_min_logins must be greater than 0")
+	}
+	if l.UnusualHourTolerance < 0 || l.UnusualHourTolerance >= 12 {
+		return errors.New("unusual_hour_tolerance must be between 0 and 11")
+	}
+	if l.RevokeUrl == "" {
+		return errors.New("revoke_url must not be empty")
+	}
+	if l.RevokeTTL <= 0 {
+		return errors.New("revoke_ttl must be greater than 0")
+	}
+	return nil
+}
+
+const (
+	// GuestLoginNotificationsEach notifies the account holder about every login of a guest
+	GuestLoginNotificationsEach = "each"
+	// GuestLoginNotificationsFirst notifies the account holder about the first login of a guest per relation
+	GuestLoginNotificationsFirst = "first"
+	// GuestLoginNotificationsDigest sends the account holder one email with the logins of the guests per day
+	GuestLoginNotificationsDigest = "digest"
+	GuestLoginNotificationsOff    = "off"
+)
+
+// GuestLoginNotificationModes are the modes an account holder can choose from
+var GuestLoginNotificationModes = []string{
+	GuestLoginNotificationsEach,
+	GuestLoginNotificationsFirst,
+	GuestLoginNotificationsDigest,
+	GuestLoginNotificationsOff,
+}
+
+// GuestLoginNotifications configures the emails to the account holders about the logins of their guests. Every
+// account holder can choose another mode, Mode applies to the account holders who did not.
+type GuestLoginNotifications struct {
+	Mode string `yaml:"mode" json:"mode" koanf:"mode"`
+	// RevokeUrl of the page in the frontend which revokes the guest relation, the token is appended as query parameter
+	// "token"
+	RevokeUrl string `yaml:"revoke_url" json:"revoke_url" koanf:"revoke_url"`
+	// RevokeTTL is how long the link is valid in seconds
+	RevokeTTL int `yaml:"revoke_ttl" json:"revoke_ttl" koanf:"revoke_ttl"`
+}
+
+func (g *GuestLoginNotifications) Validate() error {
+	valid := false
+	for _, mode := range GuestLoginNotificationModes {
+		if g.Mode == mode {
+			valid = true
+		}
+	}
+	if !valid {
+		return fmt.Errorf("mode must be one of %s", strings.Join(GuestLoginNotificationModes, ", "))
+	}
+	if g.RevokeUrl == "" {
+		return errors.New("revoke_url must not be empty")
+	}
+	if g.RevokeTTL <= 0 {
+		return errors.New("revoke_ttl must be greater than 0")
+	}
+	return nil
+}
+
+const (
+	// SecondFactorOptional requires a second factor only from users who enrolled one
+	SecondFactorOptional = "optional"
+	// SecondFactorMandatory requires a second factor from every user after password or passcode login
+	SecondFactorMandatory = "mandatory"
+)
+
+// SecondFactor configures the TOTP second factor after password or passcode login
+type SecondFactor struct {
+	Mode string `yaml:"mode" json:"mode" koanf:"mode"`
+	// Lifespan is how long the restricted session waiting for the second factor is valid
+	Lifespan string `yaml:"lifespan" json:"lifespan" koanf:"lifespan"`
+	// MaxAttempts is the number of invalid codes after which the restricted session is rejected
+	MaxAttempts int `yaml:"max_attempts" json:"max_attempts" koanf:"max_attempts"`
+}
+
+func (s *SecondFactor) Validate() error {
+	switch s.Mode {
+	case SecondFactorOptional, SecondFactorMandatory:
+	default:
+		return fmt.Errorf("mode must be one of %s, %s", SecondFactorOptional, SecondFactorMandatory)
+	}
+	lifespan, err := time.ParseDuration(s.Lifespan)
+	if err != nil {
+		return errors.New("failed to parse lifespan")
+	}
+	if lifespan <= 0 {
+		return errors.New("lifespan must be positive")
+	}
+	if s.MaxAttempts < 1 {
+		return errors.New("max_attempts must be at least 1")
+	}
+	return nil
+}
+
+const (
+	HashingArgon2id = "argon2id"
+	HashingBcrypt   = "bcrypt"
+)
+
+// Hashing configures how passwords are hashed. Hashes created with another algorithm or other parameters remain
+// valid, passwords are re-hashed on the next successful login. Secrets generated by the server are hashed with
+// HMAC-SHA256 instead, see crypto.NewTokenHasher.
+type Hashing struct {
+	Algorithm string           `yaml:"algorithm" json:"algorithm" koanf:"algorithm"`
+	Bcrypt    BcryptSettings   `yaml:"bcrypt" json:"bcrypt" koanf:"bcrypt"`
+	Argon2id  Argon2idSettings `yaml:"argon2id" json:"argon2id" koanf:"argon2id"`
+}
+
+func (h *Hashing) Validate() error {
+	switch h.Algorithm {
+	case HashingArgon2id, HashingBcrypt:
+	default:
+		return fmt.Errorf("algorithm must be one of %s, %s", HashingArgon2id, HashingBcrypt)
+	}
+	err := h.Bcrypt.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate bcrypt settings: %w", err)
+	}
+	err = h.Argon2id.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate argon2id settings: %w", err)
+	}
+	return nil
+}
+
+type BcryptSettings struct {
+	Cost int `yaml:"cost" json:"cost" koanf:"cost"`
+}
+
+func (b *BcryptSettings) Validate() error {
+	if b.Cost < 10 || b.Cost > 31 {
+		return errors.New("cost must be between 10 and 31")
+	}
+	return nil
+}
+
+type Argon2idSettings struct {
+	// Memory in KiB
+	Memory      uint32 `yaml:"memory" json:"memory" koanf:"memory"`
+	Iterations  uint32 `yaml:"iterations" json:"iterations" koanf:"iterations"`
+	Parallelism uint8  `yaml:"parallelism" json:"parallelism" koanf:"parallelism"`
+	SaltLength  uint32 `yaml:"salt_length" json:"salt_length" koanf:"salt_length"`
+	KeyLength   uint32 `yaml:"key_length" json:"key_length" koanf:"key_length"`
+}
+
+func (a *Argon2idSettings) Validate() error {
+	if a.Iterations < 1 {
+		return errors.New("iterations must be at least 1")
+	}
+	if a.Parallelism < 1 {
+		return errors.New("parallelism must be at least 1")
+	}
+	if a.Memory < 8*uint32(a.Parallelism) {
+		return errors.New("memory must be at least 8 KiB per degree of parallelism")
+	}
+	if a.SaltLength < 16 {
+		return errors.New("salt_length must be at least 16")
+	}
+	if a.KeyLength < 16 {
+		return errors.New("key_length must be at least 16")
+	}
+	return nil
+}
+
+const (
+	// RateLimitStoreMemory keeps the attempts in memory of the instance
+	RateLimitStoreMemory = "memory"
+	// RateLimitStoreDatabase keeps the attempts in the database, so they are shared by multiple instances
+	RateLimitStoreDatabase = "database"
+)
+
+// RateLimit configures the exponential backoff for brute-force prone endpoints
+type RateLimit struct {
+	Enabled         bool            `yaml:"enabled" json:"enabled" koanf:"enabled"`
+	Store           string          `yaml:"store" json:"store" koanf:"store"`
+	PasswordLogin   RateLimitPolicy `yaml:"password_login" json:"password_login" koanf:"password_login"`
+	PasscodeInit    RateLimitPolicy `yaml:"passcode_init" json:"passcode_init" koanf:"passcode_init"`
+	PasscodeFinish  RateLimitPolicy `yaml:"passcode_finish" json:"passcode_finish" koanf:"passcode_finish"`
+	UserLookup      RateLimitPolicy `yaml:"user_lookup" json:"user_lookup" koanf:"user_lookup"`
+	ShareInvitation RateLimitPolicy `yaml:"share_invitation" json:"share_invitation" koanf:"share_invitation"`
+	PasswordReset   RateLimitPolicy `yaml:"password_reset" json:"password_reset" koanf:"password_reset"`
+	RecoveryLogin   RateLimitPolicy `yaml:"recovery_login" json:"recovery_login" koanf:"recovery_login"`
+	TotpLogin       RateLimitPolicy `yaml:"totp_login" json:"totp_login" koanf:"totp_login"`
+}
+
+func (r *RateLimit) Validate() error {
+	if !r.Enabled {
+		return nil
+	}
+	switch r.Store {
+	case RateLimitStoreMemory, RateLimitStoreDatabase:
+	default:
+		return fmt.Errorf("store must be one of %s, %s", RateLimitStoreMemory, RateLimitStoreDatabase)
+	}
+	policies := map[string]RateLimitPolicy{
+		"password_login":   r.PasswordLogin,
+		"passcode_init":    r.PasscodeInit,
+		"passcode_finish":  r.PasscodeFinish,
+		"user_lookup":      r.UserLookup,
+		"share_invitation": r.ShareInvitation,
+		"password_reset":   r.PasswordReset,
+		"recovery_login":   r.RecoveryLogin,
+		"totp_login":       r.TotpLogin,
+	}
+	for name, policy := range policies {
+		err := policy.Validate()
+		if err != nil {
+			return fmt.Errorf("failed to validate %s policy: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// RateLimitPolicy allows a number of attempts, after that each further attempt doubles the delay until the next attempt
+// is allowed, starting with Delay and up to MaxDelay. The attempts are forgotten after MaxDelay without attempts.
+type RateLimitPolicy struct {
+	Attempts int    `yaml:"attempts" json:"attempts" koanf:"attempts"`
+	Delay    string `yaml:"delay" json:"delay" koanf:"delay"`
+	MaxDelay string `yaml:"max_delay" json:"max_delay" koanf:"max_delay"`
+}
+
+func (r *RateLimitPolicy) Validate() error {
+	if r.Attempts < 1 {
+		return errors.New("attempts must be at least 1")
+	}
+	delay, err := time.ParseDuration(r.Delay)
+	if err != nil {
+		return errors.New("failed to parse delay")
+	}
+	maxDelay, err := time.ParseDuration(r.MaxDelay)
+	if err != nil {
+		return errors.New("failed to parse max_delay")
+	}
+	if delay <= 0 || maxDelay < delay {
+		return errors.New("delay must be positive and must not exceed max_delay")
+	}
 	return nil
 }
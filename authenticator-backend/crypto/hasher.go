@@ -0,0 +1,349 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/teamhanko/hanko/backend/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultBcryptCost is used when no bcrypt cost is configured
+const DefaultBcryptCost = 12
+
+// bcryptMaxLength is the maximum length of a plaintext in bytes, because bcrypt ignores everything after 72 bytes
+const bcryptMaxLength = 72
+
+// argon2idMaxLength bounds the length of a plaintext, argon2id itself accepts plaintexts of any length
+const argon2idMaxLength = 1024
+
+const argon2idPrefix = "$argon2id$"
+
+const hmacSha256Prefix = "$hmac-sha256$"
+
+// Hasher hashes secrets like passwords, passcodes and access tokens. The hashes are self-describing, i.e. they carry
+// the algorithm and its parameters, so secrets can still be verified after the configuration changed.
+type Hasher interface {
+	Hash(plaintext string) (string, error)
+	// Verify returns whether the plaintext matches the hash
+	Verify(hash string, plaintext string) (bool, error)
+	// NeedsRehash returns whether the hash was created with another algorithm or other parameters than the
+	// configured ones
+	NeedsRehash(hash string) bool
+	// MaxLength returns the maximum length of a plaintext in bytes
+	MaxLength() int
+}
+
+// NewHasher returns a hasher which creates new hashes with the configured algorithm and verifies hashes of all
+// supported algorithms. An unset algorithm falls back to bcrypt.
+func NewHasher(cfg config.Hashing) Hasher {
+	defaults := DefaultArgon2idParams()
+	params := Argon2idParams{
+		Memory:      cfg.Argon2id.Memory,
+		Iterations:  cfg.Argon2id.Iterations,
+		Parallelism: cfg.Argon2id.Parallelism,
+		SaltLength:  cfg.Argon2id.SaltLength,
+		KeyLength:   cfg.Argon2id.KeyLength,
+	}
+	if params.Memory == 0 {
+		params.Memory = defaults.Memory
+	}
+	if params.Iterations == 0 {
+		params.Iterations = defaults.Iterations
+	}
+	if params.Parallelism == 0 {
+		params.Parallelism = defaults.Parallelism
+	}
+	if params.SaltLength == 0 {
+		params.SaltLength = defaults.SaltLength
+	}
+	if params.KeyLength == 0 {
+		params.KeyLength = defaults.KeyLength
+	}
+
+	h := &hasher{
+		bcrypt:   NewBcryptHasher(cfg.Bcrypt.Cost),
+		argon2id: NewArgon2idHasher(params),
+	}
+	if cfg.Algorithm == config.HashingArgon2id {
+		h.preferred = h.argon2id
+	} else {
+		h.preferred = h.bcrypt
+	}
+	return h
+}
+
+type hasher struct {
+	preferred Hasher
+	bcrypt    Hasher
+	argon2id  Hasher
+}
+
+func (h *hasher) Hash(plaintext string) (string, error) {
+	return h.preferred.Hash(plaintext)
+}
+
+func (h *hasher) Verify(hash string, plaintext string) (bool, error) {
+	return h.hasherFor(hash).Verify(hash, plaintext)
+}
+
+func (h *hasher) NeedsRehash(hash string) bool {
+	hasher := h.hasherFor(hash)
+	return hasher != h.preferred || hasher.NeedsRehash(hash)
+}
+
+func (h *hasher) MaxLength() int {
+	return h.preferred.MaxLength()
+}
+
+func (h *hasher) hasherFor(hash string) Hasher {
+	if strings.HasPrefix(hash, argon2idPrefix) {
+		return h.argon2id
+	}
+	return h.bcrypt
+}
+
+type tokenHasher struct {
+	keys     [][]byte
+	fallback Hasher
+}
+
+// NewTokenHasher returns a hasher for secrets generated by the server, e.g. passcodes, recovery codes and access
+// tokens. These are verified without authentication, so a memory-hard hash would let every request allocate the
+// memory of a password hash. Instead, they are hashed with HMAC-SHA256 keyed with the first of the secret keys, so a
+// leaked hash can not be brute forced without the key, even for short passcodes. Hashes are verified with all keys to
+// allow key rotation. Hashes created by the password hasher before remain valid. Without secret keys the password
+// hasher is returned, an unkeyed MAC of a short passcode would be brute forced in no time.
+func NewTokenHasher(keys []string, fallback config.Hashing) Hasher {
+	if len(keys) == 0 {
+		return NewHasher(fallback)
+	}
+
+	h := &tokenHasher{fallback: NewHasher(fallback)}
+	for _, key := range keys {
+		h.keys = append(h.keys, []byte(key))
+	}
+	return h
+}
+
+func (h *tokenHasher) Hash(plaintext string) (string, error) {
+	if len(plaintext) > argon2idMaxLength {
+		return "", fmt.Errorf("plaintext must not be longer than %d bytes", argon2idMaxLength)
+	}
+	return hmacSha256Prefix + base64.RawStdEncoding.EncodeToString(h.mac(h.keys[0], plaintext)), nil
+}
+
+func (h *tokenHasher) Verify(hash string, plaintext string) (bool, error) {
+	if !strings.HasPrefix(hash, hmacSha256Prefix) {
+		return h.fallback.Verify(hash, plaintext)
+	}
+	if len(plaintext) > argon2idMaxLength {
+		return false, nil
+	}
+
+	mac, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(hash, hmacSha256Prefix))
+	if err != nil {
+		return false, fmt.Errorf("failed to decode hmac-sha256 hash: %w", err)
+	}
+
+	for _, key := range h.keys {
+		if hmac.Equal(mac, h.mac(key, plaintext)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (h *tokenHasher) NeedsRehash(hash string) bool {
+	return !strings.HasPrefix(hash, hmacSha256Prefix)
+}
+
+func (h *tokenHasher) MaxLength() int {
+	return argon2idMaxLength
+}
+
+func (h *tokenHasher) mac(key []byte, plaintext string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(plaintext))
+	return mac.Sum(nil)
+}
+
+// IsSupportedHash returns whether the hash can be verified by a hasher, e.g. for hashes imported from other systems
+func IsSupportedHash(hash string) bool {
+	if strings.HasPrefix(hash, argon2idPrefix) {
+		_, _, _, err := decodeArgon2idHash(hash)
+		return err == nil
+	}
+	_, err := bcrypt.Cost([]byte(hash))
+	return err == nil
+}
+
+type bcryptHasher struct {
+	cost int
+}
+
+// NewBcryptHasher returns a hasher using bcrypt with the given cost. A cost of 0 falls back to DefaultBcryptCost.
+func NewBcryptHasher(cost int) Hasher {
+	if cost == 0 {
+		cost = DefaultBcryptCost
+	}
+	return &bcryptHasher{cost: cost}
+}
+
+func (h *bcryptHasher) Hash(plaintext string) (string, error) {
+	if len(plaintext) > bcryptMaxLength {
+		return "", fmt.Errorf("plaintext must not be longer than %d bytes", bcryptMaxLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(plaintext), h.cost)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate bcrypt hash: %w", err)
+	}
+	return string(hash), nil
+}
+
+func (h *bcryptHasher) Verify(hash string, plaintext string) (bool, error) {
+	// bcrypt would only compare the first 72 bytes, so longer plaintexts can never be the one which was hashed
+	if len(plaintext) > bcryptMaxLength {
+		return false, nil
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(plaintext))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to compare bcrypt hash: %w", err)
+	}
+	return true, nil
+}
+
+func (h *bcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cost
+}
+
+func (h *bcryptHasher) MaxLength() int {
+	return bcryptMaxLength
+}
+
+// Argon2idParams are the parameters of argon2id. The memory is given in KiB.
+type Argon2idParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idParams returns the second recommended option of RFC 9106 with 64 MiB of memory
+func DefaultArgon2idParams() Argon2idParams {
+	return Argon2idParams{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 4,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+type argon2idHasher struct {
+	params Argon2idParams
+}
+
+// NewArgon2idHasher returns a hasher using argon2id. Hashes are encoded in the PHC string format, e.g.
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>
+func NewArgon2idHasher(params Argon2idParams) Hasher {
+	return &argon2idHasher{params: params}
+}
+
+func (h *argon2idHasher) Hash(plaintext string) (string, error) {
+	if len(plaintext) > argon2idMaxLength {
+		return "", fmt.Errorf("plaintext must not be longer than %d bytes", argon2idMaxLength)
+	}
+
+	salt := make([]byte, h.params.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(plaintext), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		h.params.Memory,
+		h.params.Iterations,
+		h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *argon2idHasher) Verify(hash string, plaintext string) (bool, error) {
+	if len(plaintext) > argon2idMaxLength {
+		return false, nil
+	}
+
+	params, salt, key, err := decodeArgon2idHash(hash)
+	if err != nil {
+		return false, err
+	}
+
+	otherKey := argon2.IDKey([]byte(plaintext), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+}
+
+func (h *argon2idHasher) NeedsRehash(hash string) bool {
+	params, _, _, err := decodeArgon2idHash(hash)
+	return err != nil || *params != h.params
+}
+
+func (h *argon2idHasher) MaxLength() int {
+	return argon2idMaxLength
+}
+
+func decodeArgon2idHash(hash string) (*Argon2idParams, []byte, []byte, error) {
+	// "$argon2id$v=19$m=65536,t=3,p=4$salt$key" splits into "", "argon2id", "v=19", "m=65536,t=3,p=4", "salt", "key"
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, errors.New("hash is not an argon2id hash")
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to parse argon2id version: %w", err)
+	}
+	if version != argon2.Version {
+		return nil, nil, nil, fmt.Errorf("unsupported argon2id version: %d", version)
+	}
+
+	params := &Argon2idParams{}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to parse argon2id parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decode argon2id salt: %w", err)
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decode argon2id key: %w", err)
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
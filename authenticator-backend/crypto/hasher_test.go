@@ -0,0 +1,211 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/config"
+)
+
+var testArgon2idSettings = config.Argon2idSettings{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestArgon2idHasher(t *testing.T) {
+	hasher := NewHasher(config.Hashing{Algorithm: config.HashingArgon2id, Argon2id: testArgon2idSettings})
+
+	hash, err := hasher.Hash("verybadpassword")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	valid, err := hasher.Verify(hash, "verybadpassword")
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	valid, err = hasher.Verify(hash, "wrongpassword")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+
+	assert.False(t, hasher.NeedsRehash(hash))
+}
+
+func TestArgon2idHasher_AcceptsPasswordsLongerThan72Bytes(t *testing.T) {
+	hasher := NewHasher(config.Hashing{Algorithm: config.HashingArgon2id, Argon2id: testArgon2idSettings})
+	password := strings.Repeat("a", 100)
+
+	hash, err := hasher.Hash(password)
+	require.NoError(t, err)
+
+	valid, err := hasher.Verify(hash, password)
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	valid, err = hasher.Verify(hash, password[:72])
+	assert.NoError(t, err)
+	assert.False(t, valid)
+}
+
+func TestArgon2idHasher_Verify_Errors_WhenHashIsMalformed(t *testing.T) {
+	hasher := NewHasher(config.Hashing{Algorithm: config.HashingArgon2id, Argon2id: testArgon2idSettings})
+
+	_, err := hasher.Verify("$argon2id$v=19$m=1024$salt$key", "verybadpassword")
+	assert.Error(t, err)
+}
+
+func TestBcryptHasher(t *testing.T) {
+	hasher := NewHasher(config.Hashing{Algorithm: config.HashingBcrypt, Bcrypt: config.BcryptSettings{Cost: 10}})
+
+	hash, err := hasher.Hash("verybadpassword")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$2a$10$"))
+
+	valid, err := hasher.Verify(hash, "verybadpassword")
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	valid, err = hasher.Verify(hash, "wrongpassword")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+
+	assert.False(t, hasher.NeedsRehash(hash))
+	assert.Equal(t, 72, hasher.MaxLength())
+
+	_, err = hasher.Hash(strings.Repeat("a", 73))
+	assert.Error(t, err)
+}
+
+func TestHasher_NeedsRehash(t *testing.T) {
+	bcryptHash, err := NewBcryptHasher(10).Hash("verybadpassword")
+	require.NoError(t, err)
+	argon2idHash, err := NewHasher(config.Hashing{Algorithm: config.HashingArgon2id, Argon2id: testArgon2idSettings}).Hash("verybadpassword")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		cfg   config.Hashing
+		hash  string
+		wants bool
+	}{
+		{
+			name:  "bcrypt hash with argon2id configured",
+			cfg:   config.Hashing{Algorithm: config.HashingArgon2id, Argon2id: testArgon2idSettings},
+			hash:  bcryptHash,
+			wants: true,
+		},
+		{
+			name:  "bcrypt hash with outdated cost",
+			cfg:   config.Hashing{Algorithm: config.HashingBcrypt, Bcrypt: config.BcryptSettings{Cost: 11}},
+			hash:  bcryptHash,
+			wants: true,
+		},
+		{
+			name:  "argon2id hash with bcrypt configured",
+			cfg:   config.Hashing{Algorithm: config.HashingBcrypt, Bcrypt: config.BcryptSettings{Cost: 10}},
+			hash:  argon2idHash,
+			wants: true,
+		},
+		{
+			name: "argon2id hash with outdated parameters",
+			cfg: config.Hashing{Algorithm: config.HashingArgon2id, Argon2id: config.Argon2idSettings{
+				Memory:      2048,
+				Iterations:  1,
+				Parallelism: 1,
+				SaltLength:  16,
+				KeyLength:   32,
+			}},
+			hash:  argon2idHash,
+			wants: true,
+		},
+		{
+			name:  "argon2id hash with current parameters",
+			cfg:   config.Hashing{Algorithm: config.HashingArgon2id, Argon2id: testArgon2idSettings},
+			hash:  argon2idHash,
+			wants: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hasher := NewHasher(test.cfg)
+			assert.Equal(t, test.wants, hasher.NeedsRehash(test.hash))
+
+			valid, err := hasher.Verify(test.hash, "verybadpassword")
+			assert.NoError(t, err)
+			assert.True(t, valid)
+		})
+	}
+}
+
+func TestTokenHasher(t *testing.T) {
+	hashing := config.Hashing{Algorithm: config.HashingArgon2id, Argon2id: testArgon2idSettings}
+	hasher := NewTokenHasher([]string{"needsToBeAtLeast16"}, hashing)
+
+	hash, err := hasher.Hash("123456")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$hmac-sha256$"))
+	assert.False(t, hasher.NeedsRehash(hash))
+
+	valid, err := hasher.Verify(hash, "123456")
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	valid, err = hasher.Verify(hash, "654321")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+
+	// hashes remain valid after a new key was added in front of the old one
+	rotated := NewTokenHasher([]string{"anotherKeyOfAtLeast16", "needsToBeAtLeast16"}, hashing)
+	valid, err = rotated.Verify(hash, "123456")
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	// hashes of another key are not valid
+	other := NewTokenHasher([]string{"anotherKeyOfAtLeast16"}, hashing)
+	valid, err = other.Verify(hash, "123456")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+}
+
+func TestTokenHasher_VerifiesHashesOfThePasswordHasher(t *testing.T) {
+	hashing := config.Hashing{Algorithm: config.HashingArgon2id, Argon2id: testArgon2idSettings}
+	hash, err := NewHasher(hashing).Hash("abcde-fghjk")
+	require.NoError(t, err)
+
+	hasher := NewTokenHasher([]string{"needsToBeAtLeast16"}, hashing)
+	valid, err := hasher.Verify(hash, "abcde-fghjk")
+	assert.NoError(t, err)
+	assert.True(t, valid)
+	assert.True(t, hasher.NeedsRehash(hash))
+}
+
+func TestTokenHasher_UsesThePasswordHasherWithoutKeys(t *testing.T) {
+	hashing := config.Hashing{Algorithm: config.HashingArgon2id, Argon2id: testArgon2idSettings}
+	hasher := NewTokenHasher(nil, hashing)
+
+	hash, err := hasher.Hash("123456")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, argon2idPrefix))
+
+	valid, err := hasher.Verify(hash, "123456")
+	assert.NoError(t, err)
+	assert.True(t, valid)
+}
+
+func TestIsSupportedHash(t *testing.T) {
+	bcryptHash, err := NewBcryptHasher(4).Hash("verybadpassword")
+	require.NoError(t, err)
+	argon2idHash, err := NewHasher(config.Hashing{Algorithm: config.HashingArgon2id, Argon2id: testArgon2idSettings}).Hash("verybadpassword")
+	require.NoError(t, err)
+
+	assert.True(t, IsSupportedHash(bcryptHash))
+	assert.True(t, IsSupportedHash(argon2idHash))
+	assert.False(t, IsSupportedHash("verybadpassword"))
+	assert.False(t, IsSupportedHash("$argon2id$v=19$m=65536"))
+	assert.False(t, IsSupportedHash(""))
+}
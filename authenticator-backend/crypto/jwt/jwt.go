@@ -3,6 +3,9 @@ package jwt
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
+
 	"github.com/lestrrat-go/jwx/v2/jwa"
 	"github.com/lestrrat-go/jwx/v2/jwk"
 	"github.com/lestrrat-go/jwx/v2/jwt"
@@ -22,6 +25,17 @@ type generator struct {
 const (
 	SurrogateKey = "surr"
 	GrantKey     = "grant"
+	AuthTimeKey  = "auth_time"
+	AmrKey       = "amr"
+	RestrictKey  = "restrict"
+	PurposeKey   = "purpose"
+	ScopeKey     = "scope"
+)
+
+// Authentication method reference values as defined in RFC 8176
+const (
+	AmrHardwareKey = "hwk"
+	AmrUser        = "user"
 )
 
 // NewGenerator returns a new jwt generator which signs JWTs with the given signing key and verifies JWTs with the given verificationKeys
@@ -83,3 +97,74 @@ func GetGrantKeyFromToken(token jwt.Token) (string, error) {
 	}
 	return key.(string), nil
 }
+
+// GetAuthTimeFromToken returns the time of the last step-up authentication stamped into the token
+func GetAuthTimeFromToken(token jwt.Token) (time.Time, error) {
+	claims := token.PrivateClaims()
+	if claims == nil {
+		return time.Time{}, errors.New("unable to get auth time from token: private claims not found")
+	}
+	switch value := claims[AuthTimeKey].(type) {
+	case float64:
+		return time.Unix(int64(value), 0).UTC(), nil
+	case int64:
+		return time.Unix(value, 0).UTC(), nil
+	case time.Time:
+		return value.UTC(), nil
+	}
+	return time.Time{}, errors.New("unable to get auth time from token: key not found")
+}
+
+// GetAmrFromToken returns the authentication method references stamped into the token
+func GetAmrFromToken(token jwt.Token) ([]string, error) {
+	claims := token.PrivateClaims()
+	if claims == nil {
+		return nil, errors.New("unable to get amr from token: private claims not found")
+	}
+	switch value := claims[AmrKey].(type) {
+	case []string:
+		return value, nil
+	case []interface{}:
+		methods := make([]string, 0, len(value))
+		for _, method := range value {
+			if str, ok := method.(string); ok {
+				methods = append(methods, str)
+			}
+		}
+		return methods, nil
+	}
+	return nil, errors.New("unable to get amr from token: key not found")
+}
+
+// GetRestrictionFromToken returns the restriction of the session, e.g. when the user logged in with a recovery code
+// and must register a new credential first. An empty string is returned for unrestricted sessions.
+func GetRestrictionFromToken(token jwt.Token) string {
+	claims := token.PrivateClaims()
+	if claims == nil {
+		return ""
+	}
+	restriction, _ := claims[RestrictKey].(string)
+	return restriction
+}
+
+// GetPurposeFromToken returns the purpose of a JWT which is not a session, e.g. a password reset token. An empty string
+// is returned for session JWTs.
+func GetPurposeFromToken(token jwt.Token) string {
+	claims := token.PrivateClaims()
+	if claims == nil {
+		return ""
+	}
+	purpose, _ := claims[PurposeKey].(string)
+	return purpose
+}
+
+// GetScopesFromToken returns the scopes of a guest session, i.e. what the guest may do on behalf of the account holder.
+// The scopes are a space separated list as in RFC 8693.
+func GetScopesFromToken(token jwt.Token) []string {
+	claims := token.PrivateClaims()
+	if claims == nil {
+		return []string{}
+	}
+	scopes, _ := claims[ScopeKey].(string)
+	return strings.Fields(scopes)
+}
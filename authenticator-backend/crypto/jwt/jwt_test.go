@@ -6,6 +6,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func TestNewGenerator(t *testing.T) {
@@ -85,6 +86,35 @@ func TestGenerator_Verify(t *testing.T) {
 	}
 }
 
+func TestGetAuthTimeAndAmrFromToken(t *testing.T) {
+	signatureKey := getSignatureJwk(t, key1)
+	verificationKeys := getVerificationJwks(t)
+	jwtGenerator, err := NewGenerator(signatureKey, verificationKeys)
+	require.NoError(t, err)
+
+	authTime := time.Now().UTC().Truncate(time.Second)
+	token := jwt.New()
+	require.NoError(t, token.Set(jwt.SubjectKey, subject))
+	require.NoError(t, token.Set(AuthTimeKey, authTime.Unix()))
+	require.NoError(t, token.Set(AmrKey, []string{AmrHardwareKey, AmrUser}))
+
+	signedTokenBytes, err := jwtGenerator.Sign(token)
+	require.NoError(t, err)
+	verifiedToken, err := jwtGenerator.Verify(signedTokenBytes)
+	require.NoError(t, err)
+
+	verifiedAuthTime, err := GetAuthTimeFromToken(verifiedToken)
+	assert.NoError(t, err)
+	assert.Equal(t, authTime, verifiedAuthTime)
+
+	amr, err := GetAmrFromToken(verifiedToken)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{AmrHardwareKey, AmrUser}, amr)
+
+	_, err = GetAuthTimeFromToken(jwt.New())
+	assert.Error(t, err)
+}
+
 func getSignatureJwk(t *testing.T, keyString string) jwk.Key {
 	key, err := jwk.ParseKey([]byte(keyString))
 	require.NoError(t, err)
@@ -0,0 +1,39 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// recoveryCodeAlphabet omits characters which are easily confused with each other, e.g. 0/O and 1/l
+const recoveryCodeAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+
+type RecoveryCodeGenerator interface {
+	Generate() (string, error)
+}
+
+type recoveryCodeGenerator struct {
+}
+
+func NewRecoveryCodeGenerator() RecoveryCodeGenerator {
+	return &recoveryCodeGenerator{}
+}
+
+// Generate returns a random code in the form xxxxx-xxxxx
+func (*recoveryCodeGenerator) Generate() (string, error) {
+	code := make([]byte, 11)
+	max := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	for i := range code {
+		if i == 5 {
+			code[i] = '-'
+			continue
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate random number: %w", err)
+		}
+		code[i] = recoveryCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
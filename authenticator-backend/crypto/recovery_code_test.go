@@ -0,0 +1,19 @@
+package crypto
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRecoveryCodeGenerator_Generate(t *testing.T) {
+	rg := NewRecoveryCodeGenerator()
+	code, err := rg.Generate()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 11, len(code))
+	assert.Equal(t, byte('-'), code[5])
+
+	other, err := rg.Generate()
+	assert.NoError(t, err)
+	assert.NotEqual(t, code, other)
+}
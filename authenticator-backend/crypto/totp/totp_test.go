@@ -0,0 +1,62 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test vectors from RFC 6238 Appendix B, truncated to 6 digits
+var rfcSecret = []byte("12345678901234567890")
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		Time int64
+		Code string
+	}{
+		{Time: 59, Code: "287082"},
+		{Time: 1111111109, Code: "081804"},
+		{Time: 1111111111, Code: "050471"},
+		{Time: 1234567890, Code: "005924"},
+		{Time: 2000000000, Code: "279037"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Code, Code(rfcSecret, Step(time.Unix(test.Time, 0))))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	step, ok := Validate(rfcSecret, "081804", now)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now), step)
+
+	step, ok = Validate(rfcSecret, "081804", now.Add(Period*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, Step(now), step)
+
+	_, ok = Validate(rfcSecret, "081804", now.Add(3*Period*time.Second))
+	assert.False(t, ok)
+
+	_, ok = Validate(rfcSecret, "000000", now)
+	assert.False(t, ok)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, SecretSize)
+
+	uri, err := url.Parse(ProvisioningURI(secret, "Hanko", "john.doe@example.com"))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Hanko:john.doe@example.com", uri.Path)
+	assert.Equal(t, EncodeSecret(secret), uri.Query().Get("secret"))
+	assert.Equal(t, "Hanko", uri.Query().Get("issuer"))
+}
@@ -0,0 +1,8 @@
+package dto
+
+import "time"
+
+type AccountDeletionReturn struct {
+	// ScheduledAt is the time after which the account is deleted, the deletion can be cancelled until then
+	ScheduledAt time.Time `json:"scheduled_at"`
+}
@@ -6,10 +6,21 @@ import (
 
 // PublicConfig is the part of the configuration that will be shared with the frontend
 type PublicConfig struct {
-	Password config.Password `json:"password"`
+	Password     config.Password      `json:"password"`
+	Passcode     PublicPasscodeConfig `json:"passcode"`
+	SecondFactor config.SecondFactor  `json:"second_factor"`
+}
+
+// PublicPasscodeConfig tells the frontend whether a passcode is entered on the login screen or sent as a link
+type PublicPasscodeConfig struct {
+	Mode string `json:"mode"`
 }
 
 // FromConfig Returns a PublicConfig from the Application configuration
 func FromConfig(config config.Config) PublicConfig {
-	return PublicConfig{Password: config.Password}
+	return PublicConfig{
+		Password:     config.Password,
+		Passcode:     PublicPasscodeConfig{Mode: config.Passcode.Mode},
+		SecondFactor: config.SecondFactor,
+	}
 }
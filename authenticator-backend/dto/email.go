@@ -0,0 +1,5 @@
+package dto
+
+type EmailCreateRequest struct {
+	Address string `json:"address" validate:"required,email"`
+}
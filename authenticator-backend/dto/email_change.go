@@ -0,0 +1,20 @@
+package dto
+
+import "time"
+
+type EmailChangeInitRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type EmailChangeFinishRequest struct {
+	Id   string `json:"id" validate:"required,uuid4"`
+	Code string `json:"code" validate:"required_without=Token"`
+	// Token is the token of the link, it is sent instead of the code
+	Token string `json:"token" validate:"required_without=Code"`
+}
+
+type EmailChangeReturn struct {
+	Id        string    `json:"id"`
+	TTL       int       `json:"ttl"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -7,9 +7,10 @@ import (
 )
 
 type HTTPError struct {
-	Code     int    `json:"code"`
-	Message  string `json:"message"`
-	Internal error  `json:"-"` // Stores the error returned by an external dependency
+	Code     int         `json:"code"`
+	Message  string      `json:"message"`
+	Details  interface{} `json:"details,omitempty"` // Structured information the frontend can display, e.g. validation errors
+	Internal error       `json:"-"`                 // Stores the error returned by an external dependency
 }
 
 // Error makes it compatible with `error` interface
@@ -28,6 +29,12 @@ func (he *HTTPError) SetInternal(err error) *HTTPError {
 	return he
 }
 
+// SetDetails sets structured information about the error, which will be returned in the response
+func (he *HTTPError) SetDetails(details interface{}) *HTTPError {
+	he.Details = details
+	return he
+}
+
 // Unwrap satisfies the Go 1.13 error wrapper interface.
 func (he *HTTPError) Unwrap() error {
 	return he.Internal
@@ -83,6 +90,9 @@ func NewHTTPErrorHandler(config HTTPErrorHandlerConfig) func(err error, c echo.C
 		if config.Debug {
 			message = echo.Map{"code": code, "message": herr.Message, "error": err.Error()}
 		}
+		if herr.Details != nil {
+			message["details"] = herr.Details
+		}
 
 		// Send response
 		if c.Request().Method == http.MethodHead { // Issue https://github.com/labstack/echo/issues/608
@@ -0,0 +1,131 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// UserExport is the copy of the data stored about a user. Secrets like public keys, password hashes, TOTP secrets,
+// recovery codes and grant tokens are never included.
+type UserExport struct {
+	ExportedAt          time.Time                      `json:"exported_at"`
+	User                UserExportUser                 `json:"user"`
+	Emails              []UserExportEmail              `json:"emails"`
+	WebauthnCredentials []UserExportWebauthnCredential `json:"webauthn_credentials"`
+	LoginAuditLogs      UserExportLoginAuditLogs       `json:"login_audit_logs"`
+	AccessGrants        UserExportAccessGrants         `json:"access_grants"`
+	Relations           UserExportUserGuestRelations   `json:"relations"`
+	Posts               []UserExportPost               `json:"posts"`
+	PostHistories       []UserExportPostHistory        `json:"post_histories"`
+}
+
+type UserExportUser struct {
+	ID                  uuid.UUID  `json:"id"`
+	Email               string     `json:"email"`
+	Verified            bool       `json:"verified"`
+	IsActive            bool       `json:"is_active"`
+	Roles               []string   `json:"roles"`
+	HasPassword         bool       `json:"has_password"`
+	HasTotp             bool       `json:"has_totp"`
+	RecoveryCodes       int        `json:"recovery_codes"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+type UserExportEmail struct {
+	Address   string    `json:"address"`
+	Verified  bool      `json:"verified"`
+	Primary   bool      `json:"primary"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserExportWebauthnCredential struct {
+	ID              string    `json:"id"`
+	AAGUID          uuid.UUID `json:"aaguid"`
+	AttestationType string    `json:"attestation_type"`
+	Transports      []string  `json:"transports"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type UserExportLoginAuditLogs struct {
+	// AsAccountHolder are the logins to the account of the user, including the logins of guests
+	AsAccountHolder []UserExportLoginAuditLog `json:"as_account_holder"`
+	// AsGuest are the logins of the user to other accounts
+	AsGuest []UserExportLoginAuditLog `json:"as_guest"`
+}
+
+type UserExportLoginAuditLog struct {
+	ID                  uuid.UUID  `json:"id"`
+	UserId              uuid.UUID  `json:"user_id"`
+	SurrogateUserId     *uuid.UUID `json:"surrogate_user_id,omitempty"`
+	UserGuestRelationId *uuid.UUID `json:"user_guest_relation_id,omitempty"`
+	LoginMethod         int        `json:"login_method"`
+	ClientIpAddress     string     `json:"client_ip_address"`
+	ClientUserAgent     string     `json:"client_user_agent"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+type UserExportAccessGrants struct {
+	// Created are the grants the user created to share the account
+	Created []UserExportAccessGrant `json:"created"`
+	// Claimed are the grants of other accounts the user claimed as guest
+	Claimed []UserExportAccessGrant `json:"claimed"`
+}
+
+type UserExportAccessGrant struct {
+	ID             uuid.UUID  `json:"id"`
+	UserId         uuid.UUID  `json:"user_id"`
+	Email          *string    `json:"email,omitempty"`
+	IsActive       bool       `json:"is_active"`
+	ClaimedBy      *uuid.UUID `json:"claimed_by,omitempty"`
+	LoginsAllowed  *int32     `json:"logins_allowed,omitempty"`
+	MinutesAllowed *int32     `json:"minutes_allowed,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type UserExportUserGuestRelations struct {
+	// AsAccountHolder are the relations of guests to the account of the user
+	AsAccountHolder []UserExportUserGuestRelation `json:"as_account_holder"`
+	// AsGuest are the relations of the user to other accounts
+	AsGuest []UserExportUserGuestRelation `json:"as_guest"`
+}
+
+type UserExportUserGuestRelation struct {
+	ID             uuid.UUID `json:"id"`
+	GuestUserId    uuid.UUID `json:"guest_user_id"`
+	ParentUserId   uuid.UUID `json:"parent_user_id"`
+	IsActive       bool      `json:"is_active"`
+	LoginsAllowed  *int32    `json:"logins_allowed,omitempty"`
+	MinutesAllowed *int32    `json:"minutes_allowed,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type UserExportPost struct {
+	ID                   uuid.UUID `json:"id"`
+	CreatedByUserId      uuid.UUID `json:"created_by_user_id"`
+	CreatedBySurrogateId uuid.UUID `json:"created_by_surrogate_id"`
+	UpdatedByUserId      uuid.UUID `json:"updated_by_user_id"`
+	UpdatedBySurrogateId uuid.UUID `json:"updated_by_surrogate_id"`
+	Data                 string    `json:"data"`
+	IsActive             bool      `json:"is_active"`
+	Visibility           string    `json:"visibility"`
+	Version              int       `json:"version"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+type UserExportPostHistory struct {
+	ID                   uuid.UUID `json:"id"`
+	PostId               uuid.UUID `json:"post_id"`
+	Version              int       `json:"version"`
+	UpdatedByUserId      uuid.UUID `json:"updated_by_user_id"`
+	UpdatedBySurrogateId uuid.UUID `json:"updated_by_surrogate_id"`
+	Data                 string    `json:"data"`
+	Visibility           string    `json:"visibility"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
@@ -0,0 +1,20 @@
+package dto
+
+import (
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+type GuestActivityReportRequest struct {
+	PerPage int `query:"per_page"`
+	Page    int `query:"page"`
+}
+
+// GuestActivityReport is a page of the activities of a guest under a guest relation, newest first
+type GuestActivityReport struct {
+	RelationId  uuid.UUID              `json:"relation_id"`
+	GuestUserId uuid.UUID              `json:"guest_user_id"`
+	GuestEmail  string                 `json:"guest_email"`
+	Scopes      []string               `json:"scopes"`
+	Activities  []models.GuestActivity `json:"activities"`
+}
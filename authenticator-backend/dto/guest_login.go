@@ -0,0 +1,13 @@
+package dto
+
+type GuestLoginRevokeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type GuestLoginNotificationsRequest struct {
+	Mode string `json:"mode" validate:"required"`
+}
+
+type GuestLoginNotificationsResponse struct {
+	Mode string `json:"mode"`
+}
@@ -0,0 +1,5 @@
+package dto
+
+type LoginAnomalyRevokeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
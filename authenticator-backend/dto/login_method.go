@@ -6,7 +6,10 @@ const (
 	Password      LoginMethod = 0
 	Passcode      LoginMethod = 1
 	Webauthn      LoginMethod = 2
-	LogoutAsGuest LoginMethod = 3
+	LogoutAsGuest LoginMethod = 3 // only found in old records, guest logouts are security events now
+	RecoveryCode  LoginMethod = 4
+	Totp          LoginMethod = 5
+	PasswordReset LoginMethod = 6
 )
 
 func LoginMethodToValue(method LoginMethod) int {
@@ -19,6 +22,12 @@ func LoginMethodToValue(method LoginMethod) int {
 		return 2
 	case LogoutAsGuest:
 		return 3
+	case RecoveryCode:
+		return 4
+	case Totp:
+		return 5
+	case PasswordReset:
+		return 6
 	}
 	return -1
 }
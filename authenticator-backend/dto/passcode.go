@@ -4,15 +4,20 @@ import "time"
 
 type PasscodeFinishRequest struct {
 	Id   string `json:"id" validate:"required,uuid4"`
-	Code string `json:"code" validate:"required"`
+	Code string `json:"code" validate:"required_without=Token"`
+	// Token is the token of a magic link, it is sent instead of the code
+	Token string `json:"token" validate:"required_without=Code"`
 }
 
 type PasscodeInitRequest struct {
 	UserId string `json:"user_id" validate:"required,uuid4"`
+	// Email is the address the passcode is sent to, defaults to the primary address of the user
+	Email string `json:"email" validate:"omitempty,email"`
 }
 
 type PasscodeReturn struct {
-	Id        string    `json:"id"`
-	TTL       int       `json:"ttl"`
-	CreatedAt time.Time `json:"created_at"`
+	Id           string              `json:"id"`
+	TTL          int                 `json:"ttl"`
+	CreatedAt    time.Time           `json:"created_at"`
+	SecondFactor *SecondFactorStatus `json:"second_factor,omitempty"`
 }
@@ -0,0 +1,10 @@
+package dto
+
+type PasswordResetInitRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type PasswordResetFinishRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
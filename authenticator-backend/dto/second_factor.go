@@ -0,0 +1,16 @@
+package dto
+
+// SecondFactorStatus is returned after password or passcode login, when the session is restricted until the user
+// completed the second factor. If the user has not enrolled a second factor yet, the enrolment must be done first.
+type SecondFactorStatus struct {
+	Enrolled bool `json:"enrolled"`
+}
+
+type TotpEnrollmentResponse struct {
+	Secret string `json:"secret"`
+	Uri    string `json:"uri"`
+}
+
+type TotpCodeRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
@@ -0,0 +1,54 @@
+package guestlogin
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+// Mode returns the mode of the notifications the account holder chose, or the configured mode
+func Mode(accountHolder models.User, cfg config.GuestLoginNotifications) string {
+	if accountHolder.GuestLoginNotifications != "" {
+		return accountHolder.GuestLoginNotifications
+	}
+	return cfg.Mode
+}
+
+// NewNotification returns the notification about the login of the guest, or nil if the account holder is not to be
+// notified in the mode. previousLogins is the number of logins of the guest to the relation before this one.
+func NewNotification(mode string, relation models.UserGuestRelation, log models.LoginAuditLog, previousLogins int, now time.Time) *models.GuestLoginNotification {
+	switch mode {
+	case config.GuestLoginNotificationsEach, config.GuestLoginNotificationsDigest:
+	case config.GuestLoginNotificationsFirst:
+		if previousLogins > 0 {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	id, _ := uuid.NewV4()
+	notification := models.GuestLoginNotification{
+		ID:                  id,
+		UserGuestRelationId: relation.ID,
+		UserId:              relation.ParentUserID,
+		GuestUserId:         relation.GuestUserID,
+		ClientIpAddress:     log.ClientIpAddress,
+		ClientUserAgent:     log.ClientUserAgent,
+		LoginAt:             now,
+		Digest:              mode == config.GuestLoginNotificationsDigest,
+		CreatedAt:           now,
+		UpdatedAt:           now,
+	}
+	if relation.ExpireByLogins {
+		remaining := int(relation.LoginsAllowed.Int32) - previousLogins - 1
+		notification.LoginsRemaining = &remaining
+	}
+	if relation.ExpireByTime {
+		expiresAt := relation.CreatedAt.UTC().Add(time.Duration(relation.MinutesAllowed.Int32) * time.Minute)
+		notification.ExpiresAt = &expiresAt
+	}
+	return &notification
+}
@@ -0,0 +1,268 @@
+package guestlogin
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/teamhanko/hanko/backend/config"
+	hankoJwk "github.com/teamhanko/hanko/backend/crypto/jwk"
+	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/mail"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"gopkg.in/gomail.v2"
+)
+
+// PurposeRevoke is the purpose claim of the tokens in the links to revoke a guest relation. The subject of the token
+// is the account holder, the ID of the token is the ID of the relation.
+const PurposeRevoke = "guest_relation_revoke"
+
+const (
+	// batchSize is the maximum number of notifications and digests sent per run
+	batchSize = 100
+	// digestInterval is how long the logins are collected for a digest, counted from the oldest login
+	digestInterval = 24 * time.Hour
+)
+
+// Notifier sends the notifications about the logins of guests to the account holders. Notifications are claimed
+// before they are sent, so several instances can run a notifier at the same time. A notification which can't be sent
+// is not retried.
+type Notifier struct {
+	persister    persistence.Persister
+	mailer       mail.Mailer
+	renderer     *mail.Renderer
+	jwtGenerator jwt2.Generator
+	cfg          *config.Config
+	now          func() time.Time
+}
+
+func NewNotifier(cfg *config.Config, persister persistence.Persister, jwkManager hankoJwk.Manager, mailer mail.Mailer) (*Notifier, error) {
+	renderer, err := mail.NewRenderer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new renderer: %w", err)
+	}
+
+	signatureKey, err := jwkManager.GetSigningKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signing key: %w", err)
+	}
+	verificationKeys, err := jwkManager.GetPublicKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get verification keys: %w", err)
+	}
+	generator, err := jwt2.NewGenerator(signatureKey, verificationKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jwt generator: %w", err)
+	}
+
+	return &Notifier{
+		persister:    persister,
+		mailer:       mailer,
+		renderer:     renderer,
+		jwtGenerator: generator,
+		cfg:          cfg,
+		now:          time.Now,
+	}, nil
+}
+
+// Notify sends the single notifications and the due digests and returns how many emails were sent. A digest is due a
+// day after the oldest login in it.
+func (n *Notifier) Notify() (int, error) {
+	notificationPersister := n.persister.GetGuestLoginNotificationPersister()
+	sent := 0
+
+	notifications, err := notificationPersister.ListSingle(batchSize)
+	if err != nil {
+		return sent, err
+	}
+	for _, notification := range notifications {
+		claimed, err := notificationPersister.Claim(notification.ID)
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+
+		err = n.send(notification.UserId, []models.GuestLoginNotification{notification}, false)
+		if err != nil {
+			log.Printf("failed to notify about guest login %s: %v", notification.ID, err)
+			continue
+		}
+		sent++
+	}
+
+	recipients, err := notificationPersister.ListDigestRecipients(n.now().UTC().Add(-digestInterval), batchSize)
+	if err != nil {
+		return sent, err
+	}
+	for _, userId := range recipients {
+		pending, err := notificationPersister.ListDigest(userId)
+		if err != nil {
+			return sent, err
+		}
+
+		var digest []models.GuestLoginNotification
+		for _, notification := range pending {
+			claimed, err := notificationPersister.Claim(notification.ID)
+			if err != nil {
+				return sent, err
+			}
+			if claimed {
+				digest = append(digest, notification)
+			}
+		}
+		if len(digest) == 0 {
+			continue
+		}
+
+		err = n.send(userId, digest, true)
+		if err != nil {
+			log.Printf("failed to send guest login digest to user %s: %v", userId, err)
+			continue
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+func (n *Notifier) send(userId uuid.UUID, notifications []models.GuestLoginNotification, digest bool) error {
+	userPersister := n.persister.GetUserPersister()
+	user, err := userPersister.Get(userId)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil
+	}
+
+	// there is no request, the notification is sent in the default language
+	lang := ""
+	guestEmails := map[uuid.UUID]string{}
+	logins := []map[string]interface{}{}
+	for _, notification := range notifications {
+		guestEmail, ok := guestEmails[notification.GuestUserId]
+		if !ok {
+			guest, err := userPersister.Get(notification.GuestUserId)
+			if err != nil {
+				return fmt.Errorf("failed to get guest user: %w", err)
+			}
+			if guest == nil {
+				continue
+			}
+			guestEmail = guest.Email
+			guestEmails[notification.GuestUserId] = guestEmail
+		}
+
+		link, err := n.revokeLink(notification)
+		if err != nil {
+			return err
+		}
+
+		loginData := map[string]interface{}{
+			"GuestEmail": guestEmail,
+			"Time":       notification.LoginAt.UTC().Format(time.RFC1123),
+			"IpAddress":  notification.ClientIpAddress,
+			"UserAgent":  notification.ClientUserAgent,
+		}
+		remaining := ""
+		if notification.LoginsRemaining != nil {
+			loginData["LoginsRemaining"] = *notification.LoginsRemaining
+			remaining = n.renderer.Translate(lang, "guest_login_logins_remaining_text", loginData)
+		}
+		if notification.ExpiresAt != nil {
+			minutes := notification.ExpiresAt.Sub(notification.LoginAt).Minutes()
+			if minutes < 0 {
+				minutes = 0
+			}
+			loginData["MinutesRemaining"] = fmt.Sprintf("%.0f", minutes)
+			if remaining != "" {
+				remaining += "\n"
+			}
+			remaining += n.renderer.Translate(lang, "guest_login_minutes_remaining_text", loginData)
+		}
+
+		logins = append(logins, map[string]interface{}{
+			"Details":    n.renderer.Translate(lang, "guest_login_details_text", loginData),
+			"Remaining":  remaining,
+			"RevokeText": n.renderer.Translate(lang, "guest_login_revoke_text", loginData),
+			"Link":       link,
+		})
+	}
+	if len(logins) == 0 {
+		return nil
+	}
+
+	data := map[string]interface{}{
+		"ServiceName": n.cfg.Service.Name,
+		"Digest":      digest,
+		"Count":       len(logins),
+		"GuestEmail":  guestEmails[notifications[0].GuestUserId],
+		"Logins":      logins,
+		"TTL":         fmt.Sprintf("%.0f", (time.Duration(n.cfg.GuestLoginNotifications.RevokeTTL) * time.Second).Hours()),
+	}
+
+	str, err := n.renderer.Render("guestLoginTextMail", lang, data)
+	if err != nil {
+		return fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	subject := "email_subject_guest_login"
+	if digest {
+		subject = "email_subject_guest_login_digest"
+	}
+	message := gomail.NewMessage()
+	message.SetAddressHeader("To", user.Email, "")
+	message.SetAddressHeader("From", n.cfg.Passcode.Email.FromAddress, n.cfg.Passcode.Email.FromName)
+	message.SetHeader("Subject", n.renderer.Translate(lang, subject, data))
+	message.SetBody("text/plain", str)
+
+	err = n.mailer.Send(message)
+	if err != nil {
+		return fmt.Errorf("failed to send guest login email: %w", err)
+	}
+
+	return nil
+}
+
+func (n *Notifier) revokeLink(notification models.GuestLoginNotification) (string, error) {
+	issuedAt := n.now().UTC()
+	token := jwt.New()
+	_ = token.Set(jwt.SubjectKey, notification.UserId.String())
+	_ = token.Set(jwt.JwtIDKey, notification.UserGuestRelationId.String())
+	_ = token.Set(jwt.IssuedAtKey, issuedAt)
+	_ = token.Set(jwt.ExpirationKey, issuedAt.Add(time.Duration(n.cfg.GuestLoginNotifications.RevokeTTL)*time.Second))
+	_ = token.Set(jwt2.PurposeKey, PurposeRevoke)
+
+	signed, err := n.jwtGenerator.Sign(token)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign guest relation token: %w", err)
+	}
+
+	link, err := url.Parse(n.cfg.GuestLoginNotifications.RevokeUrl)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse revoke url: %w", err)
+	}
+	query := link.Query()
+	query.Set("token", string(signed))
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
+
+// Run sends the notifications in the given interval
+func (n *Notifier) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		_, err := n.Notify()
+		if err != nil {
+			log.Printf("failed to notify about guest logins: %v", err)
+		}
+	}
+}
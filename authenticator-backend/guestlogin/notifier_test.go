@@ -0,0 +1,181 @@
+package guestlogin
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/crypto/jwk"
+	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+	"gopkg.in/gomail.v2"
+)
+
+var (
+	holderId = uuid.FromStringOrNil("b5dd5267-b462-48be-b70d-bcd6f1bbe7a5")
+	guestId  = uuid.FromStringOrNil("6e1c4c5b-0d7c-4e58-9c43-9f5b0b1d2e3f")
+)
+
+type recordingMailer struct {
+	messages []*gomail.Message
+}
+
+func (m *recordingMailer) Send(message *gomail.Message) error {
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+func newNotifier(t *testing.T) (*Notifier, persistence.Persister, *recordingMailer) {
+	now := time.Now().UTC()
+	users := []models.User{
+		{ID: holderId, Email: "holder@example.com", IsActive: true, CreatedAt: now, UpdatedAt: now},
+		{ID: guestId, Email: "guest@example.com", IsActive: true, CreatedAt: now, UpdatedAt: now},
+	}
+	persister := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	cfg := config.DefaultConfig()
+	cfg.Service.Name = "Test Service"
+	cfg.Secrets.Keys = []string{"needsToBeAtLeast16"}
+	cfg.GuestLoginNotifications.RevokeUrl = "https://example.com/shares/revoke"
+	jwkManager, err := jwk.NewDefaultManager(cfg.Secrets.Keys, persister.GetJwkPersister())
+	require.NoError(t, err)
+
+	mailer := &recordingMailer{}
+	notifier, err := NewNotifier(cfg, persister, jwkManager, mailer)
+	require.NoError(t, err)
+	return notifier, persister, mailer
+}
+
+func newRelation() models.UserGuestRelation {
+	return models.UserGuestRelation{
+		ID:             uuid.Must(uuid.NewV4()),
+		GuestUserID:    guestId,
+		ParentUserID:   holderId,
+		IsActive:       true,
+		ExpireByLogins: true,
+		LoginsAllowed:  sql.NullInt32{Int32: 3, Valid: true},
+		CreatedAt:      time.Now().UTC(),
+		UpdatedAt:      time.Now().UTC(),
+	}
+}
+
+func body(t *testing.T, message *gomail.Message) string {
+	builder := &strings.Builder{}
+	_, err := message.WriteTo(builder)
+	require.NoError(t, err)
+	return builder.String()
+}
+
+func TestMode(t *testing.T) {
+	cfg := config.GuestLoginNotifications{Mode: config.GuestLoginNotificationsEach}
+
+	assert.Equal(t, config.GuestLoginNotificationsEach, Mode(models.User{}, cfg))
+	assert.Equal(t, config.GuestLoginNotificationsOff, Mode(models.User{GuestLoginNotifications: config.GuestLoginNotificationsOff}, cfg))
+}
+
+func TestNewNotification(t *testing.T) {
+	now := time.Now().UTC()
+	relation := newRelation()
+	log := models.LoginAuditLog{ClientIpAddress: "198.51.100.1:4711", ClientUserAgent: "curl/7.85.0"}
+
+	notification := NewNotification(config.GuestLoginNotificationsEach, relation, log, 1, now)
+	if assert.NotNil(t, notification) {
+		assert.Equal(t, holderId, notification.UserId)
+		assert.Equal(t, guestId, notification.GuestUserId)
+		assert.Equal(t, relation.ID, notification.UserGuestRelationId)
+		assert.False(t, notification.Digest)
+		if assert.NotNil(t, notification.LoginsRemaining) {
+			assert.Equal(t, 1, *notification.LoginsRemaining)
+		}
+		assert.Nil(t, notification.ExpiresAt)
+	}
+
+	assert.NotNil(t, NewNotification(config.GuestLoginNotificationsFirst, relation, log, 0, now))
+	assert.Nil(t, NewNotification(config.GuestLoginNotificationsFirst, relation, log, 1, now))
+	assert.Nil(t, NewNotification(config.GuestLoginNotificationsOff, relation, log, 0, now))
+
+	digest := NewNotification(config.GuestLoginNotificationsDigest, relation, log, 0, now)
+	if assert.NotNil(t, digest) {
+		assert.True(t, digest.Digest)
+	}
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	notifier, persister, mailer := newNotifier(t)
+	now := time.Now().UTC()
+	relation := newRelation()
+	log := models.LoginAuditLog{ClientIpAddress: "198.51.100.1:4711", ClientUserAgent: "curl/7.85.0"}
+
+	notificationPersister := persister.GetGuestLoginNotificationPersister()
+	require.NoError(t, notificationPersister.Create(*NewNotification(config.GuestLoginNotificationsEach, relation, log, 0, now)))
+
+	sent, err := notifier.Notify()
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	require.Len(t, mailer.messages, 1)
+	assert.Equal(t, []string{"holder@example.com"}, mailer.messages[0].GetHeader("To"))
+	assert.Equal(t, []string{"guest@example.com signed in to your Test Service account"}, mailer.messages[0].GetHeader("Subject"))
+	content := body(t, mailer.messages[0])
+	assert.Contains(t, content, "IP address: 198.51.100.1:4711")
+	assert.Contains(t, content, "Remaining logins: 2")
+	assert.Contains(t, content, "https://example.com/shares/revoke?token=3D")
+
+	sent, err = notifier.Notify()
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+}
+
+func TestNotifier_Notify_Digest(t *testing.T) {
+	notifier, persister, mailer := newNotifier(t)
+	now := time.Now().UTC()
+	relation := newRelation()
+	log := models.LoginAuditLog{ClientIpAddress: "198.51.100.1:4711", ClientUserAgent: "curl/7.85.0"}
+
+	notificationPersister := persister.GetGuestLoginNotificationPersister()
+	require.NoError(t, notificationPersister.Create(*NewNotification(config.GuestLoginNotificationsDigest, relation, log, 0, now.Add(-23*time.Hour))))
+	require.NoError(t, notificationPersister.Create(*NewNotification(config.GuestLoginNotificationsDigest, relation, log, 1, now.Add(-time.Hour))))
+
+	// the oldest login is less than a day ago
+	sent, err := notifier.Notify()
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	notifier.now = func() time.Time { return now.Add(2 * time.Hour) }
+	sent, err = notifier.Notify()
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	require.Len(t, mailer.messages, 1)
+	assert.Equal(t, []string{"Sign-ins of your guests to your Test Service account"}, mailer.messages[0].GetHeader("Subject"))
+	content := body(t, mailer.messages[0])
+	assert.Contains(t, content, "signed in to your Test Service account 2 times")
+	assert.Contains(t, content, "Remaining logins: 2")
+	assert.Contains(t, content, "Remaining logins: 1")
+
+	sent, err = notifier.Notify()
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+}
+
+func TestNotifier_Token(t *testing.T) {
+	notifier, _, _ := newNotifier(t)
+	notification := NewNotification(config.GuestLoginNotificationsEach, newRelation(), models.LoginAuditLog{}, 0, time.Now().UTC())
+
+	link, err := notifier.revokeLink(*notification)
+	require.NoError(t, err)
+
+	signed := link[strings.Index(link, "token=")+len("token="):]
+	token, err := notifier.jwtGenerator.Verify([]byte(signed))
+	require.NoError(t, err)
+	assert.Equal(t, PurposeRevoke, jwt2.GetPurposeFromToken(token))
+	assert.Equal(t, notification.UserGuestRelationId.String(), token.JwtID())
+	assert.Equal(t, holderId.String(), token.Subject())
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+)
+
+type AccountDeletionHandler struct {
+	persister   persistence.Persister
+	gracePeriod time.Duration
+}
+
+// NewAccountDeletionHandler creates a handler for the deletion of the account by the user. The account is deleted
+// after the configured grace period, until then the user can cancel the deletion.
+func NewAccountDeletionHandler(cfg *config.Config, persister persistence.Persister) *AccountDeletionHandler {
+	gracePeriod, _ := time.ParseDuration(cfg.Account.Deletion.GracePeriod) // error can be ignored, value is checked in config validation
+	return &AccountDeletionHandler{
+		persister:   persister,
+		gracePeriod: gracePeriod,
+	}
+}
+
+// Request schedules the deletion of the account. A deletion which is already scheduled is not postponed.
+func (h *AccountDeletionHandler) Request(c echo.Context) error {
+	userId, err := accountHolderId(c)
+	if err != nil {
+		return err
+	}
+
+	userPersister := h.persister.GetUserPersister()
+	user, err := userPersister.Get(userId)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return dto.NewHTTPError(http.StatusNotFound, "user not found")
+	}
+
+	if user.DeletionScheduledAt == nil {
+		scheduledAt := time.Now().UTC().Add(h.gracePeriod)
+		user.DeletionScheduledAt = &scheduledAt
+		user.UpdatedAt = time.Now().UTC()
+		err = userPersister.Update(*user)
+		if err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+	}
+
+	return c.JSON(http.StatusAccepted, dto.AccountDeletionReturn{ScheduledAt: *user.DeletionScheduledAt})
+}
+
+// Cancel cancels a scheduled deletion of the account
+func (h *AccountDeletionHandler) Cancel(c echo.Context) error {
+	userId, err := accountHolderId(c)
+	if err != nil {
+		return err
+	}
+
+	userPersister := h.persister.GetUserPersister()
+	user, err := userPersister.Get(userId)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return dto.NewHTTPError(http.StatusNotFound, "user not found")
+	}
+
+	if user.DeletionScheduledAt == nil {
+		return dto.NewHTTPError(http.StatusNotFound, "no deletion scheduled")
+	}
+
+	user.DeletionScheduledAt = nil
+	user.UpdatedAt = time.Now().UTC()
+	err = userPersister.Update(*user)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+func newAccountDeletionHandler(t *testing.T) *AccountDeletionHandler {
+	cfg := config.DefaultConfig()
+	require.NoError(t, cfg.Account.Validate())
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	return NewAccountDeletionHandler(cfg, p)
+}
+
+func TestAccountDeletionHandler_Request(t *testing.T) {
+	h := newAccountDeletionHandler(t)
+	uId := uuid.FromStringOrNil(userId)
+
+	c, rec := newEmailContext(t, http.MethodPost, "/users/deletion", "", "")
+	require.NoError(t, h.Request(c))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	var response dto.AccountDeletionReturn
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.WithinDuration(t, time.Now().Add(720*time.Hour), response.ScheduledAt, time.Minute)
+
+	user, err := h.persister.GetUserPersister().Get(uId)
+	require.NoError(t, err)
+	require.NotNil(t, user.DeletionScheduledAt)
+	assert.True(t, user.DeletionScheduledAt.Equal(response.ScheduledAt))
+
+	// a repeated request does not postpone the deletion
+	c, rec = newEmailContext(t, http.MethodPost, "/users/deletion", "", "")
+	require.NoError(t, h.Request(c))
+	var repeated dto.AccountDeletionReturn
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &repeated))
+	assert.True(t, repeated.ScheduledAt.Equal(response.ScheduledAt))
+}
+
+func TestAccountDeletionHandler_Request_AsGuest(t *testing.T) {
+	h := newAccountDeletionHandler(t)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/users/deletion", nil)
+	c := e.NewContext(req, httptest.NewRecorder())
+	c.Set("session", generateJwt(t, uuid.FromStringOrNil(userId), generateUuid(t), 5))
+
+	err := h.Request(c)
+	if assert.Error(t, err) {
+		httpError := dto.ToHttpError(err)
+		assert.Equal(t, http.StatusForbidden, httpError.Code)
+	}
+}
+
+func TestAccountDeletionHandler_Cancel(t *testing.T) {
+	h := newAccountDeletionHandler(t)
+	uId := uuid.FromStringOrNil(userId)
+
+	c, _ := newEmailContext(t, http.MethodDelete, "/users/deletion", "", "")
+	err := h.Cancel(c)
+	if assert.Error(t, err) {
+		httpError := dto.ToHttpError(err)
+		assert.Equal(t, http.StatusNotFound, httpError.Code)
+	}
+
+	c, _ = newEmailContext(t, http.MethodPost, "/users/deletion", "", "")
+	require.NoError(t, h.Request(c))
+
+	c, rec := newEmailContext(t, http.MethodDelete, "/users/deletion", "", "")
+	require.NoError(t, h.Cancel(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	user, err := h.persister.GetUserPersister().Get(uId)
+	require.NoError(t, err)
+	assert.Nil(t, user.DeletionScheduledAt)
+}
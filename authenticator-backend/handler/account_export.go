@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/teamhanko/hanko/backend/account"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+)
+
+type AccountExportHandler struct {
+	exporter *account.Exporter
+}
+
+// NewAccountExportHandler creates a handler which hands out a copy of the data stored about the signed-in user
+func NewAccountExportHandler(persister persistence.Persister) *AccountExportHandler {
+	return &AccountExportHandler{exporter: account.NewExporter(persister)}
+}
+
+// Export returns the data of the user as JSON or, with the query parameter format=zip, as zipped JSON
+func (h *AccountExportHandler) Export(c echo.Context) error {
+	userId, err := accountHolderId(c)
+	if err != nil {
+		return err
+	}
+
+	format := c.QueryParam("format")
+	if format != "" && format != "json" && format != "zip" {
+		return dto.NewHTTPError(http.StatusBadRequest, "format must be one of json, zip")
+	}
+
+	export, err := h.exporter.Export(userId)
+	if err != nil {
+		return fmt.Errorf("failed to export user: %w", err)
+	}
+	if export == nil {
+		return dto.NewHTTPError(http.StatusNotFound, "user not found")
+	}
+
+	response := c.Response()
+	if format == "zip" {
+		response.Header().Set(echo.HeaderContentType, "application/zip")
+		response.Header().Set(echo.HeaderContentDisposition, `attachment; filename="hanko-export.zip"`)
+		response.WriteHeader(http.StatusOK)
+		return account.WriteZip(response, export)
+	}
+
+	response.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	response.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, account.ExportFileName))
+	response.WriteHeader(http.StatusOK)
+	return account.WriteJSON(response, export)
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+func TestAccountExportHandler_Export(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	h := NewAccountExportHandler(p)
+
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+	}{
+		{name: "json", query: "", contentType: echo.MIMEApplicationJSONCharsetUTF8},
+		{name: "zip", query: "?format=zip", contentType: "application/zip"},
+	}
+
+	for _, currentTest := range tests {
+		t.Run(currentTest.name, func(t *testing.T) {
+			c, rec := newEmailContext(t, http.MethodGet, "/users/export"+currentTest.query, "", "")
+			require.NoError(t, h.Export(c))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, currentTest.contentType, rec.Header().Get(echo.HeaderContentType))
+			assert.Contains(t, rec.Header().Get(echo.HeaderContentDisposition), "attachment")
+		})
+	}
+
+	c, rec := newEmailContext(t, http.MethodGet, "/users/export", "", "")
+	require.NoError(t, h.Export(c))
+	var export dto.UserExport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &export))
+	assert.Equal(t, userId, export.User.ID.String())
+}
+
+func TestAccountExportHandler_Export_InvalidFormat(t *testing.T) {
+	h := NewAccountExportHandler(test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil))
+
+	c, _ := newEmailContext(t, http.MethodGet, "/users/export?format=xml", "", "")
+	err := h.Export(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusBadRequest, dto.ToHttpError(err).Code)
+	}
+}
+
+func TestAccountExportHandler_Export_AsGuest(t *testing.T) {
+	h := NewAccountExportHandler(test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil))
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/users/export", nil)
+	c := e.NewContext(req, httptest.NewRecorder())
+	c.Set("session", generateJwt(t, uuid.FromStringOrNil(userId), generateUuid(t), 5))
+
+	err := h.Export(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusForbidden, dto.ToHttpError(err).Code)
+	}
+}
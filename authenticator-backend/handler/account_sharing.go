@@ -9,6 +9,7 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-webauthn/webauthn/protocol"
@@ -26,8 +27,10 @@ import (
 	"github.com/teamhanko/hanko/backend/mail"
 	"github.com/teamhanko/hanko/backend/persistence"
 	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/ratelimit"
+	"github.com/teamhanko/hanko/backend/resource"
 	"github.com/teamhanko/hanko/backend/session"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/teamhanko/hanko/backend/webhook"
 	"gopkg.in/gomail.v2"
 )
 
@@ -35,6 +38,8 @@ type AccountSharingHandler struct {
 	mailer          mail.Mailer
 	renderer        *mail.Renderer
 	nanoidGenerator crypto.NanoidGenerator
+	hasher          crypto.Hasher
+	shareLimiter    *ratelimit.Limiter
 	sessionManager  session.Manager
 	persister       persistence.Persister
 	emailConfig     config.Email
@@ -69,6 +74,8 @@ func NewAccountSharingHandler(cfg *config.Config, persister persistence.Persiste
 		mailer:          mailer,
 		renderer:        renderer,
 		nanoidGenerator: crypto.NewNanoidGenerator(),
+		hasher:          crypto.NewTokenHasher(cfg.Secrets.Keys, cfg.Hashing),
+		shareLimiter:    ratelimit.New(cfg.RateLimit, "share_invitation", cfg.RateLimit.ShareInvitation, persister),
 		persister:       persister,
 		emailConfig:     cfg.Passcode.Email, // TODO: Separate out into its own config value
 		serviceConfig:   cfg.Service,
@@ -84,6 +91,8 @@ type AccountShareRequest struct {
 	LifetimeMinutes int32  `json:"minutesAllowed"`
 	ExpireByLogins  bool   `json:"expireByLogin"`
 	LoginsAllowed   int32  `json:"loginsAllowed"`
+	// Scopes restrict what the guest may do on behalf of the account holder, the guest gets all scopes if empty
+	Scopes []string `json:"scopes"`
 }
 
 func (h *AccountSharingHandler) BeginShare(c echo.Context) error {
@@ -116,6 +125,16 @@ func (h *AccountSharingHandler) BeginShare(c echo.Context) error {
 		return dto.NewHTTPError(http.StatusForbidden)
 	}
 
+	scopes := request.Scopes
+	if len(scopes) == 0 {
+		scopes = resource.Scopes()
+	}
+	for _, scope := range scopes {
+		if !resource.IsScope(scope) {
+			return dto.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unknown scope %s", scope))
+		}
+	}
+
 	user, err := h.persister.GetUserPersister().Get(uId)
 	if err != nil {
 		return fmt.Errorf("failed to get user: %w", err)
@@ -124,6 +143,17 @@ func (h *AccountSharingHandler) BeginShare(c echo.Context) error {
 		return dto.NewHTTPError(http.StatusNotFound).SetInternal(errors.New("user not found"))
 	}
 
+	// every invitation counts, so neither the inviting user nor the invited address can be used to send mass emails
+	rateLimitKeys := []string{userRateLimitKey(uId), emailRateLimitKey(request.Email), ipRateLimitKey(c)}
+	if err := checkRateLimit(c, h.shareLimiter, rateLimitKeys...); err != nil {
+		return err
+	}
+	if err := h.shareLimiter.Register(rateLimitKeys...); err != nil {
+		return err
+	}
+
+	invitedEmail := strings.ToLower(request.Email)
+
 	nanoidGenerator := crypto.NewNanoidGenerator()
 	accessToken, err := nanoidGenerator.Generate()
 	if err != nil {
@@ -135,7 +165,7 @@ func (h *AccountSharingHandler) BeginShare(c echo.Context) error {
 		return fmt.Errorf("failed to create grantId: %w", err)
 	}
 	now := time.Now().UTC()
-	hashedAccessToken, err := bcrypt.GenerateFromPassword([]byte(accessToken), 12)
+	hashedAccessToken, err := h.hasher.Hash(accessToken)
 	if err != nil {
 		return fmt.Errorf("failed to hash access token: %w", err)
 	}
@@ -143,7 +173,7 @@ func (h *AccountSharingHandler) BeginShare(c echo.Context) error {
 		ID:             grantId,
 		UserId:         uId,
 		Ttl:            60 * TimeToLiveMinutes,
-		Token:          string(hashedAccessToken),
+		Token:          hashedAccessToken,
 		IsActive:       true,
 		CreatedAt:      now,
 		UpdatedAt:      now,
@@ -151,6 +181,8 @@ func (h *AccountSharingHandler) BeginShare(c echo.Context) error {
 		LoginsAllowed:  sql.NullInt32{Int32: request.LoginsAllowed, Valid: request.ExpireByLogins},
 		ExpireByTime:   request.ExpireByTime,
 		MinutesAllowed: sql.NullInt32{Int32: request.LifetimeMinutes, Valid: request.ExpireByTime},
+		Email:          &invitedEmail,
+		Scopes:         strings.Join(scopes, ","),
 	}
 
 	err = h.persister.GetAccountAccessGrantPersister().Create(accessGrantModel)
@@ -158,6 +190,14 @@ func (h *AccountSharingHandler) BeginShare(c echo.Context) error {
 		return fmt.Errorf("failed to create access grant: %w", err)
 	}
 
+	event := newSecurityEvent(c, models.EventGrantCreated, &uId)
+	event.Metadata["grant_id"] = grantId.String()
+	event.Metadata["email"] = invitedEmail
+	err = h.persister.GetSecurityEventPersister().Create(event)
+	if err != nil {
+		return fmt.Errorf("failed to create security event: %w", err)
+	}
+
 	lang := c.Request().Header.Get("Accept-Language")
 
 	data := map[string]interface{}{
@@ -232,10 +272,13 @@ func (h *AccountSharingHandler) GetAccountShareGrantWithToken(grantId string, to
 			return nil
 		}
 
-		err = bcrypt.CompareHashAndPassword([]byte(grant.Token), []byte(token))
+		valid, err := h.hasher.Verify(grant.Token, token)
+		if err != nil {
+			return fmt.Errorf("failed to verify access token: %w", err)
+		}
 
 		// Return same HTTP code for (grant ID not found) and (token invalid) to prevent disclosing which condition failed
-		if err != nil {
+		if !valid {
 			businessError = dto.NewHTTPError(http.StatusNotFound, "grant not found")
 		}
 
@@ -292,42 +335,14 @@ func (h *AccountSharingHandler) BeginCreateAccountWithGrant(c echo.Context) erro
 		return dto.NewHTTPError(http.StatusNotFound).SetInternal(fmt.Errorf("unable to find a grant with ID %s", request.GrantId))
 	}
 
-	var options *protocol.CredentialAssertion
-	var sessionData *webauthn.SessionData
-
-	webauthnUser, err := h.getWebauthnUser(h.persister.GetConnection(), uuid.FromStringOrNil(sessionToken.Subject()))
+	webauthnUser, err := getWebauthnUser(h.persister, h.persister.GetConnection(), uuid.FromStringOrNil(sessionToken.Subject()))
 	if err != nil || webauthnUser == nil {
 		return dto.NewHTTPError(http.StatusNotFound).SetInternal(fmt.Errorf("an error occurred fetching webauthn user for user id %s: %w", sessionToken.Subject(), err))
 	}
 
-	if webauthnUser == nil {
-		return dto.NewHTTPError(http.StatusBadRequest, "user not found")
-	}
-
-	if len(webauthnUser.WebAuthnCredentials()) > 0 {
-		options, sessionData, err = h.webauthn.BeginLogin(webauthnUser, webauthn.WithUserVerification(protocol.VerificationRequired))
-		if err != nil {
-			return fmt.Errorf("failed to create webauthn assertion options: %w", err)
-		}
-	}
-
-	if options == nil && sessionData == nil {
-		var err error
-		options, sessionData, err = h.webauthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
-		if err != nil {
-			return fmt.Errorf("failed to create webauthn assertion options for discoverable login: %w", err)
-		}
-	}
-
-	err = h.persister.GetWebauthnSessionDataPersister().Create(*intern.WebauthnSessionDataToModel(sessionData, models.WebauthnOperationAuthentication))
+	options, err := beginUserVerifiedAssertion(h.webauthn, h.persister, webauthnUser)
 	if err != nil {
-		return fmt.Errorf("failed to store webauthn assertion session data: %w", err)
-	}
-
-	// Remove all transports, because of a bug in android and windows where the internal authenticator gets triggered,
-	// when the transports array contains the type 'internal' although the credential is not available on the device.
-	for i := range options.Response.AllowedCredentials {
-		options.Response.AllowedCredentials[i].Transport = nil
+		return err
 	}
 
 	grantAttestationObject := GrantAttestationObject{
@@ -409,6 +424,25 @@ func (h *AccountSharingHandler) FinishCreateAccountWithGrant(c echo.Context) err
 	guestUserId := uuid.FromStringOrNil(body.GuestUserId)
 	primaryUserId := uuid.FromStringOrNil(sessionToken.Subject())
 
+	// the guest is matched by any of their verified addresses, grants created before invitations stored the address
+	// can be claimed by any guest
+	if grant.Email != nil {
+		guestUser, err := h.persister.GetUserPersister().Get(guestUserId)
+		if err != nil {
+			return fmt.Errorf("failed to get guest user: %w", err)
+		}
+		if guestUser == nil {
+			return dto.NewHTTPError(http.StatusNotFound).SetInternal(fmt.Errorf("unable to find user id %s", guestUserId))
+		}
+		invited, err := hasVerifiedEmail(h.persister.GetEmailPersister(), guestUser, *grant.Email)
+		if err != nil {
+			return err
+		}
+		if !invited {
+			return dto.NewHTTPError(http.StatusForbidden).SetInternal(fmt.Errorf("guest user %s has no verified address matching the invitation of grant id %s", guestUserId, grant.ID))
+		}
+	}
+
 	existingUserGuestRelationships, err := h.persister.GetUserGuestRelationPersister().GetByGuestUserId(&guestUserId)
 	if err != nil {
 		fmt.Println("an error occurred fetching existing user guest relationships: ", err)
@@ -431,8 +465,6 @@ func (h *AccountSharingHandler) FinishCreateAccountWithGrant(c echo.Context) err
 	grant.ClaimedBy = &guestUserId
 	grant.UserGuestRelationId = &relationId
 
-	h.persister.GetAccountAccessGrantPersister().Update(*grant)
-
 	hash := []byte(body.GrantAttestation)
 
 	userGuestRelation := models.UserGuestRelation{
@@ -448,9 +480,23 @@ func (h *AccountSharingHandler) FinishCreateAccountWithGrant(c echo.Context) err
 		AssociatedAccessGrantId: grant.ID,
 		IsActive:                true,
 		GrantHash:               &hash,
+		Scopes:                  grant.Scopes,
 	}
 
-	h.persister.GetUserGuestRelationPersister().Create(userGuestRelation)
+	err = h.persister.Transaction(func(tx *pop.Connection) error {
+		err := h.persister.GetAccountAccessGrantPersisterWithConnection(tx).Update(*grant)
+		if err != nil {
+			return fmt.Errorf("failed to update grant: %w", err)
+		}
+		err = h.persister.GetUserGuestRelationPersisterWithConnection(tx).Create(userGuestRelation)
+		if err != nil {
+			return fmt.Errorf("failed to create user guest relation: %w", err)
+		}
+		return webhook.Enqueue(h.persister.GetWebhookPersisterWithConnection(tx), models.WebhookEventRelationCreated, webhook.NewRelationData(userGuestRelation, ""))
+	})
+	if err != nil {
+		return err
+	}
 
 	return c.JSON(http.StatusOK, struct{}{})
 }
@@ -470,85 +516,7 @@ func (*AccountSharingHandler) validateTokenForPrimaryAccountHolder(c echo.Contex
 	return sessionToken, nil
 }
 
-func (h AccountSharingHandler) getWebauthnUser(connection *pop.Connection, userId uuid.UUID) (*intern.WebauthnUser, error) {
-	user, err := h.persister.GetUserPersisterWithConnection(connection).Get(userId)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get user: %w", err)
-	}
-
-	if user == nil {
-		return nil, nil
-	}
-
-	credentials, err := h.persister.GetWebauthnCredentialPersisterWithConnection(connection).GetFromUser(user.ID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get webauthn credentials: %w", err)
-	}
-
-	return intern.NewWebauthnUser(*user, credentials), nil
-}
-
 func (h AccountSharingHandler) validateWebauthnRequest(request *protocol.ParsedCredentialAssertionData) (error, *webauthn.Credential, *intern.WebauthnUser) {
-	var credential *webauthn.Credential
-	var webauthnUser *intern.WebauthnUser
-	err := h.persister.Transaction(func(tx *pop.Connection) error {
-		sessionDataPersister := h.persister.GetWebauthnSessionDataPersisterWithConnection(tx)
-		sessionData, err := sessionDataPersister.GetByChallenge(request.Response.CollectedClientData.Challenge)
-		if err != nil {
-			return fmt.Errorf("failed to get webauthn assertion session data: %w", err)
-		}
-
-		if sessionData != nil && sessionData.Operation != models.WebauthnOperationAuthentication {
-			sessionData = nil
-		}
-
-		if sessionData == nil {
-			return dto.NewHTTPError(http.StatusUnauthorized, "Stored challenge and received challenge do not match").SetInternal(errors.New("sessionData not found"))
-		}
-
-		model := intern.WebauthnSessionDataFromModel(sessionData)
-
-		if sessionData.UserId.IsNil() {
-			// Discoverable Login
-			userId, err := uuid.FromBytes(request.Response.UserHandle)
-			if err != nil {
-				return dto.NewHTTPError(http.StatusBadRequest, "failed to parse userHandle as uuid").SetInternal(err)
-			}
-			webauthnUser, err = h.getWebauthnUser(tx, userId)
-			if err != nil {
-				return fmt.Errorf("failed to get user: %w", err)
-			}
-
-			if webauthnUser == nil {
-				return dto.NewHTTPError(http.StatusUnauthorized).SetInternal(errors.New("user not found"))
-			}
-
-			credential, err = h.webauthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (user webauthn.User, err error) {
-				return webauthnUser, nil
-			}, *model, request)
-			if err != nil {
-				return dto.NewHTTPError(http.StatusUnauthorized, "failed to validate assertion").SetInternal(err)
-			}
-		} else {
-			// non discoverable Login
-			webauthnUser, err = h.getWebauthnUser(tx, sessionData.UserId)
-			if err != nil {
-				return fmt.Errorf("failed to get user: %w", err)
-			}
-			if webauthnUser == nil {
-				return dto.NewHTTPError(http.StatusUnauthorized).SetInternal(errors.New("user not found"))
-			}
-			credential, err = h.webauthn.ValidateLogin(webauthnUser, *model, request)
-			if err != nil {
-				return dto.NewHTTPError(http.StatusUnauthorized, "failed to validate assertion").SetInternal(err)
-			}
-		}
-
-		err = sessionDataPersister.Delete(*sessionData)
-		if err != nil {
-			return fmt.Errorf("failed to delete assertion session data: %w", err)
-		}
-		return nil
-	})
+	credential, webauthnUser, err := finishAssertion(h.webauthn, h.persister, request)
 	return err, credential, webauthnUser
 }
@@ -12,6 +12,7 @@ import (
 	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
 	"github.com/teamhanko/hanko/backend/dto"
 	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/resource"
 	"github.com/teamhanko/hanko/backend/test"
 	"golang.org/x/crypto/bcrypt"
 	"net/http"
@@ -673,9 +674,24 @@ func generateJwt(t *testing.T, subjectUserId uuid.UUID, surrogateUserId uuid.UUI
 	assert.NoError(t, token.Set(jwt.SubjectKey, subjectUserId.String()))
 	assert.NoError(t, token.Set(jwt2.SurrogateKey, surrogateUserId.String()))
 	assert.NoError(t, token.Set(jwt.ExpirationKey, time.Now().UTC().Add(time.Duration(sessionLengthMinutes)*time.Minute)))
+	if subjectUserId != surrogateUserId {
+		// the sessions of guests carry the guest relation and its scopes, all scopes by default
+		assert.NoError(t, token.Set(jwt2.GrantKey, generateUuid(t).String()))
+		assert.NoError(t, token.Set(jwt2.ScopeKey, strings.Join(resource.Scopes(), " ")))
+	}
 	return token
 }
 
+// newContext returns the context of a JSON request to the handler under test
+func newContext(method string, target string, body string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
 func (h *AccountSharingHandler) generateCredentialsAndSessionDataForUserId(userId uuid.UUID) {
 	credentials := []models.WebauthnCredential{
 		func() models.WebauthnCredential {
@@ -757,3 +773,21 @@ var signedRequestBody = `{
 "grantId": "%s",
 "grantAttestation": "%s"
 }`
+
+func Test_AccountSharingHandler_BeginShare_Errors_WhenScopeIsUnknown(t *testing.T) {
+	handler := generateHandler(t)
+	primaryUser := generateUser(t)
+	handler.persister.GetUserPersister().Create(primaryUser)
+
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	req := httptest.NewRequest(http.MethodPost, "/share", strings.NewReader(`{"email": "guest@example.com", "scopes": ["posts:read", "users:delete"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	c := e.NewContext(req, httptest.NewRecorder())
+	c.Set("session", generateJwt(t, primaryUser.ID, primaryUser.ID, 60))
+
+	err := handler.BeginShare(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusBadRequest, dto.ToHttpError(err).Code)
+	}
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/teamhanko/hanko/backend/actor"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+// actingAs returns the actor of the request. The Session middleware puts the actor into the context of the request,
+// without the middleware it is derived from the session.
+func actingAs(c echo.Context) (actor.Actor, error) {
+	if a, ok := actor.FromContext(c.Request().Context()); ok {
+		return a, nil
+	}
+
+	sessionToken, ok := c.Get("session").(jwt.Token)
+	if !ok {
+		return actor.Actor{}, dto.NewHTTPError(http.StatusUnauthorized)
+	}
+	a, err := actor.FromToken(sessionToken)
+	if err != nil {
+		return actor.Actor{}, dto.NewHTTPError(http.StatusUnauthorized).SetInternal(err)
+	}
+	return a, nil
+}
+
+// recordGuestActivity records the action for the report to the account holder, if a guest acts on behalf of the
+// account holder. It should be called in the transaction of the action.
+func recordGuestActivity(persister persistence.GuestActivityPersister, a actor.Actor, action string, resourceType string, resourceId uuid.UUID) error {
+	activity := models.NewGuestActivity(a, action, resourceType, resourceId)
+	if activity == nil {
+		return nil
+	}
+	return persister.Create(*activity)
+}
@@ -0,0 +1,313 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+// unverifiedEmailLifespan is how long an address added by a user is reserved for them without being verified
+const unverifiedEmailLifespan = 24 * time.Hour
+
+type EmailHandler struct {
+	persister persistence.Persister
+}
+
+// NewEmailHandler creates a handler for managing the email addresses of the signed-in user. New addresses are
+// unverified until a passcode sent to them was entered.
+func NewEmailHandler(persister persistence.Persister) *EmailHandler {
+	return &EmailHandler{persister: persister}
+}
+
+// List returns all email addresses of the user, including the primary address
+func (h *EmailHandler) List(c echo.Context) error {
+	userId, err := accountHolderId(c)
+	if err != nil {
+		return err
+	}
+
+	emails, err := h.persister.GetEmailPersister().FindByUserId(userId)
+	if err != nil {
+		return fmt.Errorf("failed to get emails: %w", err)
+	}
+	if emails == nil {
+		emails = []models.Email{}
+	}
+
+	return c.JSON(http.StatusOK, emails)
+}
+
+// Create adds an unverified address to the user. Unverified addresses of other users block the address only until
+// they expire.
+func (h *EmailHandler) Create(c echo.Context) error {
+	userId, err := accountHolderId(c)
+	if err != nil {
+		return err
+	}
+
+	var body dto.EmailCreateRequest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	if err := c.Validate(body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	address := strings.ToLower(body.Address)
+
+	var email models.Email
+	err = h.persister.Transaction(func(tx *pop.Connection) error {
+		existingUser, err := h.persister.GetUserPersisterWithConnection(tx).GetByEmail(address)
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		if existingUser != nil {
+			return dto.NewHTTPError(http.StatusConflict, "email address not available")
+		}
+
+		emailPersister := h.persister.GetEmailPersisterWithConnection(tx)
+		err = emailPersister.DeleteUnverified(address, time.Now().UTC().Add(-unverifiedEmailLifespan))
+		if err != nil {
+			return err
+		}
+
+		reserved, err := emailPersister.GetByAddress(address)
+		if err != nil {
+			return fmt.Errorf("failed to get email: %w", err)
+		}
+		if reserved != nil {
+			return dto.NewHTTPError(http.StatusConflict, "email address not available")
+		}
+
+		email = models.NewEmail(userId, address)
+		return emailPersister.Create(email)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, email)
+}
+
+// Delete removes an address of the user, the primary address can't be removed
+func (h *EmailHandler) Delete(c echo.Context) error {
+	userId, err := accountHolderId(c)
+	if err != nil {
+		return err
+	}
+
+	email, err := h.getEmail(c, userId)
+	if err != nil {
+		return err
+	}
+
+	if email.Primary {
+		return dto.NewHTTPError(http.StatusBadRequest, "the primary email address can't be deleted")
+	}
+
+	err = h.persister.GetEmailPersister().Delete(*email)
+	if err != nil {
+		return fmt.Errorf("failed to delete email: %w", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// SetPrimary makes a verified address the primary address of the user. The previous primary address is kept.
+func (h *EmailHandler) SetPrimary(c echo.Context) error {
+	userId, err := accountHolderId(c)
+	if err != nil {
+		return err
+	}
+
+	email, err := h.getEmail(c, userId)
+	if err != nil {
+		return err
+	}
+
+	if !email.Verified {
+		return dto.NewHTTPError(http.StatusBadRequest, "only a verified email address can be the primary address")
+	}
+
+	return h.persister.Transaction(func(tx *pop.Connection) error {
+		emailPersister := h.persister.GetEmailPersisterWithConnection(tx)
+		userPersister := h.persister.GetUserPersisterWithConnection(tx)
+
+		emails, err := emailPersister.FindByUserId(userId)
+		if err != nil {
+			return fmt.Errorf("failed to get emails: %w", err)
+		}
+
+		now := time.Now().UTC()
+		for _, other := range emails {
+			if other.Primary && other.ID != email.ID {
+				other.Primary = false
+				other.UpdatedAt = now
+				err = emailPersister.Update(other)
+				if err != nil {
+					return fmt.Errorf("failed to update email: %w", err)
+				}
+			}
+		}
+
+		email.Primary = true
+		email.UpdatedAt = now
+		err = emailPersister.Update(*email)
+		if err != nil {
+			return fmt.Errorf("failed to update email: %w", err)
+		}
+
+		user, err := userPersister.Get(userId)
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		if user == nil {
+			return dto.NewHTTPError(http.StatusNotFound).SetInternal(errors.New("user not found"))
+		}
+
+		user.Email = email.Address
+		user.Verified = true
+		user.UpdatedAt = now
+		err = userPersister.Update(*user)
+		if err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+
+		return c.JSON(http.StatusOK, email)
+	})
+}
+
+func (h *EmailHandler) getEmail(c echo.Context, userId uuid.UUID) (*models.Email, error) {
+	emailId, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return nil, dto.NewHTTPError(http.StatusBadRequest, "failed to parse id as uuid").SetInternal(err)
+	}
+
+	email, err := h.persister.GetEmailPersister().Get(emailId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email: %w", err)
+	}
+	if email == nil || email.UserId != userId {
+		return nil, dto.NewHTTPError(http.StatusNotFound).SetInternal(errors.New("email not found"))
+	}
+
+	return email, nil
+}
+
+// accountHolderId returns the id of the signed-in user. Guests acting on behalf of an account holder can't manage
+// the email addresses of the account.
+func accountHolderId(c echo.Context) (uuid.UUID, error) {
+	sessionToken, ok := c.Get("session").(jwt.Token)
+	if !ok {
+		return uuid.Nil, errors.New("missing or malformed jwt")
+	}
+
+	surrogateId, err := jwt2.GetSurrogateKeyFromToken(sessionToken)
+	if err != nil {
+		return uuid.Nil, dto.NewHTTPError(http.StatusUnauthorized).SetInternal(fmt.Errorf("unable to get surrogate ID from token: %w", err))
+	}
+
+	if sessionToken.Subject() != surrogateId {
+		return uuid.Nil, dto.NewHTTPError(http.StatusForbidden).SetInternal(errors.New("only the account holder can manage email addresses"))
+	}
+
+	return uuid.FromStringOrNil(sessionToken.Subject()), nil
+}
+
+// replacePrimaryEmail replaces the primary address of the user with the given address, the previous primary address
+// is removed. Unverified secondary addresses of other users don't block the address. The caller has to update the
+// user afterwards.
+func replacePrimaryEmail(emailPersister persistence.EmailPersister, user *models.User, address string, verified bool) error {
+	emails, err := emailPersister.FindByUserId(user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get emails: %w", err)
+	}
+
+	now := time.Now().UTC()
+	var replacement *models.Email
+	for _, email := range emails {
+		if email.Address == address {
+			e := email
+			replacement = &e
+		} else if email.Primary {
+			err = emailPersister.Delete(email)
+			if err != nil {
+				return fmt.Errorf("failed to delete email: %w", err)
+			}
+		}
+	}
+
+	if replacement == nil {
+		err = emailPersister.DeleteUnverified(address, now)
+		if err != nil {
+			return err
+		}
+
+		email := models.NewEmail(user.ID, address)
+		email.Primary = true
+		email.Verified = verified
+		err = emailPersister.Create(email)
+	} else {
+		replacement.Primary = true
+		replacement.Verified = replacement.Verified || verified
+		replacement.UpdatedAt = now
+		err = emailPersister.Update(*replacement)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store primary email: %w", err)
+	}
+
+	user.Email = address
+	return nil
+}
+
+// markEmailVerified marks the address of the user as verified, the primary address also in the users table. The
+// caller has to update the user afterwards.
+func markEmailVerified(emailPersister persistence.EmailPersister, user *models.User, address string) error {
+	if address == user.Email {
+		user.Verified = true
+	}
+
+	email, err := emailPersister.GetByAddress(address)
+	if err != nil {
+		return fmt.Errorf("failed to get email: %w", err)
+	}
+	if email == nil || email.UserId != user.ID || email.Verified {
+		return nil
+	}
+
+	email.Verified = true
+	email.UpdatedAt = time.Now().UTC()
+	err = emailPersister.Update(*email)
+	if err != nil {
+		return fmt.Errorf("failed to update email: %w", err)
+	}
+
+	return nil
+}
+
+// hasVerifiedEmail returns whether the address is a verified address of the user
+func hasVerifiedEmail(emailPersister persistence.EmailPersister, user *models.User, address string) (bool, error) {
+	if address == user.Email && user.Verified {
+		return true, nil
+	}
+
+	email, err := emailPersister.GetByAddress(address)
+	if err != nil {
+		return false, fmt.Errorf("failed to get email: %w", err)
+	}
+
+	return email != nil && email.UserId == user.ID && email.Verified, nil
+}
@@ -0,0 +1,384 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/crypto"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/mail"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/ratelimit"
+	"gopkg.in/gomail.v2"
+)
+
+type EmailChangeHandler struct {
+	persister         persistence.Persister
+	cfg               *config.Config
+	mailer            mail.Mailer
+	renderer          *mail.Renderer
+	passcodeGenerator crypto.PasscodeGenerator
+	nanoidGenerator   crypto.NanoidGenerator
+	hasher            crypto.Hasher
+	limiter           *ratelimit.Limiter
+}
+
+// NewEmailChangeHandler creates a handler for changing the email address of the signed-in user. Depending on the
+// passcode mode, a passcode or a link is sent to the new address, and it expires with the passcode TTL.
+func NewEmailChangeHandler(cfg *config.Config, persister persistence.Persister, mailer mail.Mailer) (*EmailChangeHandler, error) {
+	renderer, err := mail.NewRenderer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new renderer: %w", err)
+	}
+	return &EmailChangeHandler{
+		persister:         persister,
+		cfg:               cfg,
+		mailer:            mailer,
+		renderer:          renderer,
+		passcodeGenerator: crypto.NewPasscodeGenerator(),
+		nanoidGenerator:   crypto.NewNanoidGenerator(),
+		hasher:            crypto.NewTokenHasher(cfg.Secrets.Keys, cfg.Hashing),
+		limiter:           ratelimit.New(cfg.RateLimit, "email_change", cfg.RateLimit.PasscodeInit, persister),
+	}, nil
+}
+
+// Init sends a passcode or link to the new email address and notifies the current address about the requested change
+func (h *EmailChangeHandler) Init(c echo.Context) error {
+	userId, err := accountHolderId(c)
+	if err != nil {
+		return err
+	}
+
+	var body dto.EmailChangeInitRequest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	if err := c.Validate(body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	newEmail := strings.ToLower(body.Email)
+
+	rateLimitKeys := []string{userRateLimitKey(userId), ipRateLimitKey(c)}
+	if err := checkRateLimit(c, h.limiter, rateLimitKeys...); err != nil {
+		return err
+	}
+
+	user, err := h.persister.GetUserPersister().Get(userId)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return dto.NewHTTPError(http.StatusNotFound).SetInternal(errors.New("user not found"))
+	}
+
+	existingUser, err := h.persister.GetUserPersister().GetByEmail(newEmail)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if existingUser != nil && existingUser.ID == user.ID {
+		return dto.NewHTTPError(http.StatusBadRequest, "email address is already in use by this account")
+	}
+	if existingUser != nil {
+		return dto.NewHTTPError(http.StatusConflict, "email address not available")
+	}
+
+	// every email sent counts, so the inbox of the new address can't be flooded
+	if err := h.limiter.Register(rateLimitKeys...); err != nil {
+		return err
+	}
+
+	changeId, err := uuid.NewV4()
+	if err != nil {
+		return fmt.Errorf("failed to create email change id: %w", err)
+	}
+	now := time.Now().UTC()
+	change := models.EmailChange{
+		ID:        changeId,
+		UserId:    user.ID,
+		Email:     newEmail,
+		Ttl:       h.cfg.Passcode.TTL,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	lang := c.Request().Header.Get("Accept-Language")
+	var message *gomail.Message
+	if h.cfg.Passcode.Mode == config.PasscodeModeLink {
+		message, err = h.linkMessage(lang, &change)
+	} else {
+		message, err = h.codeMessage(lang, &change)
+	}
+	if err != nil {
+		return err
+	}
+
+	// only the latest requested change can be confirmed
+	err = h.persister.Transaction(func(tx *pop.Connection) error {
+		changePersister := h.persister.GetEmailChangePersisterWithConnection(tx)
+		err := changePersister.DeleteByUserId(user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to delete email changes: %w", err)
+		}
+		return changePersister.Create(change)
+	})
+	if err != nil {
+		return err
+	}
+
+	message.SetAddressHeader("To", newEmail, "")
+	message.SetAddressHeader("From", h.cfg.Passcode.Email.FromAddress, h.cfg.Passcode.Email.FromName)
+	err = h.mailer.Send(message)
+	if err != nil {
+		return fmt.Errorf("failed to send email change confirmation: %w", err)
+	}
+
+	err = h.sendNotice(lang, user.Email, newEmail)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, dto.EmailChangeReturn{
+		Id:        change.ID.String(),
+		TTL:       change.Ttl,
+		CreatedAt: change.CreatedAt,
+	})
+}
+
+// Finish verifies the passcode or link token and changes the email address, if it is still available
+func (h *EmailChangeHandler) Finish(c echo.Context) error {
+	startTime := time.Now().UTC()
+	userId, err := accountHolderId(c)
+	if err != nil {
+		return err
+	}
+
+	var body dto.EmailChangeFinishRequest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	if err := c.Validate(body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	changeId, err := uuid.FromString(body.Id)
+	if err != nil {
+		return dto.NewHTTPError(http.StatusBadRequest, "failed to parse id as uuid").SetInternal(err)
+	}
+
+	// only if an internal server occurs the transaction should be rolled back
+	var businessError error
+	transactionError := h.persister.Transaction(func(tx *pop.Connection) error {
+		changePersister := h.persister.GetEmailChangePersisterWithConnection(tx)
+		userPersister := h.persister.GetUserPersisterWithConnection(tx)
+
+		change, err := changePersister.Get(changeId)
+		if err != nil {
+			return fmt.Errorf("failed to get email change: %w", err)
+		}
+		if change == nil || change.UserId != userId {
+			businessError = dto.NewHTTPError(http.StatusNotFound, "email change not found")
+			return nil
+		}
+
+		lastVerificationTime := change.CreatedAt.Add(time.Duration(change.Ttl) * time.Second)
+		if lastVerificationTime.Before(startTime) {
+			businessError = dto.NewHTTPError(http.StatusRequestTimeout, "email change request timed out").SetInternal(fmt.Errorf("createdAt: %s -> lastVerificationTime: %s", change.CreatedAt, lastVerificationTime))
+			return nil
+		}
+
+		valid, err := h.verify(change, body)
+		if err != nil {
+			return fmt.Errorf("failed to verify email change: %w", err)
+		}
+		if !valid {
+			change.TryCount = change.TryCount + 1
+
+			if change.TryCount >= maxPasscodeTries {
+				err = changePersister.Delete(*change)
+				if err != nil {
+					return fmt.Errorf("failed to delete email change: %w", err)
+				}
+				businessError = dto.NewHTTPError(http.StatusGone, "max attempts reached")
+				return nil
+			}
+
+			change.UpdatedAt = time.Now().UTC()
+			err = changePersister.Update(*change)
+			if err != nil {
+				return fmt.Errorf("failed to update email change: %w", err)
+			}
+
+			businessError = dto.NewHTTPError(http.StatusUnauthorized).SetInternal(errors.New("email change passcode invalid"))
+			return nil
+		}
+
+		err = changePersister.Delete(*change)
+		if err != nil {
+			return fmt.Errorf("failed to delete email change: %w", err)
+		}
+
+		// the address might have been taken since the change was requested
+		existingUser, err := userPersister.GetByEmail(change.Email)
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		if existingUser != nil && existingUser.ID != change.UserId {
+			businessError = dto.NewHTTPError(http.StatusConflict, "email address not available")
+			return nil
+		}
+
+		user, err := userPersister.Get(change.UserId)
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		if user == nil {
+			businessError = dto.NewHTTPError(http.StatusNotFound).SetInternal(errors.New("user not found"))
+			return nil
+		}
+
+		err = replacePrimaryEmail(h.persister.GetEmailPersisterWithConnection(tx), user, change.Email, true)
+		if err != nil {
+			return err
+		}
+		user.Verified = true
+		user.UpdatedAt = time.Now().UTC()
+		err = userPersister.Update(*user)
+		if err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+
+		return c.JSON(http.StatusOK, user)
+	})
+
+	if businessError != nil {
+		return businessError
+	}
+
+	return transactionError
+}
+
+func (h *EmailChangeHandler) codeMessage(lang string, change *models.EmailChange) (*gomail.Message, error) {
+	code, err := h.passcodeGenerator.Generate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate passcode: %w", err)
+	}
+	hashedCode, err := h.hasher.Hash(code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash passcode: %w", err)
+	}
+	change.Code = hashedCode
+
+	durationTTL := time.Duration(change.Ttl) * time.Second
+	data := map[string]interface{}{
+		"Code":        code,
+		"Email":       change.Email,
+		"ServiceName": h.cfg.Service.Name,
+		"TTL":         fmt.Sprintf("%.0f", durationTTL.Minutes()),
+	}
+
+	str, err := h.renderer.Render("emailChangeCodeTextMail", lang, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	message := gomail.NewMessage()
+	message.SetHeader("Subject", h.renderer.Translate(lang, "email_subject_email_change", data))
+	message.SetBody("text/plain", str)
+
+	return message, nil
+}
+
+func (h *EmailChangeHandler) linkMessage(lang string, change *models.EmailChange) (*gomail.Message, error) {
+	token, err := h.nanoidGenerator.Generate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate link token: %w", err)
+	}
+	hashedToken, err := h.hasher.Hash(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash link token: %w", err)
+	}
+	change.Token = &hashedToken
+
+	link, err := url.Parse(h.cfg.Passcode.Link.EmailChangeUrl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse email change url: %w", err)
+	}
+	query := link.Query()
+	query.Set("id", change.ID.String())
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	durationTTL := time.Duration(change.Ttl) * time.Second
+	data := map[string]interface{}{
+		// built from the configuration and generated values only, see PasscodeHandler.linkMessage
+		"Link":        template.HTML(link.String()),
+		"Email":       change.Email,
+		"ServiceName": h.cfg.Service.Name,
+		"TTL":         fmt.Sprintf("%.0f", durationTTL.Minutes()),
+	}
+
+	str, err := h.renderer.Render("emailChangeLinkTextMail", lang, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	message := gomail.NewMessage()
+	message.SetHeader("Subject", h.renderer.Translate(lang, "email_subject_email_change", data))
+	message.SetBody("text/plain", str)
+
+	return message, nil
+}
+
+// sendNotice informs the current address about the requested change, so an attacker with access to the session can't
+// change the address unnoticed
+func (h *EmailChangeHandler) sendNotice(lang string, oldEmail string, newEmail string) error {
+	data := map[string]interface{}{
+		"Email":       newEmail,
+		"ServiceName": h.cfg.Service.Name,
+	}
+
+	str, err := h.renderer.Render("emailChangeNoticeTextMail", lang, data)
+	if err != nil {
+		return fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	message := gomail.NewMessage()
+	message.SetAddressHeader("To", oldEmail, "")
+	message.SetAddressHeader("From", h.cfg.Passcode.Email.FromAddress, h.cfg.Passcode.Email.FromName)
+	message.SetHeader("Subject", h.renderer.Translate(lang, "email_subject_email_change_notice", data))
+	message.SetBody("text/plain", str)
+
+	err = h.mailer.Send(message)
+	if err != nil {
+		return fmt.Errorf("failed to send email change notice: %w", err)
+	}
+
+	return nil
+}
+
+func (h *EmailChangeHandler) verify(change *models.EmailChange, body dto.EmailChangeFinishRequest) (bool, error) {
+	if change.Token == nil {
+		if body.Code == "" {
+			return false, nil
+		}
+		return h.hasher.Verify(change.Code, body.Code)
+	}
+
+	if body.Token == "" {
+		return false, nil
+	}
+	return h.hasher.Verify(*change.Token, body.Token)
+}
@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/crypto"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+func emailChangeConfig() *config.Config {
+	cfg := defaultConfig
+	cfg.Service.Name = "Test Service"
+	cfg.Passcode.TTL = 300
+	return &cfg
+}
+
+func newEmailChangeContext(t *testing.T, path string, body interface{}, subject uuid.UUID, surrogate uuid.UUID) (echo.Context, *httptest.ResponseRecorder) {
+	bodyJson, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	c, rec := newContext(http.MethodPost, path, string(bodyJson))
+	c.Set("session", generateJwt(t, subject, surrogate, 5))
+	return c, rec
+}
+
+func createEmailChange(t *testing.T, p persistence.Persister, email string, createdAt time.Time) models.EmailChange {
+	code, err := crypto.NewBcryptHasher(4).Hash("123456")
+	require.NoError(t, err)
+
+	change := models.EmailChange{
+		ID:        generateUuid(t),
+		UserId:    uuid.FromStringOrNil(userId),
+		Email:     email,
+		Ttl:       300,
+		Code:      code,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+	require.NoError(t, p.GetEmailChangePersister().Create(change))
+	return change
+}
+
+func TestEmailChangeHandler_Init(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	m := &recordingMailer{}
+	handler, err := NewEmailChangeHandler(emailChangeConfig(), p, m)
+	require.NoError(t, err)
+
+	uId := uuid.FromStringOrNil(userId)
+	c, rec := newEmailChangeContext(t, "/users/email/initialize", dto.EmailChangeInitRequest{Email: "John.New@example.com"}, uId, uId)
+
+	if assert.NoError(t, handler.Init(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response dto.EmailChangeReturn
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		change, err := p.GetEmailChangePersister().Get(uuid.FromStringOrNil(response.Id))
+		require.NoError(t, err)
+		if assert.NotNil(t, change) {
+			assert.Equal(t, "john.new@example.com", change.Email)
+			assert.Equal(t, 300, change.Ttl)
+		}
+
+		if assert.Len(t, m.messages, 2) {
+			assert.Equal(t, []string{"john.new@example.com"}, m.messages[0].GetHeader("To"))
+			assert.Equal(t, []string{"Confirm your new email address for Test Service"}, m.messages[0].GetHeader("Subject"))
+			assert.Equal(t, []string{"john.doe@example.com"}, m.messages[1].GetHeader("To"))
+			assert.Equal(t, []string{"Your email address for Test Service is being changed"}, m.messages[1].GetHeader("Subject"))
+		}
+
+		user, err := p.GetUserPersister().Get(uId)
+		require.NoError(t, err)
+		assert.Equal(t, "john.doe@example.com", user.Email)
+	}
+}
+
+func TestEmailChangeHandler_Init_Errors(t *testing.T) {
+	uId := uuid.FromStringOrNil(userId)
+	otherUser := models.NewUser("jane.doe@example.com")
+
+	tests := []struct {
+		name         string
+		email        string
+		surrogate    uuid.UUID
+		expectedCode int
+	}{
+		{
+			name:         "address of another user",
+			email:        "jane.doe@example.com",
+			surrogate:    uId,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "current address",
+			email:        "john.doe@example.com",
+			surrogate:    uId,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "guest session",
+			email:        "john.new@example.com",
+			surrogate:    otherUser.ID,
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := test.NewPersister(append([]models.User{otherUser}, users...), nil, nil, nil, nil, nil, nil, nil, nil)
+			m := &recordingMailer{}
+			handler, err := NewEmailChangeHandler(emailChangeConfig(), p, m)
+			require.NoError(t, err)
+
+			c, _ := newEmailChangeContext(t, "/users/email/initialize", dto.EmailChangeInitRequest{Email: tt.email}, uId, tt.surrogate)
+			err = handler.Init(c)
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.expectedCode, dto.ToHttpError(err).Code)
+			}
+			assert.Empty(t, m.messages)
+		})
+	}
+}
+
+func TestEmailChangeHandler_Finish(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	handler, err := NewEmailChangeHandler(emailChangeConfig(), p, &recordingMailer{})
+	require.NoError(t, err)
+	change := createEmailChange(t, p, "john.new@example.com", time.Now().UTC())
+
+	uId := uuid.FromStringOrNil(userId)
+	c, rec := newEmailChangeContext(t, "/users/email/finalize", dto.EmailChangeFinishRequest{Id: change.ID.String(), Code: "123456"}, uId, uId)
+
+	if assert.NoError(t, handler.Finish(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		user, err := p.GetUserPersister().Get(uId)
+		require.NoError(t, err)
+		assert.Equal(t, "john.new@example.com", user.Email)
+		assert.True(t, user.Verified)
+
+		stored, err := p.GetEmailChangePersister().Get(change.ID)
+		assert.NoError(t, err)
+		assert.Nil(t, stored)
+	}
+}
+
+func TestEmailChangeHandler_Finish_Errors(t *testing.T) {
+	uId := uuid.FromStringOrNil(userId)
+
+	tests := []struct {
+		name         string
+		code         string
+		createdAt    time.Time
+		takenBy      *models.User
+		expectedCode int
+	}{
+		{
+			name:         "wrong code",
+			code:         "654321",
+			createdAt:    time.Now().UTC(),
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "expired",
+			code:         "123456",
+			createdAt:    time.Now().UTC().Add(-time.Hour),
+			expectedCode: http.StatusRequestTimeout,
+		},
+		{
+			name:      "address taken in the meantime",
+			code:      "123456",
+			createdAt: time.Now().UTC(),
+			takenBy: func() *models.User {
+				user := models.NewUser("john.new@example.com")
+				return &user
+			}(),
+			expectedCode: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+			if tt.takenBy != nil {
+				require.NoError(t, p.GetUserPersister().Create(*tt.takenBy))
+			}
+			handler, err := NewEmailChangeHandler(emailChangeConfig(), p, &recordingMailer{})
+			require.NoError(t, err)
+			change := createEmailChange(t, p, "john.new@example.com", tt.createdAt)
+
+			c, _ := newEmailChangeContext(t, "/users/email/finalize", dto.EmailChangeFinishRequest{Id: change.ID.String(), Code: tt.code}, uId, uId)
+			err = handler.Finish(c)
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.expectedCode, dto.ToHttpError(err).Code)
+			}
+
+			user, err := p.GetUserPersister().Get(uId)
+			require.NoError(t, err)
+			assert.Equal(t, "john.doe@example.com", user.Email)
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+func newEmailContext(t *testing.T, method string, path string, body string, emailId string) (echo.Context, *httptest.ResponseRecorder) {
+	c, rec := newContext(method, path, body)
+	if emailId != "" {
+		c.SetParamNames("id")
+		c.SetParamValues(emailId)
+	}
+	uId := uuid.FromStringOrNil(userId)
+	c.Set("session", generateJwt(t, uId, uId, 5))
+	return c, rec
+}
+
+func createEmail(t *testing.T, p persistence.Persister, userId uuid.UUID, address string, verified bool, primary bool) models.Email {
+	email := models.NewEmail(userId, address)
+	email.Verified = verified
+	email.Primary = primary
+	require.NoError(t, p.GetEmailPersister().Create(email))
+	return email
+}
+
+func TestEmailHandler_List(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	uId := uuid.FromStringOrNil(userId)
+	createEmail(t, p, uId, "john.doe@example.com", true, true)
+	createEmail(t, p, uId, "john@work.example.com", false, false)
+	createEmail(t, p, generateUuid(t), "jane.doe@example.com", true, true)
+
+	c, rec := newEmailContext(t, http.MethodGet, "/emails", "", "")
+	if assert.NoError(t, NewEmailHandler(p).List(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var emails []models.Email
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &emails))
+		if assert.Len(t, emails, 2) {
+			assert.Equal(t, "john.doe@example.com", emails[0].Address)
+			assert.True(t, emails[0].Primary)
+			assert.Equal(t, "john@work.example.com", emails[1].Address)
+			assert.False(t, emails[1].Verified)
+		}
+	}
+}
+
+func TestEmailHandler_Create(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	c, rec := newEmailContext(t, http.MethodPost, "/emails", `{"address": "John@Work.example.com"}`, "")
+	if assert.NoError(t, NewEmailHandler(p).Create(c)) {
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		email, err := p.GetEmailPersister().GetByAddress("john@work.example.com")
+		require.NoError(t, err)
+		if assert.NotNil(t, email) {
+			assert.False(t, email.Verified)
+			assert.False(t, email.Primary)
+		}
+	}
+
+	c, _ = newEmailContext(t, http.MethodPost, "/emails", `{"address": "john@work.example.com"}`, "")
+	err := NewEmailHandler(p).Create(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusConflict, dto.ToHttpError(err).Code)
+	}
+}
+
+func TestEmailHandler_Create_UnverifiedAddressOfAnotherUser(t *testing.T) {
+	tests := []struct {
+		name         string
+		createdAt    time.Time
+		expectedCode int
+	}{
+		{name: "reserved", createdAt: time.Now().UTC().Add(-time.Hour), expectedCode: http.StatusConflict},
+		{name: "expired", createdAt: time.Now().UTC().Add(-unverifiedEmailLifespan - time.Hour), expectedCode: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+			email := models.NewEmail(generateUuid(t), "john@work.example.com")
+			email.CreatedAt = tt.createdAt
+			require.NoError(t, p.GetEmailPersister().Create(email))
+
+			c, rec := newEmailContext(t, http.MethodPost, "/emails", `{"address": "john@work.example.com"}`, "")
+			err := NewEmailHandler(p).Create(c)
+			if tt.expectedCode == http.StatusCreated {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedCode, rec.Code)
+				email, err := p.GetEmailPersister().GetByAddress("john@work.example.com")
+				require.NoError(t, err)
+				assert.Equal(t, userId, email.UserId.String())
+			} else if assert.Error(t, err) {
+				assert.Equal(t, tt.expectedCode, dto.ToHttpError(err).Code)
+			}
+		})
+	}
+}
+
+func TestEmailHandler_Delete(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	uId := uuid.FromStringOrNil(userId)
+	primary := createEmail(t, p, uId, "john.doe@example.com", true, true)
+	secondary := createEmail(t, p, uId, "john@work.example.com", true, false)
+	other := createEmail(t, p, generateUuid(t), "jane.doe@example.com", true, true)
+
+	c, rec := newEmailContext(t, http.MethodDelete, "/emails/:id", "", secondary.ID.String())
+	if assert.NoError(t, NewEmailHandler(p).Delete(c)) {
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		email, err := p.GetEmailPersister().Get(secondary.ID)
+		assert.NoError(t, err)
+		assert.Nil(t, email)
+	}
+
+	c, _ = newEmailContext(t, http.MethodDelete, "/emails/:id", "", primary.ID.String())
+	err := NewEmailHandler(p).Delete(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusBadRequest, dto.ToHttpError(err).Code)
+	}
+
+	c, _ = newEmailContext(t, http.MethodDelete, "/emails/:id", "", other.ID.String())
+	err = NewEmailHandler(p).Delete(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusNotFound, dto.ToHttpError(err).Code)
+	}
+}
+
+func TestEmailHandler_SetPrimary(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	uId := uuid.FromStringOrNil(userId)
+	primary := createEmail(t, p, uId, "john.doe@example.com", true, true)
+	secondary := createEmail(t, p, uId, "john@work.example.com", true, false)
+	unverified := createEmail(t, p, uId, "john@home.example.com", false, false)
+
+	c, rec := newEmailContext(t, http.MethodPost, "/emails/:id/set_primary", "", secondary.ID.String())
+	if assert.NoError(t, NewEmailHandler(p).SetPrimary(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		user, err := p.GetUserPersister().Get(uId)
+		require.NoError(t, err)
+		assert.Equal(t, "john@work.example.com", user.Email)
+
+		previous, err := p.GetEmailPersister().Get(primary.ID)
+		require.NoError(t, err)
+		assert.False(t, previous.Primary)
+	}
+
+	c, _ = newEmailContext(t, http.MethodPost, "/emails/:id/set_primary", "", unverified.ID.String())
+	err := NewEmailHandler(p).SetPrimary(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusBadRequest, dto.ToHttpError(err).Code)
+	}
+}
+
+func TestUserHandler_GetUserIdByEmail_SecondaryAddress(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	createEmail(t, p, uuid.FromStringOrNil(userId), "john@work.example.com", true, false)
+
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"email": "John@Work.example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, NewUserHandler(&defaultConfig, p, sessionManager{}).GetUserIdByEmail(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		response := struct {
+			UserId   string `json:"id"`
+			Verified bool   `json:"verified"`
+		}{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, userId, response.UserId)
+		assert.True(t, response.Verified)
+	}
+}
+
+func TestUserHandler_GetUserIdByEmail_IgnoresUnverifiedSecondaryAddress(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	createEmail(t, p, uuid.FromStringOrNil(userId), "jane.doe@example.com", false, false)
+
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"email": "jane.doe@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := NewUserHandler(&defaultConfig, p, sessionManager{}).GetUserIdByEmail(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusNotFound, dto.ToHttpError(err).Code)
+	}
+}
+
+func TestUserHandler_Create_ReplacesUnverifiedSecondaryAddress(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	createEmail(t, p, uuid.FromStringOrNil(userId), "jane.doe@example.com", false, false)
+
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"email": "jane.doe@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, NewUserHandler(&defaultConfig, p, sessionManager{}).Create(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		email, err := p.GetEmailPersister().GetByAddress("jane.doe@example.com")
+		require.NoError(t, err)
+		if assert.NotNil(t, email) {
+			assert.NotEqual(t, userId, email.UserId.String())
+			assert.True(t, email.Primary)
+		}
+	}
+}
+
+func TestUserHandler_Create_Errors_WhenSecondaryAddressExists(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	createEmail(t, p, uuid.FromStringOrNil(userId), "john@work.example.com", true, false)
+
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"email": "john@work.example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := NewUserHandler(&defaultConfig, p, sessionManager{}).Create(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusConflict, dto.ToHttpError(err).Code)
+	}
+}
+
+func TestPasscodeHandler_SendsToAndVerifiesEnteredAddress(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	uId := uuid.FromStringOrNil(userId)
+	secondary := createEmail(t, p, uId, "john@work.example.com", false, false)
+	m := &recordingMailer{}
+	cfg := defaultConfig
+	cfg.Passcode.TTL = 300
+	passcodeHandler, err := NewPasscodeHandler(&cfg, p, sessionManager{}, m)
+	require.NoError(t, err)
+
+	bodyJson, err := json.Marshal(dto.PasscodeInitRequest{UserId: userId, Email: "john@work.example.com"})
+	require.NoError(t, err)
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	req := httptest.NewRequest(http.MethodPost, "/passcode/login/initialize", bytes.NewReader(bodyJson))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	require.NoError(t, passcodeHandler.Init(e.NewContext(req, rec)))
+
+	if assert.Len(t, m.messages, 1) {
+		assert.Equal(t, []string{"john@work.example.com"}, m.messages[0].GetHeader("To"))
+	}
+
+	var response dto.PasscodeReturn
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	passcode, err := p.GetPasscodePersister().Get(uuid.FromStringOrNil(response.Id))
+	require.NoError(t, err)
+	require.NotNil(t, passcode)
+
+	// replace the unknown passcode with a known one
+	code, err := passcodeHandler.hasher.Hash("123456")
+	require.NoError(t, err)
+	passcode.Code = code
+	require.NoError(t, p.GetPasscodePersister().Update(*passcode))
+
+	bodyJson, err = json.Marshal(dto.PasscodeFinishRequest{Id: response.Id, Code: "123456"})
+	require.NoError(t, err)
+	req = httptest.NewRequest(http.MethodPost, "/passcode/login/finalize", bytes.NewReader(bodyJson))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	if assert.NoError(t, passcodeHandler.Finish(e.NewContext(req, rec))) {
+		email, err := p.GetEmailPersister().Get(secondary.ID)
+		require.NoError(t, err)
+		assert.True(t, email.Verified)
+	}
+}
+
+func TestPasscodeHandler_Init_Errors_WhenAddressBelongsToAnotherUser(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	createEmail(t, p, generateUuid(t), "jane.doe@example.com", true, true)
+	m := &recordingMailer{}
+	passcodeHandler, err := NewPasscodeHandler(&defaultConfig, p, sessionManager{}, m)
+	require.NoError(t, err)
+
+	bodyJson, err := json.Marshal(dto.PasscodeInitRequest{UserId: userId, Email: "jane.doe@example.com"})
+	require.NoError(t, err)
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	req := httptest.NewRequest(http.MethodPost, "/passcode/login/initialize", bytes.NewReader(bodyJson))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	err = passcodeHandler.Init(e.NewContext(req, rec))
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusBadRequest, dto.ToHttpError(err).Code)
+	}
+	assert.Empty(t, m.messages)
+}
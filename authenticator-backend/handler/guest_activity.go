@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+)
+
+type GuestActivityHandler struct {
+	persister persistence.Persister
+}
+
+// NewGuestActivityHandler creates a handler for the reports to the account holders about what their guests did
+func NewGuestActivityHandler(persister persistence.Persister) *GuestActivityHandler {
+	return &GuestActivityHandler{persister: persister}
+}
+
+// Report returns a page of the activities under the guest relation. Only the account holder gets the report, the
+// guest doesn't, not even on behalf of the account holder.
+func (h *GuestActivityHandler) Report(c echo.Context) error {
+	var request dto.GuestActivityReportRequest
+	err := (&echo.DefaultBinder{}).BindQueryParams(c, &request)
+	if err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	relationId, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return dto.NewHTTPError(http.StatusBadRequest, "failed to parse id as uuid").SetInternal(err)
+	}
+
+	a, err := actingAs(c)
+	if err != nil {
+		return err
+	}
+	if a.IsGuest() {
+		return dto.NewHTTPError(http.StatusForbidden)
+	}
+
+	relation, err := h.persister.GetUserGuestRelationPersister().Get(relationId)
+	if err != nil {
+		return fmt.Errorf("failed to get user guest relation: %w", err)
+	}
+	if relation == nil || relation.ParentUserID != a.PrincipalId {
+		return dto.NewHTTPError(http.StatusNotFound).SetInternal(errors.New("user guest relation not found"))
+	}
+
+	report := dto.GuestActivityReport{
+		RelationId:  relation.ID,
+		GuestUserId: relation.GuestUserID,
+		Scopes:      relation.ScopeList(),
+	}
+	guest, err := h.persister.GetUserPersister().Get(relation.GuestUserID)
+	if err != nil {
+		return fmt.Errorf("failed to get guest user: %w", err)
+	}
+	if guest != nil {
+		report.GuestEmail = guest.Email
+	}
+
+	page, perPage := normalizePagination(request.Page, request.PerPage)
+	activities, total, err := h.persister.GetGuestActivityPersister().ListByRelation(relation.ID, page, perPage)
+	if err != nil {
+		return fmt.Errorf("failed to get guest activities: %w", err)
+	}
+	report.Activities = activities
+
+	setPaginationHeaders(c, page, perPage, total)
+	return c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/actor"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+func newActorContext(method string, target string, body string, a actor.Actor) (echo.Context, *httptest.ResponseRecorder) {
+	c, rec := newContext(method, target, body)
+	c.SetRequest(c.Request().WithContext(actor.NewContext(c.Request().Context(), a)))
+	return c, rec
+}
+
+func TestGuestActivityHandler_Report(t *testing.T) {
+	holder := generateUser(t)
+	guest := generateUser(t)
+	relation := newGuestRelation(t)
+	relation.ParentUserID = holder.ID
+	relation.GuestUserID = guest.ID
+	relation.Scopes = models.ScopePostsRead + "," + models.ScopePostsWrite
+	p := test.NewPersister([]models.User{holder, guest}, nil, nil, nil, nil, nil, nil, []models.UserGuestRelation{relation}, nil)
+	guestActor := actor.Actor{PrincipalId: holder.ID, SurrogateId: guest.ID, RelationId: &relation.ID, Scopes: relation.ScopeList()}
+	holderActor := actor.Actor{PrincipalId: holder.ID, SurrogateId: holder.ID}
+
+	// the guest writes a post, the account holder too
+	postHandler := NewPostHandler(p)
+	c, _ := newActorContext(http.MethodPost, "/posts", `{"body": "written by the guest"}`, guestActor)
+	require.NoError(t, postHandler.CreatePost(c))
+	c, _ = newActorContext(http.MethodPost, "/posts", `{"body": "written by the account holder"}`, holderActor)
+	require.NoError(t, postHandler.CreatePost(c))
+
+	posts, err := p.GetPostPersister().List(0, 10)
+	require.NoError(t, err)
+	require.Len(t, posts, 2)
+	assert.Equal(t, holder.ID, posts[0].CreatedByUserId)
+	assert.Equal(t, guest.ID, posts[0].CreatedBySurrogateId)
+	assert.Equal(t, guest.ID, posts[0].UpdatedBySurrogateId)
+
+	handler := NewGuestActivityHandler(p)
+	c, rec := newActorContext(http.MethodGet, "/users/shares/"+relation.ID.String()+"/activity", "", holderActor)
+	c.SetParamNames("id")
+	c.SetParamValues(relation.ID.String())
+	if assert.NoError(t, handler.Report(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("X-Total-Count"))
+
+		var report dto.GuestActivityReport
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		assert.Equal(t, guest.Email, report.GuestEmail)
+		assert.Equal(t, []string{models.ScopePostsRead, models.ScopePostsWrite}, report.Scopes)
+		if assert.Len(t, report.Activities, 1) {
+			assert.Equal(t, models.GuestActivityCreated, report.Activities[0].Action)
+			assert.Equal(t, models.GuestActivityResourcePost, report.Activities[0].ResourceType)
+			assert.Equal(t, posts[0].ID, report.Activities[0].ResourceId)
+			assert.Equal(t, guest.ID, report.Activities[0].SurrogateUserId)
+		}
+	}
+}
+
+func TestGuestActivityHandler_Report_Forbidden(t *testing.T) {
+	holder := generateUser(t)
+	guest := generateUser(t)
+	relation := newGuestRelation(t)
+	relation.ParentUserID = holder.ID
+	relation.GuestUserID = guest.ID
+	p := test.NewPersister([]models.User{holder, guest}, nil, nil, nil, nil, nil, nil, []models.UserGuestRelation{relation}, nil)
+	handler := NewGuestActivityHandler(p)
+
+	tests := []struct {
+		name     string
+		actor    actor.Actor
+		expected int
+	}{
+		{name: "guest", actor: actor.Actor{PrincipalId: holder.ID, SurrogateId: guest.ID, RelationId: &relation.ID}, expected: http.StatusForbidden},
+		{name: "guest on own account", actor: actor.Actor{PrincipalId: guest.ID, SurrogateId: guest.ID}, expected: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newActorContext(http.MethodGet, "/users/shares/"+relation.ID.String()+"/activity", "", tt.actor)
+			c.SetParamNames("id")
+			c.SetParamValues(relation.ID.String())
+			err := handler.Report(c)
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.expected, dto.ToHttpError(err).Code)
+			}
+		})
+	}
+}
+
+func TestUserHandler_InitiateLoginAsGuest_RecordsActivity(t *testing.T) {
+	relation := newGuestRelation(t)
+	guest := generateUser(t)
+	guest.ID = relation.GuestUserID
+	p := test.NewPersister(append([]models.User{guest}, users...), nil, nil, nil, nil, nil, nil, []models.UserGuestRelation{relation}, nil)
+	handler := NewUserHandler(&defaultConfig, p, sessionManager{})
+
+	c, _ := newContext(http.MethodPost, "/login/guest", `{"relationId": "`+relation.ID.String()+`"}`)
+	c.Set("session", generateJwt(t, guest.ID, guest.ID, 60))
+	require.NoError(t, handler.InitiateLoginAsGuest(c))
+
+	activities, total, err := p.GetGuestActivityPersister().ListByRelation(relation.ID, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	if assert.Len(t, activities, 1) {
+		assert.Equal(t, models.GuestActivityLogin, activities[0].Action)
+		assert.Equal(t, relation.ParentUserID, activities[0].UserId)
+		assert.Equal(t, guest.ID, activities[0].SurrogateUserId)
+	}
+}
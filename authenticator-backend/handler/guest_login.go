@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/teamhanko/hanko/backend/config"
+	hankoJwk "github.com/teamhanko/hanko/backend/crypto/jwk"
+	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/guestlogin"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+type GuestLoginHandler struct {
+	persister persistence.Persister
+	tokens    *purposeTokenVerifier
+	cfg       config.GuestLoginNotifications
+}
+
+// NewGuestLoginHandler creates a handler for the notifications about guest logins. The tokens in the revoke links are
+// signed by the guestlogin.Notifier.
+func NewGuestLoginHandler(cfg *config.Config, persister persistence.Persister, jwkManager hankoJwk.Manager) (*GuestLoginHandler, error) {
+	tokens, err := newPurposeTokenVerifier(jwkManager)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GuestLoginHandler{persister: persister, tokens: tokens, cfg: cfg.GuestLoginNotifications}, nil
+}
+
+// Revoke redeems the token of a notification about a guest login and revokes the guest relation. Revoking a relation
+// twice has no further effect.
+func (h *GuestLoginHandler) Revoke(c echo.Context) error {
+	var body dto.GuestLoginRevokeRequest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	if err := c.Validate(body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	parentUserId, relationId, err := h.tokens.verify(body.Token, guestlogin.PurposeRevoke)
+	if err != nil {
+		return err
+	}
+
+	return h.persister.Transaction(func(tx *pop.Connection) error {
+		err := revokeRelation(c, h.persister, tx, relationId, parentUserId, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	})
+}
+
+// GetNotifications returns the mode of the notifications about guest logins for the acting user
+func (h *GuestLoginHandler) GetNotifications(c echo.Context) error {
+	user, err := h.actingUser(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, dto.GuestLoginNotificationsResponse{Mode: guestlogin.Mode(*user, h.cfg)})
+}
+
+// SetNotifications sets the mode of the notifications about guest logins for the acting user
+func (h *GuestLoginHandler) SetNotifications(c echo.Context) error {
+	var body dto.GuestLoginNotificationsRequest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	if err := c.Validate(body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	valid := false
+	for _, mode := range config.GuestLoginNotificationModes {
+		if body.Mode == mode {
+			valid = true
+		}
+	}
+	if !valid {
+		return dto.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("mode must be one of %v", config.GuestLoginNotificationModes))
+	}
+
+	user, err := h.actingUser(c)
+	if err != nil {
+		return err
+	}
+
+	user.GuestLoginNotifications = body.Mode
+	user.UpdatedAt = time.Now().UTC()
+	err = h.persister.GetUserPersister().Update(*user)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, dto.GuestLoginNotificationsResponse{Mode: user.GuestLoginNotifications})
+}
+
+// actingUser returns the user behind the session, which is the guest in a guest session
+func (h *GuestLoginHandler) actingUser(c echo.Context) (*models.User, error) {
+	sessionToken, ok := c.Get("session").(jwt.Token)
+	if !ok {
+		return nil, errors.New("missing or malformed jwt")
+	}
+
+	surrogateId, err := jwt2.GetSurrogateKeyFromToken(sessionToken)
+	if err != nil {
+		return nil, dto.NewHTTPError(http.StatusUnauthorized).SetInternal(fmt.Errorf("unable to get surrogate ID from token: %w", err))
+	}
+
+	user, err := h.persister.GetUserPersister().Get(uuid.FromStringOrNil(surrogateId))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, dto.NewHTTPError(http.StatusNotFound).SetInternal(errors.New("user not found"))
+	}
+
+	return user, nil
+}
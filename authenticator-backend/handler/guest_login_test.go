@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/crypto/jwk"
+	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/guestlogin"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+func newGuestLoginConfig() config.Config {
+	cfg := defaultConfig
+	cfg.GuestLoginNotifications = config.GuestLoginNotifications{
+		Mode:      config.GuestLoginNotificationsEach,
+		RevokeUrl: "https://example.com/shares/revoke",
+		RevokeTTL: 3600,
+	}
+	return cfg
+}
+
+func newGuestLoginHandler(t *testing.T, p persistence.Persister) *GuestLoginHandler {
+	cfg := newGuestLoginConfig()
+	jwkManager, err := jwk.NewDefaultManager([]string{"needsToBeAtLeast16"}, p.GetJwkPersister())
+	require.NoError(t, err)
+	handler, err := NewGuestLoginHandler(&cfg, p, jwkManager)
+	require.NoError(t, err)
+	return handler
+}
+
+func newGuestRelation(t *testing.T) models.UserGuestRelation {
+	now := time.Now().UTC()
+	return models.UserGuestRelation{
+		ID:             generateUuid(t),
+		GuestUserID:    generateUuid(t),
+		ParentUserID:   uuid.FromStringOrNil(userId),
+		IsActive:       true,
+		ExpireByLogins: true,
+		LoginsAllowed:  sql.NullInt32{Int32: 2, Valid: true},
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+func signGuestRelationToken(t *testing.T, h *GuestLoginHandler, subject string, relationId uuid.UUID, purpose string) string {
+	token := jwt.New()
+	_ = token.Set(jwt.SubjectKey, subject)
+	_ = token.Set(jwt.JwtIDKey, relationId.String())
+	_ = token.Set(jwt.IssuedAtKey, time.Now().UTC())
+	_ = token.Set(jwt.ExpirationKey, time.Now().UTC().Add(time.Hour))
+	_ = token.Set(jwt2.PurposeKey, purpose)
+	signed, err := h.tokens.jwtGenerator.Sign(token)
+	require.NoError(t, err)
+	return string(signed)
+}
+
+func TestGuestLoginHandler_Revoke(t *testing.T) {
+	relation := newGuestRelation(t)
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, []models.UserGuestRelation{relation}, nil)
+	handler := newGuestLoginHandler(t, p)
+	token := signGuestRelationToken(t, handler, userId, relation.ID, guestlogin.PurposeRevoke)
+
+	c, rec := newContext(http.MethodPost, "/users/shares/revoke", `{"token": "`+token+`"}`)
+	if assert.NoError(t, handler.Revoke(c)) {
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		revoked, err := p.GetUserGuestRelationPersister().Get(relation.ID)
+		require.NoError(t, err)
+		assert.False(t, revoked.IsActive)
+
+		events, err := p.GetWebhookPersister().ListUndispatchedEvents(10)
+		require.NoError(t, err)
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, models.WebhookEventRelationRevoked, events[0].Type)
+		}
+	}
+
+	// the link can be opened again
+	c, rec = newContext(http.MethodPost, "/users/shares/revoke", `{"token": "`+token+`"}`)
+	if assert.NoError(t, handler.Revoke(c)) {
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		events, err := p.GetWebhookPersister().ListUndispatchedEvents(10)
+		require.NoError(t, err)
+		assert.Len(t, events, 1)
+	}
+}
+
+func TestGuestLoginHandler_Revoke_InvalidToken(t *testing.T) {
+	relation := newGuestRelation(t)
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, []models.UserGuestRelation{relation}, nil)
+	handler := newGuestLoginHandler(t, p)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "malformed", token: "not-a-token"},
+		{name: "other purpose", token: signGuestRelationToken(t, handler, userId, relation.ID, PurposePasswordReset)},
+		{name: "other user", token: signGuestRelationToken(t, handler, relation.GuestUserID.String(), relation.ID, guestlogin.PurposeRevoke)},
+		{name: "unknown relation", token: signGuestRelationToken(t, handler, userId, generateUuid(t), guestlogin.PurposeRevoke)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newContext(http.MethodPost, "/users/shares/revoke", `{"token": "`+tt.token+`"}`)
+			err := handler.Revoke(c)
+			if assert.Error(t, err) {
+				httpError := dto.ToHttpError(err)
+				assert.Equal(t, http.StatusBadRequest, httpError.Code)
+			}
+		})
+	}
+
+	unchanged, err := p.GetUserGuestRelationPersister().Get(relation.ID)
+	require.NoError(t, err)
+	assert.True(t, unchanged.IsActive)
+}
+
+func TestGuestLoginHandler_Notifications(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	handler := newGuestLoginHandler(t, p)
+	session := generateJwt(t, uuid.FromStringOrNil(userId), uuid.FromStringOrNil(userId), 60)
+
+	c, rec := newContext(http.MethodGet, "/users/shares/notifications", "")
+	c.Set("session", session)
+	if assert.NoError(t, handler.GetNotifications(c)) {
+		response := dto.GuestLoginNotificationsResponse{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, config.GuestLoginNotificationsEach, response.Mode)
+	}
+
+	c, rec = newContext(http.MethodPut, "/users/shares/notifications", `{"mode": "digest"}`)
+	c.Set("session", session)
+	if assert.NoError(t, handler.SetNotifications(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		user, err := p.GetUserPersister().Get(uuid.FromStringOrNil(userId))
+		require.NoError(t, err)
+		assert.Equal(t, config.GuestLoginNotificationsDigest, user.GuestLoginNotifications)
+	}
+
+	c, _ = newContext(http.MethodPut, "/users/shares/notifications", `{"mode": "hourly"}`)
+	c.Set("session", session)
+	err := handler.SetNotifications(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusBadRequest, dto.ToHttpError(err).Code)
+	}
+}
+
+func TestUserHandler_InitiateLoginAsGuest_CreatesNotification(t *testing.T) {
+	relation := newGuestRelation(t)
+	now := time.Now().UTC()
+	guest := models.User{ID: relation.GuestUserID, Email: "guest@example.com", IsActive: true, CreatedAt: now, UpdatedAt: now}
+	p := test.NewPersister(append([]models.User{guest}, users...), nil, nil, nil, nil, nil, nil, []models.UserGuestRelation{relation}, nil)
+	cfg := newGuestLoginConfig()
+	handler := NewUserHandler(&cfg, p, sessionManager{})
+
+	c, rec := newContext(http.MethodPost, "/login/guest", `{"relationId": "`+relation.ID.String()+`"}`)
+	c.Set("session", generateJwt(t, guest.ID, guest.ID, 60))
+	if assert.NoError(t, handler.InitiateLoginAsGuest(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		notifications, err := p.GetGuestLoginNotificationPersister().ListSingle(10)
+		require.NoError(t, err)
+		if assert.Len(t, notifications, 1) {
+			assert.Equal(t, relation.ParentUserID, notifications[0].UserId)
+			assert.Equal(t, guest.ID, notifications[0].GuestUserId)
+			if assert.NotNil(t, notifications[0].LoginsRemaining) {
+				assert.Equal(t, 1, *notifications[0].LoginsRemaining)
+			}
+		}
+	}
+}
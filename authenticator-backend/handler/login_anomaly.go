@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/teamhanko/hanko/backend/anomaly"
+	hankoJwk "github.com/teamhanko/hanko/backend/crypto/jwk"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+type LoginAnomalyHandler struct {
+	persister persistence.Persister
+	tokens    *purposeTokenVerifier
+}
+
+// NewLoginAnomalyHandler creates a handler for the links in the notifications about unusual logins. The tokens are
+// signed by the anomaly.Detector.
+func NewLoginAnomalyHandler(persister persistence.Persister, jwkManager hankoJwk.Manager) (*LoginAnomalyHandler, error) {
+	tokens, err := newPurposeTokenVerifier(jwkManager)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginAnomalyHandler{persister: persister, tokens: tokens}, nil
+}
+
+// Revoke redeems the token of a notification about an unusual login. For a login of the account holder all sessions
+// of the account holder are revoked, single sessions can't be revoked. For a guest login the guest relation is revoked,
+// which ends the sessions of the guest. Revoking an anomaly twice has no further effect.
+func (h *LoginAnomalyHandler) Revoke(c echo.Context) error {
+	var body dto.LoginAnomalyRevokeRequest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	if err := c.Validate(body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	userId, anomalyId, err := h.tokens.verify(body.Token, anomaly.PurposeRevoke)
+	if err != nil {
+		return err
+	}
+
+	return h.persister.Transaction(func(tx *pop.Connection) error {
+		anomalyPersister := h.persister.GetLoginAnomalyPersisterWithConnection(tx)
+		loginAnomaly, err := anomalyPersister.Get(anomalyId)
+		if err != nil {
+			return fmt.Errorf("failed to get login anomaly: %w", err)
+		}
+		if loginAnomaly == nil || loginAnomaly.UserId != userId {
+			return dto.NewHTTPError(http.StatusBadRequest, "invalid token").SetInternal(errors.New("login anomaly not found"))
+		}
+		if loginAnomaly.RevokedAt != nil {
+			return c.NoContent(http.StatusNoContent)
+		}
+
+		now := time.Now().UTC()
+		if loginAnomaly.IsGuestLogin() {
+			err = revokeRelation(c, h.persister, tx, *loginAnomaly.UserGuestRelationId, loginAnomaly.UserId, now)
+		} else {
+			err = h.revokeSessions(c, tx, loginAnomaly.UserId, now)
+		}
+		if err != nil {
+			return err
+		}
+
+		loginAnomaly.RevokedAt = &now
+		loginAnomaly.UpdatedAt = now
+		err = anomalyPersister.Update(*loginAnomaly)
+		if err != nil {
+			return fmt.Errorf("failed to update login anomaly: %w", err)
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	})
+}
+
+func (h *LoginAnomalyHandler) revokeSessions(c echo.Context, tx *pop.Connection, userId uuid.UUID, now time.Time) error {
+	userPersister := h.persister.GetUserPersisterWithConnection(tx)
+	user, err := userPersister.Get(userId)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return dto.NewHTTPError(http.StatusBadRequest, "invalid token").SetInternal(errors.New("user not found"))
+	}
+
+	user.SessionsRevokedAt = &now
+	user.UpdatedAt = now
+	err = userPersister.Update(*user)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	// there is no session, the token proves that the account holder is the actor
+	event := newSecurityEvent(c, models.EventSessionsRevoked, &user.ID)
+	event.ActorUserId = &user.ID
+	err = h.persister.GetSecurityEventPersisterWithConnection(tx).Create(event)
+	if err != nil {
+		return fmt.Errorf("failed to create security event: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/anomaly"
+	"github.com/teamhanko/hanko/backend/crypto/jwk"
+	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+func newLoginAnomalyHandler(t *testing.T, p persistence.Persister) *LoginAnomalyHandler {
+	jwkManager, err := jwk.NewDefaultManager([]string{"needsToBeAtLeast16"}, p.GetJwkPersister())
+	require.NoError(t, err)
+	handler, err := NewLoginAnomalyHandler(p, jwkManager)
+	require.NoError(t, err)
+	return handler
+}
+
+func createLoginAnomaly(t *testing.T, p persistence.Persister, relation *models.UserGuestRelation) models.LoginAnomaly {
+	now := time.Now().UTC()
+	log := models.LoginAuditLog{ID: generateUuid(t), UserId: uuid.FromStringOrNil(userId), ClientIpAddress: "198.51.100.1", ClientUserAgent: "curl/7.85.0", CreatedAt: now}
+	if relation != nil {
+		log.SurrogateUserId = &relation.GuestUserID
+		log.UserGuestRelationId = &relation.ID
+	}
+	loginAnomaly := models.NewLoginAnomaly(log, []string{models.LoginAnomalyNewDevice}, now)
+	require.NoError(t, p.GetLoginAnomalyPersister().Create(loginAnomaly))
+	return loginAnomaly
+}
+
+func signLoginAnomalyToken(t *testing.T, h *LoginAnomalyHandler, subject string, anomalyId uuid.UUID, purpose string) string {
+	token := jwt.New()
+	_ = token.Set(jwt.SubjectKey, subject)
+	_ = token.Set(jwt.JwtIDKey, anomalyId.String())
+	_ = token.Set(jwt.IssuedAtKey, time.Now().UTC())
+	_ = token.Set(jwt.ExpirationKey, time.Now().UTC().Add(time.Hour))
+	_ = token.Set(jwt2.PurposeKey, purpose)
+	signed, err := h.tokens.jwtGenerator.Sign(token)
+	require.NoError(t, err)
+	return string(signed)
+}
+
+func newLoginAnomalyRevokeContext(token string) (echo.Context, *httptest.ResponseRecorder) {
+	return newContext(http.MethodPost, "/login/anomalies/revoke", `{"token": "`+token+`"}`)
+}
+
+func TestLoginAnomalyHandler_Revoke(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	handler := newLoginAnomalyHandler(t, p)
+	loginAnomaly := createLoginAnomaly(t, p, nil)
+	token := signLoginAnomalyToken(t, handler, userId, loginAnomaly.ID, anomaly.PurposeRevoke)
+
+	c, rec := newLoginAnomalyRevokeContext(token)
+	if assert.NoError(t, handler.Revoke(c)) {
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		user, err := p.GetUserPersister().Get(uuid.FromStringOrNil(userId))
+		require.NoError(t, err)
+		assert.NotNil(t, user.SessionsRevokedAt)
+
+		revoked, err := p.GetLoginAnomalyPersister().Get(loginAnomaly.ID)
+		require.NoError(t, err)
+		assert.NotNil(t, revoked.RevokedAt)
+
+		events, _, err := p.GetSecurityEventPersister().Search(persistence.SecurityEventFilter{Types: []string{models.EventSessionsRevoked}}, 1, 10)
+		require.NoError(t, err)
+		assert.Len(t, events, 1)
+	}
+
+	// the link can be opened again
+	c, rec = newLoginAnomalyRevokeContext(token)
+	if assert.NoError(t, handler.Revoke(c)) {
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	}
+}
+
+func TestLoginAnomalyHandler_Revoke_Guest(t *testing.T) {
+	now := time.Now().UTC()
+	relation := models.UserGuestRelation{
+		ID:           generateUuid(t),
+		GuestUserID:  generateUuid(t),
+		ParentUserID: uuid.FromStringOrNil(userId),
+		IsActive:     true,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, []models.UserGuestRelation{relation}, nil)
+	handler := newLoginAnomalyHandler(t, p)
+	loginAnomaly := createLoginAnomaly(t, p, &relation)
+	token := signLoginAnomalyToken(t, handler, userId, loginAnomaly.ID, anomaly.PurposeRevoke)
+
+	c, rec := newLoginAnomalyRevokeContext(token)
+	if assert.NoError(t, handler.Revoke(c)) {
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		revoked, err := p.GetUserGuestRelationPersister().Get(relation.ID)
+		require.NoError(t, err)
+		assert.False(t, revoked.IsActive)
+
+		user, err := p.GetUserPersister().Get(uuid.FromStringOrNil(userId))
+		require.NoError(t, err)
+		assert.Nil(t, user.SessionsRevokedAt)
+
+		events, err := p.GetWebhookPersister().ListUndispatchedEvents(10)
+		require.NoError(t, err)
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, models.WebhookEventRelationRevoked, events[0].Type)
+		}
+	}
+}
+
+func TestLoginAnomalyHandler_Revoke_InvalidToken(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	handler := newLoginAnomalyHandler(t, p)
+	loginAnomaly := createLoginAnomaly(t, p, nil)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "malformed", token: "not-a-token"},
+		{name: "other purpose", token: signLoginAnomalyToken(t, handler, userId, loginAnomaly.ID, PurposePasswordReset)},
+		{name: "other user", token: signLoginAnomalyToken(t, handler, generateUuid(t).String(), loginAnomaly.ID, anomaly.PurposeRevoke)},
+		{name: "unknown anomaly", token: signLoginAnomalyToken(t, handler, userId, generateUuid(t), anomaly.PurposeRevoke)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newLoginAnomalyRevokeContext(tt.token)
+			err := handler.Revoke(c)
+			if assert.Error(t, err) {
+				httpError := dto.ToHttpError(err)
+				assert.Equal(t, http.StatusBadRequest, httpError.Code)
+			}
+		})
+	}
+
+	user, err := p.GetUserPersister().Get(uuid.FromStringOrNil(userId))
+	require.NoError(t, err)
+	assert.Nil(t, user.SessionsRevokedAt)
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultPerPage = 20
+	// maxPerPage bounds the page size a client may ask for, larger pages are cut to it
+	maxPerPage = 100
+)
+
+// normalizePagination applies the same defaults to the page and the page size as the persisters do and caps the page
+// size
+func normalizePagination(page int, perPage int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	return page, perPage
+}
+
+// setPaginationHeaders sets the 'X-Total-Count' header and the 'Link' header with the links to the first, previous,
+// next and last page (see https://docs.github.com/en/rest/guides/traversing-with-pagination). The links keep all
+// other query parameters of the request.
+func setPaginationHeaders(c echo.Context, page int, perPage int, total int) {
+	lastPage := (total + perPage - 1) / perPage
+	if lastPage < 1 {
+		lastPage = 1
+	}
+
+	link := func(page int, rel string) string {
+		query := c.Request().URL.Query()
+		query.Set("page", strconv.Itoa(page))
+		query.Set("per_page", strconv.Itoa(perPage))
+		u := url.URL{Path: c.Request().URL.Path, RawQuery: query.Encode()}
+		return fmt.Sprintf("<%s>; rel=\"%s\"", u.String(), rel)
+	}
+
+	links := []string{link(1, "first")}
+	if page > 1 {
+		// a page behind the last page links back to the last page
+		prev := page - 1
+		if prev > lastPage {
+			prev = lastPage
+		}
+		links = append(links, link(prev, "prev"))
+	}
+	if page < lastPage {
+		links = append(links, link(page+1, "next"))
+	}
+	links = append(links, link(lastPage, "last"))
+
+	c.Response().Header().Set("X-Total-Count", strconv.Itoa(total))
+	c.Response().Header().Set("Link", strings.Join(links, ", "))
+}
+
+// setCursorPaginationHeaders sets the 'Link' header with the link to the next page of a list which is paginated with
+// a cursor. The link keeps all other query parameters of the request.
+func setCursorPaginationHeaders(c echo.Context, perPage int, next string) {
+	query := c.Request().URL.Query()
+	query.Set("cursor", next)
+	query.Set("per_page", strconv.Itoa(perPage))
+	u := url.URL{Path: c.Request().URL.Path, RawQuery: query.Encode()}
+	c.Response().Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", u.String()))
+}
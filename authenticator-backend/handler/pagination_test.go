@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizePagination(t *testing.T) {
+	tests := []struct {
+		name            string
+		page, perPage   int
+		wantPage, wants int
+	}{
+		{name: "defaults", page: 0, perPage: 0, wantPage: 1, wants: defaultPerPage},
+		{name: "given", page: 3, perPage: 50, wantPage: 3, wants: 50},
+		{name: "capped", page: 1, perPage: 10000000, wantPage: 1, wants: maxPerPage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, perPage := normalizePagination(tt.page, tt.perPage)
+			assert.Equal(t, tt.wantPage, page)
+			assert.Equal(t, tt.wants, perPage)
+		})
+	}
+}
@@ -3,7 +3,10 @@ package handler
 import (
 	"errors"
 	"fmt"
+	"html/template"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gobuffalo/pop/v6"
@@ -15,8 +18,8 @@ import (
 	"github.com/teamhanko/hanko/backend/mail"
 	"github.com/teamhanko/hanko/backend/persistence"
 	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/ratelimit"
 	"github.com/teamhanko/hanko/backend/session"
-	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/gomail.v2"
 )
 
@@ -24,16 +27,23 @@ type PasscodeHandler struct {
 	mailer            mail.Mailer
 	renderer          *mail.Renderer
 	passcodeGenerator crypto.PasscodeGenerator
+	nanoidGenerator   crypto.NanoidGenerator
+	hasher            crypto.Hasher
 	persister         persistence.Persister
 	emailConfig       config.Email
 	serviceConfig     config.Service
 	TTL               int
 	sessionManager    session.Manager
 	cfg               *config.Config
+	initLimiter       *ratelimit.Limiter
+	finishLimiter     *ratelimit.Limiter
 }
 
 var maxPasscodeTries = 3
 
+// deviceBindingCookieName is the name of the cookie binding a magic link to the browser which requested it
+const deviceBindingCookieName = "hanko_device"
+
 func NewPasscodeHandler(cfg *config.Config, persister persistence.Persister, sessionManager session.Manager, mailer mail.Mailer) (*PasscodeHandler, error) {
 	renderer, err := mail.NewRenderer()
 	if err != nil {
@@ -43,12 +53,16 @@ func NewPasscodeHandler(cfg *config.Config, persister persistence.Persister, ses
 		mailer:            mailer,
 		renderer:          renderer,
 		passcodeGenerator: crypto.NewPasscodeGenerator(),
+		nanoidGenerator:   crypto.NewNanoidGenerator(),
+		hasher:            crypto.NewTokenHasher(cfg.Secrets.Keys, cfg.Hashing),
 		persister:         persister,
 		emailConfig:       cfg.Passcode.Email,
 		serviceConfig:     cfg.Service,
 		TTL:               cfg.Passcode.TTL,
 		sessionManager:    sessionManager,
 		cfg:               cfg,
+		initLimiter:       ratelimit.New(cfg.RateLimit, "passcode_init", cfg.RateLimit.PasscodeInit, persister),
+		finishLimiter:     ratelimit.New(cfg.RateLimit, "passcode_finish", cfg.RateLimit.PasscodeFinish, persister),
 	}, nil
 }
 
@@ -67,6 +81,11 @@ func (h *PasscodeHandler) Init(c echo.Context) error {
 		return dto.NewHTTPError(http.StatusBadRequest, "failed to parse userId as uuid").SetInternal(err)
 	}
 
+	rateLimitKeys := []string{userRateLimitKey(userId), ipRateLimitKey(c)}
+	if err := checkRateLimit(c, h.initLimiter, rateLimitKeys...); err != nil {
+		return err
+	}
+
 	user, err := h.persister.GetUserPersister().Get(userId)
 	if err != nil {
 		return fmt.Errorf("failed to get user: %w", err)
@@ -75,9 +94,24 @@ func (h *PasscodeHandler) Init(c echo.Context) error {
 		return dto.NewHTTPError(http.StatusBadRequest).SetInternal(errors.New("user not found"))
 	}
 
-	passcode, err := h.passcodeGenerator.Generate()
-	if err != nil {
-		return fmt.Errorf("failed to generate passcode: %w", err)
+	// the passcode is sent to the address that was entered, which has to be one of the addresses of the user
+	address := user.Email
+	if body.Email != "" {
+		address = strings.ToLower(body.Email)
+		if address != user.Email {
+			email, err := h.persister.GetEmailPersister().GetByAddress(address)
+			if err != nil {
+				return fmt.Errorf("failed to get email: %w", err)
+			}
+			if email == nil || email.UserId != user.ID {
+				return dto.NewHTTPError(http.StatusBadRequest, "email address does not belong to the user")
+			}
+		}
+	}
+
+	// every passcode sent counts, so the inbox of the user can't be flooded
+	if err := h.initLimiter.Register(rateLimitKeys...); err != nil {
+		return err
 	}
 
 	passcodeId, err := uuid.NewV4()
@@ -85,50 +119,46 @@ func (h *PasscodeHandler) Init(c echo.Context) error {
 		return fmt.Errorf("failed to create passcodeId: %w", err)
 	}
 	now := time.Now().UTC()
-	hashedPasscode, err := bcrypt.GenerateFromPassword([]byte(passcode), 12)
-	if err != nil {
-		return fmt.Errorf("failed to hash passcode: %w", err)
-	}
 	passcodeModel := models.Passcode{
 		ID:        passcodeId,
 		UserId:    userId,
 		Ttl:       h.TTL,
-		Code:      string(hashedPasscode),
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
-
-	err = h.persister.GetPasscodePersister().Create(passcodeModel)
-	if err != nil {
-		return fmt.Errorf("failed to store passcode: %w", err)
+	if address != user.Email {
+		passcodeModel.Email = &address
 	}
 
-	durationTTL := time.Duration(h.TTL) * time.Second
-	data := map[string]interface{}{
-		"Code":        passcode,
-		"ServiceName": h.serviceConfig.Name,
-		"TTL":         fmt.Sprintf("%.0f", durationTTL.Minutes()),
+	lang := c.Request().Header.Get("Accept-Language")
+	var message *gomail.Message
+	var deviceBinding *http.Cookie
+	if h.cfg.Passcode.Mode == config.PasscodeModeLink {
+		message, deviceBinding, err = h.linkMessage(lang, &passcodeModel)
+	} else {
+		message, err = h.codeMessage(lang, &passcodeModel)
+	}
+	if err != nil {
+		return err
 	}
 
-	lang := c.Request().Header.Get("Accept-Language")
-	str, err := h.renderer.Render("loginTextMail", lang, data)
+	err = h.persister.GetPasscodePersister().Create(passcodeModel)
 	if err != nil {
-		return fmt.Errorf("failed to render email template: %w", err)
+		return fmt.Errorf("failed to store passcode: %w", err)
 	}
 
-	message := gomail.NewMessage()
-	message.SetAddressHeader("To", user.Email, "")
+	message.SetAddressHeader("To", address, "")
 	message.SetAddressHeader("From", h.emailConfig.FromAddress, h.emailConfig.FromName)
 
-	message.SetHeader("Subject", h.renderer.Translate(lang, "email_subject_login", data))
-
-	message.SetBody("text/plain", str)
-
 	err = h.mailer.Send(message)
 	if err != nil {
 		return fmt.Errorf("failed to send passcode: %w", err)
 	}
 
+	if deviceBinding != nil {
+		c.SetCookie(deviceBinding)
+	}
+
 	return c.JSON(http.StatusOK, dto.PasscodeReturn{
 		Id:        passcodeId.String(),
 		TTL:       h.TTL,
@@ -152,6 +182,10 @@ func (h *PasscodeHandler) Finish(c echo.Context) error {
 		return dto.NewHTTPError(http.StatusBadRequest, "failed to parse passcodeId as uuid").SetInternal(err)
 	}
 
+	if err := checkRateLimit(c, h.finishLimiter, ipRateLimitKey(c)); err != nil {
+		return err
+	}
+
 	// only if an internal server occurs the transaction should be rolled back
 	var businessError error
 	transactionError := h.persister.Transaction(func(tx *pop.Connection) error {
@@ -162,18 +196,30 @@ func (h *PasscodeHandler) Finish(c echo.Context) error {
 			return fmt.Errorf("failed to get passcode: %w", err)
 		}
 		if passcode == nil {
+			if err := h.finishLimiter.Register(ipRateLimitKey(c)); err != nil {
+				return err
+			}
 			businessError = dto.NewHTTPError(http.StatusNotFound, "passcode not found")
 			return nil
 		}
 
+		rateLimitKeys := []string{userRateLimitKey(passcode.UserId), ipRateLimitKey(c)}
+		if err := checkRateLimit(c, h.finishLimiter, rateLimitKeys...); err != nil {
+			businessError = err
+			return nil
+		}
+
 		lastVerificationTime := passcode.CreatedAt.Add(time.Duration(passcode.Ttl) * time.Second)
 		if lastVerificationTime.Before(startTime) {
 			businessError = dto.NewHTTPError(http.StatusRequestTimeout, "passcode request timed out").SetInternal(fmt.Errorf("createdAt: %s -> lastVerificationTime: %s", passcode.CreatedAt, lastVerificationTime)) // TODO: maybe we should use BadRequest, because RequestTimeout might be to technical and can refer to different error
 			return nil
 		}
 
-		err = bcrypt.CompareHashAndPassword([]byte(passcode.Code), []byte(body.Code))
+		valid, err := h.verify(c, passcode, body)
 		if err != nil {
+			return fmt.Errorf("failed to verify passcode: %w", err)
+		}
+		if !valid {
 			passcode.TryCount = passcode.TryCount + 1
 
 			if passcode.TryCount >= maxPasscodeTries {
@@ -190,10 +236,21 @@ func (h *PasscodeHandler) Finish(c echo.Context) error {
 				return fmt.Errorf("failed to update passcode: %w", err)
 			}
 
+			if err := h.finishLimiter.Register(rateLimitKeys...); err != nil {
+				return err
+			}
+
 			businessError = dto.NewHTTPError(http.StatusUnauthorized).SetInternal(errors.New("passcode invalid"))
 			return nil
 		}
 
+		if err := h.finishLimiter.Reset(userRateLimitKey(passcode.UserId)); err != nil {
+			return err
+		}
+		if err := h.initLimiter.Reset(userRateLimitKey(passcode.UserId)); err != nil {
+			return err
+		}
+
 		err = passcodePersister.Delete(*passcode)
 		if err != nil {
 			return fmt.Errorf("failed to delete passcode: %w", err)
@@ -204,15 +261,34 @@ func (h *PasscodeHandler) Finish(c echo.Context) error {
 			return fmt.Errorf("failed to get user: %w", err)
 		}
 
-		if !user.Verified {
-			user.Verified = true
+		// entering the passcode proves access to the address it was sent to
+		address := user.Email
+		if passcode.Email != nil {
+			address = *passcode.Email
+		}
+		wasVerified := user.Verified
+		err = markEmailVerified(h.persister.GetEmailPersisterWithConnection(tx), user, address)
+		if err != nil {
+			return err
+		}
+		if user.Verified != wasVerified {
 			err = userPersister.Update(*user)
 			if err != nil {
 				return fmt.Errorf("failed to update user: %w", err)
 			}
 		}
 
-		token, err := h.sessionManager.GenerateJWT(passcode.UserId, passcode.UserId, uuid.Nil)
+		secondFactor, err := secondFactorStatus(h.persister, h.cfg, passcode.UserId)
+		if err != nil {
+			return err
+		}
+
+		var sessionOptions []session.Option
+		if secondFactor != nil {
+			sessionOptions = append(sessionOptions, session.WithRestriction(session.RestrictionSecondFactor), session.WithLifespan(secondFactorLifespan(h.cfg)))
+		}
+
+		token, err := h.sessionManager.GenerateJWT(passcode.UserId, passcode.UserId, uuid.Nil, sessionOptions...)
 		if err != nil {
 			return fmt.Errorf("failed to generate jwt: %w", err)
 		}
@@ -224,7 +300,7 @@ func (h *PasscodeHandler) Finish(c echo.Context) error {
 
 		log := models.LoginAuditLog{
 			UserId:          passcode.UserId,
-			ClientIpAddress: c.Request().RemoteAddr,
+			ClientIpAddress: c.RealIP(),
 			ClientUserAgent: c.Request().UserAgent(),
 			LoginMethod:     dto.LoginMethodToValue(dto.Passcode),
 		}
@@ -234,15 +310,19 @@ func (h *PasscodeHandler) Finish(c echo.Context) error {
 		}
 
 		c.SetCookie(cookie)
+		if passcode.Token != nil {
+			c.SetCookie(h.deviceBindingCookie("", -1))
+		}
 
 		if h.cfg.Session.EnableAuthTokenHeader {
 			c.Response().Header().Set("X-Auth-Token", token)
 		}
 
 		return c.JSON(http.StatusOK, dto.PasscodeReturn{
-			Id:        passcode.ID.String(),
-			TTL:       100000000, //passcode.Ttl,
-			CreatedAt: passcode.CreatedAt,
+			Id:           passcode.ID.String(),
+			TTL:          100000000, //passcode.Ttl,
+			CreatedAt:    passcode.CreatedAt,
+			SecondFactor: secondFactor,
 		})
 	})
 
@@ -252,3 +332,131 @@ func (h *PasscodeHandler) Finish(c echo.Context) error {
 
 	return transactionError
 }
+
+// codeMessage generates a six-digit passcode, stores its hash in the passcode and renders the email containing it
+func (h *PasscodeHandler) codeMessage(lang string, passcode *models.Passcode) (*gomail.Message, error) {
+	code, err := h.passcodeGenerator.Generate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate passcode: %w", err)
+	}
+	hashedCode, err := h.hasher.Hash(code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash passcode: %w", err)
+	}
+	passcode.Code = hashedCode
+
+	durationTTL := time.Duration(passcode.Ttl) * time.Second
+	data := map[string]interface{}{
+		"Code":        code,
+		"ServiceName": h.serviceConfig.Name,
+		"TTL":         fmt.Sprintf("%.0f", durationTTL.Minutes()),
+	}
+
+	str, err := h.renderer.Render("loginTextMail", lang, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	message := gomail.NewMessage()
+	message.SetHeader("Subject", h.renderer.Translate(lang, "email_subject_login", data))
+	message.SetBody("text/plain", str)
+
+	return message, nil
+}
+
+// linkMessage generates the token of a magic link and a device binding, stores their hashes in the passcode and
+// renders the email containing the link. The returned cookie carries the device binding, so the link only works in the
+// browser which requested it.
+func (h *PasscodeHandler) linkMessage(lang string, passcode *models.Passcode) (*gomail.Message, *http.Cookie, error) {
+	token, err := h.nanoidGenerator.Generate()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate link token: %w", err)
+	}
+	hashedToken, err := h.hasher.Hash(token)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to hash link token: %w", err)
+	}
+
+	binding, err := h.nanoidGenerator.Generate()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate device binding: %w", err)
+	}
+	hashedBinding, err := h.hasher.Hash(binding)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to hash device binding: %w", err)
+	}
+
+	passcode.Token = &hashedToken
+	passcode.DeviceBinding = &hashedBinding
+
+	link, err := url.Parse(h.cfg.Passcode.Link.Url)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse passcode link url: %w", err)
+	}
+	query := link.Query()
+	query.Set("id", passcode.ID.String())
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	durationTTL := time.Duration(passcode.Ttl) * time.Second
+	data := map[string]interface{}{
+		// the link is built from the configuration and generated values only, marking it as safe keeps the "&" of the
+		// query unescaped in the text email
+		"Link":        template.HTML(link.String()),
+		"ServiceName": h.serviceConfig.Name,
+		"TTL":         fmt.Sprintf("%.0f", durationTTL.Minutes()),
+	}
+
+	text, err := h.renderer.Render("loginLinkTextMail", lang, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to render email template: %w", err)
+	}
+	html, err := h.renderer.Render("loginLinkHtmlMail", lang, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	message := gomail.NewMessage()
+	message.SetHeader("Subject", h.renderer.Translate(lang, "email_subject_login", data))
+	message.SetBody("text/plain", text)
+	message.AddAlternative("text/html", html)
+
+	return message, h.deviceBindingCookie(binding, passcode.Ttl), nil
+}
+
+// verify checks the code or, for passcodes sent as link, the link token and the device binding
+func (h *PasscodeHandler) verify(c echo.Context, passcode *models.Passcode, body dto.PasscodeFinishRequest) (bool, error) {
+	if passcode.Token == nil {
+		if body.Code == "" {
+			return false, nil
+		}
+		return h.hasher.Verify(passcode.Code, body.Code)
+	}
+
+	if body.Token == "" || passcode.DeviceBinding == nil {
+		return false, nil
+	}
+	valid, err := h.hasher.Verify(*passcode.Token, body.Token)
+	if err != nil || !valid {
+		return false, err
+	}
+
+	cookie, err := c.Cookie(deviceBindingCookieName)
+	if err != nil {
+		return false, nil
+	}
+	return h.hasher.Verify(*passcode.DeviceBinding, cookie.Value)
+}
+
+func (h *PasscodeHandler) deviceBindingCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     deviceBindingCookieName,
+		Value:    value,
+		Path:     "/",
+		Domain:   h.cfg.Session.Cookie.Domain,
+		MaxAge:   maxAge,
+		Secure:   h.cfg.Session.Cookie.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
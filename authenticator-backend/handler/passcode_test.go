@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/crypto"
 	"github.com/teamhanko/hanko/backend/dto"
 	"github.com/teamhanko/hanko/backend/persistence/models"
 	"github.com/teamhanko/hanko/backend/test"
@@ -179,6 +180,148 @@ func TestPasscodeHandler_Finish_WrongId(t *testing.T) {
 	}
 }
 
+func TestPasscodeHandler_Init_LinkMode(t *testing.T) {
+	cfg := &config.Config{Passcode: config.Passcode{
+		TTL:  300,
+		Mode: config.PasscodeModeLink,
+		Link: config.PasscodeLink{Url: "https://example.com/#/login/link"},
+	}}
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	m := &recordingMailer{}
+	passcodeHandler, err := NewPasscodeHandler(cfg, p, sessionManager{}, m)
+	require.NoError(t, err)
+
+	bodyJson, err := json.Marshal(dto.PasscodeInitRequest{UserId: userId})
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	req := httptest.NewRequest(http.MethodPost, "/passcode/login/initialize", bytes.NewReader(bodyJson))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, passcodeHandler.Init(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response dto.PasscodeReturn
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		passcode, err := p.GetPasscodePersister().Get(uuid.FromStringOrNil(response.Id))
+		require.NoError(t, err)
+		if assert.NotNil(t, passcode) {
+			assert.NotNil(t, passcode.Token)
+			assert.NotNil(t, passcode.DeviceBinding)
+			assert.Empty(t, passcode.Code)
+		}
+
+		cookies := rec.Result().Cookies()
+		if assert.Len(t, cookies, 1) {
+			assert.Equal(t, deviceBindingCookieName, cookies[0].Name)
+			assert.True(t, cookies[0].HttpOnly)
+		}
+
+		if assert.Len(t, m.messages, 1) {
+			buf := &bytes.Buffer{}
+			_, err = m.messages[0].WriteTo(buf)
+			require.NoError(t, err)
+			assert.Contains(t, buf.String(), "text/html")
+			assert.Contains(t, buf.String(), "text/plain")
+		}
+	}
+}
+
+func TestPasscodeHandler_Finish_Link(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		code          string
+		deviceBinding string
+		expectedCode  int
+	}{
+		{
+			name:          "valid token in requesting browser",
+			token:         "link-token",
+			deviceBinding: "device-binding",
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:         "valid token in another browser",
+			token:        "link-token",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:          "valid token with wrong device binding",
+			token:         "link-token",
+			deviceBinding: "another-device-binding",
+			expectedCode:  http.StatusUnauthorized,
+		},
+		{
+			name:          "wrong token",
+			token:         "wrong-token",
+			deviceBinding: "device-binding",
+			expectedCode:  http.StatusUnauthorized,
+		},
+		{
+			name:          "code instead of token",
+			code:          "123456",
+			deviceBinding: "device-binding",
+			expectedCode:  http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passcodeHandler, err := NewPasscodeHandler(&config.Config{}, test.NewPersister(users, []models.Passcode{linkPasscode(t)}, nil, nil, nil, nil, nil, nil, nil), sessionManager{}, mailer{})
+			require.NoError(t, err)
+
+			bodyJson, err := json.Marshal(dto.PasscodeFinishRequest{
+				Id:    "6a4b4e11-1a4e-4d3a-a5b6-7a0ee0b1c4c3",
+				Code:  tt.code,
+				Token: tt.token,
+			})
+			require.NoError(t, err)
+
+			e := echo.New()
+			e.Validator = dto.NewCustomValidator()
+			req := httptest.NewRequest(http.MethodPost, "/passcode/login/finalize", bytes.NewReader(bodyJson))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.deviceBinding != "" {
+				req.AddCookie(&http.Cookie{Name: deviceBindingCookieName, Value: tt.deviceBinding})
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err = passcodeHandler.Finish(c)
+			if tt.expectedCode == http.StatusOK {
+				if assert.NoError(t, err) {
+					assert.Equal(t, http.StatusOK, rec.Code)
+				}
+			} else if assert.Error(t, err) {
+				assert.Equal(t, tt.expectedCode, dto.ToHttpError(err).Code)
+			}
+		})
+	}
+}
+
+func linkPasscode(t *testing.T) models.Passcode {
+	hasher := crypto.NewBcryptHasher(4)
+	token, err := hasher.Hash("link-token")
+	require.NoError(t, err)
+	binding, err := hasher.Hash("device-binding")
+	require.NoError(t, err)
+
+	now := time.Now()
+	return models.Passcode{
+		ID:            uuid.FromStringOrNil("6a4b4e11-1a4e-4d3a-a5b6-7a0ee0b1c4c3"),
+		UserId:        uuid.FromStringOrNil(userId),
+		Ttl:           300,
+		Token:         &token,
+		DeviceBinding: &binding,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
 func passcodes() []models.Passcode {
 	now := time.Now()
 	return []models.Passcode{{
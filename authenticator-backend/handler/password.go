@@ -4,31 +4,44 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"unicode/utf8"
+	"time"
 
 	"github.com/gobuffalo/pop/v6"
 	"github.com/gofrs/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/crypto"
+	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
 	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/password"
 	"github.com/teamhanko/hanko/backend/persistence"
 	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/ratelimit"
 	"github.com/teamhanko/hanko/backend/session"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type PasswordHandler struct {
 	persister      persistence.Persister
 	sessionManager session.Manager
 	cfg            *config.Config
+	policy         *password.Policy
+	hasher         crypto.Hasher
+	limiter        *ratelimit.Limiter
+	stepUpMaxAge   time.Duration
 }
 
 func NewPasswordHandler(persister persistence.Persister, sessionManager session.Manager, cfg *config.Config) *PasswordHandler {
+	hasher := crypto.NewHasher(cfg.Hashing)
+	stepUpMaxAge, _ := time.ParseDuration(cfg.Session.StepUp.MaxAge) // error can be ignored, value is checked in config validation
 	return &PasswordHandler{
 		persister:      persister,
 		sessionManager: sessionManager,
 		cfg:            cfg,
+		policy:         password.NewPolicy(cfg.Password, cfg.Service.Name, hasher.MaxLength()),
+		hasher:         hasher,
+		limiter:        ratelimit.New(cfg.RateLimit, "password_login", cfg.RateLimit.PasswordLogin, persister),
+		stepUpMaxAge:   stepUpMaxAge,
 	}
 }
 
@@ -37,6 +50,9 @@ type PasswordSetBody struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// Set sets the password of the user. Replacing an existing password requires a step-up authentication, setting the
+// first password, e.g. right after a registration with a passcode, does not, as such users have no passkey to step up
+// with.
 func (h *PasswordHandler) Set(c echo.Context) error {
 	var body PasswordSetBody
 	if err := (&echo.DefaultBinder{}).BindBody(c, &body); err != nil {
@@ -57,12 +73,8 @@ func (h *PasswordHandler) Set(c echo.Context) error {
 		return dto.NewHTTPError(http.StatusBadRequest, "failed to parse userId as uuid").SetInternal(err)
 	}
 
-	pwBytes := []byte(body.Password)
-	if utf8.RuneCountInString(body.Password) < h.cfg.Password.MinPasswordLength { // use utf8.RuneCountInString, so utf8 characters would count as 1
-		return dto.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("password must be at least %d characters long", h.cfg.Password.MinPasswordLength))
-	}
-	if len(pwBytes) > 72 {
-		return dto.NewHTTPError(http.StatusBadRequest, "password must not be longer than 72 bytes")
+	if surrogateId, err := jwt2.GetSurrogateKeyFromToken(sessionToken); err == nil && surrogateId != sessionToken.Subject() {
+		return dto.NewHTTPError(http.StatusForbidden).SetInternal(fmt.Errorf("guest %s tried to set the password of user %s", surrogateId, sessionUserId))
 	}
 
 	return h.persister.Transaction(func(tx *pop.Connection) error {
@@ -79,38 +91,58 @@ func (h *PasswordHandler) Set(c echo.Context) error {
 			return dto.NewHTTPError(http.StatusForbidden).SetInternal(fmt.Errorf("session.userId %s tried to set password credentials for body.userId %s", sessionUserId, user.ID))
 		}
 
+		violations, err := h.policy.Check(body.Password, user.Email)
+		if err != nil {
+			return err
+		}
+
+		if len(violations) > 0 {
+			return dto.NewHTTPError(http.StatusBadRequest, "password does not satisfy the password policy").SetDetails(violations)
+		}
+
 		pwPersister := h.persister.GetPasswordCredentialPersisterWithConnection(tx)
 		pw, err := pwPersister.GetByUserID(user.ID)
 		if err != nil {
 			return fmt.Errorf("failed to get credential: %w", err)
 		}
 
-		hashedPassword, err := bcrypt.GenerateFromPassword(pwBytes, 12)
+		if pw != nil {
+			if err := session.VerifyStepUp(sessionToken, h.stepUpMaxAge); err != nil {
+				return dto.NewHTTPError(http.StatusForbidden, "step-up authentication required").SetInternal(err)
+			}
+		}
+
+		hashedPassword, err := h.hasher.Hash(body.Password)
 		if err != nil {
 			return fmt.Errorf("failed to hash password: %s", err)
 		}
 
 		newPw := models.PasswordCredential{
 			UserId:   uuid.FromStringOrNil(body.UserID),
-			Password: string(hashedPassword),
+			Password: hashedPassword,
 		}
 
+		status := http.StatusOK
 		if pw == nil {
 			err = pwPersister.Create(newPw)
 			if err != nil {
 				return fmt.Errorf("failed to create password: %w", err)
-			} else {
-				return c.JSON(http.StatusCreated, nil)
 			}
+			status = http.StatusCreated
 		} else {
 			newPw.ID = pw.ID
 			err = pwPersister.Update(newPw)
 			if err != nil {
 				return fmt.Errorf("failed to set password: %w", err)
-			} else {
-				return c.JSON(http.StatusOK, nil)
 			}
 		}
+
+		err = h.persister.GetSecurityEventPersisterWithConnection(tx).Create(newSecurityEvent(c, models.EventPasswordChanged, &user.ID))
+		if err != nil {
+			return fmt.Errorf("failed to create security event: %w", err)
+		}
+
+		return c.JSON(status, nil)
 	})
 }
 
@@ -129,13 +161,21 @@ func (h *PasswordHandler) Login(c echo.Context) error {
 		return dto.ToHttpError(err)
 	}
 
-	pwBytes := []byte(body.Password)
-	if len(pwBytes) > 72 {
-		return dto.NewHTTPError(http.StatusBadRequest, "password must not be longer than 72 bytes")
+	if len(body.Password) > h.hasher.MaxLength() {
+		return dto.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("password must not be longer than %d bytes", h.hasher.MaxLength()))
+	}
+
+	userId := uuid.FromStringOrNil(body.UserId)
+	rateLimitKeys := []string{userRateLimitKey(userId), ipRateLimitKey(c)}
+	if err := checkRateLimit(c, h.limiter, rateLimitKeys...); err != nil {
+		return err
 	}
 
-	pw, err := h.persister.GetPasswordCredentialPersister().GetByUserID(uuid.FromStringOrNil(body.UserId))
+	pw, err := h.persister.GetPasswordCredentialPersister().GetByUserID(userId)
 	if pw == nil {
+		if err := h.limiter.Register(rateLimitKeys...); err != nil {
+			return err
+		}
 		return dto.NewHTTPError(http.StatusUnauthorized).SetInternal(fmt.Errorf("no password credential found for: %s", body.UserId))
 	}
 
@@ -143,11 +183,39 @@ func (h *PasswordHandler) Login(c echo.Context) error {
 		return fmt.Errorf("error retrieving credential: %w", err)
 	}
 
-	if err = bcrypt.CompareHashAndPassword([]byte(pw.Password), pwBytes); err != nil {
-		return dto.NewHTTPError(http.StatusUnauthorized).SetInternal(err)
+	valid, err := h.hasher.Verify(pw.Password, body.Password)
+	if err != nil {
+		return fmt.Errorf("failed to verify password: %w", err)
+	}
+	if !valid {
+		if err := h.limiter.Register(rateLimitKeys...); err != nil {
+			return err
+		}
+		return dto.NewHTTPError(http.StatusUnauthorized).SetInternal(fmt.Errorf("password mismatch for: %s", body.UserId))
+	}
+
+	if err := h.limiter.Reset(userRateLimitKey(userId)); err != nil {
+		return err
+	}
+
+	if h.hasher.NeedsRehash(pw.Password) {
+		err = h.rehash(*pw, body.Password)
+		if err != nil {
+			c.Logger().Errorf("failed to rehash password: %s", err)
+		}
+	}
+
+	secondFactor, err := secondFactorStatus(h.persister, h.cfg, pw.UserId)
+	if err != nil {
+		return err
+	}
+
+	var sessionOptions []session.Option
+	if secondFactor != nil {
+		sessionOptions = append(sessionOptions, session.WithRestriction(session.RestrictionSecondFactor), session.WithLifespan(secondFactorLifespan(h.cfg)))
 	}
 
-	token, err := h.sessionManager.GenerateJWT(pw.UserId, pw.UserId, uuid.Nil)
+	token, err := h.sessionManager.GenerateJWT(pw.UserId, pw.UserId, uuid.Nil, sessionOptions...)
 	if err != nil {
 		return fmt.Errorf("failed to generate jwt: %w", err)
 	}
@@ -159,7 +227,7 @@ func (h *PasswordHandler) Login(c echo.Context) error {
 
 	log := models.LoginAuditLog{
 		UserId:          pw.UserId,
-		ClientIpAddress: c.Request().RemoteAddr,
+		ClientIpAddress: c.RealIP(),
 		ClientUserAgent: c.Request().UserAgent(),
 		LoginMethod:     dto.LoginMethodToValue(dto.Password),
 	}
@@ -174,5 +242,26 @@ func (h *PasswordHandler) Login(c echo.Context) error {
 		c.Response().Header().Set("X-Auth-Token", token)
 	}
 
+	if secondFactor != nil {
+		return c.JSON(http.StatusOK, map[string]interface{}{"second_factor": secondFactor})
+	}
+
 	return c.JSON(http.StatusOK, nil)
 }
+
+// rehash replaces a password hash created with an outdated algorithm or outdated parameters. The plaintext password is
+// only available on login, so this is the only place to upgrade existing hashes.
+func (h *PasswordHandler) rehash(pw models.PasswordCredential, plaintext string) error {
+	hashedPassword, err := h.hasher.Hash(plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	pw.Password = hashedPassword
+	err = h.persister.GetPasswordCredentialPersister().Update(pw)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	return nil
+}
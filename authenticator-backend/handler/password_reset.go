@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/crypto"
+	hankoJwk "github.com/teamhanko/hanko/backend/crypto/jwk"
+	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/mail"
+	"github.com/teamhanko/hanko/backend/password"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/ratelimit"
+	"gopkg.in/gomail.v2"
+)
+
+// PurposePasswordReset is the purpose claim of password reset tokens
+const PurposePasswordReset = "password_reset"
+
+type PasswordResetHandler struct {
+	persister    persistence.Persister
+	cfg          *config.Config
+	jwtGenerator jwt2.Generator
+	mailer       mail.Mailer
+	renderer     *mail.Renderer
+	policy       *password.Policy
+	hasher       crypto.Hasher
+	limiter      *ratelimit.Limiter
+}
+
+// NewPasswordResetHandler creates a handler for resetting a forgotten password. The reset tokens are JWTs signed with
+// the same keys as the session JWTs, but carry a purpose claim, so they are never accepted as a session.
+func NewPasswordResetHandler(cfg *config.Config, persister persistence.Persister, jwkManager hankoJwk.Manager, mailer mail.Mailer) (*PasswordResetHandler, error) {
+	renderer, err := mail.NewRenderer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new renderer: %w", err)
+	}
+
+	signatureKey, err := jwkManager.GetSigningKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signing key: %w", err)
+	}
+	verificationKeys, err := jwkManager.GetPublicKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get verification keys: %w", err)
+	}
+	generator, err := jwt2.NewGenerator(signatureKey, verificationKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jwt generator: %w", err)
+	}
+
+	hasher := crypto.NewHasher(cfg.Hashing)
+	return &PasswordResetHandler{
+		persister:    persister,
+		cfg:          cfg,
+		jwtGenerator: generator,
+		mailer:       mailer,
+		renderer:     renderer,
+		policy:       password.NewPolicy(cfg.Password, cfg.Service.Name, hasher.MaxLength()),
+		hasher:       hasher,
+		limiter:      ratelimit.New(cfg.RateLimit, "password_reset", cfg.RateLimit.PasswordReset, persister),
+	}, nil
+}
+
+// Init sends a link with a single-use reset token to the email address. The response is the same whether a user with
+// the address exists or not.
+func (h *PasswordResetHandler) Init(c echo.Context) error {
+	var body dto.PasswordResetInitRequest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	if err := c.Validate(body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	rateLimitKeys := []string{emailRateLimitKey(body.Email), ipRateLimitKey(c)}
+	if err := checkRateLimit(c, h.limiter, rateLimitKeys...); err != nil {
+		return err
+	}
+	if err := h.limiter.Register(rateLimitKeys...); err != nil {
+		return err
+	}
+
+	user, err := h.persister.GetUserPersister().GetByEmail(strings.ToLower(body.Email))
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		c.Logger().Debugf("password reset requested for unknown email %s", body.Email)
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	resetId, err := uuid.NewV4()
+	if err != nil {
+		return fmt.Errorf("failed to create password reset id: %w", err)
+	}
+
+	now := time.Now().UTC()
+	reset := models.PasswordReset{
+		ID:        resetId,
+		UserId:    user.ID,
+		Ttl:       h.cfg.Password.Reset.TTL,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	// only the latest link is valid
+	err = h.persister.Transaction(func(tx *pop.Connection) error {
+		resetPersister := h.persister.GetPasswordResetPersisterWithConnection(tx)
+		err := resetPersister.DeleteByUserId(user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to delete password resets: %w", err)
+		}
+		return resetPersister.Create(reset)
+	})
+	if err != nil {
+		return err
+	}
+
+	token, err := h.generateToken(reset)
+	if err != nil {
+		return err
+	}
+
+	link, err := url.Parse(h.cfg.Password.Reset.Url)
+	if err != nil {
+		return fmt.Errorf("failed to parse password reset url: %w", err)
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	durationTTL := time.Duration(reset.Ttl) * time.Second
+	data := map[string]interface{}{
+		"Link":        link.String(),
+		"ServiceName": h.cfg.Service.Name,
+		"TTL":         fmt.Sprintf("%.0f", durationTTL.Minutes()),
+	}
+
+	lang := c.Request().Header.Get("Accept-Language")
+	str, err := h.renderer.Render("passwordResetTextMail", lang, data)
+	if err != nil {
+		return fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	message := gomail.NewMessage()
+	message.SetAddressHeader("To", user.Email, "")
+	message.SetAddressHeader("From", h.cfg.Passcode.Email.FromAddress, h.cfg.Passcode.Email.FromName)
+	message.SetHeader("Subject", h.renderer.Translate(lang, "email_subject_password_reset", data))
+	message.SetBody("text/plain", str)
+
+	err = h.mailer.Send(message)
+	if err != nil {
+		return fmt.Errorf("failed to send password reset email: %w", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Finish redeems the reset token and sets the new password. All existing sessions of the user are revoked.
+func (h *PasswordResetHandler) Finish(c echo.Context) error {
+	startTime := time.Now().UTC()
+	var body dto.PasswordResetFinishRequest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	if err := c.Validate(body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	token, err := h.jwtGenerator.Verify([]byte(body.Token))
+	if err != nil {
+		return dto.NewHTTPError(http.StatusBadRequest, "invalid token").SetInternal(err)
+	}
+
+	if jwt2.GetPurposeFromToken(token) != PurposePasswordReset {
+		return dto.NewHTTPError(http.StatusBadRequest, "invalid token").SetInternal(errors.New("token is not a password reset token"))
+	}
+
+	resetId, err := uuid.FromString(token.JwtID())
+	if err != nil {
+		return dto.NewHTTPError(http.StatusBadRequest, "invalid token").SetInternal(err)
+	}
+
+	return h.persister.Transaction(func(tx *pop.Connection) error {
+		resetPersister := h.persister.GetPasswordResetPersisterWithConnection(tx)
+		reset, err := resetPersister.Get(resetId)
+		if err != nil {
+			return fmt.Errorf("failed to get password reset: %w", err)
+		}
+		if reset == nil || reset.UserId.String() != token.Subject() {
+			return dto.NewHTTPError(http.StatusBadRequest, "invalid token").SetInternal(errors.New("password reset not found, it has already been used or replaced"))
+		}
+
+		if reset.CreatedAt.Add(time.Duration(reset.Ttl) * time.Second).Before(startTime) {
+			return dto.NewHTTPError(http.StatusBadRequest, "invalid token").SetInternal(errors.New("password reset expired"))
+		}
+
+		userPersister := h.persister.GetUserPersisterWithConnection(tx)
+		user, err := userPersister.Get(reset.UserId)
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		if user == nil {
+			return dto.NewHTTPError(http.StatusBadRequest, "invalid token").SetInternal(errors.New("user not found"))
+		}
+
+		violations, err := h.policy.Check(body.Password, user.Email)
+		if err != nil {
+			return err
+		}
+		if len(violations) > 0 {
+			return dto.NewHTTPError(http.StatusBadRequest, "password does not satisfy the password policy").SetDetails(violations)
+		}
+
+		hashedPassword, err := h.hasher.Hash(body.Password)
+		if err != nil {
+			return fmt.Errorf("failed to hash password: %w", err)
+		}
+
+		pwPersister := h.persister.GetPasswordCredentialPersisterWithConnection(tx)
+		pw, err := pwPersister.GetByUserID(user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get credential: %w", err)
+		}
+
+		if pw == nil {
+			err = pwPersister.Create(models.PasswordCredential{UserId: user.ID, Password: hashedPassword})
+		} else {
+			pw.Password = hashedPassword
+			err = pwPersister.Update(*pw)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to set password: %w", err)
+		}
+
+		err = resetPersister.Delete(*reset)
+		if err != nil {
+			return fmt.Errorf("failed to delete password reset: %w", err)
+		}
+
+		now := time.Now().UTC()
+		user.SessionsRevokedAt = &now
+		user.UpdatedAt = now
+		err = userPersister.Update(*user)
+		if err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+
+		log := models.LoginAuditLog{
+			UserId:          user.ID,
+			ClientIpAddress: c.RealIP(),
+			ClientUserAgent: c.Request().UserAgent(),
+			LoginMethod:     dto.LoginMethodToValue(dto.PasswordReset),
+		}
+		err = h.persister.GetLoginAuditLogPersister().Create(log)
+		if err != nil {
+			return dto.NewHTTPError(http.StatusInternalServerError, "An error occurred generating login audit record", err.Error())
+		}
+
+		// there is no session, the reset token proves that the user is the actor
+		event := newSecurityEvent(c, models.EventPasswordReset, &user.ID)
+		event.ActorUserId = &user.ID
+		err = h.persister.GetSecurityEventPersisterWithConnection(tx).Create(event)
+		if err != nil {
+			return fmt.Errorf("failed to create security event: %w", err)
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	})
+}
+
+func (h *PasswordResetHandler) generateToken(reset models.PasswordReset) (string, error) {
+	token := jwt.New()
+	_ = token.Set(jwt.SubjectKey, reset.UserId.String())
+	_ = token.Set(jwt.JwtIDKey, reset.ID.String())
+	_ = token.Set(jwt.IssuedAtKey, reset.CreatedAt)
+	_ = token.Set(jwt.ExpirationKey, reset.CreatedAt.Add(time.Duration(reset.Ttl)*time.Second))
+	_ = token.Set(jwt2.PurposeKey, PurposePasswordReset)
+
+	signed, err := h.jwtGenerator.Sign(token)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign password reset token: %w", err)
+	}
+
+	return string(signed), nil
+}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/crypto"
+	"github.com/teamhanko/hanko/backend/crypto/jwk"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+	"gopkg.in/gomail.v2"
+)
+
+type recordingMailer struct {
+	messages []*gomail.Message
+}
+
+func (m *recordingMailer) Send(message *gomail.Message) error {
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+func passwordResetConfig() *config.Config {
+	cfg := defaultConfig
+	cfg.Service.Name = "Test Service"
+	cfg.Secrets.Keys = []string{"needsToBeAtLeast16"}
+	cfg.Password = config.Password{
+		Enabled:           true,
+		MinPasswordLength: 8,
+		Reset: config.PasswordReset{
+			TTL: 900,
+			Url: "https://example.com/password/reset",
+		},
+	}
+	return &cfg
+}
+
+func newPasswordResetHandler(t *testing.T, cfg *config.Config, p persistence.Persister, m *recordingMailer) *PasswordResetHandler {
+	jwkManager, err := jwk.NewDefaultManager(cfg.Secrets.Keys, p.GetJwkPersister())
+	require.NoError(t, err)
+	handler, err := NewPasswordResetHandler(cfg, p, jwkManager, m)
+	require.NoError(t, err)
+	return handler
+}
+
+func createPasswordReset(t *testing.T, p persistence.Persister, createdAt time.Time) models.PasswordReset {
+	reset := models.PasswordReset{
+		ID:        generateUuid(t),
+		UserId:    uuid.FromStringOrNil(userId),
+		Ttl:       900,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+	require.NoError(t, p.GetPasswordResetPersister().Create(reset))
+	return reset
+}
+
+func newPasswordResetFinishContext(t *testing.T, token string, password string) (echo.Context, *httptest.ResponseRecorder) {
+	bodyJson, err := json.Marshal(dto.PasswordResetFinishRequest{Token: token, Password: password})
+	require.NoError(t, err)
+
+	return newContext(http.MethodPost, "/password/reset/finalize", string(bodyJson))
+}
+
+func TestPasswordResetHandler_Init(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	m := &recordingMailer{}
+	handler := newPasswordResetHandler(t, passwordResetConfig(), p, m)
+
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	req := httptest.NewRequest(http.MethodPost, "/password/reset/initialize", strings.NewReader(`{"email": "john.doe@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	if assert.NoError(t, handler.Init(e.NewContext(req, rec))) {
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		if assert.Len(t, m.messages, 1) {
+			assert.Equal(t, []string{"john.doe@example.com"}, m.messages[0].GetHeader("To"))
+			assert.Equal(t, []string{"Reset your password for Test Service"}, m.messages[0].GetHeader("Subject"))
+		}
+	}
+}
+
+func TestPasswordResetHandler_Init_DoesNotDiscloseUnknownEmail(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	m := &recordingMailer{}
+	handler := newPasswordResetHandler(t, passwordResetConfig(), p, m)
+
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	req := httptest.NewRequest(http.MethodPost, "/password/reset/initialize", strings.NewReader(`{"email": "unknown@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	if assert.NoError(t, handler.Init(e.NewContext(req, rec))) {
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Empty(t, m.messages)
+	}
+}
+
+func TestPasswordResetHandler_Finish(t *testing.T) {
+	cfg := passwordResetConfig()
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	handler := newPasswordResetHandler(t, cfg, p, &recordingMailer{})
+	reset := createPasswordReset(t, p, time.Now().UTC())
+	token, err := handler.generateToken(reset)
+	require.NoError(t, err)
+
+	c, rec := newPasswordResetFinishContext(t, token, "a-new-and-long-password")
+	if assert.NoError(t, handler.Finish(c)) {
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		uId := uuid.FromStringOrNil(userId)
+		pw, err := p.GetPasswordCredentialPersister().GetByUserID(uId)
+		require.NoError(t, err)
+		if assert.NotNil(t, pw) {
+			valid, err := crypto.NewHasher(cfg.Hashing).Verify(pw.Password, "a-new-and-long-password")
+			assert.NoError(t, err)
+			assert.True(t, valid)
+		}
+
+		user, err := p.GetUserPersister().Get(uId)
+		require.NoError(t, err)
+		assert.NotNil(t, user.SessionsRevokedAt)
+
+		logs, err := p.GetLoginAuditLogPersister().GetByPrimaryUserId(uId)
+		assert.NoError(t, err)
+		if assert.Len(t, logs, 1) {
+			assert.Equal(t, dto.LoginMethodToValue(dto.PasswordReset), logs[0].LoginMethod)
+		}
+	}
+
+	c2, _ := newPasswordResetFinishContext(t, token, "another-long-password")
+	err = handler.Finish(c2)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusBadRequest, dto.ToHttpError(err).Code)
+	}
+}
+
+func TestPasswordResetHandler_Finish_Errors_WhenPasswordViolatesPolicy(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	handler := newPasswordResetHandler(t, passwordResetConfig(), p, &recordingMailer{})
+	reset := createPasswordReset(t, p, time.Now().UTC())
+	token, err := handler.generateToken(reset)
+	require.NoError(t, err)
+
+	c, _ := newPasswordResetFinishContext(t, token, "short")
+	err = handler.Finish(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusBadRequest, dto.ToHttpError(err).Code)
+	}
+
+	stored, err := p.GetPasswordResetPersister().Get(reset.ID)
+	assert.NoError(t, err)
+	assert.NotNil(t, stored)
+}
+
+func TestPasswordResetHandler_Finish_Errors_WhenTokenExpired(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	handler := newPasswordResetHandler(t, passwordResetConfig(), p, &recordingMailer{})
+	reset := createPasswordReset(t, p, time.Now().UTC().Add(-time.Hour))
+	token, err := handler.generateToken(reset)
+	require.NoError(t, err)
+
+	c, _ := newPasswordResetFinishContext(t, token, "a-new-and-long-password")
+	err = handler.Finish(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusBadRequest, dto.ToHttpError(err).Code)
+	}
+}
+
+func TestPasswordResetHandler_Finish_Errors_WhenTokenIsASession(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	handler := newPasswordResetHandler(t, passwordResetConfig(), p, &recordingMailer{})
+	uId := uuid.FromStringOrNil(userId)
+	signed, err := handler.jwtGenerator.Sign(generateJwt(t, uId, uId, 5))
+	require.NoError(t, err)
+
+	c, _ := newPasswordResetFinishContext(t, string(signed), "a-new-and-long-password")
+	err = handler.Finish(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusBadRequest, dto.ToHttpError(err).Code)
+	}
+}
@@ -9,7 +9,10 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/crypto"
+	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
 	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/password"
 	"github.com/teamhanko/hanko/backend/persistence/models"
 	"github.com/teamhanko/hanko/backend/test"
 	"golang.org/x/crypto/bcrypt"
@@ -129,6 +132,44 @@ func TestPasswordHandler_Set_Create_PasswordTooLong(t *testing.T) {
 	}
 }
 
+func TestPasswordHandler_Set_Create_PolicyViolation(t *testing.T) {
+	userId, _ := uuid.FromString("ec4ef049-5b88-4321-a173-21b0eff06a04")
+	users := []models.User{
+		func() models.User {
+			return models.User{
+				ID:        userId,
+				Email:     "john.doe@example.com",
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+			}
+		}(),
+	}
+
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	body := PasswordSetBody{UserID: userId.String(), Password: "johndoepassword"}
+	bodyJson, err := json.Marshal(body)
+	assert.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/password", bytes.NewReader(bodyJson))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	token := jwt.New()
+	err = token.Set(jwt.SubjectKey, userId.String())
+	require.NoError(t, err)
+	c.Set("session", token)
+
+	p := test.NewPersister(users, nil, nil, nil, nil, []models.PasswordCredential{}, nil, nil, nil)
+	handler := NewPasswordHandler(p, sessionManager{}, &config.Config{Password: config.Password{MinPasswordLength: 8, Policy: config.PasswordPolicy{RequireDigit: true}}})
+
+	err = handler.Set(c)
+	if assert.Error(t, err) {
+		httpError := dto.ToHttpError(err)
+		assert.Equal(t, http.StatusBadRequest, httpError.Code)
+		assert.Equal(t, []password.Violation{{Code: password.ViolationMissingDigit}, {Code: password.ViolationContainsEmail}}, httpError.Details)
+	}
+}
+
 func TestPasswordHandler_Set_Update(t *testing.T) {
 	userId, _ := uuid.FromString("ec4ef049-5b88-4321-a173-21b0eff06a04")
 	users := []models.User{
@@ -167,19 +208,87 @@ func TestPasswordHandler_Set_Update(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
-	token := jwt.New()
-	err = token.Set(jwt.SubjectKey, userId.String())
-	require.NoError(t, err)
+	token := generateJwt(t, userId, userId, 5)
+	require.NoError(t, token.Set(jwt2.AuthTimeKey, time.Now().UTC().Unix()))
 	c.Set("session", token)
 
+	cfg := config.Config{}
+	cfg.Session.StepUp.MaxAge = "5m"
 	p := test.NewPersister(users, nil, nil, nil, nil, passwords, nil, nil, nil)
-	handler := NewPasswordHandler(p, sessionManager{}, &config.Config{})
+	handler := NewPasswordHandler(p, sessionManager{}, &cfg)
 
 	if assert.NoError(t, handler.Set(c)) {
 		assert.Equal(t, http.StatusOK, rec.Code)
 	}
 }
 
+func TestPasswordHandler_Set_Update_RequiresStepUp(t *testing.T) {
+	userId, _ := uuid.FromString("ec4ef049-5b88-4321-a173-21b0eff06a04")
+	users := []models.User{{ID: userId, Email: "john.doe@example.com", CreatedAt: time.Now(), UpdatedAt: time.Now()}}
+	passwords := []models.PasswordCredential{{ID: uuid.Must(uuid.NewV4()), UserId: userId, Password: "hash", CreatedAt: time.Now(), UpdatedAt: time.Now()}}
+
+	tests := []struct {
+		name     string
+		authTime *time.Time
+	}{
+		{name: "without step-up"},
+		{name: "with expired step-up", authTime: func() *time.Time { t := time.Now().UTC().Add(-10 * time.Minute); return &t }()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.Validator = dto.NewCustomValidator()
+			bodyJson, err := json.Marshal(PasswordSetBody{UserID: userId.String(), Password: "anotherbadnewpassword"})
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPut, "/password", bytes.NewReader(bodyJson))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			token := generateJwt(t, userId, userId, 5)
+			if tt.authTime != nil {
+				require.NoError(t, token.Set(jwt2.AuthTimeKey, tt.authTime.Unix()))
+			}
+			c.Set("session", token)
+
+			cfg := config.Config{}
+			cfg.Session.StepUp.MaxAge = "5m"
+			p := test.NewPersister(users, nil, nil, nil, nil, passwords, nil, nil, nil)
+			handler := NewPasswordHandler(p, sessionManager{}, &cfg)
+
+			err = handler.Set(c)
+			if assert.Error(t, err) {
+				assert.Equal(t, http.StatusForbidden, dto.ToHttpError(err).Code)
+			}
+		})
+	}
+}
+
+func TestPasswordHandler_Set_Errors_WhenCalledByAGuestUser(t *testing.T) {
+	userId, _ := uuid.FromString("ec4ef049-5b88-4321-a173-21b0eff06a04")
+	users := []models.User{{ID: userId, Email: "john.doe@example.com", CreatedAt: time.Now(), UpdatedAt: time.Now()}}
+
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	bodyJson, err := json.Marshal(PasswordSetBody{UserID: userId.String(), Password: "anotherbadnewpassword"})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPut, "/password", bytes.NewReader(bodyJson))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	token := generateJwt(t, userId, generateUuid(t), 5)
+	require.NoError(t, token.Set(jwt2.AuthTimeKey, time.Now().UTC().Unix()))
+	c.Set("session", token)
+
+	p := test.NewPersister(users, nil, nil, nil, nil, []models.PasswordCredential{}, nil, nil, nil)
+	handler := NewPasswordHandler(p, sessionManager{}, &config.Config{})
+
+	err = handler.Set(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusForbidden, dto.ToHttpError(err).Code)
+	}
+}
+
 func TestPasswordHandler_Set_UserNotFound(t *testing.T) {
 	userId, _ := uuid.FromString("ec4ef049-5b88-4321-a173-21b0eff06a04")
 
@@ -405,6 +514,88 @@ func TestPasswordHandler_Login_NonExistingUser(t *testing.T) {
 	}
 }
 
+func TestPasswordHandler_Login_RehashesOutdatedHash(t *testing.T) {
+	userId, _ := uuid.FromString("ec4ef049-5b88-4321-a173-21b0eff06a04")
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("verybadpassword"), 10)
+	require.NoError(t, err)
+
+	passwords := []models.PasswordCredential{
+		{
+			ID:        generateUuid(t),
+			UserId:    userId,
+			Password:  string(hashedPassword),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		},
+	}
+
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	body := `{"user_id": "ec4ef049-5b88-4321-a173-21b0eff06a04", "password": "verybadpassword"}`
+	req := httptest.NewRequest(http.MethodPost, "/password/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	p := test.NewPersister(nil, nil, nil, nil, nil, passwords, nil, nil, nil)
+	cfg := &config.Config{Hashing: config.Hashing{
+		Algorithm: config.HashingArgon2id,
+		Argon2id:  config.Argon2idSettings{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+	}}
+	handler := NewPasswordHandler(p, sessionManager{}, cfg)
+
+	if assert.NoError(t, handler.Login(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		pw, err := p.GetPasswordCredentialPersister().GetByUserID(userId)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(pw.Password, "$argon2id$"))
+
+		valid, err := crypto.NewHasher(cfg.Hashing).Verify(pw.Password, "verybadpassword")
+		assert.NoError(t, err)
+		assert.True(t, valid)
+	}
+}
+
+func TestPasswordHandler_Set_Create_LongPasswordWithArgon2id(t *testing.T) {
+	userId, _ := uuid.FromString("ec4ef049-5b88-4321-a173-21b0eff06a04")
+	users := []models.User{
+		{
+			ID:        userId,
+			Email:     "john.doe@example.com",
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		},
+	}
+
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	body := PasswordSetBody{UserID: userId.String(), Password: "thisIsAVeryLongPasswordThatIsUsedToTestIfAnErrorWillBeReturnedForTooLongPasswords"}
+	bodyJson, err := json.Marshal(body)
+	assert.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/password", bytes.NewReader(bodyJson))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	token := jwt.New()
+	err = token.Set(jwt.SubjectKey, userId.String())
+	require.NoError(t, err)
+	c.Set("session", token)
+
+	p := test.NewPersister(users, nil, nil, nil, nil, []models.PasswordCredential{}, nil, nil, nil)
+	handler := NewPasswordHandler(p, sessionManager{}, &config.Config{
+		Password: config.Password{MinPasswordLength: 8},
+		Hashing: config.Hashing{
+			Algorithm: config.HashingArgon2id,
+			Argon2id:  config.Argon2idSettings{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		},
+	})
+
+	if assert.NoError(t, handler.Set(c)) {
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	}
+}
+
 // TestMaxPasswordLength is only for documentation purposes, because it is nowhere documented, that the bcrypt
 // implementation of golang truncates the password silent to 72 bytes.
 func TestMaxPasswordLength(t *testing.T) {
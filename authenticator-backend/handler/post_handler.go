@@ -1,28 +1,43 @@
 package handler
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"github.com/gobuffalo/pop/v6"
 	"github.com/gofrs/uuid"
 	"github.com/labstack/echo/v4"
-	"github.com/lestrrat-go/jwx/v2/jwt"
-	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/actor"
 	"github.com/teamhanko/hanko/backend/dto"
 	"github.com/teamhanko/hanko/backend/persistence"
 	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/resource"
 	"net/http"
+	"strings"
 	"time"
 )
 
 type PostHandler struct {
 	persister persistence.Persister
+	resources *resourceHandler
 }
 
 func NewPostHandler(persister persistence.Persister) *PostHandler {
-	return &PostHandler{persister: persister}
+	store := func(tx *pop.Connection) resource.Store {
+		return persistence.NewPostStore(persister.GetPostPersisterWithConnection(tx), persister.GetPostHistoryPersisterWithConnection(tx))
+	}
+	return &PostHandler{persister: persister, resources: newResourceHandler(persister, models.PostType, store)}
+}
+
+type GetPostsRequest struct {
+	Cursor  string `query:"cursor"`
+	PerPage int    `query:"per_page"`
 }
 
 type GetPostsDto struct {
 	Posts []PostDto `json:"posts"`
+	// NextCursor continues the feed, it is omitted on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 type PostDto struct {
@@ -32,44 +47,89 @@ type PostDto struct {
 	CreatedByEmail     string    `json:"created_by"`
 	CreatedBySurrogate *string   `json:"created_by_surrogate"`
 	UpdatedBySurrogate *string   `json:"updated_by_surrogate"`
+	Visibility         string    `json:"visibility"`
 	Data               string    `json:"data"`
 }
 
+// GetPosts returns the feed of the account of the session
 func (h *PostHandler) GetPosts(c echo.Context) error {
-	posts, err := h.persister.GetPostPersister().List(0, 20)
+	a, err := actingAs(c)
+	if err != nil {
+		return err
+	}
+
+	return h.feed(c, a, a.PrincipalId)
+}
+
+// GetUserPosts returns the feed of the user given in the path. Other users only get the public posts.
+func (h *PostHandler) GetUserPosts(c echo.Context) error {
+	ownerId, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return dto.NewHTTPError(http.StatusBadRequest, "failed to parse userId as uuid").SetInternal(err)
+	}
+
+	a, err := actingAs(c)
 	if err != nil {
-		return dto.NewHTTPError(http.StatusInternalServerError).SetInternal(fmt.Errorf("unable to fetch posts"))
+		return err
 	}
 
-	sessionToken, ok := c.Get("session").(jwt.Token)
-	if !ok {
-		return dto.NewHTTPError(http.StatusUnauthorized)
+	return h.feed(c, a, ownerId)
+}
+
+// feed returns a page of the posts of the owner the actor may read, newest first
+func (h *PostHandler) feed(c echo.Context, a actor.Actor, ownerId uuid.UUID) error {
+	var request GetPostsRequest
+	err := (&echo.DefaultBinder{}).BindQueryParams(c, &request)
+	if err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	filter := persistence.PostFilter{OwnerId: &ownerId}
+	if request.Cursor != "" {
+		filter.After, err = decodePostCursor(request.Cursor)
+		if err != nil {
+			return dto.NewHTTPError(http.StatusBadRequest, "invalid cursor").SetInternal(err)
+		}
 	}
-	surrogateId, err := jwt2.GetSurrogateKeyFromToken(sessionToken)
+	_, perPage := normalizePagination(1, request.PerPage)
+
+	// one more post is fetched to know whether there is a next page
+	posts, err := h.persister.GetPostPersister().Feed(a, filter, perPage+1)
 	if err != nil {
-		return dto.NewHTTPError(http.StatusUnauthorized)
+		return dto.NewHTTPError(http.StatusInternalServerError).SetInternal(fmt.Errorf("unable to fetch posts: %w", err))
 	}
-	user, err := h.persister.GetUserPersister().Get(uuid.FromStringOrNil(sessionToken.Subject()))
+
+	user, err := h.persister.GetUserPersister().Get(a.PrincipalId)
 	if err != nil || user == nil {
-		return dto.NewHTTPError(http.StatusNotFound).SetInternal(fmt.Errorf("an error occurred fetchng user id %s: %w", sessionToken.Subject(), err))
+		return dto.NewHTTPError(http.StatusNotFound).SetInternal(fmt.Errorf("an error occurred fetchng user id %s: %w", a.PrincipalId, err))
 	}
+	roles, err := h.persister.GetRolePersister().FindByUserId(user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get roles: %w", err)
+	}
+	// the guest who actually wrote a post is shown to the account holder and is part of the audit, guests never see it
+	auditor := models.HasPermission(roles, models.PermissionAuditRead)
 	emailMaps := map[uuid.UUID]string{}
-	result := GetPostsDto{}
+	result := GetPostsDto{Posts: []PostDto{}}
 
-	for _, post := range posts {
-		if !post.IsActive {
-			continue
-		}
+	if len(posts) > perPage {
+		posts = posts[:perPage]
+		last := posts[len(posts)-1]
+		result.NextCursor = encodePostCursor(persistence.PostCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+		setCursorPaginationHeaders(c, perPage, result.NextCursor)
+	}
 
+	for _, post := range posts {
 		dto := PostDto{
 			ID:             post.ID,
 			CreatedAt:      post.CreatedAt,
 			UpdatedAt:      post.UpdatedAt,
 			CreatedByEmail: h.GetUserEmail(post.CreatedByUserId, emailMaps),
+			Visibility:     post.Visibility,
 			Data:           post.Data,
 		}
 
-		if user.IsAdmin && (sessionToken.Subject() == surrogateId) {
+		if !a.IsGuest() && (post.CreatedByUserId == a.PrincipalId || auditor) {
 			createdBySurrogate := h.GetUserEmail(post.CreatedBySurrogateId, emailMaps)
 			updatedBySurrogate := h.GetUserEmail(post.UpdatedBySurrogateId, emailMaps)
 			dto.CreatedBySurrogate = &createdBySurrogate
@@ -82,18 +142,41 @@ func (h *PostHandler) GetPosts(c echo.Context) error {
 	return c.JSON(http.StatusOK, result)
 }
 
+// encodePostCursor returns the opaque cursor clients pass to continue a feed
+func encodePostCursor(cursor persistence.PostCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursor.CreatedAt.UTC().Format(time.RFC3339Nano) + "_" + cursor.ID.String()))
+}
+
+func decodePostCursor(encoded string) (*persistence.PostCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.SplitN(string(decoded), "_", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("cursor is malformed")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.FromString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	return &persistence.PostCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
 type CreatePostDto struct {
 	Body string `json:"body" validate:"required"`
+	// Visibility is one of resource.Visibilities, the posts are shared with the guests by default
+	Visibility string `json:"visibility"`
 }
 
 func (h *PostHandler) CreatePost(c echo.Context) error {
-	sessionToken, ok := c.Get("session").(jwt.Token)
-	if !ok {
-		return dto.NewHTTPError(http.StatusUnauthorized)
-	}
-	surrogateId, err := jwt2.GetSurrogateKeyFromToken(sessionToken)
+	a, err := actingAs(c)
 	if err != nil {
-		return dto.NewHTTPError(http.StatusForbidden)
+		return err
 	}
 	newPost := CreatePostDto{}
 	err = c.Bind(&newPost)
@@ -101,33 +184,158 @@ func (h *PostHandler) CreatePost(c echo.Context) error {
 	if err != nil {
 		return dto.NewHTTPError(http.StatusBadRequest)
 	}
+	if newPost.Visibility == "" {
+		newPost.Visibility = resource.VisibilityGuests
+	}
+	err = h.resources.checkVisibility(a, newPost.Visibility)
+	if err != nil {
+		return err
+	}
 	uId, _ := uuid.NewV4()
 	post := models.Post{
-		ID:                   uId,
-		IsActive:             true,
-		CreatedAt:            time.Now().UTC(),
-		UpdatedAt:            time.Now().UTC(),
-		CreatedByUserId:      uuid.FromStringOrNil(sessionToken.Subject()),
-		UpdatedByUserId:      uuid.FromStringOrNil(sessionToken.Subject()),
-		CreatedBySurrogateId: uuid.FromStringOrNil(surrogateId),
-		UpdatedBySurrogateId: uuid.FromStringOrNil(surrogateId),
-		Data:                 newPost.Body,
+		ID:         uId,
+		IsActive:   true,
+		Visibility: newPost.Visibility,
+		Data:       newPost.Body,
 	}
-	err = h.persister.GetPostPersister().Create(post)
+	err = h.resources.create(a, &post)
 	if err != nil {
-		return dto.NewHTTPError(http.StatusInternalServerError)
+		return err
 	}
 
 	return c.JSON(http.StatusOK, struct{}{})
 }
 
+type UpdatePostDto struct {
+	Body string `json:"body" validate:"required"`
+	// Visibility is kept if not set
+	Visibility string `json:"visibility"`
+}
+
+// UpdatePost changes the text and the visibility of a post of the account. The previous version is kept in the history
+// of the post.
+func (h *PostHandler) UpdatePost(c echo.Context) error {
+	var body UpdatePostDto
+	if err := (&echo.DefaultBinder{}).BindBody(c, &body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	if err := c.Validate(body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	a, err := actingAs(c)
+	if err != nil {
+		return err
+	}
+	if body.Visibility != "" {
+		err = h.resources.checkVisibility(a, body.Visibility)
+		if err != nil {
+			return err
+		}
+	}
+
+	changed, err := h.resources.change(c, a, func(r resource.Versioned) {
+		post := r.(*models.Post)
+		post.Data = body.Body
+		if body.Visibility != "" {
+			post.Visibility = body.Visibility
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	post := changed.(*models.Post)
+	return c.JSON(http.StatusOK, PostDto{
+		ID:             post.ID,
+		CreatedAt:      post.CreatedAt,
+		UpdatedAt:      post.UpdatedAt,
+		CreatedByEmail: h.GetUserEmail(post.CreatedByUserId, map[uuid.UUID]string{}),
+		Visibility:     post.Visibility,
+		Data:           post.Data,
+	})
+}
+
+// DeletePost deactivates a post of the account. The post is kept with its history.
+func (h *PostHandler) DeletePost(c echo.Context) error {
+	a, err := actingAs(c)
+	if err != nil {
+		return err
+	}
+
+	err = h.resources.delete(c, a)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+type PostHistoryDto struct {
+	PostId   uuid.UUID        `json:"post_id"`
+	Versions []PostVersionDto `json:"versions"`
+}
+
+// PostVersionDto is a version of a post and who wrote it. ChangedBy is the account, ChangedByGuest the guest who
+// acted on behalf of the account holder, which only the account holder gets to see.
+type PostVersionDto struct {
+	Version        int       `json:"version"`
+	Data           string    `json:"data"`
+	IsActive       bool      `json:"is_active"`
+	Visibility     string    `json:"visibility"`
+	ChangedAt      time.Time `json:"changed_at"`
+	ChangedBy      string    `json:"changed_by"`
+	ActingAsGuest  bool      `json:"acting_as_guest"`
+	ChangedByGuest *string   `json:"changed_by_guest"`
+}
+
+// GetPostHistory returns all versions of a post of the account, oldest first. The history of deleted posts is kept,
+// the last version shows who deleted the post.
+func (h *PostHandler) GetPostHistory(c echo.Context) error {
+	a, err := actingAs(c)
+	if err != nil {
+		return err
+	}
+
+	versions, err := h.resources.versions(c, a)
+	if err != nil {
+		return err
+	}
+
+	emailMaps := map[uuid.UUID]string{}
+	result := PostHistoryDto{Versions: []PostVersionDto{}}
+	for _, version := range versions {
+		post := version.(*models.Post)
+		result.PostId = post.ID
+		dto := PostVersionDto{
+			Version:       post.Version,
+			Data:          post.Data,
+			IsActive:      post.IsActive,
+			Visibility:    post.Visibility,
+			ChangedAt:     post.UpdatedAt,
+			ChangedBy:     h.GetUserEmail(post.UpdatedByUserId, emailMaps),
+			ActingAsGuest: resource.ChangedByGuest(post),
+		}
+		// guests see that a guest changed the post, but not who
+		if dto.ActingAsGuest && !a.IsGuest() {
+			guestEmail := h.GetUserEmail(post.UpdatedBySurrogateId, emailMaps)
+			dto.ChangedByGuest = &guestEmail
+		}
+		result.Versions = append(result.Versions, dto)
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
 func (h *PostHandler) GetUserEmail(userId uuid.UUID, emailMaps map[uuid.UUID]string) string {
 	val, exists := emailMaps[userId]
 	if exists {
 		return val
 	}
 	user, err := h.persister.GetUserPersister().Get(userId)
-	if err != nil {
+	if err != nil || user == nil {
+		// the user has been deleted and pseudonymised
 		return ""
 	}
 	emailMaps[userId] = user.Email
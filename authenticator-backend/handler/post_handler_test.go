@@ -2,10 +2,14 @@ package handler
 
 import (
 	"encoding/json"
+	"github.com/gofrs/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/teamhanko/hanko/backend/dto"
 	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/resource"
 	"github.com/teamhanko/hanko/backend/test"
 	"net/http"
 	"net/http/httptest"
@@ -18,38 +22,43 @@ func TestPostHandler_ListPosts_WhenNotAdmin(t *testing.T) {
 	handler := newPostHandler()
 	actingUser := generateUser(t)
 	handler.persister.GetUserPersister().Create(actingUser)
-	seedPostsAndUsers(t, handler)
+	owner := seedPostsAndUsers(t, handler)
 
 	e := echo.New()
 	e.Validator = dto.NewCustomValidator()
-	req := httptest.NewRequest(http.MethodGet, "/posts", strings.NewReader(""))
+	req := httptest.NewRequest(http.MethodGet, "/users/"+owner.ID.String()+"/posts", strings.NewReader(""))
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(owner.ID.String())
 	c.Set("session", generateJwt(t, actingUser.ID, actingUser.ID, 60))
 
-	if assert.NoError(t, handler.GetPosts(c)) {
+	if assert.NoError(t, handler.GetUserPosts(c)) {
 		assert.Equal(t, http.StatusOK, rec.Result().StatusCode)
 		response := GetPostsDto{}
 		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
 		assert.Equal(t, 2, len(response.Posts))
+		assert.Nil(t, response.Posts[1].CreatedBySurrogate)
 	}
 }
 
 func TestPostHandler_ListPosts_WhenAdmin(t *testing.T) {
 	handler := newPostHandler()
 	actingUser := generateUser(t)
-	actingUser.IsAdmin = true
 	handler.persister.GetUserPersister().Create(actingUser)
-	seedPostsAndUsers(t, handler)
+	grantRole(handler.persister, actingUser.ID, models.RoleSupport)
+	owner := seedPostsAndUsers(t, handler)
 
 	e := echo.New()
 	e.Validator = dto.NewCustomValidator()
-	req := httptest.NewRequest(http.MethodGet, "/posts", strings.NewReader(""))
+	req := httptest.NewRequest(http.MethodGet, "/users/"+owner.ID.String()+"/posts", strings.NewReader(""))
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(owner.ID.String())
 	c.Set("session", generateJwt(t, actingUser.ID, actingUser.ID, 60))
 
-	if assert.NoError(t, handler.GetPosts(c)) {
+	if assert.NoError(t, handler.GetUserPosts(c)) {
 		assert.Equal(t, http.StatusOK, rec.Result().StatusCode)
 		var response GetPostsDto
 		err := json.Unmarshal(rec.Body.Bytes(), &response)
@@ -112,12 +121,265 @@ func TestPostHandler_CreatePost_WhenGuest(t *testing.T) {
 	}
 }
 
+func TestPostHandler_UpdatePost_WhenGuest(t *testing.T) {
+	handler := newPostHandler()
+	owner := generateUser(t)
+	guest := generateUser(t)
+	handler.persister.GetUserPersister().Create(owner)
+	handler.persister.GetUserPersister().Create(guest)
+	post := createPost(t, handler, owner.ID)
+
+	c, rec := newPostContext(http.MethodPut, post.ID, `{"body":"edited by the guest"}`, generateJwt(t, owner.ID, guest.ID, 60))
+
+	if assert.NoError(t, handler.UpdatePost(c)) {
+		assert.Equal(t, http.StatusOK, rec.Result().StatusCode)
+		updated, err := handler.persister.GetPostPersister().Get(post.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "edited by the guest", updated.Data)
+		assert.Equal(t, 2, updated.Version)
+		assert.Equal(t, owner.ID, updated.UpdatedByUserId)
+		assert.Equal(t, guest.ID, updated.UpdatedBySurrogateId)
+		assert.Equal(t, owner.ID, updated.CreatedBySurrogateId)
+
+		histories, err := handler.persister.GetPostHistoryPersister().ListByPost(post.ID)
+		require.NoError(t, err)
+		if assert.Len(t, histories, 1) {
+			assert.Equal(t, 1, histories[0].Version)
+			assert.Equal(t, post.Data, histories[0].Data)
+			assert.Equal(t, owner.ID, histories[0].UpdatedBySurrogateId)
+		}
+	}
+}
+
+func TestPostHandler_UpdatePost_WhenNotOwner(t *testing.T) {
+	handler := newPostHandler()
+	owner := generateUser(t)
+	other := generateUser(t)
+	handler.persister.GetUserPersister().Create(owner)
+	handler.persister.GetUserPersister().Create(other)
+	post := createPost(t, handler, owner.ID)
+
+	c, _ := newPostContext(http.MethodPut, post.ID, `{"body":"not mine"}`, generateJwt(t, other.ID, other.ID, 60))
+
+	err := handler.UpdatePost(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusNotFound, dto.ToHttpError(err).Code)
+	}
+	unchanged, err := handler.persister.GetPostPersister().Get(post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, post.Data, unchanged.Data)
+}
+
+func TestPostHandler_DeletePost(t *testing.T) {
+	handler := newPostHandler()
+	owner := generateUser(t)
+	handler.persister.GetUserPersister().Create(owner)
+	post := createPost(t, handler, owner.ID)
+
+	c, rec := newPostContext(http.MethodDelete, post.ID, "", generateJwt(t, owner.ID, owner.ID, 60))
+
+	if assert.NoError(t, handler.DeletePost(c)) {
+		assert.Equal(t, http.StatusNoContent, rec.Result().StatusCode)
+		deleted, err := handler.persister.GetPostPersister().Get(post.ID)
+		require.NoError(t, err)
+		assert.False(t, deleted.IsActive)
+		assert.Equal(t, post.Data, deleted.Data)
+	}
+
+	// a deleted post can neither be changed nor deleted again
+	c, _ = newPostContext(http.MethodDelete, post.ID, "", generateJwt(t, owner.ID, owner.ID, 60))
+	err := handler.DeletePost(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusNotFound, dto.ToHttpError(err).Code)
+	}
+}
+
+func TestPostHandler_GetPostHistory(t *testing.T) {
+	handler := newPostHandler()
+	owner := generateUser(t)
+	guest := generateUser(t)
+	handler.persister.GetUserPersister().Create(owner)
+	handler.persister.GetUserPersister().Create(guest)
+	post := createPost(t, handler, owner.ID)
+	guestSession := generateJwt(t, owner.ID, guest.ID, 60)
+	ownerSession := generateJwt(t, owner.ID, owner.ID, 60)
+
+	c, _ := newPostContext(http.MethodPut, post.ID, `{"body":"edited by the guest"}`, guestSession)
+	require.NoError(t, handler.UpdatePost(c))
+	c, _ = newPostContext(http.MethodDelete, post.ID, "", ownerSession)
+	require.NoError(t, handler.DeletePost(c))
+
+	c, rec := newPostContext(http.MethodGet, post.ID, "", ownerSession)
+	if assert.NoError(t, handler.GetPostHistory(c)) {
+		assert.Equal(t, http.StatusOK, rec.Result().StatusCode)
+		var response PostHistoryDto
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		require.Len(t, response.Versions, 3)
+
+		assert.Equal(t, 1, response.Versions[0].Version)
+		assert.False(t, response.Versions[0].ActingAsGuest)
+		assert.Nil(t, response.Versions[0].ChangedByGuest)
+
+		assert.Equal(t, "edited by the guest", response.Versions[1].Data)
+		assert.Equal(t, owner.Email, response.Versions[1].ChangedBy)
+		assert.True(t, response.Versions[1].ActingAsGuest)
+		if assert.NotNil(t, response.Versions[1].ChangedByGuest) {
+			assert.Equal(t, guest.Email, *response.Versions[1].ChangedByGuest)
+		}
+
+		assert.Equal(t, 3, response.Versions[2].Version)
+		assert.False(t, response.Versions[2].IsActive)
+		assert.False(t, response.Versions[2].ActingAsGuest)
+	}
+
+	// guests see that a guest changed the post, but not who
+	c, rec = newPostContext(http.MethodGet, post.ID, "", guestSession)
+	if assert.NoError(t, handler.GetPostHistory(c)) {
+		var response PostHistoryDto
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		require.Len(t, response.Versions, 3)
+		assert.True(t, response.Versions[1].ActingAsGuest)
+		assert.Nil(t, response.Versions[1].ChangedByGuest)
+	}
+}
+
+func TestPostHandler_GetPosts_Visibility(t *testing.T) {
+	handler := newPostHandler()
+	owner := generateUser(t)
+	guest := generateUser(t)
+	other := generateUser(t)
+	handler.persister.GetUserPersister().Create(owner)
+	handler.persister.GetUserPersister().Create(guest)
+	handler.persister.GetUserPersister().Create(other)
+	now := time.Now().UTC()
+	createPostWithVisibility(t, handler, owner.ID, resource.VisibilityPrivate, now)
+	createPostWithVisibility(t, handler, owner.ID, resource.VisibilityGuests, now.Add(-time.Minute))
+	createPostWithVisibility(t, handler, owner.ID, resource.VisibilityPublic, now.Add(-2*time.Minute))
+	createPostWithVisibility(t, handler, other.ID, resource.VisibilityPublic, now)
+
+	tests := []struct {
+		name     string
+		session  jwt.Token
+		expected []string
+	}{
+		{name: "account holder", session: generateJwt(t, owner.ID, owner.ID, 60), expected: resource.Visibilities},
+		{name: "guest", session: generateJwt(t, owner.ID, guest.ID, 60), expected: []string{resource.VisibilityGuests, resource.VisibilityPublic}},
+		{name: "other user", session: generateJwt(t, other.ID, other.ID, 60), expected: []string{resource.VisibilityPublic}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newFeedContext(owner.ID, "", tt.session)
+			if assert.NoError(t, handler.GetUserPosts(c)) {
+				var response GetPostsDto
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+				var visibilities []string
+				for _, post := range response.Posts {
+					visibilities = append(visibilities, post.Visibility)
+				}
+				assert.Equal(t, tt.expected, visibilities)
+			}
+		})
+	}
+
+	// the feed of the session only has the posts of its account
+	c, rec := newFeedContext(owner.ID, "", generateJwt(t, other.ID, other.ID, 60))
+	if assert.NoError(t, handler.GetPosts(c)) {
+		var response GetPostsDto
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		if assert.Len(t, response.Posts, 1) {
+			assert.Equal(t, other.Email, response.Posts[0].CreatedByEmail)
+		}
+	}
+}
+
+func TestPostHandler_GetPosts_Cursor(t *testing.T) {
+	handler := newPostHandler()
+	owner := generateUser(t)
+	handler.persister.GetUserPersister().Create(owner)
+	session := generateJwt(t, owner.ID, owner.ID, 60)
+	now := time.Now().UTC()
+	var created []uuid.UUID
+	for i := 0; i < 5; i++ {
+		created = append(created, createPostWithVisibility(t, handler, owner.ID, resource.VisibilityPrivate, now.Add(-time.Duration(i)*time.Minute)).ID)
+	}
+
+	var read []uuid.UUID
+	cursor := ""
+	for page := 0; page < 3; page++ {
+		c, rec := newFeedContext(owner.ID, "per_page=2&cursor="+cursor, session)
+		require.NoError(t, handler.GetPosts(c))
+		var response GetPostsDto
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		for _, post := range response.Posts {
+			read = append(read, post.ID)
+		}
+		cursor = response.NextCursor
+		if page < 2 {
+			assert.Len(t, response.Posts, 2)
+			assert.Contains(t, rec.Header().Get("Link"), `rel="next"`)
+		} else {
+			assert.Len(t, response.Posts, 1)
+			assert.Empty(t, cursor)
+			assert.Empty(t, rec.Header().Get("Link"))
+		}
+	}
+	assert.Equal(t, created, read)
+
+	c, _ := newFeedContext(owner.ID, "cursor=invalid", session)
+	err := handler.GetPosts(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusBadRequest, dto.ToHttpError(err).Code)
+	}
+}
+
+func TestPostHandler_UpdatePost_Forbidden(t *testing.T) {
+	handler := newPostHandler()
+	owner := generateUser(t)
+	guest := generateUser(t)
+	other := generateUser(t)
+	handler.persister.GetUserPersister().Create(owner)
+	handler.persister.GetUserPersister().Create(guest)
+	handler.persister.GetUserPersister().Create(other)
+	privatePost := createPostWithVisibility(t, handler, owner.ID, resource.VisibilityPrivate, time.Now().UTC())
+	publicPost := createPostWithVisibility(t, handler, owner.ID, resource.VisibilityPublic, time.Now().UTC())
+
+	tests := []struct {
+		name     string
+		post     models.Post
+		body     string
+		session  jwt.Token
+		expected int
+	}{
+		{name: "guest on private post", post: privatePost, body: `{"body":"edited"}`, session: generateJwt(t, owner.ID, guest.ID, 60), expected: http.StatusNotFound},
+		{name: "guest making post private", post: publicPost, body: `{"body":"edited","visibility":"private"}`, session: generateJwt(t, owner.ID, guest.ID, 60), expected: http.StatusForbidden},
+		{name: "other user on public post", post: publicPost, body: `{"body":"edited"}`, session: generateJwt(t, other.ID, other.ID, 60), expected: http.StatusForbidden},
+		{name: "unknown visibility", post: publicPost, body: `{"body":"edited","visibility":"friends"}`, session: generateJwt(t, owner.ID, owner.ID, 60), expected: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newPostContext(http.MethodPut, tt.post.ID, tt.body, tt.session)
+			err := handler.UpdatePost(c)
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.expected, dto.ToHttpError(err).Code)
+			}
+			unchanged, err := handler.persister.GetPostPersister().Get(tt.post.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.post.Data, unchanged.Data)
+			assert.Equal(t, tt.post.Visibility, unchanged.Visibility)
+		})
+	}
+}
+
 func newPostHandler() *PostHandler {
 	p := test.NewPersister(nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	return NewPostHandler(p)
 }
 
-func seedPostsAndUsers(t *testing.T, h *PostHandler) {
+// seedPostsAndUsers creates two public posts of an account, the second one written by a guest, and returns the
+// account holder
+func seedPostsAndUsers(t *testing.T, h *PostHandler) models.User {
 	user1 := generateUser(t)
 	user2 := generateUser(t)
 	posts := []models.Post{
@@ -126,6 +388,7 @@ func seedPostsAndUsers(t *testing.T, h *PostHandler) {
 				ID:                   generateUuid(t),
 				CreatedAt:            time.Now().UTC(),
 				IsActive:             true,
+				Visibility:           resource.VisibilityPublic,
 				CreatedByUserId:      user1.ID,
 				UpdatedByUserId:      user1.ID,
 				CreatedBySurrogateId: user1.ID,
@@ -138,6 +401,7 @@ func seedPostsAndUsers(t *testing.T, h *PostHandler) {
 				ID:                   generateUuid(t),
 				CreatedAt:            time.Now().UTC(),
 				IsActive:             true,
+				Visibility:           resource.VisibilityPublic,
 				CreatedByUserId:      user1.ID,
 				UpdatedByUserId:      user1.ID,
 				CreatedBySurrogateId: user2.ID,
@@ -153,4 +417,43 @@ func seedPostsAndUsers(t *testing.T, h *PostHandler) {
 	for _, post := range posts {
 		h.persister.GetPostPersister().Create(post)
 	}
+	return user1
+}
+
+func createPost(t *testing.T, h *PostHandler, ownerId uuid.UUID) models.Post {
+	return createPostWithVisibility(t, h, ownerId, resource.VisibilityGuests, time.Now().UTC())
+}
+
+func createPostWithVisibility(t *testing.T, h *PostHandler, ownerId uuid.UUID, visibility string, createdAt time.Time) models.Post {
+	post := models.Post{
+		ID:                   generateUuid(t),
+		CreatedAt:            createdAt,
+		UpdatedAt:            createdAt,
+		IsActive:             true,
+		Version:              1,
+		Visibility:           visibility,
+		CreatedByUserId:      ownerId,
+		UpdatedByUserId:      ownerId,
+		CreatedBySurrogateId: ownerId,
+		UpdatedBySurrogateId: ownerId,
+		Data:                 "hello, world!",
+	}
+	require.NoError(t, h.persister.GetPostPersister().Create(post))
+	return post
+}
+
+func newPostContext(method string, postId uuid.UUID, body string, session jwt.Token) (echo.Context, *httptest.ResponseRecorder) {
+	c, rec := newContext(method, "/posts/"+postId.String(), body)
+	c.SetParamNames("id")
+	c.SetParamValues(postId.String())
+	c.Set("session", session)
+	return c, rec
+}
+
+func newFeedContext(ownerId uuid.UUID, query string, session jwt.Token) (echo.Context, *httptest.ResponseRecorder) {
+	c, rec := newContext(http.MethodGet, "/users/"+ownerId.String()+"/posts?"+query, "")
+	c.SetParamNames("id")
+	c.SetParamValues(ownerId.String())
+	c.Set("session", session)
+	return c, rec
 }
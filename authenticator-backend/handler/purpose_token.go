@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	hankoJwk "github.com/teamhanko/hanko/backend/crypto/jwk"
+	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/webhook"
+)
+
+// purposeTokenVerifier verifies the tokens of the links in notifications. They are signed with the same keys as the
+// session JWTs, but carry a purpose claim, so that neither can be used as the other.
+type purposeTokenVerifier struct {
+	jwtGenerator jwt2.Generator
+}
+
+func newPurposeTokenVerifier(jwkManager hankoJwk.Manager) (*purposeTokenVerifier, error) {
+	signatureKey, err := jwkManager.GetSigningKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signing key: %w", err)
+	}
+	verificationKeys, err := jwkManager.GetPublicKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get verification keys: %w", err)
+	}
+	generator, err := jwt2.NewGenerator(signatureKey, verificationKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jwt generator: %w", err)
+	}
+
+	return &purposeTokenVerifier{jwtGenerator: generator}, nil
+}
+
+// verify returns the subject of the token and the ID of the object it was issued for, which is its JWT ID
+func (v *purposeTokenVerifier) verify(value string, purpose string) (uuid.UUID, uuid.UUID, error) {
+	token, err := v.jwtGenerator.Verify([]byte(value))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, dto.NewHTTPError(http.StatusBadRequest, "invalid token").SetInternal(err)
+	}
+
+	if jwt2.GetPurposeFromToken(token) != purpose {
+		return uuid.Nil, uuid.Nil, dto.NewHTTPError(http.StatusBadRequest, "invalid token").SetInternal(fmt.Errorf("token is not a %s token", purpose))
+	}
+
+	subject, err := uuid.FromString(token.Subject())
+	if err != nil {
+		return uuid.Nil, uuid.Nil, dto.NewHTTPError(http.StatusBadRequest, "invalid token").SetInternal(err)
+	}
+	id, err := uuid.FromString(token.JwtID())
+	if err != nil {
+		return uuid.Nil, uuid.Nil, dto.NewHTTPError(http.StatusBadRequest, "invalid token").SetInternal(err)
+	}
+
+	return subject, id, nil
+}
+
+// revokeRelation deactivates the guest relation on behalf of the account holder, who proved to be the actor with a
+// token from a notification. Revoking an inactive relation has no effect.
+func revokeRelation(c echo.Context, persister persistence.Persister, tx *pop.Connection, relationId uuid.UUID, parentUserId uuid.UUID, now time.Time) error {
+	relationPersister := persister.GetUserGuestRelationPersisterWithConnection(tx)
+	relation, err := relationPersister.Get(relationId)
+	if err != nil {
+		return fmt.Errorf("failed to get user guest relation: %w", err)
+	}
+	if relation == nil || relation.ParentUserID != parentUserId {
+		return dto.NewHTTPError(http.StatusBadRequest, "invalid token").SetInternal(errors.New("user guest relation not found"))
+	}
+	if !relation.IsActive {
+		return nil
+	}
+
+	relation.IsActive = false
+	relation.UpdatedAt = now
+	err = relationPersister.Update(*relation)
+	if err != nil {
+		return fmt.Errorf("failed to revoke user guest relation: %w", err)
+	}
+
+	event := newSecurityEvent(c, models.EventRelationRevoked, &relation.GuestUserID)
+	event.ActorUserId = &relation.ParentUserID
+	event.Metadata["relation_id"] = relation.ID.String()
+	err = persister.GetSecurityEventPersisterWithConnection(tx).Create(event)
+	if err != nil {
+		return fmt.Errorf("failed to create security event: %w", err)
+	}
+
+	return webhook.Enqueue(persister.GetWebhookPersisterWithConnection(tx), models.WebhookEventRelationRevoked, webhook.NewRelationData(*relation, webhook.ReasonRevoked))
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/ratelimit"
+)
+
+// checkRateLimit returns a 429 error with a Retry-After header when one of the keys is still blocked
+func checkRateLimit(c echo.Context, limiter *ratelimit.Limiter, keys ...string) error {
+	retryAfter, err := limiter.Check(keys...)
+	if err != nil {
+		return fmt.Errorf("failed to check rate limit: %w", err)
+	}
+
+	if retryAfter > 0 {
+		c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		return dto.NewHTTPError(http.StatusTooManyRequests).SetInternal(fmt.Errorf("rate limit exceeded for keys %v", keys))
+	}
+
+	return nil
+}
+
+func userRateLimitKey(userId fmt.Stringer) string {
+	return "user:" + userId.String()
+}
+
+func emailRateLimitKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipRateLimitKey(c echo.Context) string {
+	return "ip:" + c.RealIP()
+}
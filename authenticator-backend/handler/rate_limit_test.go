@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+func rateLimitConfig() *config.Config {
+	policy := config.RateLimitPolicy{Attempts: 2, Delay: "1m", MaxDelay: "10m"}
+	return &config.Config{
+		RateLimit: config.RateLimit{
+			Enabled:         true,
+			Store:           config.RateLimitStoreMemory,
+			PasswordLogin:   policy,
+			PasscodeInit:    policy,
+			PasscodeFinish:  policy,
+			UserLookup:      policy,
+			ShareInvitation: policy,
+			RecoveryLogin:   policy,
+			TotpLogin:       policy,
+		},
+	}
+}
+
+func TestPasswordHandler_Login_RateLimited(t *testing.T) {
+	passwords := []models.PasswordCredential{
+		{
+			ID:        generateUuid(t),
+			UserId:    uuid.FromStringOrNil(userId),
+			Password:  generateHash(t, "verybadpassword"),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		},
+	}
+	p := test.NewPersister(users, nil, nil, nil, nil, passwords, nil, nil, nil)
+	handler := NewPasswordHandler(p, sessionManager{}, rateLimitConfig())
+
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	login := func(password string) (*httptest.ResponseRecorder, error) {
+		body := `{"user_id": "` + userId + `", "password": "` + password + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/password/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		return rec, handler.Login(e.NewContext(req, rec))
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := login("wrongpassword")
+		if assert.Error(t, err) {
+			assert.Equal(t, http.StatusUnauthorized, dto.ToHttpError(err).Code)
+		}
+	}
+
+	rec, err := login("verybadpassword")
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusTooManyRequests, dto.ToHttpError(err).Code)
+		assert.Equal(t, "60", rec.Header().Get(echo.HeaderRetryAfter))
+	}
+}
+
+func TestPasscodeHandler_Init_RateLimited(t *testing.T) {
+	passcodeHandler, err := NewPasscodeHandler(rateLimitConfig(), test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil), sessionManager{}, mailer{})
+	require.NoError(t, err)
+
+	bodyJson, err := json.Marshal(dto.PasscodeInitRequest{UserId: userId})
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/passcode/login/initialize", bytes.NewReader(bodyJson))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		assert.NoError(t, passcodeHandler.Init(e.NewContext(req, rec)))
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/passcode/login/initialize", bytes.NewReader(bodyJson))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	err = passcodeHandler.Init(e.NewContext(req, rec))
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusTooManyRequests, dto.ToHttpError(err).Code)
+		assert.Equal(t, "60", rec.Header().Get(echo.HeaderRetryAfter))
+	}
+}
+
+func TestUserHandler_GetUserIdByEmail_RateLimited(t *testing.T) {
+	handler := NewUserHandler(rateLimitConfig(), test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil), sessionManager{})
+
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	lookup := func(email string) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"email": "`+email+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		return rec, handler.GetUserIdByEmail(e.NewContext(req, rec))
+	}
+
+	_, err := lookup("unknown1@example.com")
+	assert.Error(t, err)
+	_, err = lookup("unknown2@example.com")
+	assert.Error(t, err)
+
+	rec, err := lookup("unknown3@example.com")
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusTooManyRequests, dto.ToHttpError(err).Code)
+		assert.Equal(t, "60", rec.Header().Get(echo.HeaderRetryAfter))
+	}
+}
+
+func TestUserHandler_GetUserIdByEmail_DoesNotCountKnownAddresses(t *testing.T) {
+	handler := NewUserHandler(rateLimitConfig(), test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil), sessionManager{})
+
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(`{"email": "john.doe@example.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		if assert.NoError(t, handler.GetUserIdByEmail(e.NewContext(req, rec))) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/crypto"
+	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/ratelimit"
+	"github.com/teamhanko/hanko/backend/session"
+)
+
+const RecoveryCodeCount = 10
+
+type RecoveryCodeHandler struct {
+	persister      persistence.Persister
+	sessionManager session.Manager
+	cfg            *config.Config
+	generator      crypto.RecoveryCodeGenerator
+	hasher         crypto.Hasher
+	limiter        *ratelimit.Limiter
+}
+
+func NewRecoveryCodeHandler(cfg *config.Config, persister persistence.Persister, sessionManager session.Manager) *RecoveryCodeHandler {
+	return &RecoveryCodeHandler{
+		persister:      persister,
+		sessionManager: sessionManager,
+		cfg:            cfg,
+		generator:      crypto.NewRecoveryCodeGenerator(),
+		hasher:         crypto.NewTokenHasher(cfg.Secrets.Keys, cfg.Hashing),
+		limiter:        ratelimit.New(cfg.RateLimit, "recovery_login", cfg.RateLimit.RecoveryLogin, persister),
+	}
+}
+
+type RecoveryCodesResponse struct {
+	Codes []string `json:"codes"`
+}
+
+// Generate replaces all recovery codes of the account holder with new ones. The plain codes are only returned once.
+func (h *RecoveryCodeHandler) Generate(c echo.Context) error {
+	sessionToken, ok := c.Get("session").(jwt.Token)
+	if !ok {
+		return errors.New("failed to cast session object")
+	}
+
+	surrogateId, err := jwt2.GetSurrogateKeyFromToken(sessionToken)
+	if err != nil {
+		return dto.NewHTTPError(http.StatusUnauthorized).SetInternal(fmt.Errorf("unable to get surrogate ID from token: %w", err))
+	}
+
+	if sessionToken.Subject() != surrogateId {
+		return dto.NewHTTPError(http.StatusForbidden).SetInternal(fmt.Errorf("guest %s tried to generate recovery codes for user %s", surrogateId, sessionToken.Subject()))
+	}
+
+	userId := uuid.FromStringOrNil(sessionToken.Subject())
+
+	codes := make([]string, RecoveryCodeCount)
+	err = h.persister.Transaction(func(tx *pop.Connection) error {
+		user, err := h.persister.GetUserPersisterWithConnection(tx).Get(userId)
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		if user == nil {
+			return dto.NewHTTPError(http.StatusNotFound).SetInternal(errors.New("user not found"))
+		}
+
+		codePersister := h.persister.GetRecoveryCodePersisterWithConnection(tx)
+		err = codePersister.DeleteByUserId(user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+
+		now := time.Now().UTC()
+		for i := range codes {
+			codes[i], err = h.generator.Generate()
+			if err != nil {
+				return fmt.Errorf("failed to generate recovery code: %w", err)
+			}
+
+			hashedCode, err := h.hasher.Hash(codes[i])
+			if err != nil {
+				return fmt.Errorf("failed to hash recovery code: %w", err)
+			}
+
+			id, err := uuid.NewV4()
+			if err != nil {
+				return fmt.Errorf("failed to create recovery code id: %w", err)
+			}
+
+			err = codePersister.Create(models.RecoveryCode{
+				ID:        id,
+				UserId:    user.ID,
+				Code:      hashedCode,
+				CreatedAt: now,
+				UpdatedAt: now,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to store recovery code: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, RecoveryCodesResponse{Codes: codes})
+}
+
+type RecoveryLoginBody struct {
+	UserId string `json:"user_id" validate:"required,uuid4"`
+	Code   string `json:"code" validate:"required"`
+}
+
+// Login redeems a recovery code. The session created is restricted until the user registered a new webauthn
+// credential. Failed attempts are rate limited per user and per IP address, as every attempt verifies the code against
+// all remaining codes of the user.
+func (h *RecoveryCodeHandler) Login(c echo.Context) error {
+	var body RecoveryLoginBody
+	if err := (&echo.DefaultBinder{}).BindBody(c, &body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	if err := c.Validate(body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	userId := uuid.FromStringOrNil(body.UserId)
+	code := strings.ToLower(strings.TrimSpace(body.Code))
+
+	rateLimitKeys := []string{userRateLimitKey(userId), ipRateLimitKey(c)}
+	if err := checkRateLimit(c, h.limiter, rateLimitKeys...); err != nil {
+		return err
+	}
+
+	return h.persister.Transaction(func(tx *pop.Connection) error {
+		user, err := h.persister.GetUserPersisterWithConnection(tx).Get(userId)
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		if user == nil || !user.IsActive {
+			if err := h.limiter.Register(rateLimitKeys...); err != nil {
+				return err
+			}
+			return dto.NewHTTPError(http.StatusUnauthorized).SetInternal(fmt.Errorf("user %s not found or not active", body.UserId))
+		}
+
+		codePersister := h.persister.GetRecoveryCodePersisterWithConnection(tx)
+		codes, err := codePersister.GetByUserId(userId)
+		if err != nil {
+			return fmt.Errorf("failed to get recovery codes: %w", err)
+		}
+
+		var redeemed *models.RecoveryCode
+		for i := range codes {
+			valid, err := h.hasher.Verify(codes[i].Code, code)
+			if err != nil {
+				return fmt.Errorf("failed to verify recovery code: %w", err)
+			}
+			if valid {
+				redeemed = &codes[i]
+				break
+			}
+		}
+
+		if redeemed == nil {
+			if err := h.limiter.Register(rateLimitKeys...); err != nil {
+				return err
+			}
+			return dto.NewHTTPError(http.StatusUnauthorized).SetInternal(fmt.Errorf("no matching recovery code found for: %s", body.UserId))
+		}
+
+		ok, err := codePersister.Redeem(*redeemed)
+		if err != nil {
+			return fmt.Errorf("failed to redeem recovery code: %w", err)
+		}
+		if !ok {
+			return dto.NewHTTPError(http.StatusUnauthorized).SetInternal(fmt.Errorf("recovery code of %s was redeemed concurrently", body.UserId))
+		}
+
+		if err := h.limiter.Reset(userRateLimitKey(userId)); err != nil {
+			return err
+		}
+
+		token, err := h.sessionManager.GenerateJWT(userId, userId, uuid.Nil, session.WithRestriction(session.RestrictionRegisterCredential))
+		if err != nil {
+			return fmt.Errorf("failed to generate jwt: %w", err)
+		}
+
+		cookie, err := h.sessionManager.GenerateCookie(token)
+		if err != nil {
+			return fmt.Errorf("failed to create session cookie: %w", err)
+		}
+
+		log := models.LoginAuditLog{
+			UserId:          userId,
+			ClientIpAddress: c.RealIP(),
+			ClientUserAgent: c.Request().UserAgent(),
+			LoginMethod:     dto.LoginMethodToValue(dto.RecoveryCode),
+		}
+		err = h.persister.GetLoginAuditLogPersister().Create(log)
+		if err != nil {
+			return dto.NewHTTPError(http.StatusInternalServerError, "An error occurred generating login audit record", err.Error())
+		}
+
+		c.SetCookie(cookie)
+
+		if h.cfg.Session.EnableAuthTokenHeader {
+			c.Response().Header().Set("X-Auth-Token", token)
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"restriction":              session.RestrictionRegisterCredential,
+			"remaining_recovery_codes": len(codes) - 1,
+		})
+	})
+}
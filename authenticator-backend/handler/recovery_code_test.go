@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+func TestRecoveryCodeHandler_Generate(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/recovery/codes", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	uId := uuid.FromStringOrNil(userId)
+	c.Set("session", generateJwt(t, uId, uId, 5))
+
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	handler := NewRecoveryCodeHandler(&defaultConfig, p, sessionManager{})
+
+	if assert.NoError(t, handler.Generate(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		response := RecoveryCodesResponse{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Len(t, response.Codes, RecoveryCodeCount)
+
+		stored, err := p.GetRecoveryCodePersister().GetByUserId(uId)
+		assert.NoError(t, err)
+		assert.Len(t, stored, RecoveryCodeCount)
+		for _, code := range stored {
+			assert.NotContains(t, response.Codes, code.Code)
+		}
+	}
+}
+
+func TestRecoveryCodeHandler_Generate_Errors_WhenCalledByAGuestUser(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/recovery/codes", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("session", generateJwt(t, uuid.FromStringOrNil(userId), generateUuid(t), 5))
+
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	handler := NewRecoveryCodeHandler(&defaultConfig, p, sessionManager{})
+
+	err := handler.Generate(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusForbidden, dto.ToHttpError(err).Code)
+	}
+}
+
+func newRecoveryCodePersister(t *testing.T, active bool) persistence.Persister {
+	uId := uuid.FromStringOrNil(userId)
+	users := []models.User{{ID: uId, Email: "john.doe@example.com", IsActive: active, CreatedAt: time.Now(), UpdatedAt: time.Now()}}
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, p.GetRecoveryCodePersister().Create(models.RecoveryCode{
+		ID:        generateUuid(t),
+		UserId:    uId,
+		Code:      generateHash(t, "abcde-fghjk"),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}))
+	return p
+}
+
+func recoveryLogin(t *testing.T, handler *RecoveryCodeHandler, code string) (*httptest.ResponseRecorder, error) {
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	bodyJson, err := json.Marshal(RecoveryLoginBody{UserId: userId, Code: code})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/recovery/login", bytes.NewReader(bodyJson))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	return rec, handler.Login(e.NewContext(req, rec))
+}
+
+func TestRecoveryCodeHandler_Login(t *testing.T) {
+	uId := uuid.FromStringOrNil(userId)
+	p := newRecoveryCodePersister(t, true)
+	handler := NewRecoveryCodeHandler(&defaultConfig, p, sessionManager{})
+
+	rec, err := recoveryLogin(t, handler, "ABCDE-FGHJK")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotEmpty(t, rec.Result().Cookies())
+
+		codes, err := p.GetRecoveryCodePersister().GetByUserId(uId)
+		assert.NoError(t, err)
+		assert.Empty(t, codes)
+
+		logs, err := p.GetLoginAuditLogPersister().GetByPrimaryUserId(uId)
+		assert.NoError(t, err)
+		if assert.Len(t, logs, 1) {
+			assert.Equal(t, dto.LoginMethodToValue(dto.RecoveryCode), logs[0].LoginMethod)
+		}
+	}
+
+	_, err = recoveryLogin(t, handler, "ABCDE-FGHJK")
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusUnauthorized, dto.ToHttpError(err).Code)
+	}
+}
+
+func TestRecoveryCodeHandler_Login_Errors_WhenUserIsNotActive(t *testing.T) {
+	p := newRecoveryCodePersister(t, false)
+	handler := NewRecoveryCodeHandler(&defaultConfig, p, sessionManager{})
+
+	_, err := recoveryLogin(t, handler, "ABCDE-FGHJK")
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusUnauthorized, dto.ToHttpError(err).Code)
+	}
+
+	codes, err := p.GetRecoveryCodePersister().GetByUserId(uuid.FromStringOrNil(userId))
+	assert.NoError(t, err)
+	assert.Len(t, codes, 1)
+}
+
+func TestRecoveryCodeHandler_Login_RateLimited(t *testing.T) {
+	handler := NewRecoveryCodeHandler(rateLimitConfig(), newRecoveryCodePersister(t, true), sessionManager{})
+
+	for i := 0; i < 2; i++ {
+		_, err := recoveryLogin(t, handler, "wrong-code")
+		if assert.Error(t, err) {
+			assert.Equal(t, http.StatusUnauthorized, dto.ToHttpError(err).Code)
+		}
+	}
+
+	rec, err := recoveryLogin(t, handler, "ABCDE-FGHJK")
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusTooManyRequests, dto.ToHttpError(err).Code)
+		assert.Equal(t, "60", rec.Header().Get(echo.HeaderRetryAfter))
+	}
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/teamhanko/hanko/backend/actor"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/resource"
+)
+
+// resourceHandler runs the lifecycle of the resources of a type in transactions: writing, changing and deleting them
+// with their versions and recording the activities of guests. The handler of a type decodes the requests and encodes
+// the responses, see PostHandler.
+type resourceHandler struct {
+	persister    persistence.Persister
+	resourceType resource.Type
+	// store returns the store of the type for the transaction
+	store func(tx *pop.Connection) resource.Store
+}
+
+func newResourceHandler(persister persistence.Persister, resourceType resource.Type, store func(tx *pop.Connection) resource.Store) *resourceHandler {
+	return &resourceHandler{persister: persister, resourceType: resourceType, store: store}
+}
+
+// checkVisibility returns an error if the visibility is unknown or the actor may not give it to a resource
+func (h *resourceHandler) checkVisibility(a actor.Actor, visibility string) error {
+	if !resource.IsVisibility(visibility) {
+		return dto.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("visibility must be one of %v", resource.Visibilities))
+	}
+	if !resource.MayPublish(a, visibility) {
+		return dto.NewHTTPError(http.StatusForbidden, fmt.Sprintf("guests can not write private %ss", h.resourceType.Name))
+	}
+	return nil
+}
+
+func (h *resourceHandler) create(a actor.Actor, r resource.Versioned) error {
+	err := h.persister.Transaction(func(tx *pop.Connection) error {
+		err := resource.Create(h.store(tx), a, r, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		return recordGuestActivity(h.persister.GetGuestActivityPersisterWithConnection(tx), a, models.GuestActivityCreated, h.resourceType.Name, r.ResourceId())
+	})
+	return h.toHttpError(err)
+}
+
+// change applies the change to the resource given in the path
+func (h *resourceHandler) change(c echo.Context, a actor.Actor, change func(r resource.Versioned)) (resource.Versioned, error) {
+	id, err := h.resourceId(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed resource.Versioned
+	err = h.persister.Transaction(func(tx *pop.Connection) error {
+		changed, err = resource.Change(h.store(tx), a, id, time.Now().UTC(), change)
+		if err != nil {
+			return err
+		}
+		return recordGuestActivity(h.persister.GetGuestActivityPersisterWithConnection(tx), a, models.GuestActivityUpdated, h.resourceType.Name, id)
+	})
+	if err != nil {
+		return nil, h.toHttpError(err)
+	}
+	return changed, nil
+}
+
+// delete deletes the resource given in the path
+func (h *resourceHandler) delete(c echo.Context, a actor.Actor) error {
+	id, err := h.resourceId(c)
+	if err != nil {
+		return err
+	}
+
+	err = h.persister.Transaction(func(tx *pop.Connection) error {
+		_, err := resource.Delete(h.store(tx), a, id, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		return recordGuestActivity(h.persister.GetGuestActivityPersisterWithConnection(tx), a, models.GuestActivityDeleted, h.resourceType.Name, id)
+	})
+	return h.toHttpError(err)
+}
+
+// versions returns all versions of the resource given in the path, oldest first
+func (h *resourceHandler) versions(c echo.Context, a actor.Actor) ([]resource.Versioned, error) {
+	id, err := h.resourceId(c)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := resource.Versions(h.store(h.persister.GetConnection()), a, id)
+	if err != nil {
+		return nil, h.toHttpError(err)
+	}
+	return versions, nil
+}
+
+func (h *resourceHandler) resourceId(c echo.Context) (uuid.UUID, error) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return uuid.Nil, dto.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("failed to parse %sId as uuid", h.resourceType.Name)).SetInternal(err)
+	}
+	return id, nil
+}
+
+func (h *resourceHandler) toHttpError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, resource.ErrNotFound):
+		return dto.NewHTTPError(http.StatusNotFound, fmt.Sprintf("%s not found", h.resourceType.Name))
+	case errors.Is(err, resource.ErrForbidden):
+		return dto.NewHTTPError(http.StatusForbidden, fmt.Sprintf("%s is not editable", h.resourceType.Name))
+	}
+	return err
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+type RoleHandlerAdmin struct {
+	persister persistence.Persister
+}
+
+// NewRoleHandlerAdmin creates a handler for the assignment of roles to users. The permissions are checked by the
+// Permission middleware.
+func NewRoleHandlerAdmin(persister persistence.Persister) *RoleHandlerAdmin {
+	return &RoleHandlerAdmin{persister: persister}
+}
+
+// List returns all roles with their permissions
+func (h *RoleHandlerAdmin) List(c echo.Context) error {
+	roles, err := h.persister.GetRolePersister().List()
+	if err != nil {
+		return fmt.Errorf("failed to get roles: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, roles)
+}
+
+// ListForUser returns the roles of the user
+func (h *RoleHandlerAdmin) ListForUser(c echo.Context) error {
+	user, err := h.getUser(c)
+	if err != nil {
+		return err
+	}
+
+	roles, err := h.persister.GetRolePersister().FindByUserId(user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get roles: %w", err)
+	}
+	if roles == nil {
+		roles = []models.Role{}
+	}
+
+	return c.JSON(http.StatusOK, roles)
+}
+
+// Assign assigns the role to the user, assigning a role twice has no effect
+func (h *RoleHandlerAdmin) Assign(c echo.Context) error {
+	user, err := h.getUser(c)
+	if err != nil {
+		return err
+	}
+	role, err := h.getRole(c)
+	if err != nil {
+		return err
+	}
+
+	err = h.persister.GetRolePersister().AddToUser(user.ID, role.ID)
+	if err != nil {
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+
+	err = h.createRoleEvent(c, h.persister.GetSecurityEventPersister(), models.EventRoleAssigned, user, role)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Remove removes the role from the user. The last super-admin can't be removed, so that there is always someone who
+// can assign roles. The role is locked while its users are counted, so concurrent removals can't remove all of them.
+func (h *RoleHandlerAdmin) Remove(c echo.Context) error {
+	user, err := h.getUser(c)
+	if err != nil {
+		return err
+	}
+	role, err := h.getRole(c)
+	if err != nil {
+		return err
+	}
+
+	err = h.persister.Transaction(func(tx *pop.Connection) error {
+		rolePersister := h.persister.GetRolePersisterWithConnection(tx)
+		err := rolePersister.Lock(role.ID)
+		if err != nil {
+			return err
+		}
+
+		roles, err := rolePersister.FindByUserId(user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get roles: %w", err)
+		}
+		assigned := false
+		for _, r := range roles {
+			if r.ID == role.ID {
+				assigned = true
+			}
+		}
+		if !assigned {
+			return dto.NewHTTPError(http.StatusNotFound, "role is not assigned to the user")
+		}
+
+		if role.Name == models.RoleSuperAdmin {
+			count, err := rolePersister.CountUsers(role.ID)
+			if err != nil {
+				return fmt.Errorf("failed to count users: %w", err)
+			}
+			if count <= 1 {
+				return dto.NewHTTPError(http.StatusConflict, "the last super-admin can't be removed")
+			}
+		}
+
+		err = rolePersister.RemoveFromUser(user.ID, role.ID)
+		if err != nil {
+			return fmt.Errorf("failed to remove role: %w", err)
+		}
+
+		return h.createRoleEvent(c, h.persister.GetSecurityEventPersisterWithConnection(tx), models.EventRoleRemoved, user, role)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *RoleHandlerAdmin) getUser(c echo.Context) (*models.User, error) {
+	userId, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return nil, dto.NewHTTPError(http.StatusBadRequest, "failed to parse userId as uuid").SetInternal(err)
+	}
+
+	user, err := h.persister.GetUserPersister().Get(userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, dto.NewHTTPError(http.StatusNotFound, "user not found")
+	}
+
+	return user, nil
+}
+
+func (h *RoleHandlerAdmin) getRole(c echo.Context) (*models.Role, error) {
+	role, err := h.persister.GetRolePersister().GetByName(c.Param("role"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	if role == nil {
+		return nil, dto.NewHTTPError(http.StatusNotFound, "role not found")
+	}
+
+	return role, nil
+}
+
+func (h *RoleHandlerAdmin) createRoleEvent(c echo.Context, securityEventPersister persistence.SecurityEventPersister, eventType string, user *models.User, role *models.Role) error {
+	event := newSecurityEvent(c, eventType, &user.ID)
+	event.Metadata["role"] = role.Name
+	err := securityEventPersister.Create(event)
+	if err != nil {
+		return fmt.Errorf("failed to create security event: %w", err)
+	}
+	return nil
+}
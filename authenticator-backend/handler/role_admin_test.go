@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+func newRoleContext(method string, userId string, role string) (echo.Context, *httptest.ResponseRecorder) {
+	c, rec := newContext(method, "/", "")
+	c.SetPath("/users/:id/roles/:role")
+	c.SetParamNames("id", "role")
+	c.SetParamValues(userId, role)
+	return c, rec
+}
+
+func TestRoleHandlerAdmin_List(t *testing.T) {
+	_, persister := createAdmin()
+	c, rec := newRoleContext(http.MethodGet, "", "")
+
+	if assert.NoError(t, NewRoleHandlerAdmin(persister).List(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var roles []models.Role
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &roles))
+		assert.Len(t, roles, 3)
+	}
+}
+
+func TestRoleHandlerAdmin_AssignAndListForUser(t *testing.T) {
+	_, persister := createAdmin()
+	user := generateUser(t)
+	require.NoError(t, persister.GetUserPersister().Create(user))
+	handler := NewRoleHandlerAdmin(persister)
+
+	c, rec := newRoleContext(http.MethodPut, user.ID.String(), models.RoleSupport)
+	if assert.NoError(t, handler.Assign(c)) {
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	}
+	// assigning a role twice has no effect
+	c, _ = newRoleContext(http.MethodPut, user.ID.String(), models.RoleSupport)
+	assert.NoError(t, handler.Assign(c))
+
+	c, rec = newRoleContext(http.MethodGet, user.ID.String(), "")
+	if assert.NoError(t, handler.ListForUser(c)) {
+		var roles []models.Role
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &roles))
+		require.Len(t, roles, 1)
+		assert.Equal(t, models.RoleSupport, roles[0].Name)
+		assert.Contains(t, roles[0].Permissions, models.PermissionAuditRead)
+	}
+}
+
+func TestRoleHandlerAdmin_Assign_NotFound(t *testing.T) {
+	admin, persister := createAdmin()
+	handler := NewRoleHandlerAdmin(persister)
+
+	tests := []struct {
+		name   string
+		userId string
+		role   string
+		code   int
+	}{
+		{name: "unknown role", userId: admin.ID.String(), role: "owner", code: http.StatusNotFound},
+		{name: "unknown user", userId: generateUuid(t).String(), role: models.RoleSupport, code: http.StatusNotFound},
+		{name: "invalid user id", userId: "invalid", role: models.RoleSupport, code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newRoleContext(http.MethodPut, tt.userId, tt.role)
+			err := handler.Assign(c)
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.code, dto.ToHttpError(err).Code)
+			}
+		})
+	}
+}
+
+func TestRoleHandlerAdmin_Remove(t *testing.T) {
+	_, persister := createAdmin()
+	user := generateUser(t)
+	require.NoError(t, persister.GetUserPersister().Create(user))
+	grantRole(persister, user.ID, models.RoleUserAdmin)
+	handler := NewRoleHandlerAdmin(persister)
+
+	c, rec := newRoleContext(http.MethodDelete, user.ID.String(), models.RoleUserAdmin)
+	if assert.NoError(t, handler.Remove(c)) {
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	}
+	roles, err := persister.GetRolePersister().FindByUserId(user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, roles)
+
+	c, _ = newRoleContext(http.MethodDelete, user.ID.String(), models.RoleUserAdmin)
+	err = handler.Remove(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusNotFound, dto.ToHttpError(err).Code)
+	}
+}
+
+func TestRoleHandlerAdmin_Remove_LastSuperAdmin(t *testing.T) {
+	admin, persister := createAdmin()
+	handler := NewRoleHandlerAdmin(persister)
+
+	c, _ := newRoleContext(http.MethodDelete, admin.ID.String(), models.RoleSuperAdmin)
+	err := handler.Remove(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusConflict, dto.ToHttpError(err).Code)
+	}
+
+	// with a second super-admin the role can be removed
+	other := generateUser(t)
+	require.NoError(t, persister.GetUserPersister().Create(other))
+	grantRole(persister, other.ID, models.RoleSuperAdmin)
+
+	c, rec := newRoleContext(http.MethodDelete, admin.ID.String(), models.RoleSuperAdmin)
+	if assert.NoError(t, handler.Remove(c)) {
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	}
+}
+
+func TestUserHandler_Me_Roles(t *testing.T) {
+	admin, persister := createAdmin()
+	guestId := uuid.Must(uuid.NewV4())
+	handler := NewUserHandler(&defaultConfig, persister, sessionManager{})
+
+	tests := []struct {
+		name      string
+		surrogate uuid.UUID
+		isAdmin   bool
+	}{
+		{name: "account holder", surrogate: admin.ID, isAdmin: true},
+		{name: "guest", surrogate: guestId, isAdmin: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newRoleContext(http.MethodGet, "", "")
+			c.Set("session", generateJwt(t, admin.ID, tt.surrogate, 5))
+
+			if assert.NoError(t, handler.Me(c)) {
+				var response MeResponseDto
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+				assert.Equal(t, tt.isAdmin, response.IsAdmin)
+				if tt.isAdmin {
+					assert.Equal(t, []string{models.RoleSuperAdmin}, response.Roles)
+					assert.Contains(t, response.Permissions, models.PermissionRolesManage)
+				} else {
+					assert.Empty(t, response.Roles)
+					assert.Empty(t, response.Permissions)
+				}
+			}
+		})
+	}
+}
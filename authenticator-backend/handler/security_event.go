@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+// newSecurityEvent returns an event of the type for the request. The actor and the surrogate are taken from the
+// session, if there is one.
+func newSecurityEvent(c echo.Context, eventType string, targetUserId *uuid.UUID) models.SecurityEvent {
+	event := models.NewSecurityEvent(eventType)
+	event.TargetUserId = targetUserId
+	event.RequestId = c.Response().Header().Get(echo.HeaderXRequestID)
+	event.ClientIpAddress = c.RealIP()
+	event.ClientUserAgent = c.Request().UserAgent()
+
+	if sessionToken, ok := c.Get("session").(jwt.Token); ok && sessionToken != nil {
+		if actorId, err := uuid.FromString(sessionToken.Subject()); err == nil {
+			event.ActorUserId = &actorId
+		}
+		surrogateId, err := jwt2.GetSurrogateKeyFromToken(sessionToken)
+		if err == nil && surrogateId != sessionToken.Subject() {
+			if id, err := uuid.FromString(surrogateId); err == nil {
+				event.SurrogateUserId = &id
+			}
+		}
+	}
+
+	return event
+}
+
+type SecurityEventHandlerAdmin struct {
+	persister persistence.Persister
+}
+
+// NewSecurityEventHandlerAdmin creates a handler for querying the security events. The permissions are checked by the
+// Permission middleware.
+func NewSecurityEventHandlerAdmin(persister persistence.Persister) *SecurityEventHandlerAdmin {
+	return &SecurityEventHandlerAdmin{persister: persister}
+}
+
+type SecurityEventListRequest struct {
+	PerPage int    `query:"per_page"`
+	Page    int    `query:"page"`
+	UserId  string `query:"user_id"`
+	// Types is a comma separated list of event types, the parameter can also be repeated
+	Types []string   `query:"type"`
+	From  *time.Time `query:"from"`
+	To    *time.Time `query:"to"`
+}
+
+// List returns the security events matching the filter, newest first
+func (h *SecurityEventHandlerAdmin) List(c echo.Context) error {
+	var request SecurityEventListRequest
+	err := (&echo.DefaultBinder{}).BindQueryParams(c, &request)
+	if err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	filter := persistence.SecurityEventFilter{From: request.From, To: request.To}
+	if request.UserId != "" {
+		userId, err := uuid.FromString(request.UserId)
+		if err != nil {
+			return dto.NewHTTPError(http.StatusBadRequest, "failed to parse user_id as uuid").SetInternal(err)
+		}
+		filter.UserId = &userId
+	}
+	for _, value := range request.Types {
+		for _, eventType := range strings.Split(value, ",") {
+			if eventType = strings.TrimSpace(eventType); eventType != "" {
+				filter.Types = append(filter.Types, eventType)
+			}
+		}
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return dto.NewHTTPError(http.StatusBadRequest, "from must be before to")
+	}
+
+	page, perPage := normalizePagination(request.Page, request.PerPage)
+	events, total, err := h.persister.GetSecurityEventPersister().Search(filter, page, perPage)
+	if err != nil {
+		return fmt.Errorf("failed to get security events: %w", err)
+	}
+
+	setPaginationHeaders(c, page, perPage, total)
+	return c.JSON(http.StatusOK, events)
+}
@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+func TestNewSecurityEvent(t *testing.T) {
+	accountHolderId := generateUuid(t)
+	guestId := generateUuid(t)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Response().Header().Set(echo.HeaderXRequestID, "request-1")
+	token := jwt.New()
+	require.NoError(t, token.Set(jwt.SubjectKey, accountHolderId.String()))
+	require.NoError(t, token.Set(jwt2.SurrogateKey, guestId.String()))
+	c.Set("session", token)
+
+	event := newSecurityEvent(c, models.EventGuestLogout, &accountHolderId)
+
+	assert.Equal(t, models.EventGuestLogout, event.Type)
+	assert.Equal(t, &accountHolderId, event.ActorUserId)
+	assert.Equal(t, &guestId, event.SurrogateUserId)
+	assert.Equal(t, &accountHolderId, event.TargetUserId)
+	assert.Equal(t, "request-1", event.RequestId)
+	assert.Equal(t, "test-agent", event.ClientUserAgent)
+	assert.NotEmpty(t, event.ClientIpAddress)
+}
+
+func TestNewSecurityEvent_RecordsTheClientBehindATrustedProxy(t *testing.T) {
+	c, _ := newContext(http.MethodPost, "/", "")
+	_, proxies, err := net.ParseCIDR("192.0.2.0/24")
+	require.NoError(t, err)
+	c.Echo().IPExtractor = echo.ExtractIPFromXFFHeader(echo.TrustIPRange(proxies))
+	c.Request().Header.Set(echo.HeaderXForwardedFor, "203.0.113.7")
+
+	event := newSecurityEvent(c, models.EventSessionsRevoked, nil)
+	assert.Equal(t, "203.0.113.7", event.ClientIpAddress)
+}
+
+func TestSecurityEventHandlerAdmin_List(t *testing.T) {
+	_, persister := createAdmin()
+	userId := generateUuid(t)
+	otherUserId := generateUuid(t)
+	now := time.Now().UTC()
+
+	createSecurityEvent(t, persister, models.EventGrantCreated, &userId, nil, now.Add(-3*time.Hour))
+	createSecurityEvent(t, persister, models.EventPasswordChanged, &userId, &userId, now.Add(-2*time.Hour))
+	createSecurityEvent(t, persister, models.EventUserDeactivated, &otherUserId, &userId, now.Add(-1*time.Hour))
+	createSecurityEvent(t, persister, models.EventPasswordChanged, &otherUserId, &otherUserId, now)
+
+	tests := []struct {
+		name  string
+		query url.Values
+		types []string
+	}{
+		{
+			name:  "no filter, newest first",
+			query: url.Values{},
+			types: []string{models.EventPasswordChanged, models.EventUserDeactivated, models.EventPasswordChanged, models.EventGrantCreated},
+		},
+		{
+			name:  "user as actor or target",
+			query: url.Values{"user_id": {userId.String()}},
+			types: []string{models.EventUserDeactivated, models.EventPasswordChanged, models.EventGrantCreated},
+		},
+		{
+			name:  "comma separated types",
+			query: url.Values{"type": {models.EventGrantCreated + "," + models.EventUserDeactivated}},
+			types: []string{models.EventUserDeactivated, models.EventGrantCreated},
+		},
+		{
+			name:  "repeated types",
+			query: url.Values{"type": {models.EventGrantCreated, models.EventUserDeactivated}, "user_id": {otherUserId.String()}},
+			types: []string{models.EventUserDeactivated},
+		},
+		{
+			name:  "time range",
+			query: url.Values{"from": {now.Add(-150 * time.Minute).Format(time.RFC3339)}, "to": {now.Add(-30 * time.Minute).Format(time.RFC3339)}},
+			types: []string{models.EventUserDeactivated, models.EventPasswordChanged},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/security-events?"+tt.query.Encode(), nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if assert.NoError(t, NewSecurityEventHandlerAdmin(persister).List(c)) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				var events []models.SecurityEvent
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &events))
+				types := []string{}
+				for _, event := range events {
+					types = append(types, event.Type)
+				}
+				assert.Equal(t, tt.types, types)
+				assert.Equal(t, fmt.Sprint(len(tt.types)), rec.Header().Get("X-Total-Count"))
+			}
+		})
+	}
+}
+
+func TestSecurityEventHandlerAdmin_List_InvalidFilter(t *testing.T) {
+	_, persister := createAdmin()
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name  string
+		query url.Values
+	}{
+		{name: "invalid user id", query: url.Values{"user_id": {"invalid"}}},
+		{name: "invalid time", query: url.Values{"from": {"yesterday"}}},
+		{name: "from after to", query: url.Values{"from": {now.Format(time.RFC3339)}, "to": {now.Add(-time.Hour).Format(time.RFC3339)}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/security-events?"+tt.query.Encode(), nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := NewSecurityEventHandlerAdmin(persister).List(c)
+			if assert.Error(t, err) {
+				httpError := dto.ToHttpError(err)
+				assert.Equal(t, http.StatusBadRequest, httpError.Code)
+			}
+		})
+	}
+}
+
+func TestUserHandlerAdmin_ToggleIsActiveForUser_CreatesSecurityEvent(t *testing.T) {
+	userId := generateUuid(t)
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPut, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/users/active/:id")
+	c.SetParamNames("id")
+	c.SetParamValues(userId.String())
+
+	adminUser, persister := createAdmin()
+	setSessionToken(t, c, adminUser)
+	require.NoError(t, persister.GetUserPersister().Create(models.User{ID: userId, Email: "testy@example.com", IsActive: true}))
+
+	require.NoError(t, NewUserHandlerAdmin(persister).ToggleIsActiveForUser(c))
+
+	events, _, err := persister.GetSecurityEventPersister().Search(persistence.SecurityEventFilter{UserId: &userId}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.EventUserDeactivated, events[0].Type)
+	assert.Equal(t, &adminUser.ID, events[0].ActorUserId)
+	assert.Nil(t, events[0].SurrogateUserId)
+	assert.Equal(t, &userId, events[0].TargetUserId)
+}
+
+func TestRoleHandlerAdmin_Assign_CreatesSecurityEvent(t *testing.T) {
+	adminUser, persister := createAdmin()
+	user := generateUser(t)
+	require.NoError(t, persister.GetUserPersister().Create(user))
+
+	c, _ := newRoleContext(http.MethodPut, user.ID.String(), models.RoleSupport)
+	setSessionToken(t, c, adminUser)
+	require.NoError(t, NewRoleHandlerAdmin(persister).Assign(c))
+
+	events, _, err := persister.GetSecurityEventPersister().Search(persistence.SecurityEventFilter{Types: []string{models.EventRoleAssigned}}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, &adminUser.ID, events[0].ActorUserId)
+	assert.Equal(t, &user.ID, events[0].TargetUserId)
+	assert.Equal(t, models.RoleSupport, events[0].Metadata["role"])
+}
+
+func TestUserHandler_LogoutAsGuest_CreatesSecurityEvent(t *testing.T) {
+	accountHolder := generateUser(t)
+	guest := generateUser(t)
+	persister := test.NewPersister([]models.User{accountHolder, guest}, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/users/logout-guest", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	token := jwt.New()
+	require.NoError(t, token.Set(jwt.SubjectKey, accountHolder.ID.String()))
+	require.NoError(t, token.Set(jwt2.SurrogateKey, guest.ID.String()))
+	c.Set("session", token)
+
+	require.NoError(t, NewUserHandler(&defaultConfig, persister, sessionManager{}).LogoutAsGuest(c))
+
+	events, _, err := persister.GetSecurityEventPersister().Search(persistence.SecurityEventFilter{}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.EventGuestLogout, events[0].Type)
+	assert.Equal(t, &accountHolder.ID, events[0].ActorUserId)
+	assert.Equal(t, &guest.ID, events[0].SurrogateUserId)
+
+	// guest logouts are no longer recorded as logins
+	logs, err := persister.GetLoginAuditLogPersister().GetByGuestUserId(guest.ID)
+	require.NoError(t, err)
+	assert.Empty(t, logs)
+	logs, err = persister.GetLoginAuditLogPersister().GetByPrimaryUserId(guest.ID)
+	require.NoError(t, err)
+	assert.Empty(t, logs)
+}
+
+func createSecurityEvent(t *testing.T, persister persistence.Persister, eventType string, actorId *uuid.UUID, targetId *uuid.UUID, createdAt time.Time) {
+	event := models.NewSecurityEvent(eventType)
+	event.ActorUserId = actorId
+	event.TargetUserId = targetId
+	event.CreatedAt = createdAt
+	require.NoError(t, persister.GetSecurityEventPersister().Create(event))
+}
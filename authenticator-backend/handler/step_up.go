@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/teamhanko/hanko/backend/config"
+	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/session"
+)
+
+type StepUpHandler struct {
+	persister      persistence.Persister
+	webauthn       *webauthn.WebAuthn
+	sessionManager session.Manager
+	cfg            *config.Config
+}
+
+// NewStepUpHandler creates a new handler which re-authenticates the current session with a user verified assertion
+func NewStepUpHandler(cfg *config.Config, persister persistence.Persister, sessionManager session.Manager) (*StepUpHandler, error) {
+	f := false
+	wa, err := webauthn.New(&webauthn.Config{
+		RPDisplayName:         cfg.Webauthn.RelyingParty.DisplayName,
+		RPID:                  cfg.Webauthn.RelyingParty.Id,
+		RPOrigin:              cfg.Webauthn.RelyingParty.Origin,
+		AttestationPreference: protocol.PreferNoAttestation,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			RequireResidentKey: &f,
+			ResidentKey:        protocol.ResidentKeyRequirementDiscouraged,
+			UserVerification:   protocol.VerificationRequired,
+		},
+		Timeout: cfg.Webauthn.Timeout,
+		Debug:   false,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webauthn instance: %w", err)
+	}
+
+	return &StepUpHandler{
+		persister:      persister,
+		webauthn:       wa,
+		sessionManager: sessionManager,
+		cfg:            cfg,
+	}, nil
+}
+
+type StepUpResponse struct {
+	AuthTime time.Time `json:"auth_time"`
+	MaxAge   int       `json:"max_age"`
+}
+
+// BeginStepUp returns user verified credential assertion options for the account holder. Guest sessions can not be
+// stepped up, as the guest would re-authenticate with their own credentials only.
+func (h *StepUpHandler) BeginStepUp(c echo.Context) error {
+	sessionToken, ok := c.Get("session").(jwt.Token)
+	if !ok {
+		return errors.New("failed to cast session object")
+	}
+
+	surrogateId, err := jwt2.GetSurrogateKeyFromToken(sessionToken)
+	if err != nil {
+		return dto.NewHTTPError(http.StatusUnauthorized).SetInternal(fmt.Errorf("unable to get surrogate ID from token: %w", err))
+	}
+
+	if surrogateId != sessionToken.Subject() {
+		return dto.NewHTTPError(http.StatusForbidden, "guests can not step up").SetInternal(fmt.Errorf("guest %s tried to step up the session of user %s", surrogateId, sessionToken.Subject()))
+	}
+
+	webauthnUser, err := getWebauthnUser(h.persister, h.persister.GetConnection(), uuid.FromStringOrNil(surrogateId))
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if webauthnUser == nil {
+		return dto.NewHTTPError(http.StatusBadRequest, "user not found").SetInternal(fmt.Errorf("user %s not found", surrogateId))
+	}
+
+	options, err := beginUserVerifiedAssertion(h.webauthn, h.persister, webauthnUser)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, options)
+}
+
+// FinishStepUp validates the assertion and on success re-issues the session JWT stamped with the time of the
+// re-authentication.
+func (h *StepUpHandler) FinishStepUp(c echo.Context) error {
+	sessionToken, ok := c.Get("session").(jwt.Token)
+	if !ok {
+		return errors.New("failed to cast session object")
+	}
+
+	surrogateId, err := jwt2.GetSurrogateKeyFromToken(sessionToken)
+	if err != nil {
+		return dto.NewHTTPError(http.StatusUnauthorized).SetInternal(fmt.Errorf("unable to get surrogate ID from token: %w", err))
+	}
+
+	if surrogateId != sessionToken.Subject() {
+		return dto.NewHTTPError(http.StatusForbidden, "guests can not step up").SetInternal(fmt.Errorf("guest %s tried to step up the session of user %s", surrogateId, sessionToken.Subject()))
+	}
+
+	request, err := protocol.ParseCredentialRequestResponse(c.Request())
+	if err != nil {
+		return dto.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if !request.Response.AuthenticatorData.Flags.UserVerified() {
+		return dto.NewHTTPError(http.StatusUnauthorized, "user verification required")
+	}
+
+	_, webauthnUser, err := finishAssertion(h.webauthn, h.persister, request)
+	if err != nil {
+		return err
+	}
+
+	if webauthnUser.UserId.String() != surrogateId {
+		return dto.NewHTTPError(http.StatusForbidden).SetInternal(fmt.Errorf("user %s tried to step up session of user %s", webauthnUser.UserId, surrogateId))
+	}
+
+	token, err := h.sessionManager.GenerateJWT(webauthnUser.UserId, webauthnUser.UserId, uuid.Nil, session.WithAuthenticationMethods(jwt2.AmrHardwareKey, jwt2.AmrUser))
+	if err != nil {
+		return fmt.Errorf("failed to generate jwt: %w", err)
+	}
+
+	cookie, err := h.sessionManager.GenerateCookie(token)
+	if err != nil {
+		return fmt.Errorf("failed to create session cookie: %w", err)
+	}
+
+	c.SetCookie(cookie)
+
+	if h.cfg.Session.EnableAuthTokenHeader {
+		c.Response().Header().Set("X-Auth-Token", token)
+	}
+
+	maxAge, _ := time.ParseDuration(h.cfg.Session.StepUp.MaxAge)
+
+	return c.JSON(http.StatusOK, StepUpResponse{
+		AuthTime: time.Now().UTC(),
+		MaxAge:   int(maxAge.Seconds()),
+	})
+}
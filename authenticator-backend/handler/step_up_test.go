@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+var stepUpAssertionBody = `{
+"id": "AaFdkcD4SuPjF-jwUoRwH8-ZHuY5RW46fsZmEvBX6RNKHaGtVzpATs06KQVheIOjYz-YneG4cmQOedzl0e0jF951ukx17Hl9jeGgWz5_DKZCO12p2-2LlzjH",
+"rawId": "AaFdkcD4SuPjF-jwUoRwH8-ZHuY5RW46fsZmEvBX6RNKHaGtVzpATs06KQVheIOjYz-YneG4cmQOedzl0e0jF951ukx17Hl9jeGgWz5_DKZCO12p2-2LlzjH",
+"type": "public-key",
+"response": {
+"authenticatorData": "SZYN5YgOjGh0NBcPZHZgW4_krrmihjLHmVzzuoMdl2MFYmezOw",
+"clientDataJSON": "eyJ0eXBlIjoid2ViYXV0aG4uZ2V0IiwiY2hhbGxlbmdlIjoiZ0tKS21oOTB2T3BZTzU1b0hwcWFIWF9vTUNxNG9UWnQtRDBiNnRlSXpyRSIsIm9yaWdpbiI6Imh0dHA6Ly9sb2NhbGhvc3Q6ODA4MCIsImNyb3NzT3JpZ2luIjpmYWxzZX0",
+"signature": "MEYCIQDi2vYVspG6pf38I4GyQCPOojGbvX4nwSPXCi0hm80twAIhAO3EWjhAnj0UpjU_l0AH5sEh3zq4LDvkvo3AUqaqfGYD",
+"userHandle": "7E7wSVuIQyGhcyGw7_BqBA"
+}
+}`
+
+func stepUpConfig() *config.Config {
+	cfg := defaultConfig
+	cfg.Session.StepUp.MaxAge = "5m"
+	return &cfg
+}
+
+func TestStepUpHandler_BeginStepUp(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/step-up/initialize", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	uId := uuid.FromStringOrNil(userId)
+	c.Set("session", generateJwt(t, uId, uId, 5))
+
+	p := test.NewPersister(users, nil, nil, credentials, nil, nil, nil, nil, nil)
+	handler, err := NewStepUpHandler(stepUpConfig(), p, sessionManager{})
+	require.NoError(t, err)
+
+	if assert.NoError(t, handler.BeginStepUp(c)) {
+		assert.Equal(t, http.StatusOK, rec.Result().StatusCode)
+		assertionOptions := protocol.CredentialAssertion{}
+		err = json.Unmarshal(rec.Body.Bytes(), &assertionOptions)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, assertionOptions.Response.Challenge)
+		assert.Equal(t, protocol.VerificationRequired, assertionOptions.Response.UserVerification)
+		assert.NotEmpty(t, assertionOptions.Response.AllowedCredentials)
+	}
+}
+
+func TestStepUpHandler_BeginStepUp_Errors_WhenUserNotFound(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/step-up/initialize", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	uId := generateUuid(t)
+	c.Set("session", generateJwt(t, uId, uId, 5))
+
+	p := test.NewPersister(users, nil, nil, credentials, nil, nil, nil, nil, nil)
+	handler, err := NewStepUpHandler(stepUpConfig(), p, sessionManager{})
+	require.NoError(t, err)
+
+	err = handler.BeginStepUp(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusBadRequest, dto.ToHttpError(err).Code)
+	}
+}
+
+func TestStepUpHandler_FinishStepUp(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/step-up/finalize", strings.NewReader(stepUpAssertionBody))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	uId := uuid.FromStringOrNil(userId)
+	c.Set("session", generateJwt(t, uId, uId, 5))
+
+	p := test.NewPersister(users, nil, nil, credentials, sessionData, nil, nil, nil, nil)
+	handler, err := NewStepUpHandler(stepUpConfig(), p, sessionManager{})
+	require.NoError(t, err)
+
+	if assert.NoError(t, handler.FinishStepUp(c)) {
+		assert.Equal(t, http.StatusOK, rec.Result().StatusCode)
+		assert.NotEmpty(t, rec.Result().Cookies())
+		response := StepUpResponse{}
+		err = json.Unmarshal(rec.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, 300, response.MaxAge)
+		assert.False(t, response.AuthTime.IsZero())
+	}
+}
+
+func TestStepUpHandler_FinishStepUp_Errors_WhenAssertionIsFromAnotherUser(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/step-up/finalize", strings.NewReader(stepUpAssertionBody))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	uId := generateUuid(t)
+	c.Set("session", generateJwt(t, uId, uId, 5))
+
+	p := test.NewPersister(users, nil, nil, credentials, sessionData, nil, nil, nil, nil)
+	handler, err := NewStepUpHandler(stepUpConfig(), p, sessionManager{})
+	require.NoError(t, err)
+
+	err = handler.FinishStepUp(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusForbidden, dto.ToHttpError(err).Code)
+	}
+}
+
+func TestStepUpHandler_Errors_WhenCalledByAGuestUser(t *testing.T) {
+	p := test.NewPersister(users, nil, nil, credentials, sessionData, nil, nil, nil, nil)
+	handler, err := NewStepUpHandler(stepUpConfig(), p, sessionManager{})
+	require.NoError(t, err)
+
+	for name, step := range map[string]echo.HandlerFunc{"initialize": handler.BeginStepUp, "finalize": handler.FinishStepUp} {
+		t.Run(name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/step-up/"+name, strings.NewReader(stepUpAssertionBody))
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			// the guest's own passkey must not step up the session of the account holder
+			c.Set("session", generateJwt(t, generateUuid(t), uuid.FromStringOrNil(userId), 5))
+
+			err := step(c)
+			if assert.Error(t, err) {
+				assert.Equal(t, http.StatusForbidden, dto.ToHttpError(err).Code)
+			}
+		})
+	}
+}
+
+func TestWebauthnHandler_DeleteCredential(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodDelete, "/webauthn/credentials/"+credentials[0].ID, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(credentials[0].ID)
+	uId := uuid.FromStringOrNil(userId)
+	c.Set("session", generateJwt(t, uId, uId, 5))
+
+	p := test.NewPersister(users, nil, nil, credentials, nil, nil, nil, nil, nil)
+	handler, err := NewWebauthnHandler(&defaultConfig, p, sessionManager{})
+	require.NoError(t, err)
+
+	if assert.NoError(t, handler.DeleteCredential(c)) {
+		assert.Equal(t, http.StatusNoContent, rec.Result().StatusCode)
+		credential, err := p.GetWebauthnCredentialPersister().Get(credentials[0].ID)
+		assert.NoError(t, err)
+		assert.Nil(t, credential)
+	}
+}
+
+func TestWebauthnHandler_DeleteCredential_Errors_WhenCalledByAGuestUser(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodDelete, "/webauthn/credentials/"+credentials[0].ID, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(credentials[0].ID)
+	c.Set("session", generateJwt(t, uuid.FromStringOrNil(userId), generateUuid(t), 5))
+
+	p := test.NewPersister(users, nil, nil, credentials, nil, nil, nil, nil, nil)
+	handler, err := NewWebauthnHandler(&defaultConfig, p, sessionManager{})
+	require.NoError(t, err)
+
+	err = handler.DeleteCredential(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusForbidden, dto.ToHttpError(err).Code)
+	}
+}
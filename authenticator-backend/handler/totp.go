@@ -0,0 +1,395 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/crypto/aes_gcm"
+	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/crypto/totp"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/ratelimit"
+	"github.com/teamhanko/hanko/backend/session"
+)
+
+type TotpHandler struct {
+	persister      persistence.Persister
+	sessionManager session.Manager
+	cfg            *config.Config
+	aes            *aes_gcm.AESGCM
+	limiter        *ratelimit.Limiter
+	// attempts counts the invalid codes per restricted session. A session is blocked for the lifespan of restricted
+	// sessions after the maximum attempts, i.e. it can not be used anymore.
+	attempts *ratelimit.Limiter
+}
+
+// NewTotpHandler creates a new handler for the enrolment and verification of TOTP as second factor. The shared
+// secrets are encrypted with the configured secret keys.
+func NewTotpHandler(cfg *config.Config, persister persistence.Persister, sessionManager session.Manager) (*TotpHandler, error) {
+	aes, err := aes_gcm.NewAESGCM(cfg.Secrets.Keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create aes instance: %w", err)
+	}
+
+	attemptsPolicy := config.RateLimitPolicy{
+		Attempts: cfg.SecondFactor.MaxAttempts,
+		Delay:    cfg.SecondFactor.Lifespan,
+		MaxDelay: cfg.SecondFactor.Lifespan,
+	}
+
+	return &TotpHandler{
+		persister:      persister,
+		sessionManager: sessionManager,
+		cfg:            cfg,
+		aes:            aes,
+		limiter:        ratelimit.New(cfg.RateLimit, "totp_login", cfg.RateLimit.TotpLogin, persister),
+		attempts:       ratelimit.NewLimiter(ratelimit.NewStore(cfg.RateLimit, persister), "totp_session", attemptsPolicy),
+	}, nil
+}
+
+// BeginEnrollment creates a new shared secret for the account holder and returns it together with the provisioning
+// URI. The secret is not used as second factor until the enrolment is finished with a valid code.
+func (h *TotpHandler) BeginEnrollment(c echo.Context) error {
+	sessionToken, userId, err := h.getAccountHolder(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.persister.GetUserPersister().Get(userId)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return dto.NewHTTPError(http.StatusNotFound).SetInternal(errors.New("user not found"))
+	}
+
+	credentialPersister := h.persister.GetTotpCredentialPersister()
+	credential, err := credentialPersister.GetByUserId(userId)
+	if err != nil {
+		return fmt.Errorf("failed to get totp credential: %w", err)
+	}
+
+	if credential != nil && credential.Confirmed {
+		if jwt2.GetRestrictionFromToken(sessionToken) != "" {
+			return dto.NewHTTPError(http.StatusForbidden).SetInternal(fmt.Errorf("user %s tried to replace the totp credential with a restricted session", userId))
+		}
+		return dto.NewHTTPError(http.StatusConflict, "totp is already enrolled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return err
+	}
+
+	encryptedSecret, err := h.aes.Encrypt(secret)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt totp secret: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if credential == nil {
+		id, err := uuid.NewV4()
+		if err != nil {
+			return fmt.Errorf("failed to create totp credential id: %w", err)
+		}
+		err = credentialPersister.Create(models.TotpCredential{
+			ID:        id,
+			UserId:    userId,
+			Secret:    encryptedSecret,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to store totp credential: %w", err)
+		}
+	} else {
+		credential.Secret = encryptedSecret
+		credential.UpdatedAt = now
+		err = credentialPersister.Update(*credential)
+		if err != nil {
+			return fmt.Errorf("failed to update totp credential: %w", err)
+		}
+	}
+
+	return c.JSON(http.StatusOK, dto.TotpEnrollmentResponse{
+		Secret: totp.EncodeSecret(secret),
+		Uri:    totp.ProvisioningURI(secret, h.cfg.Service.Name, user.Email),
+	})
+}
+
+// FinishEnrollment confirms the enrolment with a code from the authenticator app. A session which was waiting for
+// the second factor is upgraded to a full session.
+func (h *TotpHandler) FinishEnrollment(c echo.Context) error {
+	sessionToken, userId, err := h.getAccountHolder(c)
+	if err != nil {
+		return err
+	}
+
+	var body dto.TotpCodeRequest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	if err := c.Validate(body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	credentialPersister := h.persister.GetTotpCredentialPersister()
+	credential, err := credentialPersister.GetByUserId(userId)
+	if err != nil {
+		return fmt.Errorf("failed to get totp credential: %w", err)
+	}
+
+	if credential == nil || credential.Confirmed {
+		return dto.NewHTTPError(http.StatusBadRequest, "no pending totp enrolment")
+	}
+
+	step, err := h.verifyCode(c, sessionToken, credential, body.Code)
+	if err != nil {
+		return err
+	}
+
+	err = h.claimStep(credentialPersister, credential, step)
+	if err != nil {
+		return err
+	}
+
+	credential.Confirmed = true
+	credential.LastUsedStep = step
+	credential.UpdatedAt = time.Now().UTC()
+	err = credentialPersister.Update(*credential)
+	if err != nil {
+		return fmt.Errorf("failed to update totp credential: %w", err)
+	}
+
+	if jwt2.GetRestrictionFromToken(sessionToken) == session.RestrictionSecondFactor {
+		err = h.issueSession(c, userId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Login completes the second factor of a restricted session and upgrades it to a full session
+func (h *TotpHandler) Login(c echo.Context) error {
+	sessionToken, userId, err := h.getAccountHolder(c)
+	if err != nil {
+		return err
+	}
+
+	if jwt2.GetRestrictionFromToken(sessionToken) != session.RestrictionSecondFactor {
+		return dto.NewHTTPError(http.StatusBadRequest, "no second factor pending")
+	}
+
+	var body dto.TotpCodeRequest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	if err := c.Validate(body); err != nil {
+		return dto.ToHttpError(err)
+	}
+
+	credentialPersister := h.persister.GetTotpCredentialPersister()
+	credential, err := credentialPersister.GetByUserId(userId)
+	if err != nil {
+		return fmt.Errorf("failed to get totp credential: %w", err)
+	}
+
+	if credential == nil || !credential.Confirmed {
+		return dto.NewHTTPError(http.StatusBadRequest, "totp is not enrolled")
+	}
+
+	step, err := h.verifyCode(c, sessionToken, credential, body.Code)
+	if err != nil {
+		return err
+	}
+
+	err = h.claimStep(credentialPersister, credential, step)
+	if err != nil {
+		return err
+	}
+
+	log := models.LoginAuditLog{
+		UserId:          userId,
+		ClientIpAddress: c.RealIP(),
+		ClientUserAgent: c.Request().UserAgent(),
+		LoginMethod:     dto.LoginMethodToValue(dto.Totp),
+	}
+	err = h.persister.GetLoginAuditLogPersister().Create(log)
+	if err != nil {
+		return dto.NewHTTPError(http.StatusInternalServerError, "An error occurred generating login audit record", err.Error())
+	}
+
+	err = h.issueSession(c, userId)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Delete removes the totp credential of the account holder
+func (h *TotpHandler) Delete(c echo.Context) error {
+	_, userId, err := h.getAccountHolder(c)
+	if err != nil {
+		return err
+	}
+
+	credentialPersister := h.persister.GetTotpCredentialPersister()
+	credential, err := credentialPersister.GetByUserId(userId)
+	if err != nil {
+		return fmt.Errorf("failed to get totp credential: %w", err)
+	}
+
+	if credential == nil {
+		return dto.NewHTTPError(http.StatusNotFound, "totp is not enrolled")
+	}
+
+	err = credentialPersister.Delete(*credential)
+	if err != nil {
+		return fmt.Errorf("failed to delete totp credential: %w", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *TotpHandler) getAccountHolder(c echo.Context) (jwt.Token, uuid.UUID, error) {
+	sessionToken, ok := c.Get("session").(jwt.Token)
+	if !ok {
+		return nil, uuid.Nil, errors.New("failed to cast session object")
+	}
+
+	surrogateId, err := jwt2.GetSurrogateKeyFromToken(sessionToken)
+	if err != nil {
+		return nil, uuid.Nil, dto.NewHTTPError(http.StatusUnauthorized).SetInternal(fmt.Errorf("unable to get surrogate ID from token: %w", err))
+	}
+
+	if sessionToken.Subject() != surrogateId {
+		return nil, uuid.Nil, dto.NewHTTPError(http.StatusForbidden).SetInternal(fmt.Errorf("guest %s tried to manage totp of user %s", surrogateId, sessionToken.Subject()))
+	}
+
+	return sessionToken, uuid.FromStringOrNil(surrogateId), nil
+}
+
+// verifyCode validates the code while counting invalid codes per user and per restricted session. The attempts of the
+// user are rate limited, a restricted session is rejected after the maximum attempts, so the user has to log in again.
+func (h *TotpHandler) verifyCode(c echo.Context, sessionToken jwt.Token, credential *models.TotpCredential, code string) (int64, error) {
+	userKey := userRateLimitKey(credential.UserId)
+	if err := checkRateLimit(c, h.limiter, userKey); err != nil {
+		return 0, err
+	}
+
+	var sessionKeys []string
+	if jwt2.GetRestrictionFromToken(sessionToken) == session.RestrictionSecondFactor {
+		sessionKeys = append(sessionKeys, fmt.Sprintf("session:%s:%d", credential.UserId, sessionToken.IssuedAt().Unix()))
+		blocked, err := h.attempts.Check(sessionKeys...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to check second factor attempts: %w", err)
+		}
+		if blocked > 0 {
+			return 0, dto.NewHTTPError(http.StatusUnauthorized, "too many invalid codes, log in again").SetInternal(fmt.Errorf("restricted session of user %s exceeded the second factor attempts", credential.UserId))
+		}
+	}
+
+	step, valid, err := h.validateCode(credential, code)
+	if err != nil {
+		return 0, err
+	}
+	if !valid {
+		if err := h.limiter.Register(userKey); err != nil {
+			return 0, err
+		}
+		if err := h.attempts.Register(sessionKeys...); err != nil {
+			return 0, err
+		}
+		return 0, dto.NewHTTPError(http.StatusUnauthorized, "invalid code").SetInternal(fmt.Errorf("invalid or already used totp code of user %s", credential.UserId))
+	}
+
+	if err := h.limiter.Reset(userKey); err != nil {
+		return 0, err
+	}
+
+	return step, nil
+}
+
+// validateCode checks the code and rejects codes of a time step which has already been used
+func (h *TotpHandler) validateCode(credential *models.TotpCredential, code string) (int64, bool, error) {
+	secret, err := h.aes.Decrypt(credential.Secret)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to decrypt totp secret: %w", err)
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now().UTC())
+	if !ok || step <= credential.LastUsedStep {
+		return 0, false, nil
+	}
+
+	return step, true, nil
+}
+
+// claimStep records the step of a valid code. A parallel request with a code of the same step may have claimed it
+// after the code was validated, the code is rejected then.
+func (h *TotpHandler) claimStep(credentialPersister persistence.TotpCredentialPersister, credential *models.TotpCredential, step int64) error {
+	claimed, err := credentialPersister.ClaimStep(credential.ID, step, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to update totp credential: %w", err)
+	}
+	if !claimed {
+		return dto.NewHTTPError(http.StatusUnauthorized, "invalid code").SetInternal(fmt.Errorf("totp code of user %s has already been used", credential.UserId))
+	}
+
+	return nil
+}
+
+func (h *TotpHandler) issueSession(c echo.Context, userId uuid.UUID) error {
+	token, err := h.sessionManager.GenerateJWT(userId, userId, uuid.Nil)
+	if err != nil {
+		return fmt.Errorf("failed to generate jwt: %w", err)
+	}
+
+	cookie, err := h.sessionManager.GenerateCookie(token)
+	if err != nil {
+		return fmt.Errorf("failed to create session cookie: %w", err)
+	}
+
+	c.SetCookie(cookie)
+
+	if h.cfg.Session.EnableAuthTokenHeader {
+		c.Response().Header().Set("X-Auth-Token", token)
+	}
+
+	return nil
+}
+
+// secondFactorLifespan returns how long the restricted session waiting for the second factor is valid
+func secondFactorLifespan(cfg *config.Config) time.Duration {
+	lifespan, _ := time.ParseDuration(cfg.SecondFactor.Lifespan) // error can be ignored, value is checked in config validation
+	return lifespan
+}
+
+// secondFactorStatus returns whether the user has to complete a second factor after password or passcode login. A
+// nil status means no second factor is required.
+func secondFactorStatus(persister persistence.Persister, cfg *config.Config, userId uuid.UUID) (*dto.SecondFactorStatus, error) {
+	credential, err := persister.GetTotpCredentialPersister().GetByUserId(userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get totp credential: %w", err)
+	}
+
+	enrolled := credential != nil && credential.Confirmed
+	if !enrolled && cfg.SecondFactor.Mode != config.SecondFactorMandatory {
+		return nil, nil
+	}
+
+	return &dto.SecondFactorStatus{Enrolled: enrolled}, nil
+}
@@ -0,0 +1,301 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/crypto/aes_gcm"
+	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/crypto/totp"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/session"
+	"github.com/teamhanko/hanko/backend/test"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var totpSecret = []byte("12345678901234567890")
+
+func totpConfig() *config.Config {
+	cfg := defaultConfig
+	cfg.Service.Name = "Test Service"
+	cfg.Secrets.Keys = []string{"needsToBeAtLeast16"}
+	return &cfg
+}
+
+func generateTotpCredential(t *testing.T, cfg *config.Config, p persistence.Persister, confirmed bool) {
+	aes, err := aes_gcm.NewAESGCM(cfg.Secrets.Keys)
+	require.NoError(t, err)
+	encryptedSecret, err := aes.Encrypt(totpSecret)
+	require.NoError(t, err)
+
+	require.NoError(t, p.GetTotpCredentialPersister().Create(models.TotpCredential{
+		ID:        generateUuid(t),
+		UserId:    uuid.FromStringOrNil(userId),
+		Secret:    encryptedSecret,
+		Confirmed: confirmed,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}))
+}
+
+func generateSecondFactorJwt(t *testing.T) jwt.Token {
+	uId := uuid.FromStringOrNil(userId)
+	token := generateJwt(t, uId, uId, 5)
+	require.NoError(t, token.Set(jwt2.RestrictKey, session.RestrictionSecondFactor))
+	return token
+}
+
+func newTotpContext(path string, code string, token jwt.Token) (echo.Context, *httptest.ResponseRecorder) {
+	c, rec := newContext(http.MethodPost, path, `{"code": "`+code+`"}`)
+	c.Set("session", token)
+	return c, rec
+}
+
+func TestTotpHandler_BeginEnrollment(t *testing.T) {
+	cfg := totpConfig()
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	handler, err := NewTotpHandler(cfg, p, sessionManager{})
+	require.NoError(t, err)
+
+	uId := uuid.FromStringOrNil(userId)
+	c, rec := newTotpContext("/totp/enroll/initialize", "", generateJwt(t, uId, uId, 5))
+
+	if assert.NoError(t, handler.BeginEnrollment(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		response := dto.TotpEnrollmentResponse{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.NotEmpty(t, response.Secret)
+		assert.Contains(t, response.Uri, "otpauth://totp/")
+
+		credential, err := p.GetTotpCredentialPersister().GetByUserId(uId)
+		assert.NoError(t, err)
+		if assert.NotNil(t, credential) {
+			assert.False(t, credential.Confirmed)
+			assert.NotContains(t, credential.Secret, response.Secret)
+		}
+	}
+}
+
+func TestTotpHandler_BeginEnrollment_Errors_WhenAlreadyEnrolledAndSessionIsRestricted(t *testing.T) {
+	cfg := totpConfig()
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	generateTotpCredential(t, cfg, p, true)
+	handler, err := NewTotpHandler(cfg, p, sessionManager{})
+	require.NoError(t, err)
+
+	c, _ := newTotpContext("/totp/enroll/initialize", "", generateSecondFactorJwt(t))
+
+	err = handler.BeginEnrollment(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusForbidden, dto.ToHttpError(err).Code)
+	}
+}
+
+func TestTotpHandler_FinishEnrollment(t *testing.T) {
+	cfg := totpConfig()
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	generateTotpCredential(t, cfg, p, false)
+	handler, err := NewTotpHandler(cfg, p, sessionManager{})
+	require.NoError(t, err)
+
+	code := totp.Code(totpSecret, totp.Step(time.Now().UTC()))
+	c, rec := newTotpContext("/totp/enroll/finalize", code, generateSecondFactorJwt(t))
+
+	if assert.NoError(t, handler.FinishEnrollment(c)) {
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.NotEmpty(t, rec.Result().Cookies())
+
+		credential, err := p.GetTotpCredentialPersister().GetByUserId(uuid.FromStringOrNil(userId))
+		assert.NoError(t, err)
+		assert.True(t, credential.Confirmed)
+	}
+}
+
+func TestTotpHandler_Login(t *testing.T) {
+	cfg := totpConfig()
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	generateTotpCredential(t, cfg, p, true)
+	handler, err := NewTotpHandler(cfg, p, sessionManager{})
+	require.NoError(t, err)
+
+	code := totp.Code(totpSecret, totp.Step(time.Now().UTC()))
+	c, rec := newTotpContext("/totp/login", code, generateSecondFactorJwt(t))
+
+	if assert.NoError(t, handler.Login(c)) {
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.NotEmpty(t, rec.Result().Cookies())
+	}
+
+	c2, _ := newTotpContext("/totp/login", code, generateSecondFactorJwt(t))
+	err = handler.Login(c2)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusUnauthorized, dto.ToHttpError(err).Code)
+	}
+}
+
+// parallelTotpCredentialPersister claims the current step right after the credential is read, like a parallel request
+// with the same code would
+type parallelTotpCredentialPersister struct {
+	persistence.TotpCredentialPersister
+}
+
+func (p parallelTotpCredentialPersister) GetByUserId(userId uuid.UUID) (*models.TotpCredential, error) {
+	credential, err := p.TotpCredentialPersister.GetByUserId(userId)
+	if err != nil || credential == nil {
+		return credential, err
+	}
+	now := time.Now().UTC()
+	_, err = p.ClaimStep(credential.ID, totp.Step(now), now)
+	return credential, err
+}
+
+type parallelTotpPersister struct {
+	persistence.Persister
+}
+
+func (p parallelTotpPersister) GetTotpCredentialPersister() persistence.TotpCredentialPersister {
+	return parallelTotpCredentialPersister{TotpCredentialPersister: p.Persister.GetTotpCredentialPersister()}
+}
+
+func TestTotpHandler_Login_Errors_WhenCodeIsUsedInParallel(t *testing.T) {
+	cfg := totpConfig()
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	generateTotpCredential(t, cfg, p, true)
+	handler, err := NewTotpHandler(cfg, parallelTotpPersister{Persister: p}, sessionManager{})
+	require.NoError(t, err)
+
+	code := totp.Code(totpSecret, totp.Step(time.Now().UTC()))
+	c, rec := newTotpContext("/totp/login", code, generateSecondFactorJwt(t))
+
+	err = handler.Login(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusUnauthorized, dto.ToHttpError(err).Code)
+		assert.Empty(t, rec.Result().Cookies())
+	}
+}
+
+func TestTotpHandler_Login_RateLimited(t *testing.T) {
+	cfg := totpConfig()
+	cfg.RateLimit = rateLimitConfig().RateLimit
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	generateTotpCredential(t, cfg, p, true)
+	handler, err := NewTotpHandler(cfg, p, sessionManager{})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		c, _ := newTotpContext("/totp/login", "000000", generateSecondFactorJwt(t))
+		err := handler.Login(c)
+		if assert.Error(t, err) {
+			assert.Equal(t, http.StatusUnauthorized, dto.ToHttpError(err).Code)
+		}
+	}
+
+	code := totp.Code(totpSecret, totp.Step(time.Now().UTC()))
+	c, rec := newTotpContext("/totp/login", code, generateSecondFactorJwt(t))
+	err = handler.Login(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusTooManyRequests, dto.ToHttpError(err).Code)
+		assert.Equal(t, "60", rec.Header().Get(echo.HeaderRetryAfter))
+	}
+}
+
+func TestTotpHandler_Login_Errors_WhenMaxAttemptsOfSessionAreExceeded(t *testing.T) {
+	cfg := totpConfig()
+	cfg.SecondFactor.Lifespan = "5m"
+	cfg.SecondFactor.MaxAttempts = 2
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	generateTotpCredential(t, cfg, p, true)
+	handler, err := NewTotpHandler(cfg, p, sessionManager{})
+	require.NoError(t, err)
+
+	token := generateSecondFactorJwt(t)
+	require.NoError(t, token.Set(jwt.IssuedAtKey, time.Now().UTC()))
+	for i := 0; i < 2; i++ {
+		c, _ := newTotpContext("/totp/login", "000000", token)
+		err := handler.Login(c)
+		if assert.Error(t, err) {
+			assert.Equal(t, http.StatusUnauthorized, dto.ToHttpError(err).Code)
+		}
+	}
+
+	code := totp.Code(totpSecret, totp.Step(time.Now().UTC()))
+	c, _ := newTotpContext("/totp/login", code, token)
+	err = handler.Login(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusUnauthorized, dto.ToHttpError(err).Code)
+	}
+
+	// a new login creates a new restricted session, which can complete the second factor
+	newToken := generateSecondFactorJwt(t)
+	require.NoError(t, newToken.Set(jwt.IssuedAtKey, time.Now().UTC().Add(time.Second)))
+	c, rec := newTotpContext("/totp/login", code, newToken)
+	if assert.NoError(t, handler.Login(c)) {
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	}
+}
+
+func TestTotpHandler_Login_Errors_WhenNoSecondFactorIsPending(t *testing.T) {
+	cfg := totpConfig()
+	p := test.NewPersister(users, nil, nil, nil, nil, nil, nil, nil, nil)
+	generateTotpCredential(t, cfg, p, true)
+	handler, err := NewTotpHandler(cfg, p, sessionManager{})
+	require.NoError(t, err)
+
+	uId := uuid.FromStringOrNil(userId)
+	code := totp.Code(totpSecret, totp.Step(time.Now().UTC()))
+	c, _ := newTotpContext("/totp/login", code, generateJwt(t, uId, uId, 5))
+
+	err = handler.Login(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusBadRequest, dto.ToHttpError(err).Code)
+	}
+}
+
+func TestPasswordHandler_Login_RequiresSecondFactor(t *testing.T) {
+	cfg := totpConfig()
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("verybadpassword"), 12)
+	require.NoError(t, err)
+	passwords := []models.PasswordCredential{
+		{
+			ID:        generateUuid(t),
+			UserId:    uuid.FromStringOrNil(userId),
+			Password:  string(hashedPassword),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		},
+	}
+	p := test.NewPersister(users, nil, nil, nil, nil, passwords, nil, nil, nil)
+	generateTotpCredential(t, cfg, p, true)
+	handler := NewPasswordHandler(p, sessionManager{}, cfg)
+
+	e := echo.New()
+	e.Validator = dto.NewCustomValidator()
+	body := `{"user_id": "` + userId + `", "password": "verybadpassword"}`
+	req := httptest.NewRequest(http.MethodPost, "/password/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, handler.Login(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		response := struct {
+			SecondFactor *dto.SecondFactorStatus `json:"second_factor"`
+		}{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		if assert.NotNil(t, response.SecondFactor) {
+			assert.True(t, response.SecondFactor.Enrolled)
+		}
+	}
+}
@@ -13,19 +13,24 @@ import (
 	"github.com/gofrs/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/teamhanko/hanko/backend/actor"
 	"github.com/teamhanko/hanko/backend/config"
 	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
 	"github.com/teamhanko/hanko/backend/dto"
-	"github.com/teamhanko/hanko/backend/dto/intern"
+	"github.com/teamhanko/hanko/backend/guestlogin"
 	"github.com/teamhanko/hanko/backend/persistence"
 	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/ratelimit"
 	"github.com/teamhanko/hanko/backend/session"
+	"github.com/teamhanko/hanko/backend/webhook"
 )
 
 type UserHandler struct {
-	persister      persistence.Persister
-	sessionManager session.Manager
-	webauthn       *webauthn.WebAuthn
+	persister               persistence.Persister
+	sessionManager          session.Manager
+	webauthn                *webauthn.WebAuthn
+	lookupLimiter           *ratelimit.Limiter
+	guestLoginNotifications config.GuestLoginNotifications
 }
 
 func NewUserHandler(cfg *config.Config, persister persistence.Persister, sessionManager session.Manager) *UserHandler {
@@ -43,7 +48,13 @@ func NewUserHandler(cfg *config.Config, persister persistence.Persister, session
 		Timeout: cfg.Webauthn.Timeout,
 		Debug:   false,
 	})
-	return &UserHandler{persister: persister, sessionManager: sessionManager, webauthn: wa}
+	return &UserHandler{
+		persister:               persister,
+		sessionManager:          sessionManager,
+		webauthn:                wa,
+		lookupLimiter:           ratelimit.New(cfg.RateLimit, "user_lookup", cfg.RateLimit.UserLookup, persister),
+		guestLoginNotifications: cfg.GuestLoginNotifications,
+	}
 }
 
 type UserCreateBody struct {
@@ -78,6 +89,25 @@ func (h *UserHandler) Create(c echo.Context) error {
 			return fmt.Errorf("failed to store user: %w", err)
 		}
 
+		// unverified secondary addresses of other users don't block the address, as anybody could have added it
+		emailPersister := h.persister.GetEmailPersisterWithConnection(tx)
+		err = emailPersister.DeleteUnverified(newUser.Email, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+
+		email := models.NewEmail(newUser.ID, newUser.Email)
+		email.Primary = true
+		err = emailPersister.Create(email)
+		if err != nil {
+			return fmt.Errorf("failed to store email: %w", err)
+		}
+
+		err = webhook.Enqueue(h.persister.GetWebhookPersisterWithConnection(tx), models.WebhookEventUserCreated, webhook.NewUserData(newUser))
+		if err != nil {
+			return err
+		}
+
 		return c.JSON(http.StatusOK, newUser)
 	})
 }
@@ -120,22 +150,38 @@ func (h *UserHandler) GetUserIdByEmail(c echo.Context) error {
 		return dto.ToHttpError(err)
 	}
 
-	user, err := h.persister.GetUserPersister().GetByEmail(strings.ToLower(request.Email))
+	// lookups of unknown addresses count, otherwise registered email addresses could be enumerated. Lookups of known
+	// addresses don't, as every login starts with one, e.g. of all users behind the same NAT.
+	rateLimitKeys := []string{emailRateLimitKey(request.Email), ipRateLimitKey(c)}
+	if err := checkRateLimit(c, h.lookupLimiter, rateLimitKeys...); err != nil {
+		return err
+	}
+
+	address := strings.ToLower(request.Email)
+	user, err := h.persister.GetUserPersister().GetByEmail(address)
 	if err != nil {
 		return fmt.Errorf("failed to get user: %w", err)
 	}
 
 	if user == nil {
+		if err := h.lookupLimiter.Register(rateLimitKeys...); err != nil {
+			return err
+		}
 		return dto.NewHTTPError(http.StatusNotFound).SetInternal(errors.New("user not found"))
 	}
 
+	verified, err := hasVerifiedEmail(h.persister.GetEmailPersister(), user, address)
+	if err != nil {
+		return err
+	}
+
 	return c.JSON(http.StatusOK, struct {
 		UserId                string `json:"id"`
 		Verified              bool   `json:"verified"`
 		HasWebauthnCredential bool   `json:"has_webauthn_credential"`
 	}{
 		UserId:                user.ID.String(),
-		Verified:              user.Verified,
+		Verified:              verified,
 		HasWebauthnCredential: len(user.WebauthnCredentials) > 0,
 	})
 }
@@ -292,10 +338,7 @@ func (h *UserHandler) BeginLoginAsGuest(c echo.Context) error {
 	if err != nil {
 		return err
 	}
-	var options *protocol.CredentialAssertion
-	var sessionData *webauthn.SessionData
-
-	webauthnUser, err := h.getWebauthnUser(h.persister.GetConnection(), uuid.FromStringOrNil(sessionToken.Subject()))
+	webauthnUser, err := getWebauthnUser(h.persister, h.persister.GetConnection(), uuid.FromStringOrNil(sessionToken.Subject()))
 	if err != nil {
 		return dto.NewHTTPError(http.StatusNotFound).SetInternal(fmt.Errorf("unable to get webauthn user for user ID %s: %w", sessionToken.Subject(), err))
 	}
@@ -304,30 +347,9 @@ func (h *UserHandler) BeginLoginAsGuest(c echo.Context) error {
 		return dto.NewHTTPError(http.StatusBadRequest, "user not found")
 	}
 
-	if len(webauthnUser.WebAuthnCredentials()) > 0 {
-		options, sessionData, err = h.webauthn.BeginLogin(webauthnUser, webauthn.WithUserVerification(protocol.VerificationRequired))
-		if err != nil {
-			return fmt.Errorf("failed to create webauthn assertion options: %w", err)
-		}
-	}
-
-	if options == nil && sessionData == nil {
-		var err error
-		options, sessionData, err = h.webauthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
-		if err != nil {
-			return fmt.Errorf("failed to create webauthn assertion options for discoverable login: %w", err)
-		}
-	}
-
-	err = h.persister.GetWebauthnSessionDataPersister().Create(*intern.WebauthnSessionDataToModel(sessionData, models.WebauthnOperationAuthentication))
+	options, err := beginUserVerifiedAssertion(h.webauthn, h.persister, webauthnUser)
 	if err != nil {
-		return fmt.Errorf("failed to store webauthn assertion session data: %w", err)
-	}
-
-	// Remove all transports, because of a bug in android and windows where the internal authenticator gets triggered,
-	// when the transports array contains the type 'internal' although the credential is not available on the device.
-	for i := range options.Response.AllowedCredentials {
-		options.Response.AllowedCredentials[i].Transport = nil
+		return err
 	}
 
 	return c.JSON(http.StatusOK, options)
@@ -372,27 +394,28 @@ func (h *UserHandler) InitiateLoginAsGuest(c echo.Context) error {
 	}
 
 	if relation.ExpireByTime && time.Now().UTC().After(relation.CreatedAt.UTC().Add(time.Duration(relation.MinutesAllowed.Int32)*time.Minute)) {
-		relation.IsActive = false
-		relation.UpdatedAt = time.Now().UTC()
-
-		_ = h.persister.GetUserGuestRelationPersister().Update(*relation)
+		h.expireRelation(c, *relation, webhook.ReasonTimeElapsed)
 
 		return dto.NewHTTPError(http.StatusForbidden).SetInternal(fmt.Errorf("Access on relation ID %s has expired", relation.ID))
 	}
 
-	if relation.ExpireByLogins {
-		models, err := h.persister.GetLoginAuditLogPersister().GetByGuestUserIdAndGrantId(relation.GuestUserID, relation.ID)
-		if err != nil {
-			return dto.NewHTTPError(http.StatusInternalServerError).SetInternal(fmt.Errorf("an error occurred while fetching login audit records: %w", err))
-		}
-		if int32(len(models)) >= relation.LoginsAllowed.Int32 {
-			relation.IsActive = false
-			relation.UpdatedAt = time.Now().UTC()
+	previousLogins, err := h.persister.GetLoginAuditLogPersister().GetByGuestUserIdAndGrantId(relation.GuestUserID, relation.ID)
+	if err != nil {
+		return dto.NewHTTPError(http.StatusInternalServerError).SetInternal(fmt.Errorf("an error occurred while fetching login audit records: %w", err))
+	}
 
-			_ = h.persister.GetUserGuestRelationPersister().Update(*relation)
+	if relation.ExpireByLogins && int32(len(previousLogins)) >= relation.LoginsAllowed.Int32 {
+		h.expireRelation(c, *relation, webhook.ReasonLoginsUsed)
 
-			return dto.NewHTTPError(http.StatusForbidden).SetInternal(fmt.Errorf("access on relation ID %s has expired", relation.ID))
-		}
+		return dto.NewHTTPError(http.StatusForbidden).SetInternal(fmt.Errorf("access on relation ID %s has expired", relation.ID))
+	}
+
+	accountHolder, err := h.persister.GetUserPersister().Get(relation.ParentUserID)
+	if err != nil {
+		return fmt.Errorf("failed to get account holder: %w", err)
+	}
+	if accountHolder == nil {
+		return dto.NewHTTPError(http.StatusNotFound).SetInternal(errors.New("account holder not found"))
 	}
 
 	token, err := h.sessionManager.GenerateJWT(relation.ParentUserID, relation.GuestUserID, relation.ID)
@@ -411,13 +434,35 @@ func (h *UserHandler) InitiateLoginAsGuest(c echo.Context) error {
 		UserId:              relation.ParentUserID,
 		SurrogateUserId:     &relation.GuestUserID,
 		UserGuestRelationId: &relation.ID,
-		ClientIpAddress:     c.Request().RemoteAddr,
+		ClientIpAddress:     c.RealIP(),
 		ClientUserAgent:     c.Request().UserAgent(),
 		LoginMethod:         dto.LoginMethodToValue(dto.Webauthn),
 	}
-	err = h.persister.GetLoginAuditLogPersister().Create(log)
+	err = h.persister.Transaction(func(tx *pop.Connection) error {
+		err := h.persister.GetLoginAuditLogPersisterWithConnection(tx).Create(log)
+		if err != nil {
+			return dto.NewHTTPError(http.StatusInternalServerError, "An error occurred generating login audit record", err.Error())
+		}
+		err = webhook.Enqueue(h.persister.GetWebhookPersisterWithConnection(tx), models.WebhookEventRelationUsed, webhook.NewRelationData(*relation, ""))
+		if err != nil {
+			return err
+		}
+
+		guest := actor.Actor{PrincipalId: relation.ParentUserID, SurrogateId: relation.GuestUserID, RelationId: &relation.ID, Scopes: relation.ScopeList()}
+		err = recordGuestActivity(h.persister.GetGuestActivityPersisterWithConnection(tx), guest, models.GuestActivityLogin, models.GuestActivityResourceRelation, relation.ID)
+		if err != nil {
+			return err
+		}
+
+		mode := guestlogin.Mode(*accountHolder, h.guestLoginNotifications)
+		notification := guestlogin.NewNotification(mode, *relation, log, len(previousLogins), time.Now().UTC())
+		if notification == nil {
+			return nil
+		}
+		return h.persister.GetGuestLoginNotificationPersisterWithConnection(tx).Create(*notification)
+	})
 	if err != nil {
-		return dto.NewHTTPError(http.StatusInternalServerError, "An error occurred generating login audit record", err.Error())
+		return err
 	}
 
 	return c.JSON(http.StatusOK, struct{}{})
@@ -425,6 +470,24 @@ func (h *UserHandler) InitiateLoginAsGuest(c echo.Context) error {
 	return nil
 }
 
+// expireRelation deactivates a relation whose logins are used up or whose time has elapsed. Errors are only logged,
+// the login is refused anyway.
+func (h *UserHandler) expireRelation(c echo.Context, relation models.UserGuestRelation, reason string) {
+	relation.IsActive = false
+	relation.UpdatedAt = time.Now().UTC()
+
+	err := h.persister.Transaction(func(tx *pop.Connection) error {
+		err := h.persister.GetUserGuestRelationPersisterWithConnection(tx).Update(relation)
+		if err != nil {
+			return err
+		}
+		return webhook.Enqueue(h.persister.GetWebhookPersisterWithConnection(tx), models.WebhookEventRelationExpired, webhook.NewRelationData(relation, reason))
+	})
+	if err != nil {
+		c.Logger().Errorf("failed to expire relation %s: %s", relation.ID, err)
+	}
+}
+
 func (h *UserHandler) LogoutAsGuest(c echo.Context) error {
 	sessionToken, ok := c.Get("session").(jwt.Token)
 	if !ok {
@@ -454,15 +517,18 @@ func (h *UserHandler) LogoutAsGuest(c echo.Context) error {
 
 	c.SetCookie(cookie)
 
-	log := models.LoginAuditLog{
-		UserId:          uuid.FromStringOrNil(surrogateId),
-		ClientIpAddress: c.Request().RemoteAddr,
-		ClientUserAgent: c.Request().UserAgent(),
-		LoginMethod:     dto.LoginMethodToValue(dto.LogoutAsGuest),
-	}
-	err = h.persister.GetLoginAuditLogPersister().Create(log)
+	parentUserId := uuid.FromStringOrNil(sessionToken.Subject())
+	err = h.persister.GetSecurityEventPersister().Create(newSecurityEvent(c, models.EventGuestLogout, &parentUserId))
 	if err != nil {
-		return dto.NewHTTPError(http.StatusInternalServerError, "An error occurred generating login audit record", err.Error())
+		return fmt.Errorf("failed to create security event: %w", err)
+	}
+
+	// the logout doesn't fail on a session without the guest relation, the activity just isn't recorded
+	if a, err := actingAs(c); err == nil && a.IsGuest() {
+		err = recordGuestActivity(h.persister.GetGuestActivityPersister(), a, models.GuestActivityLogout, models.GuestActivityResourceRelation, *a.RelationId)
+		if err != nil {
+			return fmt.Errorf("failed to record guest activity: %w", err)
+		}
 	}
 
 	return c.JSON(http.StatusOK, struct{}{})
@@ -498,9 +564,23 @@ func (h *UserHandler) RemoveAccessToRelation(c echo.Context) error {
 	relation.IsActive = false
 	relation.UpdatedAt = time.Now().UTC()
 
-	err = h.persister.GetUserGuestRelationPersister().Update(*relation)
+	err = h.persister.Transaction(func(tx *pop.Connection) error {
+		err := h.persister.GetUserGuestRelationPersisterWithConnection(tx).Update(*relation)
+		if err != nil {
+			return dto.NewHTTPError(http.StatusInternalServerError).SetInternal(fmt.Errorf("An error occurred while updating the relation ID %s", relation.ID))
+		}
+
+		event := newSecurityEvent(c, models.EventRelationRevoked, &relation.GuestUserID)
+		event.Metadata["relation_id"] = relation.ID.String()
+		err = h.persister.GetSecurityEventPersisterWithConnection(tx).Create(event)
+		if err != nil {
+			return fmt.Errorf("failed to create security event: %w", err)
+		}
+
+		return webhook.Enqueue(h.persister.GetWebhookPersisterWithConnection(tx), models.WebhookEventRelationRevoked, webhook.NewRelationData(*relation, webhook.ReasonRevoked))
+	})
 	if err != nil {
-		return dto.NewHTTPError(http.StatusInternalServerError).SetInternal(fmt.Errorf("An error occurred while updating the relation ID %s", relation.ID))
+		return err
 	}
 
 	return c.JSON(http.StatusOK, struct{}{})
@@ -513,6 +593,8 @@ type MeResponseDto struct {
 	Email           string    `json:"email"`
 	IsAccountHolder bool      `json:"isAccountHolder"`
 	IsAdmin         bool      `json:"isAdmin"`
+	Roles           []string  `json:"roles"`
+	Permissions     []string  `json:"permissions"`
 }
 
 func (h *UserHandler) Me(c echo.Context) error {
@@ -527,16 +609,33 @@ func (h *UserHandler) Me(c echo.Context) error {
 	}
 
 	surrogateId, _ := jwt2.GetSurrogateKeyFromToken(sessionToken)
-	isAdmin := false
+	roleNames := []string{}
+	permissions := []string{}
+	// guests never get the roles of the account they are logged in to
 	if sessionToken.Subject() == surrogateId {
-		isAdmin = user.IsAdmin
+		roles, err := h.persister.GetRolePersister().FindByUserId(user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get roles: %w", err)
+		}
+		seen := map[string]bool{}
+		for _, role := range roles {
+			roleNames = append(roleNames, role.Name)
+			for _, permission := range role.Permissions {
+				if !seen[permission] {
+					seen[permission] = true
+					permissions = append(permissions, permission)
+				}
+			}
+		}
 	}
 
 	dto := MeResponseDto{
 		Email:           user.Email,
 		Id:              user.ID,
 		IsAccountHolder: sessionToken.Subject() == surrogateId,
-		IsAdmin:         isAdmin,
+		IsAdmin:         len(roleNames) > 0,
+		Roles:           roleNames,
+		Permissions:     permissions,
 	}
 
 	return c.JSON(http.StatusOK, dto)
@@ -572,21 +671,3 @@ func (*UserHandler) parseAndValidateToken(c echo.Context, shouldBeAccountHolder
 	}
 	return sessionToken, nil
 }
-
-func (h *UserHandler) getWebauthnUser(connection *pop.Connection, userId uuid.UUID) (*intern.WebauthnUser, error) {
-	user, err := h.persister.GetUserPersisterWithConnection(connection).Get(userId)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get user: %w", err)
-	}
-
-	if user == nil {
-		return nil, nil
-	}
-
-	credentials, err := h.persister.GetWebauthnCredentialPersisterWithConnection(connection).GetFromUser(user.ID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get webauthn credentials: %w", err)
-	}
-
-	return intern.NewWebauthnUser(*user, credentials), nil
-}
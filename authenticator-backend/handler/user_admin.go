@@ -3,12 +3,15 @@ package handler
 import (
 	"database/sql"
 	"fmt"
+	"github.com/gobuffalo/pop/v6"
 	"github.com/gofrs/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/lestrrat-go/jwx/v2/jwt"
-	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/account"
 	"github.com/teamhanko/hanko/backend/dto"
 	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/webhook"
 	"net/http"
 	"strings"
 	"time"
@@ -16,10 +19,11 @@ import (
 
 type UserHandlerAdmin struct {
 	persister persistence.Persister
+	deleter   *account.Deleter
 }
 
 func NewUserHandlerAdmin(persister persistence.Persister) *UserHandlerAdmin {
-	return &UserHandlerAdmin{persister: persister}
+	return &UserHandlerAdmin{persister: persister, deleter: account.NewDeleter(persister)}
 }
 
 func (h *UserHandlerAdmin) Delete(c echo.Context) error {
@@ -28,11 +32,6 @@ func (h *UserHandlerAdmin) Delete(c echo.Context) error {
 		return dto.NewHTTPError(http.StatusBadRequest, "failed to parse userId as uuid").SetInternal(err)
 	}
 
-	err, isSuccess := h.validateAdminPermission(c)
-	if !isSuccess {
-		return err
-	}
-
 	p := h.persister.GetUserPersister()
 	user, err := p.Get(userId)
 	if err != nil {
@@ -43,7 +42,7 @@ func (h *UserHandlerAdmin) Delete(c echo.Context) error {
 		return dto.NewHTTPError(http.StatusNotFound, "user not found")
 	}
 
-	err = p.Delete(*user)
+	err = h.deleter.Delete(*user)
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
@@ -57,11 +56,6 @@ func (h *UserHandlerAdmin) ToggleIsActiveForUser(c echo.Context) error {
 		return dto.NewHTTPError(http.StatusBadRequest, "failed to parse userId as uuid").SetInternal(err)
 	}
 
-	err, isSuccess := h.validateAdminPermission(c)
-	if !isSuccess {
-		return err
-	}
-
 	sessionToken, ok := c.Get("session").(jwt.Token)
 	if !ok {
 		return dto.NewHTTPError(http.StatusForbidden)
@@ -83,9 +77,29 @@ func (h *UserHandlerAdmin) ToggleIsActiveForUser(c echo.Context) error {
 
 	user.IsActive = !user.IsActive
 	user.UpdatedAt = time.Now().UTC()
-	err = h.persister.GetUserPersister().Update(*user)
+
+	eventType := models.EventUserDeactivated
+	webhookEventType := models.WebhookEventUserDeactivated
+	if user.IsActive {
+		eventType = models.EventUserActivated
+		webhookEventType = models.WebhookEventUserActivated
+	}
+
+	err = h.persister.Transaction(func(tx *pop.Connection) error {
+		err := h.persister.GetUserPersisterWithConnection(tx).Update(*user)
+		if err != nil {
+			return dto.NewHTTPError(http.StatusInternalServerError, "toggling user isactive failed").SetInternal(err)
+		}
+
+		err = h.persister.GetSecurityEventPersisterWithConnection(tx).Create(newSecurityEvent(c, eventType, &user.ID))
+		if err != nil {
+			return fmt.Errorf("failed to create security event: %w", err)
+		}
+
+		return webhook.Enqueue(h.persister.GetWebhookPersisterWithConnection(tx), webhookEventType, webhook.NewUserData(*user))
+	})
 	if err != nil {
-		return dto.NewHTTPError(http.StatusInternalServerError, "toggling user isactive failed")
+		return err
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
@@ -97,28 +111,43 @@ func (h *UserHandlerAdmin) DeactivateGrantsForUser(c echo.Context) error {
 		return dto.NewHTTPError(http.StatusBadRequest, "failed to parse userId as uuid").SetInternal(err)
 	}
 
-	err, isSuccess := h.validateAdminPermission(c)
-	if !isSuccess {
-		return err
-	}
-
 	userGuestRelationsPersister := h.persister.GetUserGuestRelationPersister()
 	grants, err := userGuestRelationsPersister.GetByParentUserId(&userId)
 	if err != nil {
 		return dto.NewHTTPError(http.StatusInternalServerError, "failed to get grants for user")
 	}
 
-	// TODO: Convert to a transaction
-	for _, grant := range grants {
-		if !grant.IsActive {
-			continue
+	err = h.persister.Transaction(func(tx *pop.Connection) error {
+		relationPersister := h.persister.GetUserGuestRelationPersisterWithConnection(tx)
+		webhookPersister := h.persister.GetWebhookPersisterWithConnection(tx)
+		deactivated := 0
+		for _, grant := range grants {
+			if !grant.IsActive {
+				continue
+			}
+			grant.IsActive = false
+			grant.UpdatedAt = time.Now().UTC()
+			err := relationPersister.Update(grant)
+			if err != nil {
+				return dto.NewHTTPError(http.StatusInternalServerError, "failed to update grant").SetInternal(err)
+			}
+			err = webhook.Enqueue(webhookPersister, models.WebhookEventRelationRevoked, webhook.NewRelationData(grant, webhook.ReasonRevoked))
+			if err != nil {
+				return err
+			}
+			deactivated++
 		}
-		grant.IsActive = false
-		grant.UpdatedAt = time.Now().UTC()
-		err = userGuestRelationsPersister.Update(grant)
+
+		event := newSecurityEvent(c, models.EventGrantsDeactivated, &userId)
+		event.Metadata["count"] = deactivated
+		err := h.persister.GetSecurityEventPersisterWithConnection(tx).Create(event)
 		if err != nil {
-			return dto.NewHTTPError(http.StatusInternalServerError, "failed to update grant")
+			return fmt.Errorf("failed to create security event: %w", err)
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{})
@@ -140,11 +169,6 @@ func (h *UserHandlerAdmin) Patch(c echo.Context) error {
 		return dto.ToHttpError(err)
 	}
 
-	err, isSuccess := h.validateAdminPermission(c)
-	if !isSuccess {
-		return err
-	}
-
 	patchRequest.Email = strings.ToLower(patchRequest.Email)
 
 	p := h.persister.GetUserPersister()
@@ -163,17 +187,22 @@ func (h *UserHandlerAdmin) Patch(c echo.Context) error {
 			return fmt.Errorf("failed to get user: %w", err)
 		}
 
-		if maybeExistingUser != nil {
+		if maybeExistingUser != nil && maybeExistingUser.ID != user.ID {
 			return dto.NewHTTPError(http.StatusBadRequest, "email address not available")
 		}
-
-		user.Email = patchRequest.Email
 	}
 
 	if patchRequest.Verified != nil {
 		user.Verified = *patchRequest.Verified
 	}
 
+	if patchRequest.Email != "" && patchRequest.Email != user.Email {
+		err = replacePrimaryEmail(h.persister.GetEmailPersister(), user, patchRequest.Email, user.Verified)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = p.Update(*user)
 	if err != nil {
 		return fmt.Errorf("failed to update user: %w", err)
@@ -182,30 +211,83 @@ func (h *UserHandlerAdmin) Patch(c echo.Context) error {
 }
 
 type UserListRequest struct {
-	PerPage int `query:"per_page"`
-	Page    int `query:"page"`
+	PerPage                 int        `query:"per_page"`
+	Page                    int        `query:"page"`
+	Email                   string     `query:"email"`
+	Verified                *bool      `query:"verified"`
+	IsActive                *bool      `query:"is_active"`
+	IsAdmin                 *bool      `query:"is_admin"`
+	HasPasskey              *bool      `query:"has_passkey"`
+	HasPassword             *bool      `query:"has_password"`
+	HasActiveGuestRelations *bool      `query:"has_active_guest_relations"`
+	CreatedAfter            *time.Time `query:"created_after"`
+	CreatedBefore           *time.Time `query:"created_before"`
+	SortBy                  string     `query:"sort_by"`
+	SortOrder               string     `query:"sort_order"`
+}
+
+type UserListResponseDto struct {
+	models.User
+	// IsAdmin is true if the user has at least one role
+	IsAdmin bool     `json:"is_admin"`
+	Roles   []string `json:"roles"`
 }
 
+// List returns a page of the users matching the filters of the request. The number of all matching users is returned
+// in the 'X-Total-Count' header, the links to the other pages in the 'Link' header.
 func (h *UserHandlerAdmin) List(c echo.Context) error {
-	// TODO: return 'X-Total-Count' header, which includes the all users count
-	// TODO; return 'Link' header, which includes links to next, previous, current(???), first, last page (example https://docs.github.com/en/rest/guides/traversing-with-pagination)
 	var request UserListRequest
 	err := (&echo.DefaultBinder{}).BindQueryParams(c, &request)
 	if err != nil {
 		return dto.ToHttpError(err)
 	}
 
-	err, isSuccess := h.validateAdminPermission(c)
-	if !isSuccess {
-		return err
+	switch request.SortBy {
+	case "", persistence.UserSortCreatedAt, persistence.UserSortUpdatedAt, persistence.UserSortEmail:
+	default:
+		return dto.NewHTTPError(http.StatusBadRequest, "sort_by must be one of created_at, updated_at, email")
+	}
+	if request.SortOrder != "" && request.SortOrder != "asc" && request.SortOrder != "desc" {
+		return dto.NewHTTPError(http.StatusBadRequest, "sort_order must be one of asc, desc")
+	}
+	if request.CreatedAfter != nil && request.CreatedBefore != nil && !request.CreatedAfter.Before(*request.CreatedBefore) {
+		return dto.NewHTTPError(http.StatusBadRequest, "created_after must be before created_before")
 	}
 
-	users, err := h.persister.GetUserPersister().List(request.Page, request.PerPage)
+	page, perPage := normalizePagination(request.Page, request.PerPage)
+	filter := persistence.UserFilter{
+		Email:                   strings.TrimSpace(request.Email),
+		Verified:                request.Verified,
+		Active:                  request.IsActive,
+		Admin:                   request.IsAdmin,
+		HasPasskey:              request.HasPasskey,
+		HasPassword:             request.HasPassword,
+		HasActiveGuestRelations: request.HasActiveGuestRelations,
+		CreatedAfter:            request.CreatedAfter,
+		CreatedBefore:           request.CreatedBefore,
+		SortBy:                  request.SortBy,
+		SortDesc:                request.SortOrder == "desc",
+	}
+	users, total, err := h.persister.GetUserPersister().Search(filter, page, perPage)
 	if err != nil {
 		return fmt.Errorf("failed to get list of users: %w", err)
 	}
 
-	return c.JSON(http.StatusOK, users)
+	response := []UserListResponseDto{}
+	for _, user := range users {
+		roles, err := h.persister.GetRolePersister().FindByUserId(user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get roles: %w", err)
+		}
+		roleNames := []string{}
+		for _, role := range roles {
+			roleNames = append(roleNames, role.Name)
+		}
+		response = append(response, UserListResponseDto{User: user, IsAdmin: len(roleNames) > 0, Roles: roleNames})
+	}
+
+	setPaginationHeaders(c, page, perPage, total)
+	return c.JSON(http.StatusOK, response)
 }
 
 type LoginAuditRecordRequest struct {
@@ -239,11 +321,6 @@ func (h *UserHandlerAdmin) GetLoginAuditRecordsForUser(c echo.Context) error {
 	//	return dto.ToHttpError(err)
 	//}
 
-	err, isSuccess := h.validateAdminPermission(c)
-	if !isSuccess {
-		return err
-	}
-
 	response := LoginAuditRecordResponseDto{
 		LoginsToAccount: []LoginAuditRecordResponseAccountLoginDto{},
 		LoginsAsGuest:   []LoginAuditRecordResponseAccountLoginDto{},
@@ -348,11 +425,6 @@ func (h *UserHandlerAdmin) GetGrantsForUser(c echo.Context) error {
 	//	return dto.ToHttpError(err)
 	//}
 
-	err, isSuccess := h.validateAdminPermission(c)
-	if !isSuccess {
-		return err
-	}
-
 	user, err := h.persister.GetUserPersister().Get(uuid.FromStringOrNil(grantFetchRequest.UserId))
 	if err != nil {
 		return fmt.Errorf("failed to get user ID %s: %w", grantFetchRequest.UserId, err)
@@ -414,30 +486,3 @@ func (h *UserHandlerAdmin) GetGrantsForUser(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, response)
 }
-
-func (h *UserHandlerAdmin) validateAdminPermission(c echo.Context) (error, bool) {
-	sessionToken, ok := c.Get("session").(jwt.Token)
-	if !ok {
-		return dto.NewHTTPError(http.StatusUnauthorized), false
-	}
-
-	surrogateId, err := jwt2.GetSurrogateKeyFromToken(sessionToken)
-	if err != nil {
-		return dto.NewHTTPError(http.StatusUnauthorized).SetInternal(fmt.Errorf("unable to get surrogate ID from token: %w", err)), false
-	}
-
-	if sessionToken.Subject() != surrogateId {
-		return dto.NewHTTPError(http.StatusForbidden), false
-	}
-
-	currentUser, err := h.persister.GetUserPersister().Get(uuid.FromStringOrNil(sessionToken.Subject()))
-	if err != nil || currentUser == nil {
-		return dto.NewHTTPError(http.StatusNotFound).SetInternal(fmt.Errorf("unable to find user id %s: %w", sessionToken.Subject(), err)), false
-	}
-
-	if !currentUser.IsAdmin || !currentUser.IsActive {
-		return dto.NewHTTPError(http.StatusForbidden), false
-	}
-
-	return nil, true
-}
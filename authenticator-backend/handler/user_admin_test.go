@@ -19,6 +19,7 @@ import (
 	"github.com/teamhanko/hanko/backend/dto"
 	"github.com/teamhanko/hanko/backend/persistence"
 	"github.com/teamhanko/hanko/backend/persistence/models"
+	hankoMiddleware "github.com/teamhanko/hanko/backend/server/middleware"
 	"github.com/teamhanko/hanko/backend/test"
 )
 
@@ -112,8 +113,7 @@ func TestUserHandlerAdmin_ToggleIsActiveForUser_UserIsNotAdmin_Error(t *testing.
 	adminUser, persister := createAdmin()
 	setSessionToken(t, c, adminUser)
 
-	adminUser.IsAdmin = false
-	persister.GetUserPersister().Update(adminUser)
+	revokeRole(persister, adminUser.ID, models.RoleSuperAdmin)
 	persister.GetUserPersister().Create(models.User{
 		ID:       userId,
 		Email:    "testy@example.com",
@@ -121,7 +121,7 @@ func TestUserHandlerAdmin_ToggleIsActiveForUser_UserIsNotAdmin_Error(t *testing.
 	})
 
 	handler := NewUserHandlerAdmin(persister)
-	err := handler.ToggleIsActiveForUser(c)
+	err := hankoMiddleware.Permission(persister, models.PermissionUsersWrite)(handler.ToggleIsActiveForUser)(c)
 	assert.Error(t, err)
 }
 
@@ -225,8 +225,7 @@ func TestUserHandlerAdmin_DeactivateGrantsForUser_UserIsNotAdmin_Error(t *testin
 	adminUser, persister := createAdmin()
 	setSessionToken(t, c, adminUser)
 
-	adminUser.IsAdmin = false
-	persister.GetUserPersister().Update(adminUser)
+	revokeRole(persister, adminUser.ID, models.RoleSuperAdmin)
 	persister.GetUserPersister().Create(models.User{
 		ID:       userId,
 		Email:    "testy@example.com",
@@ -243,7 +242,7 @@ func TestUserHandlerAdmin_DeactivateGrantsForUser_UserIsNotAdmin_Error(t *testin
 	}
 
 	handler := NewUserHandlerAdmin(persister)
-	err := handler.DeactivateGrantsForUser(c)
+	err := hankoMiddleware.Permission(persister, models.PermissionUsersWrite)(handler.DeactivateGrantsForUser)(c)
 	assert.Error(t, err)
 }
 
@@ -601,6 +600,138 @@ func TestUserHandlerAdmin_List_InvalidPaginationParam(t *testing.T) {
 	}
 }
 
+func TestUserHandlerAdmin_List_Filter(t *testing.T) {
+	adminUser, _ := createAdmin()
+	now := time.Now().UTC()
+	johnId, janeId, jackId := generateUuid(t), generateUuid(t), generateUuid(t)
+	users := []models.User{
+		adminUser,
+		{ID: johnId, Email: "john.doe@example.com", Verified: true, IsActive: true, CreatedAt: now.Add(-48 * time.Hour), UpdatedAt: now},
+		{ID: janeId, Email: "jane.doe@example.org", Verified: false, IsActive: true, CreatedAt: now.Add(-24 * time.Hour), UpdatedAt: now},
+		{ID: jackId, Email: "jack@test.example.com", Verified: true, IsActive: false, CreatedAt: now, UpdatedAt: now},
+	}
+	credentials := []models.WebauthnCredential{{ID: "credential", UserId: johnId}}
+	passwords := []models.PasswordCredential{{ID: generateUuid(t), UserId: janeId}}
+	relations := []models.UserGuestRelation{
+		{ID: generateUuid(t), ParentUserID: johnId, GuestUserID: janeId, IsActive: false},
+		{ID: generateUuid(t), ParentUserID: jackId, GuestUserID: janeId, IsActive: true},
+	}
+
+	tests := []struct {
+		name     string
+		query    url.Values
+		expected []uuid.UUID
+	}{
+		{name: "email", query: url.Values{"email": {"DOE@EXAMPLE"}}, expected: []uuid.UUID{johnId, janeId}},
+		{name: "verified", query: url.Values{"verified": {"false"}}, expected: []uuid.UUID{adminUser.ID, janeId}},
+		{name: "active", query: url.Values{"is_active": {"false"}}, expected: []uuid.UUID{jackId}},
+		{name: "admin", query: url.Values{"is_admin": {"true"}}, expected: []uuid.UUID{adminUser.ID}},
+		{name: "passkey", query: url.Values{"has_passkey": {"true"}}, expected: []uuid.UUID{johnId}},
+		{name: "password", query: url.Values{"has_password": {"true"}}, expected: []uuid.UUID{janeId}},
+		{name: "active guest relations", query: url.Values{"has_active_guest_relations": {"true"}}, expected: []uuid.UUID{janeId, jackId}},
+		{name: "no active guest relations", query: url.Values{"has_active_guest_relations": {"false"}, "is_admin": {"false"}}, expected: []uuid.UUID{johnId}},
+		{
+			name:     "created range",
+			query:    url.Values{"created_after": {now.Add(-36 * time.Hour).Format(time.RFC3339)}, "created_before": {now.Add(-time.Hour).Format(time.RFC3339)}},
+			expected: []uuid.UUID{janeId},
+		},
+		{name: "sort by email descending", query: url.Values{"sort_by": {"email"}, "sort_order": {"desc"}}, expected: []uuid.UUID{johnId, janeId, jackId, adminUser.ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			persister := test.NewPersister(users, nil, nil, credentials, nil, passwords, nil, relations, nil)
+			grantRole(persister, adminUser.ID, models.RoleSuperAdmin)
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/users?"+tt.query.Encode(), nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			setSessionToken(t, c, adminUser)
+
+			handler := NewUserHandlerAdmin(persister)
+
+			if assert.NoError(t, handler.List(c)) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				var got []models.User
+				err := json.Unmarshal(rec.Body.Bytes(), &got)
+				require.NoError(t, err)
+				ids := []uuid.UUID{}
+				for _, user := range got {
+					ids = append(ids, user.ID)
+				}
+				assert.Equal(t, tt.expected, ids)
+				assert.Equal(t, fmt.Sprint(len(tt.expected)), rec.Header().Get("X-Total-Count"))
+			}
+		})
+	}
+}
+
+func TestUserHandlerAdmin_List_PaginationHeaders(t *testing.T) {
+	adminUser, persister := createAdmin()
+	for i := 0; i < 4; i++ {
+		err := persister.GetUserPersister().Create(models.User{
+			ID:        generateUuid(t),
+			Email:     fmt.Sprintf("user%d@example.com", i),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		})
+		require.NoError(t, err)
+	}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/admin/users?email=example.com&per_page=2&page=2", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	setSessionToken(t, c, adminUser)
+
+	handler := NewUserHandlerAdmin(persister)
+
+	if assert.NoError(t, handler.List(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "5", rec.Header().Get("X-Total-Count"))
+		assert.Equal(t, strings.Join([]string{
+			`</admin/users?email=example.com&page=1&per_page=2>; rel="first"`,
+			`</admin/users?email=example.com&page=1&per_page=2>; rel="prev"`,
+			`</admin/users?email=example.com&page=3&per_page=2>; rel="next"`,
+			`</admin/users?email=example.com&page=3&per_page=2>; rel="last"`,
+		}, ", "), rec.Header().Get("Link"))
+	}
+}
+
+func TestUserHandlerAdmin_List_InvalidFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "verified", query: "verified=maybe"},
+		{name: "created_after", query: "created_after=yesterday"},
+		{name: "created range", query: "created_after=2022-11-02T00:00:00Z&created_before=2022-11-01T00:00:00Z"},
+		{name: "sort_by", query: "sort_by=password"},
+		{name: "sort_order", query: "sort_order=up"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/users?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			adminUser, persister := createAdmin()
+			setSessionToken(t, c, adminUser)
+
+			handler := NewUserHandlerAdmin(persister)
+
+			err := handler.List(c)
+			if assert.Error(t, err) {
+				httpError := dto.ToHttpError(err)
+				assert.Equal(t, http.StatusBadRequest, httpError.Code)
+			}
+		})
+	}
+}
+
 func TestGetLoginAuditRecordsByUserId_List(t *testing.T) {
 	userId := uuid.FromStringOrNil("8846cc7e-c748-4a5d-bf8b-673284b01974")
 	guestUserId := uuid.FromStringOrNil("40ac1f81-4d2d-4bf0-bc24-a0f6c067f171")
@@ -729,8 +860,7 @@ func TestUserHandlerAdmin_GetGrantsForUser_WhenUserIsNotAdmin_Errors(t *testing.
 	adminUser, persister := createAdmin()
 	setSessionToken(t, c, adminUser)
 
-	adminUser.IsAdmin = false
-	persister.GetUserPersister().Update(adminUser)
+	revokeRole(persister, adminUser.ID, models.RoleSuperAdmin)
 
 	for _, grant := range grants {
 		persister.GetUserGuestRelationPersister().Create(grant)
@@ -749,7 +879,7 @@ func TestUserHandlerAdmin_GetGrantsForUser_WhenUserIsNotAdmin_Errors(t *testing.
 
 	handler := NewUserHandlerAdmin(persister)
 
-	assert.Error(t, handler.GetGrantsForUser(c))
+	assert.Error(t, hankoMiddleware.Permission(persister, models.PermissionUsersRead)(handler.GetGrantsForUser)(c))
 }
 
 func TestUserHandlerAdmin_GetGrantsForUser_WhenUserIsAdmin(t *testing.T) {
@@ -831,78 +961,28 @@ func TestUserHandlerAdmin_GetGrantsForUser_WhenUserIsAdmin(t *testing.T) {
 	assert.Equal(t, 2, len(got.Grants))
 }
 
-func Test_validateAdminPermission_WhenUserIsAdminAndActive_ReturnsNoError(t *testing.T) {
-	e := echo.New()
-
-	q := make(url.Values)
-	q.Set("per_page", "invalidPerPageValue")
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
-	rec := httptest.NewRecorder()
-	c := e.NewContext(req, rec)
-
-	adminUser, persister := createAdmin()
-	setSessionToken(t, c, adminUser)
-
-	handler := NewUserHandlerAdmin(persister)
-	err, isSuccess := handler.validateAdminPermission(c)
-	assert.NoError(t, err)
-	assert.True(t, isSuccess)
-}
-
-func Test_validateAdminPermission_WhenUserIsNotAdminAndActive_ReturnsError(t *testing.T) {
-	e := echo.New()
-
-	q := make(url.Values)
-	q.Set("per_page", "invalidPerPageValue")
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
-	rec := httptest.NewRecorder()
-	c := e.NewContext(req, rec)
-
-	adminUser, persister := createAdmin()
-	setSessionToken(t, c, adminUser)
-
-	adminUser.IsAdmin = false
-	persister.GetUserPersister().Update(adminUser)
-
-	handler := NewUserHandlerAdmin(persister)
-	err, isSuccess := handler.validateAdminPermission(c)
-	assert.Error(t, err)
-	assert.False(t, isSuccess)
-}
-
-func Test_validateAdminPermission_WhenUserIsAdminAndNotActive_ReturnsError(t *testing.T) {
-	e := echo.New()
-
-	q := make(url.Values)
-	q.Set("per_page", "invalidPerPageValue")
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
-	rec := httptest.NewRecorder()
-	c := e.NewContext(req, rec)
-
-	adminUser, persister := createAdmin()
-	setSessionToken(t, c, adminUser)
-
-	adminUser.IsActive = false
-	persister.GetUserPersister().Update(adminUser)
-
-	handler := NewUserHandlerAdmin(persister)
-	err, isSuccess := handler.validateAdminPermission(c)
-	assert.Error(t, err)
-	assert.False(t, isSuccess)
-}
-
 func createAdmin() (models.User, persistence.Persister) {
 	userId := uuid.FromStringOrNil("6bc3a580-d922-42f3-9032-a4faf8faef5e")
 	user := models.User{
 		ID:       userId,
 		Email:    "admin@example.com",
-		IsAdmin:  true,
 		IsActive: true,
 	}
 	persister := test.NewPersister(append([]models.User{}, user), nil, nil, nil, nil, nil, nil, nil, nil)
+	grantRole(persister, userId, models.RoleSuperAdmin)
 	return user, persister
 }
 
+func grantRole(persister persistence.Persister, userId uuid.UUID, name string) {
+	role, _ := persister.GetRolePersister().GetByName(name)
+	persister.GetRolePersister().AddToUser(userId, role.ID)
+}
+
+func revokeRole(persister persistence.Persister, userId uuid.UUID, name string) {
+	role, _ := persister.GetRolePersister().GetByName(name)
+	persister.GetRolePersister().RemoveFromUser(userId, role.ID)
+}
+
 func setSessionToken(t *testing.T, c echo.Context, adminUser models.User) {
 	token := jwt.New()
 	err := token.Set(jwt.SubjectKey, adminUser.ID.String())
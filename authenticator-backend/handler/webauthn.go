@@ -13,6 +13,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/teamhanko/hanko/backend/config"
+	jwt2 "github.com/teamhanko/hanko/backend/crypto/jwt"
 	"github.com/teamhanko/hanko/backend/dto"
 	"github.com/teamhanko/hanko/backend/dto/intern"
 	"github.com/teamhanko/hanko/backend/persistence"
@@ -66,7 +67,7 @@ func (h *WebauthnHandler) BeginRegistration(c echo.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse userId from JWT subject:%w", err)
 	}
-	webauthnUser, err := h.getWebauthnUser(h.persister.GetConnection(), uId)
+	webauthnUser, err := getWebauthnUser(h.persister, h.persister.GetConnection(), uId)
 	if err != nil {
 		return fmt.Errorf("failed to get user: %w", err)
 	}
@@ -129,7 +130,7 @@ func (h *WebauthnHandler) FinishRegistration(c echo.Context) error {
 			return dto.NewHTTPError(http.StatusBadRequest, "Stored challenge and received challenge do not match").SetInternal(errors.New("userId in webauthn.sessionData does not match user session"))
 		}
 
-		webauthnUser, err := h.getWebauthnUser(tx, sessionData.UserId)
+		webauthnUser, err := getWebauthnUser(h.persister, tx, sessionData.UserId)
 		if err != nil {
 			return fmt.Errorf("failed to get user: %w", err)
 		}
@@ -154,6 +155,32 @@ func (h *WebauthnHandler) FinishRegistration(c echo.Context) error {
 			c.Logger().Errorf("failed to delete attestation session data: %w", err)
 		}
 
+		event := newSecurityEvent(c, models.EventPasskeyRegistered, &webauthnUser.UserId)
+		event.Metadata["credential_id"] = model.ID
+		err = h.persister.GetSecurityEventPersisterWithConnection(tx).Create(event)
+		if err != nil {
+			return fmt.Errorf("failed to create security event: %w", err)
+		}
+
+		// A session restricted after a recovery login is lifted, once the user has a new credential
+		if jwt2.GetRestrictionFromToken(sessionToken) == session.RestrictionRegisterCredential {
+			token, err := h.sessionManager.GenerateJWT(webauthnUser.UserId, webauthnUser.UserId, uuid.Nil)
+			if err != nil {
+				return fmt.Errorf("failed to generate jwt: %w", err)
+			}
+
+			cookie, err := h.sessionManager.GenerateCookie(token)
+			if err != nil {
+				return fmt.Errorf("failed to create session cookie: %w", err)
+			}
+
+			c.SetCookie(cookie)
+
+			if h.cfg.Session.EnableAuthTokenHeader {
+				c.Response().Header().Set("X-Auth-Token", token)
+			}
+		}
+
 		return c.JSON(http.StatusOK, map[string]string{"credential_id": model.ID, "user_id": webauthnUser.UserId.String()})
 	})
 }
@@ -179,7 +206,7 @@ func (h *WebauthnHandler) BeginAuthentication(c echo.Context) error {
 		if err != nil {
 			return dto.NewHTTPError(http.StatusBadRequest, "failed to parse UserID as uuid").SetInternal(err)
 		}
-		webauthnUser, err := h.getWebauthnUser(h.persister.GetConnection(), userId)
+		webauthnUser, err := getWebauthnUser(h.persister, h.persister.GetConnection(), userId)
 		if err != nil {
 			return dto.NewHTTPError(http.StatusInternalServerError).SetInternal(fmt.Errorf("failed to get user: %w", err))
 		}
@@ -248,7 +275,7 @@ func (h *WebauthnHandler) FinishAuthentication(c echo.Context) error {
 			if err != nil {
 				return dto.NewHTTPError(http.StatusBadRequest, "failed to parse userHandle as uuid").SetInternal(err)
 			}
-			webauthnUser, err = h.getWebauthnUser(tx, userId)
+			webauthnUser, err = getWebauthnUser(h.persister, tx, userId)
 			if err != nil {
 				return fmt.Errorf("failed to get user: %w", err)
 			}
@@ -265,7 +292,7 @@ func (h *WebauthnHandler) FinishAuthentication(c echo.Context) error {
 			}
 		} else {
 			// non discoverable Login
-			webauthnUser, err = h.getWebauthnUser(tx, sessionData.UserId)
+			webauthnUser, err = getWebauthnUser(h.persister, tx, sessionData.UserId)
 			if err != nil {
 				return fmt.Errorf("failed to get user: %w", err)
 			}
@@ -295,7 +322,7 @@ func (h *WebauthnHandler) FinishAuthentication(c echo.Context) error {
 
 		log := models.LoginAuditLog{
 			UserId:          webauthnUser.UserId,
-			ClientIpAddress: c.Request().RemoteAddr,
+			ClientIpAddress: c.RealIP(),
 			ClientUserAgent: c.Request().UserAgent(),
 			LoginMethod:     dto.LoginMethodToValue(dto.Webauthn),
 		}
@@ -314,20 +341,36 @@ func (h *WebauthnHandler) FinishAuthentication(c echo.Context) error {
 	})
 }
 
-func (h WebauthnHandler) getWebauthnUser(connection *pop.Connection, userId uuid.UUID) (*intern.WebauthnUser, error) {
-	user, err := h.persister.GetUserPersisterWithConnection(connection).Get(userId)
+// DeleteCredential deletes a webauthn credential of the account holder. It expects a valid session JWT in the request.
+func (h *WebauthnHandler) DeleteCredential(c echo.Context) error {
+	sessionToken, ok := c.Get("session").(jwt.Token)
+	if !ok {
+		return errors.New("failed to cast session object")
+	}
+
+	surrogateId, err := jwt2.GetSurrogateKeyFromToken(sessionToken)
+	if err != nil {
+		return dto.NewHTTPError(http.StatusUnauthorized).SetInternal(fmt.Errorf("unable to get surrogate ID from token: %w", err))
+	}
+
+	if sessionToken.Subject() != surrogateId {
+		return dto.NewHTTPError(http.StatusForbidden).SetInternal(fmt.Errorf("guest %s tried to delete a credential of user %s", surrogateId, sessionToken.Subject()))
+	}
+
+	credentialPersister := h.persister.GetWebauthnCredentialPersister()
+	credential, err := credentialPersister.Get(c.Param("id"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return fmt.Errorf("failed to get webauthn credential: %w", err)
 	}
 
-	if user == nil {
-		return nil, nil
+	if credential == nil || credential.UserId.String() != sessionToken.Subject() {
+		return dto.NewHTTPError(http.StatusNotFound, "credential not found")
 	}
 
-	credentials, err := h.persister.GetWebauthnCredentialPersisterWithConnection(connection).GetFromUser(user.ID)
+	err = credentialPersister.Delete(*credential)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get webauthn credentials: %w", err)
+		return fmt.Errorf("failed to delete webauthn credential: %w", err)
 	}
 
-	return intern.NewWebauthnUser(*user, credentials), nil
+	return c.NoContent(http.StatusNoContent)
 }
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/dto/intern"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+// beginUserVerifiedAssertion creates assertion options requiring user verification for the given user and stores the
+// session data. If the user has no credentials, options for a discoverable login are created instead.
+func beginUserVerifiedAssertion(wa *webauthn.WebAuthn, persister persistence.Persister, webauthnUser *intern.WebauthnUser) (*protocol.CredentialAssertion, error) {
+	var options *protocol.CredentialAssertion
+	var sessionData *webauthn.SessionData
+	var err error
+
+	if len(webauthnUser.WebAuthnCredentials()) > 0 {
+		options, sessionData, err = wa.BeginLogin(webauthnUser, webauthn.WithUserVerification(protocol.VerificationRequired))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create webauthn assertion options: %w", err)
+		}
+	}
+
+	if options == nil && sessionData == nil {
+		options, sessionData, err = wa.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create webauthn assertion options for discoverable login: %w", err)
+		}
+	}
+
+	err = persister.GetWebauthnSessionDataPersister().Create(*intern.WebauthnSessionDataToModel(sessionData, models.WebauthnOperationAuthentication))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store webauthn assertion session data: %w", err)
+	}
+
+	// Remove all transports, because of a bug in android and windows where the internal authenticator gets triggered,
+	// when the transports array contains the type 'internal' although the credential is not available on the device.
+	for i := range options.Response.AllowedCredentials {
+		options.Response.AllowedCredentials[i].Transport = nil
+	}
+
+	return options, nil
+}
+
+// finishAssertion validates the assertion against the stored session data and deletes the session data on success.
+func finishAssertion(wa *webauthn.WebAuthn, persister persistence.Persister, request *protocol.ParsedCredentialAssertionData) (*webauthn.Credential, *intern.WebauthnUser, error) {
+	var credential *webauthn.Credential
+	var webauthnUser *intern.WebauthnUser
+	err := persister.Transaction(func(tx *pop.Connection) error {
+		sessionDataPersister := persister.GetWebauthnSessionDataPersisterWithConnection(tx)
+		sessionData, err := sessionDataPersister.GetByChallenge(request.Response.CollectedClientData.Challenge)
+		if err != nil {
+			return fmt.Errorf("failed to get webauthn assertion session data: %w", err)
+		}
+
+		if sessionData != nil && sessionData.Operation != models.WebauthnOperationAuthentication {
+			sessionData = nil
+		}
+
+		if sessionData == nil {
+			return dto.NewHTTPError(http.StatusUnauthorized, "Stored challenge and received challenge do not match").SetInternal(errors.New("sessionData not found"))
+		}
+
+		model := intern.WebauthnSessionDataFromModel(sessionData)
+
+		if sessionData.UserId.IsNil() {
+			// Discoverable Login
+			userId, err := uuid.FromBytes(request.Response.UserHandle)
+			if err != nil {
+				return dto.NewHTTPError(http.StatusBadRequest, "failed to parse userHandle as uuid").SetInternal(err)
+			}
+			webauthnUser, err = getWebauthnUser(persister, tx, userId)
+			if err != nil {
+				return fmt.Errorf("failed to get user: %w", err)
+			}
+
+			if webauthnUser == nil {
+				return dto.NewHTTPError(http.StatusUnauthorized).SetInternal(errors.New("user not found"))
+			}
+
+			credential, err = wa.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (user webauthn.User, err error) {
+				return webauthnUser, nil
+			}, *model, request)
+			if err != nil {
+				return dto.NewHTTPError(http.StatusUnauthorized, "failed to validate assertion").SetInternal(err)
+			}
+		} else {
+			// non discoverable Login
+			webauthnUser, err = getWebauthnUser(persister, tx, sessionData.UserId)
+			if err != nil {
+				return fmt.Errorf("failed to get user: %w", err)
+			}
+			if webauthnUser == nil {
+				return dto.NewHTTPError(http.StatusUnauthorized).SetInternal(errors.New("user not found"))
+			}
+			credential, err = wa.ValidateLogin(webauthnUser, *model, request)
+			if err != nil {
+				return dto.NewHTTPError(http.StatusUnauthorized, "failed to validate assertion").SetInternal(err)
+			}
+		}
+
+		err = sessionDataPersister.Delete(*sessionData)
+		if err != nil {
+			return fmt.Errorf("failed to delete assertion session data: %w", err)
+		}
+		return nil
+	})
+	return credential, webauthnUser, err
+}
+
+func getWebauthnUser(persister persistence.Persister, connection *pop.Connection, userId uuid.UUID) (*intern.WebauthnUser, error) {
+	user, err := persister.GetUserPersisterWithConnection(connection).Get(userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user == nil {
+		return nil, nil
+	}
+
+	credentials, err := persister.GetWebauthnCredentialPersisterWithConnection(connection).GetFromUser(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webauthn credentials: %w", err)
+	}
+
+	return intern.NewWebauthnUser(*user, credentials), nil
+}
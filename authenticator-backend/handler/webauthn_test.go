@@ -17,6 +17,7 @@ import (
 	"github.com/teamhanko/hanko/backend/config"
 	"github.com/teamhanko/hanko/backend/dto"
 	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/session"
 	"github.com/teamhanko/hanko/backend/test"
 )
 
@@ -182,7 +183,7 @@ var defaultConfig = config.Config{
 type sessionManager struct {
 }
 
-func (sessionManager) GenerateJWT(_ uuid.UUID, _ uuid.UUID, _ uuid.UUID) (string, error) {
+func (sessionManager) GenerateJWT(_ uuid.UUID, _ uuid.UUID, _ uuid.UUID, _ ...session.Option) (string, error) {
 	return userId, nil
 }
 
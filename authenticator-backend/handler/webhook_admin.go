@@ -0,0 +1,322 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+type WebhookHandlerAdmin struct {
+	persister persistence.Persister
+}
+
+// NewWebhookHandlerAdmin creates a handler for managing the webhook endpoints and their deliveries. The permissions
+// are checked by the Permission middleware.
+func NewWebhookHandlerAdmin(persister persistence.Persister) *WebhookHandlerAdmin {
+	return &WebhookHandlerAdmin{persister: persister}
+}
+
+type WebhookEndpointResponse struct {
+	ID          uuid.UUID `json:"id"`
+	Url         string    `json:"url"`
+	Description string    `json:"description"`
+	Events      []string  `json:"events"`
+	IsActive    bool      `json:"is_active"`
+	// Secret is only returned when the endpoint is created
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newWebhookEndpointResponse(endpoint models.WebhookEndpoint) WebhookEndpointResponse {
+	return WebhookEndpointResponse{
+		ID:          endpoint.ID,
+		Url:         endpoint.Url,
+		Description: endpoint.Description,
+		Events:      endpoint.EventTypes(),
+		IsActive:    endpoint.IsActive,
+		CreatedAt:   endpoint.CreatedAt,
+		UpdatedAt:   endpoint.UpdatedAt,
+	}
+}
+
+type WebhookEndpointCreateRequest struct {
+	Url         string `json:"url" validate:"required"`
+	Description string `json:"description"`
+	// Events are the subscribed event types, all events are subscribed if it is empty
+	Events []string `json:"events"`
+}
+
+type WebhookEndpointUpdateRequest struct {
+	Url         *string   `json:"url"`
+	Description *string   `json:"description"`
+	Events      *[]string `json:"events"`
+	IsActive    *bool     `json:"is_active"`
+}
+
+func validateWebhookUrl(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return dto.NewHTTPError(http.StatusBadRequest, "url must be an https url")
+	}
+	return nil
+}
+
+func validateWebhookEvents(eventTypes []string) error {
+	for _, eventType := range eventTypes {
+		known := false
+		for _, knownType := range models.WebhookEventTypes {
+			if eventType == knownType {
+				known = true
+			}
+		}
+		if !known {
+			return dto.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unknown event type %s", eventType))
+		}
+	}
+	return nil
+}
+
+func (h *WebhookHandlerAdmin) getEndpoint(c echo.Context) (*models.WebhookEndpoint, error) {
+	endpointId, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return nil, dto.NewHTTPError(http.StatusBadRequest, "failed to parse id as uuid").SetInternal(err)
+	}
+	endpoint, err := h.persister.GetWebhookPersister().GetEndpoint(endpointId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook endpoint: %w", err)
+	}
+	if endpoint == nil {
+		return nil, dto.NewHTTPError(http.StatusNotFound, "webhook endpoint not found")
+	}
+	return endpoint, nil
+}
+
+func (h *WebhookHandlerAdmin) List(c echo.Context) error {
+	endpoints, err := h.persister.GetWebhookPersister().ListEndpoints()
+	if err != nil {
+		return fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+
+	response := []WebhookEndpointResponse{}
+	for _, endpoint := range endpoints {
+		response = append(response, newWebhookEndpointResponse(endpoint))
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// Create registers an endpoint. The secret which signs the deliveries is generated and only returned once.
+func (h *WebhookHandlerAdmin) Create(c echo.Context) error {
+	var request WebhookEndpointCreateRequest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &request); err != nil {
+		return dto.ToHttpError(err)
+	}
+	if err := c.Validate(request); err != nil {
+		return dto.ToHttpError(err)
+	}
+	if err := validateWebhookUrl(request.Url); err != nil {
+		return err
+	}
+	if err := validateWebhookEvents(request.Events); err != nil {
+		return err
+	}
+
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return fmt.Errorf("failed to generate webhook endpoint id: %w", err)
+	}
+	now := time.Now().UTC()
+	endpoint := models.WebhookEndpoint{
+		ID:          id,
+		Url:         request.Url,
+		Description: request.Description,
+		Secret:      "whsec_" + hex.EncodeToString(secret),
+		IsActive:    true,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	endpoint.SetEventTypes(request.Events)
+
+	err = h.persister.GetWebhookPersister().CreateEndpoint(endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook endpoint: %w", err)
+	}
+
+	response := newWebhookEndpointResponse(endpoint)
+	response.Secret = endpoint.Secret
+	return c.JSON(http.StatusCreated, response)
+}
+
+func (h *WebhookHandlerAdmin) Get(c echo.Context) error {
+	endpoint, err := h.getEndpoint(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, newWebhookEndpointResponse(*endpoint))
+}
+
+func (h *WebhookHandlerAdmin) Update(c echo.Context) error {
+	endpoint, err := h.getEndpoint(c)
+	if err != nil {
+		return err
+	}
+
+	var request WebhookEndpointUpdateRequest
+	if err := (&echo.DefaultBinder{}).BindBody(c, &request); err != nil {
+		return dto.ToHttpError(err)
+	}
+	if request.Url != nil {
+		if err := validateWebhookUrl(*request.Url); err != nil {
+			return err
+		}
+		endpoint.Url = *request.Url
+	}
+	if request.Description != nil {
+		endpoint.Description = *request.Description
+	}
+	if request.Events != nil {
+		if err := validateWebhookEvents(*request.Events); err != nil {
+			return err
+		}
+		endpoint.SetEventTypes(*request.Events)
+	}
+	if request.IsActive != nil {
+		endpoint.IsActive = *request.IsActive
+	}
+	endpoint.UpdatedAt = time.Now().UTC()
+
+	err = h.persister.GetWebhookPersister().UpdateEndpoint(*endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook endpoint: %w", err)
+	}
+	return c.JSON(http.StatusOK, newWebhookEndpointResponse(*endpoint))
+}
+
+// Delete removes the endpoint together with its deliveries
+func (h *WebhookHandlerAdmin) Delete(c echo.Context) error {
+	endpoint, err := h.getEndpoint(c)
+	if err != nil {
+		return err
+	}
+	err = h.persister.GetWebhookPersister().DeleteEndpoint(*endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook endpoint: %w", err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+type WebhookDeliveryListRequest struct {
+	PerPage int        `query:"per_page"`
+	Page    int        `query:"page"`
+	Status  string     `query:"status"`
+	From    *time.Time `query:"from"`
+	To      *time.Time `query:"to"`
+}
+
+func bindWebhookDeliveryFilter(c echo.Context, request *WebhookDeliveryListRequest) (persistence.WebhookDeliveryFilter, error) {
+	err := (&echo.DefaultBinder{}).BindQueryParams(c, request)
+	if err != nil {
+		return persistence.WebhookDeliveryFilter{}, dto.ToHttpError(err)
+	}
+	switch request.Status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliveryDelivered, models.WebhookDeliveryFailed:
+	default:
+		return persistence.WebhookDeliveryFilter{}, dto.NewHTTPError(http.StatusBadRequest, "unknown status")
+	}
+	if request.From != nil && request.To != nil && !request.From.Before(*request.To) {
+		return persistence.WebhookDeliveryFilter{}, dto.NewHTTPError(http.StatusBadRequest, "from must be before to")
+	}
+	return persistence.WebhookDeliveryFilter{Status: request.Status, From: request.From, To: request.To}, nil
+}
+
+// ListDeliveries returns the deliveries to the endpoint, newest first
+func (h *WebhookHandlerAdmin) ListDeliveries(c echo.Context) error {
+	endpoint, err := h.getEndpoint(c)
+	if err != nil {
+		return err
+	}
+	var request WebhookDeliveryListRequest
+	filter, err := bindWebhookDeliveryFilter(c, &request)
+	if err != nil {
+		return err
+	}
+
+	page, perPage := normalizePagination(request.Page, request.PerPage)
+	deliveries, total, err := h.persister.GetWebhookPersister().ListDeliveries(endpoint.ID, filter, page, perPage)
+	if err != nil {
+		return fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+
+	setPaginationHeaders(c, page, perPage, total)
+	return c.JSON(http.StatusOK, deliveries)
+}
+
+// ReplayDelivery resets a delivery of the endpoint, so that it is sent again with all attempts
+func (h *WebhookHandlerAdmin) ReplayDelivery(c echo.Context) error {
+	endpoint, err := h.getEndpoint(c)
+	if err != nil {
+		return err
+	}
+	deliveryId, err := uuid.FromString(c.Param("delivery_id"))
+	if err != nil {
+		return dto.NewHTTPError(http.StatusBadRequest, "failed to parse delivery_id as uuid").SetInternal(err)
+	}
+
+	webhookPersister := h.persister.GetWebhookPersister()
+	delivery, err := webhookPersister.GetDelivery(deliveryId)
+	if err != nil {
+		return fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+	if delivery == nil || delivery.EndpointId != endpoint.ID {
+		return dto.NewHTTPError(http.StatusNotFound, "webhook delivery not found")
+	}
+
+	delivery.Replay(time.Now().UTC())
+	err = webhookPersister.UpdateDelivery(*delivery)
+	if err != nil {
+		return fmt.Errorf("failed to replay webhook delivery: %w", err)
+	}
+	return c.JSON(http.StatusAccepted, delivery)
+}
+
+// Replay resets the deliveries of the endpoint matching the filter, by default the failed ones, so that they are sent
+// again
+func (h *WebhookHandlerAdmin) Replay(c echo.Context) error {
+	endpoint, err := h.getEndpoint(c)
+	if err != nil {
+		return err
+	}
+	var request WebhookDeliveryListRequest
+	filter, err := bindWebhookDeliveryFilter(c, &request)
+	if err != nil {
+		return err
+	}
+	if filter.Status == "" {
+		filter.Status = models.WebhookDeliveryFailed
+	}
+
+	var replayed int
+	err = h.persister.Transaction(func(tx *pop.Connection) error {
+		replayed, err = h.persister.GetWebhookPersisterWithConnection(tx).ReplayDeliveries(endpoint.ID, filter, time.Now().UTC())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replay webhook deliveries: %w", err)
+	}
+	return c.JSON(http.StatusAccepted, map[string]int{"replayed": replayed})
+}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+	"github.com/teamhanko/hanko/backend/webhook"
+)
+
+func newWebhookContext(method string, target string, body string, names []string, values []string) (echo.Context, *httptest.ResponseRecorder) {
+	c, rec := newContext(method, target, body)
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+	return c, rec
+}
+
+func createWebhookEndpoint(t *testing.T, persister persistence.Persister, body string) WebhookEndpointResponse {
+	c, rec := newWebhookContext(http.MethodPost, "/webhooks", body, nil, nil)
+	require.NoError(t, NewWebhookHandlerAdmin(persister).Create(c))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var response WebhookEndpointResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return response
+}
+
+func TestWebhookHandlerAdmin_Create(t *testing.T) {
+	persister := test.NewPersister(nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	response := createWebhookEndpoint(t, persister, `{"url": "https://app.example.com/hooks", "description": "app", "events": ["relation.created", "relation.revoked"]}`)
+
+	assert.Equal(t, "https://app.example.com/hooks", response.Url)
+	assert.Equal(t, []string{models.WebhookEventRelationCreated, models.WebhookEventRelationRevoked}, response.Events)
+	assert.True(t, response.IsActive)
+	assert.True(t, strings.HasPrefix(response.Secret, "whsec_"))
+
+	// the secret is only returned once
+	c, rec := newWebhookContext(http.MethodGet, "/", "", []string{"id"}, []string{response.ID.String()})
+	require.NoError(t, NewWebhookHandlerAdmin(persister).Get(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), response.Secret)
+
+	endpoint, err := persister.GetWebhookPersister().GetEndpoint(response.ID)
+	require.NoError(t, err)
+	assert.Equal(t, response.Secret, endpoint.Secret)
+}
+
+func TestWebhookHandlerAdmin_Create_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "missing url", body: `{}`},
+		{name: "http url", body: `{"url": "http://app.example.com/hooks"}`},
+		{name: "unknown event", body: `{"url": "https://app.example.com/hooks", "events": ["user.logged_in"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			persister := test.NewPersister(nil, nil, nil, nil, nil, nil, nil, nil, nil)
+			c, _ := newWebhookContext(http.MethodPost, "/webhooks", tt.body, nil, nil)
+			err := NewWebhookHandlerAdmin(persister).Create(c)
+			httpError := dto.ToHttpError(err)
+			require.NotNil(t, httpError)
+			assert.Equal(t, http.StatusBadRequest, httpError.Code)
+		})
+	}
+}
+
+func TestWebhookHandlerAdmin_Update(t *testing.T) {
+	persister := test.NewPersister(nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	created := createWebhookEndpoint(t, persister, `{"url": "https://app.example.com/hooks"}`)
+
+	c, rec := newWebhookContext(http.MethodPatch, "/", `{"is_active": false, "events": ["user.deleted"]}`, []string{"id"}, []string{created.ID.String()})
+	require.NoError(t, NewWebhookHandlerAdmin(persister).Update(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	endpoint, err := persister.GetWebhookPersister().GetEndpoint(created.ID)
+	require.NoError(t, err)
+	assert.False(t, endpoint.IsActive)
+	assert.Equal(t, []string{models.WebhookEventUserDeleted}, endpoint.EventTypes())
+	assert.Equal(t, "https://app.example.com/hooks", endpoint.Url)
+}
+
+func TestWebhookHandlerAdmin_Replay(t *testing.T) {
+	persister := test.NewPersister(nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	created := createWebhookEndpoint(t, persister, `{"url": "https://app.example.com/hooks"}`)
+	endpoint, err := persister.GetWebhookPersister().GetEndpoint(created.ID)
+	require.NoError(t, err)
+
+	webhookPersister := persister.GetWebhookPersister()
+	now := time.Now().UTC()
+	var deliveries []models.WebhookDelivery
+	for _, status := range []string{models.WebhookDeliveryFailed, models.WebhookDeliveryDelivered, models.WebhookDeliveryFailed} {
+		event, err := webhook.NewEvent(models.WebhookEventUserCreated, webhook.UserData{UserId: generateUuid(t)})
+		require.NoError(t, err)
+		require.NoError(t, webhookPersister.CreateEvent(event))
+		delivery := models.NewWebhookDelivery(event, *endpoint, now)
+		delivery.Status = status
+		delivery.Attempts = 10
+		require.NoError(t, webhookPersister.CreateDelivery(delivery))
+		deliveries = append(deliveries, delivery)
+	}
+
+	c, rec := newWebhookContext(http.MethodGet, "/?status=failed", "", []string{"id"}, []string{endpoint.ID.String()})
+	require.NoError(t, NewWebhookHandlerAdmin(persister).ListDeliveries(c))
+	assert.Equal(t, "2", rec.Header().Get("X-Total-Count"))
+
+	// a single delivery
+	c, rec = newWebhookContext(http.MethodPost, "/", "", []string{"id", "delivery_id"}, []string{endpoint.ID.String(), deliveries[1].ID.String()})
+	require.NoError(t, NewWebhookHandlerAdmin(persister).ReplayDelivery(c))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	delivery, err := webhookPersister.GetDelivery(deliveries[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookDeliveryPending, delivery.Status)
+	assert.Equal(t, 0, delivery.Attempts)
+
+	// all failed deliveries
+	c, rec = newWebhookContext(http.MethodPost, "/", "", []string{"id"}, []string{endpoint.ID.String()})
+	require.NoError(t, NewWebhookHandlerAdmin(persister).Replay(c))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.JSONEq(t, `{"replayed": 2}`, rec.Body.String())
+	due, err := webhookPersister.ListDueDeliveries(time.Now().UTC(), 10)
+	require.NoError(t, err)
+	assert.Len(t, due, 3)
+}
+
+func TestWebhookHandlerAdmin_ReplayDelivery_OtherEndpoint(t *testing.T) {
+	persister := test.NewPersister(nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	first := createWebhookEndpoint(t, persister, `{"url": "https://app.example.com/hooks"}`)
+	second := createWebhookEndpoint(t, persister, `{"url": "https://other.example.com/hooks"}`)
+	endpoint, err := persister.GetWebhookPersister().GetEndpoint(first.ID)
+	require.NoError(t, err)
+	event, err := webhook.NewEvent(models.WebhookEventUserCreated, webhook.UserData{UserId: generateUuid(t)})
+	require.NoError(t, err)
+	delivery := models.NewWebhookDelivery(event, *endpoint, time.Now().UTC())
+	require.NoError(t, persister.GetWebhookPersister().CreateDelivery(delivery))
+
+	c, _ := newWebhookContext(http.MethodPost, "/", "", []string{"id", "delivery_id"}, []string{second.ID.String(), delivery.ID.String()})
+	err = NewWebhookHandlerAdmin(persister).ReplayDelivery(c)
+	httpError := dto.ToHttpError(err)
+	require.NotNil(t, httpError)
+	assert.Equal(t, http.StatusNotFound, httpError.Code)
+}
+
+func TestUserHandlerAdmin_ToggleIsActiveForUser_EnqueuesWebhookEvent(t *testing.T) {
+	userId := generateUuid(t)
+	c, _ := newWebhookContext(http.MethodPut, "/", "", []string{"id"}, []string{userId.String()})
+	adminUser, persister := createAdmin()
+	setSessionToken(t, c, adminUser)
+	require.NoError(t, persister.GetUserPersister().Create(models.User{ID: userId, Email: "testy@example.com", IsActive: true}))
+
+	require.NoError(t, NewUserHandlerAdmin(persister).ToggleIsActiveForUser(c))
+
+	events, err := persister.GetWebhookPersister().ListUndispatchedEvents(10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.WebhookEventUserDeactivated, events[0].Type)
+	var payload webhook.Payload
+	payload.Data = &webhook.UserData{}
+	require.NoError(t, json.Unmarshal([]byte(events[0].Payload), &payload))
+	assert.Equal(t, events[0].ID, payload.ID)
+	assert.Equal(t, userId, payload.Data.(*webhook.UserData).UserId)
+}
+
+func TestUserHandler_RemoveAccessToRelation_EnqueuesWebhookEvent(t *testing.T) {
+	accountHolder := generateUser(t)
+	guest := generateUser(t)
+	now := time.Now().UTC()
+	relation := models.UserGuestRelation{ID: generateUuid(t), ParentUserID: accountHolder.ID, GuestUserID: guest.ID, IsActive: true, CreatedAt: now, UpdatedAt: now}
+	persister := test.NewPersister([]models.User{accountHolder, guest}, nil, nil, nil, nil, nil, nil, []models.UserGuestRelation{relation}, nil)
+
+	c, _ := newWebhookContext(http.MethodDelete, "/", "", []string{"id"}, []string{relation.ID.String()})
+	c.Set("session", generateJwt(t, accountHolder.ID, accountHolder.ID, 60))
+	require.NoError(t, NewUserHandler(&defaultConfig, persister, sessionManager{}).RemoveAccessToRelation(c))
+
+	events, err := persister.GetWebhookPersister().ListUndispatchedEvents(10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.WebhookEventRelationRevoked, events[0].Type)
+	var payload webhook.Payload
+	payload.Data = &webhook.RelationData{}
+	require.NoError(t, json.Unmarshal([]byte(events[0].Payload), &payload))
+	data := payload.Data.(*webhook.RelationData)
+	assert.Equal(t, relation.ID, data.RelationId)
+	assert.Equal(t, guest.ID, data.GuestUserId)
+	assert.Equal(t, webhook.ReasonRevoked, data.Reason)
+}
@@ -68,3 +68,33 @@ func TestGetPublicKeys(t *testing.T) {
 		assert.NoError(t, err)
 	}
 }
+
+func TestGetConfig_PublishesPasswordPolicy(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/config", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	cfg := config.Config{Password: config.Password{
+		Enabled:           true,
+		MinPasswordLength: 10,
+		Policy: config.PasswordPolicy{
+			RequireDigit:      true,
+			MinStrength:       3,
+			BreachedPasswords: config.BreachedPasswords{Enabled: true, File: "/etc/hanko/breached.txt"},
+		},
+	}}
+	h, err := NewWellKnownHandler(cfg, faultyJwkManager{})
+	assert.NoError(t, err)
+
+	if assert.NoError(t, h.GetConfig(c)) {
+		assert.Equal(t, http.StatusOK, rec.Result().StatusCode)
+		publicConfig := dto.PublicConfig{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &publicConfig))
+		assert.Equal(t, 10, publicConfig.Password.MinPasswordLength)
+		assert.True(t, publicConfig.Password.Policy.RequireDigit)
+		assert.Equal(t, 3, publicConfig.Password.Policy.MinStrength)
+		assert.True(t, publicConfig.Password.Policy.BreachedPasswords.Enabled)
+		assert.NotContains(t, rec.Body.String(), "breached.txt")
+	}
+}
@@ -2,6 +2,7 @@ package mail
 
 import (
 	"github.com/stretchr/testify/assert"
+	"html/template"
 	"testing"
 )
 
@@ -73,3 +74,22 @@ func TestRenderer_Render(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderer_Render_LoginLink(t *testing.T) {
+	renderer, err := NewRenderer()
+	assert.NoError(t, err)
+
+	templateData := map[string]interface{}{
+		"TTL":         5,
+		"ServiceName": "Test Service",
+		"Link":        template.HTML("https://example.com/#/login/link?id=1&token=abc"),
+	}
+
+	text, err := renderer.Render("loginLinkTextMail", "en", templateData)
+	assert.NoError(t, err)
+	assert.Equal(t, "Click the button below to sign in to Test Service.\n\nhttps://example.com/#/login/link?id=1&token=abc\n\nThe link is valid for 5 minutes. It only works in the browser in which you requested it.", text)
+
+	html, err := renderer.Render("loginLinkHtmlMail", "en", templateData)
+	assert.NoError(t, err)
+	assert.Contains(t, html, `<a href="https://example.com/#/login/link?id=1&amp;token=abc"`)
+}
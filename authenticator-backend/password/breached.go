@@ -0,0 +1,182 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// maxLineLength bounds a line of the corpus, a hash of 40 characters followed by a colon and the number of occurrences
+const maxLineLength = 128
+
+// prefixLength is the number of hex characters of the hash which name a range file, the lines of the file contain the
+// remaining characters
+const prefixLength = 5
+
+// BreachedCorpus checks passwords against a local corpus of SHA-1 hashes of breached passwords, in one of the formats
+// of the Have I Been Pwned downloads:
+//
+// A directory of k-anonymity range files: each file is named by the first 5 hex characters of the hashes it contains,
+// e.g. "5BAA6.txt", and each line contains the remaining 35 characters, optionally followed by a colon and the number
+// of occurrences. Only the range file of the password is read. A missing range file contains no hashes.
+//
+// A single file of full hashes: each line contains a hex encoded hash, optionally followed by a colon and the number of
+// occurrences. The file must be ordered by hash. It is not loaded into memory, as it is tens of gigabytes large, every
+// lookup binary searches the file instead.
+//
+// The hashes may be uppercase or lowercase.
+type BreachedCorpus struct {
+	path string
+	once sync.Once
+	dir  bool
+	f    *os.File
+	size int64
+	err  error
+}
+
+// NewBreachedCorpus returns the corpus at the path, which is a directory of range files or a single ordered file
+func NewBreachedCorpus(path string) *BreachedCorpus {
+	return &BreachedCorpus{path: path}
+}
+
+// Contains returns whether the password is part of the corpus
+func (b *BreachedCorpus) Contains(password string) (bool, error) {
+	b.once.Do(b.open)
+	if b.err != nil {
+		return false, b.err
+	}
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	if b.dir {
+		return b.rangeContains(hash)
+	}
+
+	// find the smallest offset from which on the first line has a hash not less than the hash searched for
+	low, high := int64(0), b.size
+	for low < high {
+		mid := low + (high-low)/2
+		line, err := b.lineFrom(mid)
+		if err != nil {
+			return false, err
+		}
+		if line == "" || line >= hash {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+
+	line, err := b.lineFrom(low)
+	if err != nil {
+		return false, err
+	}
+	return line == hash, nil
+}
+
+func (b *BreachedCorpus) open() {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		b.err = fmt.Errorf("failed to stat breached password file: %w", err)
+		return
+	}
+	if info.IsDir() {
+		b.dir = true
+		return
+	}
+
+	f, err := os.Open(b.path)
+	if err != nil {
+		b.err = fmt.Errorf("failed to open breached password file: %w", err)
+		return
+	}
+
+	b.f = f
+	b.size = info.Size()
+}
+
+// rangeContains returns whether the range file of the prefix of the hash contains the suffix of the hash
+func (b *BreachedCorpus) rangeContains(hash string) (bool, error) {
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+	f, err := os.Open(filepath.Join(b.path, prefix+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to open breached password range file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			line = line[:i]
+		}
+		if strings.ToUpper(strings.TrimSpace(line)) == suffix {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to read breached password range file: %w", err)
+	}
+	return false, nil
+}
+
+// lineFrom returns the uppercase hash of the first line which starts at or after the offset. An empty string is
+// returned if there is no such line.
+func (b *BreachedCorpus) lineFrom(offset int64) (string, error) {
+	if offset > 0 {
+		// skip the rest of the line the offset points into, unless the offset is the start of a line
+		start, err := b.nextLineStart(offset - 1)
+		if err != nil {
+			return "", err
+		}
+		offset = start
+	}
+	if offset >= b.size {
+		return "", nil
+	}
+
+	buf := make([]byte, maxLineLength)
+	n, err := b.f.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read breached password file: %w", err)
+	}
+
+	line := buf[:n]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	if i := bytes.IndexByte(line, ':'); i >= 0 {
+		line = line[:i]
+	}
+	return strings.ToUpper(strings.TrimSpace(string(line))), nil
+}
+
+// nextLineStart returns the offset following the next line break at or after the offset
+func (b *BreachedCorpus) nextLineStart(offset int64) (int64, error) {
+	buf := make([]byte, maxLineLength)
+	for offset < b.size {
+		n, err := b.f.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return 0, fmt.Errorf("failed to read breached password file: %w", err)
+		}
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return offset + int64(i) + 1, nil
+		}
+		if n == 0 {
+			break
+		}
+		offset += int64(n)
+	}
+	return b.size, nil
+}
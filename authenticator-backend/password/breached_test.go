@@ -0,0 +1,104 @@
+package password
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/config"
+)
+
+// SHA-1 of "password" and "verybadpassword", ordered by hash
+const corpus = `5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8:9545824
+BC64853B86B5CC05AF75C81D8E7961A6DA399179:3
+`
+
+func TestBreachedCorpus_Contains(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(file, []byte(corpus), 0600))
+
+	b := NewBreachedCorpus(file)
+
+	breached, err := b.Contains("password")
+	assert.NoError(t, err)
+	assert.True(t, breached)
+
+	breached, err = b.Contains("Correct-Horse-7-Battery")
+	assert.NoError(t, err)
+	assert.False(t, breached)
+}
+
+func TestBreachedCorpus_Contains_SearchesOrderedFile(t *testing.T) {
+	passwords := make([]string, 1000)
+	hashes := make([]string, len(passwords))
+	for i := range passwords {
+		passwords[i] = fmt.Sprintf("password-%d", i)
+		sum := sha1.Sum([]byte(passwords[i]))
+		hashes[i] = fmt.Sprintf("%X:%d", sum, i+1)
+	}
+	sort.Strings(hashes)
+
+	file := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(file, []byte(strings.Join(hashes, "\r\n")), 0600))
+
+	b := NewBreachedCorpus(file)
+	for _, password := range passwords {
+		breached, err := b.Contains(password)
+		require.NoError(t, err)
+		assert.True(t, breached, password)
+	}
+
+	for _, password := range []string{"password-1000", "password--1", ""} {
+		breached, err := b.Contains(password)
+		require.NoError(t, err)
+		assert.False(t, breached, password)
+	}
+}
+
+func TestBreachedCorpus_Contains_SearchesRangeFiles(t *testing.T) {
+	dir := t.TempDir()
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "5BAA6.txt"), []byte("003D68EB55068C33ACE09247EE4C639306B:3\r\n1e4c9b93f3f0682250b6cf8331b7ee68fd8:9545824\r\n"), 0600))
+	// SHA-1 of "verybadpassword" is BC64853B86B5CC05AF75C81D8E7961A6DA399179
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "BC648.txt"), []byte("003D68EB55068C33ACE09247EE4C639306B:3\r\n"), 0600))
+
+	b := NewBreachedCorpus(dir)
+
+	breached, err := b.Contains("password")
+	assert.NoError(t, err)
+	assert.True(t, breached)
+
+	// the range file of the prefix doesn't contain the suffix
+	breached, err = b.Contains("verybadpassword")
+	assert.NoError(t, err)
+	assert.False(t, breached)
+
+	// there is no range file of the prefix
+	breached, err = b.Contains("Correct-Horse-7-Battery")
+	assert.NoError(t, err)
+	assert.False(t, breached)
+}
+
+func TestBreachedCorpus_Contains_Errors_WhenFileIsMissing(t *testing.T) {
+	b := NewBreachedCorpus(filepath.Join(t.TempDir(), "missing.txt"))
+
+	_, err := b.Contains("password")
+	assert.Error(t, err)
+}
+
+func TestPolicy_Check_Breached(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(file, []byte(corpus), 0600))
+
+	policy := NewPolicy(config.Password{Policy: config.PasswordPolicy{BreachedPasswords: config.BreachedPasswords{Enabled: true, File: file}}}, "", 72)
+
+	violations, err := policy.Check("password", "john.doe@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{ViolationBreached}, violationCodes(violations))
+}
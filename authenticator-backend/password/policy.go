@@ -0,0 +1,120 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/teamhanko/hanko/backend/config"
+)
+
+// Codes of policy violations. The frontend uses them to display localized messages.
+const (
+	ViolationTooShort            = "too_short"
+	ViolationTooLong             = "too_long"
+	ViolationMissingLowercase    = "missing_lowercase"
+	ViolationMissingUppercase    = "missing_uppercase"
+	ViolationMissingDigit        = "missing_digit"
+	ViolationMissingSymbol       = "missing_symbol"
+	ViolationContainsEmail       = "contains_email"
+	ViolationContainsServiceName = "contains_service_name"
+	ViolationTooWeak             = "too_weak"
+	ViolationBreached            = "breached"
+)
+
+// Violation is a rule of the policy which the password does not satisfy
+type Violation struct {
+	Code   string                 `json:"code"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+// Policy checks new passwords against the configured password rules
+type Policy struct {
+	cfg         config.Password
+	serviceName string
+	maxLength   int
+	breached    *BreachedCorpus
+}
+
+// NewPolicy creates a policy for the password settings. The maxLength in bytes depends on the hashing algorithm, see
+// crypto.Hasher.
+func NewPolicy(cfg config.Password, serviceName string, maxLength int) *Policy {
+	policy := &Policy{cfg: cfg, serviceName: serviceName, maxLength: maxLength}
+	if cfg.Policy.BreachedPasswords.Enabled {
+		policy.breached = NewBreachedCorpus(cfg.Policy.BreachedPasswords.File)
+	}
+	return policy
+}
+
+// Check returns all violations of the policy. The email is the one of the user the password is set for.
+func (p *Policy) Check(password string, email string) ([]Violation, error) {
+	var violations []Violation
+
+	if utf8.RuneCountInString(password) < p.cfg.MinPasswordLength { // use utf8.RuneCountInString, so utf8 characters would count as 1
+		violations = append(violations, Violation{Code: ViolationTooShort, Params: map[string]interface{}{"min": p.cfg.MinPasswordLength}})
+	}
+	if len(password) > p.maxLength {
+		violations = append(violations, Violation{Code: ViolationTooLong, Params: map[string]interface{}{"max": p.maxLength}})
+	}
+
+	if p.cfg.Policy.RequireLowercase && !containsAny(password, unicode.IsLower) {
+		violations = append(violations, Violation{Code: ViolationMissingLowercase})
+	}
+	if p.cfg.Policy.RequireUppercase && !containsAny(password, unicode.IsUpper) {
+		violations = append(violations, Violation{Code: ViolationMissingUppercase})
+	}
+	if p.cfg.Policy.RequireDigit && !containsAny(password, unicode.IsDigit) {
+		violations = append(violations, Violation{Code: ViolationMissingDigit})
+	}
+	if p.cfg.Policy.RequireSymbol && !containsAny(password, isSymbol) {
+		violations = append(violations, Violation{Code: ViolationMissingSymbol})
+	}
+
+	normalized := normalize(password)
+	if localPart := normalize(strings.SplitN(email, "@", 2)[0]); len(localPart) >= 3 && strings.Contains(normalized, localPart) {
+		violations = append(violations, Violation{Code: ViolationContainsEmail})
+	}
+	if serviceName := normalize(p.serviceName); len(serviceName) >= 3 && strings.Contains(normalized, serviceName) {
+		violations = append(violations, Violation{Code: ViolationContainsServiceName})
+	}
+
+	if strength := Strength(password); strength < p.cfg.Policy.MinStrength {
+		violations = append(violations, Violation{Code: ViolationTooWeak, Params: map[string]interface{}{"strength": strength, "min": p.cfg.Policy.MinStrength}})
+	}
+
+	if p.breached != nil {
+		breached, err := p.breached.Contains(password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check breached passwords: %w", err)
+		}
+		if breached {
+			violations = append(violations, Violation{Code: ViolationBreached})
+		}
+	}
+
+	return violations, nil
+}
+
+// normalize lowercases the value and removes all separators, so e.g. "John.Doe" matches "john-doe"
+func normalize(value string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, value)
+}
+
+func containsAny(password string, fn func(rune) bool) bool {
+	for _, r := range password {
+		if fn(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSymbol(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+}
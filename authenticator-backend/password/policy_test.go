@@ -0,0 +1,83 @@
+package password
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/config"
+)
+
+func violationCodes(violations []Violation) []string {
+	codes := []string{}
+	for _, v := range violations {
+		codes = append(codes, v.Code)
+	}
+	return codes
+}
+
+func TestPolicy_Check(t *testing.T) {
+	cfg := config.Password{
+		MinPasswordLength: 8,
+		Policy: config.PasswordPolicy{
+			RequireLowercase: true,
+			RequireUppercase: true,
+			RequireDigit:     true,
+			RequireSymbol:    true,
+			MinStrength:      3,
+		},
+	}
+	policy := NewPolicy(cfg, "Example Project", 72)
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{
+			name:     "satisfies all rules",
+			password: "Correct-Horse-7-Battery",
+			want:     []string{},
+		},
+		{
+			name:     "too short and too weak",
+			password: "aB3!",
+			want:     []string{ViolationTooShort, ViolationTooWeak},
+		},
+		{
+			name:     "missing character classes",
+			password: "correcthorsebatterystaple",
+			want:     []string{ViolationMissingUppercase, ViolationMissingDigit, ViolationMissingSymbol},
+		},
+		{
+			name:     "contains email",
+			password: "John.Doe-1234-Xyz",
+			want:     []string{ViolationContainsEmail},
+		},
+		{
+			name:     "contains service name",
+			password: "My-Example-Project-9",
+			want:     []string{ViolationContainsServiceName},
+		},
+		{
+			name:     "too long",
+			password: "Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!",
+			want:     []string{ViolationTooLong},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			violations, err := policy.Check(test.password, "john.doe@example.com")
+			require.NoError(t, err)
+			assert.Equal(t, test.want, violationCodes(violations))
+		})
+	}
+}
+
+func TestStrength(t *testing.T) {
+	assert.Equal(t, 0, Strength("aaaaaaaa"))
+	assert.Equal(t, 0, Strength("password"))
+	assert.Less(t, Strength("Password123"), 3)
+	assert.Equal(t, 4, Strength("Correct-Horse-7-Battery"))
+}
@@ -0,0 +1,91 @@
+package password
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// commonPasswords are fragments which are tried first by every password cracker. Passwords containing them are
+// estimated as if the fragment was a single character.
+var commonPasswords = []string{
+	"password", "passwort", "qwerty", "qwertz", "azerty", "letmein", "welcome", "admin", "login", "dragon",
+	"monkey", "iloveyou", "sunshine", "princess", "football", "baseball", "master", "shadow", "secret", "abc123",
+	"123456", "654321", "111111", "000000",
+}
+
+// Strength estimates the strength of a password on a scale from 0 (too guessable) to 4 (very unguessable), similar
+// to the score of zxcvbn. The number of guesses is estimated from the size of the used character classes and the
+// length of the password, after common passwords, repetitions and sequences have been collapsed.
+func Strength(password string) int {
+	guesses := math.Log10(estimateGuesses(password))
+	switch {
+	case guesses < 3:
+		return 0
+	case guesses < 6:
+		return 1
+	case guesses < 8:
+		return 2
+	case guesses < 10:
+		return 3
+	}
+	return 4
+}
+
+func estimateGuesses(password string) float64 {
+	lower := strings.ToLower(password)
+	for _, common := range commonPasswords {
+		lower = strings.ReplaceAll(lower, common, "\x00")
+	}
+
+	runes := []rune(lower)
+	length := 0
+	for i, r := range runes {
+		if i > 0 && (r == runes[i-1] || r == runes[i-1]+1 || r == runes[i-1]-1) {
+			// repeated characters and sequences like "aaa", "abc" or "321" add little entropy
+			continue
+		}
+		length++
+	}
+
+	return math.Pow(float64(charsetSize(password)), float64(length))
+}
+
+func charsetSize(password string) int {
+	var hasLower, hasUpper, hasDigit, hasSymbol, hasOther bool
+	for _, r := range password {
+		switch {
+		case r > unicode.MaxASCII:
+			hasOther = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+
+	size := 0
+	if hasLower {
+		size += 26
+	}
+	if hasUpper {
+		size += 26
+	}
+	if hasDigit {
+		size += 10
+	}
+	if hasSymbol {
+		size += 33
+	}
+	if hasOther {
+		size += 100
+	}
+	if size == 0 {
+		size = 1
+	}
+	return size
+}
@@ -12,6 +12,8 @@ type AccountAccessGrantPersister interface {
 	Get(uuid uuid.UUID) (*models.AccountAccessGrant, error)
 	Create(grant models.AccountAccessGrant) error
 	Update(grant models.AccountAccessGrant) error
+	GetByUserId(userId uuid.UUID) ([]models.AccountAccessGrant, error)
+	GetByClaimedBy(userId uuid.UUID) ([]models.AccountAccessGrant, error)
 }
 
 type accessGrantPersister struct {
@@ -60,3 +62,21 @@ func (p *accessGrantPersister) Update(accessGrant models.AccountAccessGrant) err
 
 	return nil
 }
+
+func (p *accessGrantPersister) GetByUserId(userId uuid.UUID) ([]models.AccountAccessGrant, error) {
+	grants := []models.AccountAccessGrant{}
+	err := p.db.Where("user_id = ?", userId).All(&grants)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve access grants by user id: %w", err)
+	}
+	return grants, nil
+}
+
+func (p *accessGrantPersister) GetByClaimedBy(userId uuid.UUID) ([]models.AccountAccessGrant, error) {
+	grants := []models.AccountAccessGrant{}
+	err := p.db.Where("claimed_by = ?", userId).All(&grants)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve access grants by claiming user id: %w", err)
+	}
+	return grants, nil
+}
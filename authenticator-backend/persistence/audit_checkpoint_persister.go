@@ -0,0 +1,60 @@
+package persistence
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+type AuditCheckpointPersister interface {
+	Create(checkpoint models.AuditCheckpoint) error
+	// GetLatest returns the checkpoint with the highest sequence or nil if there is none
+	GetLatest() (*models.AuditCheckpoint, error)
+	// List returns all checkpoints ordered by the sequence
+	List() ([]models.AuditCheckpoint, error)
+}
+
+type auditCheckpointPersister struct {
+	db *pop.Connection
+}
+
+func NewAuditCheckpointPersister(db *pop.Connection) AuditCheckpointPersister {
+	return &auditCheckpointPersister{db: db}
+}
+
+func (p *auditCheckpointPersister) Create(checkpoint models.AuditCheckpoint) error {
+	vErr, err := p.db.ValidateAndCreate(&checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to store audit checkpoint: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("audit checkpoint object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *auditCheckpointPersister) GetLatest() (*models.AuditCheckpoint, error) {
+	checkpoint := models.AuditCheckpoint{}
+	err := p.db.Order("sequence desc, created_at desc").First(&checkpoint)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit checkpoint: %w", err)
+	}
+	return &checkpoint, nil
+}
+
+func (p *auditCheckpointPersister) List() ([]models.AuditCheckpoint, error) {
+	checkpoints := []models.AuditCheckpoint{}
+	err := p.db.Order("sequence asc, created_at asc").All(&checkpoints)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit checkpoints: %w", err)
+	}
+	return checkpoints, nil
+}
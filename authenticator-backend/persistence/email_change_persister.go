@@ -0,0 +1,84 @@
+package persistence
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+type EmailChangePersister interface {
+	Get(id uuid.UUID) (*models.EmailChange, error)
+	Create(change models.EmailChange) error
+	Update(change models.EmailChange) error
+	Delete(change models.EmailChange) error
+	DeleteByUserId(userId uuid.UUID) error
+}
+
+type emailChangePersister struct {
+	db *pop.Connection
+}
+
+func NewEmailChangePersister(db *pop.Connection) EmailChangePersister {
+	return &emailChangePersister{db: db}
+}
+
+func (p *emailChangePersister) Get(id uuid.UUID) (*models.EmailChange, error) {
+	change := models.EmailChange{}
+	err := p.db.Find(&change, id)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email change: %w", err)
+	}
+
+	return &change, nil
+}
+
+func (p *emailChangePersister) Create(change models.EmailChange) error {
+	vErr, err := p.db.ValidateAndCreate(&change)
+	if err != nil {
+		return fmt.Errorf("failed to store email change: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("email change object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *emailChangePersister) Update(change models.EmailChange) error {
+	vErr, err := p.db.ValidateAndUpdate(&change)
+	if err != nil {
+		return fmt.Errorf("failed to update email change: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("email change object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *emailChangePersister) Delete(change models.EmailChange) error {
+	err := p.db.Destroy(&change)
+	if err != nil {
+		return fmt.Errorf("failed to delete email change: %w", err)
+	}
+
+	return nil
+}
+
+func (p *emailChangePersister) DeleteByUserId(userId uuid.UUID) error {
+	err := p.db.RawQuery("DELETE FROM email_changes WHERE user_id = ?", userId).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to delete email changes: %w", err)
+	}
+
+	return nil
+}
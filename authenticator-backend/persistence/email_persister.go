@@ -0,0 +1,115 @@
+package persistence
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+type EmailPersister interface {
+	Get(id uuid.UUID) (*models.Email, error)
+	GetByAddress(address string) (*models.Email, error)
+	FindByUserId(userId uuid.UUID) ([]models.Email, error)
+	Create(email models.Email) error
+	Update(email models.Email) error
+	Delete(email models.Email) error
+	// DeleteUnverified deletes the unverified secondary addresses with the given address, which were added before the
+	// given time
+	DeleteUnverified(address string, createdBefore time.Time) error
+}
+
+type emailPersister struct {
+	db *pop.Connection
+}
+
+func NewEmailPersister(db *pop.Connection) EmailPersister {
+	return &emailPersister{db: db}
+}
+
+func (p *emailPersister) Get(id uuid.UUID) (*models.Email, error) {
+	email := models.Email{}
+	err := p.db.Find(&email, id)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email: %w", err)
+	}
+
+	return &email, nil
+}
+
+func (p *emailPersister) GetByAddress(address string) (*models.Email, error) {
+	email := models.Email{}
+	err := p.db.Where("address = ?", address).First(&email)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email: %w", err)
+	}
+
+	return &email, nil
+}
+
+func (p *emailPersister) FindByUserId(userId uuid.UUID) ([]models.Email, error) {
+	var emails []models.Email
+	err := p.db.Where("user_id = ?", userId).Order("created_at asc").All(&emails)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return emails, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch emails: %w", err)
+	}
+
+	return emails, nil
+}
+
+func (p *emailPersister) Create(email models.Email) error {
+	vErr, err := p.db.ValidateAndCreate(&email)
+	if err != nil {
+		return fmt.Errorf("failed to store email: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("email object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *emailPersister) Update(email models.Email) error {
+	vErr, err := p.db.ValidateAndUpdate(&email)
+	if err != nil {
+		return fmt.Errorf("failed to update email: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("email object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *emailPersister) Delete(email models.Email) error {
+	err := p.db.Destroy(&email)
+	if err != nil {
+		return fmt.Errorf("failed to delete email: %w", err)
+	}
+
+	return nil
+}
+
+func (p *emailPersister) DeleteUnverified(address string, createdBefore time.Time) error {
+	err := p.db.RawQuery("DELETE FROM emails WHERE address = ? AND verified = ? AND is_primary = ? AND created_at < ?", address, false, false, createdBefore).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to delete unverified emails: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package persistence
+
+import (
+	"fmt"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+type GuestActivityPersister interface {
+	// Create adds the activity, it should be called in the transaction of the action
+	Create(activity models.GuestActivity) error
+	// ListByRelation returns a page of the activities under the guest relation, newest first, and the total number of
+	// activities
+	ListByRelation(relationId uuid.UUID, page int, perPage int) ([]models.GuestActivity, int, error)
+	// PseudonymiseSurrogate replaces the user id with the pseudonym in the activities of the user as guest of other
+	// accounts
+	PseudonymiseSurrogate(userId uuid.UUID, pseudonym uuid.UUID) error
+}
+
+type guestActivityPersister struct {
+	db *pop.Connection
+}
+
+func NewGuestActivityPersister(db *pop.Connection) GuestActivityPersister {
+	return &guestActivityPersister{db: db}
+}
+
+func (p *guestActivityPersister) Create(activity models.GuestActivity) error {
+	vErr, err := p.db.ValidateAndCreate(&activity)
+	if err != nil {
+		return fmt.Errorf("failed to store guest activity: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("guest activity object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *guestActivityPersister) ListByRelation(relationId uuid.UUID, page int, perPage int) ([]models.GuestActivity, int, error) {
+	activities := []models.GuestActivity{}
+	query := p.db.Where("user_guest_relation_id = ?", relationId).Order("created_at desc, id desc").Paginate(page, perPage)
+	err := query.All(&activities)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch guest activities: %w", err)
+	}
+
+	return activities, query.Paginator.TotalEntriesSize, nil
+}
+
+func (p *guestActivityPersister) PseudonymiseSurrogate(userId uuid.UUID, pseudonym uuid.UUID) error {
+	err := p.db.RawQuery("UPDATE guest_activities SET surrogate_user_id = ? WHERE surrogate_user_id = ?", pseudonym.String(), userId.String()).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to pseudonymise guest activities: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package persistence
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+type GuestLoginNotificationPersister interface {
+	// Create adds the notification, it must be called in the transaction of the login
+	Create(notification models.GuestLoginNotification) error
+	// ListSingle returns up to limit notifications which are not part of a digest, oldest first
+	ListSingle(limit int) ([]models.GuestLoginNotification, error)
+	// ListDigestRecipients returns up to limit account holders with a notification for the digest about a login before
+	// the given time
+	ListDigestRecipients(loginBefore time.Time, limit int) ([]uuid.UUID, error)
+	// ListDigest returns the notifications of the account holder for the digest, oldest first
+	ListDigest(userId uuid.UUID) ([]models.GuestLoginNotification, error)
+	// Claim removes the notification before it is sent. It returns false, if the notification was claimed already.
+	Claim(id uuid.UUID) (bool, error)
+}
+
+type guestLoginNotificationPersister struct {
+	db *pop.Connection
+}
+
+func NewGuestLoginNotificationPersister(db *pop.Connection) GuestLoginNotificationPersister {
+	return &guestLoginNotificationPersister{db: db}
+}
+
+func (p *guestLoginNotificationPersister) Create(notification models.GuestLoginNotification) error {
+	vErr, err := p.db.ValidateAndCreate(&notification)
+	if err != nil {
+		return fmt.Errorf("failed to store guest login notification: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("guest login notification object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *guestLoginNotificationPersister) ListSingle(limit int) ([]models.GuestLoginNotification, error) {
+	notifications := []models.GuestLoginNotification{}
+	err := p.db.Where("digest = ?", false).Order("login_at asc").Limit(limit).All(&notifications)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list guest login notifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
+func (p *guestLoginNotificationPersister) ListDigestRecipients(loginBefore time.Time, limit int) ([]uuid.UUID, error) {
+	var recipients []struct {
+		UserId uuid.UUID `db:"user_id"`
+	}
+	err := p.db.RawQuery("SELECT DISTINCT user_id FROM guest_login_notifications WHERE digest = ? AND login_at < ? LIMIT ?", true, loginBefore, limit).All(&recipients)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list guest login digest recipients: %w", err)
+	}
+
+	userIds := make([]uuid.UUID, len(recipients))
+	for i, recipient := range recipients {
+		userIds[i] = recipient.UserId
+	}
+	return userIds, nil
+}
+
+func (p *guestLoginNotificationPersister) ListDigest(userId uuid.UUID) ([]models.GuestLoginNotification, error) {
+	notifications := []models.GuestLoginNotification{}
+	err := p.db.Where("user_id = ? AND digest = ?", userId, true).Order("login_at asc").All(&notifications)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list guest login digest: %w", err)
+	}
+
+	return notifications, nil
+}
+
+func (p *guestLoginNotificationPersister) Claim(id uuid.UUID) (bool, error) {
+	count, err := p.db.RawQuery("DELETE FROM guest_login_notifications WHERE id = ?", id).ExecWithCount()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim guest login notification: %w", err)
+	}
+
+	return count > 0, nil
+}
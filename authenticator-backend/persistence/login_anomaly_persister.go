@@ -0,0 +1,115 @@
+package persistence
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+type LoginAnomalyPersister interface {
+	// LockCursor returns the cursor in the login audit log chain and locks it until the end of the transaction, so
+	// that logins are compared with the history only once. It must be called in a transaction.
+	LockCursor() (*models.LoginAnomalyCursor, error)
+	UpdateCursor(cursor models.LoginAnomalyCursor) error
+
+	Create(anomaly models.LoginAnomaly) error
+	Get(id uuid.UUID) (*models.LoginAnomaly, error)
+	Update(anomaly models.LoginAnomaly) error
+	// ListUnnotified returns up to limit anomalies whose account holder was not notified yet, oldest first
+	ListUnnotified(limit int) ([]models.LoginAnomaly, error)
+	// ClaimNotification marks the anomaly as notified. It returns false, if the anomaly was claimed already.
+	ClaimNotification(id uuid.UUID, notifiedAt time.Time) (bool, error)
+}
+
+type loginAnomalyPersister struct {
+	db *pop.Connection
+}
+
+func NewLoginAnomalyPersister(db *pop.Connection) LoginAnomalyPersister {
+	return &loginAnomalyPersister{db: db}
+}
+
+func (p *loginAnomalyPersister) LockCursor() (*models.LoginAnomalyCursor, error) {
+	cursor := models.LoginAnomalyCursor{}
+	err := p.db.RawQuery("SELECT * FROM login_anomaly_cursors WHERE id = ? FOR UPDATE", models.LoginAnomalyCursorLoginAuditLogs).First(&cursor)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock the login anomaly cursor: %w", err)
+	}
+
+	return &cursor, nil
+}
+
+func (p *loginAnomalyPersister) UpdateCursor(cursor models.LoginAnomalyCursor) error {
+	err := p.db.RawQuery("UPDATE login_anomaly_cursors SET sequence = ?, updated_at = ? WHERE id = ?", cursor.Sequence, cursor.UpdatedAt, cursor.ID).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to update the login anomaly cursor: %w", err)
+	}
+
+	return nil
+}
+
+func (p *loginAnomalyPersister) Create(anomaly models.LoginAnomaly) error {
+	vErr, err := p.db.ValidateAndCreate(&anomaly)
+	if err != nil {
+		return fmt.Errorf("failed to store login anomaly: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("login anomaly object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *loginAnomalyPersister) Get(id uuid.UUID) (*models.LoginAnomaly, error) {
+	anomaly := models.LoginAnomaly{}
+	err := p.db.Find(&anomaly, id)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login anomaly: %w", err)
+	}
+
+	return &anomaly, nil
+}
+
+func (p *loginAnomalyPersister) Update(anomaly models.LoginAnomaly) error {
+	vErr, err := p.db.ValidateAndUpdate(&anomaly)
+	if err != nil {
+		return fmt.Errorf("failed to update login anomaly: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("login anomaly object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *loginAnomalyPersister) ListUnnotified(limit int) ([]models.LoginAnomaly, error) {
+	anomalies := []models.LoginAnomaly{}
+	err := p.db.Where("notified_at IS NULL").Order("created_at asc").Limit(limit).All(&anomalies)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unnotified login anomalies: %w", err)
+	}
+
+	return anomalies, nil
+}
+
+func (p *loginAnomalyPersister) ClaimNotification(id uuid.UUID, notifiedAt time.Time) (bool, error) {
+	count, err := p.db.RawQuery("UPDATE login_anomalies SET notified_at = ?, updated_at = ? WHERE id = ? AND notified_at IS NULL", notifiedAt, notifiedAt, id).ExecWithCount()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim login anomaly notification: %w", err)
+	}
+
+	return count > 0, nil
+}
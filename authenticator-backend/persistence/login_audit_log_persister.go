@@ -1,6 +1,8 @@
 package persistence
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/gobuffalo/pop/v6"
 	"github.com/gofrs/uuid"
@@ -13,6 +15,19 @@ type LoginAuditLogPersister interface {
 	GetByPrimaryUserId(uuid uuid.UUID) ([]models.LoginAuditLog, error)
 	GetByGuestUserId(uuid uuid.UUID) ([]models.LoginAuditLog, error)
 	GetByGuestUserIdAndGrantId(guestUserId uuid.UUID, grantId uuid.UUID) ([]models.LoginAuditLog, error)
+	// Pseudonymise replaces the user id with the pseudonym in all logs of the user, as account holder and as guest,
+	// and removes the client information
+	Pseudonymise(userId uuid.UUID, pseudonym uuid.UUID) error
+	// ListChained returns up to limit logs of the hash chain with a sequence greater than afterSequence, ordered by
+	// the sequence
+	ListChained(afterSequence int, limit int) ([]models.LoginAuditLog, error)
+	// CountUnchained returns the number of logs created before the introduction of the hash chain
+	CountUnchained() (int, error)
+	GetChainHead() (*models.AuditChainHead, error)
+	// ListHistory returns up to limit logs of the chain with a sequence lower than beforeSequence, newest first. The
+	// logs are the logins of the account holder, if surrogateUserId is nil, otherwise the logins of the guest to the
+	// account of the user.
+	ListHistory(userId uuid.UUID, surrogateUserId *uuid.UUID, beforeSequence int, limit int) ([]models.LoginAuditLog, error)
 }
 
 type loginAuditLogPersister struct {
@@ -23,14 +38,35 @@ func NewLoginAuditLogPersister(db *pop.Connection) LoginAuditLogPersister {
 	return &loginAuditLogPersister{db: db}
 }
 
+// Create appends the log to the hash chain. The head of the chain is locked until the log is stored, so the logs are
+// linked in the order they are created.
 func (p *loginAuditLogPersister) Create(log models.LoginAuditLog) error {
 	if log.ID == uuid.Nil {
 		uuId, _ := uuid.NewV4()
 		log.ID = uuId
 	}
-	log.CreatedAt = time.Now().UTC()
-	log.UpdatedAt = time.Now().UTC()
-	vErr, err := p.db.ValidateAndCreate(&log)
+	// the hash covers whole seconds only, not all databases store fractions of seconds
+	now := time.Now().UTC().Truncate(time.Second)
+	log.CreatedAt = now
+	log.UpdatedAt = now
+
+	if p.db.TX != nil {
+		return p.createLinked(p.db, log)
+	}
+	return p.db.Transaction(func(tx *pop.Connection) error {
+		return p.createLinked(tx, log)
+	})
+}
+
+func (p *loginAuditLogPersister) createLinked(tx *pop.Connection, log models.LoginAuditLog) error {
+	head := models.AuditChainHead{}
+	err := tx.RawQuery("SELECT * FROM audit_chain_heads WHERE id = ? FOR UPDATE", models.AuditChainHeadLoginAuditLogs).First(&head)
+	if err != nil {
+		return fmt.Errorf("failed to lock the head of the login audit chain: %w", err)
+	}
+
+	log.Link(head.Sequence, head.Hash)
+	vErr, err := tx.ValidateAndCreate(&log)
 	if err != nil {
 		return fmt.Errorf("failed to store user audit login: %w", err)
 	}
@@ -39,6 +75,11 @@ func (p *loginAuditLogPersister) Create(log models.LoginAuditLog) error {
 		return fmt.Errorf("accessGrant object validation failed: %w", vErr)
 	}
 
+	err = tx.RawQuery("UPDATE audit_chain_heads SET sequence = ?, hash = ?, updated_at = ? WHERE id = ?", log.Sequence, log.Hash, log.UpdatedAt, head.ID).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to update the head of the login audit chain: %w", err)
+	}
+
 	return nil
 }
 
@@ -71,3 +112,59 @@ func (p *loginAuditLogPersister) GetByGuestUserIdAndGrantId(guestUserId uuid.UUI
 	}
 	return models, nil
 }
+
+func (p *loginAuditLogPersister) Pseudonymise(userId uuid.UUID, pseudonym uuid.UUID) error {
+	err := p.db.RawQuery("UPDATE login_audit_logs SET user_id = ?, client_ip_address = ?, client_user_agent = ? WHERE user_id = ?", pseudonym, models.Redacted, models.Redacted, userId).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to pseudonymise login audits by primary user id: %w", err)
+	}
+	err = p.db.RawQuery("UPDATE login_audit_logs SET surrogate_user_id = ?, client_ip_address = ?, client_user_agent = ? WHERE surrogate_user_id = ?", pseudonym, models.Redacted, models.Redacted, userId).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to pseudonymise login audits by guest user id: %w", err)
+	}
+	return nil
+}
+
+func (p *loginAuditLogPersister) ListChained(afterSequence int, limit int) ([]models.LoginAuditLog, error) {
+	logs := []models.LoginAuditLog{}
+	err := p.db.Where("sequence > ?", afterSequence).Order("sequence asc").Limit(limit).All(&logs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve chained login audits: %w", err)
+	}
+	return logs, nil
+}
+
+func (p *loginAuditLogPersister) CountUnchained() (int, error) {
+	count, err := p.db.Where("sequence = 0").Count(&models.LoginAuditLog{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unchained login audits: %w", err)
+	}
+	return count, nil
+}
+
+func (p *loginAuditLogPersister) GetChainHead() (*models.AuditChainHead, error) {
+	head := models.AuditChainHead{}
+	err := p.db.Find(&head, models.AuditChainHeadLoginAuditLogs)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the head of the login audit chain: %w", err)
+	}
+	return &head, nil
+}
+
+func (p *loginAuditLogPersister) ListHistory(userId uuid.UUID, surrogateUserId *uuid.UUID, beforeSequence int, limit int) ([]models.LoginAuditLog, error) {
+	logs := []models.LoginAuditLog{}
+	query := p.db.Where("user_id = ? AND sequence > 0 AND sequence < ?", userId, beforeSequence)
+	if surrogateUserId == nil {
+		query = query.Where("surrogate_user_id IS NULL")
+	} else {
+		query = query.Where("surrogate_user_id = ?", *surrogateUserId)
+	}
+	err := query.Order("sequence desc").Limit(limit).All(&logs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve login history: %w", err)
+	}
+	return logs, nil
+}
@@ -24,6 +24,10 @@ type AccountAccessGrant struct {
 	LoginsAllowed       sql.NullInt32 `db:"logins_allowed"`
 	ExpireByTime        bool          `db:"expire_by_time"`
 	MinutesAllowed      sql.NullInt32 `db:"minutes_allowed"`
+	// Email is the invited address, only a guest with this address as verified address can claim the grant
+	Email *string `db:"email"`
+	// Scopes is a comma separated list of the scopes the relation gets when the grant is claimed
+	Scopes string `db:"scopes"`
 }
 
 // Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
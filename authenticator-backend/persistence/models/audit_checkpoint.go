@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// AuditChainHeadLoginAuditLogs is the ID of the head of the login audit log chain
+const AuditChainHeadLoginAuditLogs = "login_audit_logs"
+
+// AuditChainHead is the sequence and the hash of the last log of a hash chain. New logs are linked to the head while
+// its row is locked, so that concurrent logins can't fork the chain.
+type AuditChainHead struct {
+	ID        string    `db:"id" json:"id"`
+	Sequence  int       `db:"sequence" json:"sequence"`
+	Hash      string    `db:"hash" json:"hash"`
+	CreatedAt time.Time `db:"created_at" json:"-"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// AuditCheckpoint is a signed head of the login audit log chain. Signature is a JWS with the sequence, the hash and the
+// time of the checkpoint as payload, signed with the JWT signing key.
+type AuditCheckpoint struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	Sequence  int       `db:"sequence" json:"sequence"`
+	Hash      string    `db:"hash" json:"hash"`
+	Signature string    `db:"signature" json:"signature"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"-"`
+}
+
+func (checkpoint *AuditCheckpoint) Validate(_ *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Name: "ID", Field: checkpoint.ID},
+		&validators.IntIsGreaterThan{Name: "Sequence", Field: checkpoint.Sequence, Compared: 0},
+		&validators.StringIsPresent{Name: "Hash", Field: checkpoint.Hash},
+		&validators.StringIsPresent{Name: "Signature", Field: checkpoint.Signature},
+		&validators.TimeIsPresent{Name: "CreatedAt", Field: checkpoint.CreatedAt},
+		&validators.TimeIsPresent{Name: "UpdatedAt", Field: checkpoint.UpdatedAt},
+	), nil
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// Email is an email address of a user. Every user has exactly one primary address, which is also kept in the email
+// column of the users table.
+type Email struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	UserId    uuid.UUID `db:"user_id" json:"-"`
+	Address   string    `db:"address" json:"address"`
+	Verified  bool      `db:"verified" json:"verified"`
+	Primary   bool      `db:"is_primary" json:"is_primary"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+func NewEmail(userId uuid.UUID, address string) Email {
+	id, _ := uuid.NewV4()
+	now := time.Now().UTC()
+	return Email{
+		ID:        id,
+		UserId:    userId,
+		Address:   address,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+func (email *Email) Validate(_ *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Name: "ID", Field: email.ID},
+		&validators.UUIDIsPresent{Name: "UserId", Field: email.UserId},
+		&validators.EmailLike{Name: "Address", Field: email.Address},
+		&validators.TimeIsPresent{Name: "CreatedAt", Field: email.CreatedAt},
+		&validators.TimeIsPresent{Name: "UpdatedAt", Field: email.UpdatedAt},
+	), nil
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// EmailChange is a pending change of the email address of a user. The address is only changed after the passcode or
+// link sent to the new address was verified.
+type EmailChange struct {
+	ID     uuid.UUID `db:"id"`
+	UserId uuid.UUID `db:"user_id"`
+	// Email is the new email address
+	Email string `db:"email"`
+	Ttl   int    `db:"ttl"` // in seconds
+	Code  string `db:"code"`
+	// Token is the hashed token of the link, changes confirmed by link have no code
+	Token     *string   `db:"token"`
+	TryCount  int       `db:"try_count"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func (change *EmailChange) Validate(_ *pop.Connection) (*validate.Errors, error) {
+	secret := validate.Validator(&validators.StringLengthInRange{Name: "Code", Field: change.Code, Min: 6})
+	if change.Token != nil {
+		secret = &validators.StringIsPresent{Name: "Token", Field: *change.Token}
+	}
+	return validate.Validate(
+		&validators.UUIDIsPresent{Name: "ID", Field: change.ID},
+		&validators.UUIDIsPresent{Name: "UserId", Field: change.UserId},
+		&validators.EmailIsPresent{Name: "Email", Field: change.Email},
+		&validators.IntIsGreaterThan{Name: "Ttl", Field: change.Ttl, Compared: 0},
+		secret,
+		&validators.TimeIsPresent{Name: "CreatedAt", Field: change.CreatedAt},
+		&validators.TimeIsPresent{Name: "UpdatedAt", Field: change.UpdatedAt},
+	), nil
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/actor"
+)
+
+// The actions of a guest on behalf of the account holder
+const (
+	GuestActivityLogin   = "login"
+	GuestActivityLogout  = "logout"
+	GuestActivityCreated = "created"
+	GuestActivityUpdated = "updated"
+	GuestActivityDeleted = "deleted"
+)
+
+// The types of the resources a guest acts on
+const (
+	GuestActivityResourceRelation = "relation"
+	GuestActivityResourcePost     = "post"
+)
+
+// GuestActivity records what a guest did on behalf of the account holder. The activities are reported to the account
+// holder per guest relation.
+type GuestActivity struct {
+	ID                  uuid.UUID `db:"id" json:"id"`
+	UserGuestRelationId uuid.UUID `db:"user_guest_relation_id" json:"relation_id"`
+	// UserId is the account holder
+	UserId          uuid.UUID `db:"user_id" json:"user_id"`
+	SurrogateUserId uuid.UUID `db:"surrogate_user_id" json:"surrogate_user_id"`
+	Action          string    `db:"action" json:"action"`
+	ResourceType    string    `db:"resource_type" json:"resource_type"`
+	ResourceId      uuid.UUID `db:"resource_id" json:"resource_id"`
+	CreatedAt       time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt       time.Time `db:"updated_at" json:"-"`
+}
+
+// NewGuestActivity returns the activity of the guest, or nil if the actor is the account holder
+func NewGuestActivity(a actor.Actor, action string, resourceType string, resourceId uuid.UUID) *GuestActivity {
+	if !a.IsGuest() {
+		return nil
+	}
+
+	id, _ := uuid.NewV4()
+	now := time.Now().UTC()
+	return &GuestActivity{
+		ID:                  id,
+		UserGuestRelationId: *a.RelationId,
+		UserId:              a.PrincipalId,
+		SurrogateUserId:     a.SurrogateId,
+		Action:              action,
+		ResourceType:        resourceType,
+		ResourceId:          resourceId,
+		CreatedAt:           now,
+		UpdatedAt:           now,
+	}
+}
+
+func (activity *GuestActivity) Validate(_ *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Name: "ID", Field: activity.ID},
+		&validators.UUIDIsPresent{Name: "UserGuestRelationId", Field: activity.UserGuestRelationId},
+		&validators.UUIDIsPresent{Name: "UserId", Field: activity.UserId},
+		&validators.UUIDIsPresent{Name: "SurrogateUserId", Field: activity.SurrogateUserId},
+		&validators.StringIsPresent{Name: "Action", Field: activity.Action},
+		&validators.StringIsPresent{Name: "ResourceType", Field: activity.ResourceType},
+		&validators.UUIDIsPresent{Name: "ResourceId", Field: activity.ResourceId},
+		&validators.TimeIsPresent{Name: "CreatedAt", Field: activity.CreatedAt},
+		&validators.TimeIsPresent{Name: "UpdatedAt", Field: activity.UpdatedAt},
+	), nil
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// GuestLoginNotification is a pending notification of the account holder about a login of a guest. It is created in
+// the transaction of the login and removed when it is sent. Notifications which are part of a digest are sent together
+// once a day.
+type GuestLoginNotification struct {
+	ID                  uuid.UUID `db:"id"`
+	UserGuestRelationId uuid.UUID `db:"user_guest_relation_id"`
+	// UserId is the account holder
+	UserId          uuid.UUID `db:"user_id"`
+	GuestUserId     uuid.UUID `db:"guest_user_id"`
+	ClientIpAddress string    `db:"client_ip_address"`
+	ClientUserAgent string    `db:"client_user_agent"`
+	LoginAt         time.Time `db:"login_at"`
+	// LoginsRemaining after the login, if the relation expires by logins
+	LoginsRemaining *int `db:"logins_remaining"`
+	// ExpiresAt is the end of the relation, if it expires by time
+	ExpiresAt *time.Time `db:"expires_at"`
+	Digest    bool       `db:"digest"`
+	CreatedAt time.Time  `db:"created_at"`
+	UpdatedAt time.Time  `db:"updated_at"`
+}
+
+func (notification *GuestLoginNotification) Validate(_ *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Name: "ID", Field: notification.ID},
+		&validators.UUIDIsPresent{Name: "UserGuestRelationId", Field: notification.UserGuestRelationId},
+		&validators.UUIDIsPresent{Name: "UserId", Field: notification.UserId},
+		&validators.UUIDIsPresent{Name: "GuestUserId", Field: notification.GuestUserId},
+		&validators.TimeIsPresent{Name: "LoginAt", Field: notification.LoginAt},
+		&validators.TimeIsPresent{Name: "CreatedAt", Field: notification.CreatedAt},
+		&validators.TimeIsPresent{Name: "UpdatedAt", Field: notification.UpdatedAt},
+	), nil
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// The reasons why a login is unusual
+const (
+	LoginAnomalyNewDevice        = "new_device"
+	LoginAnomalyNewIpRange       = "new_ip_range"
+	LoginAnomalyImpossibleTravel = "impossible_travel"
+	LoginAnomalyUnusualHour      = "unusual_hour"
+)
+
+// LoginAnomalyCursorLoginAuditLogs is the ID of the cursor in the login audit log chain
+const LoginAnomalyCursorLoginAuditLogs = "login_audit_logs"
+
+// LoginAnomalyCursor is the sequence of the last login audit log which was compared with the login history
+type LoginAnomalyCursor struct {
+	ID        string    `db:"id"`
+	Sequence  int       `db:"sequence"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// LoginAnomaly is an unusual login. The account holder is notified once, and can revoke the sessions, or the guest
+// relation in case of a guest login, with the link in the notification.
+type LoginAnomaly struct {
+	ID                  uuid.UUID  `db:"id" json:"id"`
+	LoginAuditLogId     uuid.UUID  `db:"login_audit_log_id" json:"login_audit_log_id"`
+	UserId              uuid.UUID  `db:"user_id" json:"user_id"`
+	SurrogateUserId     *uuid.UUID `db:"surrogate_user_id" json:"surrogate_user_id,omitempty"`
+	UserGuestRelationId *uuid.UUID `db:"user_guest_relation_id" json:"user_guest_relation_id,omitempty"`
+	// Reasons is the comma separated list of the reasons why the login is unusual
+	Reasons         string     `db:"reasons" json:"-"`
+	ClientIpAddress string     `db:"client_ip_address" json:"client_ip_address"`
+	ClientUserAgent string     `db:"client_user_agent" json:"client_user_agent"`
+	LoginAt         time.Time  `db:"login_at" json:"login_at"`
+	NotifiedAt      *time.Time `db:"notified_at" json:"notified_at,omitempty"`
+	RevokedAt       *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt       time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// NewLoginAnomaly returns an anomaly of the login with the given reasons
+func NewLoginAnomaly(log LoginAuditLog, reasons []string, now time.Time) LoginAnomaly {
+	id, _ := uuid.NewV4()
+	return LoginAnomaly{
+		ID:                  id,
+		LoginAuditLogId:     log.ID,
+		UserId:              log.UserId,
+		SurrogateUserId:     log.SurrogateUserId,
+		UserGuestRelationId: log.UserGuestRelationId,
+		Reasons:             strings.Join(reasons, ","),
+		ClientIpAddress:     log.ClientIpAddress,
+		ClientUserAgent:     log.ClientUserAgent,
+		LoginAt:             log.CreatedAt,
+		CreatedAt:           now,
+		UpdatedAt:           now,
+	}
+}
+
+func (anomaly *LoginAnomaly) ReasonList() []string {
+	if anomaly.Reasons == "" {
+		return []string{}
+	}
+	return strings.Split(anomaly.Reasons, ",")
+}
+
+// IsGuestLogin returns whether a guest logged in to the account of the user
+func (anomaly *LoginAnomaly) IsGuestLogin() bool {
+	return anomaly.UserGuestRelationId != nil
+}
+
+func (anomaly *LoginAnomaly) Validate(_ *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Name: "ID", Field: anomaly.ID},
+		&validators.UUIDIsPresent{Name: "LoginAuditLogId", Field: anomaly.LoginAuditLogId},
+		&validators.UUIDIsPresent{Name: "UserId", Field: anomaly.UserId},
+		&validators.StringIsPresent{Name: "Reasons", Field: anomaly.Reasons},
+		&validators.TimeIsPresent{Name: "LoginAt", Field: anomaly.LoginAt},
+		&validators.TimeIsPresent{Name: "CreatedAt", Field: anomaly.CreatedAt},
+		&validators.TimeIsPresent{Name: "UpdatedAt", Field: anomaly.UpdatedAt},
+	), nil
+}
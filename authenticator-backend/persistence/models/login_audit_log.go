@@ -1,6 +1,10 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
 	"time"
 
 	"github.com/gobuffalo/pop/v6"
@@ -9,16 +13,74 @@ import (
 	"github.com/gofrs/uuid"
 )
 
+// Redacted replaces the client information of login audits of deleted users
+const Redacted = "redacted"
+
+// LoginAuditLog records a login. Logs created since the introduction of the hash chain are linked to their predecessor:
+// Hash covers the content of the log and the hash of the previous log, so changing or removing a log breaks the chain.
+// The personal data is only covered through PersonalDigest, so that the logs of deleted users can be pseudonymised
+// without breaking the chain. Logs created before have the Sequence 0 and no hashes.
 type LoginAuditLog struct {
-	ID                  uuid.UUID  `db:"id"`
-	UserId              uuid.UUID  `db:"user_id"`
-	SurrogateUserId     *uuid.UUID `db:"surrogate_user_id"`
-	UserGuestRelationId *uuid.UUID `db:"user_guest_relation_id"`
-	ClientIpAddress     string     `db:"client_ip_address"`
-	ClientUserAgent     string     `db:"client_user_agent"`
-	CreatedAt           time.Time  `db:"created_at"`
-	UpdatedAt           time.Time  `db:"updated_at"`
-	LoginMethod         int        `db:"login_method"`
+	ID                  uuid.UUID  `db:"id" json:"id"`
+	UserId              uuid.UUID  `db:"user_id" json:"user_id"`
+	SurrogateUserId     *uuid.UUID `db:"surrogate_user_id" json:"surrogate_user_id,omitempty"`
+	UserGuestRelationId *uuid.UUID `db:"user_guest_relation_id" json:"user_guest_relation_id,omitempty"`
+	ClientIpAddress     string     `db:"client_ip_address" json:"client_ip_address"`
+	ClientUserAgent     string     `db:"client_user_agent" json:"client_user_agent"`
+	CreatedAt           time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt           time.Time  `db:"updated_at" json:"updated_at"`
+	LoginMethod         int        `db:"login_method" json:"login_method"`
+	Sequence            int        `db:"sequence" json:"sequence"`
+	PreviousHash        string     `db:"previous_hash" json:"previous_hash"`
+	PersonalDigest      string     `db:"personal_digest" json:"personal_digest"`
+	Hash                string     `db:"hash" json:"hash"`
+}
+
+// Link appends the log to the chain whose last log has the given sequence and hash. The sequence and the hash of an
+// empty chain are 0 and "".
+func (log *LoginAuditLog) Link(previousSequence int, previousHash string) {
+	log.Sequence = previousSequence + 1
+	log.PreviousHash = previousHash
+	log.PersonalDigest = log.ComputePersonalDigest()
+	log.Hash = log.ComputeHash()
+}
+
+// ComputePersonalDigest returns the digest of the personal data of the log
+func (log *LoginAuditLog) ComputePersonalDigest() string {
+	surrogateUserId := ""
+	if log.SurrogateUserId != nil {
+		surrogateUserId = log.SurrogateUserId.String()
+	}
+	return digest(log.UserId.String(), surrogateUserId, log.ClientIpAddress, log.ClientUserAgent)
+}
+
+// ComputeHash returns the hash of the log. It covers the stored PersonalDigest instead of the personal data itself.
+func (log *LoginAuditLog) ComputeHash() string {
+	relationId := ""
+	if log.UserGuestRelationId != nil {
+		relationId = log.UserGuestRelationId.String()
+	}
+	return digest(
+		log.PreviousHash,
+		strconv.Itoa(log.Sequence),
+		log.ID.String(),
+		relationId,
+		strconv.Itoa(log.LoginMethod),
+		log.CreatedAt.UTC().Format(time.RFC3339),
+		log.PersonalDigest,
+	)
+}
+
+// IsPseudonymised returns whether the personal data of the log was replaced after the deletion of the user
+func (log *LoginAuditLog) IsPseudonymised() bool {
+	return log.ClientIpAddress == Redacted && log.ClientUserAgent == Redacted
+}
+
+// digest returns the hex encoded SHA-256 hash of the JSON encoded values, the encoding keeps the values apart
+func digest(values ...string) string {
+	encoded, _ := json.Marshal(values)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
 }
 
 func (log *LoginAuditLog) Validate(_ *pop.Connection) (*validate.Errors, error) {
@@ -18,14 +18,24 @@ type Passcode struct {
 	TryCount  int       `db:"try_count"`
 	CreatedAt time.Time `db:"created_at"`
 	UpdatedAt time.Time `db:"updated_at"`
+	// Token is the hashed token of a magic link, passcodes sent as link have no code
+	Token *string `db:"token"`
+	// DeviceBinding is the hashed value of the cookie set in the browser which requested the magic link
+	DeviceBinding *string `db:"device_binding"`
+	// Email is the address the passcode was sent to, nil means the primary address of the user
+	Email *string `db:"email"`
 }
 
 // Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
 func (passcode *Passcode) Validate(_ *pop.Connection) (*validate.Errors, error) {
+	secret := validate.Validator(&validators.StringLengthInRange{Name: "Code", Field: passcode.Code, Min: 6})
+	if passcode.Token != nil {
+		secret = &validators.StringIsPresent{Name: "Token", Field: *passcode.Token}
+	}
 	return validate.Validate(
 		&validators.UUIDIsPresent{Name: "ID", Field: passcode.ID},
 		&validators.UUIDIsPresent{Name: "UserID", Field: passcode.UserId},
-		&validators.StringLengthInRange{Name: "Code", Field: passcode.Code, Min: 6},
+		secret,
 		&validators.TimeIsPresent{Name: "CreatedAt", Field: passcode.CreatedAt},
 		&validators.TimeIsPresent{Name: "UpdatedAt", Field: passcode.UpdatedAt},
 	), nil
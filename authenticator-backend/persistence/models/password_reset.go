@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// PasswordReset is a pending password reset. Its ID is part of the signed token sent by email, deleting the reset
+// invalidates the token.
+type PasswordReset struct {
+	ID        uuid.UUID `db:"id"`
+	UserId    uuid.UUID `db:"user_id"`
+	Ttl       int       `db:"ttl"` // in seconds
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func (reset *PasswordReset) Validate(_ *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Name: "ID", Field: reset.ID},
+		&validators.UUIDIsPresent{Name: "UserId", Field: reset.UserId},
+		&validators.IntIsGreaterThan{Name: "Ttl", Field: reset.Ttl, Compared: 0},
+		&validators.TimeIsPresent{Name: "CreatedAt", Field: reset.CreatedAt},
+		&validators.TimeIsPresent{Name: "UpdatedAt", Field: reset.UpdatedAt},
+	), nil
+}
@@ -7,8 +7,16 @@ import (
 	"github.com/gobuffalo/validate/v3"
 	"github.com/gobuffalo/validate/v3/validators"
 	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/resource"
 )
 
+// PostType is the resource type of the posts
+var PostType = resource.Register(resource.Type{
+	Name:       GuestActivityResourcePost,
+	ReadScope:  ScopePostsRead,
+	WriteScope: ScopePostsWrite,
+})
+
 type Post struct {
 	ID                   uuid.UUID `db:"id" json:"id"`
 	CreatedAt            time.Time `db:"created_at" json:"created_at"`
@@ -19,12 +27,16 @@ type Post struct {
 	UpdatedBySurrogateId uuid.UUID `db:"updated_by_surrogate_id" json:"updated_by_surrogate_id"`
 	Data                 string    `db:"data" json:"data"`
 	IsActive             bool      `db:"is_active" json:"is_active"`
+	Visibility           string    `db:"visibility" json:"visibility"`
+	// Version is counted up with every change, the previous versions are kept as PostHistory
+	Version int `db:"version" json:"version"`
 }
 
 func (post *Post) Validate(_ *pop.Connection) (*validate.Errors, error) {
 	return validate.Validate(
 		&validators.UUIDIsPresent{Name: "ID", Field: post.ID},
 		&validators.StringIsPresent{Name: "Data", Field: post.Data},
+		&validators.StringInclusion{Name: "Visibility", Field: post.Visibility, List: resource.Visibilities},
 		&validators.TimeIsPresent{Name: "UpdatedAt", Field: post.UpdatedAt},
 		&validators.TimeIsPresent{Name: "CreatedAt", Field: post.CreatedAt},
 		&validators.UUIDIsPresent{Name: "CreatedByUserId", Field: post.CreatedByUserId},
@@ -33,3 +45,54 @@ func (post *Post) Validate(_ *pop.Connection) (*validate.Errors, error) {
 		&validators.UUIDIsPresent{Name: "UpdatedBySurrogateId", Field: post.UpdatedBySurrogateId},
 	), nil
 }
+
+func (post *Post) SetCreatedBy(userId uuid.UUID, surrogateId uuid.UUID) {
+	post.CreatedByUserId = userId
+	post.CreatedBySurrogateId = surrogateId
+}
+
+func (post *Post) SetUpdatedBy(userId uuid.UUID, surrogateId uuid.UUID) {
+	post.UpdatedByUserId = userId
+	post.UpdatedBySurrogateId = surrogateId
+}
+
+func (post *Post) ResourceType() resource.Type {
+	return PostType
+}
+
+func (post *Post) ResourceId() uuid.UUID {
+	return post.ID
+}
+
+// OwnerId returns the account the post was written in
+func (post *Post) OwnerId() uuid.UUID {
+	return post.CreatedByUserId
+}
+
+func (post *Post) UpdatedBy() (uuid.UUID, uuid.UUID) {
+	return post.UpdatedByUserId, post.UpdatedBySurrogateId
+}
+
+func (post *Post) ResourceVisibility() string {
+	return post.Visibility
+}
+
+func (post *Post) IsDeleted() bool {
+	return !post.IsActive
+}
+
+func (post *Post) ResourceVersion() int {
+	return post.Version
+}
+
+func (post *Post) SetResourceVersion(version int, at time.Time) {
+	post.Version = version
+	post.UpdatedAt = at
+	if post.CreatedAt.IsZero() {
+		post.CreatedAt = at
+	}
+}
+
+func (post *Post) MarkDeleted() {
+	post.IsActive = false
+}
@@ -1,13 +1,20 @@
 package models
 
 import (
-	"github.com/gofrs/uuid"
 	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
 )
 
+// PostHistory is a previous version of a post. CreatedAt is when the version was written, UpdatedAt when it was
+// replaced by the next one.
 type PostHistory struct {
 	ID                   uuid.UUID `db:"id" json:"id"`
 	PostId               uuid.UUID `db:"post_id" json:"post_id"`
+	Version              int       `db:"version" json:"version"`
 	CreatedAt            time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt            time.Time `db:"updated_at" json:"updated_at"`
 	CreatedByUserId      uuid.UUID `db:"created_by_user_id" json:"created_by_user_id"`
@@ -15,4 +22,49 @@ type PostHistory struct {
 	UpdatedByUserId      uuid.UUID `db:"updated_by_user_id" json:"updated_by_user_id"`
 	UpdatedBySurrogateId uuid.UUID `db:"updated_by_surrogate_id" json:"updated_by_surrogate_id"`
 	Data                 string    `db:"data" json:"data"`
+	Visibility           string    `db:"visibility" json:"visibility"`
+}
+
+// NewPostHistory returns the snapshot of the post before it is changed
+func NewPostHistory(post Post, now time.Time) PostHistory {
+	id, _ := uuid.NewV4()
+	return PostHistory{
+		ID:                   id,
+		PostId:               post.ID,
+		Version:              post.Version,
+		CreatedAt:            post.UpdatedAt,
+		UpdatedAt:            now,
+		CreatedByUserId:      post.CreatedByUserId,
+		CreatedBySurrogateId: post.CreatedBySurrogateId,
+		UpdatedByUserId:      post.UpdatedByUserId,
+		UpdatedBySurrogateId: post.UpdatedBySurrogateId,
+		Data:                 post.Data,
+		Visibility:           post.Visibility,
+	}
+}
+
+func (history *PostHistory) Validate(_ *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Name: "ID", Field: history.ID},
+		&validators.UUIDIsPresent{Name: "PostId", Field: history.PostId},
+		&validators.TimeIsPresent{Name: "UpdatedAt", Field: history.UpdatedAt},
+		&validators.UUIDIsPresent{Name: "UpdatedByUserId", Field: history.UpdatedByUserId},
+		&validators.UUIDIsPresent{Name: "UpdatedBySurrogateId", Field: history.UpdatedBySurrogateId},
+	), nil
+}
+
+// Post returns the version as it was written
+func (history *PostHistory) Post() Post {
+	return Post{
+		ID:                   history.PostId,
+		UpdatedAt:            history.CreatedAt,
+		CreatedByUserId:      history.CreatedByUserId,
+		CreatedBySurrogateId: history.CreatedBySurrogateId,
+		UpdatedByUserId:      history.UpdatedByUserId,
+		UpdatedBySurrogateId: history.UpdatedBySurrogateId,
+		Data:                 history.Data,
+		IsActive:             true,
+		Visibility:           history.Visibility,
+		Version:              history.Version,
+	}
 }
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// RateLimit counts the consecutive failed or throttled attempts for a key, e.g. a user ID or a client IP
+type RateLimit struct {
+	ID           uuid.UUID `db:"id"`
+	Key          string    `db:"limit_key"`
+	Attempts     int       `db:"attempts"`
+	BlockedUntil time.Time `db:"blocked_until"`
+	ExpiresAt    time.Time `db:"expires_at"`
+	CreatedAt    time.Time `db:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at"`
+}
+
+func (limit *RateLimit) Validate(_ *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Name: "ID", Field: limit.ID},
+		&validators.StringIsPresent{Name: "Key", Field: limit.Key},
+		&validators.TimeIsPresent{Name: "ExpiresAt", Field: limit.ExpiresAt},
+		&validators.TimeIsPresent{Name: "CreatedAt", Field: limit.CreatedAt},
+		&validators.TimeIsPresent{Name: "UpdatedAt", Field: limit.UpdatedAt},
+	), nil
+}
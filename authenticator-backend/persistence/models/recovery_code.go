@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// RecoveryCode is a hashed one-time code which can be redeemed once to log in when all other credentials are lost
+type RecoveryCode struct {
+	ID        uuid.UUID `db:"id"`
+	UserId    uuid.UUID `db:"user_id"`
+	Code      string    `db:"code"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func (code *RecoveryCode) Validate(_ *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Name: "ID", Field: code.ID},
+		&validators.UUIDIsPresent{Name: "UserId", Field: code.UserId},
+		&validators.StringIsPresent{Name: "Code", Field: code.Code},
+		&validators.TimeIsPresent{Name: "CreatedAt", Field: code.CreatedAt},
+		&validators.TimeIsPresent{Name: "UpdatedAt", Field: code.UpdatedAt},
+	), nil
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// The roles seeded by the migrations
+const (
+	// RoleSupport can look up users and read the login audit
+	RoleSupport = "support"
+	// RoleUserAdmin can additionally change and delete users
+	RoleUserAdmin = "user-admin"
+	// RoleSuperAdmin has all permissions, including the assignment of roles
+	RoleSuperAdmin = "super-admin"
+)
+
+// The permissions seeded by the migrations
+const (
+	PermissionUsersRead      = "users:read"
+	PermissionUsersWrite     = "users:write"
+	PermissionUsersDelete    = "users:delete"
+	PermissionAuditRead      = "audit:read"
+	PermissionRolesManage    = "roles:manage"
+	PermissionWebhooksManage = "webhooks:manage"
+)
+
+// Role is a named set of permissions which can be assigned to users
+type Role struct {
+	ID          uuid.UUID `db:"id" json:"id"`
+	Name        string    `db:"name" json:"name"`
+	Description string    `db:"description" json:"description"`
+	// Permissions are the names of the permissions of the role, they are loaded by the persister
+	Permissions []string  `db:"-" json:"permissions"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+}
+
+type Permission struct {
+	ID          uuid.UUID `db:"id" json:"id"`
+	Name        string    `db:"name" json:"name"`
+	Description string    `db:"description" json:"description"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// UserRole assigns a role to a user
+type UserRole struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	UserId    uuid.UUID `db:"user_id" json:"user_id"`
+	RoleId    uuid.UUID `db:"role_id" json:"role_id"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+func NewUserRole(userId uuid.UUID, roleId uuid.UUID) UserRole {
+	id, _ := uuid.NewV4()
+	now := time.Now().UTC()
+	return UserRole{
+		ID:        id,
+		UserId:    userId,
+		RoleId:    roleId,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+func (userRole *UserRole) Validate(_ *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Name: "ID", Field: userRole.ID},
+		&validators.UUIDIsPresent{Name: "UserId", Field: userRole.UserId},
+		&validators.UUIDIsPresent{Name: "RoleId", Field: userRole.RoleId},
+		&validators.TimeIsPresent{Name: "CreatedAt", Field: userRole.CreatedAt},
+		&validators.TimeIsPresent{Name: "UpdatedAt", Field: userRole.UpdatedAt},
+	), nil
+}
+
+// HasPermission returns whether one of the roles has the permission
+func HasPermission(roles []Role, permission string) bool {
+	for _, role := range roles {
+		for _, p := range role.Permissions {
+			if p == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/pop/v6/slices"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// The types of the security events
+const (
+	EventGrantCreated      = "grant.created"
+	EventGrantDenied       = "grant.denied"
+	EventGrantsDeactivated = "grants.deactivated"
+	EventRelationRevoked   = "relation.revoked"
+	EventGuestLogout       = "guest.logout"
+	EventUserActivated     = "user.activated"
+	EventUserDeactivated   = "user.deactivated"
+	EventPasswordChanged   = "password.changed"
+	EventPasswordReset     = "password.reset"
+	EventSessionsRevoked   = "sessions.revoked"
+	EventPasskeyRegistered = "passkey.registered"
+	EventRoleAssigned      = "role.assigned"
+	EventRoleRemoved       = "role.removed"
+)
+
+// SecurityEvent records a security relevant action. The actor is the account the session belongs to, the surrogate
+// is the guest who acted on behalf of the account holder, if any, and the target is the user affected by the action.
+type SecurityEvent struct {
+	ID              uuid.UUID  `db:"id" json:"id"`
+	Type            string     `db:"type" json:"type"`
+	ActorUserId     *uuid.UUID `db:"actor_user_id" json:"actor_user_id,omitempty"`
+	SurrogateUserId *uuid.UUID `db:"surrogate_user_id" json:"surrogate_user_id,omitempty"`
+	TargetUserId    *uuid.UUID `db:"target_user_id" json:"target_user_id,omitempty"`
+	RequestId       string     `db:"request_id" json:"request_id,omitempty"`
+	ClientIpAddress string     `db:"client_ip_address" json:"client_ip_address,omitempty"`
+	ClientUserAgent string     `db:"client_user_agent" json:"client_user_agent,omitempty"`
+	Metadata        slices.Map `db:"metadata" json:"metadata"`
+	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt       time.Time  `db:"updated_at" json:"-"`
+}
+
+func NewSecurityEvent(eventType string) SecurityEvent {
+	id, _ := uuid.NewV4()
+	now := time.Now().UTC()
+	return SecurityEvent{
+		ID:        id,
+		Type:      eventType,
+		Metadata:  slices.Map{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+func (event *SecurityEvent) Validate(_ *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Name: "ID", Field: event.ID},
+		&validators.StringIsPresent{Name: "Type", Field: event.Type},
+		&validators.TimeIsPresent{Name: "CreatedAt", Field: event.CreatedAt},
+		&validators.TimeIsPresent{Name: "UpdatedAt", Field: event.UpdatedAt},
+	), nil
+}
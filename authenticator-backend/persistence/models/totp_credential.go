@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// TotpCredential holds the encrypted shared secret of a user's authenticator app. The credential is only used as
+// second factor after the user confirmed the enrolment with a valid code.
+type TotpCredential struct {
+	ID           uuid.UUID `db:"id"`
+	UserId       uuid.UUID `db:"user_id"`
+	Secret       string    `db:"secret"`
+	Confirmed    bool      `db:"confirmed"`
+	LastUsedStep int64     `db:"last_used_step"`
+	CreatedAt    time.Time `db:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at"`
+}
+
+func (credential *TotpCredential) Validate(_ *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Name: "ID", Field: credential.ID},
+		&validators.UUIDIsPresent{Name: "UserId", Field: credential.UserId},
+		&validators.StringIsPresent{Name: "Secret", Field: credential.Secret},
+		&validators.TimeIsPresent{Name: "CreatedAt", Field: credential.CreatedAt},
+		&validators.TimeIsPresent{Name: "UpdatedAt", Field: credential.UpdatedAt},
+	), nil
+}
@@ -15,10 +15,17 @@ type User struct {
 	Email               string               `db:"email" json:"email"`
 	Verified            bool                 `db:"verified" json:"verified"`
 	WebauthnCredentials []WebauthnCredential `has_many:"webauthn_credentials" json:"webauthn_credentials,omitempty"`
+	Emails              []Email              `has_many:"emails" json:"emails,omitempty"`
 	CreatedAt           time.Time            `db:"created_at" json:"created_at"`
 	UpdatedAt           time.Time            `db:"updated_at" json:"updated_at"`
 	IsActive            bool                 `db:"is_active" json:"is_active"`
-	IsAdmin             bool                 `db:"is_admin" json:"is_admin"`
+	// SessionsRevokedAt invalidates all sessions issued before, e.g. after a password reset
+	SessionsRevokedAt *time.Time `db:"sessions_revoked_at" json:"-"`
+	// DeletionScheduledAt is the time after which a deletion requested by the user is carried out
+	DeletionScheduledAt *time.Time `db:"deletion_scheduled_at" json:"deletion_scheduled_at,omitempty"`
+	// GuestLoginNotifications is the mode of the notifications about logins of guests chosen by the user, the
+	// configured mode applies if it is empty
+	GuestLoginNotifications string `db:"guest_login_notifications" json:"-"`
 }
 
 func NewUser(email string) User {
@@ -30,7 +37,6 @@ func NewUser(email string) User {
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		IsActive:  true,
-		IsAdmin:   false,
 	}
 }
 
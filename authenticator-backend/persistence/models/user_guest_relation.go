@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql"
+	"strings"
 	"time"
 
 	"github.com/gobuffalo/pop/v6"
@@ -10,6 +11,13 @@ import (
 	"github.com/gofrs/uuid"
 )
 
+// The scopes of a guest relation, i.e. what the guest may do on behalf of the account holder. The scopes belong to the
+// resource types, see resource.Scopes.
+const (
+	ScopePostsRead  = "posts:read"
+	ScopePostsWrite = "posts:write"
+)
+
 type UserGuestRelation struct {
 	ID                      uuid.UUID     `db:"id" json:"id"`
 	GuestUserID             uuid.UUID     `db:"guest_user_id" json:"guestUserId"`
@@ -23,6 +31,16 @@ type UserGuestRelation struct {
 	MinutesAllowed          sql.NullInt32 `db:"minutes_allowed" json:"-"`
 	AssociatedAccessGrantId uuid.UUID     `db:"associated_access_grant_id" json:"-"`
 	GrantHash               *[]byte       `db:"grant_hash" json:"-"`
+	// Scopes is a comma separated list of the scopes of the relation
+	Scopes string `db:"scopes" json:"scopes"`
+}
+
+// ScopeList returns the scopes of the relation
+func (relation *UserGuestRelation) ScopeList() []string {
+	if relation.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(relation.Scopes, ",")
 }
 
 // Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
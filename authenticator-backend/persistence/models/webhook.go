@@ -0,0 +1,168 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// The types of the events delivered to webhook endpoints
+const (
+	WebhookEventUserCreated     = "user.created"
+	WebhookEventUserActivated   = "user.activated"
+	WebhookEventUserDeactivated = "user.deactivated"
+	WebhookEventUserDeleted     = "user.deleted"
+	WebhookEventRelationCreated = "relation.created"
+	WebhookEventRelationUsed    = "relation.used"
+	WebhookEventRelationExpired = "relation.expired"
+	WebhookEventRelationRevoked = "relation.revoked"
+)
+
+// WebhookEventTypes are all types of webhook events
+var WebhookEventTypes = []string{
+	WebhookEventUserCreated,
+	WebhookEventUserActivated,
+	WebhookEventUserDeactivated,
+	WebhookEventUserDeleted,
+	WebhookEventRelationCreated,
+	WebhookEventRelationUsed,
+	WebhookEventRelationExpired,
+	WebhookEventRelationRevoked,
+}
+
+// The states of a webhook delivery
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	// WebhookDeliveryFailed deliveries were given up after the last attempt
+	WebhookDeliveryFailed = "failed"
+)
+
+// WebhookEndpoint is a registered receiver of webhook events
+type WebhookEndpoint struct {
+	ID          uuid.UUID `db:"id" json:"id"`
+	Url         string    `db:"url" json:"url"`
+	Description string    `db:"description" json:"description"`
+	// Secret signs the deliveries, it is only returned when the endpoint is created
+	Secret string `db:"secret" json:"-"`
+	// Events is the comma separated list of the subscribed event types, all events are subscribed if it is empty
+	Events    string    `db:"events" json:"-"`
+	IsActive  bool      `db:"is_active" json:"is_active"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// EventTypes returns the subscribed event types, an empty list subscribes all events
+func (endpoint *WebhookEndpoint) EventTypes() []string {
+	if endpoint.Events == "" {
+		return []string{}
+	}
+	return strings.Split(endpoint.Events, ",")
+}
+
+func (endpoint *WebhookEndpoint) SetEventTypes(eventTypes []string) {
+	endpoint.Events = strings.Join(eventTypes, ",")
+}
+
+// Subscribes returns whether events of the type are delivered to the endpoint
+func (endpoint *WebhookEndpoint) Subscribes(eventType string) bool {
+	eventTypes := endpoint.EventTypes()
+	if len(eventTypes) == 0 {
+		return true
+	}
+	for _, subscribed := range eventTypes {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (endpoint *WebhookEndpoint) Validate(_ *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Name: "ID", Field: endpoint.ID},
+		&validators.URLIsPresent{Name: "Url", Field: endpoint.Url},
+		&validators.StringIsPresent{Name: "Secret", Field: endpoint.Secret},
+		&validators.TimeIsPresent{Name: "CreatedAt", Field: endpoint.CreatedAt},
+		&validators.TimeIsPresent{Name: "UpdatedAt", Field: endpoint.UpdatedAt},
+	), nil
+}
+
+// WebhookEvent is an entry of the outbox. It is written in the transaction of the change it announces and fanned out
+// into a delivery per subscribed endpoint afterwards.
+type WebhookEvent struct {
+	ID   uuid.UUID `db:"id" json:"id"`
+	Type string    `db:"type" json:"type"`
+	// Payload is the JSON encoded body sent to the endpoints
+	Payload      string     `db:"payload" json:"-"`
+	DispatchedAt *time.Time `db:"dispatched_at" json:"dispatched_at,omitempty"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time  `db:"updated_at" json:"-"`
+}
+
+func (event *WebhookEvent) Validate(_ *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Name: "ID", Field: event.ID},
+		&validators.StringIsPresent{Name: "Type", Field: event.Type},
+		&validators.StringIsPresent{Name: "Payload", Field: event.Payload},
+		&validators.TimeIsPresent{Name: "CreatedAt", Field: event.CreatedAt},
+		&validators.TimeIsPresent{Name: "UpdatedAt", Field: event.UpdatedAt},
+	), nil
+}
+
+// WebhookDelivery is the delivery of an event to an endpoint
+type WebhookDelivery struct {
+	ID            uuid.UUID  `db:"id" json:"id"`
+	EventId       uuid.UUID  `db:"event_id" json:"event_id"`
+	EventType     string     `db:"event_type" json:"event_type"`
+	EndpointId    uuid.UUID  `db:"endpoint_id" json:"endpoint_id"`
+	Status        string     `db:"status" json:"status"`
+	Attempts      int        `db:"attempts" json:"attempts"`
+	NextAttemptAt time.Time  `db:"next_attempt_at" json:"next_attempt_at"`
+	LastAttemptAt *time.Time `db:"last_attempt_at" json:"last_attempt_at,omitempty"`
+	// ResponseStatus is the HTTP status of the last attempt, 0 if the endpoint could not be reached
+	ResponseStatus int        `db:"response_status" json:"response_status"`
+	LastError      string     `db:"last_error" json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `db:"delivered_at" json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+func NewWebhookDelivery(event WebhookEvent, endpoint WebhookEndpoint, now time.Time) WebhookDelivery {
+	id, _ := uuid.NewV4()
+	return WebhookDelivery{
+		ID:            id,
+		EventId:       event.ID,
+		EventType:     event.Type,
+		EndpointId:    endpoint.ID,
+		Status:        WebhookDeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+// Replay resets the delivery, so it is attempted again with all attempts
+func (delivery *WebhookDelivery) Replay(now time.Time) {
+	delivery.Status = WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	delivery.DeliveredAt = nil
+	delivery.UpdatedAt = now
+}
+
+func (delivery *WebhookDelivery) Validate(_ *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Name: "ID", Field: delivery.ID},
+		&validators.UUIDIsPresent{Name: "EventId", Field: delivery.EventId},
+		&validators.UUIDIsPresent{Name: "EndpointId", Field: delivery.EndpointId},
+		&validators.StringInclusion{Name: "Status", Field: delivery.Status, List: []string{WebhookDeliveryPending, WebhookDeliveryDelivered, WebhookDeliveryFailed}},
+		&validators.TimeIsPresent{Name: "NextAttemptAt", Field: delivery.NextAttemptAt},
+		&validators.TimeIsPresent{Name: "CreatedAt", Field: delivery.CreatedAt},
+		&validators.TimeIsPresent{Name: "UpdatedAt", Field: delivery.UpdatedAt},
+	), nil
+}
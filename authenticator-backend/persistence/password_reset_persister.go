@@ -0,0 +1,70 @@
+package persistence
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+type PasswordResetPersister interface {
+	Get(id uuid.UUID) (*models.PasswordReset, error)
+	Create(reset models.PasswordReset) error
+	Delete(reset models.PasswordReset) error
+	DeleteByUserId(userId uuid.UUID) error
+}
+
+type passwordResetPersister struct {
+	db *pop.Connection
+}
+
+func NewPasswordResetPersister(db *pop.Connection) PasswordResetPersister {
+	return &passwordResetPersister{db: db}
+}
+
+func (p *passwordResetPersister) Get(id uuid.UUID) (*models.PasswordReset, error) {
+	reset := models.PasswordReset{}
+	err := p.db.Find(&reset, id)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get password reset: %w", err)
+	}
+
+	return &reset, nil
+}
+
+func (p *passwordResetPersister) Create(reset models.PasswordReset) error {
+	vErr, err := p.db.ValidateAndCreate(&reset)
+	if err != nil {
+		return fmt.Errorf("failed to store password reset: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("password reset object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *passwordResetPersister) Delete(reset models.PasswordReset) error {
+	err := p.db.Destroy(&reset)
+	if err != nil {
+		return fmt.Errorf("failed to delete password reset: %w", err)
+	}
+
+	return nil
+}
+
+func (p *passwordResetPersister) DeleteByUserId(userId uuid.UUID) error {
+	err := p.db.RawQuery("DELETE FROM password_resets WHERE user_id = ?", userId).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to delete password resets: %w", err)
+	}
+
+	return nil
+}
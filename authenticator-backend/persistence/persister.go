@@ -32,9 +32,39 @@ type Persister interface {
 	GetJwkPersister() JwkPersister
 	GetJwkPersisterWithConnection(tx *pop.Connection) JwkPersister
 	GetAccountAccessGrantPersister() AccountAccessGrantPersister
+	GetAccountAccessGrantPersisterWithConnection(tx *pop.Connection) AccountAccessGrantPersister
 	GetUserGuestRelationPersister() UserGuestRelationPersister
+	GetUserGuestRelationPersisterWithConnection(tx *pop.Connection) UserGuestRelationPersister
 	GetLoginAuditLogPersister() LoginAuditLogPersister
+	GetLoginAuditLogPersisterWithConnection(tx *pop.Connection) LoginAuditLogPersister
 	GetPostPersister() PostPersister
+	GetPostPersisterWithConnection(tx *pop.Connection) PostPersister
+	GetPostHistoryPersister() PostHistoryPersister
+	GetPostHistoryPersisterWithConnection(tx *pop.Connection) PostHistoryPersister
+	GetRecoveryCodePersister() RecoveryCodePersister
+	GetRecoveryCodePersisterWithConnection(tx *pop.Connection) RecoveryCodePersister
+	GetTotpCredentialPersister() TotpCredentialPersister
+	GetTotpCredentialPersisterWithConnection(tx *pop.Connection) TotpCredentialPersister
+	GetRateLimitPersister() RateLimitPersister
+	GetPasswordResetPersister() PasswordResetPersister
+	GetPasswordResetPersisterWithConnection(tx *pop.Connection) PasswordResetPersister
+	GetEmailChangePersister() EmailChangePersister
+	GetEmailChangePersisterWithConnection(tx *pop.Connection) EmailChangePersister
+	GetEmailPersister() EmailPersister
+	GetEmailPersisterWithConnection(tx *pop.Connection) EmailPersister
+	GetRolePersister() RolePersister
+	GetRolePersisterWithConnection(tx *pop.Connection) RolePersister
+	GetSecurityEventPersister() SecurityEventPersister
+	GetSecurityEventPersisterWithConnection(tx *pop.Connection) SecurityEventPersister
+	GetAuditCheckpointPersister() AuditCheckpointPersister
+	GetWebhookPersister() WebhookPersister
+	GetWebhookPersisterWithConnection(tx *pop.Connection) WebhookPersister
+	GetLoginAnomalyPersister() LoginAnomalyPersister
+	GetLoginAnomalyPersisterWithConnection(tx *pop.Connection) LoginAnomalyPersister
+	GetGuestLoginNotificationPersister() GuestLoginNotificationPersister
+	GetGuestLoginNotificationPersisterWithConnection(tx *pop.Connection) GuestLoginNotificationPersister
+	GetGuestActivityPersister() GuestActivityPersister
+	GetGuestActivityPersisterWithConnection(tx *pop.Connection) GuestActivityPersister
 }
 
 type Migrator interface {
@@ -151,6 +181,10 @@ func (p *persister) GetAccountAccessGrantPersister() AccountAccessGrantPersister
 	return NewAccountAccessGrantPersister(p.DB)
 }
 
+func (*persister) GetAccountAccessGrantPersisterWithConnection(tx *pop.Connection) AccountAccessGrantPersister {
+	return NewAccountAccessGrantPersister(tx)
+}
+
 func (p *persister) GetJwkPersister() JwkPersister {
 	return NewJwkPersister(p.DB)
 }
@@ -167,10 +201,126 @@ func (p *persister) GetUserGuestRelationPersister() UserGuestRelationPersister {
 	return NewUserGuestRelationPersister(p.DB)
 }
 
+func (*persister) GetUserGuestRelationPersisterWithConnection(tx *pop.Connection) UserGuestRelationPersister {
+	return NewUserGuestRelationPersister(tx)
+}
+
 func (p *persister) GetLoginAuditLogPersister() LoginAuditLogPersister {
 	return NewLoginAuditLogPersister(p.DB)
 }
 
+func (*persister) GetLoginAuditLogPersisterWithConnection(tx *pop.Connection) LoginAuditLogPersister {
+	return NewLoginAuditLogPersister(tx)
+}
+
 func (p *persister) GetPostPersister() PostPersister {
 	return NewPostPersister(p.DB)
 }
+
+func (*persister) GetPostPersisterWithConnection(tx *pop.Connection) PostPersister {
+	return NewPostPersister(tx)
+}
+
+func (p *persister) GetPostHistoryPersister() PostHistoryPersister {
+	return NewPostHistoryPersister(p.DB)
+}
+
+func (*persister) GetPostHistoryPersisterWithConnection(tx *pop.Connection) PostHistoryPersister {
+	return NewPostHistoryPersister(tx)
+}
+
+func (p *persister) GetRecoveryCodePersister() RecoveryCodePersister {
+	return NewRecoveryCodePersister(p.DB)
+}
+
+func (*persister) GetRecoveryCodePersisterWithConnection(tx *pop.Connection) RecoveryCodePersister {
+	return NewRecoveryCodePersister(tx)
+}
+
+func (p *persister) GetTotpCredentialPersister() TotpCredentialPersister {
+	return NewTotpCredentialPersister(p.DB)
+}
+
+func (*persister) GetTotpCredentialPersisterWithConnection(tx *pop.Connection) TotpCredentialPersister {
+	return NewTotpCredentialPersister(tx)
+}
+
+func (p *persister) GetRateLimitPersister() RateLimitPersister {
+	return NewRateLimitPersister(p.DB)
+}
+
+func (p *persister) GetPasswordResetPersister() PasswordResetPersister {
+	return NewPasswordResetPersister(p.DB)
+}
+
+func (*persister) GetPasswordResetPersisterWithConnection(tx *pop.Connection) PasswordResetPersister {
+	return NewPasswordResetPersister(tx)
+}
+
+func (p *persister) GetEmailChangePersister() EmailChangePersister {
+	return NewEmailChangePersister(p.DB)
+}
+
+func (*persister) GetEmailChangePersisterWithConnection(tx *pop.Connection) EmailChangePersister {
+	return NewEmailChangePersister(tx)
+}
+
+func (p *persister) GetEmailPersister() EmailPersister {
+	return NewEmailPersister(p.DB)
+}
+
+func (*persister) GetEmailPersisterWithConnection(tx *pop.Connection) EmailPersister {
+	return NewEmailPersister(tx)
+}
+
+func (p *persister) GetRolePersister() RolePersister {
+	return NewRolePersister(p.DB)
+}
+
+func (*persister) GetRolePersisterWithConnection(tx *pop.Connection) RolePersister {
+	return NewRolePersister(tx)
+}
+
+func (p *persister) GetSecurityEventPersister() SecurityEventPersister {
+	return NewSecurityEventPersister(p.DB)
+}
+
+func (*persister) GetSecurityEventPersisterWithConnection(tx *pop.Connection) SecurityEventPersister {
+	return NewSecurityEventPersister(tx)
+}
+
+func (p *persister) GetAuditCheckpointPersister() AuditCheckpointPersister {
+	return NewAuditCheckpointPersister(p.DB)
+}
+
+func (p *persister) GetWebhookPersister() WebhookPersister {
+	return NewWebhookPersister(p.DB)
+}
+
+func (*persister) GetWebhookPersisterWithConnection(tx *pop.Connection) WebhookPersister {
+	return NewWebhookPersister(tx)
+}
+
+func (p *persister) GetLoginAnomalyPersister() LoginAnomalyPersister {
+	return NewLoginAnomalyPersister(p.DB)
+}
+
+func (*persister) GetLoginAnomalyPersisterWithConnection(tx *pop.Connection) LoginAnomalyPersister {
+	return NewLoginAnomalyPersister(tx)
+}
+
+func (p *persister) GetGuestLoginNotificationPersister() GuestLoginNotificationPersister {
+	return NewGuestLoginNotificationPersister(p.DB)
+}
+
+func (*persister) GetGuestLoginNotificationPersisterWithConnection(tx *pop.Connection) GuestLoginNotificationPersister {
+	return NewGuestLoginNotificationPersister(tx)
+}
+
+func (p *persister) GetGuestActivityPersister() GuestActivityPersister {
+	return NewGuestActivityPersister(p.DB)
+}
+
+func (*persister) GetGuestActivityPersisterWithConnection(tx *pop.Connection) GuestActivityPersister {
+	return NewGuestActivityPersister(tx)
+}
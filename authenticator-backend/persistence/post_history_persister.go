@@ -0,0 +1,76 @@
+package persistence
+
+import (
+	"fmt"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+type PostHistoryPersister interface {
+	// Create adds the previous version of a post, it should be called in the transaction of the change
+	Create(history models.PostHistory) error
+	// ListByPost returns the previous versions of the post, oldest first
+	ListByPost(postId uuid.UUID) ([]models.PostHistory, error)
+	// FindByAuthor returns the previous versions of the posts of the account and the versions the user wrote as guest
+	// of other accounts
+	FindByAuthor(userId uuid.UUID) ([]models.PostHistory, error)
+	// PseudonymiseSurrogate replaces the user id with the pseudonym in the versions the user wrote as guest of other
+	// accounts
+	PseudonymiseSurrogate(userId uuid.UUID, pseudonym uuid.UUID) error
+}
+
+type postHistoryPersister struct {
+	db *pop.Connection
+}
+
+func NewPostHistoryPersister(db *pop.Connection) PostHistoryPersister {
+	return &postHistoryPersister{db: db}
+}
+
+func (p *postHistoryPersister) Create(history models.PostHistory) error {
+	vErr, err := p.db.ValidateAndCreate(&history)
+	if err != nil {
+		return fmt.Errorf("failed to store post history: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("post history object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *postHistoryPersister) ListByPost(postId uuid.UUID) ([]models.PostHistory, error) {
+	histories := []models.PostHistory{}
+	err := p.db.Where("post_id = ?", postId.String()).Order("version asc").All(&histories)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch post histories: %w", err)
+	}
+
+	return histories, nil
+}
+
+func (p *postHistoryPersister) FindByAuthor(userId uuid.UUID) ([]models.PostHistory, error) {
+	histories := []models.PostHistory{}
+	err := p.db.Where("updated_by_user_id = ? OR updated_by_surrogate_id = ?", userId.String(), userId.String()).Order("post_id asc, version asc").All(&histories)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch post histories: %w", err)
+	}
+
+	return histories, nil
+}
+
+func (p *postHistoryPersister) PseudonymiseSurrogate(userId uuid.UUID, pseudonym uuid.UUID) error {
+	err := p.db.RawQuery("UPDATE post_histories SET created_by_surrogate_id = ? WHERE created_by_surrogate_id = ?", pseudonym.String(), userId.String()).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to pseudonymise post histories: %w", err)
+	}
+	err = p.db.RawQuery("UPDATE post_histories SET updated_by_surrogate_id = ? WHERE updated_by_surrogate_id = ?", pseudonym.String(), userId.String()).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to pseudonymise post histories: %w", err)
+	}
+
+	return nil
+}
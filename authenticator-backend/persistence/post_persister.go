@@ -1,14 +1,47 @@
 package persistence
 
 import (
+	"database/sql"
 	"fmt"
+	"strings"
+	"time"
+
 	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/actor"
 	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/resource"
 )
 
+// PostCursor is the position of a post in a feed, the feed continues with the posts after it
+type PostCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// PostFilter restricts the posts returned by Feed. Fields which are not set don't restrict the result.
+type PostFilter struct {
+	// OwnerId matches the posts of the account
+	OwnerId *uuid.UUID
+	// After matches the posts after the cursor
+	After *PostCursor
+}
+
 type PostPersister interface {
 	Create(models.Post) error
+	Get(id uuid.UUID) (*models.Post, error)
+	// GetForUpdate returns the post like Get and locks it until the end of the transaction
+	GetForUpdate(id uuid.UUID) (*models.Post, error)
+	Update(models.Post) error
 	List(page int, perPage int) ([]models.Post, error)
+	// Feed returns up to limit active posts matching the filter which the viewer may read, newest first
+	Feed(viewer actor.Actor, filter PostFilter, limit int) ([]models.Post, error)
+	// FindByAuthor returns the posts of the account and the posts the user wrote as guest of other accounts
+	FindByAuthor(userId uuid.UUID) ([]models.Post, error)
+	// DeleteByUserId removes all posts of the account
+	DeleteByUserId(userId uuid.UUID) error
+	// PseudonymiseSurrogate replaces the user id with the pseudonym in the posts the user wrote as guest of other accounts
+	PseudonymiseSurrogate(userId uuid.UUID, pseudonym uuid.UUID) error
 }
 
 type postPersister struct {
@@ -32,6 +65,45 @@ func (p *postPersister) Create(post models.Post) error {
 	return nil
 }
 
+func (p *postPersister) Get(id uuid.UUID) (*models.Post, error) {
+	post := models.Post{}
+	err := p.db.Find(&post, id)
+	if err != nil && err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get post: %w", err)
+	}
+
+	return &post, nil
+}
+
+func (p *postPersister) GetForUpdate(id uuid.UUID) (*models.Post, error) {
+	post := models.Post{}
+	err := p.db.RawQuery("SELECT * FROM posts WHERE id = ? FOR UPDATE", id).First(&post)
+	if err != nil && err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get post: %w", err)
+	}
+
+	return &post, nil
+}
+
+func (p *postPersister) Update(post models.Post) error {
+	vErr, err := p.db.ValidateAndUpdate(&post)
+	if err != nil {
+		return fmt.Errorf("failed to update post: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("post object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
 func (p *postPersister) List(page int, perPage int) ([]models.Post, error) {
 	post := []models.Post{}
 	err := p.db.Q().Order("created_at desc").Paginate(page, perPage).All(&post)
@@ -41,3 +113,87 @@ func (p *postPersister) List(page int, perPage int) ([]models.Post, error) {
 
 	return post, nil
 }
+
+func (p *postPersister) Feed(viewer actor.Actor, filter PostFilter, limit int) ([]models.Post, error) {
+	posts := []models.Post{}
+
+	query := p.feedQuery(viewer, filter)
+	if query == nil {
+		return posts, nil
+	}
+
+	err := query.Order("created_at desc, id desc").Limit(limit).All(&posts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch posts: %w", err)
+	}
+
+	return posts, nil
+}
+
+// feedQuery returns the query of the posts of the feed, or nil if the viewer may not read any posts. The posts are
+// matched by the visibilities resource.Accessible allows for the own account and for other accounts.
+func (p *postPersister) feedQuery(viewer actor.Actor, filter PostFilter) *pop.Query {
+	var conditions []string
+	var args []interface{}
+	match := func(owner string, visibilities []string) {
+		if len(visibilities) == 0 {
+			return
+		}
+		// the placeholders are spelled out, Where would expand "IN (?)" to all arguments of the clause
+		matches := make([]string, len(visibilities))
+		args = append(args, viewer.PrincipalId.String())
+		for i, visibility := range visibilities {
+			matches[i] = "visibility = ?"
+			args = append(args, visibility)
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s AND (%s))", owner, strings.Join(matches, " OR ")))
+	}
+	match("created_by_user_id = ?", resource.OwnVisibilities(viewer, models.PostType))
+	match("created_by_user_id <> ?", resource.OtherVisibilities(viewer, models.PostType))
+	if len(conditions) == 0 {
+		return nil
+	}
+
+	query := p.db.Where("is_active = ?", true)
+	query = query.Where(fmt.Sprintf("(%s)", strings.Join(conditions, " OR ")), args...)
+	if filter.OwnerId != nil {
+		query = query.Where("created_by_user_id = ?", filter.OwnerId.String())
+	}
+	if filter.After != nil {
+		query = query.Where("(created_at < ? OR (created_at = ? AND id < ?))", filter.After.CreatedAt, filter.After.CreatedAt, filter.After.ID.String())
+	}
+
+	return query
+}
+
+func (p *postPersister) DeleteByUserId(userId uuid.UUID) error {
+	err := p.db.RawQuery("DELETE FROM posts WHERE created_by_user_id = ?", userId.String()).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to delete posts: %w", err)
+	}
+
+	return nil
+}
+
+func (p *postPersister) PseudonymiseSurrogate(userId uuid.UUID, pseudonym uuid.UUID) error {
+	err := p.db.RawQuery("UPDATE posts SET created_by_surrogate_id = ? WHERE created_by_surrogate_id = ?", pseudonym.String(), userId.String()).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to pseudonymise posts: %w", err)
+	}
+	err = p.db.RawQuery("UPDATE posts SET updated_by_surrogate_id = ? WHERE updated_by_surrogate_id = ?", pseudonym.String(), userId.String()).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to pseudonymise posts: %w", err)
+	}
+
+	return nil
+}
+
+func (p *postPersister) FindByAuthor(userId uuid.UUID) ([]models.Post, error) {
+	posts := []models.Post{}
+	err := p.db.Where("created_by_user_id = ? OR created_by_surrogate_id = ?", userId.String(), userId.String()).Order("created_at asc").All(&posts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch posts: %w", err)
+	}
+
+	return posts, nil
+}
@@ -0,0 +1,32 @@
+package persistence
+
+import (
+	"testing"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/teamhanko/hanko/backend/actor"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+func TestPostPersister_FeedQuery_FollowsAccessible(t *testing.T) {
+	holderId := uuid.FromStringOrNil("b5dd5267-b462-48be-b70d-bcd6f1bbe7a5")
+	guestId := uuid.FromStringOrNil("6e1c4c5b-0d7c-4e58-9c43-9f5b0b1d2e3f")
+	relationId := uuid.FromStringOrNil("0f6d6a4e-7c38-4f6e-9a55-3b1c1a0d2f11")
+	persister := &postPersister{db: newConnection(t)}
+
+	holder := actor.Actor{PrincipalId: holderId, SurrogateId: holderId, Scopes: []string{}}
+	sql, args := persister.feedQuery(holder, PostFilter{}).ToSQL(&pop.Model{Value: &[]models.Post{}})
+	assert.Contains(t, sql, "WHERE is_active = $1 AND ((created_by_user_id = $2 AND (visibility = $3 OR visibility = $4 OR visibility = $5)) OR (created_by_user_id <> $6 AND (visibility = $7)))")
+	assert.Equal(t, []interface{}{true, holderId.String(), "private", "guests", "public", holderId.String(), "public"}, args)
+
+	guest := actor.Actor{PrincipalId: holderId, SurrogateId: guestId, RelationId: &relationId, Scopes: []string{models.ScopePostsRead}}
+	sql, args = persister.feedQuery(guest, PostFilter{}).ToSQL(&pop.Model{Value: &[]models.Post{}})
+	assert.Contains(t, sql, "WHERE is_active = $1 AND ((created_by_user_id = $2 AND (visibility = $3 OR visibility = $4)) OR (created_by_user_id <> $5 AND (visibility = $6)))")
+	assert.Equal(t, []interface{}{true, holderId.String(), "guests", "public", holderId.String(), "public"}, args)
+
+	// without the read scope a guest reads no posts at all, neither of the account nor public ones of other accounts
+	guest.Scopes = []string{models.ScopePostsWrite}
+	assert.Nil(t, persister.feedQuery(guest, PostFilter{}))
+}
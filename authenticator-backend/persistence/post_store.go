@@ -0,0 +1,83 @@
+package persistence
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/resource"
+)
+
+type postStore struct {
+	posts     PostPersister
+	histories PostHistoryPersister
+}
+
+// NewPostStore returns the store of the posts for the resource lifecycle. The persisters should be the ones of the
+// transaction of the change.
+func NewPostStore(posts PostPersister, histories PostHistoryPersister) resource.Store {
+	return &postStore{posts: posts, histories: histories}
+}
+
+func (s *postStore) Get(id uuid.UUID) (resource.Versioned, error) {
+	post, err := s.posts.Get(id)
+	if err != nil || post == nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+func (s *postStore) GetForUpdate(id uuid.UUID) (resource.Versioned, error) {
+	post, err := s.posts.GetForUpdate(id)
+	if err != nil || post == nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+func (s *postStore) Create(r resource.Versioned) error {
+	post, err := s.post(r)
+	if err != nil {
+		return err
+	}
+	return s.posts.Create(*post)
+}
+
+func (s *postStore) Update(r resource.Versioned) error {
+	post, err := s.post(r)
+	if err != nil {
+		return err
+	}
+	return s.posts.Update(*post)
+}
+
+func (s *postStore) CreateVersion(previous resource.Versioned, replacedAt time.Time) error {
+	post, err := s.post(previous)
+	if err != nil {
+		return err
+	}
+	return s.histories.Create(models.NewPostHistory(*post, replacedAt))
+}
+
+func (s *postStore) ListVersions(id uuid.UUID) ([]resource.Versioned, error) {
+	histories, err := s.histories.ListByPost(id)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]resource.Versioned, len(histories))
+	for i, history := range histories {
+		post := history.Post()
+		versions[i] = &post
+	}
+	return versions, nil
+}
+
+func (s *postStore) post(r resource.Versioned) (*models.Post, error) {
+	post, ok := r.(*models.Post)
+	if !ok {
+		return nil, fmt.Errorf("resource of type %s is not a post", r.ResourceType().Name)
+	}
+	return post, nil
+}
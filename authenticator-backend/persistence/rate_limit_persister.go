@@ -0,0 +1,128 @@
+package persistence
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+type RateLimitPersister interface {
+	Get(key string) (*models.RateLimit, error)
+	// Increment atomically counts an attempt for the key and returns the attempts counted, including this one. The
+	// attempts of an expired rate limit are forgotten, the rate limit expires at expiresAt at the earliest.
+	Increment(key string, now time.Time, expiresAt time.Time) (int, error)
+	// Block blocks the key until blockedUntil, unless it is blocked for longer already
+	Block(key string, blockedUntil time.Time, expiresAt time.Time) error
+	Delete(key string) error
+}
+
+type rateLimitPersister struct {
+	db *pop.Connection
+}
+
+func NewRateLimitPersister(db *pop.Connection) RateLimitPersister {
+	return &rateLimitPersister{db: db}
+}
+
+func (p *rateLimitPersister) Get(key string) (*models.RateLimit, error) {
+	limit := models.RateLimit{}
+	err := p.db.Where("limit_key = ?", key).First(&limit)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rate limit: %w", err)
+	}
+
+	return &limit, nil
+}
+
+func (p *rateLimitPersister) Increment(key string, now time.Time, expiresAt time.Time) (int, error) {
+	count, err := p.increment(key, now, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+
+	if count == 0 {
+		id, err := uuid.NewV4()
+		if err != nil {
+			return 0, fmt.Errorf("failed to create rate limit id: %w", err)
+		}
+
+		createErr := p.db.Create(&models.RateLimit{
+			ID:           id,
+			Key:          key,
+			Attempts:     1,
+			BlockedUntil: now,
+			ExpiresAt:    expiresAt,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		})
+		if createErr == nil {
+			return 1, nil
+		}
+
+		// the unique key was violated, because another request created the rate limit concurrently
+		count, err = p.increment(key, now, expiresAt)
+		if err != nil {
+			return 0, err
+		}
+		if count == 0 {
+			return 0, fmt.Errorf("failed to store rate limit: %w", createErr)
+		}
+	}
+
+	limit, err := p.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	if limit == nil {
+		return 0, fmt.Errorf("rate limit %s was deleted concurrently", key)
+	}
+
+	return limit.Attempts, nil
+}
+
+func (p *rateLimitPersister) increment(key string, now time.Time, expiresAt time.Time) (int, error) {
+	// the expressions are evaluated with the expiry before the update, as expires_at is assigned last
+	count, err := p.db.RawQuery(`UPDATE rate_limits SET
+			attempts = CASE WHEN expires_at < ? THEN 1 ELSE attempts + 1 END,
+			blocked_until = CASE WHEN expires_at < ? THEN ? ELSE blocked_until END,
+			updated_at = ?,
+			expires_at = CASE WHEN expires_at < ? THEN ? ELSE expires_at END
+		WHERE limit_key = ?`,
+		now, now, now, now, expiresAt, expiresAt, key).ExecWithCount()
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment rate limit: %w", err)
+	}
+
+	return count, nil
+}
+
+func (p *rateLimitPersister) Block(key string, blockedUntil time.Time, expiresAt time.Time) error {
+	err := p.db.RawQuery(`UPDATE rate_limits SET
+			blocked_until = CASE WHEN blocked_until < ? THEN ? ELSE blocked_until END,
+			expires_at = CASE WHEN expires_at < ? THEN ? ELSE expires_at END,
+			updated_at = ?
+		WHERE limit_key = ?`,
+		blockedUntil, blockedUntil, expiresAt, expiresAt, time.Now().UTC(), key).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to block rate limit: %w", err)
+	}
+
+	return nil
+}
+
+func (p *rateLimitPersister) Delete(key string) error {
+	err := p.db.RawQuery("DELETE FROM rate_limits WHERE limit_key = ?", key).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to delete rate limit: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package persistence
+
+import (
+	"fmt"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+type RecoveryCodePersister interface {
+	Create(code models.RecoveryCode) error
+	GetByUserId(userId uuid.UUID) ([]models.RecoveryCode, error)
+	// Redeem removes the code. It returns false, if the code was redeemed already, e.g. by a concurrent request.
+	Redeem(code models.RecoveryCode) (bool, error)
+	DeleteByUserId(userId uuid.UUID) error
+}
+
+type recoveryCodePersister struct {
+	db *pop.Connection
+}
+
+func NewRecoveryCodePersister(db *pop.Connection) RecoveryCodePersister {
+	return &recoveryCodePersister{db: db}
+}
+
+func (p *recoveryCodePersister) Create(code models.RecoveryCode) error {
+	vErr, err := p.db.ValidateAndCreate(&code)
+	if err != nil {
+		return fmt.Errorf("failed to store recovery code: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("recovery code object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *recoveryCodePersister) GetByUserId(userId uuid.UUID) ([]models.RecoveryCode, error) {
+	var codes []models.RecoveryCode
+	err := p.db.Where("user_id = ?", userId).All(&codes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+func (p *recoveryCodePersister) Redeem(code models.RecoveryCode) (bool, error) {
+	count, err := p.db.RawQuery("DELETE FROM recovery_codes WHERE id = ?", code.ID).ExecWithCount()
+	if err != nil {
+		return false, fmt.Errorf("failed to redeem recovery code: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+func (p *recoveryCodePersister) DeleteByUserId(userId uuid.UUID) error {
+	err := p.db.RawQuery("DELETE FROM recovery_codes WHERE user_id = ?", userId).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package persistence
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+type RolePersister interface {
+	// List returns all roles with their permissions
+	List() ([]models.Role, error)
+	GetByName(name string) (*models.Role, error)
+	// FindByUserId returns the roles assigned to the user with their permissions
+	FindByUserId(userId uuid.UUID) ([]models.Role, error)
+	// Lock locks the role until the end of the transaction, so that concurrent changes of its assignments are
+	// serialized
+	Lock(roleId uuid.UUID) error
+	// CountUsers returns the number of users the role is assigned to
+	CountUsers(roleId uuid.UUID) (int, error)
+	// AddToUser assigns the role to the user, a role which is already assigned is left as it is
+	AddToUser(userId uuid.UUID, roleId uuid.UUID) error
+	RemoveFromUser(userId uuid.UUID, roleId uuid.UUID) error
+}
+
+type rolePersister struct {
+	db *pop.Connection
+}
+
+func NewRolePersister(db *pop.Connection) RolePersister {
+	return &rolePersister{db: db}
+}
+
+func (p *rolePersister) List() ([]models.Role, error) {
+	var roles []models.Role
+	err := p.db.Order("name asc").All(&roles)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to fetch roles: %w", err)
+	}
+
+	return p.loadPermissions(roles)
+}
+
+func (p *rolePersister) GetByName(name string) (*models.Role, error) {
+	role := models.Role{}
+	err := p.db.Where("name = ?", name).First(&role)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+
+	roles, err := p.loadPermissions([]models.Role{role})
+	if err != nil {
+		return nil, err
+	}
+
+	return &roles[0], nil
+}
+
+func (p *rolePersister) FindByUserId(userId uuid.UUID) ([]models.Role, error) {
+	var roles []models.Role
+	err := p.db.Where("id IN (SELECT role_id FROM user_roles WHERE user_id = ?)", userId).Order("name asc").All(&roles)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to fetch roles: %w", err)
+	}
+
+	return p.loadPermissions(roles)
+}
+
+func (p *rolePersister) loadPermissions(roles []models.Role) ([]models.Role, error) {
+	for i := range roles {
+		var permissions []models.Permission
+		err := p.db.Where("id IN (SELECT permission_id FROM role_permissions WHERE role_id = ?)", roles[i].ID).Order("name asc").All(&permissions)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to fetch permissions: %w", err)
+		}
+		roles[i].Permissions = []string{}
+		for _, permission := range permissions {
+			roles[i].Permissions = append(roles[i].Permissions, permission.Name)
+		}
+	}
+
+	return roles, nil
+}
+
+func (p *rolePersister) Lock(roleId uuid.UUID) error {
+	role := models.Role{}
+	err := p.db.RawQuery("SELECT * FROM roles WHERE id = ? FOR UPDATE", roleId).First(&role)
+	if err != nil {
+		return fmt.Errorf("failed to lock role: %w", err)
+	}
+
+	return nil
+}
+
+func (p *rolePersister) CountUsers(roleId uuid.UUID) (int, error) {
+	count, err := p.db.Where("role_id = ?", roleId).Count(&models.UserRole{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	return count, nil
+}
+
+func (p *rolePersister) AddToUser(userId uuid.UUID, roleId uuid.UUID) error {
+	exists, err := p.db.Where("user_id = ? AND role_id = ?", userId, roleId).Exists(&models.UserRole{})
+	if err != nil {
+		return fmt.Errorf("failed to check role assignment: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	userRole := models.NewUserRole(userId, roleId)
+	vErr, err := p.db.ValidateAndCreate(&userRole)
+	if err != nil {
+		return fmt.Errorf("failed to store role assignment: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("role assignment object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *rolePersister) RemoveFromUser(userId uuid.UUID, roleId uuid.UUID) error {
+	err := p.db.RawQuery("DELETE FROM user_roles WHERE user_id = ? AND role_id = ?", userId, roleId).Exec()
+	if err != nil {
+		return fmt.Errorf("failed to delete role assignment: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package persistence
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+// SecurityEventFilter restricts the events returned by Search. Fields which are not set don't restrict the result.
+type SecurityEventFilter struct {
+	// UserId matches the events with the user as actor, surrogate or target
+	UserId *uuid.UUID
+	Types  []string
+	// From matches the events created at or after the time
+	From *time.Time
+	// To matches the events created before the time
+	To *time.Time
+}
+
+type SecurityEventPersister interface {
+	Create(event models.SecurityEvent) error
+	// Search returns a page of the events matching the filter, newest first, and the number of all matching events
+	Search(filter SecurityEventFilter, page int, perPage int) ([]models.SecurityEvent, int, error)
+	// Pseudonymise replaces the user id with the pseudonym in all events of the user and removes the client
+	// information
+	Pseudonymise(userId uuid.UUID, pseudonym uuid.UUID) error
+}
+
+type securityEventPersister struct {
+	db *pop.Connection
+}
+
+func NewSecurityEventPersister(db *pop.Connection) SecurityEventPersister {
+	return &securityEventPersister{db: db}
+}
+
+func (p *securityEventPersister) Create(event models.SecurityEvent) error {
+	vErr, err := p.db.ValidateAndCreate(&event)
+	if err != nil {
+		return fmt.Errorf("failed to store security event: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("security event object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *securityEventPersister) Search(filter SecurityEventFilter, page int, perPage int) ([]models.SecurityEvent, int, error) {
+	events := []models.SecurityEvent{}
+
+	query := p.searchQuery(filter).Paginate(page, perPage)
+	err := query.All(&events)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search security events: %w", err)
+	}
+
+	return events, query.Paginator.TotalEntriesSize, nil
+}
+
+// searchQuery returns the query for the events matching the filter, newest first. The page still has to be set.
+func (p *securityEventPersister) searchQuery(filter SecurityEventFilter) *pop.Query {
+	query := p.db.Q()
+	if filter.UserId != nil {
+		// pop joins the conditions with AND without parentheses, so the OR has to be enclosed
+		query = query.Where("(actor_user_id = ? OR surrogate_user_id = ? OR target_user_id = ?)", *filter.UserId, *filter.UserId, *filter.UserId)
+	}
+	if len(filter.Types) > 0 {
+		types := make([]interface{}, len(filter.Types))
+		for i, eventType := range filter.Types {
+			types[i] = eventType
+		}
+		query = query.Where("type IN (?)", types...)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	return query.Order("created_at desc, id desc")
+}
+
+func (p *securityEventPersister) Pseudonymise(userId uuid.UUID, pseudonym uuid.UUID) error {
+	for _, column := range []string{"actor_user_id", "surrogate_user_id", "target_user_id"} {
+		query := fmt.Sprintf("UPDATE security_events SET %s = ?, client_ip_address = ?, client_user_agent = ? WHERE %s = ?", column, column)
+		err := p.db.RawQuery(query, pseudonym, models.Redacted, models.Redacted, userId).Exec()
+		if err != nil {
+			return fmt.Errorf("failed to pseudonymise security events by %s: %w", column, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package persistence
+
+import (
+	"testing"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+func TestSecurityEventPersister_SearchQuery_EnclosesUserCondition(t *testing.T) {
+	userId := uuid.Must(uuid.NewV4())
+	query := (&securityEventPersister{db: newConnection(t)}).searchQuery(SecurityEventFilter{UserId: &userId, Types: []string{models.EventRoleAssigned}})
+	sql, args := query.ToSQL(&pop.Model{Value: &[]models.SecurityEvent{}})
+
+	assert.Contains(t, sql, "WHERE (actor_user_id = $1 OR surrogate_user_id = $2 OR target_user_id = $3) AND type IN ($4)")
+	assert.Equal(t, []interface{}{userId, userId, userId, models.EventRoleAssigned}, args)
+}
@@ -0,0 +1,84 @@
+package persistence
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+type TotpCredentialPersister interface {
+	Create(credential models.TotpCredential) error
+	GetByUserId(userId uuid.UUID) (*models.TotpCredential, error)
+	Update(credential models.TotpCredential) error
+	// ClaimStep records the time step of a used code, it returns false if the step or a later one has already been used
+	ClaimStep(id uuid.UUID, step int64, updatedAt time.Time) (bool, error)
+	Delete(credential models.TotpCredential) error
+}
+
+type totpCredentialPersister struct {
+	db *pop.Connection
+}
+
+func NewTotpCredentialPersister(db *pop.Connection) TotpCredentialPersister {
+	return &totpCredentialPersister{db: db}
+}
+
+func (p *totpCredentialPersister) Create(credential models.TotpCredential) error {
+	vErr, err := p.db.ValidateAndCreate(&credential)
+	if err != nil {
+		return fmt.Errorf("failed to store totp credential: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("totp credential object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *totpCredentialPersister) GetByUserId(userId uuid.UUID) (*models.TotpCredential, error) {
+	credential := models.TotpCredential{}
+	err := p.db.Where("user_id = ?", userId).First(&credential)
+	if err != nil && err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get totp credential: %w", err)
+	}
+	return &credential, nil
+}
+
+func (p *totpCredentialPersister) Update(credential models.TotpCredential) error {
+	vErr, err := p.db.ValidateAndUpdate(&credential)
+	if err != nil {
+		return fmt.Errorf("failed to update totp credential: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("totp credential object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *totpCredentialPersister) ClaimStep(id uuid.UUID, step int64, updatedAt time.Time) (bool, error) {
+	count, err := p.db.RawQuery("UPDATE totp_credentials SET last_used_step = ?, updated_at = ? WHERE id = ? AND last_used_step < ?", step, updatedAt, id, step).ExecWithCount()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim totp step: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+func (p *totpCredentialPersister) Delete(credential models.TotpCredential) error {
+	err := p.db.Destroy(&credential)
+	if err != nil {
+		return fmt.Errorf("failed to delete totp credential: %w", err)
+	}
+
+	return nil
+}
@@ -14,6 +14,8 @@ type UserGuestRelationPersister interface {
 	Update(model models.UserGuestRelation) error
 	GetByGuestUserId(guestUserId *uuid.UUID) ([]models.UserGuestRelation, error)
 	GetByParentUserId(parentUserId *uuid.UUID) ([]models.UserGuestRelation, error)
+	// FindByUserId returns the active and inactive relations of the user, as guest and as account holder
+	FindByUserId(userId uuid.UUID) ([]models.UserGuestRelation, error)
 }
 
 type userGuestRelationPersister struct {
@@ -81,3 +83,12 @@ func (p *userGuestRelationPersister) GetByParentUserId(parentUserId *uuid.UUID)
 	}
 	return models, nil
 }
+
+func (p *userGuestRelationPersister) FindByUserId(userId uuid.UUID) ([]models.UserGuestRelation, error) {
+	models := []models.UserGuestRelation{}
+	err := p.db.Where("guest_user_id = ? OR parent_user_id = ?", userId, userId).Order("created_at asc").All(&models)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve user guest relations by user id: %w", err)
+	}
+	return models, nil
+}
@@ -6,8 +6,35 @@ import (
 	"github.com/gobuffalo/pop/v6"
 	"github.com/gofrs/uuid"
 	"github.com/teamhanko/hanko/backend/persistence/models"
+	"strings"
+	"time"
 )
 
+const (
+	UserSortCreatedAt = "created_at"
+	UserSortUpdatedAt = "updated_at"
+	UserSortEmail     = "email"
+)
+
+// UserFilter restricts and orders the users returned by Search. Fields which are not set don't restrict the result.
+type UserFilter struct {
+	// Email matches users with an address containing the value, case-insensitive
+	Email    string
+	Verified *bool
+	Active   *bool
+	// Admin matches users with at least one role
+	Admin       *bool
+	HasPasskey  *bool
+	HasPassword *bool
+	// HasActiveGuestRelations matches users with an active relation, either as account holder or as guest
+	HasActiveGuestRelations *bool
+	CreatedAfter            *time.Time
+	CreatedBefore           *time.Time
+	// SortBy is one of UserSortCreatedAt (default), UserSortUpdatedAt and UserSortEmail
+	SortBy   string
+	SortDesc bool
+}
+
 type UserPersister interface {
 	Get(uuid.UUID) (*models.User, error)
 	GetByEmail(email string) (*models.User, error)
@@ -15,6 +42,10 @@ type UserPersister interface {
 	Update(models.User) error
 	Delete(models.User) error
 	List(page int, perPage int) ([]models.User, error)
+	// Search returns a page of the users matching the filter and the number of all matching users
+	Search(filter UserFilter, page int, perPage int) ([]models.User, int, error)
+	// FindDeletionDue returns the users whose scheduled deletion is due at the given time
+	FindDeletionDue(now time.Time) ([]models.User, error)
 }
 
 type userPersister struct {
@@ -40,7 +71,9 @@ func (p *userPersister) Get(id uuid.UUID) (*models.User, error) {
 
 func (p *userPersister) GetByEmail(email string) (*models.User, error) {
 	user := models.User{}
-	query := p.db.Eager().Where("email = (?)", email)
+	// the email column holds the primary address, all other addresses are in the emails table. Secondary addresses only
+	// count once they are verified, otherwise anybody could claim the address of somebody else.
+	query := p.db.Eager().Where("email = (?) OR id IN (SELECT user_id FROM emails WHERE address = (?) AND verified = (?))", email, email, true)
 	err := query.First(&user)
 	if err != nil && err == sql.ErrNoRows {
 		return nil, nil
@@ -90,10 +123,89 @@ func (p *userPersister) Delete(user models.User) error {
 func (p *userPersister) List(page int, perPage int) ([]models.User, error) {
 	users := []models.User{}
 
-	err := p.db.Q().Paginate(page, perPage).All(&users)
+	err := p.db.Q().Order("created_at asc, id asc").Paginate(page, perPage).All(&users)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch users: %w", err)
 	}
 
 	return users, nil
 }
+
+func (p *userPersister) Search(filter UserFilter, page int, perPage int) ([]models.User, int, error) {
+	users := []models.User{}
+
+	query := p.searchQuery(filter).Paginate(page, perPage)
+	err := query.All(&users)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search users: %w", err)
+	}
+
+	return users, query.Paginator.TotalEntriesSize, nil
+}
+
+// searchQuery returns the query for the users matching the filter, sorted as requested. The page still has to be set.
+func (p *userPersister) searchQuery(filter UserFilter) *pop.Query {
+	query := p.db.Q()
+	if filter.Email != "" {
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(filter.Email)) + "%"
+		// pop joins the conditions with AND without parentheses, so the OR has to be enclosed
+		query = query.Where("(LOWER(email) LIKE ? OR id IN (SELECT user_id FROM emails WHERE LOWER(address) LIKE ?))", pattern, pattern)
+	}
+	if filter.Verified != nil {
+		query = query.Where("verified = ?", *filter.Verified)
+	}
+	if filter.Active != nil {
+		query = query.Where("is_active = ?", *filter.Active)
+	}
+	if filter.Admin != nil {
+		query = query.Where(existsCondition(*filter.Admin, "SELECT 1 FROM user_roles WHERE user_roles.user_id = users.id"))
+	}
+	if filter.HasPasskey != nil {
+		query = query.Where(existsCondition(*filter.HasPasskey, "SELECT 1 FROM webauthn_credentials WHERE webauthn_credentials.user_id = users.id"))
+	}
+	if filter.HasPassword != nil {
+		query = query.Where(existsCondition(*filter.HasPassword, "SELECT 1 FROM password_credentials WHERE password_credentials.user_id = users.id"))
+	}
+	if filter.HasActiveGuestRelations != nil {
+		query = query.Where(existsCondition(*filter.HasActiveGuestRelations, "SELECT 1 FROM user_guest_relations WHERE (user_guest_relations.parent_user_id = users.id OR user_guest_relations.guest_user_id = users.id) AND user_guest_relations.is_active = true"))
+	}
+	if filter.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *filter.CreatedBefore)
+	}
+
+	// the sort column is never taken from the filter directly, it must be one of the known columns
+	column := UserSortCreatedAt
+	switch filter.SortBy {
+	case UserSortUpdatedAt, UserSortEmail:
+		column = filter.SortBy
+	}
+	direction := "asc"
+	if filter.SortDesc {
+		direction = "desc"
+	}
+
+	return query.Order(fmt.Sprintf("%s %s, id %s", column, direction, direction))
+}
+
+var likeEscaper = strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+
+func existsCondition(present bool, subquery string) string {
+	if present {
+		return "EXISTS (" + subquery + ")"
+	}
+	return "NOT EXISTS (" + subquery + ")"
+}
+
+func (p *userPersister) FindDeletionDue(now time.Time) ([]models.User, error) {
+	users := []models.User{}
+
+	err := p.db.Where("deletion_scheduled_at <= ?", now).All(&users)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch users due for deletion: %w", err)
+	}
+
+	return users, nil
+}
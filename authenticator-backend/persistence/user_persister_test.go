@@ -0,0 +1,26 @@
+package persistence
+
+import (
+	"testing"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+// newConnection returns a connection which is never opened, it is only used to build queries
+func newConnection(t *testing.T) *pop.Connection {
+	db, err := pop.NewConnection(&pop.ConnectionDetails{Dialect: "postgres", Host: "localhost", Port: "5432", Database: "hanko", User: "hanko"})
+	require.NoError(t, err)
+	return db
+}
+
+func TestUserPersister_SearchQuery_EnclosesEmailCondition(t *testing.T) {
+	verified := true
+	query := (&userPersister{db: newConnection(t)}).searchQuery(UserFilter{Email: "john", Verified: &verified})
+	sql, args := query.ToSQL(&pop.Model{Value: &[]models.User{}})
+
+	assert.Contains(t, sql, "WHERE (LOWER(email) LIKE $1 OR id IN (SELECT user_id FROM emails WHERE LOWER(address) LIKE $2)) AND verified = $3")
+	assert.Equal(t, []interface{}{"%john%", "%john%", true}, args)
+}
@@ -0,0 +1,278 @@
+package persistence
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+// WebhookDeliveryFilter restricts the listed deliveries of an endpoint, empty fields match all deliveries
+type WebhookDeliveryFilter struct {
+	Status string
+	From   *time.Time
+	To     *time.Time
+}
+
+type WebhookPersister interface {
+	CreateEndpoint(endpoint models.WebhookEndpoint) error
+	GetEndpoint(id uuid.UUID) (*models.WebhookEndpoint, error)
+	ListEndpoints() ([]models.WebhookEndpoint, error)
+	UpdateEndpoint(endpoint models.WebhookEndpoint) error
+	DeleteEndpoint(endpoint models.WebhookEndpoint) error
+
+	// CreateEvent adds the event to the outbox, it must be called in the transaction of the change it announces
+	CreateEvent(event models.WebhookEvent) error
+	GetEvent(id uuid.UUID) (*models.WebhookEvent, error)
+	// ListUndispatchedEvents returns up to limit events which were not fanned out into deliveries yet, oldest first
+	ListUndispatchedEvents(limit int) ([]models.WebhookEvent, error)
+	// ClaimEvent marks the event as dispatched. It returns false, if the event was dispatched already.
+	ClaimEvent(id uuid.UUID, dispatchedAt time.Time) (bool, error)
+	// DeleteEventsBefore removes the dispatched events created before the time together with their deliveries
+	DeleteEventsBefore(createdAt time.Time) (int, error)
+
+	CreateDelivery(delivery models.WebhookDelivery) error
+	GetDelivery(id uuid.UUID) (*models.WebhookDelivery, error)
+	UpdateDelivery(delivery models.WebhookDelivery) error
+	// ListDueDeliveries returns up to limit pending deliveries to active endpoints whose next attempt is due
+	ListDueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error)
+	// ClaimDelivery moves the next attempt of the delivery from the expected time to the given time, so that no other
+	// instance attempts it concurrently. It returns false, if the delivery was claimed by another instance.
+	ClaimDelivery(id uuid.UUID, expected time.Time, nextAttemptAt time.Time) (bool, error)
+	// ListDeliveries returns a page of the deliveries to the endpoint, newest first, and the number of all matching
+	// deliveries
+	ListDeliveries(endpointId uuid.UUID, filter WebhookDeliveryFilter, page int, perPage int) ([]models.WebhookDelivery, int, error)
+	// ReplayDeliveries resets the matching deliveries of the endpoint, so they are attempted again, and returns how
+	// many were reset
+	ReplayDeliveries(endpointId uuid.UUID, filter WebhookDeliveryFilter, now time.Time) (int, error)
+}
+
+type webhookPersister struct {
+	db *pop.Connection
+}
+
+func NewWebhookPersister(db *pop.Connection) WebhookPersister {
+	return &webhookPersister{db: db}
+}
+
+func (p *webhookPersister) CreateEndpoint(endpoint models.WebhookEndpoint) error {
+	vErr, err := p.db.ValidateAndCreate(&endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to store webhook endpoint: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("webhook endpoint object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *webhookPersister) GetEndpoint(id uuid.UUID) (*models.WebhookEndpoint, error) {
+	endpoint := models.WebhookEndpoint{}
+	err := p.db.Find(&endpoint, id)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook endpoint: %w", err)
+	}
+
+	return &endpoint, nil
+}
+
+func (p *webhookPersister) ListEndpoints() ([]models.WebhookEndpoint, error) {
+	endpoints := []models.WebhookEndpoint{}
+	err := p.db.Order("created_at asc").All(&endpoints)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+
+	return endpoints, nil
+}
+
+func (p *webhookPersister) UpdateEndpoint(endpoint models.WebhookEndpoint) error {
+	vErr, err := p.db.ValidateAndUpdate(&endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook endpoint: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("webhook endpoint object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *webhookPersister) DeleteEndpoint(endpoint models.WebhookEndpoint) error {
+	err := p.db.Destroy(&endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook endpoint: %w", err)
+	}
+
+	return nil
+}
+
+func (p *webhookPersister) CreateEvent(event models.WebhookEvent) error {
+	vErr, err := p.db.ValidateAndCreate(&event)
+	if err != nil {
+		return fmt.Errorf("failed to store webhook event: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("webhook event object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *webhookPersister) GetEvent(id uuid.UUID) (*models.WebhookEvent, error) {
+	event := models.WebhookEvent{}
+	err := p.db.Find(&event, id)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook event: %w", err)
+	}
+
+	return &event, nil
+}
+
+func (p *webhookPersister) ListUndispatchedEvents(limit int) ([]models.WebhookEvent, error) {
+	events := []models.WebhookEvent{}
+	err := p.db.Where("dispatched_at IS NULL").Order("created_at asc").Limit(limit).All(&events)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list undispatched webhook events: %w", err)
+	}
+
+	return events, nil
+}
+
+func (p *webhookPersister) ClaimEvent(id uuid.UUID, dispatchedAt time.Time) (bool, error) {
+	count, err := p.db.RawQuery("UPDATE webhook_events SET dispatched_at = ?, updated_at = ? WHERE id = ? AND dispatched_at IS NULL", dispatchedAt, dispatchedAt, id).ExecWithCount()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim webhook event: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+func (p *webhookPersister) DeleteEventsBefore(createdAt time.Time) (int, error) {
+	count, err := p.db.RawQuery("DELETE FROM webhook_events WHERE created_at < ? AND dispatched_at IS NOT NULL", createdAt).ExecWithCount()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete webhook events: %w", err)
+	}
+
+	return count, nil
+}
+
+func (p *webhookPersister) CreateDelivery(delivery models.WebhookDelivery) error {
+	vErr, err := p.db.ValidateAndCreate(&delivery)
+	if err != nil {
+		return fmt.Errorf("failed to store webhook delivery: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("webhook delivery object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *webhookPersister) GetDelivery(id uuid.UUID) (*models.WebhookDelivery, error) {
+	delivery := models.WebhookDelivery{}
+	err := p.db.Find(&delivery, id)
+	if err != nil && errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+
+	return &delivery, nil
+}
+
+func (p *webhookPersister) UpdateDelivery(delivery models.WebhookDelivery) error {
+	vErr, err := p.db.ValidateAndUpdate(&delivery)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+
+	if vErr != nil && vErr.HasAny() {
+		return fmt.Errorf("webhook delivery object validation failed: %w", vErr)
+	}
+
+	return nil
+}
+
+func (p *webhookPersister) ListDueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	deliveries := []models.WebhookDelivery{}
+	err := p.db.Q().
+		Join("webhook_endpoints", "webhook_endpoints.id = webhook_deliveries.endpoint_id").
+		Where("webhook_deliveries.status = ? AND webhook_deliveries.next_attempt_at <= ? AND webhook_endpoints.is_active = ?", models.WebhookDeliveryPending, now, true).
+		Order("webhook_deliveries.next_attempt_at asc").
+		Limit(limit).
+		All(&deliveries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (p *webhookPersister) ClaimDelivery(id uuid.UUID, expected time.Time, nextAttemptAt time.Time) (bool, error) {
+	count, err := p.db.RawQuery("UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ? AND status = ? AND next_attempt_at = ?", nextAttemptAt, id, models.WebhookDeliveryPending, expected).ExecWithCount()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim webhook delivery: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+func (p *webhookPersister) deliveriesQuery(endpointId uuid.UUID, filter WebhookDeliveryFilter) *pop.Query {
+	query := p.db.Where("endpoint_id = ?", endpointId)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	return query
+}
+
+func (p *webhookPersister) ListDeliveries(endpointId uuid.UUID, filter WebhookDeliveryFilter, page int, perPage int) ([]models.WebhookDelivery, int, error) {
+	deliveries := []models.WebhookDelivery{}
+	query := p.deliveriesQuery(endpointId, filter).Order("created_at desc, id desc").Paginate(page, perPage)
+	err := query.All(&deliveries)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+
+	return deliveries, query.Paginator.TotalEntriesSize, nil
+}
+
+func (p *webhookPersister) ReplayDeliveries(endpointId uuid.UUID, filter WebhookDeliveryFilter, now time.Time) (int, error) {
+	deliveries := []models.WebhookDelivery{}
+	err := p.deliveriesQuery(endpointId, filter).All(&deliveries)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+
+	for _, delivery := range deliveries {
+		delivery.Replay(now)
+		err = p.UpdateDelivery(delivery)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(deliveries), nil
+}
@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/persistence"
+)
+
+// Limiter applies an exponential backoff to the attempts of an action. Attempts are counted per key, e.g. a user ID,
+// an email address or a client IP. A nil Limiter allows everything, so callers don't need to check whether rate
+// limiting is enabled.
+type Limiter struct {
+	store    Store
+	action   string
+	attempts int
+	delay    time.Duration
+	maxDelay time.Duration
+	now      func() time.Time
+}
+
+// New returns a limiter for the action with the store configured, or nil if rate limiting is disabled
+func New(cfg config.RateLimit, action string, policy config.RateLimitPolicy, persister persistence.Persister) *Limiter {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	return NewLimiter(NewStore(cfg, persister), action, policy)
+}
+
+// NewStore returns the store configured, regardless of whether rate limiting is enabled. It is used by limiters which
+// must not be disabled, e.g. the attempts of a second factor.
+func NewStore(cfg config.RateLimit, persister persistence.Persister) Store {
+	if cfg.Store == config.RateLimitStoreDatabase {
+		return NewDatabaseStore(persister.GetRateLimitPersister())
+	}
+	return NewMemoryStore()
+}
+
+func NewLimiter(store Store, action string, policy config.RateLimitPolicy) *Limiter {
+	delay, _ := time.ParseDuration(policy.Delay)       // error can be ignored, value is checked in config validation
+	maxDelay, _ := time.ParseDuration(policy.MaxDelay) // error can be ignored, value is checked in config validation
+	return &Limiter{
+		store:    store,
+		action:   action,
+		attempts: policy.Attempts,
+		delay:    delay,
+		maxDelay: maxDelay,
+		now:      time.Now,
+	}
+}
+
+// Check returns how long the caller has to wait before the next attempt is allowed. The longest delay of all keys is
+// returned, zero means the attempt is allowed.
+func (l *Limiter) Check(keys ...string) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+
+	now := l.now()
+	var retryAfter time.Duration
+	for _, key := range keys {
+		entry, err := l.store.Get(l.key(key))
+		if err != nil {
+			return 0, fmt.Errorf("failed to get rate limit entry: %w", err)
+		}
+		if entry != nil && entry.BlockedUntil.After(now) && entry.BlockedUntil.Sub(now) > retryAfter {
+			retryAfter = entry.BlockedUntil.Sub(now)
+		}
+	}
+
+	return retryAfter, nil
+}
+
+// Register counts an attempt for all keys. Once the allowed attempts are used up, every further attempt doubles the
+// delay until the next attempt is allowed.
+func (l *Limiter) Register(keys ...string) error {
+	if l == nil {
+		return nil
+	}
+
+	now := l.now()
+	for _, key := range keys {
+		attempts, err := l.store.Increment(l.key(key), now, now.Add(l.maxDelay))
+		if err != nil {
+			return fmt.Errorf("failed to count rate limit attempt: %w", err)
+		}
+
+		if attempts >= l.attempts {
+			blockedUntil := now.Add(l.backoff(attempts - l.attempts))
+			err = l.store.Block(l.key(key), blockedUntil, blockedUntil.Add(l.maxDelay))
+			if err != nil {
+				return fmt.Errorf("failed to block rate limit key: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Reset forgets the attempts of all keys, e.g. after a successful login
+func (l *Limiter) Reset(keys ...string) error {
+	if l == nil {
+		return nil
+	}
+
+	for _, key := range keys {
+		err := l.store.Delete(l.key(key))
+		if err != nil {
+			return fmt.Errorf("failed to delete rate limit entry: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// backoff returns the delay after the n-th attempt exceeding the allowed attempts, starting with n = 0
+func (l *Limiter) backoff(n int) time.Duration {
+	delay := l.delay
+	for i := 0; i < n; i++ {
+		delay *= 2
+		if delay >= l.maxDelay {
+			return l.maxDelay
+		}
+	}
+	return delay
+}
+
+func (l *Limiter) key(key string) string {
+	return l.action + ":" + key
+}
@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+var testPolicy = config.RateLimitPolicy{
+	Attempts: 3,
+	Delay:    "1s",
+	MaxDelay: "10s",
+}
+
+func newTestLimiter(store Store) (*Limiter, *time.Time) {
+	now := time.Now()
+	limiter := NewLimiter(store, "test", testPolicy)
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func TestLimiter_Backoff(t *testing.T) {
+	stores := map[string]Store{
+		"memory":   NewMemoryStore(),
+		"database": NewDatabaseStore(test.NewRateLimitPersister(nil)),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			limiter, now := newTestLimiter(store)
+
+			for i := 0; i < 2; i++ {
+				require.NoError(t, limiter.Register("user:1"))
+				retryAfter, err := limiter.Check("user:1")
+				require.NoError(t, err)
+				assert.Zero(t, retryAfter)
+			}
+
+			expected := []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+			for _, delay := range expected {
+				require.NoError(t, limiter.Register("user:1"))
+				retryAfter, err := limiter.Check("user:1")
+				require.NoError(t, err)
+				assert.Equal(t, delay, retryAfter)
+
+				*now = now.Add(delay)
+				retryAfter, err = limiter.Check("user:1")
+				require.NoError(t, err)
+				assert.Zero(t, retryAfter)
+			}
+		})
+	}
+}
+
+func TestLimiter_Register_CountsConcurrentAttempts(t *testing.T) {
+	stores := map[string]Store{
+		"memory":   NewMemoryStore(),
+		"database": NewDatabaseStore(test.NewRateLimitPersister(nil)),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			limiter, _ := newTestLimiter(store)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					assert.NoError(t, limiter.Register("user:1"))
+				}()
+			}
+			wg.Wait()
+
+			entry, err := store.Get("test:user:1")
+			require.NoError(t, err)
+			require.NotNil(t, entry)
+			assert.Equal(t, 50, entry.Attempts)
+			assert.True(t, entry.BlockedUntil.After(time.Now()))
+		})
+	}
+}
+
+func TestLimiter_Check_ReturnsLongestDelayOfAllKeys(t *testing.T) {
+	limiter, _ := newTestLimiter(NewMemoryStore())
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, limiter.Register("ip:127.0.0.1"))
+	}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, limiter.Register("user:1"))
+	}
+
+	retryAfter, err := limiter.Check("user:1", "ip:127.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, retryAfter)
+
+	retryAfter, err = limiter.Check("user:2")
+	require.NoError(t, err)
+	assert.Zero(t, retryAfter)
+}
+
+func TestLimiter_Reset(t *testing.T) {
+	limiter, _ := newTestLimiter(NewMemoryStore())
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, limiter.Register("user:1"))
+	}
+	require.NoError(t, limiter.Reset("user:1"))
+
+	retryAfter, err := limiter.Check("user:1")
+	require.NoError(t, err)
+	assert.Zero(t, retryAfter)
+}
+
+func TestLimiter_KeysAreScopedByAction(t *testing.T) {
+	store := NewMemoryStore()
+	limiter, _ := newTestLimiter(store)
+	other := NewLimiter(store, "other", testPolicy)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, limiter.Register("user:1"))
+	}
+
+	retryAfter, err := other.Check("user:1")
+	require.NoError(t, err)
+	assert.Zero(t, retryAfter)
+}
+
+func TestLimiter_Nil(t *testing.T) {
+	limiter := New(config.RateLimit{Enabled: false}, "test", testPolicy, nil)
+	assert.Nil(t, limiter)
+
+	assert.NoError(t, limiter.Register("user:1"))
+	assert.NoError(t, limiter.Reset("user:1"))
+	retryAfter, err := limiter.Check("user:1")
+	assert.NoError(t, err)
+	assert.Zero(t, retryAfter)
+}
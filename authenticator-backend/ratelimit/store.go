@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/teamhanko/hanko/backend/persistence"
+)
+
+// Entry are the attempts counted for a key
+type Entry struct {
+	Attempts     int
+	BlockedUntil time.Time
+	// ExpiresAt is the time after which the attempts are forgotten
+	ExpiresAt time.Time
+}
+
+// Store keeps the entries of the limiter. Expired entries must not be returned. Attempts must be counted atomically, as
+// the same key can be registered concurrently, possibly by several instances.
+type Store interface {
+	Get(key string) (*Entry, error)
+	// Increment counts an attempt and returns the attempts counted, including this one. The attempts of an expired
+	// entry are forgotten. The entry expires at expiresAt at the earliest.
+	Increment(key string, now time.Time, expiresAt time.Time) (int, error)
+	// Block blocks the key until blockedUntil, unless it is blocked for longer already. The entry expires at expiresAt
+	// at the earliest.
+	Block(key string, blockedUntil time.Time, expiresAt time.Time) error
+	Delete(key string) error
+}
+
+type memoryStore struct {
+	mutex     sync.Mutex
+	entries   map[string]Entry
+	lastSweep time.Time
+}
+
+// NewMemoryStore returns a store which keeps the entries in memory, so they are not shared between instances
+func NewMemoryStore() Store {
+	return &memoryStore{entries: make(map[string]Entry)}
+}
+
+func (s *memoryStore) Get(key string) (*Entry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || entry.ExpiresAt.Before(time.Now()) {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func (s *memoryStore) Increment(key string, now time.Time, expiresAt time.Time) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.sweep()
+
+	entry, ok := s.entries[key]
+	if !ok || entry.ExpiresAt.Before(now) {
+		entry = Entry{}
+	}
+	entry.Attempts++
+	if entry.ExpiresAt.Before(expiresAt) {
+		entry.ExpiresAt = expiresAt
+	}
+	s.entries[key] = entry
+	return entry.Attempts, nil
+}
+
+func (s *memoryStore) Block(key string, blockedUntil time.Time, expiresAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry := s.entries[key]
+	if entry.BlockedUntil.Before(blockedUntil) {
+		entry.BlockedUntil = blockedUntil
+	}
+	if entry.ExpiresAt.Before(expiresAt) {
+		entry.ExpiresAt = expiresAt
+	}
+	s.entries[key] = entry
+	return nil
+}
+
+// sweep removes expired entries at most once a minute, the caller must hold the mutex
+func (s *memoryStore) sweep() {
+	now := time.Now()
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, e := range s.entries {
+			if e.ExpiresAt.Before(now) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+}
+
+func (s *memoryStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+type databaseStore struct {
+	persister persistence.RateLimitPersister
+}
+
+// NewDatabaseStore returns a store which keeps the entries in the database, so they are shared by all instances
+func NewDatabaseStore(persister persistence.RateLimitPersister) Store {
+	return &databaseStore{persister: persister}
+}
+
+func (s *databaseStore) Get(key string) (*Entry, error) {
+	limit, err := s.persister.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if limit == nil || limit.ExpiresAt.Before(time.Now().UTC()) {
+		return nil, nil
+	}
+	return &Entry{Attempts: limit.Attempts, BlockedUntil: limit.BlockedUntil, ExpiresAt: limit.ExpiresAt}, nil
+}
+
+func (s *databaseStore) Increment(key string, now time.Time, expiresAt time.Time) (int, error) {
+	return s.persister.Increment(key, now.UTC(), expiresAt.UTC())
+}
+
+func (s *databaseStore) Block(key string, blockedUntil time.Time, expiresAt time.Time) error {
+	return s.persister.Block(key, blockedUntil.UTC(), expiresAt.UTC())
+}
+
+func (s *databaseStore) Delete(key string) error {
+	return s.persister.Delete(key)
+}
@@ -0,0 +1,199 @@
+package resource
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/actor"
+)
+
+// The visibilities of a resource
+const (
+	// VisibilityPrivate resources are read by the account holder only
+	VisibilityPrivate = "private"
+	// VisibilityGuests resources are read by the account holder and the guests with the read scope of the type
+	VisibilityGuests = "guests"
+	// VisibilityPublic resources are read by every user
+	VisibilityPublic = "public"
+)
+
+var Visibilities = []string{VisibilityPrivate, VisibilityGuests, VisibilityPublic}
+
+// IsVisibility returns whether the visibility is known
+func IsVisibility(visibility string) bool {
+	return contains(Visibilities, visibility)
+}
+
+var (
+	ErrNotFound  = errors.New("resource not found")
+	ErrForbidden = errors.New("resource may not be changed by the actor")
+)
+
+// Resource is a record of an account which guests act on. The account holder owns the resources of the account,
+// including the ones guests wrote on behalf of the account holder.
+type Resource interface {
+	actor.Attributable
+	ResourceType() Type
+	ResourceId() uuid.UUID
+	OwnerId() uuid.UUID
+	// UpdatedBy returns the account and the user who actually changed the resource last
+	UpdatedBy() (userId uuid.UUID, surrogateId uuid.UUID)
+	ResourceVisibility() string
+	IsDeleted() bool
+}
+
+// Versioned resources count up their version with every change, the previous versions are kept by the Store
+type Versioned interface {
+	Resource
+	ResourceVersion() int
+	// SetResourceVersion sets the version and the time it was written
+	SetResourceVersion(version int, at time.Time)
+	// MarkDeleted deletes the resource, deleted resources are kept with their versions
+	MarkDeleted()
+}
+
+// Store persists the resources of a type. Each change is made with a store of the transaction of the change.
+type Store interface {
+	// Get returns the resource, or nil if there is none with the id
+	Get(id uuid.UUID) (Versioned, error)
+	// GetForUpdate returns the resource like Get and locks it until the end of the transaction, so that concurrent
+	// changes are made one after the other and each writes its own version
+	GetForUpdate(id uuid.UUID) (Versioned, error)
+	Create(r Versioned) error
+	Update(r Versioned) error
+	// CreateVersion keeps the resource as it is before a change, replacedAt is the time of the change
+	CreateVersion(previous Versioned, replacedAt time.Time) error
+	// ListVersions returns the previous versions of the resource, oldest first
+	ListVersions(id uuid.UUID) ([]Versioned, error)
+}
+
+// OwnVisibilities returns the visibilities of the resources of the account the actor may read. Together with
+// OtherVisibilities it is the rule of Accessible, queries listing the resources an actor may read follow both.
+func OwnVisibilities(a actor.Actor, t Type) []string {
+	if !a.IsGuest() {
+		return Visibilities
+	}
+	if a.HasScope(t.ReadScope) {
+		return []string{VisibilityGuests, VisibilityPublic}
+	}
+	return []string{}
+}
+
+// OtherVisibilities returns the visibilities of the resources of other accounts the actor may read. Guests need the
+// read scope of the type for them as well.
+func OtherVisibilities(a actor.Actor, t Type) []string {
+	if a.HasScope(t.ReadScope) {
+		return []string{VisibilityPublic}
+	}
+	return []string{}
+}
+
+// Accessible returns whether the actor may read the resource, regardless of whether it has been deleted
+func Accessible(a actor.Actor, r Resource) bool {
+	if r.OwnerId() != a.PrincipalId {
+		return contains(OtherVisibilities(a, r.ResourceType()), r.ResourceVisibility())
+	}
+	return contains(OwnVisibilities(a, r.ResourceType()), r.ResourceVisibility())
+}
+
+// Visible returns whether the actor may read the resource
+func Visible(a actor.Actor, r Resource) bool {
+	return !r.IsDeleted() && Accessible(a, r)
+}
+
+// Editable returns whether the actor may change or delete the resource. Resources are only changed within the account
+// they were written in, guests never change private resources.
+func Editable(a actor.Actor, r Resource) bool {
+	return Visible(a, r) && r.OwnerId() == a.PrincipalId && a.HasScope(r.ResourceType().WriteScope)
+}
+
+// MayPublish returns whether the actor may give a resource the visibility. Guests don't write private resources, as
+// they couldn't read them afterwards.
+func MayPublish(a actor.Actor, visibility string) bool {
+	return !a.IsGuest() || visibility != VisibilityPrivate
+}
+
+// Create stores the new resource as the first version, written by the actor on behalf of the account
+func Create(store Store, a actor.Actor, r Versioned, now time.Time) error {
+	if !a.HasScope(r.ResourceType().WriteScope) || !MayPublish(a, r.ResourceVisibility()) {
+		return ErrForbidden
+	}
+
+	a.AttributeCreation(r)
+	r.SetResourceVersion(1, now)
+	return store.Create(r)
+}
+
+// Change applies the change to the resource and stores it as the next version, written by the actor. The previous
+// version is kept. It returns ErrNotFound if the actor may not read the resource and ErrForbidden if the actor may not
+// change it. The store has to be one of a transaction, which is rolled back on errors.
+func Change(store Store, a actor.Actor, id uuid.UUID, now time.Time, change func(r Versioned)) (Versioned, error) {
+	r, err := store.GetForUpdate(id)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil || !Visible(a, r) {
+		return nil, ErrNotFound
+	}
+	if !Editable(a, r) {
+		return nil, ErrForbidden
+	}
+
+	err = store.CreateVersion(r, now)
+	if err != nil {
+		return nil, err
+	}
+
+	change(r)
+	if !MayPublish(a, r.ResourceVisibility()) {
+		return nil, ErrForbidden
+	}
+	r.SetResourceVersion(r.ResourceVersion()+1, now)
+	a.AttributeUpdate(r)
+
+	err = store.Update(r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Delete marks the resource as deleted. The deletion is a version of its own, so it is known who deleted a resource.
+func Delete(store Store, a actor.Actor, id uuid.UUID, now time.Time) (Versioned, error) {
+	return Change(store, a, id, now, func(r Versioned) {
+		r.MarkDeleted()
+	})
+}
+
+// Versions returns the versions of the resource the actor may read, oldest first and the current version last. The
+// versions are kept within the account, only the account holder and the guests who may read the resource get them.
+// Earlier versions with a visibility the actor may not read, e.g. before a private resource was shared with the
+// guests, are left out.
+func Versions(store Store, a actor.Actor, id uuid.UUID) ([]Versioned, error) {
+	r, err := store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil || r.OwnerId() != a.PrincipalId || !Accessible(a, r) {
+		return nil, ErrNotFound
+	}
+
+	previous, err := store.ListVersions(id)
+	if err != nil {
+		return nil, err
+	}
+	versions := []Versioned{}
+	for _, version := range previous {
+		if Accessible(a, version) {
+			versions = append(versions, version)
+		}
+	}
+	return append(versions, r), nil
+}
+
+// ChangedByGuest returns whether a guest wrote the version on behalf of the account holder
+func ChangedByGuest(r Resource) bool {
+	userId, surrogateId := r.UpdatedBy()
+	return userId != surrogateId
+}
@@ -0,0 +1,267 @@
+package resource
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/actor"
+)
+
+var (
+	holderId   = uuid.FromStringOrNil("b5dd5267-b462-48be-b70d-bcd6f1bbe7a5")
+	guestId    = uuid.FromStringOrNil("6e1c4c5b-0d7c-4e58-9c43-9f5b0b1d2e3f")
+	otherId    = uuid.FromStringOrNil("3d8f0c2a-51b7-4c1e-8f7e-2a9b6c4d1e05")
+	relationId = uuid.FromStringOrNil("0f6d6a4e-7c38-4f6e-9a55-3b1c1a0d2f11")
+)
+
+var noteType = Register(Type{Name: "note", ReadScope: "notes:read", WriteScope: "notes:write"})
+
+type note struct {
+	id, ownerId, ownerSurrogateId, updatedBy, updatedBySurrogate uuid.UUID
+	text, visibility                                             string
+	deleted                                                      bool
+	version                                                      int
+	updatedAt                                                    time.Time
+}
+
+func (n *note) SetCreatedBy(userId uuid.UUID, surrogateId uuid.UUID) {
+	n.ownerId, n.ownerSurrogateId = userId, surrogateId
+}
+
+func (n *note) SetUpdatedBy(userId uuid.UUID, surrogateId uuid.UUID) {
+	n.updatedBy, n.updatedBySurrogate = userId, surrogateId
+}
+
+func (n *note) ResourceType() Type                { return noteType }
+func (n *note) ResourceId() uuid.UUID             { return n.id }
+func (n *note) OwnerId() uuid.UUID                { return n.ownerId }
+func (n *note) UpdatedBy() (uuid.UUID, uuid.UUID) { return n.updatedBy, n.updatedBySurrogate }
+func (n *note) ResourceVisibility() string        { return n.visibility }
+func (n *note) IsDeleted() bool                   { return n.deleted }
+func (n *note) ResourceVersion() int              { return n.version }
+func (n *note) MarkDeleted()                      { n.deleted = true }
+
+func (n *note) SetResourceVersion(version int, at time.Time) {
+	n.version, n.updatedAt = version, at
+}
+
+type noteStore struct {
+	notes    map[uuid.UUID]note
+	versions []note
+	// locked are the ids of the notes read for a change
+	locked []uuid.UUID
+}
+
+func newNoteStore() *noteStore {
+	return &noteStore{notes: map[uuid.UUID]note{}}
+}
+
+func (s *noteStore) Get(id uuid.UUID) (Versioned, error) {
+	n, ok := s.notes[id]
+	if !ok {
+		return nil, nil
+	}
+	return &n, nil
+}
+
+func (s *noteStore) GetForUpdate(id uuid.UUID) (Versioned, error) {
+	s.locked = append(s.locked, id)
+	return s.Get(id)
+}
+
+func (s *noteStore) Create(r Versioned) error {
+	s.notes[r.ResourceId()] = *r.(*note)
+	return nil
+}
+
+func (s *noteStore) Update(r Versioned) error {
+	s.notes[r.ResourceId()] = *r.(*note)
+	return nil
+}
+
+func (s *noteStore) CreateVersion(previous Versioned, _ time.Time) error {
+	s.versions = append(s.versions, *previous.(*note))
+	return nil
+}
+
+func (s *noteStore) ListVersions(id uuid.UUID) ([]Versioned, error) {
+	versions := []Versioned{}
+	for _, n := range s.versions {
+		if n.id == id {
+			version := n
+			versions = append(versions, &version)
+		}
+	}
+	return versions, nil
+}
+
+func holder() actor.Actor {
+	return actor.Actor{PrincipalId: holderId, SurrogateId: holderId, Scopes: []string{}}
+}
+
+func guest(scopes ...string) actor.Actor {
+	relation := relationId
+	return actor.Actor{PrincipalId: holderId, SurrogateId: guestId, RelationId: &relation, Scopes: scopes}
+}
+
+func other() actor.Actor {
+	return actor.Actor{PrincipalId: otherId, SurrogateId: otherId, Scopes: []string{}}
+}
+
+func createNote(t *testing.T, store *noteStore, visibility string) *note {
+	n := &note{id: uuid.Must(uuid.NewV4()), text: "hello", visibility: visibility}
+	require.NoError(t, Create(store, holder(), n, time.Now().UTC()))
+	return n
+}
+
+func TestRegister(t *testing.T) {
+	assert.Contains(t, Types(), noteType)
+	assert.Contains(t, Scopes(), "notes:read")
+	assert.Contains(t, Scopes(), "notes:write")
+	assert.True(t, IsScope("notes:write"))
+	assert.False(t, IsScope("notes:delete"))
+
+	assert.Panics(t, func() {
+		Register(Type{Name: "note", ReadScope: "notes:read", WriteScope: "notes:write"})
+	})
+	assert.Panics(t, func() {
+		Register(Type{Name: "draft", ReadScope: "drafts:read"})
+	})
+}
+
+func TestAccess(t *testing.T) {
+	tests := []struct {
+		name     string
+		actor    actor.Actor
+		readable []string
+		editable []string
+	}{
+		{name: "account holder", actor: holder(), readable: Visibilities, editable: Visibilities},
+		{name: "guest", actor: guest(noteType.ReadScope, noteType.WriteScope), readable: []string{VisibilityGuests, VisibilityPublic}, editable: []string{VisibilityGuests, VisibilityPublic}},
+		{name: "guest reading only", actor: guest(noteType.ReadScope), readable: []string{VisibilityGuests, VisibilityPublic}, editable: []string{}},
+		{name: "guest without scopes", actor: guest(), readable: []string{}, editable: []string{}},
+		{name: "other user", actor: other(), readable: []string{VisibilityPublic}, editable: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, visibility := range Visibilities {
+				n := &note{id: uuid.Must(uuid.NewV4()), ownerId: holderId, visibility: visibility}
+				assert.Equal(t, contains(tt.readable, visibility), Visible(tt.actor, n), visibility)
+				assert.Equal(t, contains(tt.editable, visibility), Editable(tt.actor, n), visibility)
+
+				n.deleted = true
+				assert.False(t, Visible(tt.actor, n), visibility)
+				assert.Equal(t, contains(tt.readable, visibility), Accessible(tt.actor, n), visibility)
+			}
+		})
+	}
+}
+
+func TestChange(t *testing.T) {
+	store := newNoteStore()
+	n := createNote(t, store, VisibilityGuests)
+	assert.Equal(t, 1, n.version)
+	assert.Equal(t, holderId, n.ownerSurrogateId)
+
+	changed, err := Change(store, guest(noteType.ReadScope, noteType.WriteScope), n.id, time.Now().UTC(), func(r Versioned) {
+		r.(*note).text = "changed by the guest"
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, changed.ResourceVersion())
+	assert.True(t, ChangedByGuest(changed))
+	assert.Equal(t, holderId, changed.OwnerId())
+	assert.Equal(t, "changed by the guest", store.notes[n.id].text)
+	assert.Equal(t, []uuid.UUID{n.id}, store.locked)
+
+	if assert.Len(t, store.versions, 1) {
+		assert.Equal(t, "hello", store.versions[0].text)
+		assert.Equal(t, 1, store.versions[0].version)
+		assert.False(t, ChangedByGuest(&store.versions[0]))
+	}
+}
+
+func TestChange_Denied(t *testing.T) {
+	store := newNoteStore()
+	private := createNote(t, store, VisibilityPrivate)
+	public := createNote(t, store, VisibilityPublic)
+	writer := guest(noteType.ReadScope, noteType.WriteScope)
+
+	tests := []struct {
+		name     string
+		actor    actor.Actor
+		id       uuid.UUID
+		change   func(r Versioned)
+		expected error
+	}{
+		{name: "unknown resource", actor: holder(), id: uuid.Must(uuid.NewV4()), expected: ErrNotFound},
+		{name: "guest on private resource", actor: writer, id: private.id, expected: ErrNotFound},
+		{name: "guest reading only", actor: guest(noteType.ReadScope), id: public.id, expected: ErrForbidden},
+		{name: "other user on public resource", actor: other(), id: public.id, expected: ErrForbidden},
+		{name: "guest making resource private", actor: writer, id: public.id, change: func(r Versioned) {
+			r.(*note).visibility = VisibilityPrivate
+		}, expected: ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := tt.change
+			if change == nil {
+				change = func(r Versioned) {}
+			}
+			_, err := Change(store, tt.actor, tt.id, time.Now().UTC(), change)
+			assert.ErrorIs(t, err, tt.expected)
+		})
+	}
+	assert.Equal(t, 1, store.notes[public.id].version)
+	assert.Equal(t, VisibilityPublic, store.notes[public.id].visibility)
+}
+
+func TestDeleteAndVersions(t *testing.T) {
+	store := newNoteStore()
+	n := createNote(t, store, VisibilityGuests)
+
+	_, err := Delete(store, holder(), n.id, time.Now().UTC())
+	require.NoError(t, err)
+	assert.True(t, store.notes[n.id].deleted)
+
+	_, err = Delete(store, holder(), n.id, time.Now().UTC())
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	versions, err := Versions(store, guest(noteType.ReadScope), n.id)
+	require.NoError(t, err)
+	if assert.Len(t, versions, 2) {
+		assert.False(t, versions[0].IsDeleted())
+		assert.True(t, versions[1].IsDeleted())
+		assert.Equal(t, 2, versions[1].ResourceVersion())
+	}
+
+	// the versions are kept within the account
+	_, err = Versions(store, other(), n.id)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestVersions_LeavesOutInaccessibleVersions(t *testing.T) {
+	store := newNoteStore()
+	n := createNote(t, store, VisibilityPrivate)
+	_, err := Change(store, holder(), n.id, time.Now().UTC(), func(r Versioned) {
+		r.(*note).visibility = VisibilityGuests
+	})
+	require.NoError(t, err)
+
+	versions, err := Versions(store, guest(noteType.ReadScope), n.id)
+	require.NoError(t, err)
+	if assert.Len(t, versions, 1) {
+		assert.Equal(t, 2, versions[0].ResourceVersion())
+	}
+
+	versions, err = Versions(store, holder(), n.id)
+	require.NoError(t, err)
+	assert.Len(t, versions, 2)
+
+	// reading the versions doesn't lock the resource, only the change did
+	assert.Equal(t, []uuid.UUID{n.id}, store.locked)
+}
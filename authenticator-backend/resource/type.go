@@ -0,0 +1,76 @@
+package resource
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Type is a kind of resource which guests act on. Guests read and change the resources of the account holder within
+// the scopes of their guest relation.
+type Type struct {
+	// Name identifies the type, e.g. in the activities of guests
+	Name string
+	// ReadScope is the scope guests need to read the resources of the account holder
+	ReadScope string
+	// WriteScope is the scope guests need to write, change and delete the resources of the account holder
+	WriteScope string
+}
+
+var (
+	mu    sync.RWMutex
+	types []Type
+)
+
+// Register makes the type known, the account holders can grant the scopes of all registered types to their guests.
+// Register is meant to be called when a package is initialised and panics if the type is incomplete or its name is
+// taken.
+func Register(t Type) Type {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if t.Name == "" || t.ReadScope == "" || t.WriteScope == "" {
+		panic("resource: a type needs a name, a read and a write scope")
+	}
+	for _, registered := range types {
+		if registered.Name == t.Name {
+			panic(fmt.Sprintf("resource: type %s is registered twice", t.Name))
+		}
+	}
+	types = append(types, t)
+	return t
+}
+
+// Types returns the registered types in the order they were registered
+func Types() []Type {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]Type{}, types...)
+}
+
+// Scopes returns the scopes of all registered types. A guest relation has all scopes unless the account holder
+// restricted them when sharing the account.
+func Scopes() []string {
+	scopes := []string{}
+	for _, t := range Types() {
+		for _, scope := range []string{t.ReadScope, t.WriteScope} {
+			if !contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
+
+// IsScope returns whether the scope belongs to a registered type
+func IsScope(scope string) bool {
+	return contains(Scopes(), scope)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -9,13 +9,16 @@ import (
 	"github.com/teamhanko/hanko/backend/dto"
 	"github.com/teamhanko/hanko/backend/handler"
 	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
 	hankoMiddleware "github.com/teamhanko/hanko/backend/server/middleware"
 	"github.com/teamhanko/hanko/backend/session"
+	"time"
 )
 
 func NewPrivateRouter(cfg *config.Config, persister persistence.Persister) *echo.Echo {
 	e := echo.New()
 	e.HideBanner = true
+	e.IPExtractor = newIPExtractor(cfg.Server.Private)
 
 	e.HTTPErrorHandler = dto.NewHTTPErrorHandler(dto.HTTPErrorHandlerConfig{Debug: false, Logger: e.Logger})
 	e.Use(middleware.RequestID())
@@ -30,6 +33,9 @@ func NewPrivateRouter(cfg *config.Config, persister persistence.Persister) *echo
 		panic(fmt.Errorf("failed to create session generator: %w", err))
 	}
 
+	stepUpMaxAge, _ := time.ParseDuration(cfg.Session.StepUp.MaxAge) // error can be ignored, value is checked in config validation
+	stepUp := hankoMiddleware.StepUp(stepUpMaxAge)
+
 	e.Validator = dto.NewCustomValidator()
 
 	healthHandler := handler.NewHealthHandler()
@@ -39,12 +45,31 @@ func NewPrivateRouter(cfg *config.Config, persister persistence.Persister) *echo
 	health.GET("/ready", healthHandler.Ready)
 
 	userHandler := handler.NewUserHandlerAdmin(persister)
+	roleHandler := handler.NewRoleHandlerAdmin(persister)
+	securityEventHandler := handler.NewSecurityEventHandlerAdmin(persister)
+	webhookHandler := handler.NewWebhookHandlerAdmin(persister)
 
 	user := e.Group("/users")
-	user.DELETE("/:id", userHandler.Delete, hankoMiddleware.Session(sessionManager))
-	user.PATCH("/:id", userHandler.Patch, hankoMiddleware.Session(sessionManager))
-	user.GET("", userHandler.List, hankoMiddleware.Session(sessionManager))
-	user.POST("/login-audit", userHandler.GetLoginAuditRecordsForUser, hankoMiddleware.Session(sessionManager))
+	user.DELETE("/:id", userHandler.Delete, hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionUsersDelete), stepUp)
+	user.PATCH("/:id", userHandler.Patch, hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionUsersWrite), stepUp)
+	user.GET("", userHandler.List, hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionUsersRead))
+	user.POST("/login-audit", userHandler.GetLoginAuditRecordsForUser, hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionAuditRead))
+	user.GET("/:id/roles", roleHandler.ListForUser, hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionUsersRead))
+	user.PUT("/:id/roles/:role", roleHandler.Assign, hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionRolesManage), stepUp)
+	user.DELETE("/:id/roles/:role", roleHandler.Remove, hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionRolesManage), stepUp)
+
+	e.GET("/roles", roleHandler.List, hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionUsersRead))
+	e.GET("/security-events", securityEventHandler.List, hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionAuditRead))
+
+	webhooks := e.Group("/webhooks", hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionWebhooksManage))
+	webhooks.GET("", webhookHandler.List)
+	webhooks.POST("", webhookHandler.Create, stepUp)
+	webhooks.GET("/:id", webhookHandler.Get)
+	webhooks.PATCH("/:id", webhookHandler.Update, stepUp)
+	webhooks.DELETE("/:id", webhookHandler.Delete, stepUp)
+	webhooks.GET("/:id/deliveries", webhookHandler.ListDeliveries)
+	webhooks.POST("/:id/deliveries/:delivery_id/replay", webhookHandler.ReplayDelivery)
+	webhooks.POST("/:id/replay", webhookHandler.Replay)
 
 	return e
 }
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	hankoJwt "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+// Permission is a middleware which requires the user of the session to be active and to have a role with the
+// permission. Guests never get the permissions of the account they are logged in to. It must be registered after the
+// Session middleware.
+func Permission(persister persistence.Persister, permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			sessionToken, ok := c.Get("session").(jwt.Token)
+			if !ok {
+				return dto.NewHTTPError(http.StatusUnauthorized)
+			}
+
+			surrogateId, err := hankoJwt.GetSurrogateKeyFromToken(sessionToken)
+			if err != nil {
+				return dto.NewHTTPError(http.StatusUnauthorized).SetInternal(fmt.Errorf("unable to get surrogate ID from token: %w", err))
+			}
+
+			if sessionToken.Subject() != surrogateId {
+				return dto.NewHTTPError(http.StatusForbidden)
+			}
+
+			userId := uuid.FromStringOrNil(sessionToken.Subject())
+			user, err := persister.GetUserPersister().Get(userId)
+			if err != nil {
+				return fmt.Errorf("failed to get user: %w", err)
+			}
+			if user == nil {
+				return dto.NewHTTPError(http.StatusNotFound).SetInternal(fmt.Errorf("unable to find user id %s", sessionToken.Subject()))
+			}
+
+			if !user.IsActive {
+				return dto.NewHTTPError(http.StatusForbidden)
+			}
+
+			roles, err := persister.GetRolePersister().FindByUserId(userId)
+			if err != nil {
+				return fmt.Errorf("failed to get roles: %w", err)
+			}
+
+			if !models.HasPermission(roles, permission) {
+				return dto.NewHTTPError(http.StatusForbidden).SetInternal(fmt.Errorf("user %s lacks the permission %s", userId, permission))
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	hankoJwt "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+var (
+	adminId = uuid.FromStringOrNil("6bc3a580-d922-42f3-9032-a4faf8faef5e")
+	guestId = uuid.FromStringOrNil("40ac1f81-4d2d-4bf0-bc24-a0f6c067f171")
+)
+
+func TestPermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		role       string
+		active     bool
+		surrogate  uuid.UUID
+		permission string
+		expected   int
+	}{
+		{name: "role with permission", role: models.RoleSuperAdmin, active: true, surrogate: adminId, permission: models.PermissionRolesManage, expected: http.StatusOK},
+		{name: "support can read", role: models.RoleSupport, active: true, surrogate: adminId, permission: models.PermissionAuditRead, expected: http.StatusOK},
+		{name: "support can't write", role: models.RoleSupport, active: true, surrogate: adminId, permission: models.PermissionUsersWrite, expected: http.StatusForbidden},
+		{name: "user-admin can't manage roles", role: models.RoleUserAdmin, active: true, surrogate: adminId, permission: models.PermissionRolesManage, expected: http.StatusForbidden},
+		{name: "no role", active: true, surrogate: adminId, permission: models.PermissionUsersRead, expected: http.StatusForbidden},
+		{name: "inactive user", role: models.RoleSuperAdmin, active: false, surrogate: adminId, permission: models.PermissionUsersRead, expected: http.StatusForbidden},
+		{name: "guest of an admin", role: models.RoleSuperAdmin, active: true, surrogate: guestId, permission: models.PermissionUsersRead, expected: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			persister := test.NewPersister([]models.User{{ID: adminId, Email: "admin@example.com", IsActive: tt.active}}, nil, nil, nil, nil, nil, nil, nil, nil)
+			if tt.role != "" {
+				grantRole(t, persister, adminId, tt.role)
+			}
+
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+			c.Set("session", sessionToken(t, adminId, tt.surrogate))
+
+			next := func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			}
+			err := Permission(persister, tt.permission)(next)(c)
+			if tt.expected == http.StatusOK {
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			} else if assert.Error(t, err) {
+				assert.Equal(t, tt.expected, dto.ToHttpError(err).Code)
+			}
+		})
+	}
+}
+
+func TestPermission_UnknownUser(t *testing.T) {
+	persister := test.NewPersister(nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	c.Set("session", sessionToken(t, adminId, adminId))
+
+	err := Permission(persister, models.PermissionUsersRead)(func(c echo.Context) error { return nil })(c)
+	if assert.Error(t, err) {
+		assert.Equal(t, http.StatusNotFound, dto.ToHttpError(err).Code)
+	}
+}
+
+func sessionToken(t *testing.T, subject uuid.UUID, surrogate uuid.UUID) jwt.Token {
+	token := jwt.New()
+	require.NoError(t, token.Set(jwt.SubjectKey, subject.String()))
+	require.NoError(t, token.Set(hankoJwt.SurrogateKey, surrogate.String()))
+	return token
+}
+
+func grantRole(t *testing.T, persister persistence.Persister, userId uuid.UUID, name string) {
+	role, err := persister.GetRolePersister().GetByName(name)
+	require.NoError(t, err)
+	require.NoError(t, persister.GetRolePersister().AddToUser(userId, role.ID))
+}
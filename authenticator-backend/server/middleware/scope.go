@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/teamhanko/hanko/backend/actor"
+	"github.com/teamhanko/hanko/backend/dto"
+)
+
+// Scope is a middleware which requires guests to have the scope on the account they are logged in to. Account holders
+// have all scopes on their own account. It must be registered after the Session middleware.
+func Scope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			a, ok := actor.FromContext(c.Request().Context())
+			if !ok {
+				return dto.NewHTTPError(http.StatusUnauthorized)
+			}
+
+			if !a.HasScope(scope) {
+				return dto.NewHTTPError(http.StatusForbidden).SetInternal(fmt.Errorf("guest relation %s lacks the scope %s", a.RelationId, scope))
+			}
+
+			return next(c)
+		}
+	}
+}
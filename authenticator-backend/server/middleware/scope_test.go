@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/teamhanko/hanko/backend/actor"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+func TestScope(t *testing.T) {
+	relationId := uuid.FromStringOrNil("0f6d6a4e-7c38-4f6e-9a55-3b1c1a0d2f11")
+
+	tests := []struct {
+		name     string
+		actor    *actor.Actor
+		expected int
+	}{
+		{name: "account holder", actor: &actor.Actor{PrincipalId: adminId, SurrogateId: adminId}, expected: http.StatusOK},
+		{name: "guest with scope", actor: &actor.Actor{PrincipalId: adminId, SurrogateId: guestId, RelationId: &relationId, Scopes: []string{models.ScopePostsRead, models.ScopePostsWrite}}, expected: http.StatusOK},
+		{name: "guest without scope", actor: &actor.Actor{PrincipalId: adminId, SurrogateId: guestId, RelationId: &relationId, Scopes: []string{models.ScopePostsRead}}, expected: http.StatusForbidden},
+		{name: "no session", expected: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/posts", nil)
+			if tt.actor != nil {
+				req = req.WithContext(actor.NewContext(req.Context(), *tt.actor))
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			next := func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			}
+			err := Scope(models.ScopePostsWrite)(next)(c)
+			if tt.expected == http.StatusOK {
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			} else if assert.Error(t, err) {
+				assert.Equal(t, tt.expected, dto.ToHttpError(err).Code)
+			}
+		})
+	}
+}
@@ -1,20 +1,67 @@
 package middleware
 
 import (
+	"fmt"
+	"net/http"
+
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/teamhanko/hanko/backend/actor"
+	hankoJwt "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/dto"
 	"github.com/teamhanko/hanko/backend/session"
 )
 
-// Session is a convenience function to create a middleware.JWT with custom JWT verification
+// Session is a convenience function to create a middleware.JWT with custom JWT verification. Restricted sessions are
+// rejected.
 func Session(generator session.Manager) echo.MiddlewareFunc {
+	return sessionWithRestrictions(generator)
+}
+
+// RestrictedSession is like Session, but additionally accepts sessions carrying one of the given restrictions. It is
+// used on the routes a user needs to lift the restriction.
+func RestrictedSession(generator session.Manager, allowedRestrictions ...string) echo.MiddlewareFunc {
+	return sessionWithRestrictions(generator, allowedRestrictions...)
+}
+
+func sessionWithRestrictions(generator session.Manager, allowedRestrictions ...string) echo.MiddlewareFunc {
 	c := middleware.JWTConfig{
 		ContextKey:     "session",
 		TokenLookup:    "header:Authorization,cookie:hanko",
 		AuthScheme:     "Bearer",
 		ParseTokenFunc: parseToken(generator),
 	}
-	return middleware.JWTWithConfig(c)
+	jwtMiddleware := middleware.JWTWithConfig(c)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return jwtMiddleware(func(c echo.Context) error {
+			sessionToken, ok := c.Get("session").(jwt.Token)
+			if !ok {
+				return dto.NewHTTPError(http.StatusUnauthorized)
+			}
+
+			// the actor is extracted once, handlers find it in the context of the request
+			a, err := actor.FromToken(sessionToken)
+			if err != nil {
+				return dto.NewHTTPError(http.StatusUnauthorized).SetInternal(err)
+			}
+			c.SetRequest(c.Request().WithContext(actor.NewContext(c.Request().Context(), a)))
+
+			restriction := hankoJwt.GetRestrictionFromToken(sessionToken)
+			if restriction == "" {
+				return next(c)
+			}
+
+			for _, allowed := range allowedRestrictions {
+				if restriction == allowed {
+					return next(c)
+				}
+			}
+
+			return dto.NewHTTPError(http.StatusForbidden, "session is restricted").SetInternal(fmt.Errorf("session restriction %s not allowed", restriction))
+		})
+	}
 }
 
 type ParseTokenFunc = func(auth string, c echo.Context) (interface{}, error)
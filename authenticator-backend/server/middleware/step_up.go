@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/teamhanko/hanko/backend/dto"
+	"github.com/teamhanko/hanko/backend/session"
+)
+
+// StepUp is a middleware which requires the session JWT to carry a step-up authentication that is not older than
+// maxAge. It must be registered after the Session middleware.
+func StepUp(maxAge time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			sessionToken, ok := c.Get("session").(jwt.Token)
+			if !ok {
+				return dto.NewHTTPError(http.StatusUnauthorized)
+			}
+
+			if err := session.VerifyStepUp(sessionToken, maxAge); err != nil {
+				return dto.NewHTTPError(http.StatusForbidden, "step-up authentication required").SetInternal(err)
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	hankoJwt "github.com/teamhanko/hanko/backend/crypto/jwt"
+	"github.com/teamhanko/hanko/backend/dto"
+)
+
+func TestStepUp(t *testing.T) {
+	tests := []struct {
+		name      string
+		surrogate uuid.UUID
+		authTime  time.Time
+		expected  int
+	}{
+		{name: "recent step-up", surrogate: adminId, authTime: time.Now().UTC(), expected: http.StatusOK},
+		{name: "expired step-up", surrogate: adminId, authTime: time.Now().UTC().Add(-10 * time.Minute), expected: http.StatusForbidden},
+		{name: "no step-up", surrogate: adminId, expected: http.StatusForbidden},
+		{name: "guest session", surrogate: guestId, authTime: time.Now().UTC(), expected: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPut, "/password", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			token := jwt.New()
+			require.NoError(t, token.Set(jwt.SubjectKey, adminId.String()))
+			require.NoError(t, token.Set(hankoJwt.SurrogateKey, tt.surrogate.String()))
+			if !tt.authTime.IsZero() {
+				require.NoError(t, token.Set(hankoJwt.AuthTimeKey, tt.authTime.Unix()))
+			}
+			c.Set("session", token)
+
+			next := func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			}
+			err := StepUp(5 * time.Minute)(next)(c)
+			if tt.expected == http.StatusOK {
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusOK, rec.Code)
+			} else if assert.Error(t, err) {
+				assert.Equal(t, tt.expected, dto.ToHttpError(err).Code)
+			}
+		})
+	}
+}
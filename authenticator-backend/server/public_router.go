@@ -10,14 +10,17 @@ import (
 	"github.com/teamhanko/hanko/backend/handler"
 	"github.com/teamhanko/hanko/backend/mail"
 	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
 	hankoMiddleware "github.com/teamhanko/hanko/backend/server/middleware"
 	"github.com/teamhanko/hanko/backend/server/ws"
 	"github.com/teamhanko/hanko/backend/session"
+	"time"
 )
 
 func NewPublicRouter(cfg *config.Config, persister persistence.Persister) *echo.Echo {
 	e := echo.New()
 	e.HideBanner = true
+	e.IPExtractor = newIPExtractor(cfg.Server.Public)
 
 	e.HTTPErrorHandler = dto.NewHTTPErrorHandler(dto.HTTPErrorHandlerConfig{Debug: false, Logger: e.Logger})
 	e.Use(middleware.RequestID())
@@ -45,6 +48,9 @@ func NewPublicRouter(cfg *config.Config, persister persistence.Persister) *echo.
 		panic(fmt.Errorf("failed to create session generator: %w", err))
 	}
 
+	stepUpMaxAge, _ := time.ParseDuration(cfg.Session.StepUp.MaxAge) // error can be ignored, value is checked in config validation
+	stepUp := hankoMiddleware.StepUp(stepUpMaxAge)
+
 	mailer, err := mail.NewMailer(cfg.Passcode.Smtp)
 	if err != nil {
 		panic(fmt.Errorf("failed to create mailer: %w", err))
@@ -56,25 +62,77 @@ func NewPublicRouter(cfg *config.Config, persister persistence.Persister) *echo.
 		password := e.Group("/password")
 		password.PUT("", passwordHandler.Set, hankoMiddleware.Session(sessionManager))
 		password.POST("/login", passwordHandler.Login)
+
+		passwordResetHandler, err := handler.NewPasswordResetHandler(cfg, persister, jwkManager, mailer)
+		if err != nil {
+			panic(fmt.Errorf("failed to create public password reset handler: %w", err))
+		}
+		passwordReset := password.Group("/reset")
+		passwordReset.POST("/initialize", passwordResetHandler.Init)
+		passwordReset.POST("/finalize", passwordResetHandler.Finish)
 	}
 
 	userHandler := handler.NewUserHandler(cfg, persister, sessionManager)
 
-	e.GET("/me", userHandler.Me, hankoMiddleware.Session(sessionManager))
+	restrictedSession := hankoMiddleware.RestrictedSession(sessionManager, session.RestrictionRegisterCredential, session.RestrictionSecondFactor)
+
+	e.GET("/me", userHandler.Me, restrictedSession)
 	e.POST("/login/guest", userHandler.InitiateLoginAsGuest, hankoMiddleware.Session(sessionManager))
 
+	if cfg.LoginAnomalies.Enabled {
+		loginAnomalyHandler, err := handler.NewLoginAnomalyHandler(persister, jwkManager)
+		if err != nil {
+			panic(fmt.Errorf("failed to create public login anomaly handler: %w", err))
+		}
+		e.POST("/login/anomalies/revoke", loginAnomalyHandler.Revoke)
+	}
+
 	user := e.Group("/users")
 	user.POST("", userHandler.Create)
 	user.GET("/:id", userHandler.Get, hankoMiddleware.Session(sessionManager))
-	user.POST("/logout", userHandler.Logout, hankoMiddleware.Session(sessionManager))
+	user.POST("/logout", userHandler.Logout, restrictedSession)
 	user.POST("/logout-guest", userHandler.LogoutAsGuest, hankoMiddleware.Session(sessionManager))
 	user.GET("/shares/overview", userHandler.GetUserGuestRelationsOverview, hankoMiddleware.Session(sessionManager))
 	user.GET("/shares/guest", userHandler.GetUserGuestRelationsAsGuest, hankoMiddleware.Session(sessionManager))
 	user.GET("/shares/parent", userHandler.GetUserGuestRelationsAsAccountHolder, hankoMiddleware.Session(sessionManager))
 	user.DELETE("/shares/:id", userHandler.RemoveAccessToRelation, hankoMiddleware.Session(sessionManager))
 
+	guestLoginHandler, err := handler.NewGuestLoginHandler(cfg, persister, jwkManager)
+	if err != nil {
+		panic(fmt.Errorf("failed to create public guest login handler: %w", err))
+	}
+	user.GET("/shares/notifications", guestLoginHandler.GetNotifications, hankoMiddleware.Session(sessionManager))
+	user.PUT("/shares/notifications", guestLoginHandler.SetNotifications, hankoMiddleware.Session(sessionManager))
+	user.POST("/shares/revoke", guestLoginHandler.Revoke)
+
+	guestActivityHandler := handler.NewGuestActivityHandler(persister)
+	user.GET("/shares/:id/activity", guestActivityHandler.Report, hankoMiddleware.Session(sessionManager))
+
 	e.POST("/user", userHandler.GetUserIdByEmail)
 
+	emailHandler := handler.NewEmailHandler(persister)
+	emails := e.Group("/emails", hankoMiddleware.Session(sessionManager))
+	emails.GET("", emailHandler.List)
+	emails.POST("", emailHandler.Create, stepUp)
+	emails.DELETE("/:id", emailHandler.Delete, stepUp)
+	emails.POST("/:id/set_primary", emailHandler.SetPrimary, stepUp)
+
+	emailChangeHandler, err := handler.NewEmailChangeHandler(cfg, persister, mailer)
+	if err != nil {
+		panic(fmt.Errorf("failed to create public email change handler: %w", err))
+	}
+	emailChange := user.Group("/email", hankoMiddleware.Session(sessionManager))
+	emailChange.POST("/initialize", emailChangeHandler.Init, stepUp)
+	emailChange.POST("/finalize", emailChangeHandler.Finish)
+
+	accountDeletionHandler := handler.NewAccountDeletionHandler(cfg, persister)
+	accountDeletion := user.Group("/deletion", hankoMiddleware.Session(sessionManager))
+	accountDeletion.POST("", accountDeletionHandler.Request, stepUp)
+	accountDeletion.DELETE("", accountDeletionHandler.Cancel)
+
+	accountExportHandler := handler.NewAccountExportHandler(persister)
+	user.GET("/export", accountExportHandler.Export, hankoMiddleware.Session(sessionManager), stepUp)
+
 	healthHandler := handler.NewHealthHandler()
 	webauthnHandler, err := handler.NewWebauthnHandler(cfg, persister, sessionManager)
 	if err != nil {
@@ -84,6 +142,10 @@ func NewPublicRouter(cfg *config.Config, persister persistence.Persister) *echo.
 	if err != nil {
 		panic(fmt.Errorf("failed to create public passcode handler: %w", err))
 	}
+	stepUpHandler, err := handler.NewStepUpHandler(cfg, persister, sessionManager)
+	if err != nil {
+		panic(fmt.Errorf("failed to create public step-up handler: %w", err))
+	}
 	accountSharingHandler, err := handler.NewAccountSharingHandler(cfg, persister, sessionManager, mailer)
 	if err != nil {
 		panic(fmt.Errorf("failed to create public account sharing handler: %w", err))
@@ -106,17 +168,39 @@ func NewPublicRouter(cfg *config.Config, persister persistence.Persister) *echo.
 	wellKnown.GET("/config", wellKnownHandler.GetConfig)
 
 	webauthn := e.Group("/webauthn")
-	webauthnRegistration := webauthn.Group("/registration", hankoMiddleware.Session(sessionManager))
+	webauthnRegistration := webauthn.Group("/registration", hankoMiddleware.RestrictedSession(sessionManager, session.RestrictionRegisterCredential))
 	webauthnRegistration.POST("/initialize", webauthnHandler.BeginRegistration)
 	webauthnRegistration.POST("/finalize", webauthnHandler.FinishRegistration)
 
+	webauthn.DELETE("/credentials/:id", webauthnHandler.DeleteCredential, hankoMiddleware.Session(sessionManager), stepUp)
+
 	webauthnLogin := webauthn.Group("/login")
 	webauthnLogin.POST("/initialize", webauthnHandler.BeginAuthentication)
 	webauthnLogin.POST("/finalize", webauthnHandler.FinishAuthentication)
 
+	recoveryCodeHandler := handler.NewRecoveryCodeHandler(cfg, persister, sessionManager)
+	recovery := e.Group("/recovery")
+	recovery.POST("/codes", recoveryCodeHandler.Generate, hankoMiddleware.Session(sessionManager), stepUp)
+	recovery.POST("/login", recoveryCodeHandler.Login)
+
+	totpHandler, err := handler.NewTotpHandler(cfg, persister, sessionManager)
+	if err != nil {
+		panic(fmt.Errorf("failed to create public totp handler: %w", err))
+	}
+	secondFactorSession := hankoMiddleware.RestrictedSession(sessionManager, session.RestrictionSecondFactor)
+	totpGroup := e.Group("/totp")
+	totpGroup.POST("/enroll/initialize", totpHandler.BeginEnrollment, secondFactorSession)
+	totpGroup.POST("/enroll/finalize", totpHandler.FinishEnrollment, secondFactorSession)
+	totpGroup.POST("/login", totpHandler.Login, secondFactorSession)
+	totpGroup.DELETE("", totpHandler.Delete, hankoMiddleware.Session(sessionManager), stepUp)
+
+	stepUpGroup := e.Group("/step-up", hankoMiddleware.Session(sessionManager))
+	stepUpGroup.POST("/initialize", stepUpHandler.BeginStepUp)
+	stepUpGroup.POST("/finalize", stepUpHandler.FinishStepUp)
+
 	access := e.Group("/access")
 	share := access.Group("/share", hankoMiddleware.Session(sessionManager))
-	share.POST("/initialize", accountSharingHandler.BeginShare)
+	share.POST("/initialize", accountSharingHandler.BeginShare, stepUp)
 	share.POST("/begin-create-account-with-grant", accountSharingHandler.BeginCreateAccountWithGrant)
 	share.POST("/finish-create-account-with-grant", accountSharingHandler.FinishCreateAccountWithGrant)
 
@@ -132,17 +216,28 @@ func NewPublicRouter(cfg *config.Config, persister persistence.Persister) *echo.
 	e.GET("/ws/:id", websocketHandler.WsPage, hankoMiddleware.Session(sessionManager))
 
 	adminHandler := handler.NewUserHandlerAdmin(persister)
+	roleHandler := handler.NewRoleHandlerAdmin(persister)
+	securityEventHandler := handler.NewSecurityEventHandlerAdmin(persister)
 	admin := e.Group("/admin")
-	admin.GET("/users", adminHandler.List, hankoMiddleware.Session(sessionManager))
-	admin.GET("/grants/:id", adminHandler.GetGrantsForUser, hankoMiddleware.Session(sessionManager))
-	admin.POST("/login-audit", adminHandler.GetLoginAuditRecordsForUser, hankoMiddleware.Session(sessionManager))
-	admin.PUT("/users/active/:id", adminHandler.ToggleIsActiveForUser, hankoMiddleware.Session(sessionManager))
-	admin.DELETE("/grants/:id", adminHandler.DeactivateGrantsForUser, hankoMiddleware.Session(sessionManager))
+	admin.GET("/users", adminHandler.List, hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionUsersRead))
+	admin.GET("/grants/:id", adminHandler.GetGrantsForUser, hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionUsersRead))
+	admin.POST("/login-audit", adminHandler.GetLoginAuditRecordsForUser, hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionAuditRead))
+	admin.GET("/security-events", securityEventHandler.List, hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionAuditRead))
+	admin.PUT("/users/active/:id", adminHandler.ToggleIsActiveForUser, hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionUsersWrite), stepUp)
+	admin.DELETE("/grants/:id", adminHandler.DeactivateGrantsForUser, hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionUsersWrite), stepUp)
+	admin.GET("/roles", roleHandler.List, hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionUsersRead))
+	admin.GET("/users/:id/roles", roleHandler.ListForUser, hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionUsersRead))
+	admin.PUT("/users/:id/roles/:role", roleHandler.Assign, hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionRolesManage), stepUp)
+	admin.DELETE("/users/:id/roles/:role", roleHandler.Remove, hankoMiddleware.Session(sessionManager), hankoMiddleware.Permission(persister, models.PermissionRolesManage), stepUp)
 
 	postHandler := handler.NewPostHandler(persister)
 	posts := e.Group("/posts")
-	posts.GET("", postHandler.GetPosts, hankoMiddleware.Session(sessionManager))
-	posts.POST("", postHandler.CreatePost, hankoMiddleware.Session(sessionManager))
+	posts.GET("", postHandler.GetPosts, hankoMiddleware.Session(sessionManager), hankoMiddleware.Scope(models.PostType.ReadScope))
+	posts.POST("", postHandler.CreatePost, hankoMiddleware.Session(sessionManager), hankoMiddleware.Scope(models.PostType.WriteScope))
+	posts.PUT("/:id", postHandler.UpdatePost, hankoMiddleware.Session(sessionManager), hankoMiddleware.Scope(models.PostType.WriteScope))
+	posts.DELETE("/:id", postHandler.DeletePost, hankoMiddleware.Session(sessionManager), hankoMiddleware.Scope(models.PostType.WriteScope))
+	posts.GET("/:id/history", postHandler.GetPostHistory, hankoMiddleware.Session(sessionManager), hankoMiddleware.Scope(models.PostType.ReadScope))
+	user.GET("/:id/posts", postHandler.GetUserPosts, hankoMiddleware.Session(sessionManager), hankoMiddleware.Scope(models.PostType.ReadScope))
 
 	return e
 }
@@ -1,11 +1,37 @@
 package server
 
 import (
+	"github.com/labstack/echo/v4"
+	"github.com/teamhanko/hanko/backend/account"
+	"github.com/teamhanko/hanko/backend/anomaly"
+	"github.com/teamhanko/hanko/backend/audit"
 	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/crypto/jwk"
+	"github.com/teamhanko/hanko/backend/guestlogin"
+	"github.com/teamhanko/hanko/backend/mail"
 	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/webhook"
+	"log"
+	"net"
 	"sync"
+	"time"
 )
 
+// accountDeletionInterval is how often accounts whose deletion grace period has passed are deleted
+const accountDeletionInterval = 10 * time.Minute
+
+// auditCheckpointInterval is how often the head of the login audit chain is signed
+const auditCheckpointInterval = time.Hour
+
+// webhookDispatchInterval is how often the outbox of the webhook events is dispatched and due deliveries are attempted
+const webhookDispatchInterval = 5 * time.Second
+
+// loginAnomalyInterval is how often new logins are compared with the login history
+const loginAnomalyInterval = 30 * time.Second
+
+// guestLoginNotificationInterval is how often the notifications about guest logins are sent
+const guestLoginNotificationInterval = 30 * time.Second
+
 func StartPublic(cfg *config.Config, wg *sync.WaitGroup, persister persistence.Persister) {
 	defer wg.Done()
 	router := NewPublicRouter(cfg, persister)
@@ -17,3 +43,47 @@ func StartPrivate(cfg *config.Config, wg *sync.WaitGroup, persister persistence.
 	router := NewPrivateRouter(cfg, persister)
 	router.Logger.Fatal(router.Start(cfg.Server.Private.Address))
 }
+
+// StartJobs starts the background jobs. They run once per deployment, regardless of whether the public, the private or
+// both servers are started.
+func StartJobs(cfg *config.Config, persister persistence.Persister) {
+	go account.NewDeleter(persister).Run(accountDeletionInterval)
+	jwkManager, err := jwk.NewDefaultManager(cfg.Secrets.Keys, persister.GetJwkPersister())
+	if err != nil {
+		log.Fatalf("failed to create jwk manager: %s", err)
+	}
+	go audit.NewCheckpointer(persister, jwkManager).Run(auditCheckpointInterval)
+	go webhook.NewDispatcher(persister, cfg.Webhooks).Run(webhookDispatchInterval)
+	mailer, err := mail.NewMailer(cfg.Passcode.Smtp)
+	if err != nil {
+		log.Fatalf("failed to create mailer: %s", err)
+	}
+	if cfg.LoginAnomalies.Enabled {
+		detector, err := anomaly.NewDetector(cfg, persister, jwkManager, mailer)
+		if err != nil {
+			log.Fatalf("failed to create login anomaly detector: %s", err)
+		}
+		go detector.Run(loginAnomalyInterval)
+	}
+	// account holders can choose notifications even if they are off by default, so the notifier always runs
+	notifier, err := guestlogin.NewNotifier(cfg, persister, jwkManager, mailer)
+	if err != nil {
+		log.Fatalf("failed to create guest login notifier: %s", err)
+	}
+	go notifier.Run(guestLoginNotificationInterval)
+}
+
+// newIPExtractor returns how the client IP is determined for rate limits, audit logs and security events. Without
+// trusted proxies the address of the connection is used, as the X-Forwarded-For header can be set by any client.
+func newIPExtractor(settings config.ServerSettings) echo.IPExtractor {
+	if len(settings.TrustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range settings.TrustedProxies {
+		_, ipRange, _ := net.ParseCIDR(proxy) // error can be ignored, value is checked in config validation
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
@@ -376,6 +376,18 @@ func handleDenyGrant(grant *models.AccountAccessGrant) {
 			continue
 		}
 
+		// only the account holder can deny the grant, the guest is the target
+		event := models.NewSecurityEvent(models.EventGrantDenied)
+		event.ActorUserId = &grant.UserId
+		event.TargetUserId = &conn3.UserId
+		event.Metadata["grant_id"] = grant.ID.String()
+		if manager.websocketHandler != nil {
+			err := manager.websocketHandler.persister.GetSecurityEventPersister().Create(event)
+			if err != nil {
+				fmt.Println("Failed to create security event: ", err)
+			}
+		}
+
 		conn3.client.send <- jsonMessage
 		close(conn3.client.send)
 		delete(manager.clients, conn3.client)
@@ -8,17 +8,82 @@ import (
 	hankoJwk "github.com/teamhanko/hanko/backend/crypto/jwk"
 	hankoJwt "github.com/teamhanko/hanko/backend/crypto/jwt"
 	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
 	"net/http"
+	"strings"
 	"time"
 )
 
 type Manager interface {
-	GenerateJWT(uuid.UUID, uuid.UUID, uuid.UUID) (string, error)
+	GenerateJWT(uuid.UUID, uuid.UUID, uuid.UUID, ...Option) (string, error)
 	Verify(string) (jwt.Token, error)
 	GenerateCookie(token string) (*http.Cookie, error)
 	DeleteCookie() (*http.Cookie, error)
 }
 
+// Option adds additional claims to a session JWT
+type Option func(token jwt.Token)
+
+// WithAuthenticationMethods stamps the session JWT with the current time as auth_time and the given authentication
+// method references. Routes protected by the step-up middleware require a recent auth_time.
+func WithAuthenticationMethods(methods ...string) Option {
+	return func(token jwt.Token) {
+		_ = token.Set(hankoJwt.AuthTimeKey, time.Now().UTC().Unix())
+		_ = token.Set(hankoJwt.AmrKey, methods)
+	}
+}
+
+// VerifyStepUp checks that the session JWT carries a step-up authentication that is not older than maxAge. Only the
+// account holder can step up: a guest re-authenticates with their own credentials, so a guest session never counts as
+// stepped up, even when it carries an auth_time.
+func VerifyStepUp(token jwt.Token, maxAge time.Duration) error {
+	surrogateId, err := hankoJwt.GetSurrogateKeyFromToken(token)
+	if err != nil {
+		return err
+	}
+	if surrogateId != token.Subject() {
+		return fmt.Errorf("user %s can not step up the session of user %s", surrogateId, token.Subject())
+	}
+
+	authTime, err := hankoJwt.GetAuthTimeFromToken(token)
+	if err != nil {
+		return err
+	}
+	if authTime.Add(maxAge).Before(time.Now().UTC()) {
+		return fmt.Errorf("step-up authentication from %s has expired", authTime)
+	}
+
+	return nil
+}
+
+// Restrictions a session JWT can carry. Restricted sessions are rejected by the session middleware, except on the
+// routes needed to lift the restriction.
+const (
+	RestrictionRegisterCredential = "register_credential"
+	RestrictionSecondFactor       = "second_factor"
+)
+
+// WithLifespan shortens the lifespan of the session JWT, e.g. for restricted sessions which only serve to lift the
+// restriction
+func WithLifespan(lifespan time.Duration) Option {
+	return func(token jwt.Token) {
+		if lifespan <= 0 {
+			return
+		}
+		expiration := token.IssuedAt().Add(lifespan)
+		if expiration.Before(token.Expiration()) {
+			_ = token.Set(jwt.ExpirationKey, expiration)
+		}
+	}
+}
+
+// WithRestriction marks the session JWT as restricted
+func WithRestriction(restriction string) Option {
+	return func(token jwt.Token) {
+		_ = token.Set(hankoJwt.RestrictKey, restriction)
+	}
+}
+
 // Manager is used to create and verify session JWTs
 type manager struct {
 	jwtGenerator  hankoJwt.Generator
@@ -75,7 +140,7 @@ func NewManager(jwkManager hankoJwk.Manager, config config.Session, persister pe
 }
 
 // GenerateJWT creates a new session JWT for the given user
-func (g *manager) GenerateJWT(subjectUserId uuid.UUID, surrogateUserId uuid.UUID, grantId uuid.UUID) (string, error) {
+func (g *manager) GenerateJWT(subjectUserId uuid.UUID, surrogateUserId uuid.UUID, grantId uuid.UUID, opts ...Option) (string, error) {
 	issuedAt := time.Now().UTC()
 	var expiration time.Time
 
@@ -91,6 +156,7 @@ func (g *manager) GenerateJWT(subjectUserId uuid.UUID, surrogateUserId uuid.UUID
 			return "", fmt.Errorf("unable to get user guest relationship: %w", err)
 		}
 		_ = token.Set(hankoJwt.GrantKey, grantId.String())
+		_ = token.Set(hankoJwt.ScopeKey, strings.Join(grant.ScopeList(), " "))
 
 		expiration = issuedAt.Add(g.sessionLength)
 
@@ -106,6 +172,9 @@ func (g *manager) GenerateJWT(subjectUserId uuid.UUID, surrogateUserId uuid.UUID
 		expiration = issuedAt.Add(g.sessionLength)
 	}
 	_ = token.Set(jwt.ExpirationKey, expiration)
+	for _, opt := range opts {
+		opt(token)
+	}
 	//_ = token.Set(jwt.AudienceKey, []string{"http://localhost"})
 
 	signed, err := g.jwtGenerator.Sign(token)
@@ -123,6 +192,10 @@ func (g *manager) Verify(token string) (jwt.Token, error) {
 		return nil, fmt.Errorf("failed to verify session token: %w", err)
 	}
 
+	if purpose := hankoJwt.GetPurposeFromToken(parsedToken); purpose != "" {
+		return nil, fmt.Errorf("token with purpose %s is not a session token", purpose)
+	}
+
 	surrogateId, err := hankoJwt.GetSurrogateKeyFromToken(parsedToken)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get surrogate id from token: %w", err)
@@ -140,6 +213,10 @@ func (g *manager) Verify(token string) (jwt.Token, error) {
 		return nil, fmt.Errorf("user id %s is not active", user.ID)
 	}
 
+	if isRevoked(parsedToken, user) {
+		return nil, fmt.Errorf("sessions of user id %s have been revoked", user.ID)
+	}
+
 	if surrogateId != parsedToken.Subject() {
 		grantId, err := hankoJwt.GetGrantKeyFromToken(parsedToken)
 		if err != nil || grantId == "" {
@@ -161,11 +238,24 @@ func (g *manager) Verify(token string) (jwt.Token, error) {
 		if !guestUser.IsActive {
 			return nil, fmt.Errorf("guest user id %s is no longer active", surrogateId)
 		}
+		if isRevoked(parsedToken, guestUser) {
+			return nil, fmt.Errorf("sessions of guest user id %s have been revoked", surrogateId)
+		}
 	}
 
 	return parsedToken, nil
 }
 
+// isRevoked returns whether the token was issued before the sessions of the user were revoked. The issued at claim only
+// has a precision of seconds, so tokens issued within the second of the revocation are revoked as well, as they could
+// have been issued before it. A login within the same second as e.g. a password reset has to be repeated.
+func isRevoked(token jwt.Token, user *models.User) bool {
+	if user.SessionsRevokedAt == nil {
+		return false
+	}
+	return !token.IssuedAt().After(user.SessionsRevokedAt.Truncate(time.Second))
+}
+
 // GenerateCookie creates a new session cookie for the given user
 func (g *manager) GenerateCookie(token string) (*http.Cookie, error) {
 	return &http.Cookie{
@@ -5,9 +5,11 @@ import (
 	"errors"
 	"github.com/gofrs/uuid"
 	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/teamhanko/hanko/backend/config"
+	hankoJwt "github.com/teamhanko/hanko/backend/crypto/jwt"
 	"github.com/teamhanko/hanko/backend/persistence/models"
 	"github.com/teamhanko/hanko/backend/test"
 	"testing"
@@ -68,6 +70,124 @@ func TestGenerator_Verify(t *testing.T) {
 	assert.True(t, token.IssuedAt().Add(sessionDuration).Equal(token.Expiration()))
 }
 
+func TestGenerator_Verify_WithOptions(t *testing.T) {
+	userId, err := uuid.NewV4()
+	assert.NoError(t, err)
+
+	user := models.User{
+		ID:       userId,
+		IsActive: true,
+	}
+
+	manager := jwkManager{}
+	cfg := config.Session{Lifespan: "5m"}
+	sessionGenerator, err := NewManager(&manager, cfg, test.NewPersister(append([]models.User{}, user), nil, nil, nil, nil, nil, nil, nil, nil))
+	assert.NoError(t, err)
+	require.NotEmpty(t, sessionGenerator)
+
+	session, err := sessionGenerator.GenerateJWT(userId, userId, uuid.Nil, WithAuthenticationMethods(hankoJwt.AmrHardwareKey), WithRestriction(RestrictionRegisterCredential))
+	assert.NoError(t, err)
+	require.NotEmpty(t, session)
+
+	token, err := sessionGenerator.Verify(session)
+	assert.NoError(t, err)
+	require.NotEmpty(t, token)
+
+	authTime, err := hankoJwt.GetAuthTimeFromToken(token)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().UTC(), authTime, time.Minute)
+
+	amr, err := hankoJwt.GetAmrFromToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{hankoJwt.AmrHardwareKey}, amr)
+
+	assert.Equal(t, RestrictionRegisterCredential, hankoJwt.GetRestrictionFromToken(token))
+}
+
+func TestGenerator_GenerateJWT_WithLifespan(t *testing.T) {
+	userId, err := uuid.NewV4()
+	assert.NoError(t, err)
+
+	user := models.User{
+		ID:       userId,
+		IsActive: true,
+	}
+
+	manager := jwkManager{}
+	cfg := config.Session{Lifespan: "1h"}
+	sessionGenerator, err := NewManager(&manager, cfg, test.NewPersister(append([]models.User{}, user), nil, nil, nil, nil, nil, nil, nil, nil))
+	assert.NoError(t, err)
+
+	session, err := sessionGenerator.GenerateJWT(userId, userId, uuid.Nil, WithRestriction(RestrictionSecondFactor), WithLifespan(5*time.Minute))
+	assert.NoError(t, err)
+
+	token, err := sessionGenerator.Verify(session)
+	assert.NoError(t, err)
+	require.NotEmpty(t, token)
+	assert.True(t, token.IssuedAt().Add(5*time.Minute).Equal(token.Expiration()))
+}
+
+func TestGenerator_Verify_Errors_WhenSessionsAreRevoked(t *testing.T) {
+	userId, err := uuid.NewV4()
+	assert.NoError(t, err)
+
+	revokedAt := time.Now().UTC().Add(time.Minute)
+	user := models.User{
+		ID:                userId,
+		IsActive:          true,
+		SessionsRevokedAt: &revokedAt,
+	}
+
+	manager := jwkManager{}
+	cfg := config.Session{Lifespan: "5m"}
+	sessionGenerator, err := NewManager(&manager, cfg, test.NewPersister(append([]models.User{}, user), nil, nil, nil, nil, nil, nil, nil, nil))
+	assert.NoError(t, err)
+
+	session, err := sessionGenerator.GenerateJWT(userId, userId, uuid.Nil)
+	assert.NoError(t, err)
+
+	_, err = sessionGenerator.Verify(session)
+	assert.Error(t, err)
+}
+
+func TestIsRevoked_WithinTheSecondOfTheRevocation(t *testing.T) {
+	second := time.Now().UTC().Truncate(time.Second)
+	revokedAt := second.Add(500 * time.Millisecond)
+	user := &models.User{SessionsRevokedAt: &revokedAt}
+
+	// the issued at claim is serialized in seconds, so a token issued right before the revocation has the same value
+	issuedBefore := jwt.New()
+	require.NoError(t, issuedBefore.Set(jwt.IssuedAtKey, second))
+	assert.True(t, isRevoked(issuedBefore, user))
+
+	issuedAfter := jwt.New()
+	require.NoError(t, issuedAfter.Set(jwt.IssuedAtKey, second.Add(time.Second)))
+	assert.False(t, isRevoked(issuedAfter, user))
+}
+
+func TestGenerator_Verify_Errors_WhenTokenHasAPurpose(t *testing.T) {
+	userId, err := uuid.NewV4()
+	assert.NoError(t, err)
+
+	user := models.User{
+		ID:       userId,
+		IsActive: true,
+	}
+
+	manager := jwkManager{}
+	cfg := config.Session{Lifespan: "5m"}
+	sessionGenerator, err := NewManager(&manager, cfg, test.NewPersister(append([]models.User{}, user), nil, nil, nil, nil, nil, nil, nil, nil))
+	assert.NoError(t, err)
+
+	session, err := sessionGenerator.GenerateJWT(userId, userId, uuid.Nil, func(token jwt.Token) {
+		_ = token.Set(hankoJwt.PurposeKey, "password_reset")
+	})
+	assert.NoError(t, err)
+
+	_, err = sessionGenerator.Verify(session)
+	assert.Error(t, err)
+}
+
 func TestGenerator_Verify_GuestUser(t *testing.T) {
 	userId, err := uuid.NewV4()
 	assert.NoError(t, err)
@@ -89,6 +209,7 @@ func TestGenerator_Verify_GuestUser(t *testing.T) {
 		ParentUserID: userId,
 		GuestUserID:  surrogateId,
 		IsActive:     true,
+		Scopes:       models.ScopePostsRead,
 	}
 
 	sessionLifespan := "5m"
@@ -104,7 +225,9 @@ func TestGenerator_Verify_GuestUser(t *testing.T) {
 
 	token, err := sessionGenerator.Verify(session)
 	assert.NoError(t, err)
-	assert.NotNil(t, token)
+	if assert.NotNil(t, token) {
+		assert.Equal(t, []string{models.ScopePostsRead}, hankoJwt.GetScopesFromToken(token))
+	}
 }
 
 func TestGenerator_Verify_WhenSubjectUserIsInactive_Errors(t *testing.T) {
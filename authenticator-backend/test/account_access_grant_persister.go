@@ -39,3 +39,23 @@ func (p *accessGrantPersister) Update(accessGrant models.AccountAccessGrant) err
 	}
 	return nil
 }
+
+func (p *accessGrantPersister) GetByUserId(userId uuid.UUID) ([]models.AccountAccessGrant, error) {
+	var results []models.AccountAccessGrant
+	for _, data := range p.grants {
+		if data.UserId == userId {
+			results = append(results, data)
+		}
+	}
+	return results, nil
+}
+
+func (p *accessGrantPersister) GetByClaimedBy(userId uuid.UUID) ([]models.AccountAccessGrant, error) {
+	var results []models.AccountAccessGrant
+	for _, data := range p.grants {
+		if data.ClaimedBy != nil && *data.ClaimedBy == userId {
+			results = append(results, data)
+		}
+	}
+	return results, nil
+}
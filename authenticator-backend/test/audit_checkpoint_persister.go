@@ -0,0 +1,38 @@
+package test
+
+import (
+	"sort"
+
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+func NewAuditCheckpointPersister(init []models.AuditCheckpoint) persistence.AuditCheckpointPersister {
+	return &auditCheckpointPersister{append([]models.AuditCheckpoint{}, init...)}
+}
+
+type auditCheckpointPersister struct {
+	checkpoints []models.AuditCheckpoint
+}
+
+func (p *auditCheckpointPersister) Create(checkpoint models.AuditCheckpoint) error {
+	p.checkpoints = append(p.checkpoints, checkpoint)
+	return nil
+}
+
+func (p *auditCheckpointPersister) GetLatest() (*models.AuditCheckpoint, error) {
+	checkpoints, _ := p.List()
+	if len(checkpoints) == 0 {
+		return nil, nil
+	}
+	latest := checkpoints[len(checkpoints)-1]
+	return &latest, nil
+}
+
+func (p *auditCheckpointPersister) List() ([]models.AuditCheckpoint, error) {
+	checkpoints := append([]models.AuditCheckpoint{}, p.checkpoints...)
+	sort.SliceStable(checkpoints, func(i, j int) bool {
+		return checkpoints[i].Sequence < checkpoints[j].Sequence
+	})
+	return checkpoints, nil
+}
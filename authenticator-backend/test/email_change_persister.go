@@ -0,0 +1,64 @@
+package test
+
+import (
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+func NewEmailChangePersister(init []models.EmailChange) persistence.EmailChangePersister {
+	return &emailChangePersister{append([]models.EmailChange{}, init...)}
+}
+
+type emailChangePersister struct {
+	changes []models.EmailChange
+}
+
+func (p *emailChangePersister) Get(id uuid.UUID) (*models.EmailChange, error) {
+	for _, data := range p.changes {
+		if data.ID == id {
+			change := data
+			return &change, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *emailChangePersister) Create(change models.EmailChange) error {
+	p.changes = append(p.changes, change)
+	return nil
+}
+
+func (p *emailChangePersister) Update(change models.EmailChange) error {
+	for i, data := range p.changes {
+		if data.ID == change.ID {
+			p.changes[i] = change
+		}
+	}
+	return nil
+}
+
+func (p *emailChangePersister) Delete(change models.EmailChange) error {
+	index := -1
+	for i, data := range p.changes {
+		if data.ID == change.ID {
+			index = i
+		}
+	}
+	if index > -1 {
+		p.changes = append(p.changes[:index], p.changes[index+1:]...)
+	}
+
+	return nil
+}
+
+func (p *emailChangePersister) DeleteByUserId(userId uuid.UUID) error {
+	var remaining []models.EmailChange
+	for _, data := range p.changes {
+		if data.UserId != userId {
+			remaining = append(remaining, data)
+		}
+	}
+	p.changes = remaining
+	return nil
+}
@@ -0,0 +1,86 @@
+package test
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+func NewEmailPersister(init []models.Email) persistence.EmailPersister {
+	return &emailPersister{append([]models.Email{}, init...)}
+}
+
+type emailPersister struct {
+	emails []models.Email
+}
+
+func (p *emailPersister) Get(id uuid.UUID) (*models.Email, error) {
+	for _, data := range p.emails {
+		if data.ID == id {
+			email := data
+			return &email, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *emailPersister) GetByAddress(address string) (*models.Email, error) {
+	for _, data := range p.emails {
+		if data.Address == address {
+			email := data
+			return &email, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *emailPersister) FindByUserId(userId uuid.UUID) ([]models.Email, error) {
+	var emails []models.Email
+	for _, data := range p.emails {
+		if data.UserId == userId {
+			emails = append(emails, data)
+		}
+	}
+	return emails, nil
+}
+
+func (p *emailPersister) Create(email models.Email) error {
+	p.emails = append(p.emails, email)
+	return nil
+}
+
+func (p *emailPersister) Update(email models.Email) error {
+	for i, data := range p.emails {
+		if data.ID == email.ID {
+			p.emails[i] = email
+		}
+	}
+	return nil
+}
+
+func (p *emailPersister) Delete(email models.Email) error {
+	index := -1
+	for i, data := range p.emails {
+		if data.ID == email.ID {
+			index = i
+		}
+	}
+	if index > -1 {
+		p.emails = append(p.emails[:index], p.emails[index+1:]...)
+	}
+
+	return nil
+}
+
+func (p *emailPersister) DeleteUnverified(address string, createdBefore time.Time) error {
+	var emails []models.Email
+	for _, data := range p.emails {
+		if data.Address != address || data.Verified || data.Primary || !data.CreatedAt.Before(createdBefore) {
+			emails = append(emails, data)
+		}
+	}
+	p.emails = emails
+	return nil
+}
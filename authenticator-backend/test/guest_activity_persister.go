@@ -0,0 +1,60 @@
+package test
+
+import (
+	"sort"
+
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+func NewGuestActivityPersister(init []models.GuestActivity) persistence.GuestActivityPersister {
+	return &guestActivityPersister{activities: append([]models.GuestActivity{}, init...)}
+}
+
+type guestActivityPersister struct {
+	activities []models.GuestActivity
+}
+
+func (p *guestActivityPersister) Create(activity models.GuestActivity) error {
+	p.activities = append(p.activities, activity)
+	return nil
+}
+
+func (p *guestActivityPersister) ListByRelation(relationId uuid.UUID, page int, perPage int) ([]models.GuestActivity, int, error) {
+	var matches []models.GuestActivity
+	for _, activity := range p.activities {
+		if activity.UserGuestRelationId == relationId {
+			matches = append(matches, activity)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].CreatedAt.After(matches[j].CreatedAt)
+	})
+
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 20
+	}
+	start := (page - 1) * perPage
+	if start >= len(matches) {
+		return []models.GuestActivity{}, len(matches), nil
+	}
+	end := start + perPage
+	if end > len(matches) {
+		end = len(matches)
+	}
+	return matches[start:end], len(matches), nil
+}
+
+func (p *guestActivityPersister) PseudonymiseSurrogate(userId uuid.UUID, pseudonym uuid.UUID) error {
+	for i, data := range p.activities {
+		if data.SurrogateUserId == userId {
+			p.activities[i].SurrogateUserId = pseudonym
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package test
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+func NewGuestLoginNotificationPersister(init []models.GuestLoginNotification) persistence.GuestLoginNotificationPersister {
+	return &guestLoginNotificationPersister{notifications: append([]models.GuestLoginNotification{}, init...)}
+}
+
+type guestLoginNotificationPersister struct {
+	notifications []models.GuestLoginNotification
+}
+
+func (p *guestLoginNotificationPersister) Create(notification models.GuestLoginNotification) error {
+	p.notifications = append(p.notifications, notification)
+	return nil
+}
+
+func (p *guestLoginNotificationPersister) sorted() []models.GuestLoginNotification {
+	notifications := append([]models.GuestLoginNotification{}, p.notifications...)
+	sort.SliceStable(notifications, func(i, j int) bool {
+		return notifications[i].LoginAt.Before(notifications[j].LoginAt)
+	})
+	return notifications
+}
+
+func (p *guestLoginNotificationPersister) ListSingle(limit int) ([]models.GuestLoginNotification, error) {
+	var notifications []models.GuestLoginNotification
+	for _, notification := range p.sorted() {
+		if !notification.Digest && len(notifications) < limit {
+			notifications = append(notifications, notification)
+		}
+	}
+	return notifications, nil
+}
+
+func (p *guestLoginNotificationPersister) ListDigestRecipients(loginBefore time.Time, limit int) ([]uuid.UUID, error) {
+	var userIds []uuid.UUID
+	seen := map[uuid.UUID]bool{}
+	for _, notification := range p.sorted() {
+		if !notification.Digest || !notification.LoginAt.Before(loginBefore) || seen[notification.UserId] || len(userIds) >= limit {
+			continue
+		}
+		seen[notification.UserId] = true
+		userIds = append(userIds, notification.UserId)
+	}
+	return userIds, nil
+}
+
+func (p *guestLoginNotificationPersister) ListDigest(userId uuid.UUID) ([]models.GuestLoginNotification, error) {
+	var notifications []models.GuestLoginNotification
+	for _, notification := range p.sorted() {
+		if notification.Digest && notification.UserId == userId {
+			notifications = append(notifications, notification)
+		}
+	}
+	return notifications, nil
+}
+
+func (p *guestLoginNotificationPersister) Claim(id uuid.UUID) (bool, error) {
+	for i, notification := range p.notifications {
+		if notification.ID == id {
+			p.notifications = append(p.notifications[:i], p.notifications[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,77 @@
+package test
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+// NewLoginAnomalyPersister returns a persister with the given anomalies, its cursor starts at the given sequence
+func NewLoginAnomalyPersister(init []models.LoginAnomaly, sequence int) persistence.LoginAnomalyPersister {
+	return &loginAnomalyPersister{
+		anomalies: append([]models.LoginAnomaly{}, init...),
+		cursor:    models.LoginAnomalyCursor{ID: models.LoginAnomalyCursorLoginAuditLogs, Sequence: sequence},
+	}
+}
+
+type loginAnomalyPersister struct {
+	anomalies []models.LoginAnomaly
+	cursor    models.LoginAnomalyCursor
+}
+
+func (p *loginAnomalyPersister) LockCursor() (*models.LoginAnomalyCursor, error) {
+	cursor := p.cursor
+	return &cursor, nil
+}
+
+func (p *loginAnomalyPersister) UpdateCursor(cursor models.LoginAnomalyCursor) error {
+	p.cursor = cursor
+	return nil
+}
+
+func (p *loginAnomalyPersister) Create(anomaly models.LoginAnomaly) error {
+	p.anomalies = append(p.anomalies, anomaly)
+	return nil
+}
+
+func (p *loginAnomalyPersister) Get(id uuid.UUID) (*models.LoginAnomaly, error) {
+	for _, anomaly := range p.anomalies {
+		if anomaly.ID == id {
+			found := anomaly
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *loginAnomalyPersister) Update(anomaly models.LoginAnomaly) error {
+	for i, existing := range p.anomalies {
+		if existing.ID == anomaly.ID {
+			p.anomalies[i] = anomaly
+		}
+	}
+	return nil
+}
+
+func (p *loginAnomalyPersister) ListUnnotified(limit int) ([]models.LoginAnomaly, error) {
+	var anomalies []models.LoginAnomaly
+	for _, anomaly := range p.anomalies {
+		if anomaly.NotifiedAt == nil && len(anomalies) < limit {
+			anomalies = append(anomalies, anomaly)
+		}
+	}
+	return anomalies, nil
+}
+
+func (p *loginAnomalyPersister) ClaimNotification(id uuid.UUID, notifiedAt time.Time) (bool, error) {
+	for i, anomaly := range p.anomalies {
+		if anomaly.ID == id && anomaly.NotifiedAt == nil {
+			p.anomalies[i].NotifiedAt = &notifiedAt
+			p.anomalies[i].UpdatedAt = notifiedAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
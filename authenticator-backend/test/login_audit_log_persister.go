@@ -1,20 +1,40 @@
 package test
 
 import (
+	"sort"
+	"time"
+
 	"github.com/gofrs/uuid"
 	"github.com/teamhanko/hanko/backend/persistence"
 	"github.com/teamhanko/hanko/backend/persistence/models"
 )
 
+// NewLoginAuditLogPersister returns a persister with the given logs, they are not part of the hash chain unless
+// they are linked already
 func NewLoginAuditLogPersister(init []models.LoginAuditLog) persistence.LoginAuditLogPersister {
-	return &loginAuditLogPersister{append([]models.LoginAuditLog{}, init...)}
+	p := &loginAuditLogPersister{logs: append([]models.LoginAuditLog{}, init...)}
+	for _, log := range init {
+		if log.Sequence > p.head.Sequence {
+			p.head = models.AuditChainHead{Sequence: log.Sequence, Hash: log.Hash}
+		}
+	}
+	p.head.ID = models.AuditChainHeadLoginAuditLogs
+	return p
 }
 
 type loginAuditLogPersister struct {
 	logs []models.LoginAuditLog
+	head models.AuditChainHead
 }
 
 func (p *loginAuditLogPersister) Create(log models.LoginAuditLog) error {
+	if log.CreatedAt.IsZero() {
+		log.CreatedAt = time.Now().UTC().Truncate(time.Second)
+		log.UpdatedAt = log.CreatedAt
+	}
+	log.Link(p.head.Sequence, p.head.Hash)
+	p.head.Sequence = log.Sequence
+	p.head.Hash = log.Hash
 	p.logs = append(p.logs, log)
 	return nil
 }
@@ -45,9 +65,81 @@ func (p *loginAuditLogPersister) GetByGuestUserId(uuid uuid.UUID) ([]models.Logi
 func (p *loginAuditLogPersister) GetByGuestUserIdAndGrantId(guestUserId uuid.UUID, grantId uuid.UUID) ([]models.LoginAuditLog, error) {
 	var results []models.LoginAuditLog
 	for _, data := range p.logs {
+		if data.SurrogateUserId == nil || data.UserGuestRelationId == nil {
+			continue
+		}
 		if *data.SurrogateUserId == guestUserId && *data.UserGuestRelationId == grantId {
 			results = append(results, data)
 		}
 	}
 	return results, nil
 }
+
+func (p *loginAuditLogPersister) Pseudonymise(userId uuid.UUID, pseudonym uuid.UUID) error {
+	for i, data := range p.logs {
+		if data.UserId == userId {
+			p.logs[i].UserId = pseudonym
+		} else if data.SurrogateUserId != nil && *data.SurrogateUserId == userId {
+			p.logs[i].SurrogateUserId = &pseudonym
+		} else {
+			continue
+		}
+		p.logs[i].ClientIpAddress = models.Redacted
+		p.logs[i].ClientUserAgent = models.Redacted
+	}
+	return nil
+}
+
+func (p *loginAuditLogPersister) ListChained(afterSequence int, limit int) ([]models.LoginAuditLog, error) {
+	var chained []models.LoginAuditLog
+	for _, log := range p.logs {
+		if log.Sequence > afterSequence {
+			chained = append(chained, log)
+		}
+	}
+	sort.SliceStable(chained, func(i, j int) bool {
+		return chained[i].Sequence < chained[j].Sequence
+	})
+	if len(chained) > limit {
+		chained = chained[:limit]
+	}
+	return chained, nil
+}
+
+func (p *loginAuditLogPersister) CountUnchained() (int, error) {
+	count := 0
+	for _, log := range p.logs {
+		if log.Sequence == 0 {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (p *loginAuditLogPersister) GetChainHead() (*models.AuditChainHead, error) {
+	head := p.head
+	return &head, nil
+}
+
+func (p *loginAuditLogPersister) ListHistory(userId uuid.UUID, surrogateUserId *uuid.UUID, beforeSequence int, limit int) ([]models.LoginAuditLog, error) {
+	var history []models.LoginAuditLog
+	for _, log := range p.logs {
+		if log.UserId != userId || log.Sequence <= 0 || log.Sequence >= beforeSequence {
+			continue
+		}
+		if surrogateUserId == nil && log.SurrogateUserId != nil {
+			continue
+		}
+		if surrogateUserId != nil && (log.SurrogateUserId == nil || *log.SurrogateUserId != *surrogateUserId) {
+			continue
+		}
+		history = append(history, log)
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Sequence > history[j].Sequence
+	})
+	if len(history) > limit {
+		history = history[:limit]
+	}
+	return history, nil
+}
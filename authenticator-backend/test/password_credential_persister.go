@@ -14,12 +14,12 @@ type passwordCredentialPersister struct {
 	passwords []models.PasswordCredential
 }
 
-func (p passwordCredentialPersister) Create(password models.PasswordCredential) error {
+func (p *passwordCredentialPersister) Create(password models.PasswordCredential) error {
 	p.passwords = append(p.passwords, password)
 	return nil
 }
 
-func (p passwordCredentialPersister) GetByUserID(userId uuid.UUID) (*models.PasswordCredential, error) {
+func (p *passwordCredentialPersister) GetByUserID(userId uuid.UUID) (*models.PasswordCredential, error) {
 	var found *models.PasswordCredential
 	for _, data := range p.passwords {
 		if data.UserId == userId {
@@ -30,7 +30,7 @@ func (p passwordCredentialPersister) GetByUserID(userId uuid.UUID) (*models.Pass
 	return found, nil
 }
 
-func (p passwordCredentialPersister) Update(password models.PasswordCredential) error {
+func (p *passwordCredentialPersister) Update(password models.PasswordCredential) error {
 	for i, data := range p.passwords {
 		if data.ID == password.ID {
 			p.passwords[i] = password
@@ -0,0 +1,55 @@
+package test
+
+import (
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+func NewPasswordResetPersister(init []models.PasswordReset) persistence.PasswordResetPersister {
+	return &passwordResetPersister{append([]models.PasswordReset{}, init...)}
+}
+
+type passwordResetPersister struct {
+	resets []models.PasswordReset
+}
+
+func (p *passwordResetPersister) Get(id uuid.UUID) (*models.PasswordReset, error) {
+	for _, data := range p.resets {
+		if data.ID == id {
+			reset := data
+			return &reset, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *passwordResetPersister) Create(reset models.PasswordReset) error {
+	p.resets = append(p.resets, reset)
+	return nil
+}
+
+func (p *passwordResetPersister) Delete(reset models.PasswordReset) error {
+	index := -1
+	for i, data := range p.resets {
+		if data.ID == reset.ID {
+			index = i
+		}
+	}
+	if index > -1 {
+		p.resets = append(p.resets[:index], p.resets[index+1:]...)
+	}
+
+	return nil
+}
+
+func (p *passwordResetPersister) DeleteByUserId(userId uuid.UUID) error {
+	var remaining []models.PasswordReset
+	for _, data := range p.resets {
+		if data.UserId != userId {
+			remaining = append(remaining, data)
+		}
+	}
+	p.resets = remaining
+	return nil
+}
@@ -7,18 +7,37 @@ import (
 )
 
 func NewPersister(user []models.User, passcodes []models.Passcode, jwks []models.Jwk, credentials []models.WebauthnCredential, sessionData []models.WebauthnSessionData, passwords []models.PasswordCredential, accessGrants []models.AccountAccessGrant, userGuestRelations []models.UserGuestRelation, loginAudits []models.LoginAuditLog) persistence.Persister {
+	emailPersister := NewEmailPersister(nil)
+	webauthnCredentialPersister := NewWebauthnCredentialPersister(credentials)
+	passwordCredentialPersister := NewPasswordCredentialPersister(passwords)
+	userGuestRelationPersister := NewUserGuestRelationPersister(userGuestRelations)
+	rolePersister := NewRolePersister(nil)
 	return &persister{
-		userPersister:                          NewUserPersister(user),
+		userPersister:                          newLinkedUserPersister(user, emailPersister, webauthnCredentialPersister, passwordCredentialPersister, userGuestRelationPersister, rolePersister),
 		passcodePersister:                      NewPasscodePersister(passcodes),
 		jwkPersister:                           NewJwkPersister(jwks),
-		webauthnCredentialPersister:            NewWebauthnCredentialPersister(credentials),
+		webauthnCredentialPersister:            webauthnCredentialPersister,
 		webauthnSessionDataPersister:           NewWebauthnSessionDataPersister(sessionData),
-		passwordCredentialPersister:            NewPasswordCredentialPersister(passwords),
+		passwordCredentialPersister:            passwordCredentialPersister,
 		accountAccessGrantPersister:            NewAccountAccessGrantPersister(accessGrants),
-		userGuestRelationPersister:             NewUserGuestRelationPersister(userGuestRelations),
+		userGuestRelationPersister:             userGuestRelationPersister,
 		loginAuditLogPersister:                 NewLoginAuditLogPersister(loginAudits),
 		webauthnCredentialsPrivateKeyPersister: NewWebauthnCredentialsPrivateKeyPersister([]models.WebauthnCredentialsPrivateKey{}),
 		postPersister:                          NewPostPersister(nil),
+		postHistoryPersister:                   NewPostHistoryPersister(nil),
+		recoveryCodePersister:                  NewRecoveryCodePersister(nil),
+		totpCredentialPersister:                NewTotpCredentialPersister(nil),
+		rateLimitPersister:                     NewRateLimitPersister(nil),
+		passwordResetPersister:                 NewPasswordResetPersister(nil),
+		emailChangePersister:                   NewEmailChangePersister(nil),
+		emailPersister:                         emailPersister,
+		rolePersister:                          rolePersister,
+		securityEventPersister:                 NewSecurityEventPersister(nil),
+		auditCheckpointPersister:               NewAuditCheckpointPersister(nil),
+		webhookPersister:                       NewWebhookPersister(nil, nil, nil),
+		loginAnomalyPersister:                  NewLoginAnomalyPersister(nil, 0),
+		guestLoginNotificationPersister:        NewGuestLoginNotificationPersister(nil),
+		guestActivityPersister:                 NewGuestActivityPersister(nil),
 	}
 }
 
@@ -34,6 +53,20 @@ type persister struct {
 	userGuestRelationPersister             persistence.UserGuestRelationPersister
 	loginAuditLogPersister                 persistence.LoginAuditLogPersister
 	postPersister                          persistence.PostPersister
+	postHistoryPersister                   persistence.PostHistoryPersister
+	recoveryCodePersister                  persistence.RecoveryCodePersister
+	totpCredentialPersister                persistence.TotpCredentialPersister
+	rateLimitPersister                     persistence.RateLimitPersister
+	passwordResetPersister                 persistence.PasswordResetPersister
+	emailChangePersister                   persistence.EmailChangePersister
+	emailPersister                         persistence.EmailPersister
+	rolePersister                          persistence.RolePersister
+	securityEventPersister                 persistence.SecurityEventPersister
+	webhookPersister                       persistence.WebhookPersister
+	auditCheckpointPersister               persistence.AuditCheckpointPersister
+	loginAnomalyPersister                  persistence.LoginAnomalyPersister
+	guestLoginNotificationPersister        persistence.GuestLoginNotificationPersister
+	guestActivityPersister                 persistence.GuestActivityPersister
 }
 
 func (p *persister) GetPasswordCredentialPersister() persistence.PasswordCredentialPersister {
@@ -92,6 +125,10 @@ func (p *persister) GetAccountAccessGrantPersister() persistence.AccountAccessGr
 	return p.accountAccessGrantPersister
 }
 
+func (p *persister) GetAccountAccessGrantPersisterWithConnection(_ *pop.Connection) persistence.AccountAccessGrantPersister {
+	return p.accountAccessGrantPersister
+}
+
 func (p *persister) GetJwkPersister() persistence.JwkPersister {
 	return p.jwkPersister
 }
@@ -104,10 +141,126 @@ func (p *persister) GetUserGuestRelationPersister() persistence.UserGuestRelatio
 	return p.userGuestRelationPersister
 }
 
+func (p *persister) GetUserGuestRelationPersisterWithConnection(_ *pop.Connection) persistence.UserGuestRelationPersister {
+	return p.userGuestRelationPersister
+}
+
 func (p *persister) GetLoginAuditLogPersister() persistence.LoginAuditLogPersister {
 	return p.loginAuditLogPersister
 }
 
+func (p *persister) GetLoginAuditLogPersisterWithConnection(_ *pop.Connection) persistence.LoginAuditLogPersister {
+	return p.loginAuditLogPersister
+}
+
 func (p *persister) GetPostPersister() persistence.PostPersister {
 	return p.postPersister
 }
+
+func (p *persister) GetPostPersisterWithConnection(_ *pop.Connection) persistence.PostPersister {
+	return p.postPersister
+}
+
+func (p *persister) GetPostHistoryPersister() persistence.PostHistoryPersister {
+	return p.postHistoryPersister
+}
+
+func (p *persister) GetPostHistoryPersisterWithConnection(_ *pop.Connection) persistence.PostHistoryPersister {
+	return p.postHistoryPersister
+}
+
+func (p *persister) GetRecoveryCodePersister() persistence.RecoveryCodePersister {
+	return p.recoveryCodePersister
+}
+
+func (p *persister) GetRecoveryCodePersisterWithConnection(_ *pop.Connection) persistence.RecoveryCodePersister {
+	return p.recoveryCodePersister
+}
+
+func (p *persister) GetTotpCredentialPersister() persistence.TotpCredentialPersister {
+	return p.totpCredentialPersister
+}
+
+func (p *persister) GetTotpCredentialPersisterWithConnection(_ *pop.Connection) persistence.TotpCredentialPersister {
+	return p.totpCredentialPersister
+}
+
+func (p *persister) GetRateLimitPersister() persistence.RateLimitPersister {
+	return p.rateLimitPersister
+}
+
+func (p *persister) GetPasswordResetPersister() persistence.PasswordResetPersister {
+	return p.passwordResetPersister
+}
+
+func (p *persister) GetPasswordResetPersisterWithConnection(_ *pop.Connection) persistence.PasswordResetPersister {
+	return p.passwordResetPersister
+}
+
+func (p *persister) GetEmailChangePersister() persistence.EmailChangePersister {
+	return p.emailChangePersister
+}
+
+func (p *persister) GetEmailChangePersisterWithConnection(_ *pop.Connection) persistence.EmailChangePersister {
+	return p.emailChangePersister
+}
+
+func (p *persister) GetEmailPersister() persistence.EmailPersister {
+	return p.emailPersister
+}
+
+func (p *persister) GetEmailPersisterWithConnection(_ *pop.Connection) persistence.EmailPersister {
+	return p.emailPersister
+}
+
+func (p *persister) GetRolePersister() persistence.RolePersister {
+	return p.rolePersister
+}
+
+func (p *persister) GetRolePersisterWithConnection(_ *pop.Connection) persistence.RolePersister {
+	return p.rolePersister
+}
+
+func (p *persister) GetSecurityEventPersister() persistence.SecurityEventPersister {
+	return p.securityEventPersister
+}
+
+func (p *persister) GetSecurityEventPersisterWithConnection(_ *pop.Connection) persistence.SecurityEventPersister {
+	return p.securityEventPersister
+}
+
+func (p *persister) GetAuditCheckpointPersister() persistence.AuditCheckpointPersister {
+	return p.auditCheckpointPersister
+}
+
+func (p *persister) GetWebhookPersister() persistence.WebhookPersister {
+	return p.webhookPersister
+}
+
+func (p *persister) GetWebhookPersisterWithConnection(_ *pop.Connection) persistence.WebhookPersister {
+	return p.webhookPersister
+}
+
+func (p *persister) GetLoginAnomalyPersister() persistence.LoginAnomalyPersister {
+	return p.loginAnomalyPersister
+}
+
+func (p *persister) GetLoginAnomalyPersisterWithConnection(_ *pop.Connection) persistence.LoginAnomalyPersister {
+	return p.loginAnomalyPersister
+}
+
+func (p *persister) GetGuestLoginNotificationPersister() persistence.GuestLoginNotificationPersister {
+	return p.guestLoginNotificationPersister
+}
+
+func (p *persister) GetGuestLoginNotificationPersisterWithConnection(_ *pop.Connection) persistence.GuestLoginNotificationPersister {
+	return p.guestLoginNotificationPersister
+}
+
+func (p *persister) GetGuestActivityPersister() persistence.GuestActivityPersister {
+	return p.guestActivityPersister
+}
+
+func (p *persister) GetGuestActivityPersisterWithConnection(_ *pop.Connection) persistence.GuestActivityPersister {
+	return p.guestActivityPersister
+}
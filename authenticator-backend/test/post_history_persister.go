@@ -0,0 +1,57 @@
+package test
+
+import (
+	"sort"
+
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+func NewPostHistoryPersister(init []models.PostHistory) persistence.PostHistoryPersister {
+	return &postHistoryPersister{append([]models.PostHistory{}, init...)}
+}
+
+type postHistoryPersister struct {
+	histories []models.PostHistory
+}
+
+func (p *postHistoryPersister) Create(history models.PostHistory) error {
+	p.histories = append(p.histories, history)
+	return nil
+}
+
+func (p *postHistoryPersister) ListByPost(postId uuid.UUID) ([]models.PostHistory, error) {
+	results := []models.PostHistory{}
+	for _, data := range p.histories {
+		if data.PostId == postId {
+			results = append(results, data)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Version < results[j].Version
+	})
+	return results, nil
+}
+
+func (p *postHistoryPersister) FindByAuthor(userId uuid.UUID) ([]models.PostHistory, error) {
+	var results []models.PostHistory
+	for _, data := range p.histories {
+		if data.UpdatedByUserId == userId || data.UpdatedBySurrogateId == userId {
+			results = append(results, data)
+		}
+	}
+	return results, nil
+}
+
+func (p *postHistoryPersister) PseudonymiseSurrogate(userId uuid.UUID, pseudonym uuid.UUID) error {
+	for i, data := range p.histories {
+		if data.CreatedBySurrogateId == userId {
+			p.histories[i].CreatedBySurrogateId = pseudonym
+		}
+		if data.UpdatedBySurrogateId == userId {
+			p.histories[i].UpdatedBySurrogateId = pseudonym
+		}
+	}
+	return nil
+}
@@ -1,8 +1,13 @@
 package test
 
 import (
+	"sort"
+
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/actor"
 	"github.com/teamhanko/hanko/backend/persistence"
 	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/resource"
 )
 
 func NewPostPersister(init []models.Post) persistence.PostPersister {
@@ -18,6 +23,29 @@ func (p *postPersister) Create(post models.Post) error {
 	return nil
 }
 
+func (p *postPersister) Get(id uuid.UUID) (*models.Post, error) {
+	for _, data := range p.posts {
+		if data.ID == id {
+			post := data
+			return &post, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *postPersister) GetForUpdate(id uuid.UUID) (*models.Post, error) {
+	return p.Get(id)
+}
+
+func (p *postPersister) Update(post models.Post) error {
+	for i, data := range p.posts {
+		if data.ID == post.ID {
+			p.posts[i] = post
+		}
+	}
+	return nil
+}
+
 func (p *postPersister) List(page int, perPage int) ([]models.Post, error) {
 	if len(p.posts) == 0 {
 		return p.posts, nil
@@ -45,3 +73,67 @@ func (p *postPersister) List(page int, perPage int) ([]models.Post, error) {
 	}
 	return result[page-1], nil
 }
+
+func (p *postPersister) Feed(viewer actor.Actor, filter persistence.PostFilter, limit int) ([]models.Post, error) {
+	after := func(post models.Post, cursor persistence.PostCursor) bool {
+		if post.CreatedAt.Equal(cursor.CreatedAt) {
+			return post.ID.String() < cursor.ID.String()
+		}
+		return post.CreatedAt.Before(cursor.CreatedAt)
+	}
+
+	results := []models.Post{}
+	for _, data := range p.posts {
+		if !resource.Visible(viewer, &data) {
+			continue
+		}
+		if filter.OwnerId != nil && data.CreatedByUserId != *filter.OwnerId {
+			continue
+		}
+		if filter.After != nil && !after(data, *filter.After) {
+			continue
+		}
+		results = append(results, data)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return after(results[j], persistence.PostCursor{CreatedAt: results[i].CreatedAt, ID: results[i].ID})
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func (p *postPersister) DeleteByUserId(userId uuid.UUID) error {
+	var remaining []models.Post
+	for _, data := range p.posts {
+		if data.CreatedByUserId != userId {
+			remaining = append(remaining, data)
+		}
+	}
+	p.posts = remaining
+	return nil
+}
+
+func (p *postPersister) PseudonymiseSurrogate(userId uuid.UUID, pseudonym uuid.UUID) error {
+	for i, data := range p.posts {
+		if data.CreatedBySurrogateId == userId {
+			p.posts[i].CreatedBySurrogateId = pseudonym
+		}
+		if data.UpdatedBySurrogateId == userId {
+			p.posts[i].UpdatedBySurrogateId = pseudonym
+		}
+	}
+	return nil
+}
+
+func (p *postPersister) FindByAuthor(userId uuid.UUID) ([]models.Post, error) {
+	var results []models.Post
+	for _, data := range p.posts {
+		if data.CreatedByUserId == userId || data.CreatedBySurrogateId == userId {
+			results = append(results, data)
+		}
+	}
+	return results, nil
+}
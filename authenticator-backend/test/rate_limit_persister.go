@@ -0,0 +1,100 @@
+package test
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+func NewRateLimitPersister(init []models.RateLimit) persistence.RateLimitPersister {
+	return &rateLimitPersister{limits: append([]models.RateLimit{}, init...)}
+}
+
+type rateLimitPersister struct {
+	mutex  sync.Mutex
+	limits []models.RateLimit
+}
+
+func (p *rateLimitPersister) Get(key string) (*models.RateLimit, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, data := range p.limits {
+		if data.Key == key {
+			limit := data
+			return &limit, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *rateLimitPersister) Increment(key string, now time.Time, expiresAt time.Time) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for i, data := range p.limits {
+		if data.Key == key {
+			if data.ExpiresAt.Before(now) {
+				data.Attempts = 0
+				data.BlockedUntil = now
+			}
+			data.Attempts++
+			if data.ExpiresAt.Before(expiresAt) {
+				data.ExpiresAt = expiresAt
+			}
+			data.UpdatedAt = now
+			p.limits[i] = data
+			return data.Attempts, nil
+		}
+	}
+
+	id, _ := uuid.NewV4()
+	p.limits = append(p.limits, models.RateLimit{
+		ID:           id,
+		Key:          key,
+		Attempts:     1,
+		BlockedUntil: now,
+		ExpiresAt:    expiresAt,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	})
+	return 1, nil
+}
+
+func (p *rateLimitPersister) Block(key string, blockedUntil time.Time, expiresAt time.Time) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for i, data := range p.limits {
+		if data.Key == key {
+			if data.BlockedUntil.Before(blockedUntil) {
+				data.BlockedUntil = blockedUntil
+			}
+			if data.ExpiresAt.Before(expiresAt) {
+				data.ExpiresAt = expiresAt
+			}
+			p.limits[i] = data
+		}
+	}
+	return nil
+}
+
+func (p *rateLimitPersister) Delete(key string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	index := -1
+	for i, data := range p.limits {
+		if data.Key == key {
+			index = i
+		}
+	}
+	if index > -1 {
+		p.limits = append(p.limits[:index], p.limits[index+1:]...)
+	}
+
+	return nil
+}
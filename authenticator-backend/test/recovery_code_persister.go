@@ -0,0 +1,56 @@
+package test
+
+import (
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+func NewRecoveryCodePersister(init []models.RecoveryCode) persistence.RecoveryCodePersister {
+	return &recoveryCodePersister{append([]models.RecoveryCode{}, init...)}
+}
+
+type recoveryCodePersister struct {
+	codes []models.RecoveryCode
+}
+
+func (p *recoveryCodePersister) Create(code models.RecoveryCode) error {
+	p.codes = append(p.codes, code)
+	return nil
+}
+
+func (p *recoveryCodePersister) GetByUserId(userId uuid.UUID) ([]models.RecoveryCode, error) {
+	var found []models.RecoveryCode
+	for _, data := range p.codes {
+		if data.UserId == userId {
+			found = append(found, data)
+		}
+	}
+	return found, nil
+}
+
+func (p *recoveryCodePersister) Redeem(code models.RecoveryCode) (bool, error) {
+	index := -1
+	for i, data := range p.codes {
+		if data.ID == code.ID {
+			index = i
+		}
+	}
+	if index == -1 {
+		return false, nil
+	}
+
+	p.codes = append(p.codes[:index], p.codes[index+1:]...)
+	return true, nil
+}
+
+func (p *recoveryCodePersister) DeleteByUserId(userId uuid.UUID) error {
+	var remaining []models.RecoveryCode
+	for _, data := range p.codes {
+		if data.UserId != userId {
+			remaining = append(remaining, data)
+		}
+	}
+	p.codes = remaining
+	return nil
+}
@@ -0,0 +1,97 @@
+package test
+
+import (
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+// Roles are the roles seeded by the migrations
+var Roles = []models.Role{
+	{
+		ID:          uuid.FromStringOrNil("3f0c6f8e-2d4b-4c1a-8e5f-7b9d0a1c2e03"),
+		Name:        models.RoleSuperAdmin,
+		Permissions: []string{models.PermissionAuditRead, models.PermissionRolesManage, models.PermissionUsersDelete, models.PermissionUsersRead, models.PermissionUsersWrite, models.PermissionWebhooksManage},
+	},
+	{
+		ID:          uuid.FromStringOrNil("3f0c6f8e-2d4b-4c1a-8e5f-7b9d0a1c2e01"),
+		Name:        models.RoleSupport,
+		Permissions: []string{models.PermissionAuditRead, models.PermissionUsersRead},
+	},
+	{
+		ID:          uuid.FromStringOrNil("3f0c6f8e-2d4b-4c1a-8e5f-7b9d0a1c2e02"),
+		Name:        models.RoleUserAdmin,
+		Permissions: []string{models.PermissionAuditRead, models.PermissionUsersDelete, models.PermissionUsersRead, models.PermissionUsersWrite},
+	},
+}
+
+// NewRolePersister returns a role persister with the seeded roles and the given assignments
+func NewRolePersister(init []models.UserRole) persistence.RolePersister {
+	return &rolePersister{roles: Roles, userRoles: append([]models.UserRole{}, init...)}
+}
+
+type rolePersister struct {
+	roles     []models.Role
+	userRoles []models.UserRole
+}
+
+func (p *rolePersister) List() ([]models.Role, error) {
+	return append([]models.Role{}, p.roles...), nil
+}
+
+func (p *rolePersister) GetByName(name string) (*models.Role, error) {
+	for _, data := range p.roles {
+		if data.Name == name {
+			role := data
+			return &role, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *rolePersister) FindByUserId(userId uuid.UUID) ([]models.Role, error) {
+	var roles []models.Role
+	for _, role := range p.roles {
+		for _, userRole := range p.userRoles {
+			if userRole.UserId == userId && userRole.RoleId == role.ID {
+				roles = append(roles, role)
+			}
+		}
+	}
+	return roles, nil
+}
+
+func (p *rolePersister) Lock(_ uuid.UUID) error {
+	return nil
+}
+
+func (p *rolePersister) CountUsers(roleId uuid.UUID) (int, error) {
+	count := 0
+	for _, userRole := range p.userRoles {
+		if userRole.RoleId == roleId {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (p *rolePersister) AddToUser(userId uuid.UUID, roleId uuid.UUID) error {
+	for _, userRole := range p.userRoles {
+		if userRole.UserId == userId && userRole.RoleId == roleId {
+			return nil
+		}
+	}
+	p.userRoles = append(p.userRoles, models.NewUserRole(userId, roleId))
+	return nil
+}
+
+func (p *rolePersister) RemoveFromUser(userId uuid.UUID, roleId uuid.UUID) error {
+	var userRoles []models.UserRole
+	for _, userRole := range p.userRoles {
+		if userRole.UserId != userId || userRole.RoleId != roleId {
+			userRoles = append(userRoles, userRole)
+		}
+	}
+	p.userRoles = userRoles
+	return nil
+}
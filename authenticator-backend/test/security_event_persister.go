@@ -0,0 +1,87 @@
+package test
+
+import (
+	"sort"
+
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+func NewSecurityEventPersister(init []models.SecurityEvent) persistence.SecurityEventPersister {
+	return &securityEventPersister{append([]models.SecurityEvent{}, init...)}
+}
+
+type securityEventPersister struct {
+	events []models.SecurityEvent
+}
+
+func (p *securityEventPersister) Create(event models.SecurityEvent) error {
+	p.events = append(p.events, event)
+	return nil
+}
+
+func (p *securityEventPersister) Search(filter persistence.SecurityEventFilter, page int, perPage int) ([]models.SecurityEvent, int, error) {
+	var matches []models.SecurityEvent
+	for _, event := range p.events {
+		if filter.UserId != nil && !isUser(event.ActorUserId, *filter.UserId) && !isUser(event.SurrogateUserId, *filter.UserId) && !isUser(event.TargetUserId, *filter.UserId) {
+			continue
+		}
+		if len(filter.Types) > 0 {
+			found := false
+			for _, eventType := range filter.Types {
+				if event.Type == eventType {
+					found = true
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		if filter.From != nil && event.CreatedAt.Before(*filter.From) {
+			continue
+		}
+		if filter.To != nil && !event.CreatedAt.Before(*filter.To) {
+			continue
+		}
+		matches = append(matches, event)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].CreatedAt.After(matches[j].CreatedAt)
+	})
+
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 20
+	}
+	start := (page - 1) * perPage
+	if start >= len(matches) {
+		return []models.SecurityEvent{}, len(matches), nil
+	}
+	end := start + perPage
+	if end > len(matches) {
+		end = len(matches)
+	}
+	return matches[start:end], len(matches), nil
+}
+
+func (p *securityEventPersister) Pseudonymise(userId uuid.UUID, pseudonym uuid.UUID) error {
+	for i := range p.events {
+		event := &p.events[i]
+		for _, id := range []**uuid.UUID{&event.ActorUserId, &event.SurrogateUserId, &event.TargetUserId} {
+			if isUser(*id, userId) {
+				*id = &pseudonym
+				event.ClientIpAddress = models.Redacted
+				event.ClientUserAgent = models.Redacted
+			}
+		}
+	}
+	return nil
+}
+
+func isUser(id *uuid.UUID, userId uuid.UUID) bool {
+	return id != nil && *id == userId
+}
@@ -0,0 +1,67 @@
+package test
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+func NewTotpCredentialPersister(init []models.TotpCredential) persistence.TotpCredentialPersister {
+	return &totpCredentialPersister{append([]models.TotpCredential{}, init...)}
+}
+
+type totpCredentialPersister struct {
+	credentials []models.TotpCredential
+}
+
+func (p *totpCredentialPersister) Create(credential models.TotpCredential) error {
+	p.credentials = append(p.credentials, credential)
+	return nil
+}
+
+func (p *totpCredentialPersister) GetByUserId(userId uuid.UUID) (*models.TotpCredential, error) {
+	var found *models.TotpCredential
+	for _, data := range p.credentials {
+		if data.UserId == userId {
+			d := data
+			found = &d
+		}
+	}
+	return found, nil
+}
+
+func (p *totpCredentialPersister) Update(credential models.TotpCredential) error {
+	for i, data := range p.credentials {
+		if data.ID == credential.ID {
+			p.credentials[i] = credential
+		}
+	}
+	return nil
+}
+
+func (p *totpCredentialPersister) ClaimStep(id uuid.UUID, step int64, updatedAt time.Time) (bool, error) {
+	for i, data := range p.credentials {
+		if data.ID == id && data.LastUsedStep < step {
+			p.credentials[i].LastUsedStep = step
+			p.credentials[i].UpdatedAt = updatedAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (p *totpCredentialPersister) Delete(credential models.TotpCredential) error {
+	index := -1
+	for i, data := range p.credentials {
+		if data.ID == credential.ID {
+			index = i
+		}
+	}
+	if index > -1 {
+		p.credentials = append(p.credentials[:index], p.credentials[index+1:]...)
+	}
+
+	return nil
+}
@@ -59,3 +59,13 @@ func (p *userGuestRelationPersister) GetByParentUserId(parentUserId *uuid.UUID)
 	}
 	return results, nil
 }
+
+func (p *userGuestRelationPersister) FindByUserId(userId uuid.UUID) ([]models.UserGuestRelation, error) {
+	var results []models.UserGuestRelation
+	for _, data := range p.relations {
+		if data.GuestUserID == userId || data.ParentUserID == userId {
+			results = append(results, data)
+		}
+	}
+	return results, nil
+}
@@ -4,14 +4,35 @@ import (
 	"github.com/gofrs/uuid"
 	"github.com/teamhanko/hanko/backend/persistence"
 	"github.com/teamhanko/hanko/backend/persistence/models"
+	"sort"
+	"strings"
+	"time"
 )
 
 func NewUserPersister(init []models.User) persistence.UserPersister {
-	return &userPersister{append([]models.User{}, init...)}
+	return &userPersister{users: append([]models.User{}, init...)}
+}
+
+// newLinkedUserPersister returns a user persister which also finds users by the addresses of the email persister and
+// which uses the other persisters to filter the users in Search
+func newLinkedUserPersister(init []models.User, emails persistence.EmailPersister, credentials persistence.WebauthnCredentialPersister, passwords persistence.PasswordCredentialPersister, relations persistence.UserGuestRelationPersister, roles persistence.RolePersister) persistence.UserPersister {
+	return &userPersister{
+		users:       append([]models.User{}, init...),
+		emails:      emails,
+		credentials: credentials,
+		passwords:   passwords,
+		relations:   relations,
+		roles:       roles,
+	}
 }
 
 type userPersister struct {
-	users []models.User
+	users       []models.User
+	emails      persistence.EmailPersister
+	credentials persistence.WebauthnCredentialPersister
+	passwords   persistence.PasswordCredentialPersister
+	relations   persistence.UserGuestRelationPersister
+	roles       persistence.RolePersister
 }
 
 func (p *userPersister) Get(id uuid.UUID) (*models.User, error) {
@@ -33,6 +54,13 @@ func (p *userPersister) GetByEmail(email string) (*models.User, error) {
 			found = &d
 		}
 	}
+	if found == nil && p.emails != nil {
+		address, err := p.emails.GetByAddress(email)
+		if err != nil || address == nil || !address.Verified {
+			return nil, err
+		}
+		return p.Get(address.UserId)
+	}
 	return found, nil
 }
 
@@ -91,3 +119,146 @@ func (p *userPersister) List(page int, perPage int) ([]models.User, error) {
 	}
 	return result[page-1], nil
 }
+
+func (p *userPersister) Search(filter persistence.UserFilter, page int, perPage int) ([]models.User, int, error) {
+	var matches []models.User
+	for _, data := range p.users {
+		match, err := p.matches(data, filter)
+		if err != nil {
+			return nil, 0, err
+		}
+		if match {
+			matches = append(matches, data)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if filter.SortDesc {
+			a, b = b, a
+		}
+		switch filter.SortBy {
+		case persistence.UserSortUpdatedAt:
+			return a.UpdatedAt.Before(b.UpdatedAt)
+		case persistence.UserSortEmail:
+			return a.Email < b.Email
+		default:
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+	})
+
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 20
+	}
+	start := (page - 1) * perPage
+	if start >= len(matches) {
+		return []models.User{}, len(matches), nil
+	}
+	end := start + perPage
+	if end > len(matches) {
+		end = len(matches)
+	}
+	return matches[start:end], len(matches), nil
+}
+
+func (p *userPersister) matches(user models.User, filter persistence.UserFilter) (bool, error) {
+	if filter.Email != "" {
+		match := strings.Contains(strings.ToLower(user.Email), strings.ToLower(filter.Email))
+		if !match && p.emails != nil {
+			emails, err := p.emails.FindByUserId(user.ID)
+			if err != nil {
+				return false, err
+			}
+			for _, email := range emails {
+				if strings.Contains(strings.ToLower(email.Address), strings.ToLower(filter.Email)) {
+					match = true
+				}
+			}
+		}
+		if !match {
+			return false, nil
+		}
+	}
+	if filter.Verified != nil && user.Verified != *filter.Verified {
+		return false, nil
+	}
+	if filter.Active != nil && user.IsActive != *filter.Active {
+		return false, nil
+	}
+	if filter.Admin != nil {
+		isAdmin := false
+		if p.roles != nil {
+			roles, err := p.roles.FindByUserId(user.ID)
+			if err != nil {
+				return false, err
+			}
+			isAdmin = len(roles) > 0
+		}
+		if isAdmin != *filter.Admin {
+			return false, nil
+		}
+	}
+	if filter.CreatedAfter != nil && user.CreatedAt.Before(*filter.CreatedAfter) {
+		return false, nil
+	}
+	if filter.CreatedBefore != nil && !user.CreatedAt.Before(*filter.CreatedBefore) {
+		return false, nil
+	}
+	if filter.HasPasskey != nil {
+		hasPasskey := false
+		if p.credentials != nil {
+			credentials, err := p.credentials.GetFromUser(user.ID)
+			if err != nil {
+				return false, err
+			}
+			hasPasskey = len(credentials) > 0
+		}
+		if hasPasskey != *filter.HasPasskey {
+			return false, nil
+		}
+	}
+	if filter.HasPassword != nil {
+		hasPassword := false
+		if p.passwords != nil {
+			password, err := p.passwords.GetByUserID(user.ID)
+			if err != nil {
+				return false, err
+			}
+			hasPassword = password != nil
+		}
+		if hasPassword != *filter.HasPassword {
+			return false, nil
+		}
+	}
+	if filter.HasActiveGuestRelations != nil {
+		hasActiveRelations := false
+		if p.relations != nil {
+			relations, err := p.relations.FindByUserId(user.ID)
+			if err != nil {
+				return false, err
+			}
+			for _, relation := range relations {
+				if relation.IsActive {
+					hasActiveRelations = true
+				}
+			}
+		}
+		if hasActiveRelations != *filter.HasActiveGuestRelations {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (p *userPersister) FindDeletionDue(now time.Time) ([]models.User, error) {
+	var results []models.User
+	for _, data := range p.users {
+		if data.DeletionScheduledAt != nil && !data.DeletionScheduledAt.After(now) {
+			results = append(results, data)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,226 @@
+package test
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+func NewWebhookPersister(endpoints []models.WebhookEndpoint, events []models.WebhookEvent, deliveries []models.WebhookDelivery) persistence.WebhookPersister {
+	return &webhookPersister{
+		endpoints:  append([]models.WebhookEndpoint{}, endpoints...),
+		events:     append([]models.WebhookEvent{}, events...),
+		deliveries: append([]models.WebhookDelivery{}, deliveries...),
+	}
+}
+
+type webhookPersister struct {
+	endpoints  []models.WebhookEndpoint
+	events     []models.WebhookEvent
+	deliveries []models.WebhookDelivery
+}
+
+func (p *webhookPersister) CreateEndpoint(endpoint models.WebhookEndpoint) error {
+	p.endpoints = append(p.endpoints, endpoint)
+	return nil
+}
+
+func (p *webhookPersister) GetEndpoint(id uuid.UUID) (*models.WebhookEndpoint, error) {
+	for _, endpoint := range p.endpoints {
+		if endpoint.ID == id {
+			found := endpoint
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *webhookPersister) ListEndpoints() ([]models.WebhookEndpoint, error) {
+	return append([]models.WebhookEndpoint{}, p.endpoints...), nil
+}
+
+func (p *webhookPersister) UpdateEndpoint(endpoint models.WebhookEndpoint) error {
+	for i, existing := range p.endpoints {
+		if existing.ID == endpoint.ID {
+			p.endpoints[i] = endpoint
+		}
+	}
+	return nil
+}
+
+func (p *webhookPersister) DeleteEndpoint(endpoint models.WebhookEndpoint) error {
+	var endpoints []models.WebhookEndpoint
+	for _, existing := range p.endpoints {
+		if existing.ID != endpoint.ID {
+			endpoints = append(endpoints, existing)
+		}
+	}
+	p.endpoints = endpoints
+
+	var deliveries []models.WebhookDelivery
+	for _, delivery := range p.deliveries {
+		if delivery.EndpointId != endpoint.ID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	p.deliveries = deliveries
+	return nil
+}
+
+func (p *webhookPersister) CreateEvent(event models.WebhookEvent) error {
+	p.events = append(p.events, event)
+	return nil
+}
+
+func (p *webhookPersister) GetEvent(id uuid.UUID) (*models.WebhookEvent, error) {
+	for _, event := range p.events {
+		if event.ID == id {
+			found := event
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *webhookPersister) ListUndispatchedEvents(limit int) ([]models.WebhookEvent, error) {
+	var events []models.WebhookEvent
+	for _, event := range p.events {
+		if event.DispatchedAt == nil && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (p *webhookPersister) ClaimEvent(id uuid.UUID, dispatchedAt time.Time) (bool, error) {
+	for i, event := range p.events {
+		if event.ID == id && event.DispatchedAt == nil {
+			p.events[i].DispatchedAt = &dispatchedAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (p *webhookPersister) DeleteEventsBefore(createdAt time.Time) (int, error) {
+	var events []models.WebhookEvent
+	deleted := map[uuid.UUID]bool{}
+	for _, event := range p.events {
+		if event.DispatchedAt != nil && event.CreatedAt.Before(createdAt) {
+			deleted[event.ID] = true
+		} else {
+			events = append(events, event)
+		}
+	}
+	p.events = events
+
+	var deliveries []models.WebhookDelivery
+	for _, delivery := range p.deliveries {
+		if !deleted[delivery.EventId] {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	p.deliveries = deliveries
+	return len(deleted), nil
+}
+
+func (p *webhookPersister) CreateDelivery(delivery models.WebhookDelivery) error {
+	p.deliveries = append(p.deliveries, delivery)
+	return nil
+}
+
+func (p *webhookPersister) GetDelivery(id uuid.UUID) (*models.WebhookDelivery, error) {
+	for _, delivery := range p.deliveries {
+		if delivery.ID == id {
+			found := delivery
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *webhookPersister) UpdateDelivery(delivery models.WebhookDelivery) error {
+	for i, existing := range p.deliveries {
+		if existing.ID == delivery.ID {
+			p.deliveries[i] = delivery
+		}
+	}
+	return nil
+}
+
+func (p *webhookPersister) ListDueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	for _, delivery := range p.deliveries {
+		endpoint, _ := p.GetEndpoint(delivery.EndpointId)
+		if endpoint == nil || !endpoint.IsActive || delivery.Status != models.WebhookDeliveryPending || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].NextAttemptAt.Before(deliveries[j].NextAttemptAt)
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (p *webhookPersister) ClaimDelivery(id uuid.UUID, expected time.Time, nextAttemptAt time.Time) (bool, error) {
+	for i, delivery := range p.deliveries {
+		if delivery.ID == id && delivery.Status == models.WebhookDeliveryPending && delivery.NextAttemptAt.Equal(expected) {
+			p.deliveries[i].NextAttemptAt = nextAttemptAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (p *webhookPersister) matchingDeliveries(endpointId uuid.UUID, filter persistence.WebhookDeliveryFilter) []models.WebhookDelivery {
+	var deliveries []models.WebhookDelivery
+	for _, delivery := range p.deliveries {
+		if delivery.EndpointId != endpointId {
+			continue
+		}
+		if filter.Status != "" && delivery.Status != filter.Status {
+			continue
+		}
+		if filter.From != nil && delivery.CreatedAt.Before(*filter.From) {
+			continue
+		}
+		if filter.To != nil && !delivery.CreatedAt.Before(*filter.To) {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries
+}
+
+func (p *webhookPersister) ListDeliveries(endpointId uuid.UUID, filter persistence.WebhookDeliveryFilter, page int, perPage int) ([]models.WebhookDelivery, int, error) {
+	deliveries := p.matchingDeliveries(endpointId, filter)
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+	})
+
+	start := (page - 1) * perPage
+	if start >= len(deliveries) {
+		return []models.WebhookDelivery{}, len(deliveries), nil
+	}
+	end := start + perPage
+	if end > len(deliveries) {
+		end = len(deliveries)
+	}
+	return deliveries[start:end], len(deliveries), nil
+}
+
+func (p *webhookPersister) ReplayDeliveries(endpointId uuid.UUID, filter persistence.WebhookDeliveryFilter, now time.Time) (int, error) {
+	deliveries := p.matchingDeliveries(endpointId, filter)
+	for _, delivery := range deliveries {
+		delivery.Replay(now)
+		_ = p.UpdateDelivery(delivery)
+	}
+	return len(deliveries), nil
+}
@@ -0,0 +1,260 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+const (
+	// SignatureHeader carries the timestamp and the HMAC-SHA256 of the delivery: t=<unix time>,v1=<hex signature>
+	SignatureHeader = "X-Hanko-Webhook-Signature"
+	// DeliveryHeader carries the ID of the delivery, the ID of the event is part of the payload
+	DeliveryHeader = "X-Hanko-Webhook-Delivery"
+	// EventHeader carries the type of the event
+	EventHeader = "X-Hanko-Webhook-Event"
+
+	// batchSize is the maximum number of events fanned out and deliveries attempted per run
+	batchSize = 100
+	// maxErrorLength is the maximum length of the error stored with a failed attempt
+	maxErrorLength = 1000
+)
+
+// Sign returns the signature of a delivery sent at the timestamp. Receivers compute it from the timestamp and the raw
+// body and compare it with the v1 value of the signature header.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher fans the events of the outbox out into a delivery per subscribed endpoint and delivers them. Deliveries
+// are claimed before they are attempted, so several instances can run a dispatcher at the same time.
+type Dispatcher struct {
+	persister     persistence.Persister
+	client        *http.Client
+	maxAttempts   int
+	retryDelay    time.Duration
+	maxRetryDelay time.Duration
+	retention     time.Duration
+	now           func() time.Time
+}
+
+func NewDispatcher(persister persistence.Persister, cfg config.Webhooks) *Dispatcher {
+	// errors can be ignored, values are checked in config validation
+	timeout, _ := time.ParseDuration(cfg.Timeout)
+	retryDelay, _ := time.ParseDuration(cfg.RetryDelay)
+	maxRetryDelay, _ := time.ParseDuration(cfg.MaxRetryDelay)
+	retention, _ := time.ParseDuration(cfg.Retention)
+	return &Dispatcher{
+		persister:     persister,
+		client:        &http.Client{Timeout: timeout},
+		maxAttempts:   cfg.MaxAttempts,
+		retryDelay:    retryDelay,
+		maxRetryDelay: maxRetryDelay,
+		retention:     retention,
+		now:           time.Now,
+	}
+}
+
+// Dispatch creates the deliveries of the events in the outbox and returns how many events were dispatched
+func (d *Dispatcher) Dispatch() (int, error) {
+	webhookPersister := d.persister.GetWebhookPersister()
+	events, err := webhookPersister.ListUndispatchedEvents(batchSize)
+	if err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+	endpoints, err := webhookPersister.ListEndpoints()
+	if err != nil {
+		return 0, err
+	}
+
+	dispatched := 0
+	for _, event := range events {
+		err = d.persister.Transaction(func(tx *pop.Connection) error {
+			txPersister := d.persister.GetWebhookPersisterWithConnection(tx)
+			now := d.now().UTC()
+			claimed, err := txPersister.ClaimEvent(event.ID, now)
+			if err != nil || !claimed {
+				return err
+			}
+			for _, endpoint := range endpoints {
+				if !endpoint.IsActive || !endpoint.Subscribes(event.Type) {
+					continue
+				}
+				err = txPersister.CreateDelivery(models.NewWebhookDelivery(event, endpoint, now))
+				if err != nil {
+					return err
+				}
+			}
+			dispatched++
+			return nil
+		})
+		if err != nil {
+			return dispatched, fmt.Errorf("failed to dispatch webhook event %s: %w", event.ID, err)
+		}
+	}
+
+	return dispatched, nil
+}
+
+// Deliver attempts the due deliveries and returns how many succeeded
+func (d *Dispatcher) Deliver() (int, error) {
+	webhookPersister := d.persister.GetWebhookPersister()
+	deliveries, err := webhookPersister.ListDueDeliveries(d.now().UTC(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, delivery := range deliveries {
+		// the claim holds until the attempt would have timed out, a crashed instance can't block the delivery
+		claimedUntil := d.now().UTC().Add(d.client.Timeout + time.Minute)
+		claimed, err := webhookPersister.ClaimDelivery(delivery.ID, delivery.NextAttemptAt, claimedUntil)
+		if err != nil {
+			return delivered, err
+		}
+		if !claimed {
+			continue
+		}
+		delivery.NextAttemptAt = claimedUntil
+
+		ok, err := d.attempt(delivery)
+		if err != nil {
+			return delivered, fmt.Errorf("failed to attempt webhook delivery %s: %w", delivery.ID, err)
+		}
+		if ok {
+			delivered++
+		}
+	}
+
+	return delivered, nil
+}
+
+// attempt sends the delivery once and records the outcome. It returns whether the endpoint accepted the delivery.
+func (d *Dispatcher) attempt(delivery models.WebhookDelivery) (bool, error) {
+	webhookPersister := d.persister.GetWebhookPersister()
+	event, err := webhookPersister.GetEvent(delivery.EventId)
+	if err != nil {
+		return false, err
+	}
+	endpoint, err := webhookPersister.GetEndpoint(delivery.EndpointId)
+	if err != nil {
+		return false, err
+	}
+	if event == nil || endpoint == nil {
+		// removed in the meantime, the delivery is removed with them
+		return false, nil
+	}
+
+	status, sendErr := d.send(*endpoint, delivery, *event)
+
+	now := d.now().UTC()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = status
+	delivery.UpdatedAt = now
+	if sendErr == nil {
+		delivery.Status = models.WebhookDeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	} else {
+		delivery.LastError = sendErr.Error()
+		if len(delivery.LastError) > maxErrorLength {
+			delivery.LastError = delivery.LastError[:maxErrorLength]
+		}
+		if delivery.Attempts >= d.maxAttempts {
+			delivery.Status = models.WebhookDeliveryFailed
+		} else {
+			delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+		}
+	}
+
+	err = webhookPersister.UpdateDelivery(delivery)
+	if err != nil {
+		return false, err
+	}
+	return sendErr == nil, nil
+}
+
+// send posts the payload of the event to the endpoint and returns the response status, 0 if there was no response
+func (d *Dispatcher) send(endpoint models.WebhookEndpoint, delivery models.WebhookDelivery, event models.WebhookEvent) (int, error) {
+	body := []byte(event.Payload)
+	request, err := http.NewRequest(http.MethodPost, endpoint.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	timestamp := d.now().Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "Hanko-Webhooks")
+	request.Header.Set(DeliveryHeader, delivery.ID.String())
+	request.Header.Set(EventHeader, event.Type)
+	request.Header.Set(SignatureHeader, fmt.Sprintf("t=%d,v1=%s", timestamp, Sign(endpoint.Secret, timestamp, body)))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("endpoint responded with status %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+// backoff returns the delay after the given number of failed attempts
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.retryDelay
+	for i := 1; i < attempts && delay < d.maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > d.maxRetryDelay {
+		delay = d.maxRetryDelay
+	}
+	return delay
+}
+
+// Cleanup removes the events older than the retention together with their deliveries
+func (d *Dispatcher) Cleanup() (int, error) {
+	return d.persister.GetWebhookPersister().DeleteEventsBefore(d.now().UTC().Add(-d.retention))
+}
+
+// Run dispatches and delivers the events in the given interval. It does not return.
+func (d *Dispatcher) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		_, err := d.Dispatch()
+		if err != nil {
+			log.Printf("failed to dispatch webhook events: %v", err)
+		}
+		_, err = d.Deliver()
+		if err != nil {
+			log.Printf("failed to deliver webhooks: %v", err)
+		}
+		_, err = d.Cleanup()
+		if err != nil {
+			log.Printf("failed to clean up webhook events: %v", err)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/teamhanko/hanko/backend/config"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+	"github.com/teamhanko/hanko/backend/test"
+)
+
+var testConfig = config.Webhooks{
+	Timeout:       "5s",
+	MaxAttempts:   3,
+	RetryDelay:    "30s",
+	MaxRetryDelay: "1m",
+	Retention:     "720h",
+}
+
+func newEndpoint(t *testing.T, persister persistence.Persister, url string, eventTypes ...string) models.WebhookEndpoint {
+	id, err := uuid.NewV4()
+	require.NoError(t, err)
+	now := time.Now().UTC()
+	endpoint := models.WebhookEndpoint{ID: id, Url: url, Secret: "whsec_test", IsActive: true, CreatedAt: now, UpdatedAt: now}
+	endpoint.SetEventTypes(eventTypes)
+	require.NoError(t, persister.GetWebhookPersister().CreateEndpoint(endpoint))
+	return endpoint
+}
+
+// receiver is a stand-in for a webhook endpoint, it answers with the given status
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	body, _ := io.ReadAll(request.Body)
+	r.requests = append(r.requests, request)
+	r.bodies = append(r.bodies, body)
+	w.WriteHeader(r.status)
+}
+
+func newDispatcher(t *testing.T, status int) (*Dispatcher, persistence.Persister, *receiver, *httptest.Server) {
+	persister := test.NewPersister(nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	r := &receiver{status: status}
+	server := httptest.NewTLSServer(r)
+	t.Cleanup(server.Close)
+
+	dispatcher := NewDispatcher(persister, testConfig)
+	dispatcher.client = server.Client()
+	return dispatcher, persister, r, server
+}
+
+func TestDispatcher_Dispatch(t *testing.T) {
+	dispatcher, persister, _, server := newDispatcher(t, http.StatusOK)
+	all := newEndpoint(t, persister, server.URL)
+	relations := newEndpoint(t, persister, server.URL, models.WebhookEventRelationCreated, models.WebhookEventRelationRevoked)
+	inactive := newEndpoint(t, persister, server.URL)
+	inactive.IsActive = false
+	require.NoError(t, persister.GetWebhookPersister().UpdateEndpoint(inactive))
+
+	webhookPersister := persister.GetWebhookPersister()
+	require.NoError(t, Enqueue(webhookPersister, models.WebhookEventUserCreated, UserData{UserId: uuid.Must(uuid.NewV4())}))
+	require.NoError(t, Enqueue(webhookPersister, models.WebhookEventRelationRevoked, RelationData{RelationId: uuid.Must(uuid.NewV4())}))
+
+	dispatched, err := dispatcher.Dispatch()
+	require.NoError(t, err)
+	assert.Equal(t, 2, dispatched)
+
+	deliveries, total, err := webhookPersister.ListDeliveries(all.ID, persistence.WebhookDeliveryFilter{}, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	deliveries, _, err = webhookPersister.ListDeliveries(relations.ID, persistence.WebhookDeliveryFilter{}, 1, 10)
+	require.NoError(t, err)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, models.WebhookEventRelationRevoked, deliveries[0].EventType)
+		assert.Equal(t, models.WebhookDeliveryPending, deliveries[0].Status)
+	}
+	_, total, err = webhookPersister.ListDeliveries(inactive.ID, persistence.WebhookDeliveryFilter{}, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 0, total)
+
+	dispatched, err = dispatcher.Dispatch()
+	require.NoError(t, err)
+	assert.Equal(t, 0, dispatched, "events are dispatched once")
+}
+
+func TestDispatcher_Deliver(t *testing.T) {
+	dispatcher, persister, r, server := newDispatcher(t, http.StatusNoContent)
+	endpoint := newEndpoint(t, persister, server.URL)
+	userId := uuid.Must(uuid.NewV4())
+	require.NoError(t, Enqueue(persister.GetWebhookPersister(), models.WebhookEventUserCreated, UserData{UserId: userId, Email: "john.doe@example.com"}))
+
+	_, err := dispatcher.Dispatch()
+	require.NoError(t, err)
+	delivered, err := dispatcher.Deliver()
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+
+	require.Len(t, r.requests, 1)
+	request := r.requests[0]
+	body := r.bodies[0]
+	assert.Equal(t, models.WebhookEventUserCreated, request.Header.Get(EventHeader))
+	assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+
+	var timestamp int64
+	var signature string
+	_, err = fmt.Sscanf(strings.Replace(request.Header.Get(SignatureHeader), ",v1=", " ", 1), "t=%d %s", &timestamp, &signature)
+	require.NoError(t, err)
+	assert.Equal(t, Sign(endpoint.Secret, timestamp, body), signature)
+
+	var payload struct {
+		ID   uuid.UUID `json:"id"`
+		Type string    `json:"type"`
+		Data UserData  `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, models.WebhookEventUserCreated, payload.Type)
+	assert.Equal(t, userId, payload.Data.UserId)
+
+	deliveries, _, err := persister.GetWebhookPersister().ListDeliveries(endpoint.ID, persistence.WebhookDeliveryFilter{}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, request.Header.Get(DeliveryHeader), deliveries[0].ID.String())
+	assert.Equal(t, payload.ID, deliveries[0].EventId)
+	assert.Equal(t, models.WebhookDeliveryDelivered, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusNoContent, deliveries[0].ResponseStatus)
+	assert.NotNil(t, deliveries[0].DeliveredAt)
+
+	delivered, err = dispatcher.Deliver()
+	require.NoError(t, err)
+	assert.Equal(t, 0, delivered, "delivered deliveries are not sent again")
+}
+
+func TestDispatcher_Deliver_Retry(t *testing.T) {
+	dispatcher, persister, r, server := newDispatcher(t, http.StatusInternalServerError)
+	endpoint := newEndpoint(t, persister, server.URL)
+	require.NoError(t, Enqueue(persister.GetWebhookPersister(), models.WebhookEventUserDeleted, UserData{UserId: uuid.Must(uuid.NewV4())}))
+	_, err := dispatcher.Dispatch()
+	require.NoError(t, err)
+
+	now := time.Now().UTC()
+	dispatcher.now = func() time.Time { return now }
+	getDelivery := func() models.WebhookDelivery {
+		deliveries, _, err := persister.GetWebhookPersister().ListDeliveries(endpoint.ID, persistence.WebhookDeliveryFilter{}, 1, 10)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		return deliveries[0]
+	}
+
+	// the delay doubles with every attempt: 30s, 1m
+	for attempt, delay := range []time.Duration{30 * time.Second, time.Minute} {
+		delivered, err := dispatcher.Deliver()
+		require.NoError(t, err)
+		assert.Equal(t, 0, delivered)
+		delivery := getDelivery()
+		assert.Equal(t, models.WebhookDeliveryPending, delivery.Status)
+		assert.Equal(t, attempt+1, delivery.Attempts)
+		assert.Equal(t, http.StatusInternalServerError, delivery.ResponseStatus)
+		assert.Equal(t, now.Add(delay), delivery.NextAttemptAt)
+		assert.Contains(t, delivery.LastError, "500")
+
+		delivered, err = dispatcher.Deliver()
+		require.NoError(t, err)
+		assert.Equal(t, 0, delivered)
+		assert.Equal(t, attempt+1, len(r.requests), "the next attempt is not due yet")
+
+		now = now.Add(delay)
+	}
+
+	_, err = dispatcher.Deliver()
+	require.NoError(t, err)
+	delivery := getDelivery()
+	assert.Equal(t, models.WebhookDeliveryFailed, delivery.Status, "the delivery is given up after the last attempt")
+	assert.Equal(t, 3, delivery.Attempts)
+
+	// a replayed delivery is attempted again
+	r.status = http.StatusOK
+	delivery.Replay(now)
+	require.NoError(t, persister.GetWebhookPersister().UpdateDelivery(delivery))
+	delivered, err := dispatcher.Deliver()
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, models.WebhookDeliveryDelivered, getDelivery().Status)
+}
+
+func TestDispatcher_Deliver_InactiveEndpoint(t *testing.T) {
+	dispatcher, persister, r, server := newDispatcher(t, http.StatusOK)
+	endpoint := newEndpoint(t, persister, server.URL)
+	require.NoError(t, Enqueue(persister.GetWebhookPersister(), models.WebhookEventUserCreated, UserData{UserId: uuid.Must(uuid.NewV4())}))
+	_, err := dispatcher.Dispatch()
+	require.NoError(t, err)
+
+	endpoint.IsActive = false
+	require.NoError(t, persister.GetWebhookPersister().UpdateEndpoint(endpoint))
+	delivered, err := dispatcher.Deliver()
+	require.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+	assert.Empty(t, r.requests, "deliveries to inactive endpoints are held back")
+
+	endpoint.IsActive = true
+	require.NoError(t, persister.GetWebhookPersister().UpdateEndpoint(endpoint))
+	delivered, err = dispatcher.Deliver()
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+}
+
+func TestDispatcher_Cleanup(t *testing.T) {
+	dispatcher, persister, _, server := newDispatcher(t, http.StatusOK)
+	newEndpoint(t, persister, server.URL)
+	webhookPersister := persister.GetWebhookPersister()
+	require.NoError(t, Enqueue(webhookPersister, models.WebhookEventUserCreated, UserData{UserId: uuid.Must(uuid.NewV4())}))
+	_, err := dispatcher.Dispatch()
+	require.NoError(t, err)
+	require.NoError(t, Enqueue(webhookPersister, models.WebhookEventUserCreated, UserData{UserId: uuid.Must(uuid.NewV4())}))
+
+	dispatcher.now = func() time.Time { return time.Now().Add(721 * time.Hour) }
+	deleted, err := dispatcher.Cleanup()
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted, "undispatched events are kept")
+	events, err := webhookPersister.ListUndispatchedEvents(10)
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+}
@@ -0,0 +1,94 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/teamhanko/hanko/backend/persistence"
+	"github.com/teamhanko/hanko/backend/persistence/models"
+)
+
+// The reasons of relation.expired and relation.revoked events
+const (
+	ReasonLoginsUsed     = "logins_used"
+	ReasonTimeElapsed    = "time_elapsed"
+	ReasonRevoked        = "revoked"
+	ReasonAccountDeleted = "account_deleted"
+)
+
+// Payload is the body of a delivery
+type Payload struct {
+	ID        uuid.UUID   `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// UserData is the data of the user.* events
+type UserData struct {
+	UserId uuid.UUID `json:"user_id"`
+	Email  string    `json:"email,omitempty"`
+}
+
+// RelationData is the data of the relation.* events
+type RelationData struct {
+	RelationId   uuid.UUID  `json:"relation_id"`
+	ParentUserId uuid.UUID  `json:"parent_user_id"`
+	GuestUserId  uuid.UUID  `json:"guest_user_id"`
+	GrantId      *uuid.UUID `json:"grant_id,omitempty"`
+	Reason       string     `json:"reason,omitempty"`
+}
+
+func NewUserData(user models.User) UserData {
+	return UserData{UserId: user.ID, Email: user.Email}
+}
+
+func NewRelationData(relation models.UserGuestRelation, reason string) RelationData {
+	data := RelationData{
+		RelationId:   relation.ID,
+		ParentUserId: relation.ParentUserID,
+		GuestUserId:  relation.GuestUserID,
+		Reason:       reason,
+	}
+	if relation.AssociatedAccessGrantId != uuid.Nil {
+		grantId := relation.AssociatedAccessGrantId
+		data.GrantId = &grantId
+	}
+	return data
+}
+
+// NewEvent returns an outbox entry of the event with the data
+func NewEvent(eventType string, data interface{}) (models.WebhookEvent, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return models.WebhookEvent{}, err
+	}
+	now := time.Now().UTC()
+	payload, err := json.Marshal(Payload{ID: id, Type: eventType, CreatedAt: now, Data: data})
+	if err != nil {
+		return models.WebhookEvent{}, fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+	return models.WebhookEvent{
+		ID:        id,
+		Type:      eventType,
+		Payload:   string(payload),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+// Enqueue adds the event to the outbox. The persister must use the connection of the transaction of the change, so
+// that the event is only delivered if the change is committed.
+func Enqueue(persister persistence.WebhookPersister, eventType string, data interface{}) error {
+	event, err := NewEvent(eventType, data)
+	if err != nil {
+		return err
+	}
+	err = persister.CreateEvent(event)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue webhook event: %w", err)
+	}
+	return nil
+}